@@ -0,0 +1,22 @@
+package adapter
+
+import (
+	"context"
+	"crypto/x509"
+
+	"github.com/sagernet/sing/service"
+)
+
+type CertificateStore interface {
+	Pool() *x509.CertPool
+}
+
+// RootPoolFromContext returns the root certificates for TLS clients, nil to
+// use the system roots if no certificate store is configured.
+func RootPoolFromContext(ctx context.Context) *x509.CertPool {
+	certificateStore := service.FromContext[CertificateStore](ctx)
+	if certificateStore == nil {
+		return nil
+	}
+	return certificateStore.Pool()
+}
@@ -2,6 +2,7 @@ package endpoint
 
 import (
 	"context"
+	"sort"
 	"sync"
 
 	"github.com/sagernet/sing-box/adapter"
@@ -24,6 +25,19 @@ func Register[Options any](registry *Registry, outboundType string, constructor
 	})
 }
 
+// RegisterStub registers an endpoint type that is removed or not included in the
+// build, its options are accepted but creating it fails with err.
+func RegisterStub[Options any](registry *Registry, endpointType string, err error) {
+	registry.register(endpointType, func() any {
+		return new(Options)
+	}, func(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, rawOptions any) (adapter.Endpoint, error) {
+		return nil, err
+	})
+	registry.access.Lock()
+	defer registry.access.Unlock()
+	registry.stubs[endpointType] = true
+}
+
 var _ adapter.EndpointRegistry = (*Registry)(nil)
 
 type (
@@ -35,13 +49,29 @@ type Registry struct {
 	access      sync.Mutex
 	optionsType map[string]optionsConstructorFunc
 	constructor map[string]constructorFunc
+	stubs       map[string]bool
 }
 
 func NewRegistry() *Registry {
 	return &Registry{
 		optionsType: make(map[string]optionsConstructorFunc),
 		constructor: make(map[string]constructorFunc),
+		stubs:       make(map[string]bool),
+	}
+}
+
+// Types returns the registered types, excluding stubs.
+func (m *Registry) Types() []string {
+	m.access.Lock()
+	defer m.access.Unlock()
+	var types []string
+	for optionsType := range m.optionsType {
+		if !m.stubs[optionsType] {
+			types = append(types, optionsType)
+		}
 	}
+	sort.Strings(types)
+	return types
 }
 
 func (m *Registry) CreateOptions(outboundType string) (any, bool) {
@@ -28,6 +28,7 @@ type CacheFile interface {
 	LifecycleService
 
 	StoreFakeIP() bool
+	FakeIPReadOnly() bool
 	FakeIPStorage
 
 	StoreRDRC() bool
@@ -39,8 +40,35 @@ type CacheFile interface {
 	StoreSelected(group string, selected string) error
 	LoadGroupExpand(group string) (isExpand bool, loaded bool)
 	StoreGroupExpand(group string, expand bool) error
+	LoadGroupFilter(group string) *SavedGroupFilter
+	StoreGroupFilter(group string, saved *SavedGroupFilter) error
+	LoadURLTestGroup(group string) *SavedURLTestGroup
+	StoreURLTestGroup(group string, saved *SavedURLTestGroup) error
 	LoadRuleSet(tag string) *SavedRuleSet
 	SaveRuleSet(tag string, set *SavedRuleSet) error
+	LoadRuleGroup(group string) (enabled bool, loaded bool)
+	StoreRuleGroup(group string, enabled bool) error
+	LoadUserUsage(inbound string, user string) uint64
+	StoreUserUsage(inbound string, user string, used uint64) error
+}
+
+// SavedGroupFilter is the filter of a group set by the Clash API, and the
+// configured filter it replaced.
+type SavedGroupFilter struct {
+	Filter     OutboundGroupFilter `json:"filter"`
+	Configured OutboundGroupFilter `json:"configured"`
+}
+
+// SavedURLTestGroup is the selected outbound of a URLTest group and the
+// test results of its outbounds, keyed by real tag.
+type SavedURLTestGroup struct {
+	Selected string                       `json:"selected,omitempty"`
+	Delays   map[string]SavedURLTestDelay `json:"delays,omitempty"`
+}
+
+type SavedURLTestDelay struct {
+	Time  time.Time `json:"time"`
+	Delay uint16    `json:"delay"`
 }
 
 type SavedRuleSet struct {
@@ -100,6 +128,18 @@ type OutboundGroup interface {
 	All() []string
 }
 
+// FilterableOutboundGroup is a group whose outbounds can be narrowed by regular expressions at runtime.
+type FilterableOutboundGroup interface {
+	OutboundGroup
+	Filter() OutboundGroupFilter
+	SetFilter(filter OutboundGroupFilter) error
+}
+
+type OutboundGroupFilter struct {
+	Include string `json:"include,omitempty"`
+	Exclude string `json:"exclude,omitempty"`
+}
+
 type URLTestGroup interface {
 	OutboundGroup
 	URLTest(ctx context.Context) (map[string]uint16, error)
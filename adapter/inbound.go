@@ -2,7 +2,9 @@ package adapter
 
 import (
 	"context"
+	"net"
 	"net/netip"
+	"sync"
 	"time"
 
 	"github.com/sagernet/sing-box/common/process"
@@ -58,6 +60,13 @@ type InboundContext struct {
 	Client       string
 	SniffContext any
 
+	HTTPMethod    string
+	HTTPPath      string
+	HTTPUserAgent string
+
+	TLSFingerprintJA3 string
+	TLSFingerprintJA4 string
+
 	// cache
 
 	// Deprecated: implement in rule action
@@ -71,6 +80,9 @@ type InboundContext struct {
 	UDPDisableDomainUnmapping bool
 	UDPConnect                bool
 	UDPTimeout                time.Duration
+	UDPNATStrategy            C.UDPNATStrategy
+	DSCP                      *uint8
+	ECN                       *uint8
 
 	NetworkStrategy     *C.NetworkStrategy
 	NetworkType         []C.InterfaceType
@@ -79,7 +91,11 @@ type InboundContext struct {
 
 	DNSServer string
 
+	// DialHops records the detour dialers of an outbound chain, innermost first.
+	DialHops *DialHops
+
 	DestinationAddresses []netip.Addr
+	SourceMACAddress     net.HardwareAddr
 	SourceGeoIPCode      string
 	GeoIPCode            string
 	ProcessInfo          *process.Info
@@ -99,6 +115,33 @@ type InboundContext struct {
 	IgnoreDestinationIPCIDRMatch bool
 }
 
+type DialHop struct {
+	Outbound string
+	Latency  time.Duration
+}
+
+// DialHops collects the hops of one connection, it is safe for concurrent
+// use since an outbound may dial several detours in parallel.
+type DialHops struct {
+	access sync.Mutex
+	hops   []DialHop
+}
+
+func (h *DialHops) Add(hop DialHop) {
+	h.access.Lock()
+	defer h.access.Unlock()
+	h.hops = append(h.hops, hop)
+}
+
+func (h *DialHops) List() []DialHop {
+	if h == nil {
+		return nil
+	}
+	h.access.Lock()
+	defer h.access.Unlock()
+	return append([]DialHop(nil), h.hops...)
+}
+
 func (c *InboundContext) ResetRuleCache() {
 	c.IPCIDRMatchSource = false
 	c.IPCIDRAcceptEmpty = false
@@ -9,6 +9,7 @@ import (
 	"github.com/sagernet/sing-box/common/taskmonitor"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
 	"github.com/sagernet/sing/common"
 	E "github.com/sagernet/sing/common/exceptions"
 )
@@ -113,6 +114,9 @@ func (m *Manager) Remove(tag string) error {
 }
 
 func (m *Manager) Create(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, outboundType string, options any) error {
+	if listenWrapper, isListen := options.(option.ListenOptionsWrapper); isListen {
+		ctx = adapter.ContextWithTimeouts(ctx, adapter.TimeoutsFromContext(ctx).Override(listenWrapper.TakeListenOptions().Timeouts))
+	}
 	inbound, err := m.registry.Create(ctx, router, logger, tag, outboundType, options)
 	if err != nil {
 		return err
@@ -11,6 +11,7 @@ import (
 	"github.com/sagernet/sing-box/common/taskmonitor"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
 	"github.com/sagernet/sing/common"
 	E "github.com/sagernet/sing/common/exceptions"
 	"github.com/sagernet/sing/common/logger"
@@ -243,6 +244,9 @@ func (m *Manager) Create(ctx context.Context, router adapter.Router, logger log.
 	if tag == "" {
 		return os.ErrInvalid
 	}
+	if dialerWrapper, isDialer := options.(option.DialerOptionsWrapper); isDialer {
+		ctx = adapter.ContextWithTimeouts(ctx, adapter.TimeoutsFromContext(ctx).Override(dialerWrapper.TakeDialerOptions().Timeouts))
+	}
 	outbound, err := m.registry.CreateOutbound(ctx, router, logger, tag, inboundType, options)
 	if err != nil {
 		return err
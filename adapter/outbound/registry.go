@@ -2,6 +2,7 @@ package outbound
 
 import (
 	"context"
+	"sort"
 	"sync"
 
 	"github.com/sagernet/sing-box/adapter"
@@ -24,6 +25,19 @@ func Register[Options any](registry *Registry, outboundType string, constructor
 	})
 }
 
+// RegisterStub registers an outbound type that is removed or not included in the
+// build, its options are accepted but creating it fails with err.
+func RegisterStub[Options any](registry *Registry, outboundType string, err error) {
+	registry.register(outboundType, func() any {
+		return new(Options)
+	}, func(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, rawOptions any) (adapter.Outbound, error) {
+		return nil, err
+	})
+	registry.access.Lock()
+	defer registry.access.Unlock()
+	registry.stubs[outboundType] = true
+}
+
 var _ adapter.OutboundRegistry = (*Registry)(nil)
 
 type (
@@ -35,13 +49,29 @@ type Registry struct {
 	access       sync.Mutex
 	optionsType  map[string]optionsConstructorFunc
 	constructors map[string]constructorFunc
+	stubs        map[string]bool
 }
 
 func NewRegistry() *Registry {
 	return &Registry{
 		optionsType:  make(map[string]optionsConstructorFunc),
 		constructors: make(map[string]constructorFunc),
+		stubs:        make(map[string]bool),
+	}
+}
+
+// Types returns the registered types, excluding stubs.
+func (r *Registry) Types() []string {
+	r.access.Lock()
+	defer r.access.Unlock()
+	var types []string
+	for optionsType := range r.optionsType {
+		if !r.stubs[optionsType] {
+			types = append(types, optionsType)
+		}
 	}
+	sort.Strings(types)
+	return types
 }
 
 func (r *Registry) CreateOptions(outboundType string) (any, bool) {
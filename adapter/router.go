@@ -2,6 +2,7 @@ package adapter
 
 import (
 	"context"
+	"crypto/tls"
 	"net"
 	"net/http"
 	"net/netip"
@@ -26,10 +27,13 @@ type Router interface {
 
 	ConnectionRouter
 	PreMatch(metadata InboundContext) error
+	TestRoute(ctx context.Context, metadata InboundContext) RouteTestResult
+	SniffBufferUsage() map[string]SniffBufferUsage
 	ConnectionRouterEx
 
 	GeoIPReader() *geoip.Reader
 	LoadGeosite(code string) (Rule, error)
+	UpdateGeoDatabases(ctx context.Context) error
 	RuleSet(tag string) (RuleSet, bool)
 	NeedWIFIState() bool
 	RuleSets() []RuleSet
@@ -41,12 +45,44 @@ type Router interface {
 	Rules() []Rule
 	DNSRules() []DNSRule
 	DefaultDNSServer() string
+	RuleGroups() []RuleGroup
+	SetRuleGroupEnabled(name string, enabled bool) error
+	SetModeOverlay(ruleGroups map[string]bool, final string) error
 
-	SetTracker(tracker ConnectionTracker)
+	AppendTracker(tracker ConnectionTracker)
+	SetDNSQueryTracker(tracker DNSQueryTracker)
 
 	ResetNetwork()
 }
 
+// RuleGroup is a named group of route and DNS rules that can be disabled at
+// runtime.
+type RuleGroup struct {
+	Name         string
+	Enabled      bool
+	Overridden   bool
+	RuleCount    int
+	DNSRuleCount int
+}
+
+type dnsServerContextKey struct{}
+
+// ContextWithDNSServer returns a context in which Router.Exchange sends the
+// query to the given server instead of matching DNS rules, an empty server
+// restores rule matching.
+func ContextWithDNSServer(ctx context.Context, server string) context.Context {
+	return context.WithValue(ctx, (*dnsServerContextKey)(nil), server)
+}
+
+func DNSServerFromContext(ctx context.Context) string {
+	server, _ := ctx.Value((*dnsServerContextKey)(nil)).(string)
+	return server
+}
+
+type DNSQueryTracker interface {
+	TrackDNSQuery(domain string, source netip.Addr, blocked bool)
+}
+
 type ConnectionTracker interface {
 	RoutedConnection(ctx context.Context, conn net.Conn, metadata InboundContext, matchedRule Rule, matchOutbound Outbound) net.Conn
 	RoutedPacketConnection(ctx context.Context, conn N.PacketConn, metadata InboundContext, matchedRule Rule, matchOutbound Outbound) N.PacketConn
@@ -91,12 +127,14 @@ type RuleSetMetadata struct {
 	Format              string
 }
 type HTTPStartContext struct {
+	ctx             context.Context
 	access          sync.Mutex
 	httpClientCache map[string]*http.Client
 }
 
-func NewHTTPStartContext() *HTTPStartContext {
+func NewHTTPStartContext(ctx context.Context) *HTTPStartContext {
 	return &HTTPStartContext{
+		ctx:             ctx,
 		httpClientCache: make(map[string]*http.Client),
 	}
 }
@@ -114,6 +152,9 @@ func (c *HTTPStartContext) HTTPClient(detour string, dialer N.Dialer) *http.Clie
 			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
 				return dialer.DialContext(ctx, network, M.ParseSocksaddr(addr))
 			},
+			TLSClientConfig: &tls.Config{
+				RootCAs: RootPoolFromContext(c.ctx),
+			},
 		},
 	}
 	c.httpClientCache[detour] = httpClient
@@ -127,3 +168,25 @@ func (c *HTTPStartContext) Close() {
 		client.CloseIdleConnections()
 	}
 }
+
+type RouteTestResult struct {
+	Context   string
+	DNSRule   *RouteTestMatch
+	DNSServer string
+	Rules     []RouteTestMatch
+	Outbound  string
+}
+
+type RouteTestMatch struct {
+	Index  int
+	Rule   string
+	Action string
+}
+
+// SniffBufferUsage is the memory held by sniffing connections of an inbound
+// with sniff_buffer_limit set, and the number of times sniffing was skipped.
+type SniffBufferUsage struct {
+	Used    int64
+	Limit   int64
+	Skipped uint64
+}
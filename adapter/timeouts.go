@@ -0,0 +1,62 @@
+package adapter
+
+import (
+	"context"
+	"time"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+)
+
+// Timeouts holds the handshake and relay timeouts in effect for an inbound or
+// outbound, the global values are overridden by the options of each of them.
+type Timeouts struct {
+	TCPHandshake time.Duration
+	TLSHandshake time.Duration
+	UDPNAT       time.Duration
+	// zero disables the idle timeout of relayed TCP connections
+	RelayIdle time.Duration
+}
+
+func DefaultTimeouts() Timeouts {
+	return Timeouts{
+		TCPHandshake: C.TCPConnectTimeout,
+		TLSHandshake: C.TCPTimeout,
+		UDPNAT:       C.UDPTimeout,
+	}
+}
+
+func (t Timeouts) Override(options *option.TimeoutOptions) Timeouts {
+	if options == nil {
+		return t
+	}
+	if options.TCPHandshake > 0 {
+		t.TCPHandshake = time.Duration(options.TCPHandshake)
+	}
+	if options.TLSHandshake > 0 {
+		t.TLSHandshake = time.Duration(options.TLSHandshake)
+	}
+	if options.UDPNAT > 0 {
+		t.UDPNAT = time.Duration(options.UDPNAT)
+	}
+	if options.RelayIdle > 0 {
+		t.RelayIdle = time.Duration(options.RelayIdle)
+	}
+	return t
+}
+
+type timeoutsKey struct{}
+
+func ContextWithTimeouts(ctx context.Context, timeouts Timeouts) context.Context {
+	return context.WithValue(ctx, (*timeoutsKey)(nil), timeouts)
+}
+
+// TimeoutsFromContext returns the timeouts of the inbound or outbound the
+// context belongs to, or the defaults if none is set.
+func TimeoutsFromContext(ctx context.Context) Timeouts {
+	timeouts, loaded := ctx.Value((*timeoutsKey)(nil)).(Timeouts)
+	if !loaded {
+		return DefaultTimeouts()
+	}
+	return timeouts
+}
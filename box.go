@@ -12,13 +12,17 @@ import (
 	"github.com/sagernet/sing-box/adapter/endpoint"
 	"github.com/sagernet/sing-box/adapter/inbound"
 	"github.com/sagernet/sing-box/adapter/outbound"
+	"github.com/sagernet/sing-box/common/certificate"
 	"github.com/sagernet/sing-box/common/dialer"
 	"github.com/sagernet/sing-box/common/taskmonitor"
 	"github.com/sagernet/sing-box/common/tls"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/experimental"
 	"github.com/sagernet/sing-box/experimental/cachefile"
+	"github.com/sagernet/sing-box/experimental/capture"
 	"github.com/sagernet/sing-box/experimental/libbox/platform"
+	"github.com/sagernet/sing-box/experimental/portforward"
+	"github.com/sagernet/sing-box/experimental/sshtunnel"
 	"github.com/sagernet/sing-box/log"
 	"github.com/sagernet/sing-box/option"
 	"github.com/sagernet/sing-box/protocol/direct"
@@ -34,17 +38,19 @@ import (
 var _ adapter.Service = (*Box)(nil)
 
 type Box struct {
-	createdAt  time.Time
-	logFactory log.Factory
-	logger     log.ContextLogger
-	network    *route.NetworkManager
-	endpoint   *endpoint.Manager
-	inbound    *inbound.Manager
-	outbound   *outbound.Manager
-	connection *route.ConnectionManager
-	router     *route.Router
-	services   []adapter.LifecycleService
-	done       chan struct{}
+	createdAt    time.Time
+	logFactory   log.Factory
+	logger       log.ContextLogger
+	network      *route.NetworkManager
+	endpoint     *endpoint.Manager
+	inbound      *inbound.Manager
+	outbound     *outbound.Manager
+	connection   *route.ConnectionManager
+	router       *route.Router
+	services     []adapter.LifecycleService
+	certificates *tls.CertificateReloader
+	drainTimeout time.Duration
+	done         chan struct{}
 }
 
 type Options struct {
@@ -100,6 +106,7 @@ func New(options Options) (*Box, error) {
 	}
 
 	ctx = pause.WithDefaultManager(ctx)
+	ctx = adapter.ContextWithTimeouts(ctx, adapter.DefaultTimeouts().Override(options.Timeouts))
 	experimentalOptions := common.PtrValueOrDefault(options.Experimental)
 	applyDebugOptions(common.PtrValueOrDefault(experimentalOptions.Debug))
 	var needCacheFile bool
@@ -135,6 +142,8 @@ func New(options Options) (*Box, error) {
 	endpointManager := endpoint.NewManager(logFactory.NewLogger("endpoint"), endpointRegistry)
 	inboundManager := inbound.NewManager(logFactory.NewLogger("inbound"), inboundRegistry, endpointManager)
 	outboundManager := outbound.NewManager(logFactory.NewLogger("outbound"), outboundRegistry, endpointManager, routeOptions.Final)
+	certificateReloader := tls.NewCertificateReloader()
+	service.MustRegister[*tls.CertificateReloader](ctx, certificateReloader)
 	service.MustRegister[adapter.EndpointManager](ctx, endpointManager)
 	service.MustRegister[adapter.InboundManager](ctx, inboundManager)
 	service.MustRegister[adapter.OutboundManager](ctx, outboundManager)
@@ -144,7 +153,18 @@ func New(options Options) (*Box, error) {
 		return nil, E.Cause(err, "initialize network manager")
 	}
 	service.MustRegister[adapter.NetworkManager](ctx, networkManager)
-	connectionManager := route.NewConnectionManager(logFactory.NewLogger("connection"))
+	if options.Certificate != nil {
+		certificateStore, err := certificate.NewStore(*options.Certificate)
+		if err != nil {
+			return nil, E.Cause(err, "initialize certificate store")
+		}
+		service.MustRegister[adapter.CertificateStore](ctx, certificateStore)
+	}
+	udpTimeout := make(map[string]time.Duration, len(routeOptions.UDPTimeout))
+	for protocol, timeout := range routeOptions.UDPTimeout {
+		udpTimeout[protocol] = time.Duration(timeout)
+	}
+	connectionManager := route.NewConnectionManager(logFactory.NewLogger("connection"), udpTimeout)
 	service.MustRegister[adapter.ConnectionManager](ctx, connectionManager)
 	router, err := route.NewRouter(ctx, logFactory, routeOptions, common.PtrValueOrDefault(options.DNS))
 	if err != nil {
@@ -248,7 +268,7 @@ func New(options Options) (*Box, error) {
 		if err != nil {
 			return nil, E.Cause(err, "create clash-server")
 		}
-		router.SetTracker(clashServer)
+		router.AppendTracker(clashServer)
 		service.MustRegister[adapter.ClashServer](ctx, clashServer)
 		services = append(services, clashServer)
 	}
@@ -258,11 +278,33 @@ func New(options Options) (*Box, error) {
 			return nil, E.Cause(err, "create v2ray-server")
 		}
 		if v2rayServer.StatsService() != nil {
-			router.SetTracker(v2rayServer.StatsService())
+			router.AppendTracker(v2rayServer.StatsService())
 			services = append(services, v2rayServer)
 			service.MustRegister[adapter.V2RayServer](ctx, v2rayServer)
 		}
 	}
+	for i, tunnelOptions := range experimentalOptions.SSHTunnels {
+		tunnelService, err := sshtunnel.NewService(ctx, logFactory.NewLogger("ssh-tunnel"), tunnelOptions)
+		if err != nil {
+			return nil, E.Cause(err, "create ssh tunnel[", i, "]")
+		}
+		services = append(services, tunnelService)
+	}
+	for i, forwardOptions := range experimentalOptions.PortForwards {
+		forwardService, err := portforward.NewService(ctx, logFactory.NewLogger("port-forward"), forwardOptions)
+		if err != nil {
+			return nil, E.Cause(err, "create port forward[", i, "]")
+		}
+		services = append(services, forwardService)
+	}
+	for i, captureOptions := range experimentalOptions.PacketCaptures {
+		captureService, err := capture.NewService(ctx, logFactory.NewLogger("packet-capture"), captureOptions)
+		if err != nil {
+			return nil, E.Cause(err, "create packet capture[", i, "]")
+		}
+		router.AppendTracker(captureService)
+		services = append(services, captureService)
+	}
 	if ntpOptions.Enabled {
 		ntpDialer, err := dialer.New(ctx, ntpOptions.DialerOptions)
 		if err != nil {
@@ -280,17 +322,19 @@ func New(options Options) (*Box, error) {
 		services = append(services, adapter.NewLifecycleService(ntpService, "ntp service"))
 	}
 	return &Box{
-		network:    networkManager,
-		endpoint:   endpointManager,
-		inbound:    inboundManager,
-		outbound:   outboundManager,
-		connection: connectionManager,
-		router:     router,
-		createdAt:  createdAt,
-		logFactory: logFactory,
-		logger:     logFactory.Logger(),
-		services:   services,
-		done:       make(chan struct{}),
+		network:      networkManager,
+		endpoint:     endpointManager,
+		inbound:      inboundManager,
+		outbound:     outboundManager,
+		connection:   connectionManager,
+		router:       router,
+		createdAt:    createdAt,
+		logFactory:   logFactory,
+		logger:       logFactory.Logger(),
+		services:     services,
+		certificates: certificateReloader,
+		drainTimeout: time.Duration(routeOptions.DrainTimeout),
+		done:         make(chan struct{}),
 	}, nil
 }
 
@@ -391,13 +435,27 @@ func (s *Box) start() error {
 	return nil
 }
 
+// Close waits for active connections to drain if drain_timeout is set, then closes the box.
 func (s *Box) Close() error {
+	return s.close(s.drainTimeout > 0)
+}
+
+// CloseImmediately closes the box without waiting for active connections, so that a reload
+// is not delayed by the drain timeout.
+func (s *Box) CloseImmediately() error {
+	return s.close(false)
+}
+
+func (s *Box) close(drain bool) error {
 	select {
 	case <-s.done:
 		return os.ErrClosed
 	default:
 		close(s.done)
 	}
+	if drain {
+		s.drain()
+	}
 	err := common.Close(
 		s.inbound, s.outbound, s.endpoint, s.router, s.connection, s.network,
 	)
@@ -412,6 +470,33 @@ func (s *Box) Close() error {
 	return err
 }
 
+func (s *Box) drain() {
+	// Closing inbounds stops accepting new connections,
+	// it is a no-op when called again by Close.
+	err := s.inbound.Close()
+	if err != nil {
+		s.logger.Error(E.Cause(err, "close inbounds"))
+	}
+	s.logger.Info("draining connections, timeout ", s.drainTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), s.drainTimeout)
+	defer cancel()
+	remaining := s.connection.Drain(ctx)
+	if remaining > 0 {
+		s.logger.Warn("drain timeout exceeded, closing ", remaining, " connections")
+	}
+}
+
+// ReloadCertificates reloads certificate and key files of all TLS inbounds
+// in place, without closing existing connections.
+func (s *Box) ReloadCertificates() error {
+	return s.certificates.Reload()
+}
+
+// DrainTimeout returns the configured drain timeout, extra time Close may take.
+func (s *Box) DrainTimeout() time.Duration {
+	return s.drainTimeout
+}
+
 func (s *Box) Network() adapter.NetworkManager {
 	return s.network
 }
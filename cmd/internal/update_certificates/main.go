@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+)
+
+const (
+	sourceURL  = "https://ccadb.my.salesforce-sites.com/mozilla/IncludedRootsPEMTxt?TrustBitsInclude=Websites"
+	outputPath = "common/certificate/mozilla.pem"
+)
+
+func main() {
+	err := updateMozillaIncluded()
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func updateMozillaIncluded() error {
+	response, err := http.Get(sourceURL)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return E.New("unexpected status: ", response.Status)
+	}
+	content, err := io.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+	output := &bytes.Buffer{}
+	var certificates int
+	for {
+		var block *pem.Block
+		block, content = pem.Decode(content)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		_, err = x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return E.Cause(err, "parse certificate ", certificates)
+		}
+		common.Must(pem.Encode(output, &pem.Block{Type: "CERTIFICATE", Bytes: block.Bytes}))
+		certificates++
+	}
+	if certificates == 0 {
+		return E.New("no certificates found")
+	}
+	header := F.ToString(
+		"# Mozilla included CA certificates trusted for websites\n",
+		"# Source: ", sourceURL, "\n",
+		"# Updated: ", time.Now().UTC().Format(time.DateOnly), "\n",
+		"# Certificates: ", certificates, "\n",
+		"# Generated by cmd/internal/update_certificates, do not edit.\n",
+	)
+	err = os.WriteFile(outputPath, append([]byte(header), output.Bytes()...), 0o644)
+	if err != nil {
+		return err
+	}
+	log.Info("updated ", outputPath, " with ", certificates, " certificates")
+	return nil
+}
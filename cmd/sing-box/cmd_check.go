@@ -2,16 +2,26 @@ package main
 
 import (
 	"context"
+	"os"
 
 	"github.com/sagernet/sing-box"
 	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/json"
 
 	"github.com/spf13/cobra"
 )
 
+var commandCheckFlagJSON bool
+
 var commandCheck = &cobra.Command{
 	Use:   "check",
 	Short: "Check configuration",
+	Long: `Check configuration.
+
+Tag references between inbounds, outbounds, DNS servers and rule-sets are validated,
+and unreachable rules and unused outbounds and rule-sets are reported as warnings.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		err := check()
 		if err != nil {
@@ -22,14 +32,68 @@ var commandCheck = &cobra.Command{
 }
 
 func init() {
+	commandCheck.Flags().BoolVar(&commandCheckFlagJSON, "json", false, "Output issues in JSON")
 	mainCommand.AddCommand(commandCheck)
 }
 
 func check() error {
 	options, err := readConfigAndMerge()
+	if err != nil {
+		if commandCheckFlagJSON {
+			report := new(checkReport)
+			report.Error("", err)
+			return writeCheckReport(report)
+		}
+		return err
+	}
+	report := checkReferences(options)
+	if !report.HasError() {
+		err = checkInstance(options)
+		if err != nil {
+			report.Error("", err)
+		}
+	}
+	if commandCheckFlagJSON {
+		return writeCheckReport(report)
+	}
+	var errorCount int
+	for _, issue := range report.Issues {
+		message := issue.Message
+		if issue.Path != "" {
+			message = issue.Path + ": " + message
+		}
+		if issue.Level == checkLevelError {
+			errorCount++
+			log.Error(message)
+		} else {
+			log.Warn(message)
+		}
+	}
+	if errorCount > 0 {
+		return E.New(errorCount, " error(s) found in configuration")
+	}
+	return nil
+}
+
+// writeCheckReport writes the report as a JSON object for editors and scripts,
+// and exits with 1 if it contains errors.
+func writeCheckReport(report *checkReport) error {
+	if report.Issues == nil {
+		report.Issues = []checkIssue{}
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	err := encoder.Encode(report)
 	if err != nil {
 		return err
 	}
+	if report.HasError() {
+		os.Exit(1)
+	}
+	return nil
+}
+
+func checkInstance(options option.Options) error {
 	ctx, cancel := context.WithCancel(globalCtx)
 	instance, err := box.New(box.Options{
 		Context: ctx,
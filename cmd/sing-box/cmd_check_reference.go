@@ -0,0 +1,394 @@
+package main
+
+import (
+	"reflect"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	F "github.com/sagernet/sing/common/format"
+)
+
+const (
+	checkLevelError   = "error"
+	checkLevelWarning = "warning"
+)
+
+type checkIssue struct {
+	Level   string `json:"level"`
+	Path    string `json:"path,omitempty"`
+	Message string `json:"message"`
+}
+
+type checkReport struct {
+	Issues []checkIssue `json:"issues"`
+}
+
+func (r *checkReport) Error(path string, message ...any) {
+	r.Issues = append(r.Issues, checkIssue{Level: checkLevelError, Path: path, Message: F.ToString(message...)})
+}
+
+func (r *checkReport) Warn(path string, message ...any) {
+	r.Issues = append(r.Issues, checkIssue{Level: checkLevelWarning, Path: path, Message: F.ToString(message...)})
+}
+
+func (r *checkReport) HasError() bool {
+	return common.Any(r.Issues, func(it checkIssue) bool {
+		return it.Level == checkLevelError
+	})
+}
+
+type checkTagTable struct {
+	report *checkReport
+	name   string
+	tags   []string
+	paths  map[string]string
+	used   map[string]bool
+}
+
+func newCheckTagTable(report *checkReport, name string) *checkTagTable {
+	return &checkTagTable{
+		report: report,
+		name:   name,
+		paths:  make(map[string]string),
+		used:   make(map[string]bool),
+	}
+}
+
+func (t *checkTagTable) Define(path string, tag string) {
+	if existsPath, loaded := t.paths[tag]; loaded {
+		t.report.Error(path, "duplicate ", t.name, " tag: ", tag, ", already defined at ", existsPath)
+		return
+	}
+	t.tags = append(t.tags, tag)
+	t.paths[tag] = path
+}
+
+func (t *checkTagTable) Reference(path string, tag string) {
+	if tag == "" {
+		return
+	}
+	if _, loaded := t.paths[tag]; !loaded {
+		t.report.Error(path, t.name, " not found: ", tag)
+		return
+	}
+	t.used[tag] = true
+}
+
+func (t *checkTagTable) ReferenceList(path string, tags []string) {
+	for i, tag := range tags {
+		t.Reference(F.ToString(path, "[", i, "]"), tag)
+	}
+}
+
+func (t *checkTagTable) WarnUnused() {
+	for _, tag := range t.tags {
+		if !t.used[tag] {
+			t.report.Warn(t.paths[tag], "unused ", t.name, ": ", tag)
+		}
+	}
+}
+
+type referenceChecker struct {
+	report     *checkReport
+	inbounds   *checkTagTable
+	outbounds  *checkTagTable
+	dnsServers *checkTagTable
+	ruleSets   *checkTagTable
+}
+
+// checkReferences validates tag references between parts of the configuration,
+// and reports unreachable rules and unused outbounds and rule-sets as warnings.
+func checkReferences(options option.Options) *checkReport {
+	report := new(checkReport)
+	c := &referenceChecker{
+		report:     report,
+		inbounds:   newCheckTagTable(report, "inbound"),
+		outbounds:  newCheckTagTable(report, "outbound"),
+		dnsServers: newCheckTagTable(report, "DNS server"),
+		ruleSets:   newCheckTagTable(report, "rule-set"),
+	}
+	c.defineTags(options)
+	c.checkEndpoints(options.Endpoints)
+	c.checkInbounds(options.Inbounds)
+	c.checkOutbounds(options.Outbounds)
+	c.checkRoute(common.PtrValueOrDefault(options.Route), options.Outbounds)
+	c.checkDNS(common.PtrValueOrDefault(options.DNS))
+	c.checkExperimental(common.PtrValueOrDefault(options.Experimental))
+	c.outbounds.WarnUnused()
+	c.ruleSets.WarnUnused()
+	return report
+}
+
+func (c *referenceChecker) defineTags(options option.Options) {
+	for i, endpoint := range options.Endpoints {
+		tag := checkTag(endpoint.Tag, i)
+		path := F.ToString("endpoints[", i, "]")
+		c.inbounds.Define(path, tag)
+		c.outbounds.Define(path, tag)
+		// endpoints also accept connections, so they are never reported as unused
+		c.outbounds.used[tag] = true
+	}
+	for i, inbound := range options.Inbounds {
+		c.inbounds.Define(F.ToString("inbounds[", i, "]"), checkTag(inbound.Tag, i))
+	}
+	for i, outbound := range options.Outbounds {
+		c.outbounds.Define(F.ToString("outbounds[", i, "]"), checkTag(outbound.Tag, i))
+	}
+	if options.DNS != nil {
+		for i, server := range options.DNS.Servers {
+			c.dnsServers.Define(F.ToString("dns.servers[", i, "]"), checkTag(server.Tag, i))
+		}
+	}
+	if options.Route != nil {
+		for i, ruleSet := range options.Route.RuleSet {
+			c.ruleSets.Define(F.ToString("route.rule_set[", i, "]"), ruleSet.Tag)
+		}
+	}
+}
+
+// checkTag returns the tag used at runtime, which is the index for objects without a tag.
+func checkTag(tag string, index int) string {
+	if tag != "" {
+		return tag
+	}
+	return F.ToString(index)
+}
+
+func (c *referenceChecker) checkDialer(path string, options any) {
+	dialerWrapper, isDialer := options.(option.DialerOptionsWrapper)
+	if !isDialer {
+		return
+	}
+	dialerOptions := dialerWrapper.TakeDialerOptions()
+	c.outbounds.Reference(path+".detour", dialerOptions.Detour)
+	if dialerOptions.DomainResolver != nil {
+		c.dnsServers.Reference(path+".domain_resolver.server", dialerOptions.DomainResolver.Server)
+	}
+}
+
+func (c *referenceChecker) checkEndpoints(endpoints []option.Endpoint) {
+	for i, endpoint := range endpoints {
+		c.checkDialer(F.ToString("endpoints[", i, "]"), endpoint.Options)
+	}
+}
+
+func (c *referenceChecker) checkInbounds(inbounds []option.Inbound) {
+	for i, inbound := range inbounds {
+		path := F.ToString("inbounds[", i, "]")
+		if listenWrapper, isListen := inbound.Options.(option.ListenOptionsWrapper); isListen {
+			c.inbounds.Reference(path+".detour", listenWrapper.TakeListenOptions().Detour)
+		}
+		if tunOptions, isTun := inbound.Options.(*option.TunInboundOptions); isTun {
+			c.ruleSets.ReferenceList(path+".route_address_set", tunOptions.RouteAddressSet)
+			c.ruleSets.ReferenceList(path+".route_exclude_address_set", tunOptions.RouteExcludeAddressSet)
+			if tunOptions.EncryptedDNSHijack != nil {
+				c.ruleSets.ReferenceList(path+".encrypted_dns_hijack.rule_set", tunOptions.EncryptedDNSHijack.RuleSet)
+			}
+		}
+	}
+}
+
+func (c *referenceChecker) checkOutbounds(outbounds []option.Outbound) {
+	for i, outbound := range outbounds {
+		path := F.ToString("outbounds[", i, "]")
+		c.checkDialer(path, outbound.Options)
+		switch outboundOptions := outbound.Options.(type) {
+		case *option.SelectorOutboundOptions:
+			c.outbounds.ReferenceList(path+".outbounds", outboundOptions.Outbounds)
+			c.outbounds.Reference(path+".default", outboundOptions.Default)
+		case *option.URLTestOutboundOptions:
+			c.outbounds.ReferenceList(path+".outbounds", outboundOptions.Outbounds)
+		case *option.FallbackOutboundOptions:
+			c.outbounds.ReferenceList(path+".outbounds", outboundOptions.Outbounds)
+		case *option.RotateOutboundOptions:
+			c.outbounds.ReferenceList(path+".outbounds", outboundOptions.Outbounds)
+		case *option.DNSOutboundOptions:
+			for j, queryTypeServer := range outboundOptions.QueryTypeServer {
+				c.dnsServers.Reference(F.ToString(path, ".query_type_server[", j, "].server"), queryTypeServer.Server)
+			}
+		}
+	}
+}
+
+func (c *referenceChecker) checkRoute(options option.RouteOptions, outbounds []option.Outbound) {
+	if options.Final != "" {
+		c.outbounds.Reference("route.final", options.Final)
+	} else if len(outbounds) > 0 {
+		c.outbounds.used[checkTag(outbounds[0].Tag, 0)] = true
+	}
+	c.checkRules("route.rules", options.Rules)
+	for i, routeContext := range options.Contexts {
+		path := F.ToString("route.contexts[", i, "]")
+		c.inbounds.ReferenceList(path+".inbound", routeContext.Inbound)
+		c.checkRules(path+".rules", routeContext.Rules)
+		c.outbounds.Reference(path+".final", routeContext.Final)
+	}
+	for i, ruleSet := range options.RuleSet {
+		path := F.ToString("route.rule_set[", i, "]")
+		c.outbounds.Reference(path+".download_detour", ruleSet.RemoteOptions.DownloadDetour)
+	}
+	if options.GeoIP != nil {
+		c.outbounds.Reference("route.geoip.download_detour", options.GeoIP.DownloadDetour)
+	}
+	if options.Geosite != nil {
+		c.outbounds.Reference("route.geosite.download_detour", options.Geosite.DownloadDetour)
+	}
+}
+
+func (c *referenceChecker) checkRules(path string, rules []option.Rule) {
+	for i, rule := range rules {
+		c.checkRule(F.ToString(path, "[", i, "]"), rule, true)
+	}
+	catchAll := -1
+	for i, rule := range rules {
+		if catchAll >= 0 {
+			c.report.Warn(F.ToString(path, "[", i, "]"), "unreachable rule after catch-all rule at ", path, "[", catchAll, "]")
+		} else if isCatchAllRule(rule) {
+			catchAll = i
+		}
+	}
+}
+
+func (c *referenceChecker) checkRule(path string, rule option.Rule, withAction bool) {
+	var action option.RuleAction
+	switch rule.Type {
+	case C.RuleTypeDefault:
+		c.inbounds.ReferenceList(path+".inbound", rule.DefaultOptions.Inbound)
+		c.ruleSets.ReferenceList(path+".rule_set", rule.DefaultOptions.RuleSet)
+		action = rule.DefaultOptions.RuleAction
+	case C.RuleTypeLogical:
+		for i, subRule := range rule.LogicalOptions.Rules {
+			c.checkRule(F.ToString(path, ".rules[", i, "]"), subRule, false)
+		}
+		action = rule.LogicalOptions.RuleAction
+	}
+	if !withAction {
+		return
+	}
+	switch action.Action {
+	case C.RuleActionTypeRoute:
+		c.outbounds.Reference(path+".outbound", action.RouteOptions.Outbound)
+	case C.RuleActionTypeDirect:
+		c.checkDialer(path, (*option.DialerOptions)(&action.DirectOptions))
+	case C.RuleActionTypeResolve:
+		c.dnsServers.Reference(path+".server", action.ResolveOptions.Server)
+	}
+}
+
+// isCatchAllRule reports whether the rule matches all connections and stops matching,
+// so that following rules are never reached. Rules in groups are ignored since groups
+// can be disabled at runtime.
+func isCatchAllRule(rule option.Rule) bool {
+	if rule.Type != C.RuleTypeDefault {
+		return false
+	}
+	rawRule := rule.DefaultOptions.RawDefaultRule
+	if rawRule.Invert || rawRule.Group != "" {
+		return false
+	}
+	rawRule.RuleSetIPCIDRMatchSource = false
+	rawRule.Deprecated_RulesetIPCIDRMatchSource = false
+	if !reflect.DeepEqual(rawRule, option.RawDefaultRule{}) {
+		return false
+	}
+	switch rule.DefaultOptions.Action {
+	case C.RuleActionTypeRoute, C.RuleActionTypeDirect, C.RuleActionTypeReject, C.RuleActionTypeHijackDNS:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *referenceChecker) checkDNS(options option.DNSOptions) {
+	for i, server := range options.Servers {
+		path := F.ToString("dns.servers[", i, "]")
+		c.outbounds.Reference(path+".detour", server.Detour)
+		c.dnsServers.Reference(path+".address_resolver", server.AddressResolver)
+		if server.Group != nil {
+			c.dnsServers.ReferenceList(path+".group.servers", server.Group.Servers)
+		}
+	}
+	for i, rule := range options.Rules {
+		c.checkDNSRule(F.ToString("dns.rules[", i, "]"), rule, true)
+	}
+	catchAll := -1
+	for i, rule := range options.Rules {
+		if catchAll >= 0 {
+			c.report.Warn(F.ToString("dns.rules[", i, "]"), "unreachable rule after catch-all rule at dns.rules[", catchAll, "]")
+		} else if isCatchAllDNSRule(rule) {
+			catchAll = i
+		}
+	}
+	c.dnsServers.Reference("dns.final", options.Final)
+}
+
+func (c *referenceChecker) checkDNSRule(path string, rule option.DNSRule, withAction bool) {
+	var action option.DNSRuleAction
+	switch rule.Type {
+	case C.RuleTypeDefault:
+		c.inbounds.ReferenceList(path+".inbound", rule.DefaultOptions.Inbound)
+		c.outbounds.ReferenceList(path+".outbound", rule.DefaultOptions.Outbound)
+		c.ruleSets.ReferenceList(path+".rule_set", rule.DefaultOptions.RuleSet)
+		action = rule.DefaultOptions.DNSRuleAction
+	case C.RuleTypeLogical:
+		for i, subRule := range rule.LogicalOptions.Rules {
+			c.checkDNSRule(F.ToString(path, ".rules[", i, "]"), subRule, false)
+		}
+		action = rule.LogicalOptions.DNSRuleAction
+	}
+	if withAction && action.Action == C.RuleActionTypeRoute {
+		c.dnsServers.Reference(path+".server", action.RouteOptions.Server)
+	}
+}
+
+func isCatchAllDNSRule(rule option.DNSRule) bool {
+	if rule.Type != C.RuleTypeDefault {
+		return false
+	}
+	rawRule := rule.DefaultOptions.RawDefaultDNSRule
+	if rawRule.Invert || rawRule.Group != "" {
+		return false
+	}
+	rawRule.RuleSetIPCIDRMatchSource = false
+	rawRule.RuleSetIPCIDRAcceptEmpty = false
+	rawRule.Deprecated_RulesetIPCIDRMatchSource = false
+	if !reflect.DeepEqual(rawRule, option.RawDefaultDNSRule{}) {
+		return false
+	}
+	switch rule.DefaultOptions.Action {
+	case C.RuleActionTypeRoute, C.RuleActionTypeReject:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *referenceChecker) checkExperimental(options option.ExperimentalOptions) {
+	if options.ClashAPI != nil {
+		c.outbounds.Reference("experimental.clash_api.external_ui_download_detour", options.ClashAPI.ExternalUIDownloadDetour)
+		c.outbounds.Reference("experimental.clash_api.core_upgrade_detour", options.ClashAPI.CoreUpgradeDetour)
+		for i, mode := range options.ClashAPI.Modes {
+			c.outbounds.Reference(F.ToString("experimental.clash_api.modes[", i, "].final"), mode.Final)
+		}
+	}
+	if options.V2RayAPI != nil && options.V2RayAPI.Stats != nil {
+		c.inbounds.ReferenceList("experimental.v2ray_api.stats.inbounds", options.V2RayAPI.Stats.Inbounds)
+		c.outbounds.ReferenceList("experimental.v2ray_api.stats.outbounds", options.V2RayAPI.Stats.Outbounds)
+	}
+	for i, sshTunnel := range options.SSHTunnels {
+		path := F.ToString("experimental.ssh_tunnels[", i, "]")
+		c.checkDialer(path, &sshTunnel.DialerOptions)
+		for j, forward := range sshTunnel.Forwards {
+			c.inbounds.Reference(F.ToString(path, ".forwards[", j, "].inbound"), forward.Inbound)
+		}
+	}
+	for i, portForward := range options.PortForwards {
+		c.outbounds.Reference(F.ToString("experimental.port_forwards[", i, "].outbound"), portForward.Outbound)
+	}
+	for i, packetCapture := range options.PacketCaptures {
+		path := F.ToString("experimental.packet_captures[", i, "]")
+		c.inbounds.ReferenceList(path+".inbound", packetCapture.Inbound)
+		c.outbounds.ReferenceList(path+".outbound", packetCapture.Outbound)
+	}
+}
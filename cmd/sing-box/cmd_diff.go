@@ -0,0 +1,195 @@
+package main
+
+import (
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/sagernet/sing-box/log"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+	"github.com/sagernet/sing/common/json"
+	"github.com/sagernet/sing/common/json/badjson"
+
+	"github.com/spf13/cobra"
+)
+
+var commandDiffFlagExitCode bool
+
+var commandDiff = &cobra.Command{
+	Use:   "diff <old-path> <new-path>",
+	Short: "Compare configurations",
+	Long: `Compare two configurations after normalization.
+
+Objects in lists with a "tag" field (such as inbounds, outbounds and DNS servers) are compared by tag instead of position.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		changed, err := diff(args[0], args[1])
+		if err != nil {
+			log.Fatal(err)
+		}
+		if changed && commandDiffFlagExitCode {
+			os.Exit(1)
+		}
+	},
+	Args: cobra.ExactArgs(2),
+}
+
+func init() {
+	commandDiff.Flags().BoolVar(&commandDiffFlagExitCode, "exit-code", false, "exit with 1 if there are differences")
+	mainCommand.AddCommand(commandDiff)
+}
+
+func diff(oldPath string, newPath string) (bool, error) {
+	oldContent, err := readNormalizedConfig(oldPath)
+	if err != nil {
+		return false, err
+	}
+	newContent, err := readNormalizedConfig(newPath)
+	if err != nil {
+		return false, err
+	}
+	var changes []string
+	diffValue("", oldContent, newContent, &changes)
+	for _, change := range changes {
+		os.Stdout.WriteString(change + "\n")
+	}
+	return len(changes) > 0, nil
+}
+
+func readNormalizedConfig(path string) (any, error) {
+	optionsEntry, err := readConfigAt(path)
+	if err != nil {
+		return nil, err
+	}
+	options, err := badjson.Omitempty(globalCtx, optionsEntry.options)
+	if err != nil {
+		return nil, err
+	}
+	content, err := json.Marshal(options)
+	if err != nil {
+		return nil, E.Cause(err, "encode config at ", path)
+	}
+	var value any
+	err = json.Unmarshal(content, &value)
+	if err != nil {
+		return nil, E.Cause(err, "decode config at ", path)
+	}
+	return value, nil
+}
+
+func diffValue(path string, oldValue any, newValue any, changes *[]string) {
+	switch oldTyped := oldValue.(type) {
+	case map[string]any:
+		if newTyped, isMap := newValue.(map[string]any); isMap {
+			diffObject(path, oldTyped, newTyped, changes)
+			return
+		}
+	case []any:
+		if newTyped, isList := newValue.([]any); isList {
+			diffList(path, oldTyped, newTyped, changes)
+			return
+		}
+	}
+	oldString := encodeDiffValue(oldValue)
+	newString := encodeDiffValue(newValue)
+	if oldString != newString {
+		*changes = append(*changes, F.ToString("~ ", path, ": ", oldString, " -> ", newString))
+	}
+}
+
+func diffObject(path string, oldObject map[string]any, newObject map[string]any, changes *[]string) {
+	keys := make([]string, 0, len(oldObject)+len(newObject))
+	for key := range oldObject {
+		keys = append(keys, key)
+	}
+	for key := range newObject {
+		if _, loaded := oldObject[key]; !loaded {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		keyPath := joinDiffPath(path, key)
+		oldValue, oldLoaded := oldObject[key]
+		newValue, newLoaded := newObject[key]
+		switch {
+		case !newLoaded:
+			*changes = append(*changes, F.ToString("- ", keyPath, ": ", encodeDiffValue(oldValue)))
+		case !oldLoaded:
+			*changes = append(*changes, F.ToString("+ ", keyPath, ": ", encodeDiffValue(newValue)))
+		default:
+			diffValue(keyPath, oldValue, newValue, changes)
+		}
+	}
+}
+
+func diffList(path string, oldList []any, newList []any, changes *[]string) {
+	oldTagged, oldIsTagged := taggedObjects(oldList)
+	newTagged, newIsTagged := taggedObjects(newList)
+	if !oldIsTagged || !newIsTagged {
+		for i := 0; i < len(oldList) || i < len(newList); i++ {
+			itemPath := F.ToString(path, "[", i, "]")
+			switch {
+			case i >= len(newList):
+				*changes = append(*changes, F.ToString("- ", itemPath, ": ", encodeDiffValue(oldList[i])))
+			case i >= len(oldList):
+				*changes = append(*changes, F.ToString("+ ", itemPath, ": ", encodeDiffValue(newList[i])))
+			default:
+				diffValue(itemPath, oldList[i], newList[i], changes)
+			}
+		}
+		return
+	}
+	for _, item := range oldList {
+		tag := item.(map[string]any)["tag"].(string)
+		itemPath := F.ToString(path, "[", tag, "]")
+		if newItem, loaded := newTagged[tag]; loaded {
+			diffValue(itemPath, item, newItem, changes)
+		} else {
+			*changes = append(*changes, F.ToString("- ", itemPath, ": ", encodeDiffValue(item)))
+		}
+	}
+	for _, item := range newList {
+		tag := item.(map[string]any)["tag"].(string)
+		if _, loaded := oldTagged[tag]; !loaded {
+			*changes = append(*changes, F.ToString("+ ", path, "[", tag, "]: ", encodeDiffValue(item)))
+		}
+	}
+}
+
+func taggedObjects(list []any) (map[string]any, bool) {
+	if len(list) == 0 {
+		return nil, true
+	}
+	tagged := make(map[string]any, len(list))
+	for _, item := range list {
+		object, isObject := item.(map[string]any)
+		if !isObject {
+			return nil, false
+		}
+		tag, isString := object["tag"].(string)
+		if !isString || tag == "" {
+			return nil, false
+		}
+		if _, duplicated := tagged[tag]; duplicated {
+			return nil, false
+		}
+		tagged[tag] = item
+	}
+	return tagged, true
+}
+
+func joinDiffPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func encodeDiffValue(value any) string {
+	content, err := json.Marshal(value)
+	if err != nil {
+		return F.ToString(value)
+	}
+	return strings.TrimSpace(string(content))
+}
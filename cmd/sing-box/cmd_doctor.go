@@ -0,0 +1,178 @@
+package main
+
+import (
+	"net"
+	"net/netip"
+	"os"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	F "github.com/sagernet/sing/common/format"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+
+	"github.com/spf13/cobra"
+)
+
+var commandDoctor = &cobra.Command{
+	Use:   "doctor",
+	Short: "Diagnose the environment for the configuration",
+	Run: func(cmd *cobra.Command, args []string) {
+		if !doctor() {
+			os.Exit(1)
+		}
+	},
+	Args: cobra.NoArgs,
+}
+
+func init() {
+	mainCommand.AddCommand(commandDoctor)
+}
+
+type doctorReport struct {
+	failed bool
+}
+
+func (r *doctorReport) OK(message ...any) {
+	os.Stdout.WriteString(F.ToString("[ OK ] ", F.ToString(message...), "\n"))
+}
+
+func (r *doctorReport) Warn(message ...any) {
+	os.Stdout.WriteString(F.ToString("[WARN] ", F.ToString(message...), "\n"))
+}
+
+func (r *doctorReport) Fail(message ...any) {
+	r.failed = true
+	os.Stdout.WriteString(F.ToString("[FAIL] ", F.ToString(message...), "\n"))
+}
+
+type doctorRequirements struct {
+	tun          bool
+	tunIPv6      bool
+	autoRedirect bool
+	transparent  bool
+	listens      []doctorListen
+	listenDNS    bool
+}
+
+type doctorListen struct {
+	network string
+	address M.Socksaddr
+}
+
+func doctor() bool {
+	var report doctorReport
+	options, err := readConfigAndMerge()
+	if err != nil {
+		report.Warn("configuration not loaded, only generic checks are performed: ", err)
+	}
+	requirements := doctorCollectRequirements(options)
+	doctorCheckListen(&report, requirements)
+	doctorCheckPlatform(&report, requirements)
+	if report.failed {
+		log.Error("some checks failed")
+	}
+	return !report.failed
+}
+
+func doctorCollectRequirements(options option.Options) doctorRequirements {
+	var requirements doctorRequirements
+	for _, inbound := range options.Inbounds {
+		switch inbound.Type {
+		case C.TypeTun:
+			if tunOptions, isTun := inbound.Options.(*option.TunInboundOptions); isTun {
+				requirements.tun = true
+				if tunOptions.AutoRedirect {
+					requirements.autoRedirect = true
+				}
+				for _, address := range tunOptions.Address {
+					if address.Addr().Is6() {
+						requirements.tunIPv6 = true
+					}
+				}
+			}
+		case C.TypeRedirect, C.TypeTProxy:
+			requirements.transparent = true
+		}
+		listenWrapper, isListen := inbound.Options.(option.ListenOptionsWrapper)
+		if !isListen {
+			continue
+		}
+		listenOptions := listenWrapper.TakeListenOptions()
+		if listenOptions.ListenPort == 0 {
+			continue
+		}
+		listenAddr := netip.IPv6Unspecified()
+		if listenOptions.Listen != nil {
+			listenAddr = listenOptions.Listen.Build(netip.IPv6Unspecified())
+		}
+		for _, network := range doctorInboundNetworks(inbound) {
+			requirements.listens = append(requirements.listens, doctorListen{network, M.SocksaddrFrom(listenAddr, listenOptions.ListenPort)})
+		}
+		if listenOptions.ListenPort == 53 {
+			requirements.listenDNS = true
+		}
+	}
+	return requirements
+}
+
+// doctorInboundNetworks returns the networks the inbound listens on.
+func doctorInboundNetworks(inbound option.Inbound) []string {
+	var transport *option.V2RayTransportOptions
+	switch options := inbound.Options.(type) {
+	case *option.DirectInboundOptions:
+		return options.Network.Build()
+	case *option.ShadowsocksInboundOptions:
+		return options.Network.Build()
+	case *option.TProxyInboundOptions:
+		return options.Network.Build()
+	case *option.NaiveInboundOptions:
+		return options.Network.Build()
+	case *option.HysteriaInboundOptions, *option.Hysteria2InboundOptions, *option.TUICInboundOptions:
+		return []string{N.NetworkUDP}
+	case *option.VMessInboundOptions:
+		transport = options.Transport
+	case *option.VLESSInboundOptions:
+		transport = options.Transport
+	case *option.TrojanInboundOptions:
+		transport = options.Transport
+	}
+	if transport != nil && transport.Type == C.V2RayTransportTypeQUIC {
+		return []string{N.NetworkUDP}
+	}
+	return []string{N.NetworkTCP}
+}
+
+func doctorCheckListen(report *doctorReport, requirements doctorRequirements) {
+	for _, listen := range common.Uniq(requirements.listens) {
+		network := listen.network
+		if listen.address.Addr.Is4() {
+			network += "4"
+		}
+		var err error
+		if listen.network == N.NetworkUDP {
+			var packetConn net.PacketConn
+			packetConn, err = net.ListenPacket(network, listen.address.String())
+			if err == nil {
+				packetConn.Close()
+			}
+		} else {
+			var listener net.Listener
+			listener, err = net.Listen(network, listen.address.String())
+			if err == nil {
+				listener.Close()
+			}
+		}
+		if err != nil {
+			if pid, loaded := doctorListenOwner(listen.network, listen.address.Port); loaded {
+				report.OK("listen on ", listen.network, " ", listen.address, ": in use by sing-box (pid ", pid, ")")
+				continue
+			}
+			report.Fail("listen on ", listen.network, " ", listen.address, ": ", err)
+			continue
+		}
+		report.OK("listen on ", listen.network, " ", listen.address)
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const doctorRecommendedOpenFiles = 65535
+
+func doctorCheckPlatform(report *doctorReport, requirements doctorRequirements) {
+	doctorCheckTun(report, requirements)
+	doctorCheckFirewall(report, requirements)
+	doctorCheckForwarding(report, requirements)
+	doctorCheckResolved(report, requirements)
+	doctorCheckOpenFiles(report)
+}
+
+func doctorCheckTun(report *doctorReport, requirements doctorRequirements) {
+	tunFile, err := os.OpenFile("/dev/net/tun", os.O_RDWR, 0)
+	if err != nil {
+		if requirements.tun {
+			report.Fail("open /dev/net/tun: ", err, ", load the tun kernel module or run with CAP_NET_ADMIN")
+		} else {
+			report.Warn("open /dev/net/tun: ", err, ", the tun inbound will not be available")
+		}
+		return
+	}
+	tunFile.Close()
+	report.OK("TUN device available")
+}
+
+func doctorCheckFirewall(report *doctorReport, requirements doctorRequirements) {
+	nftPath, nftErr := exec.LookPath("nft")
+	iptablesPath, iptablesErr := exec.LookPath("iptables")
+	switch {
+	case nftErr == nil:
+		report.OK("nftables available: ", nftPath)
+	case iptablesErr == nil:
+		if requirements.autoRedirect {
+			report.Warn("nft command not found, auto_redirect will use iptables if nftables is not supported by the kernel: ", iptablesPath)
+		} else {
+			report.OK("iptables available: ", iptablesPath)
+		}
+	default:
+		if requirements.transparent {
+			report.Fail("neither nftables nor iptables found, install nftables to use redirect or tproxy inbounds")
+		} else if requirements.autoRedirect {
+			report.Warn("neither nft nor iptables command found, auto_redirect requires nftables support of the kernel")
+		} else {
+			report.Warn("neither nftables nor iptables found")
+		}
+	}
+}
+
+func doctorCheckForwarding(report *doctorReport, requirements doctorRequirements) {
+	if !requirements.tun && !requirements.transparent {
+		return
+	}
+	if doctorReadSysctl("net/ipv4/ip_forward") != "1" {
+		report.Warn("IPv4 forwarding disabled, other devices will not be able to route through this host, set net.ipv4.ip_forward=1")
+	} else {
+		report.OK("IPv4 forwarding enabled")
+	}
+	if !requirements.tunIPv6 {
+		return
+	}
+	if doctorReadSysctl("net/ipv6/conf/all/forwarding") != "1" {
+		report.Warn("IPv6 forwarding disabled, other devices will not be able to route IPv6 through this host, set net.ipv6.conf.all.forwarding=1")
+	} else {
+		report.OK("IPv6 forwarding enabled")
+	}
+}
+
+func doctorCheckResolved(report *doctorReport, requirements doctorRequirements) {
+	resolvConf, err := filepath.EvalSymlinks("/etc/resolv.conf")
+	if err != nil || !strings.HasPrefix(resolvConf, "/run/systemd/resolve/") {
+		return
+	}
+	if requirements.listenDNS {
+		report.Warn("systemd-resolved manages /etc/resolv.conf and may occupy port 53, set DNSStubListener=no in /etc/systemd/resolved.conf")
+	} else {
+		report.OK("systemd-resolved in use, no conflicts with port 53")
+	}
+}
+
+func doctorCheckOpenFiles(report *doctorReport) {
+	var rLimit syscall.Rlimit
+	err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rLimit)
+	if err != nil {
+		report.Warn("get open file limit: ", err)
+		return
+	}
+	if rLimit.Cur < doctorRecommendedOpenFiles {
+		report.Warn("open file limit is ", rLimit.Cur, ", raise it to at least ", doctorRecommendedOpenFiles, " (LimitNOFILE= in the systemd unit or ulimit -n)")
+	} else {
+		report.OK("open file limit is ", rLimit.Cur)
+	}
+}
+
+func doctorReadSysctl(name string) string {
+	content, err := os.ReadFile(filepath.Join("/proc/sys", name))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(content))
+}
+
+// doctorListenOwner returns the process of sing-box holding the port, so that
+// checking a running configuration does not report its own listeners.
+func doctorListenOwner(network string, port uint16) (int, bool) {
+	inodes := make(map[string]bool)
+	for _, suffix := range []string{"", "6"} {
+		doctorReadSocketInodes(filepath.Join("/proc/net", network+suffix), network, port, inodes)
+	}
+	if len(inodes) == 0 {
+		return 0, false
+	}
+	processes, err := os.ReadDir("/proc")
+	if err != nil {
+		return 0, false
+	}
+	for _, process := range processes {
+		pid, err := strconv.Atoi(process.Name())
+		if err != nil || pid == os.Getpid() {
+			continue
+		}
+		comm, err := os.ReadFile(filepath.Join("/proc", process.Name(), "comm"))
+		if err != nil || strings.TrimSpace(string(comm)) != "sing-box" {
+			continue
+		}
+		fdDir := filepath.Join("/proc", process.Name(), "fd")
+		fds, err := os.ReadDir(fdDir)
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			link, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+			if err == nil && strings.HasPrefix(link, "socket:[") && inodes[strings.TrimSuffix(strings.TrimPrefix(link, "socket:["), "]")] {
+				return pid, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// doctorReadSocketInodes collects inodes of listening TCP or bound UDP sockets on the port.
+func doctorReadSocketInodes(path string, network string, port uint16, inodes map[string]bool) {
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// sl local_address rem_address st tx_queue:rx_queue tr:tm->when retrnsmt uid timeout inode
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 {
+			continue
+		}
+		_, localPort, found := strings.Cut(fields[1], ":")
+		if !found {
+			continue
+		}
+		socketPort, err := strconv.ParseUint(localPort, 16, 16)
+		if err != nil || uint16(socketPort) != port {
+			continue
+		}
+		// 0A is TCP_LISTEN, 07 is TCP_CLOSE used by unconnected UDP sockets
+		if network == "tcp" && fields[3] != "0A" || network == "udp" && fields[3] != "07" {
+			continue
+		}
+		inodes[fields[9]] = true
+	}
+}
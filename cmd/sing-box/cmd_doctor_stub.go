@@ -0,0 +1,11 @@
+//go:build !linux
+
+package main
+
+func doctorCheckPlatform(report *doctorReport, requirements doctorRequirements) {
+	report.Warn("kernel feature checks are only available on Linux")
+}
+
+func doctorListenOwner(network string, port uint16) (int, bool) {
+	return 0, false
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/sagernet/sing-box/cmd/sing-box/internal/configformat"
 	"github.com/sagernet/sing-box/log"
 	E "github.com/sagernet/sing/common/exceptions"
 	"github.com/sagernet/sing/common/json"
@@ -13,7 +14,10 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var commandFormatFlagWrite bool
+var (
+	commandFormatFlagWrite           bool
+	commandFormatFlagDiscardComments bool
+)
 
 var commandFormat = &cobra.Command{
 	Use:   "format",
@@ -29,6 +33,7 @@ var commandFormat = &cobra.Command{
 
 func init() {
 	commandFormat.Flags().BoolVarP(&commandFormatFlagWrite, "write", "w", false, "write result to (source) file instead of stdout")
+	commandFormat.Flags().BoolVar(&commandFormatFlagDiscardComments, "discard-comments", false, "allow writing YAML and TOML files, which drops their comments, anchors and key order")
 	mainCommand.AddCommand(commandFormat)
 }
 
@@ -37,6 +42,15 @@ func format() error {
 	if err != nil {
 		return err
 	}
+	if commandFormatFlagWrite && !commandFormatFlagDiscardComments {
+		// YAML and TOML files are regenerated from the options, refuse before
+		// anything is written
+		for _, optionsEntry := range optionsList {
+			if configformat.FromPath(optionsEntry.path) != configformat.FormatJSON {
+				return E.New("writing ", optionsEntry.path, " drops its comments, anchors and key order, use --discard-comments to write anyway")
+			}
+		}
+	}
 	for _, optionsEntry := range optionsList {
 		optionsEntry.options, err = badjson.Omitempty(globalCtx, optionsEntry.options)
 		if err != nil {
@@ -49,6 +63,11 @@ func format() error {
 		if err != nil {
 			return E.Cause(err, "encode config")
 		}
+		formatContent, err := configformat.FromJSON(configformat.FromPath(optionsEntry.path), buffer.Bytes())
+		if err != nil {
+			return E.Cause(err, "encode config at ", optionsEntry.path)
+		}
+		buffer = bytes.NewBuffer(formatContent)
 		outputPath, _ := filepath.Abs(optionsEntry.path)
 		if !commandFormatFlagWrite {
 			if len(optionsList) > 1 {
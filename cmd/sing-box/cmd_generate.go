@@ -8,6 +8,7 @@ import (
 	"strconv"
 
 	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing/common/json"
 
 	"github.com/gofrs/uuid/v5"
 	"github.com/spf13/cobra"
@@ -18,13 +19,24 @@ var commandGenerate = &cobra.Command{
 	Short: "Generate things",
 }
 
+var flagGenerateJSON bool
+
 func init() {
+	commandGenerate.PersistentFlags().BoolVar(&flagGenerateJSON, "json", false, "Output in JSON")
 	commandGenerate.AddCommand(commandGenerateUUID)
 	commandGenerate.AddCommand(commandGenerateRandom)
 
 	mainCommand.AddCommand(commandGenerate)
 }
 
+// writeGeneratedJSON writes generated values as a JSON object, keyed by the
+// name of the configuration field they are used in.
+func writeGeneratedJSON(object map[string]string) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(object)
+}
+
 var (
 	outputBase64 bool
 	outputHex    bool
@@ -59,6 +71,15 @@ func generateRandom(args []string) error {
 		return err
 	}
 
+	if flagGenerateJSON {
+		var value string
+		if outputHex {
+			value = hex.EncodeToString(randomBytes)
+		} else {
+			value = base64.StdEncoding.EncodeToString(randomBytes)
+		}
+		return writeGeneratedJSON(map[string]string{"random": value})
+	}
 	if outputBase64 {
 		_, err = os.Stdout.WriteString(base64.StdEncoding.EncodeToString(randomBytes) + "\n")
 	} else if outputHex {
@@ -87,6 +108,9 @@ func generateUUID() error {
 	if err != nil {
 		return err
 	}
+	if flagGenerateJSON {
+		return writeGeneratedJSON(map[string]string{"uuid": newUUID.String()})
+	}
 	_, err = os.Stdout.WriteString(newUUID.String() + "\n")
 	return err
 }
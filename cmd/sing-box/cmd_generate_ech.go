@@ -33,6 +33,9 @@ func generateECHKeyPair(serverName string) error {
 	if err != nil {
 		return err
 	}
+	if flagGenerateJSON {
+		return writeGeneratedJSON(map[string]string{"config": configPem, "key": keyPem})
+	}
 	os.Stdout.WriteString(configPem)
 	os.Stdout.WriteString(keyPem)
 	return nil
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"os"
+
+	"github.com/sagernet/sing-box/log"
+	E "github.com/sagernet/sing/common/exceptions"
+
+	"github.com/spf13/cobra"
+)
+
+var shadowsocks2022KeyLength = map[string]int{
+	"2022-blake3-aes-128-gcm":       16,
+	"2022-blake3-aes-256-gcm":       32,
+	"2022-blake3-chacha20-poly1305": 32,
+}
+
+var commandGenerateShadowsocksKey = &cobra.Command{
+	Use:   "shadowsocks-key <method>",
+	Short: "Generate Shadowsocks 2022 key",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := generateShadowsocksKey(args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	commandGenerate.AddCommand(commandGenerateShadowsocksKey)
+}
+
+func generateShadowsocksKey(method string) error {
+	keyLength, loaded := shadowsocks2022KeyLength[method]
+	if !loaded {
+		return E.New("unsupported method: ", method, ", only 2022 methods use generated keys")
+	}
+	key := make([]byte, keyLength)
+	_, err := rand.Read(key)
+	if err != nil {
+		return err
+	}
+	password := base64.StdEncoding.EncodeToString(key)
+	if flagGenerateJSON {
+		return writeGeneratedJSON(map[string]string{"password": password})
+	}
+	_, err = os.Stdout.WriteString(password + "\n")
+	return err
+}
@@ -34,6 +34,9 @@ func generateTLSKeyPair(serverName string) error {
 	if err != nil {
 		return err
 	}
+	if flagGenerateJSON {
+		return writeGeneratedJSON(map[string]string{"key": string(privateKeyPem), "certificate": string(publicKeyPem)})
+	}
 	os.Stdout.WriteString(string(privateKeyPem) + "\n")
 	os.Stdout.WriteString(string(publicKeyPem) + "\n")
 	return nil
@@ -34,6 +34,12 @@ func generateVAPIDKeyPair() error {
 		return err
 	}
 	publicKey := privateKey.PublicKey()
+	if flagGenerateJSON {
+		return writeGeneratedJSON(map[string]string{
+			"private_key": base64.RawURLEncoding.EncodeToString(privateKey.Bytes()),
+			"public_key":  base64.RawURLEncoding.EncodeToString(publicKey.Bytes()),
+		})
+	}
 	os.Stdout.WriteString("PrivateKey: " + base64.RawURLEncoding.EncodeToString(privateKey.Bytes()) + "\n")
 	os.Stdout.WriteString("PublicKey: " + base64.RawURLEncoding.EncodeToString(publicKey.Bytes()) + "\n")
 	return nil
@@ -32,6 +32,12 @@ func generateWireGuardKey() error {
 	if err != nil {
 		return err
 	}
+	if flagGenerateJSON {
+		return writeGeneratedJSON(map[string]string{
+			"private_key": privateKey.String(),
+			"public_key":  privateKey.PublicKey().String(),
+		})
+	}
 	os.Stdout.WriteString("PrivateKey: " + privateKey.String() + "\n")
 	os.Stdout.WriteString("PublicKey: " + privateKey.PublicKey().String() + "\n")
 	return nil
@@ -55,6 +61,12 @@ func generateRealityKey() error {
 		return err
 	}
 	publicKey := privateKey.PublicKey()
+	if flagGenerateJSON {
+		return writeGeneratedJSON(map[string]string{
+			"private_key": base64.RawURLEncoding.EncodeToString(privateKey[:]),
+			"public_key":  base64.RawURLEncoding.EncodeToString(publicKey[:]),
+		})
+	}
 	os.Stdout.WriteString("PrivateKey: " + base64.RawURLEncoding.EncodeToString(privateKey[:]) + "\n")
 	os.Stdout.WriteString("PublicKey: " + base64.RawURLEncoding.EncodeToString(publicKey[:]) + "\n")
 	return nil
@@ -5,7 +5,9 @@ import (
 	"os"
 	"strings"
 
-	"github.com/sagernet/sing-box/cmd/sing-box/internal/convertor/adguard"
+	"github.com/sagernet/sing-box/common/convertor/adblock"
+	"github.com/sagernet/sing-box/common/convertor/adguard"
+	"github.com/sagernet/sing-box/common/convertor/clash"
 	"github.com/sagernet/sing-box/common/srs"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/log"
@@ -22,7 +24,7 @@ var (
 
 var commandRuleSetConvert = &cobra.Command{
 	Use:   "convert [source-path]",
-	Short: "Convert adguard DNS filter to rule-set",
+	Short: "Convert adguard DNS filter, adblock filter list or clash rule provider to rule-set",
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		err := convertRuleSet(args[0])
@@ -34,7 +36,7 @@ var commandRuleSetConvert = &cobra.Command{
 
 func init() {
 	commandRuleSet.AddCommand(commandRuleSetConvert)
-	commandRuleSetConvert.Flags().StringVarP(&flagRuleSetConvertType, "type", "t", "", "Source type, available: adguard")
+	commandRuleSetConvert.Flags().StringVarP(&flagRuleSetConvertType, "type", "t", "", "Source type, available: adguard, adblock, clash-domain, clash-ipcidr, clash-classical")
 	commandRuleSetConvert.Flags().StringVarP(&flagRuleSetConvertOutput, "output", "o", flagRuleSetCompileDefaultOutput, "Output file")
 }
 
@@ -54,7 +56,15 @@ func convertRuleSet(sourcePath string) error {
 	var rules []option.HeadlessRule
 	switch flagRuleSetConvertType {
 	case "adguard":
-		rules, err = adguard.Convert(reader)
+		rules, err = adguard.Convert(reader, log.StdLogger())
+	case "adblock":
+		rules, err = adblock.Convert(reader, log.StdLogger())
+	case "clash-domain":
+		rules, err = clash.Convert(reader, clash.BehaviorDomain, log.StdLogger())
+	case "clash-ipcidr":
+		rules, err = clash.Convert(reader, clash.BehaviorIPCIDR, log.StdLogger())
+	case "clash-classical":
+		rules, err = clash.Convert(reader, clash.BehaviorClassical, log.StdLogger())
 	case "":
 		return E.New("source type is required")
 	default:
@@ -65,8 +75,10 @@ func convertRuleSet(sourcePath string) error {
 	}
 	var outputPath string
 	if flagRuleSetConvertOutput == flagRuleSetCompileDefaultOutput {
-		if strings.HasSuffix(sourcePath, ".txt") {
+		if strings.HasSuffix(sourcePath, ".txt") || strings.HasSuffix(sourcePath, ".yml") {
 			outputPath = sourcePath[:len(sourcePath)-4] + ".srs"
+		} else if strings.HasSuffix(sourcePath, ".yaml") {
+			outputPath = sourcePath[:len(sourcePath)-5] + ".srs"
 		} else {
 			outputPath = sourcePath + ".srs"
 		}
@@ -8,14 +8,15 @@ import (
 	"path/filepath"
 	runtimeDebug "runtime/debug"
 	"sort"
-	"strings"
 	"syscall"
 	"time"
 
 	"github.com/sagernet/sing-box"
+	"github.com/sagernet/sing-box/cmd/sing-box/internal/configformat"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/log"
 	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
 	E "github.com/sagernet/sing/common/exceptions"
 	"github.com/sagernet/sing/common/json"
 	"github.com/sagernet/sing/common/json/badjson"
@@ -27,7 +28,10 @@ var commandRun = &cobra.Command{
 	Use:   "run",
 	Short: "Run service",
 	Run: func(cmd *cobra.Command, args []string) {
-		err := run()
+		isService, err := runAsService()
+		if !isService {
+			err = run()
+		}
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -57,7 +61,11 @@ func readConfigAt(path string) (*OptionsEntry, error) {
 	if err != nil {
 		return nil, E.Cause(err, "read config at ", path)
 	}
-	options, err := json.UnmarshalExtendedContext[option.Options](globalCtx, configContent)
+	jsonContent, err := configformat.ToJSON(configformat.FromPath(path), configContent)
+	if err != nil {
+		return nil, E.Cause(err, "decode config at ", path)
+	}
+	options, err := json.UnmarshalExtendedContext[option.Options](globalCtx, jsonContent)
 	if err != nil {
 		return nil, E.Cause(err, "decode config at ", path)
 	}
@@ -83,7 +91,7 @@ func readConfig() ([]*OptionsEntry, error) {
 			return nil, E.Cause(err, "read config directory at ", directory)
 		}
 		for _, entry := range entries {
-			if !strings.HasSuffix(entry.Name(), ".json") || entry.IsDir() {
+			if !configformat.IsConfigFile(entry.Name()) || entry.IsDir() {
 				continue
 			}
 			optionsEntry, err := readConfigAt(filepath.Join(directory, entry.Name()))
@@ -154,7 +162,7 @@ func create() (*box.Box, context.CancelFunc, error) {
 		_, loaded := <-osSignals
 		if loaded {
 			cancel()
-			closeMonitor(startCtx)
+			closeMonitor(startCtx, 0)
 		}
 	}()
 	err = instance.Start()
@@ -168,16 +176,33 @@ func create() (*box.Box, context.CancelFunc, error) {
 
 func run() error {
 	osSignals := make(chan os.Signal, 1)
-	signal.Notify(osSignals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	signal.Notify(osSignals, append([]os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGHUP}, reloadCertificatesSignals...)...)
 	defer signal.Stop(osSignals)
+	return runWithSignals(osSignals, nil)
+}
+
+// runWithSignals runs the service until a stop signal is received, onStarted is called each time the service is (re)started.
+func runWithSignals(osSignals <-chan os.Signal, onStarted func()) error {
 	for {
 		instance, cancel, err := create()
 		if err != nil {
 			return err
 		}
+		if onStarted != nil {
+			onStarted()
+		}
 		runtimeDebug.FreeOSMemory()
 		for {
 			osSignal := <-osSignals
+			if common.Contains(reloadCertificatesSignals, osSignal) {
+				err = instance.ReloadCertificates()
+				if err != nil {
+					log.Error(E.Cause(err, "reload certificates"))
+				} else {
+					log.Info("reloaded certificates")
+				}
+				continue
+			}
 			if osSignal == syscall.SIGHUP {
 				err = check()
 				if err != nil {
@@ -185,11 +210,22 @@ func run() error {
 					continue
 				}
 			}
-			cancel()
+			var drainTimeout time.Duration
+			if osSignal != syscall.SIGHUP {
+				drainTimeout = instance.DrainTimeout()
+			}
+			if drainTimeout == 0 {
+				cancel()
+			}
 			closeCtx, closed := context.WithCancel(context.Background())
-			go closeMonitor(closeCtx)
-			err = instance.Close()
+			go closeMonitor(closeCtx, drainTimeout)
+			if osSignal == syscall.SIGHUP {
+				err = instance.CloseImmediately()
+			} else {
+				err = instance.Close()
+			}
 			closed()
+			cancel()
 			if osSignal != syscall.SIGHUP {
 				if err != nil {
 					log.Error(E.Cause(err, "sing-box did not closed properly"))
@@ -201,8 +237,8 @@ func run() error {
 	}
 }
 
-func closeMonitor(ctx context.Context) {
-	time.Sleep(C.FatalStopTimeout)
+func closeMonitor(ctx context.Context, drainTimeout time.Duration) {
+	time.Sleep(C.FatalStopTimeout + drainTimeout)
 	select {
 	case <-ctx.Done():
 		return
@@ -0,0 +1,11 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// reloadCertificatesSignals reload certificate and key files of TLS inbounds without restarting the service.
+var reloadCertificatesSignals = []os.Signal{syscall.SIGUSR1}
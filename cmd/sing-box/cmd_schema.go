@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os"
+
+	"github.com/sagernet/sing-box/common/jsonschema"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/json"
+
+	"github.com/spf13/cobra"
+)
+
+var commandSchema = &cobra.Command{
+	Use:   "schema",
+	Short: "Print JSON schema of configuration",
+	Run: func(cmd *cobra.Command, args []string) {
+		err := printSchema()
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+	Args: cobra.NoArgs,
+}
+
+func init() {
+	mainCommand.AddCommand(commandSchema)
+}
+
+func printSchema() error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	err := encoder.Encode(jsonschema.Generate(globalCtx, option.Options{}))
+	if err != nil {
+		return E.Cause(err, "encode schema")
+	}
+	return nil
+}
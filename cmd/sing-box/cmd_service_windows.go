@@ -0,0 +1,279 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	E "github.com/sagernet/sing/common/exceptions"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+var commandServiceFlagName string
+
+var commandService = &cobra.Command{
+	Use:   "service",
+	Short: "Manage Windows service",
+}
+
+var commandServiceInstall = &cobra.Command{
+	Use:   "install",
+	Short: "Install Windows service with current configuration flags",
+	Run: func(cmd *cobra.Command, args []string) {
+		err := installService()
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+	Args: cobra.NoArgs,
+}
+
+var commandServiceUninstall = &cobra.Command{
+	Use:   "uninstall",
+	Short: "Stop and uninstall Windows service",
+	Run: func(cmd *cobra.Command, args []string) {
+		err := uninstallService()
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+	Args: cobra.NoArgs,
+}
+
+var commandServiceStart = &cobra.Command{
+	Use:   "start",
+	Short: "Start Windows service",
+	Run: func(cmd *cobra.Command, args []string) {
+		err := startService()
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+	Args: cobra.NoArgs,
+}
+
+var commandServiceStop = &cobra.Command{
+	Use:   "stop",
+	Short: "Stop Windows service",
+	Run: func(cmd *cobra.Command, args []string) {
+		err := stopService()
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+	Args: cobra.NoArgs,
+}
+
+func init() {
+	commandService.PersistentFlags().StringVarP(&commandServiceFlagName, "name", "n", "sing-box", "service name")
+	commandService.AddCommand(commandServiceInstall)
+	commandService.AddCommand(commandServiceUninstall)
+	commandService.AddCommand(commandServiceStart)
+	commandService.AddCommand(commandServiceStop)
+	mainCommand.AddCommand(commandService)
+}
+
+func installService() error {
+	executablePath, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	workingDirectory, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	serviceArgs := []string{"run", "-D", workingDirectory}
+	for _, path := range configPaths {
+		path, err = filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		serviceArgs = append(serviceArgs, "-c", path)
+	}
+	for _, directory := range configDirectories {
+		directory, err = filepath.Abs(directory)
+		if err != nil {
+			return err
+		}
+		serviceArgs = append(serviceArgs, "-C", directory)
+	}
+	if disableColor {
+		serviceArgs = append(serviceArgs, "--disable-color")
+	}
+	manager, err := mgr.Connect()
+	if err != nil {
+		return E.Cause(err, "connect to service manager")
+	}
+	defer manager.Disconnect()
+	service, err := manager.OpenService(commandServiceFlagName)
+	if err == nil {
+		service.Close()
+		return E.New("service ", commandServiceFlagName, " already exists")
+	}
+	service, err = manager.CreateService(commandServiceFlagName, executablePath, mgr.Config{
+		DisplayName: commandServiceFlagName,
+		Description: "sing-box universal proxy platform",
+		StartType:   mgr.StartAutomatic,
+	}, serviceArgs...)
+	if err != nil {
+		return E.Cause(err, "create service")
+	}
+	defer service.Close()
+	err = service.SetRecoveryActions([]mgr.RecoveryAction{
+		{Type: mgr.ServiceRestart, Delay: 5 * time.Second},
+	}, uint32((24 * time.Hour).Seconds()))
+	if err != nil {
+		log.Warn(E.Cause(err, "set recovery actions"))
+	}
+	log.Info("service ", commandServiceFlagName, " installed")
+	return nil
+}
+
+func uninstallService() error {
+	manager, err := mgr.Connect()
+	if err != nil {
+		return E.Cause(err, "connect to service manager")
+	}
+	defer manager.Disconnect()
+	service, err := manager.OpenService(commandServiceFlagName)
+	if err != nil {
+		return E.Cause(err, "open service ", commandServiceFlagName)
+	}
+	defer service.Close()
+	err = controlService(service, svc.Stop, svc.Stopped)
+	if err != nil && !E.IsMulti(err, windows.ERROR_SERVICE_NOT_ACTIVE) {
+		return err
+	}
+	err = service.Delete()
+	if err != nil {
+		return E.Cause(err, "delete service")
+	}
+	log.Info("service ", commandServiceFlagName, " uninstalled")
+	return nil
+}
+
+func startService() error {
+	manager, err := mgr.Connect()
+	if err != nil {
+		return E.Cause(err, "connect to service manager")
+	}
+	defer manager.Disconnect()
+	service, err := manager.OpenService(commandServiceFlagName)
+	if err != nil {
+		return E.Cause(err, "open service ", commandServiceFlagName)
+	}
+	defer service.Close()
+	err = service.Start()
+	if err != nil {
+		return E.Cause(err, "start service")
+	}
+	err = waitServiceState(service, svc.Running)
+	if err != nil {
+		return err
+	}
+	log.Info("service ", commandServiceFlagName, " started")
+	return nil
+}
+
+func stopService() error {
+	manager, err := mgr.Connect()
+	if err != nil {
+		return E.Cause(err, "connect to service manager")
+	}
+	defer manager.Disconnect()
+	service, err := manager.OpenService(commandServiceFlagName)
+	if err != nil {
+		return E.Cause(err, "open service ", commandServiceFlagName)
+	}
+	defer service.Close()
+	err = controlService(service, svc.Stop, svc.Stopped)
+	if err != nil {
+		return err
+	}
+	log.Info("service ", commandServiceFlagName, " stopped")
+	return nil
+}
+
+func controlService(service *mgr.Service, command svc.Cmd, state svc.State) error {
+	_, err := service.Control(command)
+	if err != nil {
+		return E.Cause(err, "control service")
+	}
+	return waitServiceState(service, state)
+}
+
+func waitServiceState(service *mgr.Service, state svc.State) error {
+	deadline := time.Now().Add(C.StartTimeout + C.FatalStopTimeout)
+	for {
+		status, err := service.Query()
+		if err != nil {
+			return E.Cause(err, "query service status")
+		}
+		if status.State == state {
+			return nil
+		}
+		if status.State == svc.Stopped && state != svc.Stopped {
+			return E.New("service stopped with exit code ", status.ServiceSpecificExitCode)
+		}
+		if time.Now().After(deadline) {
+			return E.New("timeout waiting for service state ", state, ", current ", status.State)
+		}
+		time.Sleep(300 * time.Millisecond)
+	}
+}
+
+func runAsService() (bool, error) {
+	isService, err := svc.IsWindowsService()
+	if err != nil || !isService {
+		return false, err
+	}
+	return true, svc.Run(commandServiceFlagName, &windowsService{})
+}
+
+type windowsService struct{}
+
+func (s *windowsService) Execute(args []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	const accepts = svc.AcceptStop | svc.AcceptShutdown | svc.AcceptParamChange
+	status <- svc.Status{State: svc.StartPending, WaitHint: uint32(C.StartTimeout.Milliseconds())}
+	osSignals := make(chan os.Signal, 4)
+	done := make(chan error, 1)
+	go func() {
+		done <- runWithSignals(osSignals, func() {
+			status <- svc.Status{State: svc.Running, Accepts: accepts}
+		})
+	}()
+	for {
+		select {
+		case request := <-requests:
+			switch request.Cmd {
+			case svc.Interrogate:
+				status <- request.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				status <- svc.Status{State: svc.StopPending, WaitHint: uint32(C.FatalStopTimeout.Milliseconds())}
+				sendSignal(osSignals, syscall.SIGTERM)
+			case svc.ParamChange:
+				sendSignal(osSignals, syscall.SIGHUP)
+			}
+		case err := <-done:
+			if err != nil {
+				log.Error(err)
+				return true, 1
+			}
+			return false, 0
+		}
+	}
+}
+
+func sendSignal(osSignals chan<- os.Signal, osSignal os.Signal) {
+	select {
+	case osSignals <- osSignal:
+	default:
+	}
+}
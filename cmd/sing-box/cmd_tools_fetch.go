@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"io"
 	"net"
@@ -9,6 +10,7 @@ import (
 	"net/url"
 	"os"
 
+	"github.com/sagernet/sing-box/adapter"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/log"
 	"github.com/sagernet/sing/common/bufio"
@@ -55,6 +57,9 @@ func fetch(args []string) error {
 				return dialer.DialContext(ctx, network, M.ParseSocksaddr(addr))
 			},
 			ForceAttemptHTTP2: true,
+			TLSClientConfig: &tls.Config{
+				RootCAs: adapter.RootPoolFromContext(globalCtx),
+			},
 		},
 	}
 	defer httpClient.CloseIdleConnections()
@@ -0,0 +1,405 @@
+package configformat
+
+import (
+	"bytes"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/json"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+	FormatTOML = "toml"
+)
+
+// FromPath returns the format of the configuration file by its extension, JSON is used for unknown extensions.
+func FromPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return FormatYAML
+	case ".toml":
+		return FormatTOML
+	default:
+		return FormatJSON
+	}
+}
+
+// IsConfigFile reports whether the file in a configuration directory should be loaded.
+func IsConfigFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json", ".yaml", ".yml", ".toml":
+		return true
+	default:
+		return false
+	}
+}
+
+// ToJSON converts the configuration content in the format to JSON,
+// so that it can be decoded as options with the same strictness.
+func ToJSON(format string, content []byte) ([]byte, error) {
+	switch format {
+	case FormatJSON:
+		return content, nil
+	case FormatYAML:
+		return yamlToJSON(content)
+	case FormatTOML:
+		return tomlToJSON(content)
+	default:
+		return nil, E.New("unknown config format: ", format)
+	}
+}
+
+// FromJSON converts the JSON content to the format.
+func FromJSON(format string, content []byte) ([]byte, error) {
+	switch format {
+	case FormatJSON:
+		return content, nil
+	case FormatYAML:
+		return jsonToYAML(content)
+	case FormatTOML:
+		return jsonToTOML(content)
+	default:
+		return nil, E.New("unknown config format: ", format)
+	}
+}
+
+func yamlToJSON(content []byte) ([]byte, error) {
+	var document yaml.Node
+	err := yaml.Unmarshal(content, &document)
+	if err != nil {
+		return nil, err
+	}
+	if len(document.Content) == 0 {
+		return []byte("{}"), nil
+	}
+	buffer := new(bytes.Buffer)
+	err = writeYAMLNode(buffer, document.Content[0])
+	if err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func writeYAMLNode(buffer *bytes.Buffer, node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.AliasNode:
+		return writeYAMLNode(buffer, node.Alias)
+	case yaml.MappingNode:
+		keys, values, err := yamlMappingPairs(node)
+		if err != nil {
+			return err
+		}
+		buffer.WriteByte('{')
+		for i, key := range keys {
+			if i > 0 {
+				buffer.WriteByte(',')
+			}
+			keyContent, _ := json.Marshal(key)
+			buffer.Write(keyContent)
+			buffer.WriteByte(':')
+			err = writeYAMLNode(buffer, values[i])
+			if err != nil {
+				return err
+			}
+		}
+		buffer.WriteByte('}')
+		return nil
+	case yaml.SequenceNode:
+		buffer.WriteByte('[')
+		for i, item := range node.Content {
+			if i > 0 {
+				buffer.WriteByte(',')
+			}
+			err := writeYAMLNode(buffer, item)
+			if err != nil {
+				return err
+			}
+		}
+		buffer.WriteByte(']')
+		return nil
+	case yaml.ScalarNode:
+		var value any
+		err := node.Decode(&value)
+		if err != nil {
+			return err
+		}
+		valueContent, err := json.Marshal(value)
+		if err != nil {
+			return E.Cause(err, "line ", node.Line)
+		}
+		buffer.Write(valueContent)
+		return nil
+	default:
+		return E.New("line ", node.Line, ": unexpected YAML node")
+	}
+}
+
+// yamlMappingPairs returns keys and values of the mapping in order, with merge keys (<<) resolved.
+func yamlMappingPairs(node *yaml.Node) ([]string, []*yaml.Node, error) {
+	defined := make(map[string]bool)
+	for i := 0; i < len(node.Content); i += 2 {
+		keyNode := node.Content[i]
+		if keyNode.Kind != yaml.ScalarNode {
+			return nil, nil, E.New("line ", keyNode.Line, ": mapping key must be a scalar")
+		}
+		if keyNode.Tag == "!!merge" {
+			continue
+		}
+		if defined[keyNode.Value] {
+			return nil, nil, E.New("line ", keyNode.Line, ": mapping key ", strconv.Quote(keyNode.Value), " already defined")
+		}
+		defined[keyNode.Value] = true
+	}
+	var (
+		keys   []string
+		values []*yaml.Node
+		added  = make(map[string]bool)
+	)
+	for i := 0; i < len(node.Content); i += 2 {
+		keyNode, valueNode := node.Content[i], node.Content[i+1]
+		if keyNode.Tag != "!!merge" {
+			keys = append(keys, keyNode.Value)
+			values = append(values, valueNode)
+			added[keyNode.Value] = true
+			continue
+		}
+		mergeNodes := []*yaml.Node{valueNode}
+		if valueNode.Kind == yaml.SequenceNode {
+			mergeNodes = valueNode.Content
+		}
+		for _, mergeNode := range mergeNodes {
+			if mergeNode.Kind == yaml.AliasNode {
+				mergeNode = mergeNode.Alias
+			}
+			if mergeNode.Kind != yaml.MappingNode {
+				return nil, nil, E.New("line ", mergeNode.Line, ": merge value must be a mapping")
+			}
+			mergeKeys, mergeValues, err := yamlMappingPairs(mergeNode)
+			if err != nil {
+				return nil, nil, err
+			}
+			for j, mergeKey := range mergeKeys {
+				if defined[mergeKey] || added[mergeKey] {
+					continue
+				}
+				keys = append(keys, mergeKey)
+				values = append(values, mergeValues[j])
+				added[mergeKey] = true
+			}
+		}
+	}
+	return keys, values, nil
+}
+
+func tomlToJSON(content []byte) ([]byte, error) {
+	var object map[string]any
+	err := toml.Unmarshal(content, &object)
+	if err != nil {
+		return nil, err
+	}
+	if object == nil {
+		object = make(map[string]any)
+	}
+	return json.Marshal(object)
+}
+
+func jsonToYAML(content []byte) ([]byte, error) {
+	node, err := jsonToYAMLNode(content)
+	if err != nil {
+		return nil, err
+	}
+	buffer := new(bytes.Buffer)
+	encoder := yaml.NewEncoder(buffer)
+	encoder.SetIndent(2)
+	err = encoder.Encode(node)
+	if err != nil {
+		return nil, err
+	}
+	err = encoder.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// jsonToYAMLNode converts the JSON value to a YAML node, keeping the order of object keys.
+func jsonToYAMLNode(content []byte) (*yaml.Node, error) {
+	content = bytes.TrimSpace(content)
+	if len(content) == 0 {
+		return nil, E.New("empty JSON value")
+	}
+	switch content[0] {
+	case '{':
+		node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		err := walkJSONObject(content, func(key string, value json.RawMessage) error {
+			valueNode, err := jsonToYAMLNode(value)
+			if err != nil {
+				return err
+			}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, valueNode)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(node.Content) == 0 {
+			node.Style = yaml.FlowStyle
+		}
+		return node, nil
+	case '[':
+		var items []json.RawMessage
+		err := json.Unmarshal(content, &items)
+		if err != nil {
+			return nil, err
+		}
+		node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, item := range items {
+			itemNode, err := jsonToYAMLNode(item)
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, itemNode)
+		}
+		if len(node.Content) == 0 {
+			node.Style = yaml.FlowStyle
+		}
+		return node, nil
+	case '"':
+		var value string
+		err := json.Unmarshal(content, &value)
+		if err != nil {
+			return nil, err
+		}
+		node := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+		switch strings.ToLower(value) {
+		case "y", "yes", "n", "no", "on", "off":
+			// booleans in YAML 1.1, which is still used by many tools
+			node.Style = yaml.DoubleQuotedStyle
+		}
+		return node, nil
+	case 't', 'f':
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: string(content)}, nil
+	case 'n':
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}, nil
+	default:
+		if bytes.ContainsAny(content, ".eE") {
+			return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: string(content)}, nil
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: string(content)}, nil
+	}
+}
+
+func walkJSONObject(content []byte, walk func(key string, value json.RawMessage) error) error {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	_, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	for decoder.More() {
+		keyToken, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		key, isString := keyToken.(string)
+		if !isString {
+			return E.New("unexpected JSON object key")
+		}
+		var value json.RawMessage
+		err = decoder.Decode(&value)
+		if err != nil {
+			return err
+		}
+		err = walk(key, value)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func jsonToTOML(content []byte) ([]byte, error) {
+	value, err := jsonToTOMLValue(content)
+	if err != nil {
+		return nil, err
+	}
+	object, isObject := value.(map[string]any)
+	if !isObject {
+		return nil, E.New("TOML document must be an object")
+	}
+	buffer := new(bytes.Buffer)
+	encoder := toml.NewEncoder(buffer)
+	encoder.Indent = ""
+	err = encoder.Encode(object)
+	if err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// jsonToTOMLValue converts the JSON value to a value for the TOML encoder,
+// integers are kept as integers since TOML distinguishes them from floats.
+func jsonToTOMLValue(content []byte) (any, error) {
+	content = bytes.TrimSpace(content)
+	if len(content) == 0 {
+		return nil, E.New("empty JSON value")
+	}
+	switch content[0] {
+	case '{':
+		object := make(map[string]any)
+		err := walkJSONObject(content, func(key string, value json.RawMessage) error {
+			tomlValue, err := jsonToTOMLValue(value)
+			if err != nil {
+				return err
+			}
+			if tomlValue != nil {
+				object[key] = tomlValue
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return object, nil
+	case '[':
+		var items []json.RawMessage
+		err := json.Unmarshal(content, &items)
+		if err != nil {
+			return nil, err
+		}
+		array := make([]any, 0, len(items))
+		for _, item := range items {
+			tomlValue, err := jsonToTOMLValue(item)
+			if err != nil {
+				return nil, err
+			}
+			if tomlValue == nil {
+				return nil, E.New("null is not supported in TOML arrays")
+			}
+			array = append(array, tomlValue)
+		}
+		return array, nil
+	case '"':
+		var value string
+		err := json.Unmarshal(content, &value)
+		if err != nil {
+			return nil, err
+		}
+		return value, nil
+	case 't', 'f':
+		return content[0] == 't', nil
+	case 'n':
+		return nil, nil
+	default:
+		if intValue, err := strconv.ParseInt(string(content), 10, 64); err == nil {
+			return intValue, nil
+		}
+		return strconv.ParseFloat(string(content), 64)
+	}
+}
@@ -0,0 +1,98 @@
+package configformat
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromPath(t *testing.T) {
+	t.Parallel()
+	require.Equal(t, FormatJSON, FromPath("config.json"))
+	require.Equal(t, FormatYAML, FromPath("config.yaml"))
+	require.Equal(t, FormatYAML, FromPath("config.YML"))
+	require.Equal(t, FormatTOML, FromPath("/etc/sing-box/config.toml"))
+	require.Equal(t, FormatJSON, FromPath("stdin"))
+	require.True(t, IsConfigFile("00-dns.yml"))
+	require.False(t, IsConfigFile("README.md"))
+}
+
+func TestYAMLToJSON(t *testing.T) {
+	t.Parallel()
+	content, err := ToJSON(FormatYAML, []byte(`
+log:
+  level: info
+inbounds:
+  - &mixed
+    type: mixed
+    tag: mixed-in
+    listen_port: 1080
+  - <<: *mixed
+    tag: mixed-in-2
+    listen_port: 0x1F91
+route:
+  final: "true"
+  auto_detect_interface: true
+`))
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+  "log": {"level": "info"},
+  "inbounds": [
+    {"type": "mixed", "tag": "mixed-in", "listen_port": 1080},
+    {"type": "mixed", "tag": "mixed-in-2", "listen_port": 8081}
+  ],
+  "route": {"final": "true", "auto_detect_interface": true}
+}`, string(content))
+	content, err = ToJSON(FormatYAML, nil)
+	require.NoError(t, err)
+	require.Equal(t, "{}", string(content))
+}
+
+func TestYAMLDuplicateKey(t *testing.T) {
+	t.Parallel()
+	_, err := ToJSON(FormatYAML, []byte(`
+log:
+  level: info
+  level: debug
+`))
+	require.Error(t, err)
+}
+
+func TestTOMLToJSON(t *testing.T) {
+	t.Parallel()
+	content, err := ToJSON(FormatTOML, []byte(`
+[log]
+level = "info"
+
+[[inbounds]]
+type = "mixed"
+listen_port = 1080
+`))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"log": {"level": "info"}, "inbounds": [{"type": "mixed", "listen_port": 1080}]}`, string(content))
+}
+
+func TestFromJSON(t *testing.T) {
+	t.Parallel()
+	jsonContent := `{"log":{"level":"info"},"inbounds":[{"type":"mixed","tag":"yes","listen_port":1080,"sniff_timeout":"1.5s"}],"route":{"rules":[]}}`
+	yamlContent, err := FromJSON(FormatYAML, []byte(jsonContent))
+	require.NoError(t, err)
+	require.Equal(t, `log:
+  level: info
+inbounds:
+  - type: mixed
+    tag: "yes"
+    listen_port: 1080
+    sniff_timeout: 1.5s
+route:
+  rules: []
+`, string(yamlContent))
+	content, err := ToJSON(FormatYAML, yamlContent)
+	require.NoError(t, err)
+	require.JSONEq(t, jsonContent, string(content))
+	tomlContent, err := FromJSON(FormatTOML, []byte(jsonContent))
+	require.NoError(t, err)
+	content, err = ToJSON(FormatTOML, tomlContent)
+	require.NoError(t, err)
+	require.JSONEq(t, jsonContent, string(content))
+}
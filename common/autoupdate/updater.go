@@ -0,0 +1,275 @@
+package autoupdate
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/logger"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+	"github.com/sagernet/sing/service"
+	"github.com/sagernet/sing/service/pause"
+)
+
+const (
+	stagingSuffix = ".download"
+	backupSuffix  = ".backup"
+)
+
+type Options struct {
+	Path           string
+	DownloadURL    string
+	DownloadDetour string
+	ChecksumURL    string
+	UpdateInterval time.Duration
+}
+
+// LoadFunc parses the file at path and replaces the data in use, it must keep
+// the previous data in use when it fails.
+type LoadFunc func(path string) error
+
+// Updater periodically replaces a local database file with a newer version
+// published with a SHA-256 checksum. The new file is downloaded next to the
+// current one, verified and loaded, and the previous file is restored if it
+// can not be loaded.
+type Updater struct {
+	ctx             context.Context
+	cancel          context.CancelFunc
+	logger          logger.ContextLogger
+	name            string
+	options         Options
+	load            LoadFunc
+	outboundManager adapter.OutboundManager
+	pauseManager    pause.Manager
+	dialer          N.Dialer
+	access          sync.Mutex
+}
+
+func New(ctx context.Context, logger logger.ContextLogger, name string, options Options, load LoadFunc) (*Updater, error) {
+	if options.DownloadURL == "" {
+		return nil, E.New("missing download_url for ", name, " update")
+	}
+	if options.ChecksumURL == "" {
+		return nil, E.New("missing checksum_url for ", name, " update")
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	return &Updater{
+		ctx:             ctx,
+		cancel:          cancel,
+		logger:          logger,
+		name:            name,
+		options:         options,
+		load:            load,
+		outboundManager: service.FromContext[adapter.OutboundManager](ctx),
+		pauseManager:    service.FromContext[pause.Manager](ctx),
+	}, nil
+}
+
+func (u *Updater) Name() string {
+	return u.name
+}
+
+func (u *Updater) Start() error {
+	if u.options.DownloadDetour != "" {
+		outbound, loaded := u.outboundManager.Outbound(u.options.DownloadDetour)
+		if !loaded {
+			return E.New("download_detour not found: ", u.options.DownloadDetour)
+		}
+		u.dialer = outbound
+	} else {
+		u.dialer = u.outboundManager.Default()
+	}
+	return nil
+}
+
+func (u *Updater) PostStart() {
+	go u.loopUpdate()
+}
+
+func (u *Updater) Close() error {
+	u.cancel()
+	return nil
+}
+
+func (u *Updater) loopUpdate() {
+	var lastUpdated time.Time
+	if stat, err := os.Stat(u.options.Path); err == nil {
+		lastUpdated = stat.ModTime()
+	}
+	timer := time.NewTimer(u.options.UpdateInterval - time.Since(lastUpdated))
+	defer timer.Stop()
+	for {
+		select {
+		case <-u.ctx.Done():
+			return
+		case <-timer.C:
+		}
+		u.pauseManager.WaitActive()
+		err := u.Update(u.ctx)
+		if err != nil && u.ctx.Err() == nil {
+			u.logger.Error(E.Cause(err, "update ", u.name))
+		}
+		timer.Reset(u.options.UpdateInterval)
+	}
+}
+
+// Update downloads and loads a new version of the file if its checksum differs
+// from the one of the current file.
+func (u *Updater) Update(ctx context.Context) error {
+	u.access.Lock()
+	defer u.access.Unlock()
+	httpClient := &http.Client{
+		Transport: &http.Transport{
+			ForceAttemptHTTP2:   true,
+			TLSHandshakeTimeout: C.TCPTimeout,
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return u.dialer.DialContext(ctx, network, M.ParseSocksaddr(addr))
+			},
+			TLSClientConfig: &tls.Config{
+				RootCAs: adapter.RootPoolFromContext(u.ctx),
+			},
+		},
+	}
+	defer httpClient.CloseIdleConnections()
+	checksum, err := fetchChecksum(ctx, httpClient, u.options.ChecksumURL)
+	if err != nil {
+		return E.Cause(err, "fetch checksum")
+	}
+	if currentChecksum, err := fileChecksum(u.options.Path); err == nil && currentChecksum == checksum {
+		os.Chtimes(u.options.Path, time.Now(), time.Now())
+		u.logger.Info("update ", u.name, ": not modified")
+		return nil
+	}
+	stagingPath := u.options.Path + stagingSuffix
+	err = download(ctx, httpClient, u.options.DownloadURL, stagingPath, checksum)
+	if err != nil {
+		os.Remove(stagingPath)
+		return err
+	}
+	backupPath := u.options.Path + backupSuffix
+	err = os.Rename(u.options.Path, backupPath)
+	hasBackup := err == nil
+	if err != nil && !os.IsNotExist(err) {
+		os.Remove(stagingPath)
+		return E.Cause(err, "backup current file")
+	}
+	err = os.Rename(stagingPath, u.options.Path)
+	if err != nil {
+		os.Remove(stagingPath)
+		if hasBackup {
+			os.Rename(backupPath, u.options.Path)
+		}
+		return E.Cause(err, "replace current file")
+	}
+	err = u.load(u.options.Path)
+	if err != nil {
+		if hasBackup {
+			u.rollback(backupPath)
+			return E.Cause(err, "load updated file, rolled back")
+		}
+		os.Remove(u.options.Path)
+		return E.Cause(err, "load updated file")
+	}
+	if hasBackup {
+		os.Remove(backupPath)
+	}
+	u.logger.Info("updated ", u.name)
+	return nil
+}
+
+func (u *Updater) rollback(backupPath string) {
+	err := os.Rename(backupPath, u.options.Path)
+	if err != nil {
+		u.logger.Error(E.Cause(err, "restore ", u.name))
+		return
+	}
+	err = u.load(u.options.Path)
+	if err != nil {
+		u.logger.Error(E.Cause(err, "reload restored ", u.name))
+	}
+}
+
+func fetchChecksum(ctx context.Context, httpClient *http.Client, checksumURL string) (string, error) {
+	response, err := get(ctx, httpClient, checksumURL)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	content, err := io.ReadAll(io.LimitReader(response.Body, 4096))
+	if err != nil {
+		return "", err
+	}
+	fields := strings.Fields(string(content))
+	if len(fields) == 0 {
+		return "", E.New("empty checksum")
+	}
+	checksum := strings.ToLower(fields[0])
+	if decoded, err := hex.DecodeString(checksum); err != nil || len(decoded) != sha256.Size {
+		return "", E.New("invalid SHA-256 checksum: ", fields[0])
+	}
+	return checksum, nil
+}
+
+func download(ctx context.Context, httpClient *http.Client, downloadURL string, savePath string, checksum string) error {
+	response, err := get(ctx, httpClient, downloadURL)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	saveFile, err := os.Create(savePath)
+	if err != nil {
+		return E.Cause(err, "open staging file")
+	}
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(saveFile, hash), response.Body)
+	saveFile.Close()
+	if err != nil {
+		return E.Cause(err, "download")
+	}
+	if actual := hex.EncodeToString(hash.Sum(nil)); actual != checksum {
+		return E.New("checksum mismatch: expected ", checksum, ", got ", actual)
+	}
+	return nil
+}
+
+func get(ctx context.Context, httpClient *http.Client, requestURL string) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		response.Body.Close()
+		return nil, E.New("unexpected status: ", response.Status)
+	}
+	return response, nil
+}
+
+func fileChecksum(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	_, err = io.Copy(hash, file)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
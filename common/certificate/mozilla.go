@@ -0,0 +1,9 @@
+package certificate
+
+import _ "embed"
+
+// mozillaIncluded is the Mozilla CA certificate bundle, the header of mozilla.pem
+// records its source, regenerate it with `make update_certificates`.
+//
+//go:embed mozilla.pem
+var mozillaIncluded []byte
@@ -0,0 +1,58 @@
+package certificate
+
+import (
+	"crypto/x509"
+	"os"
+	"strings"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+var _ adapter.CertificateStore = (*Store)(nil)
+
+type Store struct {
+	pool *x509.CertPool
+}
+
+func NewStore(options option.CertificateOptions) (*Store, error) {
+	var pool *x509.CertPool
+	switch options.Store {
+	case C.CertificateStoreSystem, "":
+		systemPool, err := x509.SystemCertPool()
+		if err != nil {
+			return nil, E.Cause(err, "load system certificates")
+		}
+		pool = systemPool
+	case C.CertificateStoreMozilla:
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(mozillaIncluded) {
+			return nil, E.New("load mozilla certificates")
+		}
+	case C.CertificateStoreNone:
+		pool = x509.NewCertPool()
+	default:
+		return nil, E.New("unknown certificate store: ", options.Store)
+	}
+	if len(options.Certificate) > 0 {
+		if !pool.AppendCertsFromPEM([]byte(strings.Join(options.Certificate, "\n"))) {
+			return nil, E.New("failed to parse certificate")
+		}
+	}
+	for _, path := range options.CertificatePath {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, E.Cause(err, "read certificate from ", path)
+		}
+		if !pool.AppendCertsFromPEM(content) {
+			return nil, E.New("failed to parse certificate from ", path)
+		}
+	}
+	return &Store{pool}, nil
+}
+
+func (s *Store) Pool() *x509.CertPool {
+	return s.pool
+}
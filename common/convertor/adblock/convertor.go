@@ -0,0 +1,130 @@
+package adblock
+
+import (
+	"bufio"
+	"io"
+	"strings"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/logger"
+	M "github.com/sagernet/sing/common/metadata"
+)
+
+// Convert converts an Adblock Plus filter list to a rule-set.
+//
+// Only rules that block or allow whole domains (`||example.org^`) can be
+// expressed by a rule-set, rules with paths, element hiding and modifiers
+// that narrow the request context are ignored.
+func Convert(reader io.Reader, logger logger.Logger) ([]option.HeadlessRule, error) {
+	scanner := bufio.NewScanner(reader)
+	var (
+		domain        []string
+		excludeDomain []string
+		ignoredLines  int
+	)
+	for scanner.Scan() {
+		ruleLine := strings.TrimSpace(scanner.Text())
+		if ruleLine == "" || ruleLine[0] == '!' || ruleLine[0] == '[' {
+			continue
+		}
+		ruleDomain, isExclude, err := parseRuleLine(ruleLine)
+		if err != nil {
+			ignoredLines++
+			logger.Debug("ignored unsupported rule: ", err, ": ", ruleLine)
+			continue
+		}
+		if isExclude {
+			excludeDomain = append(excludeDomain, ruleDomain)
+		} else {
+			domain = append(domain, ruleDomain)
+		}
+	}
+	err := scanner.Err()
+	if err != nil {
+		return nil, err
+	}
+	if len(domain) == 0 {
+		return nil, E.New("Adblock Plus filter list is empty or all rules are unsupported")
+	}
+	if ignoredLines > 0 {
+		logger.Warn("ignored ", ignoredLines, " unsupported rules")
+	}
+	logger.Info("parsed rules: ", len(domain)+len(excludeDomain), "/", len(domain)+len(excludeDomain)+ignoredLines)
+	currentRule := option.HeadlessRule{
+		Type: C.RuleTypeDefault,
+		DefaultOptions: option.DefaultHeadlessRule{
+			DomainSuffix: common.Uniq(domain),
+		},
+	}
+	if len(excludeDomain) > 0 {
+		currentRule = option.HeadlessRule{
+			Type: C.RuleTypeLogical,
+			LogicalOptions: option.LogicalHeadlessRule{
+				Mode: C.LogicalTypeAnd,
+				Rules: []option.HeadlessRule{
+					{
+						Type: C.RuleTypeDefault,
+						DefaultOptions: option.DefaultHeadlessRule{
+							DomainSuffix: common.Uniq(excludeDomain),
+							Invert:       true,
+						},
+					},
+					currentRule,
+				},
+			},
+		}
+	}
+	return []option.HeadlessRule{currentRule}, nil
+}
+
+func parseRuleLine(ruleLine string) (domain string, isExclude bool, err error) {
+	if strings.Contains(ruleLine, "##") || strings.Contains(ruleLine, "#@#") || strings.Contains(ruleLine, "#?#") || strings.Contains(ruleLine, "#$#") {
+		return "", false, E.New("element hiding")
+	}
+	if strings.HasPrefix(ruleLine, "@@") {
+		ruleLine = ruleLine[2:]
+		isExclude = true
+	}
+	if modifierIndex := strings.LastIndexByte(ruleLine, '$'); modifierIndex != -1 {
+		for _, modifier := range strings.Split(ruleLine[modifierIndex+1:], ",") {
+			if !isDomainWideModifier(modifier) {
+				return "", false, E.New("modifier ", modifier)
+			}
+		}
+		ruleLine = ruleLine[:modifierIndex]
+	}
+	if !strings.HasPrefix(ruleLine, "||") {
+		return "", false, E.New("not domain anchored")
+	}
+	ruleLine = ruleLine[2:]
+	ruleLine = strings.TrimSuffix(ruleLine, "|")
+	if strings.HasSuffix(ruleLine, "^") {
+		ruleLine = ruleLine[:len(ruleLine)-1]
+	} else if strings.HasSuffix(ruleLine, "/") {
+		ruleLine = ruleLine[:len(ruleLine)-1]
+	} else {
+		return "", false, E.New("missing separator")
+	}
+	if strings.ContainsAny(ruleLine, "*/^|") {
+		return "", false, E.New("wildcard or path")
+	}
+	ruleLine = strings.ToLower(ruleLine)
+	if !M.IsDomainName(ruleLine) {
+		return "", false, E.New("invalid domain")
+	}
+	return ruleLine, isExclude, nil
+}
+
+func isDomainWideModifier(modifier string) bool {
+	switch strings.TrimSpace(modifier) {
+	case "all", "document", "doc", "important", "popup", "third-party", "3p", "~third-party", "~3p", "first-party", "1p":
+		// Applied to the whole domain by a proxy, which sees neither the
+		// request type nor the initiator.
+		return true
+	default:
+		return false
+	}
+}
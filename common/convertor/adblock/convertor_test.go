@@ -0,0 +1,68 @@
+package adblock_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/convertor/adblock"
+	"github.com/sagernet/sing-box/route/rule"
+	"github.com/sagernet/sing/common/logger"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConverter(t *testing.T) {
+	t.Parallel()
+	rules, err := adblock.Convert(strings.NewReader(`
+[Adblock Plus 2.0]
+! Title: test
+||ads.example.org^
+||tracker.example.com^$third-party
+||Popup.example.net^$popup,important
+||cdn.example.org/ads/*
+||example.edu^$script
+example.gov##.banner
+/banner/*/img^
+@@||ok.ads.example.org^$document
+`), logger.NOP())
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+	rule, err := rule.NewHeadlessRule(context.Background(), rules[0])
+	require.NoError(t, err)
+	matchDomain := []string{
+		"ads.example.org",
+		"www.ads.example.org",
+		"tracker.example.com",
+		"popup.example.net",
+	}
+	notMatchDomain := []string{
+		"example.org",
+		"notads.example.org",
+		"ok.ads.example.org",
+		"www.ok.ads.example.org",
+		"cdn.example.org",
+		"example.edu",
+		"example.gov",
+	}
+	for _, domain := range matchDomain {
+		require.True(t, rule.Match(&adapter.InboundContext{
+			Domain: domain,
+		}), domain)
+	}
+	for _, domain := range notMatchDomain {
+		require.False(t, rule.Match(&adapter.InboundContext{
+			Domain: domain,
+		}), domain)
+	}
+}
+
+func TestEmpty(t *testing.T) {
+	t.Parallel()
+	_, err := adblock.Convert(strings.NewReader(`
+! only cosmetic rules
+example.com##.ad
+`), logger.NOP())
+	require.Error(t, err)
+}
@@ -0,0 +1,297 @@
+package clash
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/netip"
+	"regexp"
+	"strconv"
+	"strings"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/logger"
+	M "github.com/sagernet/sing/common/metadata"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	BehaviorDomain    = "domain"
+	BehaviorIPCIDR    = "ipcidr"
+	BehaviorClassical = "classical"
+)
+
+type ruleProvider struct {
+	Payload []string `yaml:"payload"`
+}
+
+// Convert converts a Clash rule provider in YAML or plain text format to a rule-set.
+func Convert(reader io.Reader, behavior string, logger logger.Logger) ([]option.HeadlessRule, error) {
+	payload, err := readPayload(reader)
+	if err != nil {
+		return nil, err
+	}
+	var converted ruleItems
+	switch behavior {
+	case BehaviorDomain:
+		converted.addDomains(payload, logger)
+	case BehaviorIPCIDR:
+		converted.addIPCIDRs(payload, logger)
+	case BehaviorClassical:
+		converted.addClassical(payload, logger)
+	default:
+		return nil, E.New("unknown rule provider behavior: ", behavior)
+	}
+	rules := converted.build()
+	if len(rules) == 0 {
+		return nil, E.New("Clash rule provider is empty or all rules are unsupported")
+	}
+	if converted.ignored > 0 {
+		logger.Warn("ignored ", converted.ignored, " unsupported rules")
+	}
+	logger.Info("parsed rules: ", len(payload)-converted.ignored, "/", len(payload))
+	return rules, nil
+}
+
+func readPayload(reader io.Reader) ([]string, error) {
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	var provider ruleProvider
+	if yaml.Unmarshal(content, &provider) == nil && len(provider.Payload) > 0 {
+		return provider.Payload, nil
+	}
+	var payload []string
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || strings.HasPrefix(line, "//") || line == "payload:" {
+			continue
+		}
+		line = strings.TrimPrefix(line, "- ")
+		line = strings.Trim(line, `'"`)
+		payload = append(payload, line)
+	}
+	return payload, scanner.Err()
+}
+
+type ruleItems struct {
+	domain          []string
+	domainSuffix    []string
+	domainKeyword   []string
+	domainRegex     []string
+	ipCIDR          []string
+	sourceIPCIDR    []string
+	port            []uint16
+	portRange       []string
+	sourcePort      []uint16
+	sourcePortRange []string
+	processName     []string
+	processPath     []string
+	network         []string
+	ignored         int
+}
+
+func (r *ruleItems) addDomains(payload []string, logger logger.Logger) {
+	for _, item := range payload {
+		err := r.addDomain(item)
+		if err != nil {
+			r.ignored++
+			logger.Debug("ignored unsupported domain: ", err, ": ", item)
+		}
+	}
+}
+
+func (r *ruleItems) addDomain(item string) error {
+	switch {
+	case strings.HasPrefix(item, "+."):
+		domain := item[2:]
+		if !M.IsDomainName(domain) {
+			return E.New("invalid domain")
+		}
+		r.domainSuffix = append(r.domainSuffix, domain)
+	case strings.HasPrefix(item, "."):
+		if !M.IsDomainName(item[1:]) {
+			return E.New("invalid domain")
+		}
+		r.domainSuffix = append(r.domainSuffix, item)
+	case strings.Contains(item, "*"):
+		if !M.IsDomainName(strings.ReplaceAll(item, "*", "x")) {
+			return E.New("invalid domain wildcard")
+		}
+		r.domainRegex = append(r.domainRegex, wildcardToRegex(item))
+	default:
+		if !M.IsDomainName(item) {
+			return E.New("invalid domain")
+		}
+		r.domain = append(r.domain, item)
+	}
+	return nil
+}
+
+func (r *ruleItems) addIPCIDRs(payload []string, logger logger.Logger) {
+	for _, item := range payload {
+		prefix, err := parsePrefix(item)
+		if err != nil {
+			r.ignored++
+			logger.Debug("ignored invalid IP CIDR: ", item)
+			continue
+		}
+		r.ipCIDR = append(r.ipCIDR, prefix)
+	}
+}
+
+func (r *ruleItems) addClassical(payload []string, logger logger.Logger) {
+	for _, item := range payload {
+		err := r.addClassicalRule(item)
+		if err != nil {
+			r.ignored++
+			logger.Debug("ignored unsupported rule: ", err, ": ", item)
+		}
+	}
+}
+
+func (r *ruleItems) addClassicalRule(item string) error {
+	parts := strings.Split(item, ",")
+	if len(parts) < 2 {
+		return E.New("missing payload")
+	}
+	ruleType := strings.ToUpper(strings.TrimSpace(parts[0]))
+	value := strings.TrimSpace(parts[1])
+	switch ruleType {
+	case "DOMAIN":
+		if !M.IsDomainName(value) {
+			return E.New("invalid domain")
+		}
+		r.domain = append(r.domain, value)
+	case "DOMAIN-SUFFIX":
+		if !M.IsDomainName(strings.TrimPrefix(value, ".")) {
+			return E.New("invalid domain")
+		}
+		r.domainSuffix = append(r.domainSuffix, value)
+	case "DOMAIN-KEYWORD":
+		r.domainKeyword = append(r.domainKeyword, value)
+	case "DOMAIN-REGEX":
+		_, err := regexp.Compile(value)
+		if err != nil {
+			return err
+		}
+		r.domainRegex = append(r.domainRegex, value)
+	case "DOMAIN-WILDCARD":
+		return r.addDomain(value)
+	case "IP-CIDR", "IP-CIDR6":
+		prefix, err := parsePrefix(value)
+		if err != nil {
+			return err
+		}
+		r.ipCIDR = append(r.ipCIDR, prefix)
+	case "SRC-IP-CIDR":
+		prefix, err := parsePrefix(value)
+		if err != nil {
+			return err
+		}
+		r.sourceIPCIDR = append(r.sourceIPCIDR, prefix)
+	case "DST-PORT":
+		return addPort(value, &r.port, &r.portRange)
+	case "SRC-PORT":
+		return addPort(value, &r.sourcePort, &r.sourcePortRange)
+	case "PROCESS-NAME":
+		r.processName = append(r.processName, value)
+	case "PROCESS-PATH":
+		r.processPath = append(r.processPath, value)
+	case "NETWORK":
+		network := strings.ToLower(value)
+		if network != "tcp" && network != "udp" {
+			return E.New("invalid network")
+		}
+		r.network = append(r.network, network)
+	default:
+		return E.New("rule type ", ruleType)
+	}
+	return nil
+}
+
+// build returns one rule per field group, because items of different groups
+// in a single rule are matched with AND, while Clash providers match any line.
+func (r *ruleItems) build() []option.HeadlessRule {
+	var rules []option.HeadlessRule
+	appendRule := func(rule option.DefaultHeadlessRule) {
+		rules = append(rules, option.HeadlessRule{
+			Type:           C.RuleTypeDefault,
+			DefaultOptions: rule,
+		})
+	}
+	if len(r.domain) > 0 || len(r.domainSuffix) > 0 || len(r.domainKeyword) > 0 || len(r.domainRegex) > 0 || len(r.ipCIDR) > 0 {
+		appendRule(option.DefaultHeadlessRule{
+			Domain:        r.domain,
+			DomainSuffix:  r.domainSuffix,
+			DomainKeyword: r.domainKeyword,
+			DomainRegex:   r.domainRegex,
+			IPCIDR:        r.ipCIDR,
+		})
+	}
+	if len(r.sourceIPCIDR) > 0 {
+		appendRule(option.DefaultHeadlessRule{SourceIPCIDR: r.sourceIPCIDR})
+	}
+	if len(r.port) > 0 || len(r.portRange) > 0 {
+		appendRule(option.DefaultHeadlessRule{Port: r.port, PortRange: r.portRange})
+	}
+	if len(r.sourcePort) > 0 || len(r.sourcePortRange) > 0 {
+		appendRule(option.DefaultHeadlessRule{SourcePort: r.sourcePort, SourcePortRange: r.sourcePortRange})
+	}
+	if len(r.processName) > 0 {
+		appendRule(option.DefaultHeadlessRule{ProcessName: r.processName})
+	}
+	if len(r.processPath) > 0 {
+		appendRule(option.DefaultHeadlessRule{ProcessPath: r.processPath})
+	}
+	if len(r.network) > 0 {
+		appendRule(option.DefaultHeadlessRule{Network: r.network})
+	}
+	return rules
+}
+
+func parsePrefix(value string) (string, error) {
+	prefix, err := netip.ParsePrefix(value)
+	if err == nil {
+		return prefix.String(), nil
+	}
+	address, addrErr := netip.ParseAddr(value)
+	if addrErr != nil {
+		return "", err
+	}
+	return netip.PrefixFrom(address, address.BitLen()).String(), nil
+}
+
+func addPort(value string, ports *[]uint16, portRanges *[]string) error {
+	for _, portItem := range strings.Split(value, "/") {
+		if strings.Contains(portItem, "-") {
+			portRange := strings.SplitN(portItem, "-", 2)
+			_, startErr := strconv.ParseUint(portRange[0], 10, 16)
+			_, endErr := strconv.ParseUint(portRange[1], 10, 16)
+			if startErr != nil || endErr != nil {
+				return E.New("invalid port range")
+			}
+			*portRanges = append(*portRanges, portRange[0]+":"+portRange[1])
+		} else {
+			port, err := strconv.ParseUint(portItem, 10, 16)
+			if err != nil {
+				return err
+			}
+			*ports = append(*ports, uint16(port))
+		}
+	}
+	return nil
+}
+
+func wildcardToRegex(wildcard string) string {
+	parts := strings.Split(wildcard, "*")
+	for i := range parts {
+		parts[i] = regexp.QuoteMeta(parts[i])
+	}
+	return "^" + strings.Join(parts, "[^.]+") + "$"
+}
@@ -0,0 +1,93 @@
+package clash_test
+
+import (
+	"context"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/convertor/clash"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/route/rule"
+	"github.com/sagernet/sing/common/logger"
+	M "github.com/sagernet/sing/common/metadata"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDomain(t *testing.T) {
+	t.Parallel()
+	rules, err := clash.Convert(strings.NewReader(`
+payload:
+  - '+.example.org'
+  - '.example.net'
+  - 'example.com'
+  - 'ad*.example.edu'
+`), clash.BehaviorDomain, logger.NOP())
+	require.NoError(t, err)
+	matchDomain := []string{
+		"example.org",
+		"www.example.org",
+		"www.example.net",
+		"example.com",
+		"ads.example.edu",
+	}
+	notMatchDomain := []string{
+		"example.net",
+		"www.example.com",
+		"notexample.org",
+		"www.ads.example.edu",
+	}
+	for _, domain := range matchDomain {
+		require.True(t, matchAny(t, rules, &adapter.InboundContext{Domain: domain}), domain)
+	}
+	for _, domain := range notMatchDomain {
+		require.False(t, matchAny(t, rules, &adapter.InboundContext{Domain: domain}), domain)
+	}
+}
+
+func TestIPCIDR(t *testing.T) {
+	t.Parallel()
+	rules, err := clash.Convert(strings.NewReader(`
+# plain text provider
+10.0.0.0/8
+2001:db8::/32
+1.1.1.1
+`), clash.BehaviorIPCIDR, logger.NOP())
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+	require.Equal(t, []string{"10.0.0.0/8", "2001:db8::/32", "1.1.1.1/32"}, []string(rules[0].DefaultOptions.IPCIDR))
+}
+
+func TestClassical(t *testing.T) {
+	t.Parallel()
+	rules, err := clash.Convert(strings.NewReader(`
+payload:
+  - DOMAIN-SUFFIX,example.org
+  - DOMAIN-KEYWORD,tracker
+  - IP-CIDR,192.168.0.0/16,no-resolve
+  - DST-PORT,853
+  - PROCESS-NAME,curl
+  - GEOIP,CN
+`), clash.BehaviorClassical, logger.NOP())
+	require.NoError(t, err)
+	require.Len(t, rules, 3)
+	require.True(t, matchAny(t, rules, &adapter.InboundContext{Domain: "www.example.org"}))
+	require.True(t, matchAny(t, rules, &adapter.InboundContext{Domain: "tracker.example.com"}))
+	require.True(t, matchAny(t, rules, &adapter.InboundContext{Destination: M.SocksaddrFrom(netip.MustParseAddr("192.168.1.1"), 443)}))
+	require.True(t, matchAny(t, rules, &adapter.InboundContext{Domain: "example.com", Destination: M.Socksaddr{Fqdn: "example.com", Port: 853}}))
+	require.False(t, matchAny(t, rules, &adapter.InboundContext{Domain: "example.com", Destination: M.Socksaddr{Fqdn: "example.com", Port: 443}}))
+}
+
+func matchAny(t *testing.T, rules []option.HeadlessRule, metadata *adapter.InboundContext) bool {
+	for _, ruleOptions := range rules {
+		headlessRule, err := rule.NewHeadlessRule(context.Background(), ruleOptions)
+		require.NoError(t, err)
+		metadata.ResetRuleCache()
+		if headlessRule.Match(metadata) {
+			return true
+		}
+	}
+	return false
+}
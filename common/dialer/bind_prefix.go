@@ -0,0 +1,70 @@
+package dialer
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"net/netip"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+)
+
+// bindPrefix selects source addresses from a routed IPv6 prefix, randomly
+// for each connection, or by a keyed hash of the destination.
+type bindPrefix struct {
+	prefix        netip.Prefix
+	byDestination bool
+	key           [32]byte
+}
+
+func newBindPrefix(prefix netip.Prefix, mode string) (*bindPrefix, error) {
+	if !prefix.Addr().Is6() || prefix.Addr().Is4In6() {
+		return nil, E.New("`inet6_bind_prefix` must be an IPv6 prefix")
+	}
+	if prefix.Bits() > 120 {
+		return nil, E.New("`inet6_bind_prefix` is too small: ", prefix)
+	}
+	bindPrefix := &bindPrefix{prefix: prefix.Masked()}
+	switch mode {
+	case "", C.BindPrefixModeConnection:
+	case C.BindPrefixModeDestination:
+		bindPrefix.byDestination = true
+	default:
+		return nil, E.New("unknown `inet6_bind_prefix_mode`: ", mode)
+	}
+	common.Must1(rand.Read(bindPrefix.key[:]))
+	return bindPrefix, nil
+}
+
+func (p *bindPrefix) Address(ctx context.Context, destination M.Socksaddr) netip.Addr {
+	var host [16]byte
+	if p.byDestination {
+		// keyed by the domain if available, so that all addresses of a site share the source address
+		destinationKey := destination.AddrString()
+		if metadata := adapter.ContextFrom(ctx); metadata != nil && metadata.Destination.IsFqdn() {
+			destinationKey = metadata.Destination.Fqdn
+		}
+		hash := sha256.Sum256(append(p.key[:], destinationKey...))
+		copy(host[:], hash[:])
+	} else {
+		common.Must1(rand.Read(host[:]))
+	}
+	address := p.prefix.Addr().As16()
+	bits := p.prefix.Bits()
+	for i := bits / 8; i < 16; i++ {
+		mask := byte(0xff)
+		if i == bits/8 {
+			mask >>= bits % 8
+		}
+		address[i] |= host[i] & mask
+	}
+	// the first address of the prefix is the subnet-router anycast address
+	if netip.AddrFrom16(address) == p.prefix.Addr() {
+		address[15] |= 1
+	}
+	return netip.AddrFrom16(address)
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package dialer
+
+import (
+	"github.com/sagernet/sing/common/control"
+)
+
+func freeBind() control.Func {
+	return nil
+}
@@ -35,6 +35,7 @@ type DefaultDialer struct {
 	udpListener            net.ListenConfig
 	udpAddr4               string
 	udpAddr6               string
+	inet6BindPrefix        *bindPrefix
 	isWireGuardListener    bool
 	networkManager         adapter.NetworkManager
 	networkStrategy        *C.NetworkStrategy
@@ -83,10 +84,10 @@ func NewDefault(ctx context.Context, options option.DialerOptions) (*DefaultDial
 			listener.Control = control.Append(listener.Control, control.RoutingMark(autoRedirectOutputMark))
 		}
 	}
-	disableDefaultBind := options.BindInterface != "" || options.Inet4BindAddress != nil || options.Inet6BindAddress != nil
+	disableDefaultBind := options.BindInterface != "" || options.Inet4BindAddress != nil || options.Inet6BindAddress != nil || options.Inet6BindPrefix != nil
 	if disableDefaultBind || options.TCPFastOpen {
 		if options.NetworkStrategy != nil || len(options.NetworkType) > 0 && options.FallbackNetworkType == nil && options.FallbackDelay == 0 {
-			return nil, E.New("`network_strategy` is conflict with `bind_interface`, `inet4_bind_address`, `inet6_bind_address` and `tcp_fast_open`")
+			return nil, E.New("`network_strategy` is conflict with `bind_interface`, `inet4_bind_address`, `inet6_bind_address`, `inet6_bind_prefix` and `tcp_fast_open`")
 		}
 	}
 
@@ -94,7 +95,7 @@ func NewDefault(ctx context.Context, options option.DialerOptions) (*DefaultDial
 		defaultOptions := networkManager.DefaultOptions()
 		if !disableDefaultBind {
 			if defaultOptions.BindInterface != "" {
-				bindFunc := control.BindToInterface(networkManager.InterfaceFinder(), defaultOptions.BindInterface, -1)
+				bindFunc := networkManager.BindDefaultInterfaceFunc()
 				dialer.Control = control.Append(dialer.Control, bindFunc)
 				listener.Control = control.Append(listener.Control, bindFunc)
 			} else if networkManager.AutoDetectInterface() {
@@ -140,7 +141,7 @@ func NewDefault(ctx context.Context, options option.DialerOptions) (*DefaultDial
 	if options.ConnectTimeout != 0 {
 		dialer.Timeout = time.Duration(options.ConnectTimeout)
 	} else {
-		dialer.Timeout = C.TCPConnectTimeout
+		dialer.Timeout = adapter.TimeoutsFromContext(ctx).Override(options.Timeouts).TCPHandshake
 	}
 	// TODO: Add an option to customize the keep alive period
 	dialer.KeepAlive = C.TCPKeepAliveInitial
@@ -177,11 +178,26 @@ func NewDefault(ctx context.Context, options option.DialerOptions) (*DefaultDial
 		udpDialer6.LocalAddr = &net.UDPAddr{IP: bindAddr.AsSlice()}
 		udpAddr6 = M.SocksaddrFrom(bindAddr, 0).String()
 	}
+	var inet6BindPrefix *bindPrefix
+	if options.Inet6BindPrefix != nil {
+		if options.Inet6BindAddress != nil {
+			return nil, E.New("`inet6_bind_prefix` is conflict with `inet6_bind_address`")
+		}
+		var err error
+		inet6BindPrefix, err = newBindPrefix(netip.Prefix(*options.Inet6BindPrefix), options.Inet6BindPrefixMode)
+		if err != nil {
+			return nil, err
+		}
+		dialer6.Control = control.Append(dialer6.Control, freeBind())
+		udpDialer6.Control = control.Append(udpDialer6.Control, freeBind())
+		listener.Control = control.Append(listener.Control, freeBind())
+	}
 	if options.TCPMultiPath {
 		if !go121Available {
 			return nil, E.New("MultiPath TCP requires go1.21, please recompile your binary.")
 		}
 		setMultiPathTCP(&dialer4)
+		setMultiPathTCP(&dialer6)
 	}
 	if options.IsWireGuardListener {
 		for _, controlFn := range WgControlFns {
@@ -204,6 +220,7 @@ func NewDefault(ctx context.Context, options option.DialerOptions) (*DefaultDial
 		udpListener:            listener,
 		udpAddr4:               udpAddr4,
 		udpAddr6:               udpAddr6,
+		inet6BindPrefix:        inet6BindPrefix,
 		isWireGuardListener:    options.IsWireGuardListener,
 		networkManager:         networkManager,
 		networkStrategy:        networkStrategy,
@@ -219,24 +236,47 @@ func (d *DefaultDialer) DialContext(ctx context.Context, network string, address
 		return nil, E.New("invalid address")
 	}
 	if d.networkStrategy == nil {
+		if d.inet6BindPrefix != nil && address.IsIPv6() {
+			return d.dialInet6BindPrefix(ctx, network, address)
+		}
 		switch N.NetworkName(network) {
 		case N.NetworkUDP:
+			var udpDialer net.Dialer
 			if !address.IsIPv6() {
-				return trackConn(d.udpDialer4.DialContext(ctx, network, address.String()))
+				udpDialer = d.udpDialer4
 			} else {
-				return trackConn(d.udpDialer6.DialContext(ctx, network, address.String()))
+				udpDialer = d.udpDialer6
 			}
+			udpDialer.Control = withTrafficClass(ctx, udpDialer.Control)
+			return trackConn(udpDialer.DialContext(ctx, network, address.String()))
 		}
+		var tcpDialer tcpDialer
 		if !address.IsIPv6() {
-			return trackConn(DialSlowContext(&d.dialer4, ctx, network, address))
+			tcpDialer = d.dialer4
 		} else {
-			return trackConn(DialSlowContext(&d.dialer6, ctx, network, address))
+			tcpDialer = d.dialer6
 		}
+		tcpDialer.Control = withTrafficClass(ctx, tcpDialer.Control)
+		return trackConn(DialSlowContext(&tcpDialer, ctx, network, address))
 	} else {
 		return d.DialParallelInterface(ctx, network, address, d.networkStrategy, d.networkType, d.fallbackNetworkType, d.networkFallbackDelay)
 	}
 }
 
+func (d *DefaultDialer) dialInet6BindPrefix(ctx context.Context, network string, address M.Socksaddr) (net.Conn, error) {
+	bindAddr := d.inet6BindPrefix.Address(ctx, address)
+	if N.NetworkName(network) == N.NetworkUDP {
+		udpDialer := d.udpDialer6
+		udpDialer.LocalAddr = &net.UDPAddr{IP: bindAddr.AsSlice()}
+		udpDialer.Control = withTrafficClass(ctx, udpDialer.Control)
+		return trackConn(udpDialer.DialContext(ctx, network, address.String()))
+	}
+	tcpDialer := d.dialer6
+	tcpDialer.LocalAddr = &net.TCPAddr{IP: bindAddr.AsSlice()}
+	tcpDialer.Control = withTrafficClass(ctx, tcpDialer.Control)
+	return trackConn(DialSlowContext(&tcpDialer, ctx, network, address))
+}
+
 func (d *DefaultDialer) DialParallelInterface(ctx context.Context, network string, address M.Socksaddr, strategy *C.NetworkStrategy, interfaceType []C.InterfaceType, fallbackInterfaceType []C.InterfaceType, fallbackDelay time.Duration) (net.Conn, error) {
 	if strategy == nil {
 		strategy = d.networkStrategy
@@ -259,6 +299,7 @@ func (d *DefaultDialer) DialParallelInterface(ctx context.Context, network strin
 	} else {
 		dialer = d.udpDialer4
 	}
+	dialer.Control = withTrafficClass(ctx, dialer.Control)
 	fastFallback := time.Now().Sub(d.networkLastFallback.Load()) < C.TCPTimeout
 	var (
 		conn      net.Conn
@@ -287,12 +328,17 @@ func (d *DefaultDialer) DialParallelInterface(ctx context.Context, network strin
 
 func (d *DefaultDialer) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
 	if d.networkStrategy == nil {
-		if destination.IsIPv6() {
-			return trackPacketConn(d.udpListener.ListenPacket(ctx, N.NetworkUDP, d.udpAddr6))
+		listener := d.udpListener
+		listener.Control = withTrafficClass(ctx, listener.Control)
+		if d.inet6BindPrefix != nil && destination.IsIPv6() {
+			bindAddr := d.inet6BindPrefix.Address(ctx, destination)
+			return trackPacketConn(listener.ListenPacket(ctx, N.NetworkUDP, M.SocksaddrFrom(bindAddr, 0).String()))
+		} else if destination.IsIPv6() {
+			return trackPacketConn(listener.ListenPacket(ctx, N.NetworkUDP, d.udpAddr6))
 		} else if destination.IsIPv4() && !destination.Addr.IsUnspecified() {
-			return trackPacketConn(d.udpListener.ListenPacket(ctx, N.NetworkUDP+"4", d.udpAddr4))
+			return trackPacketConn(listener.ListenPacket(ctx, N.NetworkUDP+"4", d.udpAddr4))
 		} else {
-			return trackPacketConn(d.udpListener.ListenPacket(ctx, N.NetworkUDP, d.udpAddr4))
+			return trackPacketConn(listener.ListenPacket(ctx, N.NetworkUDP, d.udpAddr4))
 		}
 	} else {
 		return d.ListenSerialInterfacePacket(ctx, destination, d.networkStrategy, d.networkType, d.fallbackNetworkType, d.networkFallbackDelay)
@@ -319,7 +365,9 @@ func (d *DefaultDialer) ListenSerialInterfacePacket(ctx context.Context, destina
 	if destination.IsIPv4() && !destination.Addr.IsUnspecified() {
 		network += "4"
 	}
-	packetConn, err := d.listenSerialInterfacePacket(ctx, d.udpListener, network, "", *strategy, interfaceType, fallbackInterfaceType, fallbackDelay)
+	listener := d.udpListener
+	listener.Control = withTrafficClass(ctx, listener.Control)
+	packetConn, err := d.listenSerialInterfacePacket(ctx, listener, network, "", *strategy, interfaceType, fallbackInterfaceType, fallbackDelay)
 	if err != nil {
 		// bind interface failed on legacy xiaomi systems
 		if d.defaultNetworkStrategy && errors.Is(err, syscall.EPERM) {
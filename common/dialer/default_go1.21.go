@@ -9,3 +9,8 @@ const go121Available = true
 func setMultiPathTCP(dialer *net.Dialer) {
 	dialer.SetMultipathTCP(true)
 }
+
+func isMultiPathTCP(conn *net.TCPConn) bool {
+	used, _ := conn.MultipathTCP()
+	return used
+}
@@ -10,3 +10,7 @@ const go121Available = false
 
 func setMultiPathTCP(dialer *net.Dialer) {
 }
+
+func isMultiPathTCP(conn *net.TCPConn) bool {
+	return false
+}
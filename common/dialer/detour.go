@@ -4,6 +4,7 @@ import (
 	"context"
 	"net"
 	"sync"
+	"time"
 
 	"github.com/sagernet/sing-box/adapter"
 	E "github.com/sagernet/sing/common/exceptions"
@@ -44,7 +45,13 @@ func (d *DetourDialer) DialContext(ctx context.Context, network string, destinat
 	if err != nil {
 		return nil, err
 	}
-	return dialer.DialContext(ctx, network, destination)
+	startedAt := time.Now()
+	conn, err := dialer.DialContext(ctx, network, destination)
+	if err != nil {
+		return nil, E.Cause(err, "detour[", d.detour, "]")
+	}
+	d.recordHop(ctx, startedAt)
+	return conn, nil
 }
 
 func (d *DetourDialer) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
@@ -52,7 +59,24 @@ func (d *DetourDialer) ListenPacket(ctx context.Context, destination M.Socksaddr
 	if err != nil {
 		return nil, err
 	}
-	return dialer.ListenPacket(ctx, destination)
+	startedAt := time.Now()
+	conn, err := dialer.ListenPacket(ctx, destination)
+	if err != nil {
+		return nil, E.Cause(err, "detour[", d.detour, "]")
+	}
+	d.recordHop(ctx, startedAt)
+	return conn, nil
+}
+
+func (d *DetourDialer) recordHop(ctx context.Context, startedAt time.Time) {
+	metadata := adapter.ContextFrom(ctx)
+	if metadata == nil || metadata.DialHops == nil {
+		return
+	}
+	metadata.DialHops.Add(adapter.DialHop{
+		Outbound: d.detour,
+		Latency:  time.Since(startedAt),
+	})
 }
 
 func (d *DetourDialer) Upstream() any {
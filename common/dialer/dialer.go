@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/forwarded"
+	"github.com/sagernet/sing-box/common/proxyproto"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/option"
 	"github.com/sagernet/sing-dns"
@@ -25,7 +27,12 @@ func New(ctx context.Context, options option.DialerOptions) (N.Dialer, error) {
 		err    error
 	)
 	if options.Detour == "" {
-		dialer, err = NewDefault(ctx, options)
+		var defaultDialer *DefaultDialer
+		defaultDialer, err = NewDefault(ctx, options)
+		if err != nil {
+			return nil, err
+		}
+		dialer, err = wrapDefault(defaultDialer, options)
 		if err != nil {
 			return nil, err
 		}
@@ -35,21 +42,47 @@ func New(ctx context.Context, options option.DialerOptions) (N.Dialer, error) {
 			return nil, E.New("missing outbound manager")
 		}
 		dialer = NewDetour(outboundManager, options.Detour)
+		if options.SendProxyProtocol {
+			dialer = proxyproto.NewDialer(dialer)
+		}
+		if options.ForwardedHeader != nil {
+			rewriter, err := newForwardedRewriter(options.ForwardedHeader)
+			if err != nil {
+				return nil, err
+			}
+			dialer = forwarded.NewDialer(dialer, rewriter)
+		}
 	}
-	if options.Detour == "" {
-		router := service.FromContext[adapter.Router](ctx)
-		if router != nil {
+	router := service.FromContext[adapter.Router](ctx)
+	if router != nil {
+		if options.Detour == "" {
 			dialer = NewResolveDialer(
 				router,
 				dialer,
 				options.Detour == "" && !options.TCPFastOpen,
-				dns.DomainStrategy(options.DomainStrategy),
+				resolveOptions(options),
 				time.Duration(options.FallbackDelay))
+		} else if options.DomainResolver != nil {
+			return nil, E.New("`domain_resolver` is not supported with `detour`")
 		}
 	}
 	return dialer, nil
 }
 
+// NewServer creates a dialer for an outbound connecting to server,
+// resolving it through SRV records if it is in the `_service._proto.name` form.
+func NewServer(ctx context.Context, options option.DialerOptions, server M.Socksaddr) (N.Dialer, error) {
+	dialer, err := New(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+	router := service.FromContext[adapter.Router](ctx)
+	if router != nil {
+		dialer = NewSRVDialer(router, dialer, server)
+	}
+	return dialer, nil
+}
+
 func NewDirect(ctx context.Context, options option.DialerOptions) (ParallelInterfaceDialer, error) {
 	if options.Detour != "" {
 		return nil, E.New("`detour` is not supported in direct context")
@@ -57,7 +90,11 @@ func NewDirect(ctx context.Context, options option.DialerOptions) (ParallelInter
 	if options.IsWireGuardListener {
 		return NewDefault(ctx, options)
 	}
-	dialer, err := NewDefault(ctx, options)
+	defaultDialer, err := NewDefault(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+	dialer, err := wrapDefault(defaultDialer, options)
 	if err != nil {
 		return nil, err
 	}
@@ -65,11 +102,44 @@ func NewDirect(ctx context.Context, options option.DialerOptions) (ParallelInter
 		service.FromContext[adapter.Router](ctx),
 		dialer,
 		true,
-		dns.DomainStrategy(options.DomainStrategy),
+		resolveOptions(options),
 		time.Duration(options.FallbackDelay),
 	), nil
 }
 
+func wrapDefault(defaultDialer *DefaultDialer, options option.DialerOptions) (ParallelInterfaceDialer, error) {
+	var dialer ParallelInterfaceDialer = defaultDialer
+	if options.SendProxyProtocol {
+		dialer = &proxyProtocolDialer{dialer}
+	}
+	if options.ForwardedHeader != nil {
+		rewriter, err := newForwardedRewriter(options.ForwardedHeader)
+		if err != nil {
+			return nil, err
+		}
+		dialer = &forwardedDialer{dialer, rewriter}
+	}
+	return dialer, nil
+}
+
+func resolveOptions(options option.DialerOptions) ResolveOptions {
+	resolveOptions := ResolveOptions{
+		Strategy: dns.DomainStrategy(options.DomainStrategy),
+	}
+	if options.DomainResolver != nil {
+		resolveOptions.Server = options.DomainResolver.Server
+		if options.DomainResolver.Strategy != option.DomainStrategy(dns.DomainStrategyAsIS) {
+			resolveOptions.Strategy = dns.DomainStrategy(options.DomainResolver.Strategy)
+		}
+		if options.DomainResolver.NegativeCacheTTL > 0 {
+			resolveOptions.NegativeCacheTTL = time.Duration(options.DomainResolver.NegativeCacheTTL)
+		} else {
+			resolveOptions.NegativeCacheTTL = C.DNSNegativeCacheTTL
+		}
+	}
+	return resolveOptions
+}
+
 type ParallelInterfaceDialer interface {
 	N.Dialer
 	DialParallelInterface(ctx context.Context, network string, destination M.Socksaddr, strategy *C.NetworkStrategy, interfaceType []C.InterfaceType, fallbackInterfaceType []C.InterfaceType, fallbackDelay time.Duration) (net.Conn, error)
@@ -0,0 +1,46 @@
+package dialer
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/sagernet/sing-box/common/forwarded"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+)
+
+var _ ParallelInterfaceDialer = (*forwardedDialer)(nil)
+
+// forwardedDialer is forwarded.Dialer keeping the parallel interface
+// methods of the default dialer.
+type forwardedDialer struct {
+	ParallelInterfaceDialer
+	rewriter *forwarded.Rewriter
+}
+
+func newForwardedRewriter(options *option.ForwardedHeaderOptions) (*forwarded.Rewriter, error) {
+	rewriter, err := forwarded.NewRewriter(options.Mode, options.Headers)
+	if err != nil {
+		return nil, E.Cause(err, "forwarded_header")
+	}
+	return rewriter, nil
+}
+
+func (d *forwardedDialer) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	conn, err := d.ParallelInterfaceDialer.DialContext(ctx, network, destination)
+	if err != nil {
+		return nil, err
+	}
+	return forwarded.WrapConn(ctx, network, conn, d.rewriter), nil
+}
+
+func (d *forwardedDialer) DialParallelInterface(ctx context.Context, network string, destination M.Socksaddr, strategy *C.NetworkStrategy, interfaceType []C.InterfaceType, fallbackInterfaceType []C.InterfaceType, fallbackDelay time.Duration) (net.Conn, error) {
+	conn, err := d.ParallelInterfaceDialer.DialParallelInterface(ctx, network, destination, strategy, interfaceType, fallbackInterfaceType, fallbackDelay)
+	if err != nil {
+		return nil, err
+	}
+	return forwarded.WrapConn(ctx, network, conn, d.rewriter), nil
+}
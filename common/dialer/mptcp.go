@@ -0,0 +1,33 @@
+package dialer
+
+import (
+	"net"
+
+	"github.com/sagernet/sing/common"
+)
+
+type MultiPathTCPStatus struct {
+	// Fallback reports that Multipath TCP was requested, but the connection
+	// fell back to TCP, e.g. because the server does not support it.
+	Fallback bool
+	// Subflows is the number of established subflows, or zero if unknown.
+	Subflows int
+}
+
+// ReadMultiPathTCPStatus returns the Multipath TCP status of the TCP socket
+// under conn, or false if Multipath TCP was not requested for it.
+func ReadMultiPathTCPStatus(conn any) (status MultiPathTCPStatus, loaded bool) {
+	tcpConn, isTCPConn := common.Cast[*net.TCPConn](conn)
+	if !isTCPConn || !isMultiPathTCPSocket(tcpConn) {
+		return
+	}
+	if !isMultiPathTCP(tcpConn) {
+		status.Fallback = true
+		return status, true
+	}
+	subflows, err := multiPathTCPSubflows(tcpConn)
+	if err == nil {
+		status.Subflows = subflows
+	}
+	return status, true
+}
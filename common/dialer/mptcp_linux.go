@@ -0,0 +1,37 @@
+package dialer
+
+import (
+	"net"
+	"syscall"
+	"unsafe"
+
+	"github.com/sagernet/sing/common/control"
+
+	"golang.org/x/sys/unix"
+)
+
+const mptcpInfo = 1
+
+func isMultiPathTCPSocket(conn *net.TCPConn) bool {
+	protocol, err := control.Conn0[int](conn, func(fd uintptr) (int, error) {
+		return unix.GetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_PROTOCOL)
+	})
+	return err == nil && protocol == unix.IPPROTO_MPTCP
+}
+
+// multiPathTCPSubflows reads struct mptcp_info, whose first field counts the
+// subflows added besides the initial one.
+func multiPathTCPSubflows(conn *net.TCPConn) (int, error) {
+	return control.Conn0[int](conn, func(fd uintptr) (int, error) {
+		var info [64]byte
+		length := uint32(len(info))
+		_, _, errno := unix.Syscall6(unix.SYS_GETSOCKOPT, fd, unix.SOL_MPTCP, mptcpInfo, uintptr(unsafe.Pointer(&info[0])), uintptr(unsafe.Pointer(&length)), 0)
+		if errno != 0 {
+			return 0, errno
+		}
+		if length == 0 {
+			return 0, syscall.EOPNOTSUPP
+		}
+		return int(info[0]) + 1, nil
+	})
+}
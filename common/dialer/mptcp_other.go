@@ -0,0 +1,16 @@
+//go:build !linux
+
+package dialer
+
+import (
+	"net"
+	"os"
+)
+
+func isMultiPathTCPSocket(conn *net.TCPConn) bool {
+	return false
+}
+
+func multiPathTCPSubflows(conn *net.TCPConn) (int, error) {
+	return 0, os.ErrInvalid
+}
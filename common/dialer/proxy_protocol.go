@@ -0,0 +1,51 @@
+package dialer
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/sagernet/sing-box/common/proxyproto"
+	C "github.com/sagernet/sing-box/constant"
+	M "github.com/sagernet/sing/common/metadata"
+)
+
+var _ ParallelInterfaceDialer = (*proxyProtocolDialer)(nil)
+
+// proxyProtocolDialer is proxyproto.Dialer keeping the parallel interface
+// methods of the default dialer.
+type proxyProtocolDialer struct {
+	ParallelInterfaceDialer
+}
+
+func (d *proxyProtocolDialer) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	conn, err := d.ParallelInterfaceDialer.DialContext(ctx, network, destination)
+	if err != nil {
+		return nil, err
+	}
+	return proxyproto.WrapConn(ctx, network, conn)
+}
+
+func (d *proxyProtocolDialer) DialParallelInterface(ctx context.Context, network string, destination M.Socksaddr, strategy *C.NetworkStrategy, interfaceType []C.InterfaceType, fallbackInterfaceType []C.InterfaceType, fallbackDelay time.Duration) (net.Conn, error) {
+	conn, err := d.ParallelInterfaceDialer.DialParallelInterface(ctx, network, destination, strategy, interfaceType, fallbackInterfaceType, fallbackDelay)
+	if err != nil {
+		return nil, err
+	}
+	return proxyproto.WrapConn(ctx, network, conn)
+}
+
+func (d *proxyProtocolDialer) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
+	conn, err := d.ParallelInterfaceDialer.ListenPacket(ctx, destination)
+	if err != nil {
+		return nil, err
+	}
+	return proxyproto.WrapPacketConn(ctx, conn), nil
+}
+
+func (d *proxyProtocolDialer) ListenSerialInterfacePacket(ctx context.Context, destination M.Socksaddr, strategy *C.NetworkStrategy, interfaceType []C.InterfaceType, fallbackInterfaceType []C.InterfaceType, fallbackDelay time.Duration) (net.PacketConn, error) {
+	conn, err := d.ParallelInterfaceDialer.ListenSerialInterfacePacket(ctx, destination, strategy, interfaceType, fallbackInterfaceType, fallbackDelay)
+	if err != nil {
+		return nil, err
+	}
+	return proxyproto.WrapPacketConn(ctx, conn), nil
+}
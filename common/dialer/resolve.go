@@ -11,6 +11,8 @@ import (
 	"github.com/sagernet/sing-box/log"
 	"github.com/sagernet/sing-dns"
 	"github.com/sagernet/sing/common/bufio"
+	"github.com/sagernet/sing/common/cache"
+	E "github.com/sagernet/sing/common/exceptions"
 	M "github.com/sagernet/sing/common/metadata"
 	N "github.com/sagernet/sing/common/network"
 )
@@ -25,17 +27,41 @@ type resolveDialer struct {
 	parallel      bool
 	router        adapter.Router
 	strategy      dns.DomainStrategy
+	server        string
+	negativeCache *cache.LruCache[string, error]
 	fallbackDelay time.Duration
 }
 
-func NewResolveDialer(router adapter.Router, dialer N.Dialer, parallel bool, strategy dns.DomainStrategy, fallbackDelay time.Duration) N.Dialer {
-	return &resolveDialer{
-		dialer,
-		parallel,
-		router,
-		strategy,
-		fallbackDelay,
-	}
+// ResolveOptions pins how a dialer resolves domain destinations.
+type ResolveOptions struct {
+	Strategy dns.DomainStrategy
+	// Server is the tag of the DNS server to use instead of DNS rules.
+	Server string
+	// NegativeCacheTTL is how long lookup failures are cached, zero disables the cache.
+	NegativeCacheTTL time.Duration
+}
+
+func NewResolveDialer(router adapter.Router, dialer N.Dialer, parallel bool, options ResolveOptions, fallbackDelay time.Duration) N.Dialer {
+	return newResolveDialer(router, dialer, parallel, options, fallbackDelay)
+}
+
+func newResolveDialer(router adapter.Router, dialer N.Dialer, parallel bool, options ResolveOptions, fallbackDelay time.Duration) *resolveDialer {
+	resolveDialer := &resolveDialer{
+		dialer:        dialer,
+		parallel:      parallel,
+		router:        router,
+		strategy:      options.Strategy,
+		server:        options.Server,
+		fallbackDelay: fallbackDelay,
+	}
+	if options.NegativeCacheTTL > 0 {
+		negativeCacheTTL := int64((options.NegativeCacheTTL + time.Second - 1) / time.Second)
+		resolveDialer.negativeCache = cache.New[string, error](
+			cache.WithAge[string, error](negativeCacheTTL),
+			cache.WithSize[string, error](1024),
+		)
+	}
+	return resolveDialer
 }
 
 type resolveParallelNetworkDialer struct {
@@ -43,15 +69,9 @@ type resolveParallelNetworkDialer struct {
 	dialer ParallelInterfaceDialer
 }
 
-func NewResolveParallelInterfaceDialer(router adapter.Router, dialer ParallelInterfaceDialer, parallel bool, strategy dns.DomainStrategy, fallbackDelay time.Duration) ParallelInterfaceDialer {
+func NewResolveParallelInterfaceDialer(router adapter.Router, dialer ParallelInterfaceDialer, parallel bool, options ResolveOptions, fallbackDelay time.Duration) ParallelInterfaceDialer {
 	return &resolveParallelNetworkDialer{
-		resolveDialer{
-			dialer,
-			parallel,
-			router,
-			strategy,
-			fallbackDelay,
-		},
+		*newResolveDialer(router, dialer, parallel, options, fallbackDelay),
 		dialer,
 	}
 }
@@ -64,13 +84,7 @@ func (d *resolveDialer) DialContext(ctx context.Context, network string, destina
 	ctx = log.ContextWithOverrideLevel(ctx, log.LevelDebug)
 	metadata.Destination = destination
 	metadata.Domain = ""
-	var addresses []netip.Addr
-	var err error
-	if d.strategy == dns.DomainStrategyAsIS {
-		addresses, err = d.router.LookupDefault(ctx, destination.Fqdn)
-	} else {
-		addresses, err = d.router.Lookup(ctx, destination.Fqdn, d.strategy)
-	}
+	addresses, err := d.lookup(ctx, destination.Fqdn)
 	if err != nil {
 		return nil, err
 	}
@@ -89,13 +103,7 @@ func (d *resolveDialer) ListenPacket(ctx context.Context, destination M.Socksadd
 	ctx = log.ContextWithOverrideLevel(ctx, log.LevelDebug)
 	metadata.Destination = destination
 	metadata.Domain = ""
-	var addresses []netip.Addr
-	var err error
-	if d.strategy == dns.DomainStrategyAsIS {
-		addresses, err = d.router.LookupDefault(ctx, destination.Fqdn)
-	} else {
-		addresses, err = d.router.Lookup(ctx, destination.Fqdn, d.strategy)
-	}
+	addresses, err := d.lookup(ctx, destination.Fqdn)
 	if err != nil {
 		return nil, err
 	}
@@ -114,13 +122,7 @@ func (d *resolveParallelNetworkDialer) DialParallelInterface(ctx context.Context
 	ctx = log.ContextWithOverrideLevel(ctx, log.LevelDebug)
 	metadata.Destination = destination
 	metadata.Domain = ""
-	var addresses []netip.Addr
-	var err error
-	if d.strategy == dns.DomainStrategyAsIS {
-		addresses, err = d.router.LookupDefault(ctx, destination.Fqdn)
-	} else {
-		addresses, err = d.router.Lookup(ctx, destination.Fqdn, d.strategy)
-	}
+	addresses, err := d.lookup(ctx, destination.Fqdn)
 	if err != nil {
 		return nil, err
 	}
@@ -142,13 +144,7 @@ func (d *resolveParallelNetworkDialer) ListenSerialInterfacePacket(ctx context.C
 	ctx = log.ContextWithOverrideLevel(ctx, log.LevelDebug)
 	metadata.Destination = destination
 	metadata.Domain = ""
-	var addresses []netip.Addr
-	var err error
-	if d.strategy == dns.DomainStrategyAsIS {
-		addresses, err = d.router.LookupDefault(ctx, destination.Fqdn)
-	} else {
-		addresses, err = d.router.Lookup(ctx, destination.Fqdn, d.strategy)
-	}
+	addresses, err := d.lookup(ctx, destination.Fqdn)
 	if err != nil {
 		return nil, err
 	}
@@ -159,6 +155,33 @@ func (d *resolveParallelNetworkDialer) ListenSerialInterfacePacket(ctx context.C
 	return bufio.NewNATPacketConn(bufio.NewPacketConn(conn), M.SocksaddrFrom(destinationAddress, destination.Port), destination), nil
 }
 
+func (d *resolveDialer) lookup(ctx context.Context, domain string) ([]netip.Addr, error) {
+	if d.negativeCache != nil {
+		if cachedErr, loaded := d.negativeCache.Load(domain); loaded {
+			return nil, E.Cause(cachedErr, "lookup ", domain, " (cached)")
+		}
+	}
+	if d.server != "" {
+		var metadata *adapter.InboundContext
+		ctx, metadata = adapter.ExtendContext(ctx)
+		metadata.DNSServer = d.server
+	}
+	var addresses []netip.Addr
+	var err error
+	if d.strategy == dns.DomainStrategyAsIS {
+		addresses, err = d.router.LookupDefault(ctx, domain)
+	} else {
+		addresses, err = d.router.Lookup(ctx, domain, d.strategy)
+	}
+	if err == nil && len(addresses) == 0 {
+		err = dns.RCodeNameError
+	}
+	if err != nil && d.negativeCache != nil && ctx.Err() == nil {
+		d.negativeCache.Store(domain, err)
+	}
+	return addresses, err
+}
+
 func (d *resolveDialer) Upstream() any {
 	return d.dialer
 }
@@ -0,0 +1,176 @@
+package dialer
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-dns"
+	"github.com/sagernet/sing/common/bufio"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+
+	mDNS "github.com/miekg/dns"
+)
+
+var _ N.Dialer = (*srvDialer)(nil)
+
+// srvDialer resolves the outbound server address in the `_service._proto.name`
+// form through SRV records, and tries the targets in the order of RFC 2782.
+// Other destinations are dialed as is, so names of proxied traffic are never
+// looked up as SRV records.
+// Records are cached by the DNS client according to their TTL, so changes
+// are picked up by new connections without affecting existing ones.
+type srvDialer struct {
+	dialer N.Dialer
+	router adapter.Router
+	server M.Socksaddr
+}
+
+func NewSRVDialer(router adapter.Router, dialer N.Dialer, server M.Socksaddr) N.Dialer {
+	if !isSRVName(server) {
+		return dialer
+	}
+	return &srvDialer{dialer, router, server}
+}
+
+func (d *srvDialer) isServer(destination M.Socksaddr) bool {
+	// server_port is ignored for SRV names, so only the name is compared
+	return destination.IsFqdn() && destination.Fqdn == d.server.Fqdn
+}
+
+func (d *srvDialer) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	if !d.isServer(destination) {
+		return d.dialer.DialContext(ctx, network, destination)
+	}
+	targets, err := d.lookup(ctx, destination)
+	if err != nil {
+		return nil, err
+	}
+	var connErrors []error
+	for _, target := range targets {
+		conn, err := d.dialer.DialContext(ctx, network, target)
+		if err != nil {
+			connErrors = append(connErrors, err)
+			continue
+		}
+		return conn, nil
+	}
+	return nil, E.Errors(connErrors...)
+}
+
+func (d *srvDialer) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
+	if !d.isServer(destination) {
+		return d.dialer.ListenPacket(ctx, destination)
+	}
+	targets, err := d.lookup(ctx, destination)
+	if err != nil {
+		return nil, err
+	}
+	var connErrors []error
+	for _, target := range targets {
+		conn, err := d.dialer.ListenPacket(ctx, target)
+		if err != nil {
+			connErrors = append(connErrors, err)
+			continue
+		}
+		return bufio.NewNATPacketConn(bufio.NewPacketConn(conn), target, destination), nil
+	}
+	return nil, E.Errors(connErrors...)
+}
+
+func (d *srvDialer) lookup(ctx context.Context, destination M.Socksaddr) ([]M.Socksaddr, error) {
+	ctx, metadata := adapter.ExtendContext(ctx)
+	ctx = log.ContextWithOverrideLevel(ctx, log.LevelDebug)
+	metadata.Destination = destination
+	metadata.Domain = ""
+	message := &mDNS.Msg{
+		MsgHdr: mDNS.MsgHdr{
+			RecursionDesired: true,
+		},
+		Question: []mDNS.Question{
+			{
+				Name:   mDNS.Fqdn(destination.Fqdn),
+				Qtype:  mDNS.TypeSRV,
+				Qclass: mDNS.ClassINET,
+			},
+		},
+	}
+	response, err := d.router.Exchange(ctx, message)
+	if err != nil {
+		return nil, E.Cause(err, "lookup SRV records for ", destination.Fqdn)
+	}
+	if response.Rcode != mDNS.RcodeSuccess {
+		return nil, E.Cause(dns.RCodeError(response.Rcode), "lookup SRV records for ", destination.Fqdn)
+	}
+	var records []*mDNS.SRV
+	for _, rr := range response.Answer {
+		record, isSRV := rr.(*mDNS.SRV)
+		// a single record with the target "." means the service is not available
+		if !isSRV || record.Target == "." {
+			continue
+		}
+		records = append(records, record)
+	}
+	if len(records) == 0 {
+		return nil, E.New("no SRV records found for ", destination.Fqdn)
+	}
+	sortSRVRecords(records)
+	targets := make([]M.Socksaddr, 0, len(records))
+	for _, record := range records {
+		targets = append(targets, M.ParseSocksaddrHostPort(strings.TrimSuffix(record.Target, "."), record.Port))
+	}
+	return targets, nil
+}
+
+func (d *srvDialer) Upstream() any {
+	return d.dialer
+}
+
+func isSRVName(destination M.Socksaddr) bool {
+	if !destination.IsFqdn() {
+		return false
+	}
+	labels := strings.SplitN(destination.Fqdn, ".", 3)
+	return len(labels) == 3 && strings.HasPrefix(labels[0], "_") && strings.HasPrefix(labels[1], "_")
+}
+
+// sortSRVRecords orders records by priority, and randomly by weight within
+// the same priority.
+func sortSRVRecords(records []*mDNS.SRV) {
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Priority < records[j].Priority
+	})
+	for start := 0; start < len(records); {
+		end := start + 1
+		for end < len(records) && records[end].Priority == records[start].Priority {
+			end++
+		}
+		shuffleSRVRecordsByWeight(records[start:end])
+		start = end
+	}
+}
+
+func shuffleSRVRecordsByWeight(records []*mDNS.SRV) {
+	var totalWeight int
+	for _, record := range records {
+		totalWeight += int(record.Weight)
+	}
+	for len(records) > 1 && totalWeight > 0 {
+		selected := rand.Intn(totalWeight)
+		for index, record := range records {
+			selected -= int(record.Weight)
+			if selected < 0 {
+				records[0], records[index] = records[index], records[0]
+				break
+			}
+		}
+		totalWeight -= int(records[0].Weight)
+		records = records[1:]
+	}
+}
@@ -0,0 +1,40 @@
+package dialer
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+
+	"github.com/stretchr/testify/require"
+)
+
+type recordDialer struct {
+	destinations []M.Socksaddr
+}
+
+func (d *recordDialer) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	d.destinations = append(d.destinations, destination)
+	return nil, net.ErrClosed
+}
+
+func (d *recordDialer) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
+	d.destinations = append(d.destinations, destination)
+	return nil, net.ErrClosed
+}
+
+func TestSRVDialerOnlyResolvesServer(t *testing.T) {
+	t.Parallel()
+	upstream := &recordDialer{}
+	require.Equal(t, N.Dialer(upstream), NewSRVDialer(nil, upstream, M.ParseSocksaddrHostPort("example.com", 443)))
+	srvDialer := NewSRVDialer(nil, upstream, M.ParseSocksaddrHostPort("_proxy._tcp.example.com", 0))
+	// the router is nil, so looking up anything other than the server would panic
+	destination := M.ParseSocksaddrHostPort("_xmpp._tcp.example.org", 5222)
+	_, err := srvDialer.DialContext(context.Background(), N.NetworkTCP, destination)
+	require.ErrorIs(t, err, net.ErrClosed)
+	_, err = srvDialer.ListenPacket(context.Background(), destination)
+	require.ErrorIs(t, err, net.ErrClosed)
+	require.Equal(t, []M.Socksaddr{destination, destination}, upstream.destinations)
+}
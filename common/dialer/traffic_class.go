@@ -0,0 +1,38 @@
+package dialer
+
+import (
+	"context"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing/common/control"
+)
+
+// TrafficClass returns the IP TOS or IPv6 traffic class built from the DSCP and ECN values.
+func TrafficClass(dscp *uint8, ecn *uint8) (int, bool) {
+	if dscp == nil && ecn == nil {
+		return 0, false
+	}
+	var class int
+	if dscp != nil {
+		class = int(*dscp) << 2
+	}
+	if ecn != nil {
+		class |= int(*ecn)
+	}
+	return class, true
+}
+
+// withTrafficClass appends a control function marking sockets with the traffic class
+// requested by the route of the connection in ctx, so that the handshake is marked too.
+// For proxy outbounds, the socket to the server is marked.
+func withTrafficClass(ctx context.Context, controlFn control.Func) control.Func {
+	metadata := adapter.ContextFrom(ctx)
+	if metadata == nil {
+		return controlFn
+	}
+	class, loaded := TrafficClass(metadata.DSCP, metadata.ECN)
+	if !loaded {
+		return controlFn
+	}
+	return control.Append(controlFn, setTrafficClass(class))
+}
@@ -0,0 +1,57 @@
+package dialer
+
+import (
+	"context"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common/control"
+	M "github.com/sagernet/sing/common/metadata"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func readTrafficClass(t *testing.T, conn syscall.Conn, level int, name int) int {
+	var (
+		value int
+		err   error
+	)
+	require.NoError(t, control.Conn(conn, func(fd uintptr) error {
+		value, err = unix.GetsockoptInt(int(fd), level, name)
+		return nil
+	}))
+	require.NoError(t, err)
+	return value
+}
+
+func TestTrafficClass(t *testing.T) {
+	t.Parallel()
+	dscp, ecn := uint8(46), uint8(1)
+	ctx, metadata := adapter.ExtendContext(context.Background())
+	metadata.DSCP = &dscp
+	metadata.ECN = &ecn
+	dialer, err := NewDefault(context.Background(), option.DialerOptions{})
+	require.NoError(t, err)
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	conn, err := dialer.DialContext(ctx, "tcp", M.SocksaddrFromNet(listener.Addr()))
+	require.NoError(t, err)
+	defer conn.Close()
+	// the kernel owns the ECN bits of TCP sockets
+	require.Equal(t, 46<<2, readTrafficClass(t, conn.(syscall.Conn), unix.IPPROTO_IP, unix.IP_TOS))
+
+	packetConn, err := dialer.ListenPacket(ctx, M.ParseSocksaddr("[::1]:53"))
+	require.NoError(t, err)
+	defer packetConn.Close()
+	require.Equal(t, 46<<2|1, readTrafficClass(t, packetConn.(syscall.Conn), unix.IPPROTO_IPV6, unix.IPV6_TCLASS))
+
+	packetConn, err = dialer.ListenPacket(context.Background(), M.ParseSocksaddr("[::1]:53"))
+	require.NoError(t, err)
+	defer packetConn.Close()
+	require.Equal(t, 0, readTrafficClass(t, packetConn.(syscall.Conn), unix.IPPROTO_IPV6, unix.IPV6_TCLASS))
+}
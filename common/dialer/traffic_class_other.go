@@ -0,0 +1,9 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris || windows)
+
+package dialer
+
+import "github.com/sagernet/sing/common/control"
+
+func setTrafficClass(class int) control.Func {
+	return nil
+}
@@ -0,0 +1,34 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+
+package dialer
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/sagernet/sing/common/control"
+
+	"golang.org/x/sys/unix"
+)
+
+func setTrafficClass(class int) control.Func {
+	return func(network, address string, conn syscall.RawConn) error {
+		return control.Raw(conn, func(fd uintptr) error {
+			switch network {
+			case "tcp4", "udp4":
+				err := unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_TOS, class)
+				if err != nil {
+					return os.NewSyscallError("SETSOCKOPT IP_TOS", err)
+				}
+			default:
+				err := unix.SetsockoptInt(int(fd), unix.IPPROTO_IPV6, unix.IPV6_TCLASS, class)
+				if err != nil {
+					return os.NewSyscallError("SETSOCKOPT IPV6_TCLASS", err)
+				}
+				// IPv4 traffic of dual-stack sockets, not supported on every system
+				_ = unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_TOS, class)
+			}
+			return nil
+		})
+	}
+}
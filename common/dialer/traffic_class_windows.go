@@ -0,0 +1,26 @@
+package dialer
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/sagernet/sing/common/control"
+
+	"golang.org/x/sys/windows"
+)
+
+// IPv6 traffic class is not settable on Windows.
+func setTrafficClass(class int) control.Func {
+	return func(network, address string, conn syscall.RawConn) error {
+		if network != "tcp4" && network != "udp4" {
+			return nil
+		}
+		return control.Raw(conn, func(fd uintptr) error {
+			err := windows.SetsockoptInt(windows.Handle(fd), windows.IPPROTO_IP, windows.IP_TOS, class)
+			if err != nil {
+				return os.NewSyscallError("SETSOCKOPT IP_TOS", err)
+			}
+			return nil
+		})
+	}
+}
@@ -0,0 +1,333 @@
+package forwarded
+
+import (
+	"bytes"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+// maxHeadSize is the maximum size of buffered request heads. Larger requests
+// are sent unchanged in append mode, and so is the rest of the connection.
+const maxHeadSize = 64 * 1024
+
+const (
+	stateDetect = iota
+	stateHead
+	stateBody
+	stateChunkSize
+	stateChunkData
+	stateTrailer
+	statePassthrough
+)
+
+// Conn rewrites forwarding headers of plaintext HTTP/1 requests written to the
+// connection, including pipelined and keep-alive requests. The connection is
+// passed through unchanged if the first write is not an HTTP request, and
+// after a CONNECT or Upgrade request.
+//
+// Once a request is seen, requests that can not be parsed are passed through
+// unchanged in append mode, and close the connection in replace and strip
+// modes, so that headers of the client never reach the server.
+type Conn struct {
+	net.Conn
+	rewriter  *Rewriter
+	client    netip.Addr
+	state     int
+	requested bool
+	head      []byte
+	remaining int64
+}
+
+func NewConn(conn net.Conn, rewriter *Rewriter, client netip.Addr) *Conn {
+	return &Conn{Conn: conn, rewriter: rewriter, client: client}
+}
+
+func (c *Conn) Write(b []byte) (int, error) {
+	if c.state == statePassthrough {
+		return c.Conn.Write(b)
+	}
+	output, err := c.rewrite(b)
+	if err != nil {
+		c.Conn.Close()
+		return 0, E.Cause(err, "rewrite forwarded header")
+	}
+	if len(output) > 0 {
+		_, err = c.Conn.Write(output)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+func (c *Conn) rewrite(b []byte) ([]byte, error) {
+	output := make([]byte, 0, len(b)+len(c.head)+64)
+	data := b
+	for len(data) > 0 && c.state != statePassthrough {
+		switch c.state {
+		case stateDetect:
+			c.head = append(c.head, data...)
+			data = nil
+			start, complete, isRequest := detectRequest(c.head)
+			if !isRequest {
+				if c.requested {
+					err := c.fail(E.New("invalid request line"))
+					if err != nil {
+						return nil, err
+					}
+				}
+				c.state = statePassthrough
+				data = c.head
+				c.head = nil
+				continue
+			}
+			if !complete {
+				if len(c.head) > maxHeadSize {
+					err := c.fail(E.New("request line too large"))
+					if err != nil {
+						return nil, err
+					}
+					data = c.head
+					c.head = nil
+				}
+				continue
+			}
+			// empty lines before the request line are sent unchanged
+			output = append(output, c.head[:start]...)
+			data = c.head[start:]
+			c.head = nil
+			c.requested = true
+			c.state = stateHead
+		case stateHead:
+			searchStart := common.Max(len(c.head)-3, 0)
+			c.head = append(c.head, data...)
+			index := bytes.Index(c.head[searchStart:], []byte("\r\n\r\n"))
+			if index == -1 || searchStart+index+4 > maxHeadSize {
+				data = nil
+				if index != -1 || len(c.head) > maxHeadSize {
+					err := c.fail(E.New("request head too large"))
+					if err != nil {
+						return nil, err
+					}
+					data = c.head
+					c.head = nil
+				}
+				continue
+			}
+			headEnd := searchStart + index + 4
+			data = c.head[headEnd:]
+			head := c.head[:headEnd]
+			c.head = nil
+			output = append(output, c.rewriter.RewriteHead(head, c.client)...)
+			state, remaining, err := bodyState(head)
+			if err != nil {
+				err = c.fail(err)
+				if err != nil {
+					return nil, err
+				}
+				continue
+			}
+			c.state, c.remaining = state, remaining
+		case stateBody:
+			n := int(common.Min(c.remaining, int64(len(data))))
+			output = append(output, data[:n]...)
+			data = data[n:]
+			c.remaining -= int64(n)
+			if c.remaining == 0 {
+				c.state = stateDetect
+			}
+		case stateChunkSize, stateTrailer:
+			index := bytes.IndexByte(data, '\n')
+			if index == -1 {
+				output = append(output, data...)
+				c.head = append(c.head, data...)
+				data = nil
+				if len(c.head) > maxHeadSize {
+					err := c.fail(E.New("chunk line too large"))
+					if err != nil {
+						return nil, err
+					}
+					c.head = nil
+				}
+				continue
+			}
+			output = append(output, data[:index+1]...)
+			line := strings.TrimSpace(string(append(c.head, data[:index]...)))
+			data = data[index+1:]
+			c.head = nil
+			if c.state == stateTrailer {
+				if line == "" {
+					c.state = stateDetect
+				}
+				continue
+			}
+			line, _, _ = strings.Cut(line, ";")
+			size, err := strconv.ParseInt(strings.TrimSpace(line), 16, 64)
+			if err != nil || size < 0 {
+				err = c.fail(E.New("invalid chunk size: ", line))
+				if err != nil {
+					return nil, err
+				}
+			} else if size == 0 {
+				c.state = stateTrailer
+			} else {
+				c.state = stateChunkData
+				// chunk data is followed by CRLF
+				c.remaining = size + 2
+			}
+		case stateChunkData:
+			n := int(common.Min(c.remaining, int64(len(data))))
+			output = append(output, data[:n]...)
+			data = data[n:]
+			c.remaining -= int64(n)
+			if c.remaining == 0 {
+				c.state = stateChunkSize
+			}
+		}
+	}
+	return append(output, data...), nil
+}
+
+// fail passes the rest of the connection through unchanged in append mode,
+// and returns the error to close the connection otherwise.
+func (c *Conn) fail(err error) error {
+	if c.rewriter.mode != ModeAppend {
+		return err
+	}
+	c.state = statePassthrough
+	return nil
+}
+
+func (c *Conn) Upstream() any {
+	return c.Conn
+}
+
+func (c *Conn) ReaderReplaceable() bool {
+	return true
+}
+
+func (c *Conn) WriterReplaceable() bool {
+	return c.state == statePassthrough
+}
+
+// detectRequest skips empty lines at the start of head and checks the request
+// line after them, complete is false if more data is required to tell.
+func detectRequest(head []byte) (start int, complete bool, isRequest bool) {
+	for start < len(head) && (head[start] == '\r' || head[start] == '\n') {
+		start++
+	}
+	line := head[start:]
+	methodEnd := bytes.IndexByte(line, ' ')
+	if methodEnd == -1 {
+		methodEnd = len(line)
+	} else if methodEnd == 0 {
+		return start, true, false
+	}
+	for _, char := range line[:methodEnd] {
+		if !isTokenChar(char) {
+			return start, true, false
+		}
+	}
+	lineEnd := bytes.IndexByte(line, '\n')
+	if lineEnd == -1 {
+		return start, false, true
+	}
+	return start, true, isRequestLine(string(bytes.TrimSuffix(line[:lineEnd], []byte("\r"))))
+}
+
+// isRequestLine checks for the method, request target and HTTP version of
+// RFC 7230 request lines.
+func isRequestLine(line string) bool {
+	_, rest, found := strings.Cut(line, " ")
+	if !found {
+		return false
+	}
+	index := strings.LastIndexByte(rest, ' ')
+	if index <= 0 {
+		return false
+	}
+	version := rest[index+1:]
+	return len(version) == 8 && strings.HasPrefix(version, "HTTP/") &&
+		isDigit(version[5]) && version[6] == '.' && isDigit(version[7])
+}
+
+func isTokenChar(char byte) bool {
+	return isDigit(char) || char >= 'a' && char <= 'z' || char >= 'A' && char <= 'Z' ||
+		strings.IndexByte("!#$%&'*+-.^_`|~", char) != -1
+}
+
+func isDigit(char byte) bool {
+	return char >= '0' && char <= '9'
+}
+
+// bodyState returns the state after the request head by its method and framing headers.
+// Requests with ambiguous framing are rejected, as the server may frame them differently
+// and take the rest of the body as a request with headers of the client.
+func bodyState(head []byte) (int, int64, error) {
+	if bytes.HasPrefix(head, []byte("CONNECT ")) {
+		return statePassthrough, 0, nil
+	}
+	var (
+		contentLength    int64 = -1
+		transferEncoding []string
+	)
+	for _, line := range bytes.Split(head, []byte("\n"))[1:] {
+		name, value, found := bytes.Cut(line, []byte(":"))
+		if !found {
+			continue
+		}
+		headerName := strings.TrimSpace(string(name))
+		headerValue := strings.TrimSpace(string(value))
+		isFraming := strings.EqualFold(headerName, "Transfer-Encoding") || strings.EqualFold(headerName, "Content-Length")
+		if isFraming && len(headerName) != len(name) {
+			return 0, 0, E.New("invalid whitespace in header name: ", headerName)
+		}
+		switch {
+		case strings.EqualFold(headerName, "Upgrade"):
+			return statePassthrough, 0, nil
+		case strings.EqualFold(headerName, "Transfer-Encoding"):
+			for _, coding := range strings.Split(headerValue, ",") {
+				transferEncoding = append(transferEncoding, strings.ToLower(strings.TrimSpace(coding)))
+			}
+		case strings.EqualFold(headerName, "Content-Length"):
+			if contentLength != -1 {
+				return 0, 0, E.New("duplicate content length")
+			}
+			length, err := parseContentLength(headerValue)
+			if err != nil {
+				return 0, 0, err
+			}
+			contentLength = length
+		}
+	}
+	if len(transferEncoding) > 0 {
+		if contentLength != -1 {
+			return 0, 0, E.New("content length with transfer encoding")
+		}
+		// chunked must be the final coding and be applied only once
+		if transferEncoding[len(transferEncoding)-1] != "chunked" || common.Contains(transferEncoding[:len(transferEncoding)-1], "chunked") {
+			return 0, 0, E.New("unsupported transfer encoding: ", strings.Join(transferEncoding, ", "))
+		}
+		return stateChunkSize, 0, nil
+	} else if contentLength > 0 {
+		return stateBody, contentLength, nil
+	}
+	return stateDetect, 0, nil
+}
+
+// parseContentLength accepts digits only, strconv also accepts a sign.
+func parseContentLength(value string) (int64, error) {
+	if value == "" || strings.IndexFunc(value, func(char rune) bool { return char < '0' || char > '9' }) != -1 {
+		return 0, E.New("invalid content length: ", value)
+	}
+	length, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, E.New("invalid content length: ", value)
+	}
+	return length, nil
+}
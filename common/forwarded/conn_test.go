@@ -0,0 +1,158 @@
+package forwarded
+
+import (
+	"bytes"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type recordConn struct {
+	net.Conn
+	buffer bytes.Buffer
+}
+
+func (c *recordConn) Write(b []byte) (int, error) {
+	return c.buffer.Write(b)
+}
+
+func writeAll(t *testing.T, mode string, headers []string, client netip.Addr, writes ...string) string {
+	rewriter, err := NewRewriter(mode, headers)
+	require.NoError(t, err)
+	record := &recordConn{}
+	conn := NewConn(record, rewriter, client)
+	for _, data := range writes {
+		n, err := conn.Write([]byte(data))
+		require.NoError(t, err)
+		require.Equal(t, len(data), n)
+	}
+	return record.buffer.String()
+}
+
+func TestRewriteHead(t *testing.T) {
+	t.Parallel()
+	client := netip.MustParseAddr("192.0.2.1")
+	request := "GET / HTTP/1.1\r\nHost: example.com\r\nX-Forwarded-For: 198.51.100.1\r\n\r\n"
+	require.Equal(t,
+		"GET / HTTP/1.1\r\nHost: example.com\r\nX-Forwarded-For: 198.51.100.1, 192.0.2.1\r\n\r\n",
+		writeAll(t, "", nil, client, request))
+	require.Equal(t,
+		"GET / HTTP/1.1\r\nHost: example.com\r\nX-Forwarded-For: 192.0.2.1\r\n\r\n",
+		writeAll(t, ModeReplace, nil, client, request))
+	require.Equal(t,
+		"GET / HTTP/1.1\r\nHost: example.com\r\n\r\n",
+		writeAll(t, ModeStrip, nil, client, request))
+	require.Equal(t,
+		"GET / HTTP/1.1\r\nHost: example.com\r\nX-Forwarded-For: 198.51.100.1\r\nForwarded: for=\"[2001:db8::1]\"\r\n\r\n",
+		writeAll(t, ModeAppend, []string{HeaderForwarded}, netip.MustParseAddr("2001:db8::1"), request))
+	_, err := NewRewriter("insert", nil)
+	require.Error(t, err)
+}
+
+func TestRewriteStream(t *testing.T) {
+	t.Parallel()
+	client := netip.MustParseAddr("192.0.2.1")
+	// split head, body with Content-Length, then a chunked request
+	output := writeAll(t, "", nil, client,
+		"POST /a HTTP/1.1\r\nHost: a\r\n",
+		"Content-Length: 4\r\n\r\nGET ",
+		"PUT /b HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n3\r\nGET\r\n",
+		"0\r\n\r\nGET /c HTTP/1.1\r\n\r\n",
+	)
+	require.Equal(t, "POST /a HTTP/1.1\r\nHost: a\r\nContent-Length: 4\r\nX-Forwarded-For: 192.0.2.1\r\n\r\nGET "+
+		"PUT /b HTTP/1.1\r\nTransfer-Encoding: chunked\r\nX-Forwarded-For: 192.0.2.1\r\n\r\n3\r\nGET\r\n"+
+		"0\r\n\r\nGET /c HTTP/1.1\r\nX-Forwarded-For: 192.0.2.1\r\n\r\n", output)
+	// not HTTP, and after an upgrade
+	require.Equal(t, "\x16\x03\x01GET / HTTP/1.1\r\n\r\n", writeAll(t, "", nil, client, "\x16\x03\x01", "GET / HTTP/1.1\r\n\r\n"))
+	require.Equal(t, "GET / HTTP/1.1\r\nUpgrade: websocket\r\nX-Forwarded-For: 192.0.2.1\r\n\r\nGET / HTTP/1.1\r\n\r\n",
+		writeAll(t, "", nil, client, "GET / HTTP/1.1\r\nUpgrade: websocket\r\n\r\n", "GET / HTTP/1.1\r\n\r\n"))
+}
+
+func TestDetectRequest(t *testing.T) {
+	t.Parallel()
+	client := netip.MustParseAddr("192.0.2.1")
+	// any token method, empty lines before the request line, and a request line split across writes
+	require.Equal(t, "PROPFIND / HTTP/1.1\r\nX-Forwarded-For: 192.0.2.1\r\n\r\n",
+		writeAll(t, "", nil, client, "PROPFIND / HTTP/1.1\r\n\r\n"))
+	require.Equal(t, "\r\nGET / HTTP/1.1\r\nX-Forwarded-For: 192.0.2.1\r\n\r\n",
+		writeAll(t, "", nil, client, "\r\n", "G", "ET / HT", "TP/1.1\r\n\r\n"))
+	// not a request line
+	require.Equal(t, "SSH-2.0-OpenSSH_9.6 Ubuntu\r\n", writeAll(t, ModeStrip, nil, client, "SSH-2.0-Open", "SSH_9.6 Ubuntu\r\n"))
+}
+
+type closeConn struct {
+	recordConn
+	closed bool
+}
+
+func (c *closeConn) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestFailClosed(t *testing.T) {
+	t.Parallel()
+	client := netip.MustParseAddr("192.0.2.1")
+	largeHead := "GET / HTTP/1.1\r\nX-Large: " + strings.Repeat("a", maxHeadSize) + "\r\n\r\n"
+	invalidRequests := [][]string{
+		{largeHead},
+		{"GET /" + strings.Repeat("a", maxHeadSize)},
+		{"POST / HTTP/1.1\r\nContent-Length: -1\r\nX-Forwarded-For: 198.51.100.1\r\n\r\n"},
+		{"POST / HTTP/1.1\r\nTransfer-Encoding: gzip\r\n\r\n"},
+		{"POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n", "x\r\n"},
+		{"POST / HTTP/1.1\r\nContent-Length: 4\r\nContent-Length: 4\r\n\r\n"},
+		{"POST / HTTP/1.1\r\nContent-Length: 4\r\nContent-Length: 0\r\n\r\n"},
+		{"POST / HTTP/1.1\r\nContent-Length: 4, 4\r\n\r\n"},
+		{"POST / HTTP/1.1\r\nContent-Length: +4\r\n\r\n"},
+		{"POST / HTTP/1.1\r\nContent-Length : 4\r\n\r\n"},
+		{"POST / HTTP/1.1\r\nContent-Length: 4\r\nTransfer-Encoding: chunked\r\n\r\n"},
+		{"POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\nContent-Length: 4\r\n\r\n"},
+		{"POST / HTTP/1.1\r\nTransfer-Encoding: xchunked\r\n\r\n"},
+		{"POST / HTTP/1.1\r\nTransfer-Encoding: chunked, gzip\r\n\r\n"},
+		{"POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\nTransfer-Encoding: chunked\r\n\r\n"},
+		{"GET / HTTP/1.1\r\n\r\n", "\x00GET / HTTP/1.1\r\nX-Forwarded-For: 198.51.100.1\r\n\r\n"},
+	}
+	for _, writes := range invalidRequests {
+		for _, mode := range []string{ModeReplace, ModeStrip} {
+			rewriter, err := NewRewriter(mode, nil)
+			require.NoError(t, err)
+			record := &closeConn{}
+			conn := NewConn(record, rewriter, client)
+			for _, data := range writes {
+				_, err = conn.Write([]byte(data))
+				if err != nil {
+					break
+				}
+			}
+			require.Error(t, err)
+			require.True(t, record.closed)
+			require.NotContains(t, record.buffer.String(), "198.51.100.1")
+		}
+	}
+	// sent unchanged in append mode
+	require.Equal(t, largeHead, writeAll(t, ModeAppend, nil, client, largeHead))
+}
+
+func TestBodyState(t *testing.T) {
+	t.Parallel()
+	for _, testCase := range []struct {
+		head      string
+		state     int
+		remaining int64
+	}{
+		{"GET / HTTP/1.1\r\n\r\n", stateDetect, 0},
+		{"POST / HTTP/1.1\r\nContent-Length: 0\r\n\r\n", stateDetect, 0},
+		{"POST / HTTP/1.1\r\ncontent-length: 12\r\n\r\n", stateBody, 12},
+		{"POST / HTTP/1.1\r\nTransfer-Encoding: Chunked\r\n\r\n", stateChunkSize, 0},
+		{"POST / HTTP/1.1\r\nTransfer-Encoding: gzip\r\nTransfer-Encoding: chunked\r\n\r\n", stateChunkSize, 0},
+		{"CONNECT a:443 HTTP/1.1\r\nContent-Length: 1\r\nContent-Length: 2\r\n\r\n", statePassthrough, 0},
+	} {
+		state, remaining, err := bodyState([]byte(testCase.head))
+		require.NoError(t, err, testCase.head)
+		require.Equal(t, testCase.state, state, testCase.head)
+		require.Equal(t, testCase.remaining, remaining, testCase.head)
+	}
+}
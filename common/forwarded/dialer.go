@@ -0,0 +1,48 @@
+package forwarded
+
+import (
+	"context"
+	"net"
+	"net/netip"
+
+	"github.com/sagernet/sing-box/adapter"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+var _ N.Dialer = (*Dialer)(nil)
+
+// Dialer rewrites forwarding headers of HTTP requests sent through TCP
+// connections with the inbound client address in the context, see WrapConn.
+type Dialer struct {
+	N.Dialer
+	rewriter *Rewriter
+}
+
+func NewDialer(dialer N.Dialer, rewriter *Rewriter) *Dialer {
+	return &Dialer{dialer, rewriter}
+}
+
+func (d *Dialer) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	conn, err := d.Dialer.DialContext(ctx, network, destination)
+	if err != nil {
+		return nil, err
+	}
+	return WrapConn(ctx, network, conn, d.rewriter), nil
+}
+
+// WrapConn wraps TCP connections with the client address of the inbound
+// connection in the context. Connections not originated by an inbound,
+// such as URL tests, are only wrapped in strip mode.
+func WrapConn(ctx context.Context, network string, conn net.Conn, rewriter *Rewriter) net.Conn {
+	if N.NetworkName(network) != N.NetworkTCP {
+		return conn
+	}
+	metadata := adapter.ContextFrom(ctx)
+	if metadata != nil && metadata.Source.IsIP() {
+		return NewConn(conn, rewriter, metadata.Source.Addr)
+	} else if rewriter.mode == ModeStrip {
+		return NewConn(conn, rewriter, netip.Addr{})
+	}
+	return conn
+}
@@ -0,0 +1,115 @@
+package forwarded
+
+import (
+	"bytes"
+	"net/netip"
+	"strings"
+
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+const (
+	ModeAppend  = "append"
+	ModeReplace = "replace"
+	ModeStrip   = "strip"
+)
+
+const (
+	HeaderXForwardedFor = "x-forwarded-for"
+	HeaderForwarded     = "forwarded"
+)
+
+// Rewriter rewrites forwarding headers of plaintext HTTP/1 requests.
+type Rewriter struct {
+	mode          string
+	xForwardedFor bool
+	forwarded     bool
+}
+
+func NewRewriter(mode string, headers []string) (*Rewriter, error) {
+	switch mode {
+	case "":
+		mode = ModeAppend
+	case ModeAppend, ModeReplace, ModeStrip:
+	default:
+		return nil, E.New("unknown forwarded header mode: ", mode)
+	}
+	rewriter := &Rewriter{mode: mode}
+	if len(headers) == 0 {
+		headers = []string{HeaderXForwardedFor}
+	}
+	for _, header := range headers {
+		switch strings.ToLower(header) {
+		case HeaderXForwardedFor:
+			rewriter.xForwardedFor = true
+		case HeaderForwarded:
+			rewriter.forwarded = true
+		default:
+			return nil, E.New("unknown forwarded header: ", header)
+		}
+	}
+	return rewriter, nil
+}
+
+// RewriteHead rewrites the request line and headers ending with an empty line,
+// the client address is added unless the mode is strip.
+func (r *Rewriter) RewriteHead(head []byte, client netip.Addr) []byte {
+	lines := bytes.SplitAfter(head, []byte("\n"))
+	output := make([]byte, 0, len(head)+64)
+	output = append(output, lines[0]...)
+	var (
+		xForwardedFor []string
+		forwarded     []string
+		skipFolded    bool
+	)
+	for _, line := range lines[1:] {
+		if len(bytes.TrimRight(line, "\r\n")) == 0 {
+			break
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			// obsolete line folding of the previous header
+			if !skipFolded {
+				output = append(output, line...)
+			}
+			continue
+		}
+		skipFolded = false
+		name, value, found := bytes.Cut(line, []byte(":"))
+		if !found {
+			output = append(output, line...)
+			continue
+		}
+		value = bytes.TrimSpace(value)
+		switch {
+		case r.xForwardedFor && strings.EqualFold(string(bytes.TrimSpace(name)), HeaderXForwardedFor):
+			xForwardedFor = append(xForwardedFor, string(value))
+			skipFolded = true
+		case r.forwarded && strings.EqualFold(string(bytes.TrimSpace(name)), HeaderForwarded):
+			forwarded = append(forwarded, string(value))
+			skipFolded = true
+		default:
+			output = append(output, line...)
+		}
+	}
+	if r.mode != ModeStrip && client.IsValid() {
+		client = client.Unmap()
+		if r.mode == ModeReplace {
+			xForwardedFor = nil
+			forwarded = nil
+		}
+		if r.xForwardedFor {
+			output = append(output, "X-Forwarded-For: "+strings.Join(append(xForwardedFor, client.String()), ", ")+"\r\n"...)
+		}
+		if r.forwarded {
+			output = append(output, "Forwarded: "+strings.Join(append(forwarded, forwardedFor(client)), ", ")+"\r\n"...)
+		}
+	}
+	return append(output, "\r\n"...)
+}
+
+func forwardedFor(client netip.Addr) string {
+	if client.Is6() {
+		return "for=\"[" + client.String() + "]\""
+	}
+	return "for=" + client.String()
+}
@@ -2,6 +2,7 @@ package geoip
 
 import (
 	"net/netip"
+	"os"
 
 	E "github.com/sagernet/sing/common/exceptions"
 
@@ -17,6 +18,24 @@ func Open(path string) (*Reader, []string, error) {
 	if err != nil {
 		return nil, nil, err
 	}
+	return newReader(database)
+}
+
+// Load is like Open, but reads the whole database into memory, so that the
+// file is not kept open and can be replaced while the reader is in use.
+func Load(path string) (*Reader, []string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	database, err := maxminddb.FromBytes(content)
+	if err != nil {
+		return nil, nil, err
+	}
+	return newReader(database)
+}
+
+func newReader(database *maxminddb.Reader) (*Reader, []string, error) {
 	if database.Metadata.DatabaseType != "sing-geoip" {
 		database.Close()
 		return nil, nil, E.New("incorrect database type, expected sing-geoip, got ", database.Metadata.DatabaseType)
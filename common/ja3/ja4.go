@@ -0,0 +1,121 @@
+package ja3
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+// JA4 returns the JA4 fingerprint of the ClientHello received over TCP,
+// see https://github.com/FoxIO-LLC/ja4/blob/main/technical_details/JA4.md
+func (j *ClientHello) JA4() string {
+	cipherSuites := removeGREASE(j.CipherSuites)
+	extensions := removeGREASE(j.Extensions)
+	var builder strings.Builder
+	builder.WriteByte('t')
+	builder.WriteString(ja4Version(j.Version, removeGREASE(j.Versions)))
+	if slices.Contains(extensions, sniExtensionType) {
+		builder.WriteByte('d')
+	} else {
+		builder.WriteByte('i')
+	}
+	builder.WriteString(ja4Count(len(cipherSuites)))
+	builder.WriteString(ja4Count(len(extensions)))
+	builder.WriteString(ja4ALPN(j.ALPNProtocols))
+	builder.WriteByte('_')
+	slices.Sort(cipherSuites)
+	builder.WriteString(ja4Hash(ja4HexList(cipherSuites)))
+	builder.WriteByte('_')
+	hashedExtensions := make([]uint16, 0, len(extensions))
+	for _, extension := range extensions {
+		if extension == sniExtensionType || extension == alpnExtensionType {
+			continue
+		}
+		hashedExtensions = append(hashedExtensions, extension)
+	}
+	slices.Sort(hashedExtensions)
+	extensionsString := ja4HexList(hashedExtensions)
+	if extensionsString != "" && len(j.SignatureAlgorithms) > 0 {
+		extensionsString += "_" + ja4HexList(j.SignatureAlgorithms)
+	}
+	builder.WriteString(ja4Hash(extensionsString))
+	return builder.String()
+}
+
+func removeGREASE(values []uint16) []uint16 {
+	result := make([]uint16, 0, len(values))
+	for _, value := range values {
+		if !IsGREASE(value) {
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
+func ja4Version(version uint16, versions []uint16) string {
+	for _, supportedVersion := range versions {
+		if supportedVersion > version {
+			version = supportedVersion
+		}
+	}
+	switch version {
+	case 0x0304:
+		return "13"
+	case 0x0303:
+		return "12"
+	case 0x0302:
+		return "11"
+	case 0x0301:
+		return "10"
+	case 0x0300:
+		return "s3"
+	default:
+		return "00"
+	}
+}
+
+func ja4Count(count int) string {
+	if count > 99 {
+		count = 99
+	}
+	if count < 10 {
+		return "0" + strconv.Itoa(count)
+	}
+	return strconv.Itoa(count)
+}
+
+func ja4ALPN(protocols []string) string {
+	if len(protocols) == 0 || protocols[0] == "" {
+		return "00"
+	}
+	protocol := protocols[0]
+	first, last := protocol[0], protocol[len(protocol)-1]
+	if isAlphanumeric(first) && isAlphanumeric(last) {
+		return string([]byte{first, last})
+	}
+	protocolHex := hex.EncodeToString([]byte(protocol))
+	return string([]byte{protocolHex[0], protocolHex[len(protocolHex)-1]})
+}
+
+func isAlphanumeric(char byte) bool {
+	return char >= '0' && char <= '9' || char >= 'a' && char <= 'z' || char >= 'A' && char <= 'Z'
+}
+
+func ja4HexList(values []uint16) string {
+	hexValues := make([]string, 0, len(values))
+	for _, value := range values {
+		hexValues = append(hexValues, hex.EncodeToString([]byte{byte(value >> 8), byte(value)}))
+	}
+	return strings.Join(hexValues, ",")
+}
+
+func ja4Hash(value string) string {
+	if value == "" {
+		return "000000000000"
+	}
+	hash := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(hash[:6])
+}
@@ -26,6 +26,7 @@ const (
 	ecpfExtensionHeaderLen                int    = 1
 	versionExtensionHeaderLen             int    = 1
 	signatureAlgorithmsExtensionHeaderLen int    = 2
+	alpnExtensionHeaderLen                int    = 2
 	contentType                           uint8  = 22
 	handshakeType                         uint8  = 1
 	sniExtensionType                      uint16 = 0
@@ -34,6 +35,7 @@ const (
 	ecpfExtensionType                     uint16 = 11
 	versionExtensionType                  uint16 = 43
 	signatureAlgorithmsExtensionType      uint16 = 13
+	alpnExtensionType                     uint16 = 16
 
 	// Versions
 	// The bitmask covers the versions SSL3.0 to TLS1.2
@@ -176,6 +178,7 @@ func (j *ClientHello) parseExtensions(exs []byte) error {
 	var ellipticCurvePF []uint8
 	var versions []uint16
 	var signatureAlgorithms []uint16
+	var alpnProtocols []string
 	for len(exs) > 0 {
 
 		// Check if we can decode the next fields
@@ -275,6 +278,23 @@ func (j *ClientHello) parseExtensions(exs []byte) error {
 			for i := 0; i < int(ssaLen); i += 2 {
 				signatureAlgorithms = append(signatureAlgorithms, binary.BigEndian.Uint16(sex[2:][i:]))
 			}
+		case alpnExtensionType:
+			if len(sex) < alpnExtensionHeaderLen {
+				return &ParseError{LengthErr, 21}
+			}
+			alpnLen := binary.BigEndian.Uint16(sex)
+			sex = sex[alpnExtensionHeaderLen:]
+			if len(sex) != int(alpnLen) {
+				return &ParseError{LengthErr, 22}
+			}
+			for len(sex) > 0 {
+				protocolLen := int(sex[0])
+				if len(sex) < 1+protocolLen {
+					return &ParseError{LengthErr, 23}
+				}
+				alpnProtocols = append(alpnProtocols, string(sex[1:1+protocolLen]))
+				sex = sex[1+protocolLen:]
+			}
 		}
 		exs = exs[4+exLen:]
 	}
@@ -284,6 +304,7 @@ func (j *ClientHello) parseExtensions(exs []byte) error {
 	j.EllipticCurvePF = ellipticCurvePF
 	j.Versions = versions
 	j.SignatureAlgorithms = signatureAlgorithms
+	j.ALPNProtocols = alpnProtocols
 	return nil
 }
 
@@ -299,49 +320,13 @@ func (j *ClientHello) marshalJA3() {
 	byteString = append(byteString, commaByte)
 
 	// Cipher Suites
-	if len(j.CipherSuites) != 0 {
-		for _, val := range j.CipherSuites {
-			if val&GreaseBitmask != 0x0A0A {
-				continue
-			}
-			byteString = strconv.AppendUint(byteString, uint64(val), 10)
-			byteString = append(byteString, dashByte)
-		}
-		// Replace last dash with a comma
-		byteString[len(byteString)-1] = commaByte
-	} else {
-		byteString = append(byteString, commaByte)
-	}
+	byteString = appendJA3Values(byteString, j.CipherSuites)
 
 	// Extensions
-	if len(j.Extensions) != 0 {
-		for _, val := range j.Extensions {
-			if val&GreaseBitmask != 0x0A0A {
-				continue
-			}
-			byteString = strconv.AppendUint(byteString, uint64(val), 10)
-			byteString = append(byteString, dashByte)
-		}
-		// Replace last dash with a comma
-		byteString[len(byteString)-1] = commaByte
-	} else {
-		byteString = append(byteString, commaByte)
-	}
+	byteString = appendJA3Values(byteString, j.Extensions)
 
 	// Elliptic curves
-	if len(j.EllipticCurves) != 0 {
-		for _, val := range j.EllipticCurves {
-			if val&GreaseBitmask != 0x0A0A {
-				continue
-			}
-			byteString = strconv.AppendUint(byteString, uint64(val), 10)
-			byteString = append(byteString, dashByte)
-		}
-		// Replace last dash with a comma
-		byteString[len(byteString)-1] = commaByte
-	} else {
-		byteString = append(byteString, commaByte)
-	}
+	byteString = appendJA3Values(byteString, j.EllipticCurves)
 
 	// ECPF
 	if len(j.EllipticCurvePF) != 0 {
@@ -355,3 +340,28 @@ func (j *ClientHello) marshalJA3() {
 
 	j.ja3ByteString = byteString
 }
+
+// appendJA3Values appends the dash separated values without GREASE, followed by a comma
+func appendJA3Values(byteString []byte, values []uint16) []byte {
+	var appended bool
+	for _, val := range values {
+		if IsGREASE(val) {
+			continue
+		}
+		byteString = strconv.AppendUint(byteString, uint64(val), 10)
+		byteString = append(byteString, dashByte)
+		appended = true
+	}
+	if appended {
+		// Replace last dash with a comma
+		byteString[len(byteString)-1] = commaByte
+	} else {
+		byteString = append(byteString, commaByte)
+	}
+	return byteString
+}
+
+// IsGREASE reports whether the value is reserved by RFC 8701
+func IsGREASE(value uint16) bool {
+	return value&GreaseBitmask == 0x0A0A && value>>8 == value&0xFF
+}
@@ -0,0 +1,258 @@
+package jsonschema
+
+import (
+	"context"
+	"encoding"
+	"go/token"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/sagernet/sing/common/json"
+	"github.com/sagernet/sing/common/json/badoption"
+)
+
+var (
+	providerType        = reflect.TypeOf((*Provider)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	rawMessageType      = reflect.TypeOf(json.RawMessage(nil))
+	badoptionPkgPath    = reflect.TypeOf(badoption.Duration(0)).PkgPath()
+)
+
+type generator struct {
+	ctx   context.Context
+	defs  map[string]any
+	names map[reflect.Type]string
+}
+
+// Generate returns the schema of value, with named structs and types
+// implementing Provider placed in $defs.
+func Generate(ctx context.Context, value any) Schema {
+	g := &generator{
+		ctx:   ctx,
+		defs:  make(map[string]any),
+		names: make(map[reflect.Type]string),
+	}
+	schema := Schema{"$schema": Draft}
+	for key, item := range g.describe(value) {
+		schema[key] = item
+	}
+	if len(g.defs) > 0 {
+		schema["$defs"] = g.defs
+	}
+	return schema
+}
+
+func (g *generator) describe(value any) Schema {
+	switch descriptor := value.(type) {
+	case nil:
+		return Schema{}
+	case Schema:
+		return descriptor
+	case Union:
+		schema := g.unionParts(descriptor)
+		schema["type"] = "object"
+		schema["unevaluatedProperties"] = false
+		return schema
+	case AnyOf:
+		var schemas []any
+		for _, item := range descriptor {
+			schemas = append(schemas, g.describe(item))
+		}
+		return Schema{"anyOf": schemas}
+	case Enum:
+		return Schema{"type": "string", "enum": []string(descriptor)}
+	default:
+		return g.typeSchema(reflect.TypeOf(value))
+	}
+}
+
+func (g *generator) typeSchema(t reflect.Type) Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if name, loaded := g.names[t]; loaded {
+		return ref(name)
+	}
+	if !token.IsExported(t.Name()) || t.PkgPath() == badoptionPkgPath || (t.Kind() != reflect.Struct && !isProvider(t)) || implementsText(t) {
+		return g.inlineSchema(t)
+	}
+	name := t.Name()
+	if _, loaded := g.defs[name]; loaded {
+		name = path.Base(t.PkgPath()) + "." + name
+	}
+	g.names[t] = name
+	// placeholder for recursive types
+	g.defs[name] = Schema{}
+	g.defs[name] = g.inlineSchema(t)
+	return ref(name)
+}
+
+func (g *generator) inlineSchema(t reflect.Type) Schema {
+	if isProvider(t) {
+		return g.describe(provide(g.ctx, t))
+	}
+	if t.PkgPath() == badoptionPkgPath {
+		if strings.HasPrefix(t.Name(), "Listable[") {
+			item := g.typeSchema(t.Elem())
+			return Schema{"anyOf": []any{item, Schema{"type": "array", "items": item}}}
+		}
+		switch t.Name() {
+		case "Duration", "Addr", "Prefix", "Prefixable", "Regexp":
+			return Schema{"type": "string"}
+		}
+	}
+	if t == rawMessageType {
+		return Schema{}
+	}
+	if implementsText(t) {
+		return Schema{"type": "string"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return Schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return Schema{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return Schema{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return Schema{"type": "number"}
+	case reflect.String:
+		return Schema{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return Schema{"type": "string"}
+		}
+		return Schema{"type": "array", "items": g.typeSchema(t.Elem())}
+	case reflect.Map:
+		return Schema{"type": "object", "additionalProperties": g.typeSchema(t.Elem())}
+	case reflect.Struct:
+		return Schema{"type": "object", "properties": g.properties(t), "additionalProperties": false}
+	default:
+		return Schema{}
+	}
+}
+
+// properties returns the fields of a struct as encoded by encoding/json,
+// fields of embedded structs are promoted unless shadowed.
+func (g *generator) properties(t reflect.Type) Schema {
+	properties := make(Schema)
+	var embedded []reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			fieldType := field.Type
+			if fieldType.Kind() == reflect.Pointer {
+				fieldType = fieldType.Elem()
+			}
+			if fieldType.Kind() == reflect.Struct {
+				embedded = append(embedded, fieldType)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = g.typeSchema(field.Type)
+	}
+	for _, embeddedType := range embedded {
+		for name, schema := range g.properties(embeddedType) {
+			if _, loaded := properties[name]; !loaded {
+				properties[name] = schema
+			}
+		}
+	}
+	return properties
+}
+
+// objectParts returns the properties and conditions of an object without
+// closing it, so that it can be combined with other objects.
+func (g *generator) objectParts(value any) Schema {
+	t := reflect.TypeOf(value)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if isProvider(t) {
+		descriptor := provide(g.ctx, t)
+		if union, isUnion := descriptor.(Union); isUnion {
+			return g.unionParts(union)
+		}
+		return Schema{"allOf": []any{g.describe(descriptor)}}
+	}
+	if t.Kind() == reflect.Struct {
+		return Schema{"properties": g.properties(t)}
+	}
+	return Schema{"allOf": []any{g.typeSchema(t)}}
+}
+
+func (g *generator) unionParts(union Union) Schema {
+	schema := Schema{"properties": Schema{}}
+	if union.Common != nil {
+		merge(schema, g.objectParts(union.Common))
+	}
+	values := make([]string, 0, len(union.Variants))
+	for value := range union.Variants {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+	schema["properties"].(Schema)[union.Field] = Schema{"type": "string", "enum": values}
+	for _, value := range values {
+		variant := union.Variants[value]
+		if variant == nil {
+			continue
+		}
+		condition := Schema{"properties": Schema{union.Field: Schema{"const": value}}}
+		if value != union.Default {
+			condition["required"] = []string{union.Field}
+		}
+		merge(schema, Schema{"allOf": []any{Schema{"if": condition, "then": g.objectParts(variant)}}})
+	}
+	if union.Default == "" {
+		merge(schema, Schema{"required": []string{union.Field}})
+	}
+	return schema
+}
+
+func merge(schema Schema, parts Schema) {
+	if properties, loaded := parts["properties"].(Schema); loaded {
+		if schema["properties"] == nil {
+			schema["properties"] = Schema{}
+		}
+		for name, property := range properties {
+			schema["properties"].(Schema)[name] = property
+		}
+	}
+	if allOf, loaded := parts["allOf"].([]any); loaded {
+		existing, _ := schema["allOf"].([]any)
+		schema["allOf"] = append(existing, allOf...)
+	}
+	if required, loaded := parts["required"].([]string); loaded {
+		existing, _ := schema["required"].([]string)
+		schema["required"] = append(existing, required...)
+	}
+}
+
+func isProvider(t reflect.Type) bool {
+	return reflect.PointerTo(t).Implements(providerType)
+}
+
+func implementsText(t reflect.Type) bool {
+	return reflect.PointerTo(t).Implements(textUnmarshalerType)
+}
+
+func provide(ctx context.Context, t reflect.Type) any {
+	return reflect.New(t).Interface().(Provider).JSONSchema(ctx)
+}
+
+func ref(name string) Schema {
+	return Schema{"$ref": "#/$defs/" + name}
+}
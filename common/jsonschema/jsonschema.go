@@ -0,0 +1,49 @@
+package jsonschema
+
+import (
+	"context"
+	"sort"
+)
+
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+// Provider is implemented by option types whose JSON form can not be derived
+// from their Go structure, such as objects with a type discriminator or values
+// accepting several JSON types.
+type Provider interface {
+	JSONSchema(ctx context.Context) any
+}
+
+// Schema is a literal JSON schema.
+type Schema map[string]any
+
+// Union describes an object whose remaining fields are selected by the value
+// of the discriminator field.
+type Union struct {
+	Field string
+	// Default is the variant used when the field is absent, the field is
+	// required if empty.
+	Default string
+	// Common is the value (or a pointer to it) holding the fields shared by
+	// all variants, may be nil.
+	Common any
+	// Variants maps each field value to its options, nil for no fields.
+	Variants map[string]any
+}
+
+// AnyOf describes a value matching any of the elements, which are sample
+// values or descriptors.
+type AnyOf []any
+
+// Enum describes a string with the given values.
+type Enum []string
+
+// EnumOf returns the sorted keys of values as an Enum.
+func EnumOf[T any](values map[string]T) Enum {
+	enum := make(Enum, 0, len(values))
+	for value := range values {
+		enum = append(enum, value)
+	}
+	sort.Strings(enum)
+	return enum
+}
@@ -28,8 +28,10 @@ type Listener struct {
 	disablePacketOutput      bool
 	setSystemProxy           bool
 	systemProxySOCKS         bool
+	acceptProxyProtocol      bool
 
 	tcpListener          net.Listener
+	tcpListeners         []net.Listener
 	systemProxy          settings.SystemProxy
 	udpConn              *net.UDPConn
 	udpAddr              M.Socksaddr
@@ -50,6 +52,7 @@ type Options struct {
 	DisablePacketOutput      bool
 	SetSystemProxy           bool
 	SystemProxySOCKS         bool
+	AcceptProxyProtocol      bool
 }
 
 func New(
@@ -67,6 +70,7 @@ func New(
 		disablePacketOutput:      options.DisablePacketOutput,
 		setSystemProxy:           options.SetSystemProxy,
 		systemProxySOCKS:         options.SystemProxySOCKS,
+		acceptProxyProtocol:      options.AcceptProxyProtocol,
 	}
 }
 
@@ -76,7 +80,9 @@ func (l *Listener) Start() error {
 		if err != nil {
 			return err
 		}
-		go l.loopTCPIn()
+		for _, tcpListener := range l.tcpListeners {
+			go l.loopTCPIn(tcpListener)
+		}
 	}
 	if common.Contains(l.network, N.NetworkUDP) {
 		_, err := l.ListenUDP()
@@ -128,6 +134,12 @@ func (l *Listener) TCPListener() net.Listener {
 	return l.tcpListener
 }
 
+// TCPListeners returns the underlying listeners, one per socket when
+// reuse_port_listeners is set.
+func (l *Listener) TCPListeners() []net.Listener {
+	return l.tcpListeners
+}
+
 func (l *Listener) UDPConn() *net.UDPConn {
 	return l.udpConn
 }
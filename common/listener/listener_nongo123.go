@@ -9,7 +9,9 @@ import (
 	"github.com/sagernet/sing/common/control"
 )
 
-func setKeepAliveConfig(listener *net.ListenConfig, idle time.Duration, interval time.Duration) {
+const go123Available = false
+
+func setKeepAliveConfig(listener *net.ListenConfig, idle time.Duration, interval time.Duration, _ int) {
 	listener.KeepAlive = idle
 	listener.Control = control.Append(listener.Control, control.SetKeepAlivePeriod(idle, interval))
 }
@@ -0,0 +1,17 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris)
+
+package listener
+
+import (
+	"net"
+
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+func setReusePort(listenConfig *net.ListenConfig) error {
+	return E.New("reuse port is not supported on this platform")
+}
+
+func setBacklog(listener net.Listener, backlog int) error {
+	return E.New("custom TCP backlog is not supported on this platform")
+}
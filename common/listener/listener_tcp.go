@@ -1,11 +1,13 @@
 package listener
 
 import (
+	"context"
 	"net"
 	"net/netip"
 	"time"
 
 	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/proxyproto"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/log"
 	E "github.com/sagernet/sing/common/exceptions"
@@ -16,9 +18,7 @@ import (
 )
 
 func (l *Listener) ListenTCP() (net.Listener, error) {
-	var err error
 	bindAddr := M.SocksaddrFrom(l.listenOptions.Listen.Build(netip.AddrFrom4([4]byte{127, 0, 0, 1})), l.listenOptions.ListenPort)
-	var tcpListener net.Listener
 	var listenConfig net.ListenConfig
 	if l.listenOptions.TCPKeepAlive >= 0 {
 		keepIdle := time.Duration(l.listenOptions.TCPKeepAlive)
@@ -29,7 +29,10 @@ func (l *Listener) ListenTCP() (net.Listener, error) {
 		if keepInterval == 0 {
 			keepInterval = C.TCPKeepAliveInterval
 		}
-		setKeepAliveConfig(&listenConfig, keepIdle, keepInterval)
+		if l.listenOptions.TCPKeepAliveCount > 0 && !go123Available {
+			return nil, E.New("TCP keep alive count requires go1.23, please recompile your binary.")
+		}
+		setKeepAliveConfig(&listenConfig, keepIdle, keepInterval, l.listenOptions.TCPKeepAliveCount)
 	}
 	if l.listenOptions.TCPMultiPath {
 		if !go121Available {
@@ -37,6 +40,56 @@ func (l *Listener) ListenTCP() (net.Listener, error) {
 		}
 		setMultiPathTCP(&listenConfig)
 	}
+	listenerCount := 1
+	if l.listenOptions.ReusePort {
+		err := setReusePort(&listenConfig)
+		if err != nil {
+			return nil, err
+		}
+		if l.listenOptions.ReusePortListeners > 1 {
+			listenerCount = l.listenOptions.ReusePortListeners
+		}
+	} else if l.listenOptions.ReusePortListeners > 1 {
+		return nil, E.New("reuse_port_listeners requires reuse_port")
+	}
+	if l.listenOptions.TCPBacklog < 0 {
+		return nil, E.New("invalid TCP backlog: ", l.listenOptions.TCPBacklog)
+	}
+	//nolint:staticcheck
+	if l.listenOptions.ProxyProtocol || l.listenOptions.ProxyProtocolAcceptNoHeader {
+		return nil, E.New("Proxy Protocol is deprecated and removed in sing-box 1.6.0")
+	}
+	tcpListeners := make([]net.Listener, 0, listenerCount)
+	for i := 0; i < listenerCount; i++ {
+		tcpListener, err := l.listenTCP(listenConfig, bindAddr)
+		if err != nil {
+			for _, it := range tcpListeners {
+				it.Close()
+			}
+			return nil, err
+		}
+		if bindAddr.Port == 0 {
+			// bind the other listeners to the port chosen by the system
+			bindAddr.Port = M.SocksaddrFromNet(tcpListener.Addr()).Port
+		}
+		tcpListeners = append(tcpListeners, tcpListener)
+	}
+	if listenerCount > 1 {
+		l.logger.Info("tcp server started at ", tcpListeners[0].Addr(), " with ", listenerCount, " listeners")
+		l.tcpListener = newMultiListener(tcpListeners)
+	} else {
+		l.logger.Info("tcp server started at ", tcpListeners[0].Addr())
+		l.tcpListener = tcpListeners[0]
+	}
+	l.tcpListeners = tcpListeners
+	return l.tcpListener, nil
+}
+
+func (l *Listener) listenTCP(listenConfig net.ListenConfig, bindAddr M.Socksaddr) (net.Listener, error) {
+	var (
+		tcpListener net.Listener
+		err         error
+	)
 	if l.listenOptions.TCPFastOpen {
 		var tfoConfig tfo.ListenConfig
 		tfoConfig.ListenConfig = listenConfig
@@ -44,19 +97,23 @@ func (l *Listener) ListenTCP() (net.Listener, error) {
 	} else {
 		tcpListener, err = listenConfig.Listen(l.ctx, M.NetworkFromNetAddr(N.NetworkTCP, bindAddr.Addr), bindAddr.String())
 	}
-	if err == nil {
-		l.logger.Info("tcp server started at ", tcpListener.Addr())
+	if err != nil {
+		return nil, err
 	}
-	//nolint:staticcheck
-	if l.listenOptions.ProxyProtocol || l.listenOptions.ProxyProtocolAcceptNoHeader {
-		return nil, E.New("Proxy Protocol is deprecated and removed in sing-box 1.6.0")
+	if l.listenOptions.TCPBacklog > 0 {
+		err = setBacklog(tcpListener, l.listenOptions.TCPBacklog)
+		if err != nil {
+			tcpListener.Close()
+			return nil, E.Cause(err, "set TCP backlog")
+		}
+	}
+	if l.acceptProxyProtocol {
+		tcpListener = proxyproto.NewListener(tcpListener)
 	}
-	l.tcpListener = tcpListener
-	return tcpListener, err
+	return tcpListener, nil
 }
 
-func (l *Listener) loopTCPIn() {
-	tcpListener := l.tcpListener
+func (l *Listener) loopTCPIn(tcpListener net.Listener) {
 	var metadata adapter.InboundContext
 	for {
 		conn, err := tcpListener.Accept()
@@ -69,7 +126,7 @@ func (l *Listener) loopTCPIn() {
 			if l.shutdown.Load() && E.IsClosed(err) {
 				return
 			}
-			l.tcpListener.Close()
+			tcpListener.Close()
 			l.logger.Error("tcp listener closed: ", err)
 			continue
 		}
@@ -77,10 +134,32 @@ func (l *Listener) loopTCPIn() {
 		metadata.InboundDetour = l.listenOptions.Detour
 		//nolint:staticcheck
 		metadata.InboundOptions = l.listenOptions.InboundOptions
+		ctx := log.ContextWithNewID(l.ctx)
+		if proxyConn, isProxyConn := conn.(*proxyproto.Conn); isProxyConn {
+			go l.newProxyProtocolConnection(ctx, proxyConn, metadata)
+			continue
+		}
 		metadata.Source = M.SocksaddrFromNet(conn.RemoteAddr()).Unwrap()
 		metadata.OriginDestination = M.SocksaddrFromNet(conn.LocalAddr()).Unwrap()
-		ctx := log.ContextWithNewID(l.ctx)
 		l.logger.InfoContext(ctx, "inbound connection from ", metadata.Source)
 		go l.connHandler.NewConnectionEx(ctx, conn, metadata, nil)
 	}
 }
+
+func (l *Listener) newProxyProtocolConnection(ctx context.Context, conn *proxyproto.Conn, metadata adapter.InboundContext) {
+	err := conn.Handshake()
+	if err != nil {
+		conn.Close()
+		l.logger.ErrorContext(ctx, E.Cause(err, "process connection from ", conn.Conn.RemoteAddr()))
+		return
+	}
+	metadata.Source = M.SocksaddrFromNet(conn.RemoteAddr()).Unwrap()
+	metadata.OriginDestination = M.SocksaddrFromNet(conn.LocalAddr()).Unwrap()
+	proxySource := M.SocksaddrFromNet(conn.Conn.RemoteAddr()).Unwrap()
+	if proxySource != metadata.Source {
+		l.logger.InfoContext(ctx, "inbound connection from ", metadata.Source, " via ", proxySource)
+	} else {
+		l.logger.InfoContext(ctx, "inbound connection from ", metadata.Source)
+	}
+	l.connHandler.NewConnectionEx(ctx, conn, metadata, nil)
+}
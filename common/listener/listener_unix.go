@@ -0,0 +1,29 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+
+package listener
+
+import (
+	"net"
+	"syscall"
+
+	"github.com/sagernet/sing/common/control"
+
+	"golang.org/x/sys/unix"
+)
+
+func setReusePort(listenConfig *net.ListenConfig) error {
+	listenConfig.Control = control.Append(listenConfig.Control, control.ReuseAddr())
+	return nil
+}
+
+// setBacklog calls listen(2) again on the bound socket, which updates the
+// backlog of the accept queue chosen by the Go runtime.
+func setBacklog(listener net.Listener, backlog int) error {
+	syscallConn, isSyscallConn := listener.(syscall.Conn)
+	if !isSyscallConn {
+		return syscall.EINVAL
+	}
+	return control.Conn(syscallConn, func(fd uintptr) error {
+		return unix.Listen(int(fd), backlog)
+	})
+}
@@ -0,0 +1,243 @@
+package packetfragment
+
+import (
+	"encoding/binary"
+	"net"
+	"net/netip"
+	"sync/atomic"
+	"time"
+
+	"github.com/sagernet/sing-vmess/packetaddr"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/buf"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+// MagicAddress is the destination requested by clients to open a packet addr
+// connection with fragmentation, the port of the destination is the maximum
+// size of packets written to the tunnel.
+const MagicAddress = "sp.fragment.sing-box.arpa"
+
+const (
+	MinSize = 64
+
+	headerLen       = 4
+	maxFragments    = 255
+	maxPending      = 64
+	reassemblyLimit = 10 * time.Second
+)
+
+type packetKey struct {
+	destination M.Socksaddr
+	id          uint16
+}
+
+type pendingPacket struct {
+	fragments [][]byte
+	received  int
+	created   time.Time
+}
+
+// Conn splits packets larger than size into fragments, and reassembles the
+// fragments read from the peer. It runs over a packet addr connection, whose
+// address header counts towards size.
+//
+// Each packet is prefixed with a header of the packet ID (uint16), the index
+// of the fragment and the fragment count. A header with a fragment count of
+// zero is the acknowledgement sent by the server first, servers without
+// fragmentation support close the connection instead, since they fail to dial
+// MagicAddress.
+type Conn struct {
+	N.NetPacketConn
+	size         int
+	id           atomic.Uint32
+	acknowledged bool
+	pending      map[packetKey]*pendingPacket
+	order        []packetKey
+}
+
+func NewClientConn(conn N.NetPacketConn, size int) *Conn {
+	return &Conn{
+		NetPacketConn: conn,
+		size:          size,
+		pending:       make(map[packetKey]*pendingPacket),
+	}
+}
+
+func NewServerConn(conn N.NetPacketConn, size int) (*Conn, error) {
+	buffer := buf.NewSize(N.CalculateFrontHeadroom(conn) + headerLen + N.CalculateRearHeadroom(conn))
+	buffer.Resize(N.CalculateFrontHeadroom(conn), 0)
+	writeHeader(buffer.Extend(headerLen), 0, 0, 0)
+	err := conn.WritePacket(buffer, M.SocksaddrFrom(netip.IPv4Unspecified(), 0))
+	if err != nil {
+		return nil, E.Cause(err, "write packet fragment acknowledgement")
+	}
+	return &Conn{
+		NetPacketConn: conn,
+		size:          size,
+		acknowledged:  true,
+		pending:       make(map[packetKey]*pendingPacket),
+	}, nil
+}
+
+func (c *Conn) ReadPacket(buffer *buf.Buffer) (destination M.Socksaddr, err error) {
+	start := buffer.Start()
+	for {
+		buffer.Resize(start, 0)
+		destination, err = c.NetPacketConn.ReadPacket(buffer)
+		if err != nil {
+			if !c.acknowledged {
+				err = E.Cause(err, "packet fragment: read acknowledgement, the server may not support packet fragmentation")
+			}
+			return
+		}
+		if buffer.Len() < headerLen {
+			continue
+		}
+		header := buffer.To(headerLen)
+		id := binary.BigEndian.Uint16(header)
+		index, count := int(header[2]), int(header[3])
+		buffer.Advance(headerLen)
+		if count == 0 {
+			c.acknowledged = true
+			continue
+		}
+		if !c.acknowledged {
+			err = E.New("packet fragment: missing acknowledgement from server")
+			return
+		}
+		if count == 1 && index == 0 {
+			return
+		}
+		if index >= count {
+			continue
+		}
+		packet := c.reassemble(packetKey{destination, id}, index, count, buffer.Bytes())
+		if packet == nil {
+			continue
+		}
+		buffer.Resize(start, 0)
+		if len(packet) > buffer.FreeLen() {
+			continue
+		}
+		common.Must1(buffer.Write(packet))
+		return
+	}
+}
+
+func (c *Conn) reassemble(key packetKey, index int, count int, fragment []byte) []byte {
+	now := time.Now()
+	for len(c.order) > 0 {
+		oldest, loaded := c.pending[c.order[0]]
+		if loaded && len(c.pending) < maxPending && now.Sub(oldest.created) < reassemblyLimit {
+			break
+		}
+		delete(c.pending, c.order[0])
+		c.order = c.order[1:]
+	}
+	packet, loaded := c.pending[key]
+	if !loaded {
+		packet = &pendingPacket{
+			fragments: make([][]byte, count),
+			created:   now,
+		}
+		c.pending[key] = packet
+		c.order = append(c.order, key)
+	} else if len(packet.fragments) != count {
+		return nil
+	}
+	if packet.fragments[index] != nil {
+		return nil
+	}
+	packet.fragments[index] = append([]byte(nil), fragment...)
+	packet.received++
+	if packet.received < count {
+		return nil
+	}
+	delete(c.pending, key)
+	var content []byte
+	for _, data := range packet.fragments {
+		content = append(content, data...)
+	}
+	return content
+}
+
+func (c *Conn) WritePacket(buffer *buf.Buffer, destination M.Socksaddr) error {
+	id := uint16(c.id.Add(1))
+	payloadSize := c.size - headerLen - packetaddr.AddressSerializer.AddrPortLen(destination)
+	if payloadSize <= 0 {
+		buffer.Release()
+		return E.New("packet fragment size too small for destination ", destination)
+	}
+	if buffer.Len() <= payloadSize && buffer.Start() >= headerLen {
+		writeHeader(buffer.ExtendHeader(headerLen), id, 0, 1)
+		return c.NetPacketConn.WritePacket(buffer, destination)
+	}
+	defer buffer.Release()
+	count := (buffer.Len() + payloadSize - 1) / payloadSize
+	if count > maxFragments {
+		return E.New("packet too large: ", buffer.Len())
+	}
+	frontHeadroom := N.CalculateFrontHeadroom(c.NetPacketConn)
+	rearHeadroom := N.CalculateRearHeadroom(c.NetPacketConn)
+	content := buffer.Bytes()
+	for index := 0; index < count; index++ {
+		fragmentContent := content[index*payloadSize:]
+		if len(fragmentContent) > payloadSize {
+			fragmentContent = fragmentContent[:payloadSize]
+		}
+		fragment := buf.NewSize(frontHeadroom + headerLen + len(fragmentContent) + rearHeadroom)
+		fragment.Resize(frontHeadroom, 0)
+		writeHeader(fragment.Extend(headerLen), id, index, count)
+		common.Must1(fragment.Write(fragmentContent))
+		err := c.NetPacketConn.WritePacket(fragment, destination)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeHeader(header []byte, id uint16, index int, count int) {
+	binary.BigEndian.PutUint16(header, id)
+	header[2] = byte(index)
+	header[3] = byte(count)
+}
+
+func (c *Conn) ReadFrom(p []byte) (n int, addr net.Addr, err error) {
+	buffer := buf.NewPacket()
+	defer buffer.Release()
+	destination, err := c.ReadPacket(buffer)
+	if err != nil {
+		return
+	}
+	n = copy(p, buffer.Bytes())
+	if destination.IsFqdn() {
+		addr = destination
+	} else {
+		addr = destination.UDPAddr()
+	}
+	return
+}
+
+func (c *Conn) WriteTo(p []byte, addr net.Addr) (n int, err error) {
+	frontHeadroom := N.CalculateFrontHeadroom(c)
+	buffer := buf.NewSize(frontHeadroom + len(p) + N.CalculateRearHeadroom(c))
+	buffer.Resize(frontHeadroom, 0)
+	common.Must1(buffer.Write(p))
+	err = c.WritePacket(buffer, M.SocksaddrFromNet(addr))
+	if err != nil {
+		return
+	}
+	return len(p), nil
+}
+
+func (c *Conn) FrontHeadroom() int {
+	return headerLen
+}
+
+func (c *Conn) Upstream() any {
+	return c.NetPacketConn
+}
@@ -0,0 +1,155 @@
+package packetfragment
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sagernet/sing-vmess/packetaddr"
+	"github.com/sagernet/sing/common/buf"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+
+	"github.com/stretchr/testify/require"
+)
+
+// sizeCheckConn fails writes of datagrams larger than size.
+type sizeCheckConn struct {
+	net.PacketConn
+	t    *testing.T
+	size int
+}
+
+func (c *sizeCheckConn) WriteTo(p []byte, addr net.Addr) (n int, err error) {
+	require.LessOrEqual(c.t, len(p), c.size)
+	return c.PacketConn.WriteTo(p, addr)
+}
+
+func listenUDP(t *testing.T) *net.UDPConn {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		conn.Close()
+	})
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	return conn
+}
+
+func newTestPair(t *testing.T, size int) (*Conn, *Conn) {
+	clientUDP, serverUDP := listenUDP(t), listenUDP(t)
+	server, err := NewServerConn(packetaddr.NewConn(&sizeCheckConn{serverUDP, t, size}, M.SocksaddrFromNet(clientUDP.LocalAddr())), size)
+	require.NoError(t, err)
+	client := NewClientConn(packetaddr.NewConn(&sizeCheckConn{clientUDP, t, size}, M.SocksaddrFromNet(serverUDP.LocalAddr())), size)
+	return client, server
+}
+
+func testPayload(length int) []byte {
+	payload := make([]byte, length)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+	return payload
+}
+
+func writePacket(t *testing.T, conn N.PacketWriter, payload []byte, destination M.Socksaddr) {
+	frontHeadroom := N.CalculateFrontHeadroom(conn)
+	buffer := buf.NewSize(frontHeadroom + len(payload) + N.CalculateRearHeadroom(conn))
+	buffer.Resize(frontHeadroom, 0)
+	buffer.Write(payload)
+	require.NoError(t, conn.WritePacket(buffer, destination))
+}
+
+func readPacket(t *testing.T, conn N.PacketReader) ([]byte, M.Socksaddr) {
+	buffer := buf.NewPacket()
+	defer buffer.Release()
+	destination, err := conn.ReadPacket(buffer)
+	require.NoError(t, err)
+	return append([]byte(nil), buffer.Bytes()...), destination
+}
+
+func TestConn(t *testing.T) {
+	t.Parallel()
+	client, server := newTestPair(t, 100)
+	for _, destination := range []M.Socksaddr{
+		M.ParseSocksaddr("1.1.1.1:53"),
+		M.ParseSocksaddr("[2606:4700:4700::1111]:53"),
+	} {
+		for _, length := range []int{1, 50, 77, 500, 4000} {
+			payload := testPayload(length)
+			writePacket(t, client, payload, destination)
+			message, source := readPacket(t, server)
+			require.Equal(t, payload, message)
+			require.Equal(t, destination, source)
+			writePacket(t, server, payload, destination)
+			message, source = readPacket(t, client)
+			require.Equal(t, payload, message)
+			require.Equal(t, destination, source)
+		}
+	}
+}
+
+func TestConnSizeTooSmall(t *testing.T) {
+	t.Parallel()
+	client, _ := newTestPair(t, headerLen+packetaddr.AddressSerializer.AddrPortLen(M.ParseSocksaddr("1.1.1.1:53")))
+	buffer := buf.NewSize(64)
+	buffer.Resize(32, 0)
+	buffer.WriteString("hello")
+	require.Error(t, client.WritePacket(buffer, M.ParseSocksaddr("1.1.1.1:53")))
+}
+
+func TestConnWithoutAcknowledgement(t *testing.T) {
+	t.Parallel()
+	client := NewClientConn(packetaddr.NewConn(&readConn{reader: bytes.NewReader(nil)}, M.Socksaddr{}), 100)
+	_, err := client.ReadPacket(buf.NewPacket())
+	require.ErrorIs(t, err, io.EOF)
+	require.ErrorContains(t, err, "may not support packet fragmentation")
+
+	// data sent by a server that treats MagicAddress as a regular destination
+	packet := buf.NewPacket()
+	packetaddr.AddressSerializer.WriteAddrPort(packet, M.ParseSocksaddr("1.1.1.1:53"))
+	packet.Write([]byte{0, 1, 0, 1})
+	packet.WriteString("hello")
+	client = NewClientConn(packetaddr.NewConn(&readConn{reader: bytes.NewReader(packet.Bytes())}, M.Socksaddr{}), 100)
+	_, err = client.ReadPacket(buf.NewPacket())
+	require.ErrorContains(t, err, "missing acknowledgement")
+}
+
+func TestConnMalformedFragments(t *testing.T) {
+	t.Parallel()
+	client, server := newTestPair(t, 100)
+	serverConn := server.NetPacketConn
+	destination := M.ParseSocksaddr("1.1.1.1:53")
+	writeRaw := func(id uint16, index int, count int, payload string) {
+		header := make([]byte, headerLen)
+		writeHeader(header, id, index, count)
+		writePacket(t, serverConn, append(header, payload...), destination)
+	}
+	// too short for a header
+	writePacket(t, serverConn, []byte{1, 2}, destination)
+	// index out of range
+	writeRaw(1, 2, 2, "bad")
+	// duplicate fragment
+	writeRaw(2, 0, 2, "hello ")
+	writeRaw(2, 0, 2, "bad")
+	// fragment count mismatch
+	writeRaw(2, 1, 3, "bad")
+	writeRaw(2, 1, 2, "world")
+	message, _ := readPacket(t, client)
+	require.Equal(t, "hello world", string(message))
+}
+
+// readConn reads one datagram from reader per call.
+type readConn struct {
+	net.PacketConn
+	reader *bytes.Reader
+}
+
+func (c *readConn) ReadFrom(p []byte) (n int, addr net.Addr, err error) {
+	if c.reader.Len() == 0 {
+		return 0, nil, io.EOF
+	}
+	n, err = c.reader.Read(p)
+	return n, &net.UDPAddr{}, err
+}
@@ -1,8 +1,11 @@
 package pipelistener
 
 import (
+	"context"
 	"io"
 	"net"
+
+	"github.com/sagernet/sing/common"
 )
 
 var _ net.Listener = (*Listener)(nil)
@@ -23,6 +26,29 @@ func (l *Listener) Serve(conn net.Conn) {
 	l.pipe <- conn
 }
 
+// ServeContext is like Serve, and keeps ctx for servers to restore with ConnContext.
+func (l *Listener) ServeContext(ctx context.Context, conn net.Conn) {
+	l.pipe <- &contextConn{Conn: conn, ctx: ctx}
+}
+
+// ConnContext returns the context of a connection passed by ServeContext,
+// or ctx if there is none.
+func ConnContext(ctx context.Context, conn net.Conn) context.Context {
+	if connCtx, loaded := common.Cast[*contextConn](conn); loaded {
+		return connCtx.ctx
+	}
+	return ctx
+}
+
+type contextConn struct {
+	net.Conn
+	ctx context.Context
+}
+
+func (c *contextConn) Upstream() any {
+	return c.Conn
+}
+
 func (l *Listener) Accept() (net.Conn, error) {
 	select {
 	case conn := <-l.pipe:
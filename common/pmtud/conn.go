@@ -0,0 +1,35 @@
+package pmtud
+
+import (
+	"net"
+	"syscall"
+
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/bufio"
+	N "github.com/sagernet/sing/common/network"
+)
+
+// NewPacketConn unbinds a connected UDP connection for use with quic-go.
+// If the connection is backed by a UDP socket, the socket is exposed so that
+// quic-go can set the DF bit and perform path MTU discovery on it.
+func NewPacketConn(conn net.Conn) net.PacketConn {
+	packetConn := bufio.NewUnbindPacketConn(conn)
+	udpConn, isUDPConn := common.Cast[*net.UDPConn](conn)
+	if !isUDPConn {
+		return packetConn
+	}
+	return &syscallPacketConn{packetConn, udpConn}
+}
+
+type syscallPacketConn struct {
+	N.NetPacketConn
+	udpConn *net.UDPConn
+}
+
+func (c *syscallPacketConn) SyscallConn() (syscall.RawConn, error) {
+	return c.udpConn.SyscallConn()
+}
+
+func (c *syscallPacketConn) Upstream() any {
+	return c.NetPacketConn
+}
@@ -0,0 +1,205 @@
+package preconnect
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common/logger"
+)
+
+const (
+	DefaultSize        = 2
+	DefaultIdleTimeout = 30 * time.Second
+
+	minRetryDelay = time.Second
+	maxRetryDelay = time.Minute
+)
+
+type DialFunc func(ctx context.Context) (net.Conn, error)
+
+// Pool keeps warm connections to a server, so that the connect and TLS handshake
+// round trips are already done when a new outbound connection is requested.
+// Connections are dialed one by one from a single goroutine, which backs off
+// exponentially while the server is unreachable.
+type Pool struct {
+	ctx         context.Context
+	cancel      context.CancelFunc
+	logger      logger.ContextLogger
+	dial        DialFunc
+	size        int
+	idleTimeout time.Duration
+	notify      chan struct{}
+	access      sync.Mutex
+	conns       []pooledConn
+	closed      bool
+}
+
+type pooledConn struct {
+	net.Conn
+	createdAt time.Time
+}
+
+func New(ctx context.Context, logger logger.ContextLogger, options option.PreConnectOptions, dial DialFunc) *Pool {
+	ctx, cancel := context.WithCancel(ctx)
+	size := options.Size
+	if size <= 0 {
+		size = DefaultSize
+	}
+	idleTimeout := time.Duration(options.IdleTimeout)
+	if idleTimeout <= 0 {
+		idleTimeout = DefaultIdleTimeout
+	}
+	return &Pool{
+		ctx:         ctx,
+		cancel:      cancel,
+		logger:      logger,
+		dial:        dial,
+		size:        size,
+		idleTimeout: idleTimeout,
+		notify:      make(chan struct{}, 1),
+	}
+}
+
+func (p *Pool) Start() {
+	go p.loopFill()
+}
+
+// DialContext returns a warm connection if there is one, or dials a new one.
+func (p *Pool) DialContext(ctx context.Context) (net.Conn, error) {
+	conn := p.take()
+	p.fill()
+	if conn != nil {
+		return conn, nil
+	}
+	return p.dial(ctx)
+}
+
+func (p *Pool) take() net.Conn {
+	p.access.Lock()
+	defer p.access.Unlock()
+	for len(p.conns) > 0 {
+		conn := p.conns[0]
+		p.conns = p.conns[1:]
+		if time.Since(conn.createdAt) < p.idleTimeout {
+			return conn.Conn
+		}
+		conn.Close()
+	}
+	return nil
+}
+
+// fill wakes up the fill loop.
+func (p *Pool) fill() {
+	select {
+	case p.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (p *Pool) loopFill() {
+	ticker := time.NewTicker(p.idleTimeout / 2)
+	defer ticker.Stop()
+	var retryDelay time.Duration
+	for {
+		err := p.refill()
+		if err != nil {
+			if p.ctx.Err() != nil {
+				return
+			}
+			if retryDelay == 0 {
+				retryDelay = minRetryDelay
+			} else if retryDelay < maxRetryDelay {
+				retryDelay *= 2
+				if retryDelay > maxRetryDelay {
+					retryDelay = maxRetryDelay
+				}
+			}
+			p.logger.DebugContext(p.ctx, "pre-connect: ", err, ", retry in ", retryDelay)
+			retryTimer := time.NewTimer(retryDelay)
+			select {
+			case <-retryTimer.C:
+				p.removeStale()
+				continue
+			case <-p.ctx.Done():
+				retryTimer.Stop()
+				return
+			}
+		}
+		retryDelay = 0
+		select {
+		case <-p.notify:
+		case <-ticker.C:
+			p.removeStale()
+		case <-p.ctx.Done():
+			return
+		}
+	}
+}
+
+// refill dials until the pool is full, and returns the first dial error.
+func (p *Pool) refill() error {
+	for {
+		p.access.Lock()
+		if p.closed || len(p.conns) >= p.size {
+			p.access.Unlock()
+			return nil
+		}
+		p.access.Unlock()
+		conn, err := p.dial(p.ctx)
+		if err != nil {
+			return err
+		}
+		p.access.Lock()
+		if p.closed {
+			p.access.Unlock()
+			conn.Close()
+			return nil
+		}
+		p.conns = append(p.conns, pooledConn{conn, time.Now()})
+		p.access.Unlock()
+	}
+}
+
+// removeStale closes connections before the idle timeout is reached, so that
+// they are dialed again.
+func (p *Pool) removeStale() {
+	p.access.Lock()
+	defer p.access.Unlock()
+	conns := p.conns[:0]
+	for _, conn := range p.conns {
+		if time.Since(conn.createdAt) < p.idleTimeout/2 {
+			conns = append(conns, conn)
+		} else {
+			conn.Close()
+		}
+	}
+	p.conns = conns
+}
+
+// Reset closes all warm connections, for example after the default interface changed.
+func (p *Pool) Reset() {
+	p.access.Lock()
+	conns := p.conns
+	p.conns = nil
+	p.access.Unlock()
+	for _, conn := range conns {
+		conn.Close()
+	}
+	p.fill()
+}
+
+func (p *Pool) Close() error {
+	p.access.Lock()
+	p.closed = true
+	conns := p.conns
+	p.conns = nil
+	p.access.Unlock()
+	p.cancel()
+	for _, conn := range conns {
+		conn.Close()
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package preconnect
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common/logger"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPoolFillSerial(t *testing.T) {
+	t.Parallel()
+	var dialing, maxDialing, dials atomic.Int32
+	pool := New(context.Background(), logger.NOP(), option.PreConnectOptions{Size: 4}, func(ctx context.Context) (net.Conn, error) {
+		current := dialing.Add(1)
+		defer dialing.Add(-1)
+		for {
+			last := maxDialing.Load()
+			if current <= last || maxDialing.CompareAndSwap(last, current) {
+				break
+			}
+		}
+		dials.Add(1)
+		time.Sleep(10 * time.Millisecond)
+		client, server := net.Pipe()
+		server.Close()
+		return client, nil
+	})
+	defer pool.Close()
+	pool.Start()
+	for i := 0; i < 8; i++ {
+		pool.fill()
+	}
+	require.Eventually(t, func() bool {
+		return dials.Load() == 4
+	}, time.Second, 10*time.Millisecond)
+	require.Equal(t, int32(1), maxDialing.Load())
+}
+
+func TestPoolRetryBackoff(t *testing.T) {
+	t.Parallel()
+	var dials atomic.Int32
+	pool := New(context.Background(), logger.NOP(), option.PreConnectOptions{}, func(ctx context.Context) (net.Conn, error) {
+		dials.Add(1)
+		return nil, net.ErrClosed
+	})
+	defer pool.Close()
+	pool.Start()
+	for i := 0; i < 10; i++ {
+		pool.fill()
+		time.Sleep(10 * time.Millisecond)
+	}
+	// the first retry is only made after minRetryDelay
+	require.Equal(t, int32(1), dials.Load())
+}
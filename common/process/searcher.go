@@ -23,6 +23,7 @@ type Config struct {
 }
 
 type Info struct {
+	ProcessID   uint32
 	ProcessPath string
 	PackageName string
 	User        string
@@ -24,11 +24,20 @@ func NewSearcher(_ Config) (Searcher, error) {
 }
 
 func (d *darwinSearcher) FindProcessInfo(ctx context.Context, network string, source netip.AddrPort, destination netip.AddrPort) (*Info, error) {
-	processName, err := findProcessName(network, source.Addr(), int(source.Port()))
+	processID, err := findProcessID(network, source.Addr(), int(source.Port()))
 	if err != nil {
 		return nil, err
 	}
-	return &Info{ProcessPath: processName, UserId: -1}, nil
+	processPath, err := getExecPathFromPID(processID)
+	if err != nil {
+		return nil, err
+	}
+	userId := int32(-1)
+	processInfo, err := unix.SysctlKinfoProc("kern.proc.pid", int(processID))
+	if err == nil {
+		userId = int32(processInfo.Eproc.Ucred.Uid)
+	}
+	return &Info{ProcessID: processID, ProcessPath: processPath, UserId: userId}, nil
 }
 
 var structSize = func() int {
@@ -47,7 +56,7 @@ var structSize = func() int {
 	}
 }()
 
-func findProcessName(network string, ip netip.Addr, port int) (string, error) {
+func findProcessID(network string, ip netip.Addr, port int) (uint32, error) {
 	var spath string
 	switch network {
 	case N.NetworkTCP:
@@ -55,14 +64,14 @@ func findProcessName(network string, ip netip.Addr, port int) (string, error) {
 	case N.NetworkUDP:
 		spath = "net.inet.udp.pcblist_n"
 	default:
-		return "", os.ErrInvalid
+		return 0, os.ErrInvalid
 	}
 
 	isIPv4 := ip.Is4()
 
 	value, err := unix.SysctlRaw(spath)
 	if err != nil {
-		return "", err
+		return 0, err
 	}
 
 	buf := value
@@ -106,11 +115,10 @@ func findProcessName(network string, ip netip.Addr, port int) (string, error) {
 		}
 
 		// xsocket_n.so_last_pid
-		pid := readNativeUint32(buf[so+68 : so+72])
-		return getExecPathFromPID(pid)
+		return readNativeUint32(buf[so+68 : so+72]), nil
 	}
 
-	return "", ErrNotFound
+	return 0, ErrNotFound
 }
 
 func getExecPathFromPID(pid uint32) (string, error) {
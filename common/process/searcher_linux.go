@@ -24,11 +24,12 @@ func (s *linuxSearcher) FindProcessInfo(ctx context.Context, network string, sou
 	if err != nil {
 		return nil, err
 	}
-	processPath, err := resolveProcessNameByProcSearch(inode, uid)
+	processID, processPath, err := resolveProcessByProcSearch(inode, uid)
 	if err != nil {
 		s.logger.DebugContext(ctx, "find process path: ", err)
 	}
 	return &Info{
+		ProcessID:   processID,
 		UserId:      int32(uid),
 		ProcessPath: processPath,
 	}, nil
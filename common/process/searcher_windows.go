@@ -64,14 +64,18 @@ func initWin32API() error {
 }
 
 func (s *windowsSearcher) FindProcessInfo(ctx context.Context, network string, source netip.AddrPort, destination netip.AddrPort) (*Info, error) {
-	processName, err := findProcessName(network, source.Addr(), int(source.Port()))
+	processID, err := findProcessID(network, source.Addr(), int(source.Port()))
 	if err != nil {
 		return nil, err
 	}
-	return &Info{ProcessPath: processName, UserId: -1}, nil
+	processPath, err := getExecPathFromPID(processID)
+	if err != nil {
+		return nil, err
+	}
+	return &Info{ProcessID: processID, ProcessPath: processPath, User: getUserFromPID(processID), UserId: -1}, nil
 }
 
-func findProcessName(network string, ip netip.Addr, srcPort int) (string, error) {
+func findProcessID(network string, ip netip.Addr, srcPort int) (uint32, error) {
 	family := windows.AF_INET
 	if ip.Is6() {
 		family = windows.AF_INET6
@@ -92,21 +96,17 @@ func findProcessName(network string, ip netip.Addr, srcPort int) (string, error)
 		fn = procGetExtendedUdpTable.Addr()
 		class = udpTablePid
 	default:
-		return "", os.ErrInvalid
+		return 0, os.ErrInvalid
 	}
 
 	buf, err := getTransportTable(fn, family, class)
 	if err != nil {
-		return "", err
+		return 0, err
 	}
 
 	s := newSearcher(family == windows.AF_INET, network == N.NetworkTCP)
 
-	pid, err := s.Search(buf, ip, uint16(srcPort))
-	if err != nil {
-		return "", err
-	}
-	return getExecPathFromPID(pid)
+	return s.Search(buf, ip, uint16(srcPort))
 }
 
 type searcher struct {
@@ -232,3 +232,33 @@ func getExecPathFromPID(pid uint32) (string, error) {
 	}
 	return syscall.UTF16ToString(buf[:size]), nil
 }
+
+// getUserFromPID returns the owner of the process as DOMAIN\user, or an empty
+// string if it is not accessible.
+func getUserFromPID(pid uint32) string {
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, pid)
+	if err != nil {
+		return ""
+	}
+	defer windows.CloseHandle(h)
+
+	var token windows.Token
+	err = windows.OpenProcessToken(h, windows.TOKEN_QUERY, &token)
+	if err != nil {
+		return ""
+	}
+	defer token.Close()
+
+	tokenUser, err := token.GetTokenUser()
+	if err != nil {
+		return ""
+	}
+	account, domain, _, err := tokenUser.User.Sid.LookupAccount("")
+	if err != nil {
+		return ""
+	}
+	if domain == "" {
+		return account
+	}
+	return domain + "\\" + account
+}
@@ -0,0 +1,147 @@
+package proxyauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/netip"
+	"time"
+
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/auth"
+	"github.com/sagernet/sing/common/cache"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/logger"
+)
+
+const (
+	TypeHTTP     = "http"
+	TypeHTPasswd = "htpasswd"
+
+	DefaultTimeout   = 5 * time.Second
+	DefaultCacheTTL  = time.Minute
+	DefaultRateLimit = 10
+)
+
+type verifier interface {
+	Verify(ctx context.Context, source netip.Addr, username string, password string) (bool, error)
+}
+
+// Authenticator verifies proxy credentials against the static users of the
+// inbound and an external source, remembering the results for a while.
+type Authenticator struct {
+	logger   logger.ContextLogger
+	users    *auth.Authenticator
+	external verifier
+	cache    *cache.LruCache[[sha256.Size]byte, bool]
+	reject   *auth.Authenticator
+}
+
+func New(ctx context.Context, logger logger.ContextLogger, users []auth.User, options option.ExternalAuthOptions) (*Authenticator, error) {
+	authenticator := &Authenticator{
+		logger: logger,
+		users:  auth.NewAuthenticator(users),
+	}
+	switch options.Type {
+	case TypeHTTP:
+		if options.URL == "" {
+			return nil, E.New("missing external auth URL")
+		}
+		timeout := time.Duration(options.Timeout)
+		if timeout == 0 {
+			timeout = DefaultTimeout
+		}
+		rateLimit := options.RateLimit
+		if rateLimit == 0 {
+			rateLimit = DefaultRateLimit
+		} else if rateLimit < 0 {
+			return nil, E.New("invalid external auth rate_limit: ", rateLimit)
+		}
+		authenticator.external = newHTTPVerifier(ctx, options.URL, options.Detour, timeout, rateLimit)
+	case TypeHTPasswd:
+		if options.Path == "" {
+			return nil, E.New("missing htpasswd path")
+		}
+		htpasswd, err := newHTPasswdVerifier(ctx, logger, options.Path, authenticator.clearCache)
+		if err != nil {
+			return nil, err
+		}
+		authenticator.external = htpasswd
+	default:
+		return nil, E.New("unknown external auth type: ", options.Type)
+	}
+	cacheTTL := time.Duration(options.CacheTTL)
+	if cacheTTL == 0 {
+		cacheTTL = DefaultCacheTTL
+	}
+	if cacheTTL > 0 {
+		if cacheTTL < time.Second {
+			cacheTTL = time.Second
+		}
+		authenticator.cache = cache.New(
+			cache.WithAge[[sha256.Size]byte, bool](int64(cacheTTL/time.Second)),
+			cache.WithSize[[sha256.Size]byte, bool](4096),
+		)
+	}
+	// credentials that can never be sent, for connections failing the external check
+	var rejectPassword [16]byte
+	_, err := rand.Read(rejectPassword[:])
+	if err != nil {
+		return nil, err
+	}
+	authenticator.reject = auth.NewAuthenticator([]auth.User{{
+		Username: "\x00",
+		Password: hex.EncodeToString(rejectPassword[:]),
+	}})
+	return authenticator, nil
+}
+
+func (a *Authenticator) Start() error {
+	if starter, isStarter := a.external.(interface{ Start() error }); isStarter {
+		return starter.Start()
+	}
+	return nil
+}
+
+func (a *Authenticator) Close() error {
+	return common.Close(a.external)
+}
+
+func (a *Authenticator) Verify(ctx context.Context, source netip.Addr, username string, password string) bool {
+	if a.users != nil && a.users.Verify(username, password) {
+		return true
+	}
+	var cacheKey [sha256.Size]byte
+	if a.cache != nil {
+		cacheKey = sha256.Sum256([]byte(username + "\x00" + password))
+		if verified, loaded := a.cache.Load(cacheKey); loaded {
+			return verified
+		}
+	}
+	verified, err := a.external.Verify(ctx, source, username, password)
+	if err != nil {
+		a.logger.ErrorContext(ctx, E.Cause(err, "external auth"))
+		return false
+	}
+	if a.cache != nil {
+		a.cache.Store(cacheKey, verified)
+	}
+	return verified
+}
+
+func (a *Authenticator) clearCache() {
+	if a.cache != nil {
+		a.cache.Clear()
+	}
+}
+
+// authenticatorFor returns an authenticator that accepts exactly the given
+// credentials if they are valid, and nothing otherwise.
+func (a *Authenticator) authenticatorFor(ctx context.Context, source netip.Addr, username string, password string, present bool) *auth.Authenticator {
+	if present && a.Verify(ctx, source, username, password) {
+		return auth.NewAuthenticator([]auth.User{{Username: username, Password: password}})
+	}
+	return a.reject
+}
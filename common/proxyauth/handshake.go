@@ -0,0 +1,144 @@
+package proxyauth
+
+import (
+	std_bufio "bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"net"
+	"net/textproto"
+	"strings"
+
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/auth"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	"github.com/sagernet/sing/protocol/socks/socks4"
+	"github.com/sagernet/sing/protocol/socks/socks5"
+)
+
+// HTTPAuthenticator peeks the credentials of the first request buffered in
+// reader and returns an authenticator for the HTTP proxy handshake.
+func (a *Authenticator) HTTPAuthenticator(ctx context.Context, source M.Socksaddr, reader *std_bufio.Reader) *auth.Authenticator {
+	header, err := peekHeader(reader)
+	if err != nil {
+		return a.reject
+	}
+	authorization := header.Get("Proxy-Authorization")
+	if !strings.HasPrefix(authorization, "Basic ") {
+		return a.reject
+	}
+	// decoded the same way as the HTTP proxy handshake
+	userPassword, _ := base64.URLEncoding.DecodeString(authorization[6:])
+	username, password, present := strings.Cut(string(userPassword), ":")
+	return a.authenticatorFor(ctx, source.Addr, username, password, present)
+}
+
+func peekHeader(reader *std_bufio.Reader) (textproto.MIMEHeader, error) {
+	for size := 1; ; size = reader.Buffered() + 1 {
+		if size > reader.Size() {
+			return nil, E.New("request header too large")
+		}
+		_, err := reader.Peek(size)
+		if err != nil {
+			return nil, err
+		}
+		content, _ := reader.Peek(reader.Buffered())
+		if headerEnd := bytes.Index(content, []byte("\r\n\r\n")); headerEnd != -1 {
+			_, headerContent, _ := bytes.Cut(content[:headerEnd+4], []byte("\r\n"))
+			return textproto.NewReader(std_bufio.NewReader(bytes.NewReader(headerContent))).ReadMIMEHeader()
+		}
+	}
+}
+
+// HandshakeSOCKS performs the SOCKS5 username/password authentication, and
+// returns a connection and reader on which the handshake continues without
+// authentication. SOCKS4 is rejected since it carries no password.
+func (a *Authenticator) HandshakeSOCKS(ctx context.Context, conn net.Conn, source M.Socksaddr, reader *std_bufio.Reader) (context.Context, net.Conn, *std_bufio.Reader, error) {
+	version, err := reader.ReadByte()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	switch version {
+	case socks4.Version:
+		err = socks4.WriteResponse(conn, socks4.Response{
+			ReplyCode: socks4.ReplyCodeRejectedOrFailed,
+		})
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return nil, nil, nil, E.New("socks4: not supported with external auth")
+	case socks5.Version:
+	default:
+		return nil, nil, nil, E.New("socks: unknown version ", version)
+	}
+	authRequest, err := socks5.ReadAuthRequest0(reader)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if !common.Contains(authRequest.Methods, socks5.AuthTypeUsernamePassword) {
+		err = socks5.WriteAuthResponse(conn, socks5.AuthResponse{
+			Method: socks5.AuthTypeNoAcceptedMethods,
+		})
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return nil, nil, nil, E.New("socks5: username/password authentication not offered")
+	}
+	err = socks5.WriteAuthResponse(conn, socks5.AuthResponse{
+		Method: socks5.AuthTypeUsernamePassword,
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	usernamePasswordAuthRequest, err := socks5.ReadUsernamePasswordAuthRequest(reader)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	response := socks5.UsernamePasswordAuthResponse{}
+	if a.Verify(ctx, source.Addr, usernamePasswordAuthRequest.Username, usernamePasswordAuthRequest.Password) {
+		response.Status = socks5.UsernamePasswordStatusSuccess
+	} else {
+		response.Status = socks5.UsernamePasswordStatusFailure
+	}
+	err = socks5.WriteUsernamePasswordAuthResponse(conn, response)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if response.Status != socks5.UsernamePasswordStatusSuccess {
+		return nil, nil, nil, E.New("socks5: authentication failed, username=", usernamePasswordAuthRequest.Username)
+	}
+	ctx = auth.ContextWithUser(ctx, usernamePasswordAuthRequest.Username)
+	// replay a greeting without authentication to the SOCKS handshake and drop its reply
+	greeting := []byte{socks5.Version, 1, socks5.AuthTypeNotRequired}
+	reader = std_bufio.NewReader(io.MultiReader(bytes.NewReader(greeting), reader))
+	return ctx, &authenticatedConn{Conn: conn, discard: 2}, reader, nil
+}
+
+type authenticatedConn struct {
+	net.Conn
+	discard int
+}
+
+func (c *authenticatedConn) Write(p []byte) (n int, err error) {
+	if c.discard > 0 {
+		if len(p) <= c.discard {
+			c.discard -= len(p)
+			return len(p), nil
+		}
+		discarded := c.discard
+		c.discard = 0
+		n, err = c.Conn.Write(p[discarded:])
+		return n + discarded, err
+	}
+	return c.Conn.Write(p)
+}
+
+func (c *authenticatedConn) Upstream() any {
+	return c.Conn
+}
+
+func (c *authenticatedConn) WriterReplaceable() bool {
+	return c.discard == 0
+}
@@ -0,0 +1,188 @@
+package proxyauth
+
+import (
+	"bufio"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/sagernet/fswatch"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/logger"
+	"github.com/sagernet/sing/service/filemanager"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// htpasswdVerifier checks credentials against an Apache htpasswd file with
+// bcrypt, MD5 (apr1), SHA1 or plain text entries, and reloads it on change.
+type htpasswdVerifier struct {
+	logger   logger.ContextLogger
+	path     string
+	onReload func()
+	access   sync.RWMutex
+	users    map[string]string
+	watcher  *fswatch.Watcher
+}
+
+func newHTPasswdVerifier(ctx context.Context, logger logger.ContextLogger, path string, onReload func()) (*htpasswdVerifier, error) {
+	verifier := &htpasswdVerifier{
+		logger:   logger,
+		path:     filemanager.BasePath(ctx, path),
+		onReload: onReload,
+	}
+	err := verifier.reload()
+	if err != nil {
+		return nil, err
+	}
+	filePath, _ := filepath.Abs(verifier.path)
+	watcher, err := fswatch.NewWatcher(fswatch.Options{
+		Path: []string{filePath},
+		Callback: func(path string) {
+			uErr := verifier.reload()
+			if uErr != nil {
+				logger.Error(E.Cause(uErr, "reload htpasswd file"))
+			}
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	verifier.watcher = watcher
+	return verifier, nil
+}
+
+func (v *htpasswdVerifier) Start() error {
+	err := v.watcher.Start()
+	if err != nil {
+		v.logger.Error(E.Cause(err, "watch htpasswd file"))
+	}
+	return nil
+}
+
+func (v *htpasswdVerifier) Close() error {
+	return common.Close(common.PtrOrNil(v.watcher))
+}
+
+func (v *htpasswdVerifier) reload() error {
+	file, err := os.Open(v.path)
+	if err != nil {
+		return E.Cause(err, "open htpasswd file")
+	}
+	defer file.Close()
+	users := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		username, hash, loaded := strings.Cut(line, ":")
+		if !loaded {
+			return E.New("parse htpasswd file: invalid line ", lineNumber)
+		}
+		users[username] = hash
+	}
+	err = scanner.Err()
+	if err != nil {
+		return E.Cause(err, "read htpasswd file")
+	}
+	v.access.Lock()
+	v.users = users
+	v.access.Unlock()
+	if v.onReload != nil {
+		v.onReload()
+	}
+	v.logger.Info("loaded htpasswd file: ", len(users), " users")
+	return nil
+}
+
+func (v *htpasswdVerifier) Verify(ctx context.Context, source netip.Addr, username string, password string) (bool, error) {
+	v.access.RLock()
+	hash, loaded := v.users[username]
+	v.access.RUnlock()
+	if !loaded {
+		return false, nil
+	}
+	switch {
+	case strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"), strings.HasPrefix(hash, "$2y$"):
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil, nil
+	case strings.HasPrefix(hash, "$apr1$"):
+		salt, _, _ := strings.Cut(strings.TrimPrefix(hash, "$apr1$"), "$")
+		return subtle.ConstantTimeCompare([]byte(apr1(password, salt)), []byte(hash)) == 1, nil
+	case strings.HasPrefix(hash, "{SHA}"):
+		sum := sha1.Sum([]byte(password))
+		return subtle.ConstantTimeCompare([]byte("{SHA}"+base64.StdEncoding.EncodeToString(sum[:])), []byte(hash)) == 1, nil
+	default:
+		return subtle.ConstantTimeCompare([]byte(password), []byte(hash)) == 1, nil
+	}
+}
+
+const apr1Alphabet = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// apr1 implements the Apache variant of the MD5 based crypt(3).
+func apr1(password string, salt string) string {
+	const magic = "$apr1$"
+	if len(salt) > 8 {
+		salt = salt[:8]
+	}
+	alternate := md5.Sum([]byte(password + salt + password))
+	hash := md5.New()
+	hash.Write([]byte(password + magic + salt))
+	for i := len(password); i > 0; i -= md5.Size {
+		if i > md5.Size {
+			hash.Write(alternate[:])
+		} else {
+			hash.Write(alternate[:i])
+		}
+	}
+	for i := len(password); i > 0; i >>= 1 {
+		if i&1 == 1 {
+			hash.Write([]byte{0})
+		} else {
+			hash.Write([]byte{password[0]})
+		}
+	}
+	final := hash.Sum(nil)
+	for i := 0; i < 1000; i++ {
+		round := md5.New()
+		if i&1 == 1 {
+			round.Write([]byte(password))
+		} else {
+			round.Write(final)
+		}
+		if i%3 != 0 {
+			round.Write([]byte(salt))
+		}
+		if i%7 != 0 {
+			round.Write([]byte(password))
+		}
+		if i&1 == 1 {
+			round.Write(final)
+		} else {
+			round.Write([]byte(password))
+		}
+		final = round.Sum(nil)
+	}
+	var builder strings.Builder
+	builder.WriteString(magic + salt + "$")
+	encode := func(value uint, length int) {
+		for ; length > 0; length-- {
+			builder.WriteByte(apr1Alphabet[value&0x3f])
+			value >>= 6
+		}
+	}
+	for _, group := range [][3]int{{0, 6, 12}, {1, 7, 13}, {2, 8, 14}, {3, 9, 15}, {4, 10, 5}} {
+		encode(uint(final[group[0]])<<16|uint(final[group[1]])<<8|uint(final[group[2]]), 4)
+	}
+	encode(uint(final[11]), 2)
+	return builder.String()
+}
@@ -0,0 +1,162 @@
+package proxyauth
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+	"github.com/sagernet/sing/service"
+
+	"golang.org/x/time/rate"
+)
+
+// httpVerifier asks an HTTP endpoint to check the credentials, which are sent
+// as basic authorization. A 2xx status accepts them, 401 and 403 reject them.
+//
+// Requests go through the detour outbound, or the default outbound, so they do
+// not loop back through a TUN inbound. Requests are rate limited per source, so
+// that a client sending bad credentials can not block checks of other clients.
+type httpVerifier struct {
+	ctx             context.Context
+	outboundManager adapter.OutboundManager
+	url             string
+	detour          string
+	timeout         time.Duration
+	limiter         *sourceLimiter
+	client          *http.Client
+}
+
+func newHTTPVerifier(ctx context.Context, url string, detour string, timeout time.Duration, rateLimit int) *httpVerifier {
+	return &httpVerifier{
+		ctx:             ctx,
+		outboundManager: service.FromContext[adapter.OutboundManager](ctx),
+		url:             url,
+		detour:          detour,
+		timeout:         timeout,
+		limiter:         newSourceLimiter(rate.Limit(rateLimit), rateLimit),
+	}
+}
+
+func (v *httpVerifier) Start() error {
+	var dialer N.Dialer
+	if v.detour != "" {
+		outbound, loaded := v.outboundManager.Outbound(v.detour)
+		if !loaded {
+			return E.New("external auth detour not found: ", v.detour)
+		}
+		dialer = outbound
+	} else {
+		dialer = v.outboundManager.Default()
+	}
+	v.client = &http.Client{
+		Timeout: v.timeout,
+		Transport: &http.Transport{
+			ForceAttemptHTTP2:   true,
+			TLSHandshakeTimeout: C.TCPTimeout,
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, M.ParseSocksaddr(addr))
+			},
+			TLSClientConfig: &tls.Config{
+				RootCAs: adapter.RootPoolFromContext(v.ctx),
+			},
+		},
+	}
+	return nil
+}
+
+func (v *httpVerifier) Verify(ctx context.Context, source netip.Addr, username string, password string) (bool, error) {
+	if !v.limiter.Allow(source) {
+		return false, E.New("rate limit exceeded")
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, v.url, nil)
+	if err != nil {
+		return false, err
+	}
+	request.SetBasicAuth(username, password)
+	response, err := v.client.Do(request)
+	if err != nil {
+		return false, err
+	}
+	io.Copy(io.Discard, io.LimitReader(response.Body, 4096))
+	response.Body.Close()
+	switch {
+	case response.StatusCode >= 200 && response.StatusCode < 300:
+		return true, nil
+	case response.StatusCode == http.StatusUnauthorized, response.StatusCode == http.StatusForbidden:
+		return false, nil
+	default:
+		return false, E.New("unexpected status: ", response.Status)
+	}
+}
+
+func (v *httpVerifier) Close() error {
+	if v.client != nil {
+		v.client.CloseIdleConnections()
+	}
+	return nil
+}
+
+// sourceLimiterIdle is how long the limiter of an inactive source is kept.
+const sourceLimiterIdle = time.Minute
+
+// sourceLimiter holds a rate limiter for each source address, IPv6 addresses
+// are grouped by /64 as a client usually owns the whole prefix.
+type sourceLimiter struct {
+	access    sync.Mutex
+	limit     rate.Limit
+	burst     int
+	limiters  map[netip.Prefix]*sourceLimit
+	lastClean time.Time
+}
+
+type sourceLimit struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+func newSourceLimiter(limit rate.Limit, burst int) *sourceLimiter {
+	return &sourceLimiter{
+		limit:     limit,
+		burst:     burst,
+		limiters:  make(map[netip.Prefix]*sourceLimit),
+		lastClean: time.Now(),
+	}
+}
+
+func (l *sourceLimiter) Allow(source netip.Addr) bool {
+	source = source.Unmap()
+	var key netip.Prefix
+	if source.Is6() {
+		key = netip.PrefixFrom(source, 64).Masked()
+	} else {
+		key = netip.PrefixFrom(source, source.BitLen())
+	}
+	l.access.Lock()
+	defer l.access.Unlock()
+	now := time.Now()
+	if now.Sub(l.lastClean) >= sourceLimiterIdle {
+		for limitKey, limit := range l.limiters {
+			if now.Sub(limit.lastSeen) >= sourceLimiterIdle {
+				delete(l.limiters, limitKey)
+			}
+		}
+		l.lastClean = now
+	}
+	limit, loaded := l.limiters[key]
+	if !loaded {
+		limit = &sourceLimit{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.limiters[key] = limit
+	}
+	limit.lastSeen = now
+	return limit.limiter.AllowN(now, 1)
+}
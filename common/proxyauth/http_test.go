@@ -0,0 +1,60 @@
+package proxyauth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPVerifier(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		username, password, _ := request.BasicAuth()
+		switch {
+		case username == "user" && password == "password":
+			writer.WriteHeader(http.StatusNoContent)
+		case username == "error":
+			writer.WriteHeader(http.StatusInternalServerError)
+		default:
+			writer.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+	verifier := &httpVerifier{
+		url:     server.URL,
+		limiter: newSourceLimiter(0, 4),
+		client:  server.Client(),
+	}
+	client := netip.MustParseAddr("192.0.2.1")
+	verified, err := verifier.Verify(context.Background(), client, "user", "password")
+	require.NoError(t, err)
+	require.True(t, verified)
+	verified, err = verifier.Verify(context.Background(), client, "user", "wrong")
+	require.NoError(t, err)
+	require.False(t, verified)
+	_, err = verifier.Verify(context.Background(), client, "error", "password")
+	require.Error(t, err)
+	_, err = verifier.Verify(context.Background(), client, "user", "password")
+	require.NoError(t, err)
+	_, err = verifier.Verify(context.Background(), client, "user", "password")
+	require.ErrorContains(t, err, "rate limit exceeded")
+	// other sources are not affected
+	verified, err = verifier.Verify(context.Background(), netip.MustParseAddr("192.0.2.2"), "user", "password")
+	require.NoError(t, err)
+	require.True(t, verified)
+}
+
+func TestSourceLimiter(t *testing.T) {
+	t.Parallel()
+	limiter := newSourceLimiter(0, 1)
+	require.True(t, limiter.Allow(netip.MustParseAddr("192.0.2.1")))
+	require.False(t, limiter.Allow(netip.MustParseAddr("::ffff:192.0.2.1")))
+	require.True(t, limiter.Allow(netip.MustParseAddr("192.0.2.2")))
+	require.True(t, limiter.Allow(netip.MustParseAddr("2001:db8::1")))
+	require.False(t, limiter.Allow(netip.MustParseAddr("2001:db8::2")))
+	require.True(t, limiter.Allow(netip.MustParseAddr("2001:db8:0:1::1")))
+}
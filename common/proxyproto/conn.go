@@ -0,0 +1,207 @@
+package proxyproto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+)
+
+const (
+	maxV1HeaderLen = 107
+	headerTimeout  = 15 * time.Second
+)
+
+var v1Prefix = []byte("PROXY ")
+
+var _ net.Listener = (*Listener)(nil)
+
+// Listener wraps accepted connections with Conn.
+type Listener struct {
+	net.Listener
+}
+
+func NewListener(listener net.Listener) *Listener {
+	return &Listener{listener}
+}
+
+func (l *Listener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return NewConn(conn), nil
+}
+
+// Conn reads a PROXY protocol v1 or v2 header on the first call to Read,
+// RemoteAddr, LocalAddr or Handshake, and reports the addresses of the
+// header, or of the connection itself for LOCAL and UNKNOWN headers.
+type Conn struct {
+	net.Conn
+	handshakeOnce sync.Once
+	handshakeErr  error
+	source        net.Addr
+	destination   net.Addr
+}
+
+func NewConn(conn net.Conn) *Conn {
+	return &Conn{Conn: conn}
+}
+
+// Handshake reads the header if not done yet.
+func (c *Conn) Handshake() error {
+	c.handshakeOnce.Do(func() {
+		c.Conn.SetReadDeadline(time.Now().Add(headerTimeout))
+		source, destination, err := ReadHeader(c.Conn)
+		c.Conn.SetReadDeadline(time.Time{})
+		if err != nil {
+			c.handshakeErr = E.Cause(err, "read PROXY protocol header")
+			return
+		}
+		if source.IsValid() && destination.IsValid() {
+			c.source = source.TCPAddr()
+			c.destination = destination.TCPAddr()
+		}
+	})
+	return c.handshakeErr
+}
+
+func (c *Conn) Read(b []byte) (int, error) {
+	err := c.Handshake()
+	if err != nil {
+		return 0, err
+	}
+	return c.Conn.Read(b)
+}
+
+func (c *Conn) RemoteAddr() net.Addr {
+	c.Handshake()
+	if c.source != nil {
+		return c.source
+	}
+	return c.Conn.RemoteAddr()
+}
+
+func (c *Conn) LocalAddr() net.Addr {
+	c.Handshake()
+	if c.destination != nil {
+		return c.destination
+	}
+	return c.Conn.LocalAddr()
+}
+
+func (c *Conn) Upstream() any {
+	return c.Conn
+}
+
+// ReadHeader reads a PROXY protocol v1 or v2 header without reading past it,
+// the returned addresses are invalid for LOCAL and UNKNOWN headers.
+func ReadHeader(reader io.Reader) (source M.Socksaddr, destination M.Socksaddr, err error) {
+	header := make([]byte, len(Signature))
+	_, err = io.ReadFull(reader, header)
+	if err != nil {
+		return
+	}
+	if bytes.Equal(header, Signature) {
+		return readV2(reader)
+	} else if bytes.HasPrefix(header, v1Prefix) {
+		return readV1(reader, header)
+	}
+	err = E.New("missing PROXY protocol header")
+	return
+}
+
+func readV1(reader io.Reader, header []byte) (source M.Socksaddr, destination M.Socksaddr, err error) {
+	line := header
+	next := make([]byte, 1)
+	for !bytes.HasSuffix(line, []byte("\r\n")) {
+		if len(line) >= maxV1HeaderLen {
+			err = E.New("PROXY protocol v1 header too long")
+			return
+		}
+		_, err = io.ReadFull(reader, next)
+		if err != nil {
+			return
+		}
+		line = append(line, next[0])
+	}
+	fields := strings.Fields(string(line))
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		err = E.New("invalid PROXY protocol v1 header")
+		return
+	}
+	source, err = parseV1Address(fields[2], fields[4])
+	if err != nil {
+		return
+	}
+	destination, err = parseV1Address(fields[3], fields[5])
+	return
+}
+
+func parseV1Address(address string, port string) (M.Socksaddr, error) {
+	addr, err := netip.ParseAddr(address)
+	if err != nil {
+		return M.Socksaddr{}, E.Cause(err, "invalid PROXY protocol v1 address")
+	}
+	portNumber, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return M.Socksaddr{}, E.Cause(err, "invalid PROXY protocol v1 port")
+	}
+	return M.SocksaddrFrom(addr, uint16(portNumber)), nil
+}
+
+func readV2(reader io.Reader) (source M.Socksaddr, destination M.Socksaddr, err error) {
+	header := make([]byte, 4)
+	_, err = io.ReadFull(reader, header)
+	if err != nil {
+		return
+	}
+	if header[0]&0xF0 != version2 {
+		err = E.New("unsupported PROXY protocol version: ", header[0]>>4)
+		return
+	}
+	content := make([]byte, binary.BigEndian.Uint16(header[2:]))
+	_, err = io.ReadFull(reader, content)
+	if err != nil {
+		return
+	}
+	switch header[0] & 0x0F {
+	case commandLocal:
+		return
+	case commandProxy:
+	default:
+		err = E.New("unknown PROXY protocol v2 command: ", header[0]&0x0F)
+		return
+	}
+	var addressLen int
+	switch header[1] & 0xF0 {
+	case familyInet:
+		addressLen = 4
+	case familyInet6:
+		addressLen = 16
+	default:
+		// unspecified or unix addresses
+		return
+	}
+	if len(content) < addressLen*2+4 {
+		err = E.New("invalid PROXY protocol v2 address length")
+		return
+	}
+	sourceAddr, _ := netip.AddrFromSlice(content[:addressLen])
+	destinationAddr, _ := netip.AddrFromSlice(content[addressLen : addressLen*2])
+	ports := content[addressLen*2:]
+	source = M.SocksaddrFrom(sourceAddr, binary.BigEndian.Uint16(ports))
+	destination = M.SocksaddrFrom(destinationAddr, binary.BigEndian.Uint16(ports[2:]))
+	return
+}
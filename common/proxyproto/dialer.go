@@ -0,0 +1,110 @@
+package proxyproto
+
+import (
+	"context"
+	"net"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/buf"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+var _ N.Dialer = (*Dialer)(nil)
+
+// Dialer sends a PROXY protocol v2 header describing the inbound connection
+// in the context before any payload, see WrapConn.
+type Dialer struct {
+	N.Dialer
+}
+
+func NewDialer(dialer N.Dialer) *Dialer {
+	return &Dialer{dialer}
+}
+
+func (d *Dialer) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	conn, err := d.Dialer.DialContext(ctx, network, destination)
+	if err != nil {
+		return nil, err
+	}
+	return WrapConn(ctx, network, conn)
+}
+
+func (d *Dialer) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
+	conn, err := d.Dialer.ListenPacket(ctx, destination)
+	if err != nil {
+		return nil, err
+	}
+	return WrapPacketConn(ctx, conn), nil
+}
+
+// WrapConn sends the header as the first bytes of TCP connections, or as the
+// prefix of every packet of UDP connections. A LOCAL header is sent if the
+// context has no inbound connection with IP addresses.
+func WrapConn(ctx context.Context, network string, conn net.Conn) (net.Conn, error) {
+	header := contextHeader(ctx, network)
+	if N.NetworkName(network) == N.NetworkUDP {
+		return &packetConn{Conn: conn, header: header}, nil
+	}
+	_, err := conn.Write(header)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// WrapPacketConn is like WrapConn for unconnected UDP sockets.
+func WrapPacketConn(ctx context.Context, conn net.PacketConn) net.PacketConn {
+	return &listenPacketConn{PacketConn: conn, header: contextHeader(ctx, N.NetworkUDP)}
+}
+
+func contextHeader(ctx context.Context, network string) []byte {
+	metadata := adapter.ContextFrom(ctx)
+	if metadata == nil {
+		return LocalHeader()
+	}
+	header, err := Header(network, metadata.Source, metadata.OriginDestination)
+	if err != nil {
+		return LocalHeader()
+	}
+	return header
+}
+
+type packetConn struct {
+	net.Conn
+	header []byte
+}
+
+func (c *packetConn) Write(b []byte) (int, error) {
+	packet := prefix(c.header, b)
+	defer packet.Release()
+	_, err := c.Conn.Write(packet.Bytes())
+	if err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+type listenPacketConn struct {
+	net.PacketConn
+	header []byte
+}
+
+func (c *listenPacketConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	packet := prefix(c.header, b)
+	defer packet.Release()
+	_, err := c.PacketConn.WriteTo(packet.Bytes(), addr)
+	if err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func prefix(header []byte, b []byte) *buf.Buffer {
+	packet := buf.NewSize(len(header) + len(b))
+	common.Must1(packet.Write(header))
+	common.Must1(packet.Write(b))
+	return packet
+}
@@ -0,0 +1,85 @@
+package proxyproto
+
+import (
+	"encoding/binary"
+	"net/netip"
+
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+// Signature is the first 12 bytes of a PROXY protocol v2 header.
+var Signature = []byte{0x0D, 0x0A, 0x0D, 0x0A, 0x00, 0x0D, 0x0A, 0x51, 0x55, 0x49, 0x54, 0x0A}
+
+const (
+	version2     = 0x20
+	commandLocal = 0x00
+	commandProxy = 0x01
+
+	familyUnspec = 0x00
+	familyInet   = 0x10
+	familyInet6  = 0x20
+
+	protocolUnspec = 0x00
+	protocolStream = 0x01
+	protocolDgram  = 0x02
+)
+
+// Header returns the PROXY protocol v2 header describing a connection from
+// source to destination, where destination is the address the client
+// connected to. Addresses are mapped to IPv6 if the families differ.
+func Header(network string, source M.Socksaddr, destination M.Socksaddr) ([]byte, error) {
+	var protocol byte
+	switch N.NetworkName(network) {
+	case N.NetworkTCP:
+		protocol = protocolStream
+	case N.NetworkUDP:
+		protocol = protocolDgram
+	default:
+		return nil, E.New("unsupported network: ", network)
+	}
+	source = source.Unwrap()
+	destination = destination.Unwrap()
+	if !source.IsIP() || !destination.IsIP() {
+		return nil, E.New("PROXY protocol requires IP addresses")
+	}
+	var (
+		family          byte
+		sourceAddr      []byte
+		destinationAddr []byte
+	)
+	if source.Addr.Is4() && destination.Addr.Is4() {
+		family = familyInet
+		sourceAddr = source.Addr.AsSlice()
+		destinationAddr = destination.Addr.AsSlice()
+	} else {
+		family = familyInet6
+		sourceAddr = as16(source.Addr)
+		destinationAddr = as16(destination.Addr)
+	}
+	addressLen := len(sourceAddr) + len(destinationAddr) + 4
+	header := make([]byte, 0, len(Signature)+4+addressLen)
+	header = append(header, Signature...)
+	header = append(header, version2|commandProxy, family|protocol)
+	header = binary.BigEndian.AppendUint16(header, uint16(addressLen))
+	header = append(header, sourceAddr...)
+	header = append(header, destinationAddr...)
+	header = binary.BigEndian.AppendUint16(header, source.Port)
+	header = binary.BigEndian.AppendUint16(header, destination.Port)
+	return header, nil
+}
+
+// LocalHeader returns the PROXY protocol v2 header for connections not
+// relayed on behalf of a client, such as health checks.
+func LocalHeader() []byte {
+	header := make([]byte, 0, len(Signature)+4)
+	header = append(header, Signature...)
+	header = append(header, version2|commandLocal, familyUnspec|protocolUnspec)
+	return binary.BigEndian.AppendUint16(header, 0)
+}
+
+func as16(addr netip.Addr) []byte {
+	addr16 := addr.As16()
+	return addr16[:]
+}
@@ -0,0 +1,80 @@
+package replay
+
+import (
+	"hash/maphash"
+	"math"
+	"sync"
+
+	"github.com/sagernet/sing/common/replay"
+)
+
+var _ replay.Filter = (*BloomFilter)(nil)
+
+// BloomFilter is a ping-pong bloom filter: when the active filter is full,
+// the older one is cleared and becomes active, so that the most recent
+// entries are always remembered.
+type BloomFilter struct {
+	access   sync.Mutex
+	seed     maphash.Seed
+	filters  [2][]uint64
+	current  int
+	count    int
+	capacity int
+	bits     uint64
+	hashes   int
+}
+
+func NewBloom(capacity int, falsePositiveRate float64) *BloomFilter {
+	bits := uint64(math.Ceil(-float64(capacity) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2)))
+	hashes := int(math.Round(float64(bits) / float64(capacity) * math.Ln2))
+	if hashes < 1 {
+		hashes = 1
+	}
+	words := (bits + 63) / 64
+	return &BloomFilter{
+		seed:     maphash.MakeSeed(),
+		filters:  [2][]uint64{make([]uint64, words), make([]uint64, words)},
+		capacity: capacity,
+		bits:     words * 64,
+		hashes:   hashes,
+	}
+}
+
+// Check reports whether sum was not seen before, and records it.
+func (f *BloomFilter) Check(sum []byte) bool {
+	var hash maphash.Hash
+	hash.SetSeed(f.seed)
+	hash.Write(sum)
+	value := hash.Sum64()
+	h1, h2 := value&math.MaxUint32, value>>32|1
+	f.access.Lock()
+	defer f.access.Unlock()
+	if f.contains(f.filters[0], h1, h2) || f.contains(f.filters[1], h1, h2) {
+		return false
+	}
+	if f.count >= f.capacity {
+		f.current ^= 1
+		filter := f.filters[f.current]
+		for i := range filter {
+			filter[i] = 0
+		}
+		f.count = 0
+	}
+	filter := f.filters[f.current]
+	for i := 0; i < f.hashes; i++ {
+		index := (h1 + uint64(i)*h2) % f.bits
+		filter[index/64] |= 1 << (index % 64)
+	}
+	f.count++
+	return true
+}
+
+func (f *BloomFilter) contains(filter []uint64, h1 uint64, h2 uint64) bool {
+	for i := 0; i < f.hashes; i++ {
+		index := (h1 + uint64(i)*h2) % f.bits
+		if filter[index/64]&(1<<(index%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
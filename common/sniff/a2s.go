@@ -0,0 +1,48 @@
+package sniff
+
+import (
+	"bytes"
+	"context"
+	"os"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+)
+
+var a2sInfoPayload = []byte("Source Engine Query\x00")
+
+// A2S detects the server queries of Steam and Source engine games.
+func A2S(_ context.Context, metadata *adapter.InboundContext, packet []byte) error {
+	const (
+		headerSize      = 5
+		challengeLength = 4
+	)
+	if len(packet) < headerSize || !bytes.Equal(packet[:4], []byte{0xFF, 0xFF, 0xFF, 0xFF}) {
+		return os.ErrInvalid
+	}
+	payload := packet[headerSize:]
+	switch packet[4] {
+	case 'T':
+		// A2S_INFO, with a challenge since 2020
+		if !bytes.HasPrefix(payload, a2sInfoPayload) {
+			return os.ErrInvalid
+		}
+		if extra := len(payload) - len(a2sInfoPayload); extra != 0 && extra != challengeLength {
+			return os.ErrInvalid
+		}
+	case 'U', 'V':
+		// A2S_PLAYER and A2S_RULES
+		if len(payload) != challengeLength {
+			return os.ErrInvalid
+		}
+	case 'W', 'i':
+		// A2S_SERVERQUERY_GETCHALLENGE and A2A_PING
+		if len(payload) != 0 {
+			return os.ErrInvalid
+		}
+	default:
+		return os.ErrInvalid
+	}
+	metadata.Protocol = C.ProtocolA2S
+	return nil
+}
@@ -0,0 +1,41 @@
+package sniff_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/sniff"
+	C "github.com/sagernet/sing-box/constant"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSniffA2S(t *testing.T) {
+	t.Parallel()
+	for _, query := range []string{
+		"\xff\xff\xff\xffTSource Engine Query\x00",
+		"\xff\xff\xff\xffTSource Engine Query\x00\x4b\xa1\x0c\x5a",
+		"\xff\xff\xff\xffU\xff\xff\xff\xff",
+		"\xff\xff\xff\xffV\x4b\xa1\x0c\x5a",
+		"\xff\xff\xff\xffi",
+	} {
+		var metadata adapter.InboundContext
+		err := sniff.A2S(context.Background(), &metadata, []byte(query))
+		require.NoError(t, err)
+		require.Equal(t, C.ProtocolA2S, metadata.Protocol)
+	}
+}
+
+func TestSniffNotA2S(t *testing.T) {
+	t.Parallel()
+	for _, packet := range []string{
+		"\xff\xff\xff\xffTSource Engine Quer",
+		"\xff\xff\xff\xffU\xff\xff",
+		"\xfe\xff\xff\xffi",
+	} {
+		var metadata adapter.InboundContext
+		err := sniff.A2S(context.Background(), &metadata, []byte(packet))
+		require.Error(t, err)
+	}
+}
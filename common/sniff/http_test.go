@@ -28,3 +28,14 @@ func TestSniffHTTP1WithPort(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, metadata.Domain, "www.gov.cn")
 }
+
+func TestSniffHTTP1Request(t *testing.T) {
+	t.Parallel()
+	pkt := "POST /v1/telemetry?id=1 HTTP/1.1\r\nHost: example.com\r\nUser-Agent: Updater/2.0\r\n\r\n"
+	var metadata adapter.InboundContext
+	err := sniff.HTTPHost(context.Background(), &metadata, strings.NewReader(pkt))
+	require.NoError(t, err)
+	require.Equal(t, "POST", metadata.HTTPMethod)
+	require.Equal(t, "/v1/telemetry", metadata.HTTPPath)
+	require.Equal(t, "Updater/2.0", metadata.HTTPUserAgent)
+}
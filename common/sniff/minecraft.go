@@ -0,0 +1,96 @@
+package sniff
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+	M "github.com/sagernet/sing/common/metadata"
+)
+
+var minecraftLegacyPing = []byte{0xFE, 0x01, 0xFA, 0x00, 0x0B, 0x00, 'M', 0x00, 'C', 0x00, '|', 0x00, 'P', 0x00, 'i', 0x00, 'n', 0x00, 'g', 0x00, 'H', 0x00, 'o', 0x00, 's', 0x00, 't'}
+
+// Minecraft detects the handshake of Minecraft: Java Edition and the server
+// list ping of versions before 1.7, the server address of the handshake is
+// reported as the domain.
+func Minecraft(_ context.Context, metadata *adapter.InboundContext, reader io.Reader) error {
+	const (
+		maxAddressLength = 32767
+		maxPacketLength  = maxAddressLength + 16
+	)
+	byteReader := bufio.NewReader(reader)
+	header, err := byteReader.Peek(len(minecraftLegacyPing))
+	if err == nil && bytes.Equal(header, minecraftLegacyPing) {
+		metadata.Protocol = C.ProtocolMinecraft
+		return nil
+	}
+	packetLength, err := readMinecraftVarInt(byteReader)
+	if err != nil {
+		return err
+	}
+	if packetLength == 0 || packetLength > maxPacketLength {
+		return os.ErrInvalid
+	}
+	packet := make([]byte, packetLength)
+	_, err = io.ReadFull(byteReader, packet)
+	if err != nil {
+		return err
+	}
+	packetReader := bytes.NewReader(packet)
+	packetID, err := readMinecraftVarInt(packetReader)
+	if err != nil || packetID != 0x00 {
+		return os.ErrInvalid
+	}
+	_, err = readMinecraftVarInt(packetReader)
+	if err != nil {
+		return os.ErrInvalid
+	}
+	addressLength, err := readMinecraftVarInt(packetReader)
+	if err != nil || addressLength == 0 || addressLength > maxAddressLength || int(addressLength) > packetReader.Len() {
+		return os.ErrInvalid
+	}
+	address := make([]byte, addressLength)
+	_, err = io.ReadFull(packetReader, address)
+	if err != nil || !utf8.Valid(address) {
+		return os.ErrInvalid
+	}
+	var port uint16
+	err = binary.Read(packetReader, binary.BigEndian, &port)
+	if err != nil {
+		return os.ErrInvalid
+	}
+	nextState, err := readMinecraftVarInt(packetReader)
+	if err != nil || nextState < 1 || nextState > 3 || packetReader.Len() != 0 {
+		return os.ErrInvalid
+	}
+	metadata.Protocol = C.ProtocolMinecraft
+	// mod loaders and proxies append their data after a NUL character
+	host, _, _ := strings.Cut(string(address), "\x00")
+	host = strings.TrimSuffix(host, ".")
+	if M.IsDomainName(host) {
+		metadata.Domain = host
+	}
+	return nil
+}
+
+func readMinecraftVarInt(reader io.ByteReader) (uint32, error) {
+	var value uint32
+	for i := 0; i < 5; i++ {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		value |= uint32(b&0x7F) << (7 * i)
+		if b&0x80 == 0 {
+			return value, nil
+		}
+	}
+	return 0, os.ErrInvalid
+}
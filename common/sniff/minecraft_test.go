@@ -0,0 +1,59 @@
+package sniff_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"testing"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/sniff"
+	C "github.com/sagernet/sing-box/constant"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSniffMinecraft(t *testing.T) {
+	t.Parallel()
+	for _, handshake := range []string{
+		// handshake to mc.example.com:25565 followed by a status request
+		"1500ff050e6d632e6578616d706c652e636f6d63dd010100",
+		// handshake with a Forge marker appended to the server address
+		"1b00ff05146d632e6578616d706c652e636f6d00464d4c330063dd02",
+	} {
+		packet, err := hex.DecodeString(handshake)
+		require.NoError(t, err)
+		var metadata adapter.InboundContext
+		err = sniff.Minecraft(context.Background(), &metadata, bytes.NewReader(packet))
+		require.NoError(t, err)
+		require.Equal(t, C.ProtocolMinecraft, metadata.Protocol)
+		require.Equal(t, "mc.example.com", metadata.Domain)
+	}
+}
+
+func TestSniffMinecraftLegacyPing(t *testing.T) {
+	t.Parallel()
+	packet, err := hex.DecodeString("fe01fa000b004d0043007c00500069006e00670048006f00730074")
+	require.NoError(t, err)
+	var metadata adapter.InboundContext
+	err = sniff.Minecraft(context.Background(), &metadata, bytes.NewReader(packet))
+	require.NoError(t, err)
+	require.Equal(t, C.ProtocolMinecraft, metadata.Protocol)
+}
+
+func TestSniffIncompleteMinecraft(t *testing.T) {
+	t.Parallel()
+	packet, err := hex.DecodeString("1500ff050e6d632e6578616d")
+	require.NoError(t, err)
+	var metadata adapter.InboundContext
+	err = sniff.Minecraft(context.Background(), &metadata, bytes.NewReader(packet))
+	require.Error(t, err)
+	require.Empty(t, metadata.Protocol)
+}
+
+func TestSniffNotMinecraft(t *testing.T) {
+	t.Parallel()
+	var metadata adapter.InboundContext
+	err := sniff.Minecraft(context.Background(), &metadata, bytes.NewReader([]byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n")))
+	require.Error(t, err)
+}
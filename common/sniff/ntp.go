@@ -0,0 +1,35 @@
+package sniff
+
+import (
+	"context"
+	"os"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+)
+
+func NTP(_ context.Context, metadata *adapter.InboundContext, packet []byte) error {
+	// NTP packets are 48 bytes, optionally followed by extension fields and a MAC
+	const headerSize = 48
+	if len(packet) < headerSize || (len(packet)-headerSize)%4 != 0 {
+		return os.ErrInvalid
+	}
+	version := (packet[0] >> 3) & 0x07
+	if version < 3 || version > 4 {
+		return os.ErrInvalid
+	}
+	mode := packet[0] & 0x07
+	switch mode {
+	case 3:
+		// client
+	case 4:
+		// server
+		if packet[1] > 16 {
+			return os.ErrInvalid
+		}
+	default:
+		return os.ErrInvalid
+	}
+	metadata.Protocol = C.ProtocolNTP
+	return nil
+}
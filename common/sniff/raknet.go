@@ -0,0 +1,48 @@
+package sniff
+
+import (
+	"bytes"
+	"context"
+	"os"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+)
+
+var rakNetOfflineMessageID = []byte{0x00, 0xFF, 0xFF, 0x00, 0xFE, 0xFE, 0xFE, 0xFE, 0xFD, 0xFD, 0xFD, 0xFD, 0x12, 0x34, 0x56, 0x78}
+
+// RakNet detects the unconnected pings and connection requests of RakNet,
+// used by Minecraft: Bedrock Edition and other games.
+func RakNet(_ context.Context, metadata *adapter.InboundContext, packet []byte) error {
+	const (
+		unconnectedPing          = 0x01
+		unconnectedPingOpen      = 0x02
+		openConnectionRequest1   = 0x05
+		openConnectionRequest2   = 0x07
+		unconnectedPingLength    = 1 + 8 + 16 + 8
+		openConnectionHeaderSize = 1 + 16
+	)
+	if len(packet) == 0 {
+		return os.ErrInvalid
+	}
+	var magic []byte
+	switch packet[0] {
+	case unconnectedPing, unconnectedPingOpen:
+		if len(packet) != unconnectedPingLength {
+			return os.ErrInvalid
+		}
+		magic = packet[9:25]
+	case openConnectionRequest1, openConnectionRequest2:
+		if len(packet) <= openConnectionHeaderSize {
+			return os.ErrInvalid
+		}
+		magic = packet[1:17]
+	default:
+		return os.ErrInvalid
+	}
+	if !bytes.Equal(magic, rakNetOfflineMessageID) {
+		return os.ErrInvalid
+	}
+	metadata.Protocol = C.ProtocolRakNet
+	return nil
+}
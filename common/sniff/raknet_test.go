@@ -0,0 +1,39 @@
+package sniff_test
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/sniff"
+	C "github.com/sagernet/sing-box/constant"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSniffRakNet(t *testing.T) {
+	t.Parallel()
+	for _, message := range []string{
+		// unconnected ping
+		"01000000000000303900ffff00fefefefefdfdfdfd123456780102030405060708",
+		// open connection request 1
+		"0500ffff00fefefefefdfdfdfd123456780b0000000000000000",
+	} {
+		packet, err := hex.DecodeString(message)
+		require.NoError(t, err)
+		var metadata adapter.InboundContext
+		err = sniff.RakNet(context.Background(), &metadata, packet)
+		require.NoError(t, err)
+		require.Equal(t, C.ProtocolRakNet, metadata.Protocol)
+	}
+}
+
+func TestSniffNotRakNet(t *testing.T) {
+	t.Parallel()
+	packet, err := hex.DecodeString("01000000000000303900ffff00fefefefefdfdfdfd000000000102030405060708")
+	require.NoError(t, err)
+	var metadata adapter.InboundContext
+	err = sniff.RakNet(context.Background(), &metadata, packet)
+	require.Error(t, err)
+}
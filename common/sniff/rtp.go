@@ -0,0 +1,69 @@
+package sniff
+
+import (
+	"context"
+	"encoding/binary"
+	"os"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+)
+
+// RTP detects RTP and RTCP packets by the fixed header fields.
+//
+// The check is heuristic since RTP has no magic number, so it is not enabled by default.
+func RTP(_ context.Context, metadata *adapter.InboundContext, packet []byte) error {
+	const (
+		rtcpHeaderSize = 8
+		headerSize     = 12
+	)
+	if len(packet) < rtcpHeaderSize || packet[0]>>6 != 2 {
+		return os.ErrInvalid
+	}
+	packetType := packet[1]
+	if packetType >= 200 && packetType <= 204 {
+		// RTCP: SR, RR, SDES, BYE and APP
+		length := (int(binary.BigEndian.Uint16(packet[2:4])) + 1) * 4
+		if length > len(packet) {
+			return os.ErrInvalid
+		}
+		metadata.Protocol = C.ProtocolRTP
+		return nil
+	}
+	if len(packet) < headerSize {
+		return os.ErrInvalid
+	}
+	payloadType := packetType & 0x7f
+	if payloadType < 96 && !rtpStaticPayloadType(payloadType) {
+		return os.ErrInvalid
+	}
+	headerLength := headerSize + int(packet[0]&0x0f)*4
+	if packet[0]&0x10 != 0 {
+		if len(packet) < headerLength+4 {
+			return os.ErrInvalid
+		}
+		headerLength += 4 + int(binary.BigEndian.Uint16(packet[headerLength+2:headerLength+4]))*4
+	}
+	if packet[0]&0x20 != 0 {
+		if len(packet) <= headerLength {
+			return os.ErrInvalid
+		}
+		paddingLength := int(packet[len(packet)-1])
+		if paddingLength == 0 || headerLength+paddingLength > len(packet) {
+			return os.ErrInvalid
+		}
+	} else if len(packet) < headerLength {
+		return os.ErrInvalid
+	}
+	metadata.Protocol = C.ProtocolRTP
+	return nil
+}
+
+func rtpStaticPayloadType(payloadType uint8) bool {
+	switch payloadType {
+	case 0, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 25, 26, 28, 31, 32, 33, 34:
+		return true
+	default:
+		return false
+	}
+}
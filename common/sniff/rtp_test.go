@@ -0,0 +1,33 @@
+package sniff_test
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/sniff"
+	C "github.com/sagernet/sing-box/constant"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSniffRTP(t *testing.T) {
+	t.Parallel()
+	packet, err := hex.DecodeString("8000e3a1000a3c405e2d1c3bd5d4d7d6d1d0d3d2dddcdfded9d8dbdac5c4c7c6c1c0c3c2")
+	require.NoError(t, err)
+	var metadata adapter.InboundContext
+	err = sniff.RTP(context.Background(), &metadata, packet)
+	require.NoError(t, err)
+	require.Equal(t, C.ProtocolRTP, metadata.Protocol)
+}
+
+func TestSniffRTCP(t *testing.T) {
+	t.Parallel()
+	packet, err := hex.DecodeString("80c900015e2d1c3b")
+	require.NoError(t, err)
+	var metadata adapter.InboundContext
+	err = sniff.RTP(context.Background(), &metadata, packet)
+	require.NoError(t, err)
+	require.Equal(t, C.ProtocolRTP, metadata.Protocol)
+}
@@ -0,0 +1,66 @@
+package sniff
+
+import (
+	"bytes"
+	"context"
+	"os"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+)
+
+var sipMethods = [][]byte{
+	[]byte("INVITE"),
+	[]byte("ACK"),
+	[]byte("BYE"),
+	[]byte("CANCEL"),
+	[]byte("REGISTER"),
+	[]byte("OPTIONS"),
+	[]byte("PRACK"),
+	[]byte("SUBSCRIBE"),
+	[]byte("NOTIFY"),
+	[]byte("PUBLISH"),
+	[]byte("INFO"),
+	[]byte("REFER"),
+	[]byte("MESSAGE"),
+	[]byte("UPDATE"),
+}
+
+var sipVersion = []byte("SIP/2.0")
+
+func SIP(_ context.Context, metadata *adapter.InboundContext, packet []byte) error {
+	lineEnd := bytes.Index(packet, []byte("\r\n"))
+	if lineEnd < 0 {
+		return os.ErrInvalid
+	}
+	line := packet[:lineEnd]
+	if bytes.HasPrefix(line, sipVersion) {
+		// Status-Line: SIP/2.0 200 OK
+		if len(line) < len(sipVersion)+4 || line[len(sipVersion)] != ' ' {
+			return os.ErrInvalid
+		}
+		for _, char := range line[len(sipVersion)+1 : len(sipVersion)+4] {
+			if char < '0' || char > '9' {
+				return os.ErrInvalid
+			}
+		}
+	} else {
+		// Request-Line: INVITE sip:user@example.com SIP/2.0
+		methodEnd := bytes.IndexByte(line, ' ')
+		if methodEnd < 0 || !bytes.HasSuffix(line, append([]byte(" "), sipVersion...)) {
+			return os.ErrInvalid
+		}
+		var isMethod bool
+		for _, method := range sipMethods {
+			if bytes.Equal(line[:methodEnd], method) {
+				isMethod = true
+				break
+			}
+		}
+		if !isMethod {
+			return os.ErrInvalid
+		}
+	}
+	metadata.Protocol = C.ProtocolSIP
+	return nil
+}
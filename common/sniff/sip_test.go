@@ -0,0 +1,32 @@
+package sniff_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/sniff"
+	C "github.com/sagernet/sing-box/constant"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSniffSIP(t *testing.T) {
+	t.Parallel()
+	for _, message := range []string{
+		"INVITE sip:bob@biloxi.com SIP/2.0\r\nVia: SIP/2.0/UDP pc33.atlanta.com;branch=z9hG4bK776asdhds\r\n\r\n",
+		"SIP/2.0 180 Ringing\r\nVia: SIP/2.0/UDP pc33.atlanta.com;branch=z9hG4bK776asdhds\r\n\r\n",
+	} {
+		var metadata adapter.InboundContext
+		err := sniff.SIP(context.Background(), &metadata, []byte(message))
+		require.NoError(t, err)
+		require.Equal(t, C.ProtocolSIP, metadata.Protocol)
+	}
+}
+
+func TestSniffNotSIP(t *testing.T) {
+	t.Parallel()
+	var metadata adapter.InboundContext
+	err := sniff.SIP(context.Background(), &metadata, []byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n"))
+	require.Error(t, err)
+}
@@ -0,0 +1,41 @@
+package sniff
+
+import (
+	"context"
+	"os"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+)
+
+// Syslog detects BSD (RFC 3164) and IETF (RFC 5424) syslog messages by the priority prefix.
+func Syslog(_ context.Context, metadata *adapter.InboundContext, packet []byte) error {
+	if len(packet) < 4 || packet[0] != '<' {
+		return os.ErrInvalid
+	}
+	var (
+		priority int
+		index    = 1
+	)
+	for ; index < len(packet) && index <= 4; index++ {
+		char := packet[index]
+		if char == '>' {
+			break
+		}
+		if char < '0' || char > '9' {
+			return os.ErrInvalid
+		}
+		priority = priority*10 + int(char-'0')
+	}
+	if index == 1 || index > 4 || index+1 >= len(packet) || packet[index] != '>' || priority > 191 {
+		return os.ErrInvalid
+	}
+	if packet[1] == '0' && index > 2 {
+		return os.ErrInvalid
+	}
+	if char := packet[index+1]; char < 0x20 || char > 0x7e {
+		return os.ErrInvalid
+	}
+	metadata.Protocol = C.ProtocolSyslog
+	return nil
+}
@@ -0,0 +1,38 @@
+package sniff_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/sniff"
+	C "github.com/sagernet/sing-box/constant"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSniffSyslog(t *testing.T) {
+	t.Parallel()
+	for _, message := range []string{
+		"<34>Oct 11 22:14:15 mymachine su: 'su root' failed for lonvick on /dev/pts/8",
+		"<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 - An application event",
+	} {
+		var metadata adapter.InboundContext
+		err := sniff.Syslog(context.Background(), &metadata, []byte(message))
+		require.NoError(t, err)
+		require.Equal(t, C.ProtocolSyslog, metadata.Protocol)
+	}
+}
+
+func TestSniffNotSyslog(t *testing.T) {
+	t.Parallel()
+	for _, message := range []string{
+		"<192>Oct 11 22:14:15 mymachine",
+		"<034>Oct 11 22:14:15 mymachine",
+		"<html>",
+	} {
+		var metadata adapter.InboundContext
+		err := sniff.Syslog(context.Background(), &metadata, []byte(message))
+		require.Error(t, err, message)
+	}
+}
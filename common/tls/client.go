@@ -4,6 +4,7 @@ import (
 	"context"
 	"net"
 	"os"
+	"time"
 
 	"github.com/sagernet/sing-box/adapter"
 	"github.com/sagernet/sing-box/common/badtls"
@@ -41,19 +42,31 @@ func NewClient(ctx context.Context, serverAddress string, options option.Outboun
 }
 
 func ClientHandshake(ctx context.Context, conn net.Conn, config Config) (Conn, error) {
-	ctx, cancel := context.WithTimeout(ctx, C.TCPTimeout)
+	handshakeTimeout := C.TCPTimeout
+	if timeoutConfig, isTimeoutConfig := config.(interface{ HandshakeTimeout() time.Duration }); isTimeoutConfig && timeoutConfig.HandshakeTimeout() > 0 {
+		handshakeTimeout = timeoutConfig.HandshakeTimeout()
+	}
+	ctx, cancel := context.WithTimeout(ctx, handshakeTimeout)
 	defer cancel()
+	conn = newReadBufferConn(conn, config)
+	txGuard := newKernelTXGuard(conn, config)
+	if txGuard != nil {
+		conn = txGuard
+	}
 	tlsConn, err := aTLS.ClientHandshake(ctx, conn, config)
 	if err != nil {
 		return nil, err
 	}
+	if txGuard != nil {
+		txGuard.offload(tlsConn)
+	}
 	readWaitConn, err := badtls.NewReadWaitConn(tlsConn)
 	if err == nil {
-		return readWaitConn, nil
+		tlsConn = readWaitConn
 	} else if err != os.ErrInvalid {
 		return nil, err
 	}
-	return tlsConn, nil
+	return wrapKernelTX(tlsConn, txGuard), nil
 }
 
 type Dialer struct {
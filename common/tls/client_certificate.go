@@ -0,0 +1,200 @@
+package tls
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/service"
+)
+
+const certificatePathsCheckInterval = 10 * time.Second
+
+// clientRootCAs returns the root certificates configured for the TLS client,
+// or the pool of the certificate store if not set.
+// A nil pool means the system roots.
+func clientRootCAs(ctx context.Context, options option.OutboundTLSOptions) (*x509.CertPool, error) {
+	if len(options.CertificatePaths) > 0 && (len(options.Certificate) > 0 || options.CertificatePath != "") {
+		return nil, E.New("`certificate_paths` is conflict with `certificate` and `certificate_path`")
+	}
+	var certificate []byte
+	if len(options.Certificate) > 0 {
+		certificate = []byte(strings.Join(options.Certificate, "\n"))
+	} else if options.CertificatePath != "" {
+		content, err := os.ReadFile(options.CertificatePath)
+		if err != nil {
+			return nil, E.Cause(err, "read certificate")
+		}
+		certificate = content
+	}
+	if len(certificate) > 0 {
+		certPool := x509.NewCertPool()
+		if !certPool.AppendCertsFromPEM(certificate) {
+			return nil, E.New("failed to parse certificate:\n\n", certificate)
+		}
+		return certPool, nil
+	}
+	certificateStore := service.FromContext[adapter.CertificateStore](ctx)
+	if certificateStore != nil {
+		return certificateStore.Pool(), nil
+	}
+	return nil, nil
+}
+
+func verifyPeerCertificates(peerCertificates []*x509.Certificate, serverName string, roots *x509.CertPool) error {
+	if len(peerCertificates) == 0 {
+		return E.New("missing peer certificates")
+	}
+	verifyOptions := x509.VerifyOptions{
+		Roots:         roots,
+		DNSName:       serverName,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range peerCertificates[1:] {
+		verifyOptions.Intermediates.AddCert(cert)
+	}
+	_, err := peerCertificates[0].Verify(verifyOptions)
+	return err
+}
+
+// clientPublicKeyPins decodes the pinned SHA-256 hashes of the subject public
+// key info of the server certificate.
+func clientPublicKeyPins(options option.OutboundTLSOptions) ([][]byte, error) {
+	var pins [][]byte
+	for _, value := range options.CertificatePublicKeySHA256 {
+		pin, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, E.Cause(err, "decode certificate_public_key_sha256: ", value)
+		}
+		if len(pin) != sha256.Size {
+			return nil, E.New("invalid certificate_public_key_sha256: ", value)
+		}
+		pins = append(pins, pin)
+	}
+	return pins, nil
+}
+
+// verifyPublicKeyPins checks the public key of the server certificate against
+// the pins. Only the leaf is checked, since the rest of the chain is sent by the
+// peer and is not necessarily verified.
+func verifyPublicKeyPins(pins [][]byte, peerCertificates []*x509.Certificate, serverName string) error {
+	if len(peerCertificates) == 0 {
+		return E.New("missing peer certificates")
+	}
+	publicKeyHash := sha256.Sum256(peerCertificates[0].RawSubjectPublicKeyInfo)
+	for _, pin := range pins {
+		if bytes.Equal(pin, publicKeyHash[:]) {
+			return nil
+		}
+	}
+	return E.New("certificate public key of ", serverName, " does not match any pin, got ", base64.StdEncoding.EncodeToString(publicKeyHash[:]))
+}
+
+// certificatePathsLoader loads root certificates from files and directories,
+// and reloads them on use if any file has been changed.
+type certificatePathsLoader struct {
+	paths     []string
+	access    sync.Mutex
+	pool      *x509.CertPool
+	modTime   time.Time
+	fileCount int
+	lastCheck time.Time
+}
+
+func newCertificatePathsLoader(paths []string) (*certificatePathsLoader, error) {
+	loader := &certificatePathsLoader{paths: paths}
+	files, modTime, err := loader.listFiles()
+	if err != nil {
+		return nil, err
+	}
+	err = loader.load(files, modTime)
+	if err != nil {
+		return nil, err
+	}
+	loader.lastCheck = time.Now()
+	return loader, nil
+}
+
+func (l *certificatePathsLoader) Pool() *x509.CertPool {
+	l.access.Lock()
+	defer l.access.Unlock()
+	if time.Since(l.lastCheck) < certificatePathsCheckInterval {
+		return l.pool
+	}
+	l.lastCheck = time.Now()
+	files, modTime, err := l.listFiles()
+	if err != nil || len(files) == l.fileCount && !modTime.After(l.modTime) {
+		return l.pool
+	}
+	// keep the previous certificates on error
+	_ = l.load(files, modTime)
+	return l.pool
+}
+
+func (l *certificatePathsLoader) listFiles() ([]string, time.Time, error) {
+	var (
+		files   []string
+		modTime time.Time
+	)
+	for _, path := range l.paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			if info.ModTime().After(modTime) {
+				modTime = info.ModTime()
+			}
+			continue
+		}
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		for _, entry := range entries {
+			filePath := filepath.Join(path, entry.Name())
+			fileInfo, err := os.Stat(filePath)
+			if err != nil || fileInfo.IsDir() {
+				continue
+			}
+			files = append(files, filePath)
+			if fileInfo.ModTime().After(modTime) {
+				modTime = fileInfo.ModTime()
+			}
+		}
+	}
+	return files, modTime, nil
+}
+
+func (l *certificatePathsLoader) load(files []string, modTime time.Time) error {
+	certPool := x509.NewCertPool()
+	var loaded bool
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return E.Cause(err, "read certificate from ", file)
+		}
+		// non-certificate files in directories are ignored
+		if certPool.AppendCertsFromPEM(content) {
+			loaded = true
+		}
+	}
+	if !loaded {
+		return E.New("no certificates found in ", strings.Join(l.paths, ", "))
+	}
+	l.pool = certPool
+	l.modTime = modTime
+	l.fileCount = len(files)
+	return nil
+}
@@ -5,13 +5,13 @@ package tls
 import (
 	"context"
 	"crypto/tls"
-	"crypto/x509"
 	"encoding/base64"
 	"encoding/pem"
 	"net"
 	"net/netip"
 	"os"
 	"strings"
+	"time"
 
 	cftls "github.com/sagernet/cloudflare-tls"
 	"github.com/sagernet/sing-box/adapter"
@@ -25,7 +25,9 @@ import (
 )
 
 type echClientConfig struct {
-	config *cftls.Config
+	config           *cftls.Config
+	handshakeTimeout time.Duration
+	readBufferSize   int
 }
 
 func (c *echClientConfig) ServerName() string {
@@ -52,9 +54,19 @@ func (c *echClientConfig) Client(conn net.Conn) (Conn, error) {
 	return &echConnWrapper{cftls.Client(conn, c.config)}, nil
 }
 
+func (c *echClientConfig) HandshakeTimeout() time.Duration {
+	return c.handshakeTimeout
+}
+
+func (c *echClientConfig) ReadBufferSize() int {
+	return c.readBufferSize
+}
+
 func (c *echClientConfig) Clone() Config {
 	return &echClientConfig{
-		config: c.config.Clone(),
+		config:           c.config.Clone(),
+		handshakeTimeout: c.handshakeTimeout,
+		readBufferSize:   c.readBufferSize,
 	}
 }
 
@@ -105,20 +117,44 @@ func NewECHClient(ctx context.Context, serverAddress string, options option.Outb
 	} else {
 		tlsConfig.ServerName = serverName
 	}
+	rootCAs, err := clientRootCAs(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig.RootCAs = rootCAs
+	publicKeyPins, err := clientPublicKeyPins(options)
+	if err != nil {
+		return nil, err
+	}
+	var certificateLoader *certificatePathsLoader
+	if len(options.CertificatePaths) > 0 {
+		certificateLoader, err = newCertificatePathsLoader(options.CertificatePaths)
+		if err != nil {
+			return nil, err
+		}
+	}
 	if options.Insecure {
 		tlsConfig.InsecureSkipVerify = options.Insecure
-	} else if options.DisableSNI {
+	} else if options.DisableSNI || certificateLoader != nil {
 		tlsConfig.InsecureSkipVerify = true
 		tlsConfig.VerifyConnection = func(state cftls.ConnectionState) error {
-			verifyOptions := x509.VerifyOptions{
-				DNSName:       serverName,
-				Intermediates: x509.NewCertPool(),
+			roots := rootCAs
+			if certificateLoader != nil {
+				roots = certificateLoader.Pool()
 			}
-			for _, cert := range state.PeerCertificates[1:] {
-				verifyOptions.Intermediates.AddCert(cert)
+			return verifyPeerCertificates(state.PeerCertificates, serverName, roots)
+		}
+	}
+	if len(publicKeyPins) > 0 {
+		verifyConnection := tlsConfig.VerifyConnection
+		tlsConfig.VerifyConnection = func(state cftls.ConnectionState) error {
+			if verifyConnection != nil {
+				err := verifyConnection(state)
+				if err != nil {
+					return err
+				}
 			}
-			_, err := state.PeerCertificates[0].Verify(verifyOptions)
-			return err
+			return verifyPublicKeyPins(publicKeyPins, state.PeerCertificates, serverName)
 		}
 	}
 	if len(options.ALPN) > 0 {
@@ -150,23 +186,6 @@ func NewECHClient(ctx context.Context, serverAddress string, options option.Outb
 			return nil, E.New("unknown cipher_suite: ", cipherSuite)
 		}
 	}
-	var certificate []byte
-	if len(options.Certificate) > 0 {
-		certificate = []byte(strings.Join(options.Certificate, "\n"))
-	} else if options.CertificatePath != "" {
-		content, err := os.ReadFile(options.CertificatePath)
-		if err != nil {
-			return nil, E.Cause(err, "read certificate")
-		}
-		certificate = content
-	}
-	if len(certificate) > 0 {
-		certPool := x509.NewCertPool()
-		if !certPool.AppendCertsFromPEM(certificate) {
-			return nil, E.New("failed to parse certificate:\n\n", certificate)
-		}
-		tlsConfig.RootCAs = certPool
-	}
 
 	// ECH Config
 
@@ -198,7 +217,7 @@ func NewECHClient(ctx context.Context, serverAddress string, options option.Outb
 	} else {
 		tlsConfig.GetClientECHConfigs = fetchECHClientConfig(ctx)
 	}
-	return &echClientConfig{&tlsConfig}, nil
+	return &echClientConfig{&tlsConfig, adapter.TimeoutsFromContext(ctx).TLSHandshake, int(options.ReadBufferSize)}, nil
 }
 
 func fetchECHClientConfig(ctx context.Context) func(_ context.Context, serverName string) ([]cftls.ECHConfig, error) {
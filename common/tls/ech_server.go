@@ -9,6 +9,8 @@ import (
 	"net"
 	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	cftls "github.com/sagernet/cloudflare-tls"
 	"github.com/sagernet/fswatch"
@@ -16,17 +18,24 @@ import (
 	"github.com/sagernet/sing-box/option"
 	E "github.com/sagernet/sing/common/exceptions"
 	"github.com/sagernet/sing/common/ntp"
+	"github.com/sagernet/sing/common/x/list"
+	"github.com/sagernet/sing/service"
 )
 
 type echServerConfig struct {
 	config          *cftls.Config
 	logger          log.Logger
+	access          sync.Mutex
 	certificate     []byte
 	key             []byte
 	certificatePath string
 	keyPath         string
+	keyPair         atomic.Pointer[cftls.Certificate]
 	echKeyPath      string
 	watcher         *fswatch.Watcher
+	reloader        *CertificateReloader
+	reloaderElement *list.Element[certificateReloadable]
+	readBufferSize  int
 }
 
 func (c *echServerConfig) ServerName() string {
@@ -57,13 +66,21 @@ func (c *echServerConfig) Server(conn net.Conn) (Conn, error) {
 	return &echConnWrapper{cftls.Server(conn, c.config)}, nil
 }
 
+func (c *echServerConfig) ReadBufferSize() int {
+	return c.readBufferSize
+}
+
 func (c *echServerConfig) Clone() Config {
 	return &echServerConfig{
-		config: c.config.Clone(),
+		config:         c.config.Clone(),
+		readBufferSize: c.readBufferSize,
 	}
 }
 
 func (c *echServerConfig) Start() error {
+	if c.reloader != nil && (c.certificatePath != "" || c.keyPath != "") {
+		c.reloaderElement = c.reloader.register(c)
+	}
 	err := c.startWatcher()
 	if err != nil {
 		c.logger.Warn("create credentials watcher: ", err)
@@ -107,25 +124,7 @@ func (c *echServerConfig) startWatcher() error {
 
 func (c *echServerConfig) credentialsUpdated(path string) error {
 	if path == c.certificatePath || path == c.keyPath {
-		if path == c.certificatePath {
-			certificate, err := os.ReadFile(c.certificatePath)
-			if err != nil {
-				return err
-			}
-			c.certificate = certificate
-		} else {
-			key, err := os.ReadFile(c.keyPath)
-			if err != nil {
-				return err
-			}
-			c.key = key
-		}
-		keyPair, err := cftls.X509KeyPair(c.certificate, c.key)
-		if err != nil {
-			return E.Cause(err, "parse key pair")
-		}
-		c.config.Certificates = []cftls.Certificate{keyPair}
-		c.logger.Info("reloaded TLS certificate")
+		return c.reloadCertificate()
 	} else {
 		echKeyContent, err := os.ReadFile(c.echKeyPath)
 		if err != nil {
@@ -149,8 +148,42 @@ func (c *echServerConfig) credentialsUpdated(path string) error {
 	return nil
 }
 
+func (c *echServerConfig) reloadCertificate() error {
+	c.access.Lock()
+	defer c.access.Unlock()
+	if c.certificatePath != "" {
+		certificate, err := os.ReadFile(c.certificatePath)
+		if err != nil {
+			return E.Cause(err, "reload certificate from ", c.certificatePath)
+		}
+		c.certificate = certificate
+	}
+	if c.keyPath != "" {
+		key, err := os.ReadFile(c.keyPath)
+		if err != nil {
+			return E.Cause(err, "reload key from ", c.keyPath)
+		}
+		c.key = key
+	}
+	keyPair, err := cftls.X509KeyPair(c.certificate, c.key)
+	if err != nil {
+		return E.Cause(err, "parse key pair")
+	}
+	c.keyPair.Store(&keyPair)
+	c.logger.Info("reloaded TLS certificate")
+	return nil
+}
+
+func (c *echServerConfig) getCertificate(*cftls.ClientHelloInfo) (*cftls.Certificate, error) {
+	return c.keyPair.Load(), nil
+}
+
 func (c *echServerConfig) Close() error {
 	var err error
+	if c.reloaderElement != nil {
+		c.reloader.unregister(c.reloaderElement)
+		c.reloaderElement = nil
+	}
 	if c.watcher != nil {
 		err = E.Append(err, c.watcher.Close(), func(err error) error {
 			return E.Cause(err, "close credentials watcher")
@@ -231,7 +264,6 @@ func NewECHServer(ctx context.Context, logger log.Logger, options option.Inbound
 	if err != nil {
 		return nil, E.Cause(err, "parse x509 key pair")
 	}
-	tlsConfig.Certificates = []cftls.Certificate{keyPair}
 
 	var echKey []byte
 	if len(options.ECH.Key) > 0 {
@@ -266,7 +298,7 @@ func NewECHServer(ctx context.Context, logger log.Logger, options option.Inbound
 	tlsConfig.DynamicRecordSizingDisabled = options.ECH.DynamicRecordSizingDisabled
 	tlsConfig.ServerECHProvider = echKeySet
 
-	return &echServerConfig{
+	serverConfig := &echServerConfig{
 		config:          &tlsConfig,
 		logger:          logger,
 		certificate:     certificate,
@@ -274,5 +306,10 @@ func NewECHServer(ctx context.Context, logger log.Logger, options option.Inbound
 		certificatePath: options.CertificatePath,
 		keyPath:         options.KeyPath,
 		echKeyPath:      options.ECH.KeyPath,
-	}, nil
+		reloader:        service.FromContext[*CertificateReloader](ctx),
+		readBufferSize:  int(options.ReadBufferSize),
+	}
+	serverConfig.keyPair.Store(&keyPair)
+	tlsConfig.GetCertificate = serverConfig.getCertificate
+	return serverConfig, nil
 }
@@ -0,0 +1,283 @@
+//go:build go1.21 && !without_badtls
+
+package tls
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"io"
+	"net"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	N "github.com/sagernet/sing/common/network"
+	aTLS "github.com/sagernet/sing/common/tls"
+
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/sys/unix"
+)
+
+// linux/tls.h
+const (
+	tlsTX                = 1
+	tlsSetRecordType     = 1
+	tlsVersion13         = 0x0304
+	tlsCipherAESGCM128   = 51
+	tlsCipherAESGCM256   = 52
+	tlsCipherChaCha20    = 54
+	tlsRecordTypeAlert   = 21
+	tlsAlertCloseNotify  = 0
+	tlsAlertLevelWarning = 1
+)
+
+var errKernelTX = E.New("kernel TLS: records are encrypted by the kernel")
+
+// kernelTXGuard sits between crypto/tls and the connection. Once the kernel
+// encrypts outgoing records, records still produced by crypto/tls, such as
+// alerts or key update responses, would be encrypted twice and are refused.
+type kernelTXGuard struct {
+	net.Conn
+	offloaded atomic.Bool
+}
+
+func newKernelTXGuard(conn net.Conn, config any) *kernelTXGuard {
+	kernelConfig, isKernelConfig := config.(interface{ KernelTX() bool })
+	if !isKernelConfig || !kernelConfig.KernelTX() {
+		return nil
+	}
+	return &kernelTXGuard{Conn: conn}
+}
+
+func (c *kernelTXGuard) Write(p []byte) (n int, err error) {
+	if c.offloaded.Load() {
+		return 0, errKernelTX
+	}
+	return c.Conn.Write(p)
+}
+
+// offload hands the write half of a TLS 1.3 connection to the kernel. The read
+// half stays in crypto/tls, records following the handshake are usually
+// already buffered there. Connections that can not be offloaded are left as
+// is.
+func (c *kernelTXGuard) offload(conn aTLS.Conn) bool {
+	stdConn, isSTDConn := conn.(*tls.Conn)
+	if !isSTDConn {
+		return false
+	}
+	tcpConn, isTCPConn := N.CastWriter[*net.TCPConn](c.Conn)
+	if !isTCPConn {
+		return false
+	}
+	err := writeCryptoInfo(stdConn, func(cryptoInfo []byte) error {
+		return control(tcpConn, func(fd int) error {
+			err := unix.SetsockoptString(fd, unix.IPPROTO_TCP, unix.TCP_ULP, "tls")
+			if err != nil {
+				return err
+			}
+			return unix.SetsockoptString(fd, unix.SOL_TLS, tlsTX, string(cryptoInfo))
+		})
+	})
+	if err != nil {
+		return false
+	}
+	c.offloaded.Store(true)
+	return true
+}
+
+// writeCryptoInfo calls install with the crypto info of the write half of
+// conn, which is locked meanwhile.
+func writeCryptoInfo(conn *tls.Conn, install func(cryptoInfo []byte) error) error {
+	state := conn.ConnectionState()
+	if state.Version != tls.VersionTLS13 {
+		return E.New("kernel TLS: unsupported TLS version")
+	}
+	rawHalfConn := reflect.Indirect(reflect.ValueOf(conn)).FieldByName("out")
+	if !rawHalfConn.IsValid() || rawHalfConn.Kind() != reflect.Struct {
+		return E.New("kernel TLS: invalid half conn")
+	}
+	rawHalfMutex := rawHalfConn.FieldByName("Mutex")
+	if !rawHalfMutex.IsValid() || rawHalfMutex.Kind() != reflect.Struct {
+		return E.New("kernel TLS: invalid half mutex")
+	}
+	rawSeq := rawHalfConn.FieldByName("seq")
+	if !rawSeq.IsValid() || rawSeq.Kind() != reflect.Array || rawSeq.Len() != 8 {
+		return E.New("kernel TLS: invalid seq")
+	}
+	rawTrafficSecret := rawHalfConn.FieldByName("trafficSecret")
+	if !rawTrafficSecret.IsValid() || rawTrafficSecret.Kind() != reflect.Slice {
+		return E.New("kernel TLS: invalid traffic secret")
+	}
+	halfAccess := (*sync.Mutex)(unsafe.Pointer(rawHalfMutex.UnsafeAddr()))
+	halfAccess.Lock()
+	defer halfAccess.Unlock()
+	cryptoInfo, err := kernelCryptoInfo(state.CipherSuite,
+		*(*[]byte)(unsafe.Pointer(rawTrafficSecret.UnsafeAddr())),
+		*(*[8]byte)(unsafe.Pointer(rawSeq.UnsafeAddr())))
+	if err != nil {
+		return err
+	}
+	return install(cryptoInfo)
+}
+
+// kernelCryptoInfo builds the tls12_crypto_info_* structure of the kernel for
+// the write secret of a TLS 1.3 connection.
+func kernelCryptoInfo(cipherSuite uint16, trafficSecret []byte, seq [8]byte) ([]byte, error) {
+	var (
+		hashFunc   func() hash.Hash
+		cipherType uint16
+		keyLen     int
+	)
+	switch cipherSuite {
+	case tls.TLS_AES_128_GCM_SHA256:
+		hashFunc, cipherType, keyLen = sha256.New, tlsCipherAESGCM128, 16
+	case tls.TLS_AES_256_GCM_SHA384:
+		hashFunc, cipherType, keyLen = sha512.New384, tlsCipherAESGCM256, 32
+	case tls.TLS_CHACHA20_POLY1305_SHA256:
+		hashFunc, cipherType, keyLen = sha256.New, tlsCipherChaCha20, 32
+	default:
+		return nil, E.New("kernel TLS: unsupported cipher suite: ", tls.CipherSuiteName(cipherSuite))
+	}
+	if len(trafficSecret) == 0 {
+		return nil, E.New("kernel TLS: missing traffic secret")
+	}
+	key, err := hkdfExpandLabel(hashFunc, trafficSecret, "key", keyLen)
+	if err != nil {
+		return nil, err
+	}
+	iv, err := hkdfExpandLabel(hashFunc, trafficSecret, "iv", 12)
+	if err != nil {
+		return nil, err
+	}
+	cryptoInfo := common.NativeEndian.AppendUint16(nil, tlsVersion13)
+	cryptoInfo = common.NativeEndian.AppendUint16(cryptoInfo, cipherType)
+	if cipherType == tlsCipherChaCha20 {
+		// iv[12] key[32] rec_seq[8]
+		cryptoInfo = append(cryptoInfo, iv...)
+		cryptoInfo = append(cryptoInfo, key...)
+	} else {
+		// iv[8] key[] salt[4] rec_seq[8], the salt is the fixed part of the nonce
+		cryptoInfo = append(cryptoInfo, iv[4:]...)
+		cryptoInfo = append(cryptoInfo, key...)
+		cryptoInfo = append(cryptoInfo, iv[:4]...)
+	}
+	cryptoInfo = append(cryptoInfo, seq[:]...)
+	return cryptoInfo, nil
+}
+
+// hkdfExpandLabel implements HKDF-Expand-Label of RFC 8446 with an empty
+// context.
+func hkdfExpandLabel(hashFunc func() hash.Hash, secret []byte, label string, length int) ([]byte, error) {
+	hkdfLabel := binary.BigEndian.AppendUint16(nil, uint16(length))
+	hkdfLabel = append(hkdfLabel, byte(len("tls13 ")+len(label)))
+	hkdfLabel = append(hkdfLabel, "tls13 "...)
+	hkdfLabel = append(hkdfLabel, label...)
+	hkdfLabel = append(hkdfLabel, 0)
+	output := make([]byte, length)
+	_, err := io.ReadFull(hkdf.Expand(hashFunc, secret, hkdfLabel), output)
+	if err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+func control(conn *net.TCPConn, fn func(fd int) error) error {
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var innerErr error
+	err = rawConn.Control(func(fd uintptr) {
+		innerErr = fn(int(fd))
+	})
+	if err != nil {
+		return err
+	}
+	return innerErr
+}
+
+func wrapKernelTX(conn aTLS.Conn, guard *kernelTXGuard) aTLS.Conn {
+	if guard == nil || !guard.offloaded.Load() {
+		return conn
+	}
+	return &kernelTXConn{Conn: conn, guard: guard}
+}
+
+// kernelTXConn writes plaintext to the connection encrypted by the kernel,
+// which also allows copies to use sendfile and splice.
+type kernelTXConn struct {
+	aTLS.Conn
+	guard           *kernelTXGuard
+	closeNotifySent atomic.Bool
+}
+
+func (c *kernelTXConn) Write(p []byte) (n int, err error) {
+	return c.guard.Conn.Write(p)
+}
+
+func (c *kernelTXConn) CloseWrite() error {
+	return c.closeNotify()
+}
+
+func (c *kernelTXConn) Close() error {
+	c.closeNotify()
+	err := c.Conn.Close()
+	if errors.Is(err, errKernelTX) {
+		return nil
+	}
+	return err
+}
+
+// closeNotify sends the close_notify alert as a record of the alert type.
+func (c *kernelTXConn) closeNotify() error {
+	if c.closeNotifySent.Swap(true) {
+		return nil
+	}
+	tcpConn, _ := N.CastWriter[*net.TCPConn](c.guard.Conn)
+	rawConn, err := tcpConn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	cmsg := make([]byte, unix.CmsgSpace(1))
+	header := (*unix.Cmsghdr)(unsafe.Pointer(&cmsg[0]))
+	header.Level = unix.SOL_TLS
+	header.Type = tlsSetRecordType
+	header.SetLen(unix.CmsgLen(1))
+	cmsg[unix.CmsgLen(0)] = tlsRecordTypeAlert
+	var innerErr error
+	err = rawConn.Write(func(fd uintptr) bool {
+		innerErr = unix.Sendmsg(int(fd), []byte{tlsAlertLevelWarning, tlsAlertCloseNotify}, cmsg, nil, 0)
+		return innerErr != unix.EAGAIN
+	})
+	if err != nil {
+		return err
+	}
+	return innerErr
+}
+
+func (c *kernelTXConn) Upstream() any {
+	return c.Conn
+}
+
+func (c *kernelTXConn) UpstreamReader() any {
+	return c.Conn
+}
+
+func (c *kernelTXConn) ReaderReplaceable() bool {
+	return true
+}
+
+func (c *kernelTXConn) UpstreamWriter() any {
+	return c.guard.Conn
+}
+
+func (c *kernelTXConn) WriterReplaceable() bool {
+	return true
+}
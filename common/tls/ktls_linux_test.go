@@ -0,0 +1,168 @@
+//go:build go1.21 && !without_badtls
+
+package tls
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sagernet/sing/common"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+func TestHKDFExpandLabel(t *testing.T) {
+	t.Parallel()
+	// RFC 8448, Simple 1-RTT Handshake, server handshake traffic keys
+	secret, _ := hex.DecodeString("b67b7d690cc16c4e75e54213cb2d37b4e9c912bcded9105d42befd59d391ad38")
+	key, err := hkdfExpandLabel(sha256.New, secret, "key", 16)
+	require.NoError(t, err)
+	require.Equal(t, "3fce516009c21727d0f2e4e86ee403bc", hex.EncodeToString(key))
+	iv, err := hkdfExpandLabel(sha256.New, secret, "iv", 12)
+	require.NoError(t, err)
+	require.Equal(t, "5d313eb2671276ee13000b30", hex.EncodeToString(iv))
+}
+
+func TestKernelCryptoInfo(t *testing.T) {
+	t.Parallel()
+	secret, _ := hex.DecodeString("b67b7d690cc16c4e75e54213cb2d37b4e9c912bcded9105d42befd59d391ad38")
+	seq := [8]byte{0, 0, 0, 0, 0, 0, 0, 1}
+	cryptoInfo, err := kernelCryptoInfo(tls.TLS_AES_128_GCM_SHA256, secret, seq)
+	require.NoError(t, err)
+	require.Len(t, cryptoInfo, 40)
+	require.Equal(t, "13000b30", hex.EncodeToString(cryptoInfo[4:12])[8:])
+	require.Equal(t, "3fce516009c21727d0f2e4e86ee403bc", hex.EncodeToString(cryptoInfo[12:28]))
+	require.Equal(t, "5d313eb2", hex.EncodeToString(cryptoInfo[28:32]))
+	require.Equal(t, seq[:], cryptoInfo[32:])
+	cryptoInfo, err = kernelCryptoInfo(tls.TLS_CHACHA20_POLY1305_SHA256, secret, seq)
+	require.NoError(t, err)
+	require.Len(t, cryptoInfo, 56)
+	require.Equal(t, "5d313eb2671276ee13000b30", hex.EncodeToString(cryptoInfo[4:16]))
+	_, err = kernelCryptoInfo(tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, secret, seq)
+	require.Error(t, err)
+}
+
+// TestWriteCryptoInfo encrypts a record with the crypto info like the kernel
+// and checks that the peer accepts it.
+func TestWriteCryptoInfo(t *testing.T) {
+	t.Parallel()
+	certificate, err := GenerateCertificate(time.Now, "example.org")
+	require.NoError(t, err)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	serverDone := make(chan error, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			serverDone <- err
+			return
+		}
+		defer conn.Close()
+		tlsConn := tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{*certificate}})
+		message := make([]byte, 4)
+		_, err = io.ReadFull(tlsConn, message)
+		if err == nil && string(message) != "ping" {
+			err = io.ErrUnexpectedEOF
+		}
+		serverDone <- err
+	}()
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	tlsConn := tls.Client(conn, &tls.Config{ServerName: "example.org", InsecureSkipVerify: true})
+	require.NoError(t, tlsConn.Handshake())
+	var cryptoInfo []byte
+	require.NoError(t, writeCryptoInfo(tlsConn, func(info []byte) error {
+		cryptoInfo = info
+		return nil
+	}))
+	var (
+		aead  cipher.AEAD
+		nonce []byte
+	)
+	switch common.NativeEndian.Uint16(cryptoInfo[2:]) {
+	case tlsCipherAESGCM128, tlsCipherAESGCM256:
+		keyLen := len(cryptoInfo) - 24
+		block, err := aes.NewCipher(cryptoInfo[12 : 12+keyLen])
+		require.NoError(t, err)
+		aead, err = cipher.NewGCM(block)
+		require.NoError(t, err)
+		nonce = append(append([]byte{}, cryptoInfo[12+keyLen:16+keyLen]...), cryptoInfo[4:12]...)
+	case tlsCipherChaCha20:
+		aead, err = chacha20poly1305.New(cryptoInfo[16:48])
+		require.NoError(t, err)
+		nonce = append([]byte{}, cryptoInfo[4:16]...)
+	}
+	seq := cryptoInfo[len(cryptoInfo)-8:]
+	for i := range seq {
+		nonce[4+i] ^= seq[i]
+	}
+	plaintext := []byte("ping\x17")
+	record := []byte{0x17, 0x03, 0x03, 0, 0}
+	binary.BigEndian.PutUint16(record[3:], uint16(len(plaintext)+aead.Overhead()))
+	record = aead.Seal(record, nonce, plaintext, record)
+	_, err = conn.Write(record)
+	require.NoError(t, err)
+	require.NoError(t, <-serverDone)
+}
+
+func TestKernelTX(t *testing.T) {
+	t.Parallel()
+	certificate, err := GenerateCertificate(time.Now, "example.org")
+	require.NoError(t, err)
+	serverConfig := &STDServerConfig{
+		config:   &tls.Config{Certificates: []tls.Certificate{*certificate}},
+		kernelTX: true,
+	}
+	clientConfig := &STDClientConfig{
+		config:   &tls.Config{ServerName: "example.org", InsecureSkipVerify: true},
+		kernelTX: true,
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	serverDone := make(chan error, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			serverDone <- err
+			return
+		}
+		tlsConn, err := ServerHandshake(context.Background(), conn, serverConfig)
+		if err != nil {
+			conn.Close()
+			serverDone <- err
+			return
+		}
+		_, err = io.Copy(tlsConn, io.LimitReader(tlsConn, 4))
+		if err == nil {
+			err = tlsConn.Close()
+		}
+		serverDone <- err
+	}()
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	require.NoError(t, err)
+	tlsConn, err := ClientHandshake(context.Background(), conn, clientConfig)
+	require.NoError(t, err)
+	defer tlsConn.Close()
+	_, err = tlsConn.Write([]byte("ping"))
+	require.NoError(t, err)
+	response, err := io.ReadAll(tlsConn)
+	require.NoError(t, err)
+	require.Equal(t, "ping", string(response))
+	require.NoError(t, <-serverDone)
+	if _, isKernelTX := tlsConn.(*kernelTXConn); !isKernelTX {
+		t.Skip("kernel TLS unavailable")
+	}
+}
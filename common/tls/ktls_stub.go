@@ -0,0 +1,25 @@
+//go:build !linux || !go1.21 || without_badtls
+
+package tls
+
+import (
+	"net"
+
+	aTLS "github.com/sagernet/sing/common/tls"
+)
+
+type kernelTXGuard struct {
+	net.Conn
+}
+
+func newKernelTXGuard(conn net.Conn, config any) *kernelTXGuard {
+	return nil
+}
+
+func (c *kernelTXGuard) offload(conn aTLS.Conn) bool {
+	return false
+}
+
+func wrapKernelTX(conn aTLS.Conn, guard *kernelTXGuard) aTLS.Conn {
+	return conn
+}
@@ -0,0 +1,43 @@
+package tls
+
+import (
+	"bufio"
+	"net"
+)
+
+// newReadBufferConn buffers reads from the underlying connection when
+// read_buffer_size is set, so that a read call can return several small
+// records to the TLS record layer.
+func newReadBufferConn(conn net.Conn, config any) net.Conn {
+	bufferConfig, isBufferConfig := config.(interface{ ReadBufferSize() int })
+	if !isBufferConfig || bufferConfig.ReadBufferSize() <= 0 {
+		return conn
+	}
+	return &readBufferConn{
+		Conn:   conn,
+		reader: bufio.NewReaderSize(conn, bufferConfig.ReadBufferSize()),
+	}
+}
+
+type readBufferConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *readBufferConn) Read(p []byte) (n int, err error) {
+	return c.reader.Read(p)
+}
+
+func (c *readBufferConn) Upstream() any {
+	return c.Conn
+}
+
+// ReaderReplaceable allows copies to read the underlying connection
+// directly once no buffered data is left.
+func (c *readBufferConn) ReaderReplaceable() bool {
+	return c.reader.Buffered() == 0
+}
+
+func (c *readBufferConn) WriterReplaceable() bool {
+	return true
+}
@@ -49,6 +49,9 @@ func NewRealityClient(ctx context.Context, serverAddress string, options option.
 	if options.UTLS == nil || !options.UTLS.Enabled {
 		return nil, E.New("uTLS is required by reality client")
 	}
+	if len(options.CertificatePublicKeySHA256) > 0 {
+		return nil, E.New("certificate_public_key_sha256 is unsupported in reality")
+	}
 
 	uClient, err := NewUTLSClient(ctx, serverAddress, options)
 	if err != nil {
@@ -105,6 +108,7 @@ func (e *RealityClientConfig) ClientHandshake(ctx context.Context, conn net.Conn
 	uConfig.InsecureSkipVerify = true
 	uConfig.SessionTicketsDisabled = true
 	uConfig.VerifyPeerCertificate = verifier.VerifyPeerCertificate
+	uConfig.VerifyConnection = nil
 	uConn := utls.UClient(conn, uConfig, e.uClient.id)
 	verifier.UConn = uConn
 	err := uConn.BuildHandshakeState()
@@ -211,6 +215,14 @@ func (e *RealityClientConfig) SetSessionIDGenerator(generator func(clientHello [
 	e.uClient.config.SessionIDGenerator = generator
 }
 
+func (e *RealityClientConfig) HandshakeTimeout() time.Duration {
+	return e.uClient.HandshakeTimeout()
+}
+
+func (e *RealityClientConfig) ReadBufferSize() int {
+	return e.uClient.ReadBufferSize()
+}
+
 func (e *RealityClientConfig) Clone() Config {
 	return &RealityClientConfig{
 		e.uClient.Clone().(*UTLSClientConfig),
@@ -24,7 +24,8 @@ import (
 var _ ServerConfigCompat = (*RealityServerConfig)(nil)
 
 type RealityServerConfig struct {
-	config *reality.Config
+	config         *reality.Config
+	readBufferSize int
 }
 
 func NewRealityServer(ctx context.Context, logger log.Logger, options option.InboundTLSOptions) (*RealityServerConfig, error) {
@@ -113,7 +114,7 @@ func NewRealityServer(ctx context.Context, logger log.Logger, options option.Inb
 		tlsConfig.Show = true
 	}
 
-	return &RealityServerConfig{&tlsConfig}, nil
+	return &RealityServerConfig{&tlsConfig, int(options.ReadBufferSize)}, nil
 }
 
 func (c *RealityServerConfig) ServerName() string {
@@ -160,9 +161,14 @@ func (c *RealityServerConfig) ServerHandshake(ctx context.Context, conn net.Conn
 	return &realityConnWrapper{Conn: tlsConn}, nil
 }
 
+func (c *RealityServerConfig) ReadBufferSize() int {
+	return c.readBufferSize
+}
+
 func (c *RealityServerConfig) Clone() Config {
 	return &RealityServerConfig{
-		config: c.config.Clone(),
+		config:         c.config.Clone(),
+		readBufferSize: c.readBufferSize,
 	}
 }
 
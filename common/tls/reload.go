@@ -0,0 +1,49 @@
+package tls
+
+import (
+	"sync"
+
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/x/list"
+)
+
+type certificateReloadable interface {
+	reloadCertificate() error
+}
+
+// CertificateReloader reloads certificate files of all started TLS servers
+// created with a context it is registered in.
+type CertificateReloader struct {
+	access  sync.Mutex
+	servers list.List[certificateReloadable]
+}
+
+func NewCertificateReloader() *CertificateReloader {
+	return &CertificateReloader{}
+}
+
+func (r *CertificateReloader) register(server certificateReloadable) *list.Element[certificateReloadable] {
+	r.access.Lock()
+	defer r.access.Unlock()
+	return r.servers.PushBack(server)
+}
+
+func (r *CertificateReloader) unregister(element *list.Element[certificateReloadable]) {
+	r.access.Lock()
+	defer r.access.Unlock()
+	r.servers.Remove(element)
+}
+
+func (r *CertificateReloader) Reload() error {
+	r.access.Lock()
+	servers := make([]certificateReloadable, 0, r.servers.Len())
+	for element := r.servers.Front(); element != nil; element = element.Next() {
+		servers = append(servers, element.Value)
+	}
+	r.access.Unlock()
+	var err error
+	for _, server := range servers {
+		err = E.Errors(err, server.reloadCertificate())
+	}
+	return err
+}
@@ -5,8 +5,8 @@ import (
 	"net"
 	"os"
 
+	"github.com/sagernet/sing-box/adapter"
 	"github.com/sagernet/sing-box/common/badtls"
-	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/log"
 	"github.com/sagernet/sing-box/option"
 	aTLS "github.com/sagernet/sing/common/tls"
@@ -26,17 +26,35 @@ func NewServer(ctx context.Context, logger log.Logger, options option.InboundTLS
 }
 
 func ServerHandshake(ctx context.Context, conn net.Conn, config ServerConfig) (Conn, error) {
-	ctx, cancel := context.WithTimeout(ctx, C.TCPTimeout)
+	ctx, cancel := context.WithTimeout(ctx, adapter.TimeoutsFromContext(ctx).TLSHandshake)
 	defer cancel()
+	conn = newReadBufferConn(conn, config)
+	txGuard := newKernelTXGuard(conn, config)
+	if txGuard != nil {
+		conn = txGuard
+	}
+	// the ClientHello is only recorded when the caller has inbound metadata to fill
+	metadata := adapter.ContextFrom(ctx)
+	var helloConn *clientHelloConn
+	if metadata != nil {
+		helloConn = &clientHelloConn{Conn: conn}
+		conn = helloConn
+	}
 	tlsConn, err := aTLS.ServerHandshake(ctx, conn, config)
 	if err != nil {
 		return nil, err
 	}
+	if helloConn != nil {
+		helloConn.fingerprint(metadata)
+	}
+	if txGuard != nil {
+		txGuard.offload(tlsConn)
+	}
 	readWaitConn, err := badtls.NewReadWaitConn(tlsConn)
 	if err == nil {
-		return readWaitConn, nil
+		tlsConn = readWaitConn
 	} else if err != os.ErrInvalid {
 		return nil, err
 	}
-	return tlsConn, nil
+	return wrapKernelTX(tlsConn, txGuard), nil
 }
@@ -0,0 +1,59 @@
+package tls
+
+import (
+	"encoding/binary"
+	"net"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/ja3"
+)
+
+const tlsRecordHeaderLen = 5
+
+// clientHelloConn records the first TLS record read by the server handshake,
+// which carries the ClientHello used for fingerprinting.
+type clientHelloConn struct {
+	net.Conn
+	record []byte
+	done   bool
+}
+
+func (c *clientHelloConn) Read(p []byte) (n int, err error) {
+	n, err = c.Conn.Read(p)
+	if !c.done && n > 0 {
+		c.record = append(c.record, p[:n]...)
+		if len(c.record) >= tlsRecordHeaderLen {
+			recordLen := tlsRecordHeaderLen + int(binary.BigEndian.Uint16(c.record[3:]))
+			if len(c.record) >= recordLen {
+				c.record = c.record[:recordLen]
+				c.done = true
+			}
+		}
+	}
+	return
+}
+
+func (c *clientHelloConn) fingerprint(metadata *adapter.InboundContext) {
+	if !c.done {
+		return
+	}
+	clientHello, err := ja3.Compute(c.record)
+	c.record = nil
+	if err != nil {
+		return
+	}
+	metadata.TLSFingerprintJA3 = clientHello.Hash()
+	metadata.TLSFingerprintJA4 = clientHello.JA4()
+}
+
+func (c *clientHelloConn) ReaderReplaceable() bool {
+	return c.done
+}
+
+func (c *clientHelloConn) WriterReplaceable() bool {
+	return true
+}
+
+func (c *clientHelloConn) Upstream() any {
+	return c.Conn
+}
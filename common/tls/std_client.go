@@ -3,19 +3,21 @@ package tls
 import (
 	"context"
 	"crypto/tls"
-	"crypto/x509"
 	"net"
 	"net/netip"
-	"os"
-	"strings"
+	"time"
 
+	"github.com/sagernet/sing-box/adapter"
 	"github.com/sagernet/sing-box/option"
 	E "github.com/sagernet/sing/common/exceptions"
 	"github.com/sagernet/sing/common/ntp"
 )
 
 type STDClientConfig struct {
-	config *tls.Config
+	config           *tls.Config
+	handshakeTimeout time.Duration
+	readBufferSize   int
+	kernelTX         bool
 }
 
 func (s *STDClientConfig) ServerName() string {
@@ -42,8 +44,20 @@ func (s *STDClientConfig) Client(conn net.Conn) (Conn, error) {
 	return tls.Client(conn, s.config), nil
 }
 
+func (s *STDClientConfig) HandshakeTimeout() time.Duration {
+	return s.handshakeTimeout
+}
+
+func (s *STDClientConfig) ReadBufferSize() int {
+	return s.readBufferSize
+}
+
+func (s *STDClientConfig) KernelTX() bool {
+	return s.kernelTX
+}
+
 func (s *STDClientConfig) Clone() Config {
-	return &STDClientConfig{s.config.Clone()}
+	return &STDClientConfig{s.config.Clone(), s.handshakeTimeout, s.readBufferSize, s.kernelTX}
 }
 
 func NewSTDClient(ctx context.Context, serverAddress string, options option.OutboundTLSOptions) (Config, error) {
@@ -66,20 +80,44 @@ func NewSTDClient(ctx context.Context, serverAddress string, options option.Outb
 	} else {
 		tlsConfig.ServerName = serverName
 	}
+	rootCAs, err := clientRootCAs(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig.RootCAs = rootCAs
+	publicKeyPins, err := clientPublicKeyPins(options)
+	if err != nil {
+		return nil, err
+	}
+	var certificateLoader *certificatePathsLoader
+	if len(options.CertificatePaths) > 0 {
+		certificateLoader, err = newCertificatePathsLoader(options.CertificatePaths)
+		if err != nil {
+			return nil, err
+		}
+	}
 	if options.Insecure {
 		tlsConfig.InsecureSkipVerify = options.Insecure
-	} else if options.DisableSNI {
+	} else if options.DisableSNI || certificateLoader != nil {
 		tlsConfig.InsecureSkipVerify = true
 		tlsConfig.VerifyConnection = func(state tls.ConnectionState) error {
-			verifyOptions := x509.VerifyOptions{
-				DNSName:       serverName,
-				Intermediates: x509.NewCertPool(),
+			roots := rootCAs
+			if certificateLoader != nil {
+				roots = certificateLoader.Pool()
 			}
-			for _, cert := range state.PeerCertificates[1:] {
-				verifyOptions.Intermediates.AddCert(cert)
+			return verifyPeerCertificates(state.PeerCertificates, serverName, roots)
+		}
+	}
+	if len(publicKeyPins) > 0 {
+		verifyConnection := tlsConfig.VerifyConnection
+		tlsConfig.VerifyConnection = func(state tls.ConnectionState) error {
+			if verifyConnection != nil {
+				err := verifyConnection(state)
+				if err != nil {
+					return err
+				}
 			}
-			_, err := state.PeerCertificates[0].Verify(verifyOptions)
-			return err
+			return verifyPublicKeyPins(publicKeyPins, state.PeerCertificates, serverName)
 		}
 	}
 	if len(options.ALPN) > 0 {
@@ -111,22 +149,5 @@ func NewSTDClient(ctx context.Context, serverAddress string, options option.Outb
 			return nil, E.New("unknown cipher_suite: ", cipherSuite)
 		}
 	}
-	var certificate []byte
-	if len(options.Certificate) > 0 {
-		certificate = []byte(strings.Join(options.Certificate, "\n"))
-	} else if options.CertificatePath != "" {
-		content, err := os.ReadFile(options.CertificatePath)
-		if err != nil {
-			return nil, E.Cause(err, "read certificate")
-		}
-		certificate = content
-	}
-	if len(certificate) > 0 {
-		certPool := x509.NewCertPool()
-		if !certPool.AppendCertsFromPEM(certificate) {
-			return nil, E.New("failed to parse certificate:\n\n", certificate)
-		}
-		tlsConfig.RootCAs = certPool
-	}
-	return &STDClientConfig{&tlsConfig}, nil
+	return &STDClientConfig{&tlsConfig, adapter.TimeoutsFromContext(ctx).TLSHandshake, int(options.ReadBufferSize), options.KernelTX}, nil
 }
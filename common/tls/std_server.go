@@ -6,6 +6,8 @@ import (
 	"net"
 	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/sagernet/fswatch"
 	"github.com/sagernet/sing-box/adapter"
@@ -14,6 +16,8 @@ import (
 	"github.com/sagernet/sing/common"
 	E "github.com/sagernet/sing/common/exceptions"
 	"github.com/sagernet/sing/common/ntp"
+	"github.com/sagernet/sing/common/x/list"
+	"github.com/sagernet/sing/service"
 )
 
 var errInsecureUnused = E.New("tls: insecure unused")
@@ -22,11 +26,17 @@ type STDServerConfig struct {
 	config          *tls.Config
 	logger          log.Logger
 	acmeService     adapter.Service
+	access          sync.Mutex
 	certificate     []byte
 	key             []byte
 	certificatePath string
 	keyPath         string
+	keyPair         atomic.Pointer[tls.Certificate]
 	watcher         *fswatch.Watcher
+	reloader        *CertificateReloader
+	reloaderElement *list.Element[certificateReloadable]
+	readBufferSize  int
+	kernelTX        bool
 }
 
 func (c *STDServerConfig) ServerName() string {
@@ -65,9 +75,19 @@ func (c *STDServerConfig) Server(conn net.Conn) (Conn, error) {
 	return tls.Server(conn, c.config), nil
 }
 
+func (c *STDServerConfig) ReadBufferSize() int {
+	return c.readBufferSize
+}
+
+func (c *STDServerConfig) KernelTX() bool {
+	return c.kernelTX
+}
+
 func (c *STDServerConfig) Clone() Config {
 	return &STDServerConfig{
-		config: c.config.Clone(),
+		config:         c.config.Clone(),
+		readBufferSize: c.readBufferSize,
+		kernelTX:       c.kernelTX,
 	}
 }
 
@@ -78,6 +98,9 @@ func (c *STDServerConfig) Start() error {
 		if c.certificatePath == "" && c.keyPath == "" {
 			return nil
 		}
+		if c.reloader != nil {
+			c.reloaderElement = c.reloader.register(c)
+		}
 		err := c.startWatcher()
 		if err != nil {
 			c.logger.Warn("create fsnotify watcher: ", err)
@@ -97,7 +120,7 @@ func (c *STDServerConfig) startWatcher() error {
 	watcher, err := fswatch.NewWatcher(fswatch.Options{
 		Path: watchPath,
 		Callback: func(path string) {
-			err := c.certificateUpdated(path)
+			err := c.reloadCertificate()
 			if err != nil {
 				c.logger.Error(err)
 			}
@@ -114,14 +137,17 @@ func (c *STDServerConfig) startWatcher() error {
 	return nil
 }
 
-func (c *STDServerConfig) certificateUpdated(path string) error {
-	if path == c.certificatePath {
+func (c *STDServerConfig) reloadCertificate() error {
+	c.access.Lock()
+	defer c.access.Unlock()
+	if c.certificatePath != "" {
 		certificate, err := os.ReadFile(c.certificatePath)
 		if err != nil {
 			return E.Cause(err, "reload certificate from ", c.certificatePath)
 		}
 		c.certificate = certificate
-	} else if path == c.keyPath {
+	}
+	if c.keyPath != "" {
 		key, err := os.ReadFile(c.keyPath)
 		if err != nil {
 			return E.Cause(err, "reload key from ", c.keyPath)
@@ -132,15 +158,25 @@ func (c *STDServerConfig) certificateUpdated(path string) error {
 	if err != nil {
 		return E.Cause(err, "reload key pair")
 	}
-	c.config.Certificates = []tls.Certificate{keyPair}
+	c.keyPair.Store(&keyPair)
 	c.logger.Info("reloaded TLS certificate")
 	return nil
 }
 
+// getCertificate serves the current key pair, configs cloned for QUIC
+// listeners share this callback and see reloaded certificates as well.
+func (c *STDServerConfig) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return c.keyPair.Load(), nil
+}
+
 func (c *STDServerConfig) Close() error {
 	if c.acmeService != nil {
 		return c.acmeService.Close()
 	}
+	if c.reloaderElement != nil {
+		c.reloader.unregister(c.reloaderElement)
+		c.reloaderElement = nil
+	}
 	if c.watcher != nil {
 		return c.watcher.Close()
 	}
@@ -199,6 +235,16 @@ func NewSTDServer(ctx context.Context, logger log.Logger, options option.Inbound
 			return nil, E.New("unknown cipher_suite: ", cipherSuite)
 		}
 	}
+	serverConfig := &STDServerConfig{
+		config:          tlsConfig,
+		logger:          logger,
+		acmeService:     acmeService,
+		certificatePath: options.CertificatePath,
+		keyPath:         options.KeyPath,
+		reloader:        service.FromContext[*CertificateReloader](ctx),
+		readBufferSize:  int(options.ReadBufferSize),
+		kernelTX:        options.KernelTX,
+	}
 	var certificate []byte
 	var key []byte
 	if acmeService == nil {
@@ -235,16 +281,11 @@ func NewSTDServer(ctx context.Context, logger log.Logger, options option.Inbound
 			if err != nil {
 				return nil, E.Cause(err, "parse x509 key pair")
 			}
-			tlsConfig.Certificates = []tls.Certificate{keyPair}
+			serverConfig.keyPair.Store(&keyPair)
+			tlsConfig.GetCertificate = serverConfig.getCertificate
 		}
 	}
-	return &STDServerConfig{
-		config:          tlsConfig,
-		logger:          logger,
-		acmeService:     acmeService,
-		certificate:     certificate,
-		key:             key,
-		certificatePath: options.CertificatePath,
-		keyPath:         options.KeyPath,
-	}, nil
+	serverConfig.certificate = certificate
+	serverConfig.key = key
+	return serverConfig, nil
 }
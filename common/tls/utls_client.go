@@ -5,13 +5,12 @@ package tls
 import (
 	"context"
 	"crypto/tls"
-	"crypto/x509"
 	"math/rand"
 	"net"
 	"net/netip"
-	"os"
-	"strings"
+	"time"
 
+	"github.com/sagernet/sing-box/adapter"
 	"github.com/sagernet/sing-box/option"
 	E "github.com/sagernet/sing/common/exceptions"
 	"github.com/sagernet/sing/common/ntp"
@@ -21,8 +20,10 @@ import (
 )
 
 type UTLSClientConfig struct {
-	config *utls.Config
-	id     utls.ClientHelloID
+	config           *utls.Config
+	id               utls.ClientHelloID
+	handshakeTimeout time.Duration
+	readBufferSize   int
 }
 
 func (e *UTLSClientConfig) ServerName() string {
@@ -56,10 +57,20 @@ func (e *UTLSClientConfig) SetSessionIDGenerator(generator func(clientHello []by
 	e.config.SessionIDGenerator = generator
 }
 
+func (e *UTLSClientConfig) HandshakeTimeout() time.Duration {
+	return e.handshakeTimeout
+}
+
+func (e *UTLSClientConfig) ReadBufferSize() int {
+	return e.readBufferSize
+}
+
 func (e *UTLSClientConfig) Clone() Config {
 	return &UTLSClientConfig{
-		config: e.config.Clone(),
-		id:     e.id,
+		config:           e.config.Clone(),
+		id:               e.id,
+		handshakeTimeout: e.handshakeTimeout,
+		readBufferSize:   e.readBufferSize,
 	}
 }
 
@@ -135,10 +146,43 @@ func NewUTLSClient(ctx context.Context, serverAddress string, options option.Out
 	} else {
 		tlsConfig.ServerName = serverName
 	}
+	rootCAs, err := clientRootCAs(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig.RootCAs = rootCAs
+	publicKeyPins, err := clientPublicKeyPins(options)
+	if err != nil {
+		return nil, err
+	}
+	var certificateLoader *certificatePathsLoader
+	if len(options.CertificatePaths) > 0 {
+		certificateLoader, err = newCertificatePathsLoader(options.CertificatePaths)
+		if err != nil {
+			return nil, err
+		}
+	}
 	if options.Insecure {
 		tlsConfig.InsecureSkipVerify = options.Insecure
 	} else if options.DisableSNI {
 		return nil, E.New("disable_sni is unsupported in uTLS")
+	} else if certificateLoader != nil {
+		tlsConfig.InsecureSkipVerify = true
+		tlsConfig.VerifyConnection = func(state utls.ConnectionState) error {
+			return verifyPeerCertificates(state.PeerCertificates, serverName, certificateLoader.Pool())
+		}
+	}
+	if len(publicKeyPins) > 0 {
+		verifyConnection := tlsConfig.VerifyConnection
+		tlsConfig.VerifyConnection = func(state utls.ConnectionState) error {
+			if verifyConnection != nil {
+				err := verifyConnection(state)
+				if err != nil {
+					return err
+				}
+			}
+			return verifyPublicKeyPins(publicKeyPins, state.PeerCertificates, serverName)
+		}
 	}
 	if len(options.ALPN) > 0 {
 		tlsConfig.NextProtos = options.ALPN
@@ -169,28 +213,11 @@ func NewUTLSClient(ctx context.Context, serverAddress string, options option.Out
 			return nil, E.New("unknown cipher_suite: ", cipherSuite)
 		}
 	}
-	var certificate []byte
-	if len(options.Certificate) > 0 {
-		certificate = []byte(strings.Join(options.Certificate, "\n"))
-	} else if options.CertificatePath != "" {
-		content, err := os.ReadFile(options.CertificatePath)
-		if err != nil {
-			return nil, E.Cause(err, "read certificate")
-		}
-		certificate = content
-	}
-	if len(certificate) > 0 {
-		certPool := x509.NewCertPool()
-		if !certPool.AppendCertsFromPEM(certificate) {
-			return nil, E.New("failed to parse certificate:\n\n", certificate)
-		}
-		tlsConfig.RootCAs = certPool
-	}
 	id, err := uTLSClientHelloID(options.UTLS.Fingerprint)
 	if err != nil {
 		return nil, err
 	}
-	return &UTLSClientConfig{&tlsConfig, id}, nil
+	return &UTLSClientConfig{&tlsConfig, id, adapter.TimeoutsFromContext(ctx).TLSHandshake, int(options.ReadBufferSize)}, nil
 }
 
 var (
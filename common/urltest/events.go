@@ -0,0 +1,62 @@
+package urltest
+
+import "time"
+
+// maxEvents limits the event journal, the oldest events are dropped first.
+const maxEvents = 1000
+
+const (
+	EventTypeSelected = "selected"
+	EventTypeURLTest  = "urltest"
+	EventTypeUp       = "up"
+	EventTypeDown     = "down"
+	EventTypeRotate   = "rotate"
+)
+
+// Event is a change of the outbound selected by a group, or of the
+// availability of an outbound found by URL tests.
+type Event struct {
+	Time     time.Time `json:"time"`
+	Type     string    `json:"type"`
+	Group    string    `json:"group,omitempty"`
+	Network  string    `json:"network,omitempty"`
+	Outbound string    `json:"outbound"`
+	Previous string    `json:"previous,omitempty"`
+	Reason   string    `json:"reason,omitempty"`
+}
+
+func (s *HistoryStorage) RecordEvent(event Event) {
+	if s == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	s.eventAccess.Lock()
+	defer s.eventAccess.Unlock()
+	if len(s.events) < maxEvents {
+		s.events = append(s.events, event)
+		return
+	}
+	s.events[s.eventIndex] = event
+	s.eventIndex = (s.eventIndex + 1) % maxEvents
+}
+
+// Events returns recorded events from the oldest to the newest.
+func (s *HistoryStorage) Events() []Event {
+	if s == nil {
+		return nil
+	}
+	s.eventAccess.Lock()
+	defer s.eventAccess.Unlock()
+	events := make([]Event, 0, len(s.events))
+	events = append(events, s.events[s.eventIndex:]...)
+	return append(events, s.events[:s.eventIndex]...)
+}
+
+func (s *HistoryStorage) ClearEvents() {
+	s.eventAccess.Lock()
+	defer s.eventAccess.Unlock()
+	s.events = nil
+	s.eventIndex = 0
+}
@@ -2,14 +2,18 @@ package urltest
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"net"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
 	"sync"
 	"time"
 
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing/common"
+	F "github.com/sagernet/sing/common/format"
 	M "github.com/sagernet/sing/common/metadata"
 	N "github.com/sagernet/sing/common/network"
 )
@@ -17,17 +21,34 @@ import (
 type History struct {
 	Time  time.Time `json:"time"`
 	Delay uint16    `json:"delay"`
+	Breakdown
+}
+
+// Breakdown is the time spent in each phase of a URL test, in milliseconds.
+// DNS only covers lookups done locally, such as resolving the server address
+// of the outbound, and Connect includes the outbound handshake unless it is
+// deferred to the first write.
+type Breakdown struct {
+	DNS       uint16 `json:"dns"`
+	Connect   uint16 `json:"connect"`
+	TLS       uint16 `json:"tls"`
+	FirstByte uint16 `json:"firstByte"`
 }
 
 type HistoryStorage struct {
 	access       sync.RWMutex
 	delayHistory map[string]*History
+	available    map[string]bool
 	updateHook   chan<- struct{}
+	eventAccess  sync.Mutex
+	events       []Event
+	eventIndex   int
 }
 
 func NewHistoryStorage() *HistoryStorage {
 	return &HistoryStorage{
 		delayHistory: make(map[string]*History),
+		available:    make(map[string]bool),
 	}
 }
 
@@ -45,16 +66,43 @@ func (s *HistoryStorage) LoadURLTestHistory(tag string) *History {
 }
 
 func (s *HistoryStorage) DeleteURLTestHistory(tag string) {
+	s.DeleteURLTestHistoryWithError(tag, nil)
+}
+
+// DeleteURLTestHistoryWithError deletes the history of an unavailable
+// outbound, and records the error as the reason if it was available.
+func (s *HistoryStorage) DeleteURLTestHistoryWithError(tag string, err error) {
 	s.access.Lock()
 	delete(s.delayHistory, tag)
+	available, loaded := s.available[tag]
+	s.available[tag] = false
 	s.access.Unlock()
+	if available || !loaded {
+		event := Event{
+			Type:     EventTypeDown,
+			Outbound: tag,
+		}
+		if err != nil {
+			event.Reason = err.Error()
+		}
+		s.RecordEvent(event)
+	}
 	s.notifyUpdated()
 }
 
 func (s *HistoryStorage) StoreURLTestHistory(tag string, history *History) {
 	s.access.Lock()
 	s.delayHistory[tag] = history
+	available, loaded := s.available[tag]
+	s.available[tag] = true
 	s.access.Unlock()
+	if loaded && !available {
+		s.RecordEvent(Event{
+			Type:     EventTypeUp,
+			Outbound: tag,
+			Reason:   F.ToString("delay ", history.Delay, "ms"),
+		})
+	}
 	s.notifyUpdated()
 }
 
@@ -73,7 +121,28 @@ func (s *HistoryStorage) Close() error {
 	return nil
 }
 
+type rootCAsKey struct{}
+
+// ContextWithRootCAs returns a context in which URL tests verify TLS servers
+// against pool instead of the system roots, a nil pool keeps the system roots.
+func ContextWithRootCAs(ctx context.Context, pool *x509.CertPool) context.Context {
+	if pool == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, rootCAsKey{}, pool)
+}
+
+func rootCAsFromContext(ctx context.Context) *x509.CertPool {
+	pool, _ := ctx.Value(rootCAsKey{}).(*x509.CertPool)
+	return pool
+}
+
 func URLTest(ctx context.Context, link string, detour N.Dialer) (t uint16, err error) {
+	t, _, err = URLTestBreakdown(ctx, link, detour)
+	return
+}
+
+func URLTestBreakdown(ctx context.Context, link string, detour N.Dialer) (t uint16, breakdown Breakdown, err error) {
 	if link == "" {
 		link = "https://www.gstatic.com/generate_204"
 	}
@@ -92,12 +161,15 @@ func URLTest(ctx context.Context, link string, detour N.Dialer) (t uint16, err e
 		}
 	}
 
+	var tracer tracer
+	ctx = httptrace.WithClientTrace(ctx, tracer.clientTrace())
 	start := time.Now()
 	instance, err := detour.DialContext(ctx, "tcp", M.ParseSocksaddrHostPortStr(hostname, port))
 	if err != nil {
 		return
 	}
 	defer instance.Close()
+	dial := time.Since(start)
 	if earlyConn, isEarlyConn := common.Cast[N.EarlyConn](instance); isEarlyConn && earlyConn.NeedHandshake() {
 		start = time.Now()
 	}
@@ -110,6 +182,9 @@ func URLTest(ctx context.Context, link string, detour N.Dialer) (t uint16, err e
 			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
 				return instance, nil
 			},
+			TLSClientConfig: &tls.Config{
+				RootCAs: rootCAsFromContext(ctx),
+			},
 		},
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			return http.ErrUseLastResponse
@@ -123,5 +198,74 @@ func URLTest(ctx context.Context, link string, detour N.Dialer) (t uint16, err e
 	}
 	resp.Body.Close()
 	t = uint16(time.Since(start) / time.Millisecond)
+	breakdown = tracer.breakdown(dial)
 	return
 }
+
+type tracer struct {
+	access       sync.Mutex
+	dnsDepth     int
+	dnsStart     time.Time
+	dns          time.Duration
+	tlsStart     time.Time
+	tls          time.Duration
+	wroteRequest time.Time
+	firstByte    time.Duration
+}
+
+func (t *tracer) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.access.Lock()
+			defer t.access.Unlock()
+			// lookups may be nested when DNS servers are resolved themselves
+			if t.dnsDepth == 0 {
+				t.dnsStart = time.Now()
+			}
+			t.dnsDepth++
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.access.Lock()
+			defer t.access.Unlock()
+			t.dnsDepth--
+			if t.dnsDepth == 0 {
+				t.dns += time.Since(t.dnsStart)
+			}
+		},
+		TLSHandshakeStart: func() {
+			t.access.Lock()
+			defer t.access.Unlock()
+			t.tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.access.Lock()
+			defer t.access.Unlock()
+			t.tls = time.Since(t.tlsStart)
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			t.access.Lock()
+			defer t.access.Unlock()
+			t.wroteRequest = time.Now()
+		},
+		GotFirstResponseByte: func() {
+			t.access.Lock()
+			defer t.access.Unlock()
+			t.firstByte = time.Since(t.wroteRequest)
+		},
+	}
+}
+
+func (t *tracer) breakdown(dial time.Duration) Breakdown {
+	t.access.Lock()
+	defer t.access.Unlock()
+	connect := dial - t.dns
+	if connect < 0 {
+		connect = 0
+	}
+	return Breakdown{
+		DNS:       uint16(t.dns / time.Millisecond),
+		Connect:   uint16(connect / time.Millisecond),
+		TLS:       uint16(t.tls / time.Millisecond),
+		FirstByte: uint16(t.firstByte / time.Millisecond),
+	}
+}
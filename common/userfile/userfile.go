@@ -0,0 +1,193 @@
+package userfile
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/sagernet/fswatch"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/json"
+	"github.com/sagernet/sing/common/logger"
+	"github.com/sagernet/sing/service/filemanager"
+)
+
+// Watcher loads inbound users from a JSON or CSV file, and loads them again
+// when the file changes. A file that fails to load keeps the previous users.
+type Watcher[U any] struct {
+	logger   logger.ContextLogger
+	path     string
+	onUpdate func(users []U) error
+	watcher  *fswatch.Watcher
+}
+
+func NewWatcher[U any](ctx context.Context, logger logger.ContextLogger, path string, onUpdate func(users []U) error) (*Watcher[U], error) {
+	userWatcher := &Watcher[U]{
+		logger:   logger,
+		path:     filemanager.BasePath(ctx, path),
+		onUpdate: onUpdate,
+	}
+	err := userWatcher.reload()
+	if err != nil {
+		return nil, err
+	}
+	filePath, _ := filepath.Abs(userWatcher.path)
+	watcher, err := fswatch.NewWatcher(fswatch.Options{
+		Path: []string{filePath},
+		Callback: func(path string) {
+			uErr := userWatcher.reload()
+			if uErr != nil {
+				logger.Error(E.Cause(uErr, "reload users file"))
+			}
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	userWatcher.watcher = watcher
+	return userWatcher, nil
+}
+
+func (w *Watcher[U]) Start() error {
+	err := w.watcher.Start()
+	if err != nil {
+		w.logger.Error(E.Cause(err, "watch users file"))
+	}
+	return nil
+}
+
+func (w *Watcher[U]) Close() error {
+	return common.Close(common.PtrOrNil(w.watcher))
+}
+
+func (w *Watcher[U]) reload() error {
+	users, err := Read[U](w.path)
+	if err != nil {
+		return err
+	}
+	err = w.onUpdate(users)
+	if err != nil {
+		return E.Cause(err, "update users from ", w.path)
+	}
+	w.logger.Info("loaded users file: ", len(users), " users")
+	return nil
+}
+
+// Read loads users from path. Files with the .csv extension are read as CSV
+// with a header row of user field names, others as a JSON array of users or
+// an object with a users array.
+func Read[U any](path string) ([]U, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, E.Cause(err, "read users file")
+	}
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		users, err := decodeCSV[U](content)
+		if err != nil {
+			return nil, E.Cause(err, "decode users file")
+		}
+		return users, nil
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(content), []byte("{")) {
+		object, err := json.UnmarshalExtended[struct {
+			Users []U `json:"users"`
+		}](content)
+		if err != nil {
+			return nil, E.Cause(err, "decode users file")
+		}
+		return object.Users, nil
+	}
+	users, err := json.UnmarshalExtended[[]U](content)
+	if err != nil {
+		return nil, E.Cause(err, "decode users file")
+	}
+	return users, nil
+}
+
+func decodeCSV[U any](content []byte) ([]U, error) {
+	reader := csv.NewReader(bytes.NewReader(content))
+	reader.Comment = '#'
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	userType := reflect.TypeOf((*U)(nil)).Elem()
+	fieldIndexes := make([]int, len(records[0]))
+	for columnIndex, column := range records[0] {
+		fieldIndex, loaded := jsonFieldIndex(userType, strings.TrimSpace(column))
+		if !loaded {
+			return nil, E.New("unknown column: ", column)
+		}
+		fieldIndexes[columnIndex] = fieldIndex
+	}
+	users := make([]U, 0, len(records)-1)
+	for recordIndex, record := range records[1:] {
+		var user U
+		userValue := reflect.ValueOf(&user).Elem()
+		for columnIndex, value := range record {
+			err = setField(userValue.Field(fieldIndexes[columnIndex]), value)
+			if err != nil {
+				return nil, E.Cause(err, "parse line ", recordIndex+2, " column ", records[0][columnIndex])
+			}
+		}
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+func jsonFieldIndex(userType reflect.Type, name string) (int, bool) {
+	for i := 0; i < userType.NumField(); i++ {
+		tagName, _, _ := strings.Cut(userType.Field(i).Tag.Get("json"), ",")
+		if tagName != "" && tagName == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func setField(field reflect.Value, value string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		if value == "" {
+			return nil
+		}
+		boolValue, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(boolValue)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if value == "" {
+			return nil
+		}
+		intValue, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(intValue)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if value == "" {
+			return nil
+		}
+		uintValue, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(uintValue)
+	default:
+		return E.New("unsupported field type: ", field.Type())
+	}
+	return nil
+}
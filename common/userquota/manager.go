@@ -0,0 +1,254 @@
+package userquota
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/bufio"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/logger"
+	N "github.com/sagernet/sing/common/network"
+	"github.com/sagernet/sing/common/x/list"
+	"github.com/sagernet/sing/service"
+)
+
+// flushInterval is the interval to save used bytes to the cache file.
+const flushInterval = time.Minute
+
+// Manager enforces byte quotas and expiry of inbound users. Connections of a
+// user are closed once the quota is used up or the user expires, and new
+// connections are rejected by checking Available.
+type Manager struct {
+	ctx       context.Context
+	logger    logger.ContextLogger
+	inbound   string
+	users     []*user
+	cacheFile adapter.CacheFile
+	done      chan struct{}
+}
+
+type user struct {
+	name     string
+	quota    uint64
+	expireAt time.Time
+	used     atomic.Uint64
+	stored   atomic.Uint64
+	disabled atomic.Bool
+	timer    *time.Timer
+	access   sync.Mutex
+	conns    list.List[io.Closer]
+}
+
+// NewManager creates a manager for users of the inbound, indexed as in
+// options. Nil is returned if no user has a quota or an expiry.
+func NewManager(ctx context.Context, logger logger.ContextLogger, inbound string, names []string, options []option.UserQuotaOptions) (*Manager, error) {
+	manager := &Manager{
+		ctx:     ctx,
+		logger:  logger,
+		inbound: inbound,
+		users:   make([]*user, len(options)),
+		done:    make(chan struct{}),
+	}
+	var limited bool
+	for index, quotaOptions := range options {
+		if quotaOptions.Quota == 0 && quotaOptions.ExpireAt == "" {
+			continue
+		}
+		if names[index] == "" {
+			return nil, E.New("missing name for user ", index, " with quota or expiry")
+		}
+		if quotaOptions.Quota > 0 && inbound == "" {
+			return nil, E.New("missing tag for inbound with user quota")
+		}
+		limitedUser := &user{
+			name:  names[index],
+			quota: uint64(quotaOptions.Quota),
+		}
+		if quotaOptions.ExpireAt != "" {
+			expireAt, err := time.Parse(time.RFC3339, quotaOptions.ExpireAt)
+			if err != nil {
+				return nil, E.Cause(err, "parse expire_at for user ", index)
+			}
+			limitedUser.expireAt = expireAt
+		}
+		manager.users[index] = limitedUser
+		limited = true
+	}
+	if !limited {
+		return nil, nil
+	}
+	return manager, nil
+}
+
+// Start loads used bytes from the cache file, and disables users that are
+// already over quota or expired.
+func (m *Manager) Start() error {
+	if m == nil {
+		return nil
+	}
+	m.cacheFile = service.FromContext[adapter.CacheFile](m.ctx)
+	now := time.Now()
+	for _, limitedUser := range m.users {
+		if limitedUser == nil {
+			continue
+		}
+		if m.cacheFile != nil && limitedUser.quota > 0 {
+			used := m.cacheFile.LoadUserUsage(m.inbound, limitedUser.name)
+			limitedUser.used.Store(used)
+			limitedUser.stored.Store(used)
+		}
+		if limitedUser.quota > 0 && limitedUser.used.Load() >= limitedUser.quota {
+			limitedUser.disabled.Store(true)
+			m.logger.Info("user ", limitedUser.name, " exceeded quota")
+			continue
+		}
+		if !limitedUser.expireAt.IsZero() {
+			if !now.Before(limitedUser.expireAt) {
+				limitedUser.disabled.Store(true)
+				m.logger.Info("user ", limitedUser.name, " expired")
+				continue
+			}
+			currentUser := limitedUser
+			limitedUser.timer = time.AfterFunc(limitedUser.expireAt.Sub(now), func() {
+				m.disable(currentUser, "expired")
+			})
+		}
+	}
+	if m.cacheFile != nil {
+		go m.loopFlush()
+	}
+	return nil
+}
+
+func (m *Manager) Close() error {
+	if m == nil {
+		return nil
+	}
+	select {
+	case <-m.done:
+		return nil
+	default:
+		close(m.done)
+	}
+	for _, limitedUser := range m.users {
+		if limitedUser != nil && limitedUser.timer != nil {
+			limitedUser.timer.Stop()
+		}
+	}
+	return m.flush()
+}
+
+// Available reports whether the user can authenticate and open connections.
+func (m *Manager) Available(index int) bool {
+	if m == nil {
+		return true
+	}
+	limitedUser := m.users[index]
+	return limitedUser == nil || !limitedUser.disabled.Load()
+}
+
+// NewConn counts bytes of the connection to the quota of the user, and closes
+// the connection when the user is disabled.
+func (m *Manager) NewConn(index int, conn net.Conn, onClose N.CloseHandlerFunc) (net.Conn, N.CloseHandlerFunc) {
+	if m == nil || m.users[index] == nil {
+		return conn, onClose
+	}
+	limitedUser := m.users[index]
+	onClose = m.track(limitedUser, conn, onClose)
+	if limitedUser.quota == 0 {
+		return conn, onClose
+	}
+	countFunc := []N.CountFunc{func(n int64) { m.count(limitedUser, n) }}
+	return bufio.NewCounterConn(conn, countFunc, countFunc), onClose
+}
+
+// NewPacketConn is like NewConn, for packet connections.
+func (m *Manager) NewPacketConn(index int, conn N.PacketConn, onClose N.CloseHandlerFunc) (N.PacketConn, N.CloseHandlerFunc) {
+	if m == nil || m.users[index] == nil {
+		return conn, onClose
+	}
+	limitedUser := m.users[index]
+	onClose = m.track(limitedUser, conn, onClose)
+	if limitedUser.quota == 0 {
+		return conn, onClose
+	}
+	countFunc := []N.CountFunc{func(n int64) { m.count(limitedUser, n) }}
+	return bufio.NewCounterPacketConn(conn, countFunc, countFunc), onClose
+}
+
+func (m *Manager) track(limitedUser *user, conn io.Closer, onClose N.CloseHandlerFunc) N.CloseHandlerFunc {
+	limitedUser.access.Lock()
+	element := limitedUser.conns.PushBack(conn)
+	limitedUser.access.Unlock()
+	return N.AppendClose(onClose, func(it error) {
+		limitedUser.access.Lock()
+		limitedUser.conns.Remove(element)
+		limitedUser.access.Unlock()
+	})
+}
+
+func (m *Manager) count(limitedUser *user, n int64) {
+	if limitedUser.used.Add(uint64(n)) >= limitedUser.quota {
+		m.disable(limitedUser, "exceeded quota")
+	}
+}
+
+func (m *Manager) disable(limitedUser *user, reason string) {
+	if !limitedUser.disabled.CompareAndSwap(false, true) {
+		return
+	}
+	m.logger.Info("user ", limitedUser.name, " ", reason)
+	var conns []io.Closer
+	limitedUser.access.Lock()
+	for element := limitedUser.conns.Front(); element != nil; element = element.Next() {
+		conns = append(conns, element.Value)
+	}
+	limitedUser.access.Unlock()
+	for _, conn := range conns {
+		common.Close(conn)
+	}
+}
+
+func (m *Manager) loopFlush() {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.done:
+			return
+		case <-ticker.C:
+			err := m.flush()
+			if err != nil {
+				m.logger.Error(E.Cause(err, "save user usage"))
+			}
+		}
+	}
+}
+
+func (m *Manager) flush() error {
+	if m.cacheFile == nil {
+		return nil
+	}
+	for _, limitedUser := range m.users {
+		if limitedUser == nil || limitedUser.quota == 0 {
+			continue
+		}
+		used := limitedUser.used.Load()
+		if limitedUser.stored.Load() == used {
+			continue
+		}
+		err := m.cacheFile.StoreUserUsage(m.inbound, limitedUser.name, used)
+		if err != nil {
+			return err
+		}
+		limitedUser.stored.Store(used)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package userquota
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common/logger"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestManagerUnlimited(t *testing.T) {
+	t.Parallel()
+	manager, err := NewManager(context.Background(), logger.NOP(), "in", []string{"a", ""}, make([]option.UserQuotaOptions, 2))
+	require.NoError(t, err)
+	require.Nil(t, manager)
+	require.True(t, manager.Available(1))
+}
+
+func TestManagerQuota(t *testing.T) {
+	t.Parallel()
+	manager, err := NewManager(context.Background(), logger.NOP(), "in", []string{"a", "b"}, []option.UserQuotaOptions{
+		{Quota: 8},
+		{},
+	})
+	require.NoError(t, err)
+	require.NoError(t, manager.Start())
+	defer manager.Close()
+	client, server := net.Pipe()
+	defer client.Close()
+	var closed bool
+	conn, onClose := manager.NewConn(0, server, func(it error) {
+		closed = true
+	})
+	go client.Write(make([]byte, 16))
+	buffer := make([]byte, 16)
+	n, err := conn.Read(buffer)
+	require.NoError(t, err)
+	require.Equal(t, 16, n)
+	require.False(t, manager.Available(0))
+	require.True(t, manager.Available(1))
+	_, err = conn.Read(buffer)
+	require.Error(t, err)
+	onClose(nil)
+	require.True(t, closed)
+}
+
+func TestManagerExpiry(t *testing.T) {
+	t.Parallel()
+	_, err := NewManager(context.Background(), logger.NOP(), "in", []string{"a"}, []option.UserQuotaOptions{
+		{ExpireAt: "tomorrow"},
+	})
+	require.Error(t, err)
+	_, err = NewManager(context.Background(), logger.NOP(), "in", []string{""}, []option.UserQuotaOptions{
+		{Quota: 1},
+	})
+	require.Error(t, err)
+	manager, err := NewManager(context.Background(), logger.NOP(), "in", []string{"a", "b"}, []option.UserQuotaOptions{
+		{ExpireAt: time.Now().Add(-time.Hour).Format(time.RFC3339)},
+		{ExpireAt: time.Now().Add(time.Hour).Format(time.RFC3339)},
+	})
+	require.NoError(t, err)
+	require.NoError(t, manager.Start())
+	defer manager.Close()
+	require.False(t, manager.Available(0))
+	require.True(t, manager.Available(1))
+}
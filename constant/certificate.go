@@ -0,0 +1,7 @@
+package constant
+
+const (
+	CertificateStoreSystem  = "system"
+	CertificateStoreMozilla = "mozilla"
+	CertificateStoreNone    = "none"
+)
@@ -4,3 +4,15 @@ const (
 	DNSProviderAliDNS     = "alidns"
 	DNSProviderCloudflare = "cloudflare"
 )
+
+const (
+	DNSInboundProtocolTLS   = "tls"
+	DNSInboundProtocolHTTPS = "https"
+	DNSInboundProtocolQUIC  = "quic"
+)
+
+const (
+	DNSGroupStrategyFallback = "fallback"
+	DNSGroupStrategyRace     = "race"
+	DNSGroupStrategyRandom   = "random"
+)
@@ -0,0 +1,7 @@
+package constant
+
+const (
+	HysteriaProtocolUDP         = "udp"
+	HysteriaProtocolWeChatVideo = "wechat-video"
+	HysteriaProtocolFakeTCP     = "faketcp"
+)
@@ -56,3 +56,33 @@ func (s NetworkStrategy) String() string {
 	}
 	return name
 }
+
+type UDPNATStrategy uint8
+
+const (
+	UDPNATStrategyFullCone UDPNATStrategy = iota
+	UDPNATStrategyAddressRestricted
+	UDPNATStrategyPortRestricted
+)
+
+var (
+	udpNATStrategyToString = map[UDPNATStrategy]string{
+		UDPNATStrategyFullCone:          "full_cone",
+		UDPNATStrategyAddressRestricted: "address_restricted",
+		UDPNATStrategyPortRestricted:    "port_restricted",
+	}
+	StringToUDPNATStrategy = common.ReverseMap(udpNATStrategyToString)
+)
+
+func (s UDPNATStrategy) String() string {
+	name, loaded := udpNATStrategyToString[s]
+	if !loaded {
+		return F.ToString(int(s))
+	}
+	return name
+}
+
+const (
+	BindPrefixModeConnection  = "connection"
+	BindPrefixModeDestination = "destination"
+)
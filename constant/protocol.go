@@ -11,6 +11,12 @@ const (
 	ProtocolSSH        = "ssh"
 	ProtocolRDP        = "rdp"
 	ProtocolNTP        = "ntp"
+	ProtocolSyslog     = "syslog"
+	ProtocolSIP        = "sip"
+	ProtocolRTP        = "rtp"
+	ProtocolMinecraft  = "minecraft"
+	ProtocolRakNet     = "raknet"
+	ProtocolA2S        = "a2s"
 )
 
 const (
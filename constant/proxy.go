@@ -23,11 +23,15 @@ const (
 	TypeVLESS        = "vless"
 	TypeTUIC         = "tuic"
 	TypeHysteria2    = "hysteria2"
+	TypeHTTP2        = "http2"
+	TypeHTTP3        = "http3"
 )
 
 const (
 	TypeSelector = "selector"
 	TypeURLTest  = "urltest"
+	TypeFallback = "fallback"
+	TypeRotate   = "rotate"
 )
 
 func ProxyDisplayName(proxyType string) string {
@@ -48,6 +52,10 @@ func ProxyDisplayName(proxyType string) string {
 		return "SOCKS"
 	case TypeHTTP:
 		return "HTTP"
+	case TypeHTTP2:
+		return "HTTP/2"
+	case TypeHTTP3:
+		return "HTTP/3"
 	case TypeMixed:
 		return "Mixed"
 	case TypeShadowsocks:
@@ -80,6 +88,10 @@ func ProxyDisplayName(proxyType string) string {
 		return "Selector"
 	case TypeURLTest:
 		return "URLTest"
+	case TypeFallback:
+		return "Fallback"
+	case TypeRotate:
+		return "Rotate"
 	default:
 		return "Unknown"
 	}
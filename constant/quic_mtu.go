@@ -0,0 +1,5 @@
+package constant
+
+// QUICPathMTUDiscovery reports whether quic-go is able to set the DF bit
+// and probe the path MTU (RFC 8899) on the current platform.
+const QUICPathMTUDiscovery = IsLinux || IsWindows || IsDarwin
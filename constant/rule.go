@@ -11,11 +11,14 @@ const (
 )
 
 const (
-	RuleSetTypeInline   = "inline"
-	RuleSetTypeLocal    = "local"
-	RuleSetTypeRemote   = "remote"
-	RuleSetFormatSource = "source"
-	RuleSetFormatBinary = "binary"
+	RuleSetTypeInline    = "inline"
+	RuleSetTypeLocal     = "local"
+	RuleSetTypeRemote    = "remote"
+	RuleSetFormatSource  = "source"
+	RuleSetFormatBinary  = "binary"
+	RuleSetFormatAdGuard = "adguard"
+	RuleSetFormatAdblock = "adblock"
+	RuleSetFormatClash   = "clash"
 )
 
 const (
@@ -36,6 +39,9 @@ const (
 )
 
 const (
-	RuleActionRejectMethodDefault = "default"
-	RuleActionRejectMethodDrop    = "drop"
+	RuleActionRejectMethodDefault     = "default"
+	RuleActionRejectMethodDrop        = "drop"
+	RuleActionRejectMethodReset       = "reset"
+	RuleActionRejectMethodUnreachable = "unreachable"
+	RuleActionRejectMethodHTTP        = "http"
 )
@@ -16,19 +16,22 @@ const (
 	StopTimeout                = 5 * time.Second
 	FatalStopTimeout           = 10 * time.Second
 	FakeIPMetadataSaveInterval = 10 * time.Second
+	DNSNegativeCacheTTL        = 10 * time.Second
 )
 
 var PortProtocols = map[uint16]string{
 	53:   ProtocolDNS,
 	123:  ProtocolNTP,
+	514:  ProtocolSyslog,
 	3478: ProtocolSTUN,
 	443:  ProtocolQUIC,
 }
 
 var ProtocolTimeouts = map[string]time.Duration{
-	ProtocolDNS:  10 * time.Second,
-	ProtocolNTP:  10 * time.Second,
-	ProtocolSTUN: 10 * time.Second,
-	ProtocolQUIC: 30 * time.Second,
-	ProtocolDTLS: 30 * time.Second,
+	ProtocolDNS:    10 * time.Second,
+	ProtocolNTP:    10 * time.Second,
+	ProtocolSyslog: 10 * time.Second,
+	ProtocolSTUN:   10 * time.Second,
+	ProtocolQUIC:   30 * time.Second,
+	ProtocolDTLS:   30 * time.Second,
 }
@@ -2,6 +2,7 @@ package cachefile
 
 import (
 	"context"
+	"encoding/binary"
 	"errors"
 	"net/netip"
 	"os"
@@ -15,26 +16,48 @@ import (
 	"github.com/sagernet/sing-box/option"
 	"github.com/sagernet/sing/common"
 	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/json"
 	"github.com/sagernet/sing/service/filemanager"
 )
 
 var (
-	bucketSelected = []byte("selected")
-	bucketExpand   = []byte("group_expand")
-	bucketMode     = []byte("clash_mode")
-	bucketRuleSet  = []byte("rule_set")
+	bucketSelected  = []byte("selected")
+	bucketExpand    = []byte("group_expand")
+	bucketFilter    = []byte("group_filter")
+	bucketURLTest   = []byte("group_urltest")
+	bucketMode      = []byte("clash_mode")
+	bucketRuleSet   = []byte("rule_set")
+	bucketRuleGroup = []byte("rule_group")
+	bucketUserUsage = []byte("user_usage")
 
 	bucketNameList = []string{
 		string(bucketSelected),
 		string(bucketExpand),
+		string(bucketFilter),
+		string(bucketURLTest),
 		string(bucketMode),
 		string(bucketRuleSet),
+		string(bucketRuleGroup),
+		string(bucketUserUsage),
 		string(bucketRDRC),
 	}
 
 	cacheIDDefault = []byte("default")
 )
 
+// sharedLockTimeout is the maximum time to wait for other instances to
+// release the shared cache file.
+const sharedLockTimeout = 500 * time.Millisecond
+
+const (
+	// roleDNS allocates fake IPs and writes them to the cache file before
+	// the DNS response is sent.
+	roleDNS = "dns"
+	// roleTUN looks up fake IPs allocated by the DNS instance, and never
+	// allocates or resets them.
+	roleTUN = "tun"
+)
+
 var _ adapter.CacheFile = (*CacheFile)(nil)
 
 type CacheFile struct {
@@ -44,6 +67,11 @@ type CacheFile struct {
 	storeFakeIP       bool
 	storeRDRC         bool
 	rdrcTimeout       time.Duration
+	shared            bool
+	role              string
+	access            sync.Mutex
+	sharedDB          *bbolt.DB
+	sharedReferences  int
 	DB                *bbolt.DB
 	saveMetadataTimer *time.Timer
 	saveFakeIPAccess  sync.RWMutex
@@ -86,6 +114,8 @@ func New(ctx context.Context, options option.CacheFileOptions) *CacheFile {
 		storeFakeIP:  options.StoreFakeIP,
 		storeRDRC:    options.StoreRDRC,
 		rdrcTimeout:  rdrcTimeout,
+		shared:       options.Shared,
+		role:         options.Role,
 		saveDomain:   make(map[netip.Addr]string),
 		saveAddress4: make(map[string]netip.Addr),
 		saveAddress6: make(map[string]netip.Addr),
@@ -105,27 +135,23 @@ func (c *CacheFile) Start(stage adapter.StartStage) error {
 	if stage != adapter.StartStateInitialize {
 		return nil
 	}
-	const fileMode = 0o666
-	options := bbolt.Options{Timeout: time.Second}
+	switch c.role {
+	case "":
+	case roleDNS, roleTUN:
+		if !c.shared {
+			return E.New("cache file role requires shared")
+		}
+	default:
+		return E.New("unknown cache file role: ", c.role)
+	}
 	var (
 		db  *bbolt.DB
 		err error
 	)
-	for i := 0; i < 10; i++ {
-		db, err = bbolt.Open(c.path, fileMode, &options)
-		if err == nil {
-			break
-		}
-		if errors.Is(err, bboltErrors.ErrTimeout) {
-			continue
-		}
-		if E.IsMulti(err, bboltErrors.ErrInvalid, bboltErrors.ErrChecksum, bboltErrors.ErrVersionMismatch) {
-			rmErr := os.Remove(c.path)
-			if rmErr != nil {
-				return err
-			}
-		}
-		time.Sleep(100 * time.Millisecond)
+	if c.shared {
+		db, err = c.openShared()
+	} else {
+		db, err = c.open()
 	}
 	if err != nil {
 		return err
@@ -158,10 +184,124 @@ func (c *CacheFile) Start(stage adapter.StartStage) error {
 		db.Close()
 		return err
 	}
+	if c.shared {
+		// other instances can only take the lock while the file is closed
+		return db.Close()
+	}
 	c.DB = db
 	return nil
 }
 
+func (c *CacheFile) open() (*bbolt.DB, error) {
+	const fileMode = 0o666
+	options := bbolt.Options{Timeout: time.Second}
+	var (
+		db  *bbolt.DB
+		err error
+	)
+	for i := 0; i < 10; i++ {
+		db, err = bbolt.Open(c.path, fileMode, &options)
+		if err == nil {
+			break
+		}
+		if errors.Is(err, bboltErrors.ErrTimeout) {
+			continue
+		}
+		if E.IsMulti(err, bboltErrors.ErrInvalid, bboltErrors.ErrChecksum, bboltErrors.ErrVersionMismatch) {
+			rmErr := os.Remove(c.path)
+			if rmErr != nil {
+				return nil, err
+			}
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return db, err
+}
+
+// openShared opens the shared cache file once, waiting at most
+// sharedLockTimeout for other instances. A corrupted file is never removed,
+// since other instances may still use it.
+func (c *CacheFile) openShared() (*bbolt.DB, error) {
+	const fileMode = 0o666
+	db, err := bbolt.Open(c.path, fileMode, &bbolt.Options{Timeout: sharedLockTimeout})
+	if err != nil {
+		if errors.Is(err, bboltErrors.ErrTimeout) {
+			return nil, E.New("wait for shared cache file: timeout")
+		}
+		return nil, E.Cause(err, "open shared cache file")
+	}
+	return db, nil
+}
+
+// acquireShared returns the handle of the shared cache file, which is opened
+// by the first transaction and kept open for transactions running at the
+// same time, so that a burst of reads and writes opens the file once and
+// bbolt batches the writes in one transaction.
+func (c *CacheFile) acquireShared() (*bbolt.DB, error) {
+	c.access.Lock()
+	defer c.access.Unlock()
+	if c.sharedDB == nil {
+		db, err := c.openShared()
+		if err != nil {
+			return nil, err
+		}
+		c.sharedDB = db
+	}
+	c.sharedReferences++
+	return c.sharedDB, nil
+}
+
+// releaseShared closes the handle after the last running transaction, so
+// that other instances can take the lock.
+func (c *CacheFile) releaseShared() {
+	c.access.Lock()
+	defer c.access.Unlock()
+	c.sharedReferences--
+	if c.sharedReferences == 0 {
+		c.sharedDB.Close()
+		c.sharedDB = nil
+	}
+}
+
+// view, update and batch run a transaction on the cache file. When the file
+// is shared with other instances, the lock is only held while transactions
+// are running.
+func (c *CacheFile) view(fn func(tx *bbolt.Tx) error) error {
+	if !c.shared {
+		return c.DB.View(fn)
+	}
+	db, err := c.acquireShared()
+	if err != nil {
+		return err
+	}
+	defer c.releaseShared()
+	return db.View(fn)
+}
+
+func (c *CacheFile) update(fn func(tx *bbolt.Tx) error) error {
+	if !c.shared {
+		return c.DB.Update(fn)
+	}
+	db, err := c.acquireShared()
+	if err != nil {
+		return err
+	}
+	defer c.releaseShared()
+	return db.Update(fn)
+}
+
+func (c *CacheFile) batch(fn func(tx *bbolt.Tx) error) error {
+	if !c.shared {
+		return c.DB.Batch(fn)
+	}
+	db, err := c.acquireShared()
+	if err != nil {
+		return err
+	}
+	defer c.releaseShared()
+	return db.Batch(fn)
+}
+
 func (c *CacheFile) Close() error {
 	if c.DB == nil {
 		return nil
@@ -173,9 +313,13 @@ func (c *CacheFile) StoreFakeIP() bool {
 	return c.storeFakeIP
 }
 
+func (c *CacheFile) FakeIPReadOnly() bool {
+	return c.role == roleTUN
+}
+
 func (c *CacheFile) LoadMode() string {
 	var mode string
-	c.DB.View(func(t *bbolt.Tx) error {
+	c.view(func(t *bbolt.Tx) error {
 		bucket := t.Bucket(bucketMode)
 		if bucket == nil {
 			return nil
@@ -193,7 +337,7 @@ func (c *CacheFile) LoadMode() string {
 }
 
 func (c *CacheFile) StoreMode(mode string) error {
-	return c.DB.Batch(func(t *bbolt.Tx) error {
+	return c.batch(func(t *bbolt.Tx) error {
 		bucket, err := t.CreateBucketIfNotExists(bucketMode)
 		if err != nil {
 			return err
@@ -230,7 +374,7 @@ func (c *CacheFile) createBucket(t *bbolt.Tx, key []byte) (*bbolt.Bucket, error)
 
 func (c *CacheFile) LoadSelected(group string) string {
 	var selected string
-	c.DB.View(func(t *bbolt.Tx) error {
+	c.view(func(t *bbolt.Tx) error {
 		bucket := c.bucket(t, bucketSelected)
 		if bucket == nil {
 			return nil
@@ -245,7 +389,7 @@ func (c *CacheFile) LoadSelected(group string) string {
 }
 
 func (c *CacheFile) StoreSelected(group, selected string) error {
-	return c.DB.Batch(func(t *bbolt.Tx) error {
+	return c.batch(func(t *bbolt.Tx) error {
 		bucket, err := c.createBucket(t, bucketSelected)
 		if err != nil {
 			return err
@@ -255,7 +399,7 @@ func (c *CacheFile) StoreSelected(group, selected string) error {
 }
 
 func (c *CacheFile) LoadGroupExpand(group string) (isExpand bool, loaded bool) {
-	c.DB.View(func(t *bbolt.Tx) error {
+	c.view(func(t *bbolt.Tx) error {
 		bucket := c.bucket(t, bucketExpand)
 		if bucket == nil {
 			return nil
@@ -271,7 +415,7 @@ func (c *CacheFile) LoadGroupExpand(group string) (isExpand bool, loaded bool) {
 }
 
 func (c *CacheFile) StoreGroupExpand(group string, isExpand bool) error {
-	return c.DB.Batch(func(t *bbolt.Tx) error {
+	return c.batch(func(t *bbolt.Tx) error {
 		bucket, err := c.createBucket(t, bucketExpand)
 		if err != nil {
 			return err
@@ -284,9 +428,142 @@ func (c *CacheFile) StoreGroupExpand(group string, isExpand bool) error {
 	})
 }
 
+func (c *CacheFile) LoadRuleGroup(group string) (enabled bool, loaded bool) {
+	c.view(func(t *bbolt.Tx) error {
+		bucket := c.bucket(t, bucketRuleGroup)
+		if bucket == nil {
+			return nil
+		}
+		enabledBytes := bucket.Get([]byte(group))
+		if len(enabledBytes) == 1 {
+			enabled = enabledBytes[0] == 1
+			loaded = true
+		}
+		return nil
+	})
+	return
+}
+
+func (c *CacheFile) StoreRuleGroup(group string, enabled bool) error {
+	return c.batch(func(t *bbolt.Tx) error {
+		bucket, err := c.createBucket(t, bucketRuleGroup)
+		if err != nil {
+			return err
+		}
+		if enabled {
+			return bucket.Put([]byte(group), []byte{1})
+		} else {
+			return bucket.Put([]byte(group), []byte{0})
+		}
+	})
+}
+
+func (c *CacheFile) LoadUserUsage(inbound string, user string) uint64 {
+	var used uint64
+	c.view(func(t *bbolt.Tx) error {
+		bucket := c.bucket(t, bucketUserUsage)
+		if bucket == nil {
+			return nil
+		}
+		bucket = bucket.Bucket([]byte(inbound))
+		if bucket == nil {
+			return nil
+		}
+		usedBytes := bucket.Get([]byte(user))
+		if len(usedBytes) == 8 {
+			used = binary.BigEndian.Uint64(usedBytes)
+		}
+		return nil
+	})
+	return used
+}
+
+func (c *CacheFile) StoreUserUsage(inbound string, user string, used uint64) error {
+	return c.batch(func(t *bbolt.Tx) error {
+		bucket, err := c.createBucket(t, bucketUserUsage)
+		if err != nil {
+			return err
+		}
+		bucket, err = bucket.CreateBucketIfNotExists([]byte(inbound))
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(user), binary.BigEndian.AppendUint64(nil, used))
+	})
+}
+
+func (c *CacheFile) LoadGroupFilter(group string) *adapter.SavedGroupFilter {
+	var saved adapter.SavedGroupFilter
+	err := c.view(func(t *bbolt.Tx) error {
+		bucket := c.bucket(t, bucketFilter)
+		if bucket == nil {
+			return os.ErrNotExist
+		}
+		filterBytes := bucket.Get([]byte(group))
+		if len(filterBytes) == 0 {
+			return os.ErrNotExist
+		}
+		return json.Unmarshal(filterBytes, &saved)
+	})
+	if err != nil {
+		return nil
+	}
+	return &saved
+}
+
+func (c *CacheFile) StoreGroupFilter(group string, saved *adapter.SavedGroupFilter) error {
+	return c.batch(func(t *bbolt.Tx) error {
+		bucket, err := c.createBucket(t, bucketFilter)
+		if err != nil {
+			return err
+		}
+		if saved == nil {
+			return bucket.Delete([]byte(group))
+		}
+		filterBytes, err := json.Marshal(saved)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(group), filterBytes)
+	})
+}
+
+func (c *CacheFile) LoadURLTestGroup(group string) *adapter.SavedURLTestGroup {
+	var saved adapter.SavedURLTestGroup
+	err := c.view(func(t *bbolt.Tx) error {
+		bucket := c.bucket(t, bucketURLTest)
+		if bucket == nil {
+			return os.ErrNotExist
+		}
+		savedBytes := bucket.Get([]byte(group))
+		if len(savedBytes) == 0 {
+			return os.ErrNotExist
+		}
+		return json.Unmarshal(savedBytes, &saved)
+	})
+	if err != nil {
+		return nil
+	}
+	return &saved
+}
+
+func (c *CacheFile) StoreURLTestGroup(group string, saved *adapter.SavedURLTestGroup) error {
+	return c.batch(func(t *bbolt.Tx) error {
+		bucket, err := c.createBucket(t, bucketURLTest)
+		if err != nil {
+			return err
+		}
+		savedBytes, err := json.Marshal(saved)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(group), savedBytes)
+	})
+}
+
 func (c *CacheFile) LoadRuleSet(tag string) *adapter.SavedRuleSet {
 	var savedSet adapter.SavedRuleSet
-	err := c.DB.View(func(t *bbolt.Tx) error {
+	err := c.view(func(t *bbolt.Tx) error {
 		bucket := c.bucket(t, bucketRuleSet)
 		if bucket == nil {
 			return os.ErrNotExist
@@ -304,7 +581,7 @@ func (c *CacheFile) LoadRuleSet(tag string) *adapter.SavedRuleSet {
 }
 
 func (c *CacheFile) SaveRuleSet(tag string, set *adapter.SavedRuleSet) error {
-	return c.DB.Batch(func(t *bbolt.Tx) error {
+	return c.batch(func(t *bbolt.Tx) error {
 		bucket, err := c.createBucket(t, bucketRuleSet)
 		if err != nil {
 			return err
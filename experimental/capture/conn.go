@@ -0,0 +1,163 @@
+package capture
+
+import (
+	"net"
+	"net/netip"
+	"sync"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing/common/buf"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+// The wrappers are not replaceable, so copies can not bypass the capture by
+// unwrapping them.
+
+type streamConn struct {
+	net.Conn
+	session     *Session
+	source      netip.AddrPort
+	destination netip.AddrPort
+	access      sync.Mutex
+	clientSeq   uint32
+	serverSeq   uint32
+	closed      bool
+}
+
+func newStreamConn(conn net.Conn, session *Session, metadata adapter.InboundContext) *streamConn {
+	source, destination := endpoints(metadata.Source, metadata.Destination, metadata.DestinationAddresses)
+	c := &streamConn{
+		Conn:        conn,
+		session:     session,
+		source:      source,
+		destination: destination,
+		clientSeq:   1,
+		serverSeq:   1,
+	}
+	session.writePacket(buildPacket(source, destination, &tcpSegment{flags: tcpSYN}, nil))
+	session.writePacket(buildPacket(destination, source, &tcpSegment{flags: tcpSYN | tcpACK, ack: 1}, nil))
+	session.writePacket(buildPacket(source, destination, &tcpSegment{flags: tcpACK, seq: 1, ack: 1}, nil))
+	return c
+}
+
+func (c *streamConn) Read(p []byte) (n int, err error) {
+	n, err = c.Conn.Read(p)
+	if n > 0 {
+		c.capture(true, p[:n])
+	}
+	return
+}
+
+func (c *streamConn) Write(p []byte) (n int, err error) {
+	n, err = c.Conn.Write(p)
+	if n > 0 {
+		c.capture(false, p[:n])
+	}
+	return
+}
+
+func (c *streamConn) capture(fromClient bool, data []byte) {
+	c.access.Lock()
+	defer c.access.Unlock()
+	for len(data) > 0 {
+		chunk := data
+		if len(chunk) > maxPayloadLen {
+			chunk = chunk[:maxPayloadLen]
+		}
+		data = data[len(chunk):]
+		if fromClient {
+			c.session.writePacket(buildPacket(c.source, c.destination, &tcpSegment{flags: tcpPSH | tcpACK, seq: c.clientSeq, ack: c.serverSeq}, chunk))
+			c.clientSeq += uint32(len(chunk))
+		} else {
+			c.session.writePacket(buildPacket(c.destination, c.source, &tcpSegment{flags: tcpPSH | tcpACK, seq: c.serverSeq, ack: c.clientSeq}, chunk))
+			c.serverSeq += uint32(len(chunk))
+		}
+	}
+}
+
+func (c *streamConn) Close() error {
+	c.access.Lock()
+	if !c.closed {
+		c.closed = true
+		c.session.writePacket(buildPacket(c.source, c.destination, &tcpSegment{flags: tcpFIN | tcpACK, seq: c.clientSeq, ack: c.serverSeq}, nil))
+		c.session.writePacket(buildPacket(c.destination, c.source, &tcpSegment{flags: tcpFIN | tcpACK, seq: c.serverSeq, ack: c.clientSeq + 1}, nil))
+	}
+	c.access.Unlock()
+	return c.Conn.Close()
+}
+
+func (c *streamConn) Upstream() any {
+	return c.Conn
+}
+
+func (c *streamConn) ReaderReplaceable() bool {
+	return false
+}
+
+func (c *streamConn) WriterReplaceable() bool {
+	return false
+}
+
+type packetConn struct {
+	N.PacketConn
+	session     *Session
+	source      netip.AddrPort
+	destination netip.AddrPort
+}
+
+func newPacketConn(conn N.PacketConn, session *Session, metadata adapter.InboundContext) *packetConn {
+	source, destination := endpoints(metadata.Source, metadata.Destination, metadata.DestinationAddresses)
+	return &packetConn{
+		PacketConn:  conn,
+		session:     session,
+		source:      source,
+		destination: destination,
+	}
+}
+
+func (c *packetConn) ReadPacket(buffer *buf.Buffer) (destination M.Socksaddr, err error) {
+	destination, err = c.PacketConn.ReadPacket(buffer)
+	if err == nil {
+		c.capture(true, destination, buffer.Bytes())
+	}
+	return
+}
+
+func (c *packetConn) WritePacket(buffer *buf.Buffer, destination M.Socksaddr) error {
+	c.capture(false, destination, buffer.Bytes())
+	return c.PacketConn.WritePacket(buffer, destination)
+}
+
+func (c *packetConn) capture(fromClient bool, destination M.Socksaddr, data []byte) {
+	if len(data) > maxPayloadLen {
+		data = data[:maxPayloadLen]
+	}
+	packetDestination := netip.AddrPortFrom(c.destination.Addr(), destination.Port)
+	if destination.IsIP() {
+		// keep the address family of the source
+		addr := destination.Addr.Unmap()
+		if c.source.Addr().Is6() {
+			packetDestination = netip.AddrPortFrom(netip.AddrFrom16(addr.As16()), destination.Port)
+		} else if addr.Is4() {
+			packetDestination = netip.AddrPortFrom(addr, destination.Port)
+		}
+	}
+	if fromClient {
+		c.session.writePacket(buildPacket(c.source, packetDestination, nil, data))
+	} else {
+		c.session.writePacket(buildPacket(packetDestination, c.source, nil, data))
+	}
+}
+
+func (c *packetConn) Upstream() any {
+	return c.PacketConn
+}
+
+func (c *packetConn) ReaderReplaceable() bool {
+	return false
+}
+
+func (c *packetConn) WriterReplaceable() bool {
+	return false
+}
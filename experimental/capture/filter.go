@@ -0,0 +1,336 @@
+package capture
+
+import (
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/sagernet/sing-box/adapter"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+// Filter is a connection filter in a subset of the tcpdump expression syntax:
+//
+//	[src|dst] host <address|domain>
+//	[src|dst] port <port>
+//	[src|dst] net <prefix>
+//	tcp, udp, ip, ip6
+//
+// combined with and (&&), or (||), not (!) and parentheses. Adjacent
+// primitives are joined with and, as in tcp port 443.
+type Filter struct {
+	node filterNode
+}
+
+func ParseFilter(expression string) (*Filter, error) {
+	parser := &filterParser{tokens: tokenizeFilter(expression)}
+	if len(parser.tokens) == 0 {
+		return nil, nil
+	}
+	node, err := parser.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if parser.position < len(parser.tokens) {
+		return nil, E.New("unexpected token: ", parser.tokens[parser.position])
+	}
+	return &Filter{node}, nil
+}
+
+func (f *Filter) Match(metadata *adapter.InboundContext) bool {
+	if f == nil {
+		return true
+	}
+	return f.node.match(metadata)
+}
+
+type filterNode interface {
+	match(metadata *adapter.InboundContext) bool
+}
+
+type filterAnd [2]filterNode
+
+func (n filterAnd) match(metadata *adapter.InboundContext) bool {
+	return n[0].match(metadata) && n[1].match(metadata)
+}
+
+type filterOr [2]filterNode
+
+func (n filterOr) match(metadata *adapter.InboundContext) bool {
+	return n[0].match(metadata) || n[1].match(metadata)
+}
+
+type filterNot struct {
+	node filterNode
+}
+
+func (n filterNot) match(metadata *adapter.InboundContext) bool {
+	return !n.node.match(metadata)
+}
+
+type filterNetwork string
+
+func (n filterNetwork) match(metadata *adapter.InboundContext) bool {
+	return metadata.Network == string(n)
+}
+
+type filterIPVersion uint8
+
+func (n filterIPVersion) match(metadata *adapter.InboundContext) bool {
+	if metadata.IPVersion != 0 {
+		return metadata.IPVersion == uint8(n)
+	}
+	for _, addr := range filterAddresses(metadata, true, true) {
+		if addr.Is4() == (n == 4) {
+			return true
+		}
+	}
+	return false
+}
+
+type filterDirection int
+
+const (
+	directionAny filterDirection = iota
+	directionSource
+	directionDestination
+)
+
+type filterHost struct {
+	direction filterDirection
+	addr      netip.Addr
+	domain    string
+}
+
+func (n filterHost) match(metadata *adapter.InboundContext) bool {
+	if n.domain != "" {
+		if n.direction == directionSource {
+			return false
+		}
+		return metadata.Domain == n.domain || metadata.Destination.Fqdn == n.domain
+	}
+	for _, addr := range filterAddresses(metadata, n.direction != directionDestination, n.direction != directionSource) {
+		if addr == n.addr {
+			return true
+		}
+	}
+	return false
+}
+
+type filterPort struct {
+	direction filterDirection
+	port      uint16
+}
+
+func (n filterPort) match(metadata *adapter.InboundContext) bool {
+	if n.direction != directionDestination && metadata.Source.Port == n.port {
+		return true
+	}
+	return n.direction != directionSource && metadata.Destination.Port == n.port
+}
+
+type filterNet struct {
+	direction filterDirection
+	prefix    netip.Prefix
+}
+
+func (n filterNet) match(metadata *adapter.InboundContext) bool {
+	for _, addr := range filterAddresses(metadata, n.direction != directionDestination, n.direction != directionSource) {
+		if n.prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func filterAddresses(metadata *adapter.InboundContext, source bool, destination bool) []netip.Addr {
+	var addresses []netip.Addr
+	if source && metadata.Source.IsIP() {
+		addresses = append(addresses, metadata.Source.Addr.Unmap())
+	}
+	if destination {
+		if metadata.Destination.IsIP() {
+			addresses = append(addresses, metadata.Destination.Addr.Unmap())
+		}
+		for _, addr := range metadata.DestinationAddresses {
+			addresses = append(addresses, addr.Unmap())
+		}
+	}
+	return addresses
+}
+
+func tokenizeFilter(expression string) []string {
+	var (
+		tokens  []string
+		builder strings.Builder
+	)
+	flush := func() {
+		if builder.Len() > 0 {
+			tokens = append(tokens, builder.String())
+			builder.Reset()
+		}
+	}
+	for i := 0; i < len(expression); i++ {
+		switch char := expression[i]; char {
+		case ' ', '\t', '\n', '\r':
+			flush()
+		case '(', ')':
+			flush()
+			tokens = append(tokens, string(char))
+		case '!':
+			flush()
+			tokens = append(tokens, "not")
+		case '&', '|':
+			flush()
+			if i+1 < len(expression) && expression[i+1] == char {
+				i++
+			}
+			if char == '&' {
+				tokens = append(tokens, "and")
+			} else {
+				tokens = append(tokens, "or")
+			}
+		default:
+			builder.WriteByte(char)
+		}
+	}
+	flush()
+	return tokens
+}
+
+type filterParser struct {
+	tokens   []string
+	position int
+}
+
+func (p *filterParser) peek() string {
+	if p.position < len(p.tokens) {
+		return p.tokens[p.position]
+	}
+	return ""
+}
+
+func (p *filterParser) next() (string, error) {
+	if p.position >= len(p.tokens) {
+		return "", E.New("unexpected end of filter")
+	}
+	token := p.tokens[p.position]
+	p.position++
+	return token, nil
+}
+
+func (p *filterParser) parseOr() (filterNode, error) {
+	node, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "or" {
+		p.position++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		node = filterOr{node, right}
+	}
+	return node, nil
+}
+
+func (p *filterParser) parseAnd() (filterNode, error) {
+	node, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch p.peek() {
+		case "and":
+			p.position++
+		case "", "or", ")":
+			return node, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		node = filterAnd{node, right}
+	}
+}
+
+func (p *filterParser) parseUnary() (filterNode, error) {
+	token, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	switch token {
+	case "not":
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return filterNot{node}, nil
+	case "(":
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		token, err = p.next()
+		if err != nil {
+			return nil, err
+		} else if token != ")" {
+			return nil, E.New("expected ), got ", token)
+		}
+		return node, nil
+	case "tcp":
+		return filterNetwork(N.NetworkTCP), nil
+	case "udp":
+		return filterNetwork(N.NetworkUDP), nil
+	case "ip":
+		return filterIPVersion(4), nil
+	case "ip6":
+		return filterIPVersion(6), nil
+	case "src":
+		return p.parsePrimitive(directionSource)
+	case "dst":
+		return p.parsePrimitive(directionDestination)
+	default:
+		p.position--
+		return p.parsePrimitive(directionAny)
+	}
+}
+
+func (p *filterParser) parsePrimitive(direction filterDirection) (filterNode, error) {
+	keyword, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	value, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	switch keyword {
+	case "host":
+		addr, err := netip.ParseAddr(value)
+		if err == nil {
+			return filterHost{direction: direction, addr: addr.Unmap()}, nil
+		}
+		if !M.IsDomainName(value) {
+			return nil, E.New("invalid host: ", value)
+		}
+		return filterHost{direction: direction, domain: value}, nil
+	case "port":
+		port, err := strconv.ParseUint(value, 10, 16)
+		if err != nil {
+			return nil, E.Cause(err, "invalid port: ", value)
+		}
+		return filterPort{direction: direction, port: uint16(port)}, nil
+	case "net":
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return nil, E.Cause(err, "invalid net: ", value)
+		}
+		return filterNet{direction: direction, prefix: prefix.Masked()}, nil
+	default:
+		return nil, E.New("unknown filter primitive: ", keyword)
+	}
+}
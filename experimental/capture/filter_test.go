@@ -0,0 +1,72 @@
+package capture_test
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/experimental/capture"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilter(t *testing.T) {
+	t.Parallel()
+	metadata := adapter.InboundContext{
+		Network:              N.NetworkTCP,
+		Source:               M.ParseSocksaddr("192.168.1.2:50000"),
+		Destination:          M.ParseSocksaddr("example.com:443"),
+		DestinationAddresses: []netip.Addr{netip.MustParseAddr("93.184.216.34")},
+	}
+	matchFilters := []string{
+		"",
+		"tcp",
+		"ip",
+		"port 443",
+		"dst port 443",
+		"src port 50000",
+		"tcp port 443",
+		"host example.com",
+		"dst host 93.184.216.34",
+		"src net 192.168.0.0/16",
+		"udp or port 443",
+		"not udp && !port 80",
+		"(udp or tcp) and (src host 192.168.1.2)",
+	}
+	for _, expression := range matchFilters {
+		filter, err := capture.ParseFilter(expression)
+		require.NoError(t, err, expression)
+		require.True(t, filter.Match(&metadata), expression)
+	}
+	notMatchFilters := []string{
+		"udp",
+		"ip6",
+		"src port 443",
+		"src host example.com",
+		"host 192.168.1.3",
+		"dst net 192.168.0.0/16",
+		"tcp and port 80",
+		"not (tcp port 443)",
+	}
+	for _, expression := range notMatchFilters {
+		filter, err := capture.ParseFilter(expression)
+		require.NoError(t, err, expression)
+		require.False(t, filter.Match(&metadata), expression)
+	}
+	invalidFilters := []string{
+		"port",
+		"port http",
+		"host a/b",
+		"net 10.0.0.1",
+		"(tcp",
+		"tcp)",
+		"proto tcp",
+		"and tcp",
+	}
+	for _, expression := range invalidFilters {
+		_, err := capture.ParseFilter(expression)
+		require.Error(t, err, expression)
+	}
+}
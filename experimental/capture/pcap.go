@@ -0,0 +1,138 @@
+package capture
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"time"
+)
+
+const (
+	pcapMagic      = 0xa1b2c3d4
+	pcapSnapLength = 65535
+	// linkTypeRaw marks records as raw IPv4 or IPv6 packets.
+	linkTypeRaw = 101
+
+	ipv4HeaderLen = 20
+	ipv6HeaderLen = 40
+	tcpHeaderLen  = 20
+	udpHeaderLen  = 8
+
+	// maxPayloadLen keeps synthesized packets within the IPv4 total length.
+	maxPayloadLen = 65535 - ipv4HeaderLen - tcpHeaderLen
+
+	protocolTCP = 6
+	protocolUDP = 17
+
+	tcpFIN = 0x01
+	tcpSYN = 0x02
+	tcpPSH = 0x08
+	tcpACK = 0x10
+)
+
+func pcapHeader() []byte {
+	header := make([]byte, 24)
+	binary.LittleEndian.PutUint32(header[0:], pcapMagic)
+	binary.LittleEndian.PutUint16(header[4:], 2)
+	binary.LittleEndian.PutUint16(header[6:], 4)
+	binary.LittleEndian.PutUint32(header[16:], pcapSnapLength)
+	binary.LittleEndian.PutUint32(header[20:], linkTypeRaw)
+	return header
+}
+
+func pcapRecord(timestamp time.Time, packet []byte) []byte {
+	record := make([]byte, 16, 16+len(packet))
+	binary.LittleEndian.PutUint32(record[0:], uint32(timestamp.Unix()))
+	binary.LittleEndian.PutUint32(record[4:], uint32(timestamp.Nanosecond()/1000))
+	binary.LittleEndian.PutUint32(record[8:], uint32(len(packet)))
+	binary.LittleEndian.PutUint32(record[12:], uint32(len(packet)))
+	return append(record, packet...)
+}
+
+type tcpSegment struct {
+	flags uint8
+	seq   uint32
+	ack   uint32
+}
+
+// buildPacket synthesizes an IP packet carrying payload, segment is nil for
+// UDP. Both addresses must be of the same family.
+func buildPacket(source netip.AddrPort, destination netip.AddrPort, segment *tcpSegment, payload []byte) []byte {
+	var (
+		protocol  uint8
+		headerLen int
+	)
+	if segment != nil {
+		protocol = protocolTCP
+		headerLen = tcpHeaderLen
+	} else {
+		protocol = protocolUDP
+		headerLen = udpHeaderLen
+	}
+	ipHeaderLen := ipv4HeaderLen
+	if source.Addr().Is6() {
+		ipHeaderLen = ipv6HeaderLen
+	}
+	packet := make([]byte, ipHeaderLen+headerLen+len(payload))
+	transport := packet[ipHeaderLen:]
+	binary.BigEndian.PutUint16(transport[0:], source.Port())
+	binary.BigEndian.PutUint16(transport[2:], destination.Port())
+	if segment != nil {
+		binary.BigEndian.PutUint32(transport[4:], segment.seq)
+		binary.BigEndian.PutUint32(transport[8:], segment.ack)
+		transport[12] = tcpHeaderLen / 4 << 4
+		transport[13] = segment.flags
+		binary.BigEndian.PutUint16(transport[14:], 65535)
+	} else {
+		binary.BigEndian.PutUint16(transport[4:], uint16(len(transport)))
+	}
+	copy(transport[headerLen:], payload)
+	sourceAddr := source.Addr().AsSlice()
+	destinationAddr := destination.Addr().AsSlice()
+	if ipHeaderLen == ipv4HeaderLen {
+		packet[0] = 0x45
+		binary.BigEndian.PutUint16(packet[2:], uint16(len(packet)))
+		binary.BigEndian.PutUint16(packet[6:], 0x4000)
+		packet[8] = 64
+		packet[9] = protocol
+		copy(packet[12:], sourceAddr)
+		copy(packet[16:], destinationAddr)
+		binary.BigEndian.PutUint16(packet[10:], ^checksum(0, packet[:ipv4HeaderLen]))
+	} else {
+		packet[0] = 0x60
+		binary.BigEndian.PutUint16(packet[4:], uint16(len(transport)))
+		packet[6] = protocol
+		packet[7] = 64
+		copy(packet[8:], sourceAddr)
+		copy(packet[24:], destinationAddr)
+	}
+	pseudoHeader := make([]byte, 0, 40)
+	pseudoHeader = append(pseudoHeader, sourceAddr...)
+	pseudoHeader = append(pseudoHeader, destinationAddr...)
+	pseudoHeader = append(pseudoHeader, 0, protocol)
+	pseudoHeader = binary.BigEndian.AppendUint16(pseudoHeader, uint16(len(transport)))
+	transportChecksum := ^checksum(checksum(0, pseudoHeader), transport)
+	if segment != nil {
+		binary.BigEndian.PutUint16(transport[16:], transportChecksum)
+	} else {
+		if transportChecksum == 0 {
+			transportChecksum = 0xffff
+		}
+		binary.BigEndian.PutUint16(transport[6:], transportChecksum)
+	}
+	return packet
+}
+
+func checksum(initial uint16, data []byte) uint16 {
+	sum := uint32(initial)
+	for len(data) >= 2 {
+		sum += uint32(binary.BigEndian.Uint16(data))
+		data = data[2:]
+	}
+	if len(data) > 0 {
+		sum += uint32(data[0]) << 8
+	}
+	for sum > 0xffff {
+		sum = sum>>16 + sum&0xffff
+	}
+	return uint16(sum)
+}
@@ -0,0 +1,98 @@
+package capture
+
+import (
+	"context"
+	"net"
+	"os"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/humanize"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/logger"
+	N "github.com/sagernet/sing/common/network"
+	"github.com/sagernet/sing/service/filemanager"
+)
+
+var (
+	_ adapter.LifecycleService  = (*Service)(nil)
+	_ adapter.ConnectionTracker = (*Service)(nil)
+)
+
+// Service writes a packet capture to a file from start until a limit is
+// reached or sing-box exits.
+type Service struct {
+	ctx     context.Context
+	logger  logger.ContextLogger
+	path    string
+	session *Session
+	file    *os.File
+}
+
+func NewService(ctx context.Context, logger logger.ContextLogger, options option.PacketCaptureOptions) (*Service, error) {
+	if options.Path == "" {
+		return nil, E.New("missing path")
+	}
+	session, err := NewSession(Options{
+		Inbound:  options.Inbound,
+		Outbound: options.Outbound,
+		Filter:   options.Filter,
+		MaxSize:  uint64(options.MaxSize),
+		Duration: time.Duration(options.Duration),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Service{
+		ctx:     ctx,
+		logger:  logger,
+		path:    filemanager.BasePath(ctx, options.Path),
+		session: session,
+	}, nil
+}
+
+func (s *Service) Name() string {
+	return "packet capture to " + s.path
+}
+
+func (s *Service) Start(stage adapter.StartStage) error {
+	if stage != adapter.StartStateStart {
+		return nil
+	}
+	file, err := filemanager.Create(s.ctx, s.path)
+	if err != nil {
+		return E.Cause(err, "create capture file")
+	}
+	err = s.session.Start(file)
+	if err != nil {
+		file.Close()
+		return E.Cause(err, "write capture file")
+	}
+	s.file = file
+	s.logger.Info("capturing to ", s.path)
+	go s.loopDone()
+	return nil
+}
+
+func (s *Service) loopDone() {
+	<-s.session.Done()
+	packets, size := s.session.Stats()
+	if err := s.session.Err(); err != nil {
+		s.logger.Error(E.Cause(err, "write capture file"))
+	}
+	s.logger.Info("capture to ", s.path, " finished: ", packets, " packets, ", humanize.MemoryBytes(size))
+	s.file.Close()
+}
+
+func (s *Service) Close() error {
+	return s.session.Close()
+}
+
+func (s *Service) RoutedConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, matchedRule adapter.Rule, matchOutbound adapter.Outbound) net.Conn {
+	return s.session.RoutedConnection(ctx, conn, metadata, matchedRule, matchOutbound)
+}
+
+func (s *Service) RoutedPacketConnection(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext, matchedRule adapter.Rule, matchOutbound adapter.Outbound) N.PacketConn {
+	return s.session.RoutedPacketConnection(ctx, conn, metadata, matchedRule, matchOutbound)
+}
@@ -0,0 +1,207 @@
+package capture
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+type Options struct {
+	Inbound  []string
+	Outbound []string
+	Filter   string
+	MaxSize  uint64
+	Duration time.Duration
+}
+
+var _ adapter.ConnectionTracker = (*Session)(nil)
+
+// Session writes routed connections matching the options to a pcap stream.
+//
+// Connections are captured as seen between inbounds and outbounds, so the
+// records contain the proxied payload with synthesized IP, TCP and UDP
+// headers, not packets on the wire.
+type Session struct {
+	inbound  []string
+	outbound []string
+	filter   *Filter
+	maxSize  uint64
+	duration time.Duration
+	access   sync.Mutex
+	writer   io.Writer
+	timer    *time.Timer
+	size     uint64
+	packets  uint64
+	closed   bool
+	err      error
+	done     chan struct{}
+}
+
+func NewSession(options Options) (*Session, error) {
+	filter, err := ParseFilter(options.Filter)
+	if err != nil {
+		return nil, E.Cause(err, "parse filter")
+	}
+	return &Session{
+		inbound:  options.Inbound,
+		outbound: options.Outbound,
+		filter:   filter,
+		maxSize:  options.MaxSize,
+		duration: options.Duration,
+		done:     make(chan struct{}),
+	}, nil
+}
+
+// Start writes the pcap header, connections are captured after.
+func (s *Session) Start(writer io.Writer) error {
+	s.access.Lock()
+	defer s.access.Unlock()
+	if s.closed {
+		return E.New("session closed")
+	}
+	header := pcapHeader()
+	_, err := writer.Write(header)
+	if err != nil {
+		return err
+	}
+	s.writer = writer
+	s.size = uint64(len(header))
+	if s.duration > 0 {
+		s.timer = time.AfterFunc(s.duration, func() {
+			s.access.Lock()
+			defer s.access.Unlock()
+			s.closeLocked(nil)
+		})
+	}
+	return nil
+}
+
+// Done is closed when the session is closed or a limit is reached.
+func (s *Session) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err returns the write error that stopped the session.
+func (s *Session) Err() error {
+	s.access.Lock()
+	defer s.access.Unlock()
+	return s.err
+}
+
+// Stats returns the number of captured packets and written bytes.
+func (s *Session) Stats() (packets uint64, size uint64) {
+	s.access.Lock()
+	defer s.access.Unlock()
+	return s.packets, s.size
+}
+
+func (s *Session) Close() error {
+	s.access.Lock()
+	defer s.access.Unlock()
+	s.closeLocked(nil)
+	return nil
+}
+
+func (s *Session) closeLocked(err error) {
+	if s.closed {
+		return
+	}
+	s.closed = true
+	s.err = err
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	close(s.done)
+}
+
+func (s *Session) RoutedConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, matchedRule adapter.Rule, matchOutbound adapter.Outbound) net.Conn {
+	if !s.match(&metadata, matchOutbound) {
+		return conn
+	}
+	return newStreamConn(conn, s, metadata)
+}
+
+func (s *Session) RoutedPacketConnection(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext, matchedRule adapter.Rule, matchOutbound adapter.Outbound) N.PacketConn {
+	if !s.match(&metadata, matchOutbound) {
+		return conn
+	}
+	return newPacketConn(conn, s, metadata)
+}
+
+func (s *Session) match(metadata *adapter.InboundContext, matchOutbound adapter.Outbound) bool {
+	s.access.Lock()
+	running := s.writer != nil && !s.closed
+	s.access.Unlock()
+	if !running {
+		return false
+	}
+	if len(s.inbound) > 0 && !common.Contains(s.inbound, metadata.Inbound) {
+		return false
+	}
+	if len(s.outbound) > 0 && (matchOutbound == nil || !common.Contains(s.outbound, matchOutbound.Tag())) {
+		return false
+	}
+	return s.filter.Match(metadata)
+}
+
+func (s *Session) writePacket(packet []byte) {
+	record := pcapRecord(time.Now(), packet)
+	s.access.Lock()
+	defer s.access.Unlock()
+	if s.closed {
+		return
+	}
+	if s.maxSize > 0 && s.size+uint64(len(record)) > s.maxSize {
+		s.closeLocked(nil)
+		return
+	}
+	_, err := s.writer.Write(record)
+	if err != nil {
+		s.closeLocked(err)
+		return
+	}
+	s.size += uint64(len(record))
+	s.packets++
+}
+
+// endpoints returns the addresses for synthesized packets of the connection,
+// unknown addresses are left unspecified.
+func endpoints(source M.Socksaddr, destination M.Socksaddr, destinationAddresses []netip.Addr) (netip.AddrPort, netip.AddrPort) {
+	var sourceAddr, destinationAddr netip.Addr
+	if source.IsIP() {
+		sourceAddr = source.Addr.Unmap()
+	}
+	if destination.IsIP() {
+		destinationAddr = destination.Addr.Unmap()
+	} else if len(destinationAddresses) > 0 {
+		destinationAddr = destinationAddresses[0].Unmap()
+	}
+	if !sourceAddr.IsValid() && !destinationAddr.IsValid() {
+		sourceAddr = netip.IPv4Unspecified()
+	}
+	if !sourceAddr.IsValid() {
+		sourceAddr = unspecifiedOf(destinationAddr)
+	} else if !destinationAddr.IsValid() {
+		destinationAddr = unspecifiedOf(sourceAddr)
+	} else if sourceAddr.Is4() != destinationAddr.Is4() {
+		sourceAddr = netip.AddrFrom16(sourceAddr.As16())
+		destinationAddr = netip.AddrFrom16(destinationAddr.As16())
+	}
+	return netip.AddrPortFrom(sourceAddr, source.Port), netip.AddrPortFrom(destinationAddr, destination.Port)
+}
+
+func unspecifiedOf(addr netip.Addr) netip.Addr {
+	if addr.Is4() {
+		return netip.IPv4Unspecified()
+	}
+	return netip.IPv6Unspecified()
+}
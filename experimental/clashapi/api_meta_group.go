@@ -78,7 +78,7 @@ func getGroupDelay(server *Server) func(w http.ResponseWriter, r *http.Request)
 			return
 		}
 
-		ctx, cancel := context.WithTimeout(r.Context(), time.Millisecond*time.Duration(timeout))
+		ctx, cancel := context.WithTimeout(urltest.ContextWithRootCAs(r.Context(), adapter.RootPoolFromContext(server.ctx)), time.Millisecond*time.Duration(timeout))
 		defer cancel()
 
 		var result map[string]uint16
@@ -105,15 +105,16 @@ func getGroupDelay(server *Server) func(w http.ResponseWriter, r *http.Request)
 					continue
 				}
 				b.Go(realTag, func() (any, error) {
-					t, err := urltest.URLTest(ctx, url, p)
+					t, breakdown, err := urltest.URLTestBreakdown(ctx, url, p)
 					if err != nil {
 						server.logger.Debug("outbound ", tag, " unavailable: ", err)
-						server.urlTestHistory.DeleteURLTestHistory(realTag)
+						server.urlTestHistory.DeleteURLTestHistoryWithError(realTag, err)
 					} else {
 						server.logger.Debug("outbound ", tag, " available: ", t, "ms")
 						server.urlTestHistory.StoreURLTestHistory(realTag, &urltest.History{
-							Time:  time.Now(),
-							Delay: t,
+							Time:      time.Now(),
+							Delay:     t,
+							Breakdown: breakdown,
 						})
 						resultAccess.Lock()
 						result[tag] = t
@@ -0,0 +1,161 @@
+package clashapi
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/autoupdate"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+func upgradeRouter(server *Server) http.Handler {
+	r := chi.NewRouter()
+	r.Post("/ui", updateExternalUI(server))
+	r.Post("/core", upgradeCore(server))
+	r.Post("/geo", upgradeGeo(server))
+	return r
+}
+
+func updateExternalUI(server *Server) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if server.externalUI == "" {
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, newError("external ui not configured"))
+			return
+		}
+		err := server.downloadExternalUI()
+		if err != nil {
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, newError(err.Error()))
+			return
+		}
+		render.NoContent(w, r)
+	}
+}
+
+func upgradeCore(server *Server) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if server.coreUpdater == nil {
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, newError("core upgrade not configured"))
+			return
+		}
+		server.logger.Info("upgrade core: started")
+		err := server.coreUpdater.Update(r.Context())
+		if err != nil {
+			server.logger.Error("upgrade core: ", err)
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, newError(err.Error()))
+			return
+		}
+		if server.coreUpgraded.Load() {
+			server.logger.Info("upgrade core: finished, restart sing-box to apply")
+		} else {
+			server.logger.Info("upgrade core: finished")
+		}
+		render.NoContent(w, r)
+	}
+}
+
+// upgradeGeo updates the geo databases and remote rule-sets, the progress is
+// reported in logs.
+func upgradeGeo(server *Server) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !server.geoUpgradeAccess.TryLock() {
+			render.Status(r, http.StatusConflict)
+			render.JSON(w, r, newError("upgrade in progress"))
+			return
+		}
+		defer server.geoUpgradeAccess.Unlock()
+		err := server.upgradeGeo(r.Context())
+		if err != nil {
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, newError(err.Error()))
+			return
+		}
+		render.NoContent(w, r)
+	}
+}
+
+func (s *Server) upgradeGeo(ctx context.Context) error {
+	var remoteRuleSets []adapter.RuleSet
+	for _, ruleSet := range s.router.RuleSets() {
+		if ruleSet.Type() == C.RuleSetTypeRemote {
+			remoteRuleSets = append(remoteRuleSets, ruleSet)
+		}
+	}
+	s.logger.Info("upgrade geo: started, ", len(remoteRuleSets), " remote rule-sets")
+	var errors []error
+	err := s.router.UpdateGeoDatabases(ctx)
+	if err != nil {
+		s.logger.Error("upgrade geo: ", err)
+		errors = append(errors, err)
+	}
+	for index, ruleSet := range remoteRuleSets {
+		s.logger.Info("upgrade geo: [", index+1, "/", len(remoteRuleSets), "] updating rule-set ", ruleSet.Name())
+		err = ruleSet.Update(ctx)
+		if err != nil {
+			s.logger.Error("upgrade geo: update rule-set ", ruleSet.Name(), ": ", err)
+			errors = append(errors, E.Cause(err, "update rule-set ", ruleSet.Name()))
+		}
+	}
+	if len(errors) > 0 {
+		s.logger.Warn("upgrade geo: finished with ", len(errors), " errors")
+	} else {
+		s.logger.Info("upgrade geo: finished")
+	}
+	return E.Errors(errors...)
+}
+
+func (s *Server) prepareCoreUpgrade(options option.ClashAPIOptions) error {
+	if C.IsAndroid || C.IsIos {
+		return E.New("core upgrade is not supported on ", runtime.GOOS)
+	}
+	if options.CoreUpgradeChecksumURL == "" {
+		return E.New("missing core_upgrade_checksum_url")
+	}
+	executablePath, err := os.Executable()
+	if err != nil {
+		return E.Cause(err, "find executable")
+	}
+	executablePath, err = filepath.EvalSymlinks(executablePath)
+	if err != nil {
+		return E.Cause(err, "find executable")
+	}
+	s.coreUpdater, err = autoupdate.New(s.ctx, s.logger, "sing-box core", autoupdate.Options{
+		Path:           executablePath,
+		DownloadURL:    options.CoreUpgradeURL,
+		DownloadDetour: options.CoreUpgradeDetour,
+		ChecksumURL:    options.CoreUpgradeChecksumURL,
+	}, s.loadCore)
+	return err
+}
+
+// loadCore checks that the downloaded executable runs on this system.
+func (s *Server) loadCore(path string) error {
+	err := os.Chmod(path, 0o755)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(s.ctx, C.StartTimeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, path, "version").Output()
+	if err != nil {
+		return E.Cause(err, "run new core")
+	}
+	versionLine, _, _ := strings.Cut(string(output), "\n")
+	s.logger.Info("upgrade core: downloaded ", versionLine)
+	s.coreUpgraded.Store(true)
+	return nil
+}
@@ -11,23 +11,27 @@ import (
 	"github.com/go-chi/render"
 )
 
-func cacheRouter(ctx context.Context) http.Handler {
+func cacheRouter(ctx context.Context, router adapter.Router) http.Handler {
 	r := chi.NewRouter()
-	r.Post("/fakeip/flush", flushFakeip(ctx))
+	r.Post("/fakeip/flush", flushFakeip(ctx, router))
 	return r
 }
 
-func flushFakeip(ctx context.Context) func(w http.ResponseWriter, r *http.Request) {
+func flushFakeip(ctx context.Context, router adapter.Router) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		cacheFile := service.FromContext[adapter.CacheFile](ctx)
-		if cacheFile != nil {
-			err := cacheFile.FakeIPReset()
-			if err != nil {
-				render.Status(r, http.StatusInternalServerError)
-				render.JSON(w, r, newError(err.Error()))
-				return
-			}
+		var err error
+		if fakeIPStore := router.FakeIPStore(); fakeIPStore != nil {
+			err = fakeIPStore.Reset()
+		} else if cacheFile := service.FromContext[adapter.CacheFile](ctx); cacheFile != nil {
+			err = cacheFile.FakeIPReset()
 		}
+		if err != nil {
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, newError(err.Error()))
+			return
+		}
+		// cached responses may still contain flushed addresses
+		router.ClearDNSCache()
 		render.NoContent(w, r)
 	}
 }
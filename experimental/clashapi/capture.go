@@ -0,0 +1,100 @@
+package clashapi
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sagernet/sing-box/common/humanize"
+	"github.com/sagernet/sing-box/experimental/capture"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+
+	"github.com/go-chi/render"
+)
+
+// capturePackets streams a pcap of matching connections until the client
+// disconnects or a limit is reached.
+func capturePackets(server *Server) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		options, err := parseCaptureQuery(r.URL.Query())
+		if err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, newError(err.Error()))
+			return
+		}
+		session, err := capture.NewSession(options)
+		if err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, newError(err.Error()))
+			return
+		}
+		w.Header().Set("Content-Type", "application/vnd.tcpdump.pcap")
+		w.Header().Set("Content-Disposition", "attachment; filename=capture.pcap")
+		w.WriteHeader(http.StatusOK)
+		err = session.Start(&flushWriter{w})
+		if err != nil {
+			return
+		}
+		server.captureAccess.Lock()
+		server.captureSessions = append(server.captureSessions, session)
+		server.captureAccess.Unlock()
+		server.logger.Info("packet capture started")
+		select {
+		case <-r.Context().Done():
+		case <-session.Done():
+		case <-server.ctx.Done():
+		}
+		server.captureAccess.Lock()
+		server.captureSessions = common.Filter(server.captureSessions, func(it *capture.Session) bool {
+			return it != session
+		})
+		server.captureAccess.Unlock()
+		// wait for pending writes to the response
+		session.Close()
+		packets, size := session.Stats()
+		server.logger.Info("packet capture finished: ", packets, " packets, ", humanize.MemoryBytes(size))
+	}
+}
+
+func parseCaptureQuery(values url.Values) (capture.Options, error) {
+	options := capture.Options{
+		Inbound:  splitQueryList(values.Get("inbound")),
+		Outbound: splitQueryList(values.Get("outbound")),
+		Filter:   values.Get("filter"),
+	}
+	if duration := values.Get("duration"); duration != "" {
+		parsed, err := time.ParseDuration(duration)
+		if err != nil || parsed < 0 {
+			return options, E.New("invalid duration: ", duration)
+		}
+		options.Duration = parsed
+	}
+	if maxSize := values.Get("max_size"); maxSize != "" {
+		parsed, err := humanize.ParseMemoryBytes(maxSize)
+		if err != nil {
+			return options, E.New("invalid max_size: ", maxSize)
+		}
+		options.MaxSize = parsed
+	}
+	return options, nil
+}
+
+func splitQueryList(value string) []string {
+	return common.Filter(strings.Split(value, ","), func(it string) bool {
+		return it != ""
+	})
+}
+
+type flushWriter struct {
+	http.ResponseWriter
+}
+
+func (w *flushWriter) Write(p []byte) (n int, err error) {
+	n, err = w.ResponseWriter.Write(p)
+	if flusher, isFlusher := w.ResponseWriter.(http.Flusher); isFlusher {
+		flusher.Flush()
+	}
+	return
+}
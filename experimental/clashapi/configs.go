@@ -14,6 +14,8 @@ func configRouter(server *Server, logFactory log.Factory) http.Handler {
 	r.Get("/", getConfigs(server, logFactory))
 	r.Put("/", updateConfigs)
 	r.Patch("/", patchConfigs(server))
+	// Clash.Meta
+	r.Post("/geo", upgradeGeo(server))
 	return r
 }
 
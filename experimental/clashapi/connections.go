@@ -3,12 +3,18 @@ package clashapi
 import (
 	"bytes"
 	"net/http"
+	"net/url"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/sagernet/sing-box/adapter"
 	"github.com/sagernet/sing-box/experimental/clashapi/trafficontrol"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
 	"github.com/sagernet/sing/common/json"
+	N "github.com/sagernet/sing/common/network"
 	"github.com/sagernet/ws"
 	"github.com/sagernet/ws/wsutil"
 
@@ -27,8 +33,15 @@ func connectionRouter(router adapter.Router, trafficManager *trafficontrol.Manag
 
 func getConnections(trafficManager *trafficontrol.Manager) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
+		query, err := parseConnectionQuery(r.URL.Query())
+		if err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, newError(err.Error()))
+			return
+		}
 		if r.Header.Get("Upgrade") != "websocket" {
 			snapshot := trafficManager.Snapshot()
+			query.apply(snapshot)
 			render.JSON(w, r, snapshot)
 			return
 		}
@@ -55,6 +68,7 @@ func getConnections(trafficManager *trafficontrol.Manager) func(w http.ResponseW
 		sendSnapshot := func() error {
 			buf.Reset()
 			snapshot := trafficManager.Snapshot()
+			query.apply(snapshot)
 			if err := json.NewEncoder(buf).Encode(snapshot); err != nil {
 				return err
 			}
@@ -75,6 +89,167 @@ func getConnections(trafficManager *trafficontrol.Manager) func(w http.ResponseW
 	}
 }
 
+// connectionQuery filters, orders and paginates the connections of a snapshot.
+type connectionQuery struct {
+	outbound   string
+	host       string
+	network    string
+	minAge     time.Duration
+	sortBy     string
+	descending bool
+	offset     int
+	limit      int
+}
+
+func parseConnectionQuery(values url.Values) (*connectionQuery, error) {
+	query := &connectionQuery{
+		outbound: values.Get("outbound"),
+		host:     strings.ToLower(strings.TrimPrefix(values.Get("host"), ".")),
+		network:  strings.ToLower(values.Get("network")),
+		sortBy:   values.Get("sort"),
+	}
+	switch query.network {
+	case "", N.NetworkTCP, N.NetworkUDP:
+	default:
+		return nil, E.New("invalid network: ", query.network)
+	}
+	if minAge := values.Get("min_age"); minAge != "" {
+		duration, err := time.ParseDuration(minAge)
+		if err != nil {
+			return nil, E.New("invalid min_age: ", minAge)
+		}
+		query.minAge = duration
+	}
+	switch query.sortBy {
+	case "", "start", "upload", "download":
+	default:
+		return nil, E.New("invalid sort: ", query.sortBy)
+	}
+	switch order := values.Get("order"); order {
+	case "", "asc":
+	case "desc":
+		query.descending = true
+	default:
+		return nil, E.New("invalid order: ", order)
+	}
+	if offset := values.Get("offset"); offset != "" {
+		var err error
+		query.offset, err = strconv.Atoi(offset)
+		if err != nil || query.offset < 0 {
+			return nil, E.New("invalid offset: ", offset)
+		}
+	}
+	if limit := values.Get("limit"); limit != "" {
+		var err error
+		query.limit, err = strconv.Atoi(limit)
+		if err != nil || query.limit <= 0 {
+			return nil, E.New("invalid limit: ", limit)
+		}
+	}
+	if query.sortBy == "" && (query.offset > 0 || query.limit > 0) {
+		// pages are only stable in a fixed order
+		query.sortBy = "start"
+	}
+	return query, nil
+}
+
+func (q *connectionQuery) apply(snapshot *trafficontrol.Snapshot) {
+	var (
+		connections []trafficontrol.Tracker
+		keys        []connectionSortKey
+	)
+	now := time.Now()
+	for _, connection := range snapshot.Connections {
+		metadata := connection.Metadata()
+		if !q.match(metadata, now) {
+			continue
+		}
+		connections = append(connections, connection)
+		if q.sortBy != "" {
+			// counters are read once, as they keep changing while sorting
+			key := connectionSortKey{id: metadata.ID, createdAt: metadata.CreatedAt}
+			switch q.sortBy {
+			case "upload":
+				key.value = metadata.Upload.Load()
+			case "download":
+				key.value = metadata.Download.Load()
+			}
+			keys = append(keys, key)
+		}
+	}
+	if q.sortBy != "" {
+		sort.Sort(&connectionSorter{connections, keys, q.descending})
+	}
+	snapshot.Total = len(connections)
+	if q.offset >= len(connections) {
+		connections = nil
+	} else {
+		connections = connections[q.offset:]
+	}
+	if q.limit > 0 && len(connections) > q.limit {
+		connections = connections[:q.limit]
+	}
+	snapshot.Connections = connections
+}
+
+func (q *connectionQuery) match(metadata trafficontrol.TrackerMetadata, now time.Time) bool {
+	if q.outbound != "" && metadata.Outbound != q.outbound && !common.Contains(metadata.Chain, q.outbound) {
+		return false
+	}
+	if q.network != "" && metadata.Metadata.Network != q.network {
+		return false
+	}
+	if q.minAge > 0 && now.Sub(metadata.CreatedAt) < q.minAge {
+		return false
+	}
+	if q.host != "" {
+		host := metadata.Metadata.Destination.Fqdn
+		if host == "" {
+			host = metadata.Metadata.Domain
+		}
+		host = strings.ToLower(host)
+		if host != q.host && !strings.HasSuffix(host, "."+q.host) {
+			return false
+		}
+	}
+	return true
+}
+
+type connectionSortKey struct {
+	id        uuid.UUID
+	createdAt time.Time
+	value     int64
+}
+
+type connectionSorter struct {
+	connections []trafficontrol.Tracker
+	keys        []connectionSortKey
+	descending  bool
+}
+
+func (s *connectionSorter) Len() int {
+	return len(s.connections)
+}
+
+func (s *connectionSorter) Less(i, j int) bool {
+	if s.descending {
+		i, j = j, i
+	}
+	left, right := s.keys[i], s.keys[j]
+	if left.value != right.value {
+		return left.value < right.value
+	}
+	if !left.createdAt.Equal(right.createdAt) {
+		return left.createdAt.Before(right.createdAt)
+	}
+	return bytes.Compare(left.id[:], right.id[:]) < 0
+}
+
+func (s *connectionSorter) Swap(i, j int) {
+	s.connections[i], s.connections[j] = s.connections[j], s.connections[i]
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+}
+
 func closeConnection(trafficManager *trafficontrol.Manager) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := uuid.FromStringOrNil(chi.URLParam(r, "id"))
@@ -13,12 +13,22 @@ import (
 	"github.com/miekg/dns"
 )
 
-func dnsRouter(router adapter.Router) http.Handler {
+func dnsRouter(router adapter.Router, stats *DNSStats) http.Handler {
 	r := chi.NewRouter()
 	r.Get("/query", queryDNS(router))
+	r.Post("/flush", flushDNS(router))
+	r.Get("/stats", getDNSStats(stats))
+	r.Delete("/stats", resetDNSStats(stats))
 	return r
 }
 
+func flushDNS(router adapter.Router) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		router.ClearDNSCache()
+		render.NoContent(w, r)
+	}
+}
+
 func queryDNS(router adapter.Router) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		name := r.URL.Query().Get("name")
@@ -0,0 +1,122 @@
+package clashapi
+
+import (
+	"net/http"
+	"net/netip"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/sagernet/sing-box/adapter"
+
+	"github.com/go-chi/render"
+)
+
+// dnsStatsMaxEntries limits the number of tracked domains and clients,
+// new entries are only counted in totals once the limit is reached.
+const dnsStatsMaxEntries = 10000
+
+var _ adapter.DNSQueryTracker = (*DNSStats)(nil)
+
+type DNSStats struct {
+	access         sync.Mutex
+	total          uint64
+	blocked        uint64
+	blockedDomains map[string]uint64
+	blockedClients map[netip.Addr]uint64
+}
+
+func NewDNSStats() *DNSStats {
+	return &DNSStats{
+		blockedDomains: make(map[string]uint64),
+		blockedClients: make(map[netip.Addr]uint64),
+	}
+}
+
+func (s *DNSStats) TrackDNSQuery(domain string, source netip.Addr, blocked bool) {
+	s.access.Lock()
+	defer s.access.Unlock()
+	s.total++
+	if !blocked {
+		return
+	}
+	s.blocked++
+	if _, loaded := s.blockedDomains[domain]; loaded || len(s.blockedDomains) < dnsStatsMaxEntries {
+		s.blockedDomains[domain]++
+	}
+	if !source.IsValid() {
+		return
+	}
+	if _, loaded := s.blockedClients[source]; loaded || len(s.blockedClients) < dnsStatsMaxEntries {
+		s.blockedClients[source]++
+	}
+}
+
+func (s *DNSStats) Reset() {
+	s.access.Lock()
+	defer s.access.Unlock()
+	s.total = 0
+	s.blocked = 0
+	s.blockedDomains = make(map[string]uint64)
+	s.blockedClients = make(map[netip.Addr]uint64)
+}
+
+type DNSStatsEntry struct {
+	Name  string `json:"name"`
+	Count uint64 `json:"count"`
+}
+
+func (s *DNSStats) Snapshot(limit int) render.M {
+	s.access.Lock()
+	defer s.access.Unlock()
+	topDomains := make([]DNSStatsEntry, 0, len(s.blockedDomains))
+	for domain, count := range s.blockedDomains {
+		topDomains = append(topDomains, DNSStatsEntry{Name: domain, Count: count})
+	}
+	topClients := make([]DNSStatsEntry, 0, len(s.blockedClients))
+	for client, count := range s.blockedClients {
+		topClients = append(topClients, DNSStatsEntry{Name: client.String(), Count: count})
+	}
+	return render.M{
+		"total":      s.total,
+		"blocked":    s.blocked,
+		"topBlocked": topEntries(topDomains, limit),
+		"topClients": topEntries(topClients, limit),
+	}
+}
+
+func topEntries(entries []DNSStatsEntry, limit int) []DNSStatsEntry {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Count != entries[j].Count {
+			return entries[i].Count > entries[j].Count
+		}
+		return entries[i].Name < entries[j].Name
+	})
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries
+}
+
+func getDNSStats(stats *DNSStats) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := 10
+		if limitString := r.URL.Query().Get("limit"); limitString != "" {
+			var err error
+			limit, err = strconv.Atoi(limitString)
+			if err != nil || limit <= 0 {
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, newError("invalid limit"))
+				return
+			}
+		}
+		render.JSON(w, r, stats.Snapshot(limit))
+	}
+}
+
+func resetDNSStats(stats *DNSStats) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		stats.Reset()
+		render.NoContent(w, r)
+	}
+}
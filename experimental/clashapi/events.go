@@ -0,0 +1,56 @@
+package clashapi
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/sagernet/sing-box/common/urltest"
+	"github.com/sagernet/sing/common"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+func eventRouter(history *urltest.HistoryStorage) http.Handler {
+	r := chi.NewRouter()
+	r.Get("/", getEvents(history))
+	r.Delete("/", clearEvents(history))
+	return r
+}
+
+func getEvents(history *urltest.HistoryStorage) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		events := history.Events()
+		if group := r.URL.Query().Get("group"); group != "" {
+			events = common.Filter(events, func(it urltest.Event) bool {
+				return it.Group == group
+			})
+		}
+		if outbound := r.URL.Query().Get("outbound"); outbound != "" {
+			events = common.Filter(events, func(it urltest.Event) bool {
+				return it.Outbound == outbound || it.Previous == outbound
+			})
+		}
+		if limitText := r.URL.Query().Get("limit"); limitText != "" {
+			limit, err := strconv.Atoi(limitText)
+			if err != nil || limit < 0 {
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, ErrBadRequest)
+				return
+			}
+			if len(events) > limit {
+				events = events[len(events)-limit:]
+			}
+		}
+		render.JSON(w, r, render.M{
+			"events": events,
+		})
+	}
+}
+
+func clearEvents(history *urltest.HistoryStorage) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		history.ClearEvents()
+		render.NoContent(w, r)
+	}
+}
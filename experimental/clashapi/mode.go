@@ -0,0 +1,84 @@
+package clashapi
+
+import (
+	"strings"
+
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+// customMode is a mode defined in config, which overrides rule groups and
+// the final outbound while selected.
+type customMode struct {
+	name       string
+	ruleGroups map[string]bool
+	final      string
+}
+
+func newCustomModes(options []option.ClashModeOptions) ([]*customMode, error) {
+	var modes []*customMode
+	for i, modeOptions := range options {
+		if modeOptions.Name == "" {
+			return nil, E.New("parse modes[", i, "]: missing name")
+		}
+		if common.Any(modes, func(it *customMode) bool {
+			return strings.EqualFold(it.name, modeOptions.Name)
+		}) {
+			return nil, E.New("parse modes[", i, "]: duplicate mode: ", modeOptions.Name)
+		}
+		mode := &customMode{
+			name:       modeOptions.Name,
+			ruleGroups: make(map[string]bool),
+			final:      modeOptions.Final,
+		}
+		for _, group := range modeOptions.EnableRuleGroups {
+			mode.ruleGroups[group] = true
+		}
+		for _, group := range modeOptions.DisableRuleGroups {
+			if _, loaded := mode.ruleGroups[group]; loaded {
+				return nil, E.New("parse modes[", i, "]: rule group both enabled and disabled: ", group)
+			}
+			mode.ruleGroups[group] = false
+		}
+		modes = append(modes, mode)
+	}
+	return modes, nil
+}
+
+// checkModes checks rule groups and final outbounds of custom modes, must be
+// called after the router and outbounds are initialized.
+func (s *Server) checkModes() error {
+	ruleGroups := make(map[string]bool)
+	for _, group := range s.router.RuleGroups() {
+		ruleGroups[group.Name] = true
+	}
+	for _, mode := range s.modes {
+		for group := range mode.ruleGroups {
+			if !ruleGroups[group] {
+				return E.New("mode ", mode.name, ": rule group not found: ", group)
+			}
+		}
+		if mode.final != "" {
+			if _, loaded := s.outbound.Outbound(mode.final); !loaded {
+				return E.New("mode ", mode.name, ": final outbound not found: ", mode.final)
+			}
+		}
+	}
+	return nil
+}
+
+// applyMode sets the overlay of the mode to the router, or removes the
+// overlay if the mode is not a custom mode.
+func (s *Server) applyMode(name string) error {
+	if len(s.modes) == 0 {
+		return nil
+	}
+	mode := common.Find(s.modes, func(it *customMode) bool {
+		return strings.EqualFold(it.name, name)
+	})
+	if mode == nil {
+		return s.router.SetModeOverlay(nil, "")
+	}
+	return s.router.SetModeOverlay(mode.ruleGroups, mode.final)
+}
@@ -30,6 +30,8 @@ func proxyRouter(server *Server, router adapter.Router) http.Handler {
 		r.Get("/", getProxy(server))
 		r.Get("/delay", getProxyDelay(server))
 		r.Put("/", updateProxy)
+		r.Get("/filter", getProxyFilter)
+		r.Put("/filter", updateProxyFilter)
 	})
 	return r
 }
@@ -184,6 +186,42 @@ func updateProxy(w http.ResponseWriter, r *http.Request) {
 	render.NoContent(w, r)
 }
 
+func getProxyFilter(w http.ResponseWriter, r *http.Request) {
+	proxy := r.Context().Value(CtxKeyProxy).(adapter.Outbound)
+	group, ok := proxy.(adapter.FilterableOutboundGroup)
+	if !ok {
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, newError("Must be a Selector or URLTest"))
+		return
+	}
+	render.JSON(w, r, group.Filter())
+}
+
+func updateProxyFilter(w http.ResponseWriter, r *http.Request) {
+	var filter adapter.OutboundGroupFilter
+	if err := render.DecodeJSON(r.Body, &filter); err != nil {
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, ErrBadRequest)
+		return
+	}
+
+	proxy := r.Context().Value(CtxKeyProxy).(adapter.Outbound)
+	group, ok := proxy.(adapter.FilterableOutboundGroup)
+	if !ok {
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, newError("Must be a Selector or URLTest"))
+		return
+	}
+
+	if err := group.SetFilter(filter); err != nil {
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, newError(err.Error()))
+		return
+	}
+
+	render.NoContent(w, r)
+}
+
 func getProxyDelay(server *Server) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		query := r.URL.Query()
@@ -199,18 +237,19 @@ func getProxyDelay(server *Server) func(w http.ResponseWriter, r *http.Request)
 		}
 
 		proxy := r.Context().Value(CtxKeyProxy).(adapter.Outbound)
-		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*time.Duration(timeout))
+		ctx, cancel := context.WithTimeout(urltest.ContextWithRootCAs(context.Background(), adapter.RootPoolFromContext(server.ctx)), time.Millisecond*time.Duration(timeout))
 		defer cancel()
 
-		delay, err := urltest.URLTest(ctx, url, proxy)
+		delay, breakdown, err := urltest.URLTestBreakdown(ctx, url, proxy)
 		defer func() {
 			realTag := group.RealTag(proxy)
 			if err != nil {
-				server.urlTestHistory.DeleteURLTestHistory(realTag)
+				server.urlTestHistory.DeleteURLTestHistoryWithError(realTag, err)
 			} else {
 				server.urlTestHistory.StoreURLTestHistory(realTag, &urltest.History{
-					Time:  time.Now(),
-					Delay: delay,
+					Time:      time.Now(),
+					Delay:     delay,
+					Breakdown: breakdown,
 				})
 			}
 		}()
@@ -228,7 +267,11 @@ func getProxyDelay(server *Server) func(w http.ResponseWriter, r *http.Request)
 		}
 
 		render.JSON(w, r, render.M{
-			"delay": delay,
+			"delay":     delay,
+			"dns":       breakdown.DNS,
+			"connect":   breakdown.Connect,
+			"tls":       breakdown.TLS,
+			"firstByte": breakdown.FirstByte,
 		})
 	}
 }
@@ -0,0 +1,125 @@
+package clashapi
+
+import (
+	"net/http"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/process"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+func routeRouter(router adapter.Router) http.Handler {
+	r := chi.NewRouter()
+	r.Post("/test", testRoute(router))
+	r.Get("/sniff", getSniffBufferUsage(router))
+	return r
+}
+
+type RouteTestRequest struct {
+	Inbound     string `json:"inbound"`
+	InboundType string `json:"inbound_type"`
+	Network     string `json:"network"`
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Protocol    string `json:"protocol"`
+	Domain      string `json:"domain"`
+	Client      string `json:"client"`
+	User        string `json:"user"`
+	ProcessName string `json:"process_name"`
+	ProcessPath string `json:"process_path"`
+	PackageName string `json:"package_name"`
+}
+
+type RouteTestMatch struct {
+	Index  int    `json:"index"`
+	Rule   string `json:"rule"`
+	Action string `json:"action"`
+}
+
+func testRoute(router adapter.Router) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var request RouteTestRequest
+		err := render.DecodeJSON(r.Body, &request)
+		if err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, ErrBadRequest)
+			return
+		}
+		destination := M.ParseSocksaddr(request.Destination)
+		if !destination.IsValid() {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, newError("invalid destination"))
+			return
+		}
+		metadata := adapter.InboundContext{
+			Inbound:     request.Inbound,
+			InboundType: request.InboundType,
+			Network:     N.NetworkName(request.Network),
+			Destination: destination,
+			User:        request.User,
+			Protocol:    request.Protocol,
+			Domain:      request.Domain,
+			Client:      request.Client,
+		}
+		if metadata.Network == "" {
+			metadata.Network = N.NetworkTCP
+		}
+		if request.Source != "" {
+			metadata.Source = M.ParseSocksaddr(request.Source)
+		}
+		if request.ProcessPath == "" {
+			// process_name rules match the base name of the process path
+			request.ProcessPath = request.ProcessName
+		}
+		if request.ProcessPath != "" || request.PackageName != "" {
+			metadata.ProcessInfo = &process.Info{
+				ProcessPath: request.ProcessPath,
+				PackageName: request.PackageName,
+				UserId:      -1,
+			}
+		}
+		result := router.TestRoute(r.Context(), metadata)
+		response := render.M{
+			"outbound": result.Outbound,
+			"rules":    routeTestMatches(result.Rules),
+		}
+		if result.Context != "" {
+			response["context"] = result.Context
+		}
+		if result.DNSServer != "" {
+			response["dnsServer"] = result.DNSServer
+		}
+		if result.DNSRule != nil {
+			response["dnsRule"] = RouteTestMatch(*result.DNSRule)
+		}
+		render.JSON(w, r, response)
+	}
+}
+
+func routeTestMatches(matches []adapter.RouteTestMatch) []RouteTestMatch {
+	routeMatches := make([]RouteTestMatch, 0, len(matches))
+	for _, match := range matches {
+		routeMatches = append(routeMatches, RouteTestMatch(match))
+	}
+	return routeMatches
+}
+
+func getSniffBufferUsage(router adapter.Router) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		inbounds := render.M{}
+		for inbound, usage := range router.SniffBufferUsage() {
+			inbounds[inbound] = render.M{
+				"used":    usage.Used,
+				"limit":   usage.Limit,
+				"skipped": usage.Skipped,
+			}
+		}
+		render.JSON(w, r, render.M{
+			"inbounds": inbounds,
+		})
+	}
+}
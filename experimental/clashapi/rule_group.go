@@ -0,0 +1,71 @@
+package clashapi
+
+import (
+	"net/http"
+
+	"github.com/sagernet/sing-box/adapter"
+
+	"github.com/go-chi/render"
+)
+
+type RuleGroup struct {
+	Name       string `json:"name"`
+	Enabled    bool   `json:"enabled"`
+	Overridden bool   `json:"overridden"`
+	Rules      int    `json:"rules"`
+	DNSRules   int    `json:"dnsRules"`
+}
+
+func getRuleGroups(router adapter.Router) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		groups := make([]RuleGroup, 0)
+		for _, group := range router.RuleGroups() {
+			groups = append(groups, RuleGroup{
+				Name:       group.Name,
+				Enabled:    group.Enabled,
+				Overridden: group.Overridden,
+				Rules:      group.RuleCount,
+				DNSRules:   group.DNSRuleCount,
+			})
+		}
+		render.JSON(w, r, render.M{
+			"groups": groups,
+		})
+	}
+}
+
+type UpdateRuleGroupRequest struct {
+	Enabled *bool `json:"enabled"`
+}
+
+func updateRuleGroup(router adapter.Router) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var request UpdateRuleGroupRequest
+		err := render.DecodeJSON(r.Body, &request)
+		if err != nil || request.Enabled == nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, ErrBadRequest)
+			return
+		}
+		name := getEscapeParam(r, "name")
+		var loaded bool
+		for _, group := range router.RuleGroups() {
+			if group.Name == name {
+				loaded = true
+				break
+			}
+		}
+		if !loaded {
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, ErrNotFound)
+			return
+		}
+		err = router.SetRuleGroupEnabled(name, *request.Enabled)
+		if err != nil {
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, newError(err.Error()))
+			return
+		}
+		render.NoContent(w, r)
+	}
+}
@@ -12,6 +12,8 @@ import (
 func ruleRouter(router adapter.Router) http.Handler {
 	r := chi.NewRouter()
 	r.Get("/", getRules(router))
+	r.Get("/groups", getRuleGroups(router))
+	r.Put("/groups/{name}", updateRuleGroup(router))
 	return r
 }
 
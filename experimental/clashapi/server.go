@@ -3,20 +3,26 @@ package clashapi
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"net"
 	"net/http"
 	"os"
 	"runtime"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/sagernet/cors"
 	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/autoupdate"
 	"github.com/sagernet/sing-box/common/urltest"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/experimental"
+	"github.com/sagernet/sing-box/experimental/capture"
 	"github.com/sagernet/sing-box/experimental/clashapi/trafficontrol"
 	"github.com/sagernet/sing-box/log"
 	"github.com/sagernet/sing-box/option"
@@ -44,18 +50,30 @@ type Server struct {
 	router         adapter.Router
 	outbound       adapter.OutboundManager
 	endpoint       adapter.EndpointManager
-	logger         log.Logger
+	logger         log.ContextLogger
 	httpServer     *http.Server
 	trafficManager *trafficontrol.Manager
+	dnsStats       *DNSStats
 	urlTestHistory *urltest.HistoryStorage
 	mode           string
 	modeList       []string
+	modes          []*customMode
 	modeUpdateHook chan<- struct{}
 
 	externalController       bool
 	externalUI               string
-	externalUIDownloadURL    string
+	externalUIDownloadURL    []string
+	externalUIDownloadSHA256 []byte
 	externalUIDownloadDetour string
+	externalUIUpdateInterval time.Duration
+	externalUIAccess         sync.Mutex
+	externalUIHash           []byte
+	coreUpdater              *autoupdate.Updater
+	coreUpgraded             atomic.Bool
+	geoUpgradeAccess         sync.Mutex
+	captureAccess            sync.Mutex
+	captureSessions          []*capture.Session
+	cancel                   context.CancelFunc
 }
 
 func NewServer(ctx context.Context, logFactory log.ObservableFactory, options option.ClashAPIOptions) (adapter.ClashServer, error) {
@@ -72,15 +90,41 @@ func NewServer(ctx context.Context, logFactory log.ObservableFactory, options op
 			Handler: chiRouter,
 		},
 		trafficManager:           trafficManager,
+		dnsStats:                 NewDNSStats(),
 		modeList:                 options.ModeList,
 		externalController:       options.ExternalController != "",
 		externalUIDownloadURL:    options.ExternalUIDownloadURL,
 		externalUIDownloadDetour: options.ExternalUIDownloadDetour,
+		externalUIUpdateInterval: time.Duration(options.ExternalUIUpdateInterval),
 	}
+	if options.ExternalUIDownloadSHA256 != "" {
+		downloadSHA256, err := hex.DecodeString(options.ExternalUIDownloadSHA256)
+		if err != nil || len(downloadSHA256) != sha256.Size {
+			return nil, E.New("invalid external_ui_download_sha256: ", options.ExternalUIDownloadSHA256)
+		}
+		s.externalUIDownloadSHA256 = downloadSHA256
+	}
+	if options.CoreUpgradeURL != "" {
+		err := s.prepareCoreUpgrade(options)
+		if err != nil {
+			return nil, err
+		}
+	}
+	s.router.SetDNSQueryTracker(s.dnsStats)
 	s.urlTestHistory = service.PtrFromContext[urltest.HistoryStorage](ctx)
 	if s.urlTestHistory == nil {
 		s.urlTestHistory = urltest.NewHistoryStorage()
 	}
+	modes, err := newCustomModes(options.Modes)
+	if err != nil {
+		return nil, err
+	}
+	s.modes = modes
+	for _, mode := range modes {
+		if !common.Contains(s.modeList, mode.name) {
+			s.modeList = append(s.modeList, mode.name)
+		}
+	}
 	defaultMode := "Rule"
 	if options.DefaultMode != "" {
 		defaultMode = options.DefaultMode
@@ -120,8 +164,11 @@ func NewServer(ctx context.Context, logFactory log.ObservableFactory, options op
 		r.Mount("/providers/rules", ruleProviderRouter(s.router))
 		r.Mount("/script", scriptRouter())
 		r.Mount("/profile", profileRouter())
-		r.Mount("/cache", cacheRouter(ctx))
-		r.Mount("/dns", dnsRouter(s.router))
+		r.Mount("/cache", cacheRouter(ctx, s.router))
+		r.Mount("/dns", dnsRouter(s.router, s.dnsStats))
+		r.Mount("/route", routeRouter(s.router))
+		r.Get("/capture", capturePackets(s))
+		r.Mount("/events", eventRouter(s.urlTestHistory))
 
 		s.setupMetaAPI(r)
 	})
@@ -151,9 +198,28 @@ func (s *Server) Start(stage adapter.StartStage) error {
 				s.mode = mode
 			}
 		}
+		err := s.checkModes()
+		if err != nil {
+			return err
+		}
+		err = s.applyMode(s.mode)
+		if err != nil {
+			return E.Cause(err, "apply mode: ", s.mode)
+		}
 	case adapter.StartStateStarted:
+		if s.coreUpdater != nil {
+			err := s.coreUpdater.Start()
+			if err != nil {
+				return E.Cause(err, "initialize core upgrade")
+			}
+		}
 		if s.externalController {
 			s.checkAndDownloadExternalUI()
+			if s.externalUI != "" && s.externalUIUpdateInterval > 0 {
+				var ctx context.Context
+				ctx, s.cancel = context.WithCancel(s.ctx)
+				go s.loopUpdateExternalUI(ctx)
+			}
 			var (
 				listener net.Listener
 				err      error
@@ -183,10 +249,14 @@ func (s *Server) Start(stage adapter.StartStage) error {
 }
 
 func (s *Server) Close() error {
+	if s.cancel != nil {
+		s.cancel()
+	}
 	return common.Close(
 		common.PtrOrNil(s.httpServer),
 		s.trafficManager,
 		s.urlTestHistory,
+		common.PtrOrNil(s.coreUpdater),
 	)
 }
 
@@ -214,6 +284,11 @@ func (s *Server) SetMode(newMode string) {
 	if newMode == s.mode {
 		return
 	}
+	err := s.applyMode(newMode)
+	if err != nil {
+		s.logger.Error(E.Cause(err, "apply mode: ", newMode))
+		return
+	}
 	s.mode = newMode
 	if s.modeUpdateHook != nil {
 		select {
@@ -224,7 +299,7 @@ func (s *Server) SetMode(newMode string) {
 	s.router.ClearDNSCache()
 	cacheFile := service.FromContext[adapter.CacheFile](s.ctx)
 	if cacheFile != nil {
-		err := cacheFile.StoreMode(newMode)
+		err = cacheFile.StoreMode(newMode)
 		if err != nil {
 			s.logger.Error(E.Cause(err, "save mode"))
 		}
@@ -241,13 +316,25 @@ func (s *Server) TrafficManager() *trafficontrol.Manager {
 }
 
 func (s *Server) RoutedConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, matchedRule adapter.Rule, matchOutbound adapter.Outbound) net.Conn {
+	for _, session := range s.loadCaptureSessions() {
+		conn = session.RoutedConnection(ctx, conn, metadata, matchedRule, matchOutbound)
+	}
 	return trafficontrol.NewTCPTracker(conn, s.trafficManager, metadata, s.outbound, matchedRule, matchOutbound)
 }
 
 func (s *Server) RoutedPacketConnection(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext, matchedRule adapter.Rule, matchOutbound adapter.Outbound) N.PacketConn {
+	for _, session := range s.loadCaptureSessions() {
+		conn = session.RoutedPacketConnection(ctx, conn, metadata, matchedRule, matchOutbound)
+	}
 	return trafficontrol.NewUDPTracker(conn, s.trafficManager, metadata, s.outbound, matchedRule, matchOutbound)
 }
 
+func (s *Server) loadCaptureSessions() []*capture.Session {
+	s.captureAccess.Lock()
+	defer s.captureAccess.Unlock()
+	return s.captureSessions
+}
+
 func authentication(serverSecret string) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		fn := func(w http.ResponseWriter, r *http.Request) {
@@ -2,13 +2,18 @@ package clashapi
 
 import (
 	"archive/zip"
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
 	"io"
 	"net"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/sagernet/sing-box/adapter"
 	C "github.com/sagernet/sing-box/constant"
@@ -18,6 +23,8 @@ import (
 	"github.com/sagernet/sing/service/filemanager"
 )
 
+const defaultExternalUIDownloadURL = "https://github.com/MetaCubeX/Yacd-meta/archive/gh-pages.zip"
+
 func (s *Server) checkAndDownloadExternalUI() {
 	if s.externalUI == "" {
 		return
@@ -34,14 +41,29 @@ func (s *Server) checkAndDownloadExternalUI() {
 	}
 }
 
+func (s *Server) loopUpdateExternalUI(ctx context.Context) {
+	ticker := time.NewTicker(s.externalUIUpdateInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		err := s.downloadExternalUI()
+		if err != nil {
+			s.logger.Error("update external ui error: ", err)
+		}
+	}
+}
+
 func (s *Server) downloadExternalUI() error {
-	var downloadURL string
-	if s.externalUIDownloadURL != "" {
-		downloadURL = s.externalUIDownloadURL
-	} else {
-		downloadURL = "https://github.com/MetaCubeX/Yacd-meta/archive/gh-pages.zip"
+	s.externalUIAccess.Lock()
+	defer s.externalUIAccess.Unlock()
+	downloadURLs := s.externalUIDownloadURL
+	if len(downloadURLs) == 0 {
+		downloadURLs = []string{defaultExternalUIDownloadURL}
 	}
-	s.logger.Info("downloading external ui")
 	var detour adapter.Outbound
 	if s.externalUIDownloadDetour != "" {
 		outbound, loaded := s.outbound.Outbound(s.externalUIDownloadDetour)
@@ -60,9 +82,26 @@ func (s *Server) downloadExternalUI() error {
 			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
 				return detour.DialContext(ctx, network, M.ParseSocksaddr(addr))
 			},
+			TLSClientConfig: &tls.Config{
+				RootCAs: adapter.RootPoolFromContext(s.ctx),
+			},
 		},
 	}
 	defer httpClient.CloseIdleConnections()
+	var errors []error
+	for _, downloadURL := range downloadURLs {
+		s.logger.Info("downloading external ui from ", downloadURL)
+		err := s.downloadExternalUIFrom(httpClient, downloadURL)
+		if err == nil {
+			return nil
+		}
+		s.logger.Warn("download external ui from ", downloadURL, ": ", err)
+		errors = append(errors, E.Cause(err, downloadURL))
+	}
+	return E.Errors(errors...)
+}
+
+func (s *Server) downloadExternalUIFrom(httpClient *http.Client, downloadURL string) error {
 	response, err := httpClient.Get(downloadURL)
 	if err != nil {
 		return err
@@ -71,25 +110,44 @@ func (s *Server) downloadExternalUI() error {
 	if response.StatusCode != http.StatusOK {
 		return E.New("download external ui failed: ", response.Status)
 	}
-	err = s.downloadZIP(filepath.Base(downloadURL), response.Body, s.externalUI)
-	if err != nil {
-		removeAllInDirectory(s.externalUI)
-	}
-	return err
-}
-
-func (s *Server) downloadZIP(name string, body io.Reader, output string) error {
-	tempFile, err := filemanager.CreateTemp(s.ctx, name)
+	tempFile, err := filemanager.CreateTemp(s.ctx, filepath.Base(downloadURL))
 	if err != nil {
 		return err
 	}
 	defer os.Remove(tempFile.Name())
-	_, err = io.Copy(tempFile, body)
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tempFile, hash), response.Body)
 	tempFile.Close()
 	if err != nil {
 		return err
 	}
-	reader, err := zip.OpenReader(tempFile.Name())
+	archiveHash := hash.Sum(nil)
+	if len(s.externalUIDownloadSHA256) > 0 && !bytes.Equal(archiveHash, s.externalUIDownloadSHA256) {
+		return E.New("sha256 mismatch: expected ", hex.EncodeToString(s.externalUIDownloadSHA256), ", got ", hex.EncodeToString(archiveHash))
+	}
+	if bytes.Equal(archiveHash, s.externalUIHash) {
+		s.logger.Info("external ui is up to date")
+		return nil
+	}
+	tempDirectory := s.externalUI + ".tmp"
+	os.RemoveAll(tempDirectory)
+	err = s.extractZIP(tempFile.Name(), tempDirectory)
+	if err != nil {
+		os.RemoveAll(tempDirectory)
+		return err
+	}
+	err = replaceDirectory(s.externalUI, tempDirectory)
+	if err != nil {
+		os.RemoveAll(tempDirectory)
+		return err
+	}
+	s.externalUIHash = archiveHash
+	s.logger.Info("external ui updated")
+	return nil
+}
+
+func (s *Server) extractZIP(path string, output string) error {
+	reader, err := zip.OpenReader(path)
 	if err != nil {
 		return err
 	}
@@ -134,6 +192,23 @@ func downloadZIPEntry(ctx context.Context, zipFile *zip.File, savePath string) e
 	return common.Error(io.Copy(saveFile, reader))
 }
 
+// replaceDirectory moves entries of source into directory after removing existing entries,
+// the directory itself is kept since it may be a mount point.
+func replaceDirectory(directory string, source string) error {
+	dirEntries, err := os.ReadDir(source)
+	if err != nil {
+		return err
+	}
+	removeAllInDirectory(directory)
+	for _, dirEntry := range dirEntries {
+		err = os.Rename(filepath.Join(source, dirEntry.Name()), filepath.Join(directory, dirEntry.Name()))
+		if err != nil {
+			return err
+		}
+	}
+	return os.Remove(source)
+}
+
 func removeAllInDirectory(directory string) {
 	dirEntries, err := os.ReadDir(directory)
 	if err != nil {
@@ -104,6 +104,7 @@ func (m *Manager) Snapshot() *Snapshot {
 		Upload:      m.uploadTotal.Load(),
 		Download:    m.downloadTotal.Load(),
 		Connections: connections,
+		Total:       len(connections),
 		Memory:      m.memory,
 	}
 }
@@ -117,7 +118,9 @@ type Snapshot struct {
 	Download    int64
 	Upload      int64
 	Connections []Tracker
-	Memory      uint64
+	// Total is the number of connections matched before pagination.
+	Total  int
+	Memory uint64
 }
 
 func (s *Snapshot) MarshalJSON() ([]byte, error) {
@@ -125,6 +128,7 @@ func (s *Snapshot) MarshalJSON() ([]byte, error) {
 		"downloadTotal": s.Download,
 		"uploadTotal":   s.Upload,
 		"connections":   common.Map(s.Connections, func(t Tracker) TrackerMetadata { return t.Metadata() }),
+		"total":         s.Total,
 		"memory":        s.Memory,
 	})
 }
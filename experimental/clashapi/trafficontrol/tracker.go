@@ -2,6 +2,7 @@ package trafficontrol
 
 import (
 	"net"
+	"path/filepath"
 	"time"
 
 	"github.com/sagernet/sing-box/adapter"
@@ -41,21 +42,35 @@ func (t TrackerMetadata) MarshalJSON() ([]byte, error) {
 	} else {
 		domain = t.Metadata.Domain
 	}
-	var processPath string
-	if t.Metadata.ProcessInfo != nil {
-		if t.Metadata.ProcessInfo.ProcessPath != "" {
-			processPath = t.Metadata.ProcessInfo.ProcessPath
-		} else if t.Metadata.ProcessInfo.PackageName != "" {
-			processPath = t.Metadata.ProcessInfo.PackageName
+	metadata := map[string]any{
+		"network":         t.Metadata.Network,
+		"type":            inbound,
+		"sourceIP":        t.Metadata.Source.Addr,
+		"destinationIP":   t.Metadata.Destination.Addr,
+		"sourcePort":      F.ToString(t.Metadata.Source.Port),
+		"destinationPort": F.ToString(t.Metadata.Destination.Port),
+		"host":            domain,
+		"dnsMode":         "normal",
+		"process":         "",
+		"processPath":     "",
+		"sourceMAC":       t.Metadata.SourceMACAddress.String(),
+	}
+	if processInfo := t.Metadata.ProcessInfo; processInfo != nil {
+		if processInfo.ProcessPath != "" {
+			metadata["process"] = filepath.Base(processInfo.ProcessPath)
+			metadata["processPath"] = processInfo.ProcessPath
+		} else if processInfo.PackageName != "" {
+			metadata["process"] = processInfo.PackageName
+			metadata["processPath"] = processInfo.PackageName
+		}
+		if processInfo.ProcessID != 0 {
+			metadata["pid"] = processInfo.ProcessID
 		}
-		if processPath == "" {
-			if t.Metadata.ProcessInfo.UserId != -1 {
-				processPath = F.ToString(t.Metadata.ProcessInfo.UserId)
-			}
-		} else if t.Metadata.ProcessInfo.User != "" {
-			processPath = F.ToString(processPath, " (", t.Metadata.ProcessInfo.User, ")")
-		} else if t.Metadata.ProcessInfo.UserId != -1 {
-			processPath = F.ToString(processPath, " (", t.Metadata.ProcessInfo.UserId, ")")
+		if processInfo.UserId != -1 {
+			metadata["uid"] = processInfo.UserId
+		}
+		if processInfo.User != "" {
+			metadata["user"] = processInfo.User
 		}
 	}
 	var rule string
@@ -65,18 +80,8 @@ func (t TrackerMetadata) MarshalJSON() ([]byte, error) {
 		rule = "final"
 	}
 	return json.Marshal(map[string]any{
-		"id": t.ID,
-		"metadata": map[string]any{
-			"network":         t.Metadata.Network,
-			"type":            inbound,
-			"sourceIP":        t.Metadata.Source.Addr,
-			"destinationIP":   t.Metadata.Destination.Addr,
-			"sourcePort":      F.ToString(t.Metadata.Source.Port),
-			"destinationPort": F.ToString(t.Metadata.Destination.Port),
-			"host":            domain,
-			"dnsMode":         "normal",
-			"processPath":     processPath,
-		},
+		"id":          t.ID,
+		"metadata":    metadata,
 		"upload":      t.Upload.Load(),
 		"download":    t.Download.Load(),
 		"start":       t.CreatedAt,
@@ -72,9 +72,9 @@ func (s *CommandServer) handleURLTest(conn net.Conn) error {
 			outboundToTest := detour
 			outboundTag := outboundToTest.Tag()
 			b.Go(outboundTag, func() (any, error) {
-				t, err := urltest.URLTest(serviceNow.ctx, "", outboundToTest)
+				t, err := urltest.URLTest(urltest.ContextWithRootCAs(serviceNow.ctx, adapter.RootPoolFromContext(serviceNow.ctx)), "", outboundToTest)
 				if err != nil {
-					historyStorage.DeleteURLTestHistory(outboundTag)
+					historyStorage.DeleteURLTestHistoryWithError(outboundTag, err)
 				} else {
 					historyStorage.StoreURLTestHistory(outboundTag, &urltest.History{
 						Time:  time.Now(),
@@ -6,14 +6,16 @@ import (
 	"github.com/sagernet/sing-box/option"
 	"github.com/sagernet/sing-tun"
 	"github.com/sagernet/sing/common/logger"
+
+	"go4.org/netipx"
 )
 
 type Interface interface {
 	Initialize(networkManager adapter.NetworkManager) error
 	UsePlatformAutoDetectInterfaceControl() bool
 	AutoDetectInterfaceControl(fd int) error
-	OpenTun(options *tun.Options, platformOptions option.TunPlatformOptions) (tun.Tun, error)
-	UpdateRouteOptions(options *tun.Options, platformOptions option.TunPlatformOptions) error
+	OpenTun(options *tun.Options, platformOptions option.TunPlatformOptions, addressSet TunRouteAddressSet) (tun.Tun, error)
+	UpdateRouteOptions(options *tun.Options, platformOptions option.TunPlatformOptions, addressSet TunRouteAddressSet) error
 	CreateDefaultInterfaceMonitor(logger logger.Logger) tun.DefaultInterfaceMonitor
 	Interfaces() ([]adapter.NetworkInterface, error)
 	UnderNetworkExtension() bool
@@ -24,6 +26,12 @@ type Interface interface {
 	SendNotification(notification *Notification) error
 }
 
+// TunRouteAddressSet holds the IP sets loaded from route_address_set and route_exclude_address_set.
+type TunRouteAddressSet struct {
+	Include []*netipx.IPSet
+	Exclude []*netipx.IPSet
+}
+
 type Notification struct {
 	Identifier string
 	TypeName   string
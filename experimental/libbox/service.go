@@ -107,7 +107,7 @@ func (s *BoxService) Close() error {
 	select {
 	case <-done:
 		return err
-	case <-time.After(C.FatalStopTimeout):
+	case <-time.After(C.FatalStopTimeout + s.instance.DrainTimeout()):
 		os.Exit(1)
 		return nil
 	}
@@ -146,7 +146,7 @@ func (w *platformInterfaceWrapper) AutoDetectInterfaceControl(fd int) error {
 	return w.iif.AutoDetectInterfaceControl(int32(fd))
 }
 
-func (w *platformInterfaceWrapper) OpenTun(options *tun.Options, platformOptions option.TunPlatformOptions) (tun.Tun, error) {
+func (w *platformInterfaceWrapper) OpenTun(options *tun.Options, platformOptions option.TunPlatformOptions, addressSet platform.TunRouteAddressSet) (tun.Tun, error) {
 	if len(options.IncludeUID) > 0 || len(options.ExcludeUID) > 0 {
 		return nil, E.New("platform: unsupported uid options")
 	}
@@ -157,7 +157,7 @@ func (w *platformInterfaceWrapper) OpenTun(options *tun.Options, platformOptions
 	if err != nil {
 		return nil, err
 	}
-	tunFd, err := w.iif.OpenTun(&tunOptions{options, routeRanges, platformOptions})
+	tunFd, err := w.iif.OpenTun(&tunOptions{options, routeRanges, addressSet, platformOptions})
 	if err != nil {
 		return nil, err
 	}
@@ -174,7 +174,7 @@ func (w *platformInterfaceWrapper) OpenTun(options *tun.Options, platformOptions
 	return tun.New(*options)
 }
 
-func (w *platformInterfaceWrapper) UpdateRouteOptions(options *tun.Options, platformOptions option.TunPlatformOptions) error {
+func (w *platformInterfaceWrapper) UpdateRouteOptions(options *tun.Options, platformOptions option.TunPlatformOptions, addressSet platform.TunRouteAddressSet) error {
 	if len(options.IncludeUID) > 0 || len(options.ExcludeUID) > 0 {
 		return E.New("android: unsupported uid options")
 	}
@@ -185,7 +185,7 @@ func (w *platformInterfaceWrapper) UpdateRouteOptions(options *tun.Options, plat
 	if err != nil {
 		return err
 	}
-	return w.iif.UpdateRouteOptions(&tunOptions{options, routeRanges, platformOptions})
+	return w.iif.UpdateRouteOptions(&tunOptions{options, routeRanges, addressSet, platformOptions})
 }
 
 func (w *platformInterfaceWrapper) CreateDefaultInterfaceMonitor(logger logger.Logger) tun.DefaultInterfaceMonitor {
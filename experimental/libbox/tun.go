@@ -4,10 +4,13 @@ import (
 	"net"
 	"net/netip"
 
+	"github.com/sagernet/sing-box/experimental/libbox/platform"
 	"github.com/sagernet/sing-box/option"
 	"github.com/sagernet/sing-tun"
 	"github.com/sagernet/sing/common"
 	E "github.com/sagernet/sing/common/exceptions"
+
+	"go4.org/netipx"
 )
 
 type TunOptions interface {
@@ -23,8 +26,14 @@ type TunOptions interface {
 	GetInet6RouteExcludeAddress() RoutePrefixIterator
 	GetInet4RouteRange() RoutePrefixIterator
 	GetInet6RouteRange() RoutePrefixIterator
+	GetInet4RouteAddressSet() RoutePrefixIterator
+	GetInet6RouteAddressSet() RoutePrefixIterator
+	GetInet4RouteExcludeAddressSet() RoutePrefixIterator
+	GetInet6RouteExcludeAddressSet() RoutePrefixIterator
 	GetIncludePackage() StringIterator
 	GetExcludePackage() StringIterator
+	GetIncludeInterface() StringIterator
+	GetExcludeInterface() StringIterator
 	IsHTTPProxyEnabled() bool
 	GetHTTPProxyServer() string
 	GetHTTPProxyServerPort() int32
@@ -78,6 +87,7 @@ var _ TunOptions = (*tunOptions)(nil)
 type tunOptions struct {
 	*tun.Options
 	routeRanges []netip.Prefix
+	addressSet  platform.TunRouteAddressSet
 	option.TunPlatformOptions
 }
 
@@ -136,6 +146,30 @@ func (o *tunOptions) GetInet6RouteRange() RoutePrefixIterator {
 	}))
 }
 
+// GetInet4RouteAddressSet returns the prefixes loaded from route_address_set, which are also
+// included in GetInet4RouteAddress, so that the platform can tell them apart from static routes.
+func (o *tunOptions) GetInet4RouteAddressSet() RoutePrefixIterator {
+	return mapIPSetPrefix(o.addressSet.Include, true)
+}
+
+func (o *tunOptions) GetInet6RouteAddressSet() RoutePrefixIterator {
+	return mapIPSetPrefix(o.addressSet.Include, false)
+}
+
+func (o *tunOptions) GetInet4RouteExcludeAddressSet() RoutePrefixIterator {
+	return mapIPSetPrefix(o.addressSet.Exclude, true)
+}
+
+func (o *tunOptions) GetInet6RouteExcludeAddressSet() RoutePrefixIterator {
+	return mapIPSetPrefix(o.addressSet.Exclude, false)
+}
+
+func mapIPSetPrefix(ipSets []*netipx.IPSet, is4 bool) RoutePrefixIterator {
+	return mapRoutePrefix(common.Filter(common.FlatMap(ipSets, (*netipx.IPSet).Prefixes), func(it netip.Prefix) bool {
+		return it.Addr().Is4() == is4
+	}))
+}
+
 func (o *tunOptions) GetIncludePackage() StringIterator {
 	return newIterator(o.IncludePackage)
 }
@@ -144,6 +178,14 @@ func (o *tunOptions) GetExcludePackage() StringIterator {
 	return newIterator(o.ExcludePackage)
 }
 
+func (o *tunOptions) GetIncludeInterface() StringIterator {
+	return newIterator(o.IncludeInterface)
+}
+
+func (o *tunOptions) GetExcludeInterface() StringIterator {
+	return newIterator(o.ExcludeInterface)
+}
+
 func (o *tunOptions) IsHTTPProxyEnabled() bool {
 	if o.TunPlatformOptions.HTTPProxy == nil {
 		return false
@@ -0,0 +1,142 @@
+package portforward
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/listener"
+	"github.com/sagernet/sing-box/common/proxyproto"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/buf"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/logger"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+	"github.com/sagernet/sing/common/udpnat2"
+	"github.com/sagernet/sing/service"
+)
+
+var _ adapter.LifecycleService = (*Service)(nil)
+
+// Service relays connections accepted on a local port to a fixed
+// destination through an outbound, without routing.
+type Service struct {
+	ctx               context.Context
+	logger            logger.ContextLogger
+	outbound          adapter.OutboundManager
+	connection        adapter.ConnectionManager
+	listener          *listener.Listener
+	udpNat            *udpnat.Service
+	network           []string
+	destination       M.Socksaddr
+	outboundTag       string
+	sendProxyProtocol bool
+	dialer            N.Dialer
+}
+
+func NewService(ctx context.Context, logger logger.ContextLogger, options option.PortForwardOptions) (*Service, error) {
+	if options.ListenPort == 0 {
+		return nil, E.New("missing listen_port")
+	}
+	destination := options.ServerOptions.Build()
+	if !destination.IsValid() || destination.Port == 0 {
+		return nil, E.New("missing server or server_port")
+	}
+	s := &Service{
+		ctx:               ctx,
+		logger:            logger,
+		outbound:          service.FromContext[adapter.OutboundManager](ctx),
+		connection:        service.FromContext[adapter.ConnectionManager](ctx),
+		network:           options.Network.Build(),
+		destination:       destination,
+		outboundTag:       options.Outbound,
+		sendProxyProtocol: options.SendProxyProtocol,
+	}
+	var udpTimeout time.Duration
+	if options.UDPTimeout != 0 {
+		udpTimeout = time.Duration(options.UDPTimeout)
+	} else {
+		udpTimeout = adapter.TimeoutsFromContext(ctx).UDPNAT
+	}
+	s.udpNat = udpnat.New(s, s.preparePacketConnection, udpTimeout, false)
+	s.listener = listener.New(listener.Options{
+		Context:           ctx,
+		Logger:            logger,
+		Network:           s.network,
+		Listen:            options.ListenOptions,
+		ConnectionHandler: s,
+		PacketHandler:     s,
+	})
+	return s, nil
+}
+
+func (s *Service) Name() string {
+	return "port forward to " + s.destination.String()
+}
+
+func (s *Service) Start(stage adapter.StartStage) error {
+	if stage != adapter.StartStateStart {
+		return nil
+	}
+	var detour adapter.Outbound
+	if s.outboundTag != "" {
+		var loaded bool
+		detour, loaded = s.outbound.Outbound(s.outboundTag)
+		if !loaded {
+			return E.New("outbound not found: ", s.outboundTag)
+		}
+	} else {
+		detour = s.outbound.Default()
+	}
+	for _, network := range s.network {
+		if !common.Contains(detour.Network(), network) {
+			return E.New("outbound/", detour.Type(), "[", detour.Tag(), "] does not support ", network)
+		}
+	}
+	if s.sendProxyProtocol {
+		s.dialer = proxyproto.NewDialer(detour)
+	} else {
+		s.dialer = detour
+	}
+	return s.listener.Start()
+}
+
+func (s *Service) Close() error {
+	return s.listener.Close()
+}
+
+func (s *Service) NewConnectionEx(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
+	metadata.Destination = s.destination
+	s.logger.InfoContext(ctx, "forward connection to ", metadata.Destination)
+	s.connection.NewConnection(ctx, s.dialer, conn, metadata, onClose)
+}
+
+func (s *Service) NewPacketEx(buffer *buf.Buffer, source M.Socksaddr) {
+	s.udpNat.NewPacket([][]byte{buffer.Bytes()}, source, s.destination, nil)
+}
+
+func (s *Service) NewPacketConnectionEx(ctx context.Context, conn N.PacketConn, source M.Socksaddr, destination M.Socksaddr, onClose N.CloseHandlerFunc) {
+	var metadata adapter.InboundContext
+	metadata.Source = source
+	metadata.Destination = s.destination
+	metadata.OriginDestination = s.listener.UDPAddr()
+	s.logger.InfoContext(ctx, "forward packet connection from ", metadata.Source, " to ", metadata.Destination)
+	s.connection.NewPacketConnection(ctx, s.dialer, conn, metadata, onClose)
+}
+
+func (s *Service) preparePacketConnection(source M.Socksaddr, destination M.Socksaddr, userData any) (bool, context.Context, N.PacketWriter, N.CloseHandlerFunc) {
+	return true, log.ContextWithNewID(s.ctx), &packetWriter{s.listener.PacketWriter(), source}, nil
+}
+
+type packetWriter struct {
+	writer N.PacketWriter
+	source M.Socksaddr
+}
+
+func (w *packetWriter) WritePacket(buffer *buf.Buffer, addr M.Socksaddr) error {
+	return w.writer.WritePacket(buffer, w.source)
+}
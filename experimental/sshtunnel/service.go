@@ -0,0 +1,234 @@
+package sshtunnel
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/dialer"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	boxSSH "github.com/sagernet/sing-box/protocol/ssh"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/logger"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+	"github.com/sagernet/sing/service"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	defaultReconnectDelay = 5 * time.Second
+	maxReconnectDelay     = 5 * time.Minute
+)
+
+var _ adapter.LifecycleService = (*Service)(nil)
+
+// Service keeps remote port forwards open on an SSH server,
+// and injects the forwarded connections into local inbounds.
+type Service struct {
+	ctx               context.Context
+	cancel            context.CancelFunc
+	logger            logger.ContextLogger
+	inbound           adapter.InboundManager
+	injectables       map[string]adapter.TCPInjectableInbound
+	dialer            N.Dialer
+	serverAddr        M.Socksaddr
+	config            *ssh.ClientConfig
+	forwards          []option.SSHTunnelForwardOptions
+	keepaliveInterval time.Duration
+	reconnectDelay    time.Duration
+	clientAccess      sync.Mutex
+	client            *ssh.Client
+}
+
+func NewService(ctx context.Context, logger logger.ContextLogger, options option.SSHTunnelOptions) (*Service, error) {
+	if len(options.Forwards) == 0 {
+		return nil, E.New("missing forwards")
+	}
+	for i, forward := range options.Forwards {
+		if forward.Inbound == "" {
+			return nil, E.New("missing inbound for forward[", i, "]")
+		}
+		if forward.ListenPort == 0 {
+			return nil, E.New("missing listen_port for forward[", i, "]")
+		}
+	}
+	outboundDialer, err := dialer.NewServer(ctx, options.DialerOptions, options.ServerOptions.Build())
+	if err != nil {
+		return nil, err
+	}
+	config, err := boxSSH.NewClientConfig(options.SSHClientOptions)
+	if err != nil {
+		return nil, err
+	}
+	serverAddr := options.ServerOptions.Build()
+	if serverAddr.Port == 0 {
+		serverAddr.Port = 22
+	}
+	keepaliveInterval := time.Duration(options.KeepaliveInterval)
+	if keepaliveInterval == 0 {
+		keepaliveInterval = boxSSH.DefaultKeepaliveInterval
+	}
+	reconnectDelay := time.Duration(options.ReconnectDelay)
+	if reconnectDelay == 0 {
+		reconnectDelay = defaultReconnectDelay
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	return &Service{
+		ctx:               ctx,
+		cancel:            cancel,
+		logger:            logger,
+		inbound:           service.FromContext[adapter.InboundManager](ctx),
+		injectables:       make(map[string]adapter.TCPInjectableInbound),
+		dialer:            outboundDialer,
+		serverAddr:        serverAddr,
+		config:            config,
+		forwards:          options.Forwards,
+		keepaliveInterval: keepaliveInterval,
+		reconnectDelay:    reconnectDelay,
+	}, nil
+}
+
+func (s *Service) Name() string {
+	return "ssh tunnel " + s.serverAddr.String()
+}
+
+func (s *Service) Start(stage adapter.StartStage) error {
+	if stage != adapter.StartStateStart {
+		return nil
+	}
+	for _, forward := range s.forwards {
+		detour, loaded := s.inbound.Get(forward.Inbound)
+		if !loaded {
+			return E.New("inbound not found: ", forward.Inbound)
+		}
+		injectable, isInjectable := detour.(adapter.TCPInjectableInbound)
+		if !isInjectable {
+			return E.New("inbound is not TCP injectable: ", forward.Inbound)
+		}
+		s.injectables[forward.Inbound] = injectable
+	}
+	go s.loopConnect()
+	return nil
+}
+
+func (s *Service) Close() error {
+	s.cancel()
+	s.clientAccess.Lock()
+	if s.client != nil {
+		s.client.Close()
+	}
+	s.clientAccess.Unlock()
+	return nil
+}
+
+func (s *Service) loopConnect() {
+	delay := s.reconnectDelay
+	for {
+		connected, err := s.connect()
+		if s.ctx.Err() != nil {
+			return
+		}
+		if connected {
+			delay = s.reconnectDelay
+		}
+		s.logger.Error(E.Cause(err, "ssh tunnel to ", s.serverAddr), ", reconnecting in ", delay)
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		if delay < maxReconnectDelay {
+			delay *= 2
+			if delay > maxReconnectDelay {
+				delay = maxReconnectDelay
+			}
+		}
+	}
+}
+
+// connect runs one tunnel session until the connection is lost.
+func (s *Service) connect() (connected bool, err error) {
+	dialCtx, cancel := context.WithTimeout(s.ctx, C.TCPTimeout)
+	defer cancel()
+	conn, err := s.dialer.DialContext(dialCtx, N.NetworkTCP, s.serverAddr)
+	if err != nil {
+		return
+	}
+	deadline, _ := dialCtx.Deadline()
+	conn.SetDeadline(deadline)
+	clientConn, chans, reqs, err := ssh.NewClientConn(conn, s.serverAddr.Addr.String(), s.config)
+	if err != nil {
+		conn.Close()
+		err = E.Cause(err, "connect to ssh server")
+		return
+	}
+	conn.SetDeadline(time.Time{})
+	client := ssh.NewClient(clientConn, chans, reqs)
+	defer client.Close()
+	s.clientAccess.Lock()
+	if s.ctx.Err() != nil {
+		s.clientAccess.Unlock()
+		return false, s.ctx.Err()
+	}
+	s.client = client
+	s.clientAccess.Unlock()
+	defer func() {
+		s.clientAccess.Lock()
+		s.client = nil
+		s.clientAccess.Unlock()
+	}()
+	var listeners []net.Listener
+	defer func() {
+		common.Close(common.Map(listeners, func(it net.Listener) any {
+			return it
+		})...)
+	}()
+	for _, forward := range s.forwards {
+		listenAddr := M.ParseSocksaddrHostPort(forward.Listen, forward.ListenPort)
+		if forward.Listen == "" {
+			listenAddr = M.ParseSocksaddrHostPort("127.0.0.1", forward.ListenPort)
+		}
+		var listener net.Listener
+		listener, err = client.Listen(N.NetworkTCP, listenAddr.String())
+		if err != nil {
+			err = E.Cause(err, "request remote forward on ", listenAddr)
+			return
+		}
+		listeners = append(listeners, listener)
+		s.logger.Info("forwarding ", listenAddr, " on ", s.serverAddr, " to inbound/", forward.Inbound)
+		go s.loopAccept(listener, forward.Inbound, s.injectables[forward.Inbound])
+	}
+	connected = true
+	done := make(chan struct{})
+	go boxSSH.Keepalive(s.logger, client, s.keepaliveInterval, done)
+	err = client.Wait()
+	close(done)
+	if err == nil {
+		err = E.New("connection closed")
+	}
+	return
+}
+
+func (s *Service) loopAccept(listener net.Listener, inboundTag string, injectable adapter.TCPInjectableInbound) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		ctx := log.ContextWithNewID(s.ctx)
+		var metadata adapter.InboundContext
+		metadata.Inbound = inboundTag
+		metadata.InboundType = injectable.Type()
+		metadata.Source = M.SocksaddrFromNet(conn.RemoteAddr()).Unwrap()
+		metadata.OriginDestination = M.SocksaddrFromNet(conn.LocalAddr()).Unwrap()
+		s.logger.InfoContext(ctx, "inbound connection from ", metadata.Source, " to inbound/", inboundTag)
+		go injectable.NewConnectionEx(ctx, conn, metadata, nil)
+	}
+}
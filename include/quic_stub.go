@@ -13,7 +13,6 @@ import (
 	"github.com/sagernet/sing-box/common/listener"
 	"github.com/sagernet/sing-box/common/tls"
 	C "github.com/sagernet/sing-box/constant"
-	"github.com/sagernet/sing-box/log"
 	"github.com/sagernet/sing-box/option"
 	"github.com/sagernet/sing-box/protocol/naive"
 	"github.com/sagernet/sing-box/transport/v2ray"
@@ -38,28 +37,17 @@ func init() {
 }
 
 func registerQUICInbounds(registry *inbound.Registry) {
-	inbound.Register[option.HysteriaInboundOptions](registry, C.TypeHysteria, func(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.HysteriaInboundOptions) (adapter.Inbound, error) {
-		return nil, C.ErrQUICNotIncluded
-	})
-	inbound.Register[option.TUICInboundOptions](registry, C.TypeTUIC, func(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.TUICInboundOptions) (adapter.Inbound, error) {
-		return nil, C.ErrQUICNotIncluded
-	})
-	inbound.Register[option.Hysteria2InboundOptions](registry, C.TypeHysteria2, func(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.Hysteria2InboundOptions) (adapter.Inbound, error) {
-		return nil, C.ErrQUICNotIncluded
-	})
+	inbound.RegisterStub[option.HysteriaInboundOptions](registry, C.TypeHysteria, C.ErrQUICNotIncluded)
+	inbound.RegisterStub[option.TUICInboundOptions](registry, C.TypeTUIC, C.ErrQUICNotIncluded)
+	inbound.RegisterStub[option.Hysteria2InboundOptions](registry, C.TypeHysteria2, C.ErrQUICNotIncluded)
 	naive.ConfigureHTTP3ListenerFunc = func(listener *listener.Listener, handler http.Handler, tlsConfig tls.ServerConfig, logger logger.Logger) (io.Closer, error) {
 		return nil, C.ErrQUICNotIncluded
 	}
 }
 
 func registerQUICOutbounds(registry *outbound.Registry) {
-	outbound.Register[option.HysteriaOutboundOptions](registry, C.TypeHysteria, func(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.HysteriaOutboundOptions) (adapter.Outbound, error) {
-		return nil, C.ErrQUICNotIncluded
-	})
-	outbound.Register[option.TUICOutboundOptions](registry, C.TypeTUIC, func(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.TUICOutboundOptions) (adapter.Outbound, error) {
-		return nil, C.ErrQUICNotIncluded
-	})
-	outbound.Register[option.Hysteria2OutboundOptions](registry, C.TypeHysteria2, func(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.Hysteria2OutboundOptions) (adapter.Outbound, error) {
-		return nil, C.ErrQUICNotIncluded
-	})
+	outbound.RegisterStub[option.HysteriaOutboundOptions](registry, C.TypeHysteria, C.ErrQUICNotIncluded)
+	outbound.RegisterStub[option.TUICOutboundOptions](registry, C.TypeTUIC, C.ErrQUICNotIncluded)
+	outbound.RegisterStub[option.Hysteria2OutboundOptions](registry, C.TypeHysteria2, C.ErrQUICNotIncluded)
+	outbound.RegisterStub[option.HTTP3OutboundOptions](registry, C.TypeHTTP3, C.ErrQUICNotIncluded)
 }
@@ -0,0 +1,18 @@
+//go:build !with_shadowsocksr
+
+package include
+
+import (
+	"github.com/sagernet/sing-box/adapter/outbound"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+func registerShadowsocksROutbound(registry *outbound.Registry) {
+	registerStubForRemovedOutbounds(registry)
+}
+
+func registerStubForRemovedOutbounds(registry *outbound.Registry) {
+	outbound.RegisterStub[option.ShadowsocksROutboundOptions](registry, C.TypeShadowsocksR, E.New("ShadowsocksR is deprecated and removed in sing-box 1.6.0, rebuild with -tags with_shadowsocksr to use the legacy client"))
+}
@@ -3,25 +3,17 @@
 package include
 
 import (
-	"context"
-
-	"github.com/sagernet/sing-box/adapter"
 	"github.com/sagernet/sing-box/adapter/endpoint"
 	"github.com/sagernet/sing-box/adapter/outbound"
 	C "github.com/sagernet/sing-box/constant"
-	"github.com/sagernet/sing-box/log"
 	"github.com/sagernet/sing-box/option"
 	E "github.com/sagernet/sing/common/exceptions"
 )
 
 func registerWireGuardOutbound(registry *outbound.Registry) {
-	outbound.Register[option.LegacyWireGuardOutboundOptions](registry, C.TypeWireGuard, func(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.LegacyWireGuardOutboundOptions) (adapter.Outbound, error) {
-		return nil, E.New(`WireGuard is not included in this build, rebuild with -tags with_wireguard`)
-	})
+	outbound.RegisterStub[option.LegacyWireGuardOutboundOptions](registry, C.TypeWireGuard, E.New(`WireGuard is not included in this build, rebuild with -tags with_wireguard`))
 }
 
 func registerWireGuardEndpoint(registry *endpoint.Registry) {
-	endpoint.Register[option.WireGuardEndpointOptions](registry, C.TypeWireGuard, func(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.WireGuardEndpointOptions) (adapter.Endpoint, error) {
-		return nil, E.New(`WireGuard is not included in this build, rebuild with -tags with_wireguard`)
-	})
+	endpoint.RegisterStub[option.WireGuardEndpointOptions](registry, C.TypeWireGuard, E.New(`WireGuard is not included in this build, rebuild with -tags with_wireguard`))
 }
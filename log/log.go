@@ -49,7 +49,7 @@ func New(options Options) (Factory, error) {
 		FullTimestamp:    logOptions.Timestamp,
 		TimestampFormat:  "-0700 2006-01-02 15:04:05",
 	}
-	factory := NewDefaultFactory(
+	factory := newDefaultFactory(
 		options.Context,
 		logFormatter,
 		logWriter,
@@ -66,5 +66,15 @@ func New(options Options) (Factory, error) {
 	} else {
 		factory.SetLevel(LevelTrace)
 	}
+	if len(logOptions.ModuleLevel) > 0 {
+		factory.moduleLevels = make(map[string]Level, len(logOptions.ModuleLevel))
+		for module, level := range logOptions.ModuleLevel {
+			moduleLevel, err := ParseLevel(level)
+			if err != nil {
+				return nil, E.Cause(err, "parse log level of module ", module)
+			}
+			factory.moduleLevels[module] = moduleLevel
+		}
+	}
 	return factory, nil
 }
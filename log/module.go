@@ -0,0 +1,26 @@
+package log
+
+import "strings"
+
+// lookupModuleLevel finds the level configured for a logger tag such as
+// "outbound/vmess[proxy]", trying the full tag, then "outbound/proxy",
+// "outbound/vmess" and "outbound".
+func lookupModuleLevel(moduleLevels map[string]Level, tag string) (Level, bool) {
+	if len(moduleLevels) == 0 || tag == "" {
+		return 0, false
+	}
+	candidates := []string{tag}
+	kind, name, hasName := strings.Cut(tag, "/")
+	if hasName {
+		if typeName, tagName, hasTag := strings.Cut(name, "["); hasTag && strings.HasSuffix(tagName, "]") {
+			candidates = append(candidates, kind+"/"+strings.TrimSuffix(tagName, "]"), kind+"/"+typeName)
+		}
+		candidates = append(candidates, kind)
+	}
+	for _, candidate := range candidates {
+		if level, loaded := moduleLevels[candidate]; loaded {
+			return level, true
+		}
+	}
+	return 0, false
+}
@@ -24,6 +24,7 @@ type defaultFactory struct {
 	platformWriter    PlatformWriter
 	needObservable    bool
 	level             Level
+	moduleLevels      map[string]Level
 	subscriber        *observable.Subscriber[Entry]
 	observer          *observable.Observer[Entry]
 }
@@ -36,6 +37,17 @@ func NewDefaultFactory(
 	platformWriter PlatformWriter,
 	needObservable bool,
 ) ObservableFactory {
+	return newDefaultFactory(ctx, formatter, writer, filePath, platformWriter, needObservable)
+}
+
+func newDefaultFactory(
+	ctx context.Context,
+	formatter Formatter,
+	writer io.Writer,
+	filePath string,
+	platformWriter PlatformWriter,
+	needObservable bool,
+) *defaultFactory {
 	factory := &defaultFactory{
 		ctx:       ctx,
 		formatter: formatter,
@@ -91,7 +103,9 @@ func (f *defaultFactory) Logger() ContextLogger {
 }
 
 func (f *defaultFactory) NewLogger(tag string) ContextLogger {
-	return &observableLogger{f, tag}
+	logger := &observableLogger{defaultFactory: f, tag: tag}
+	logger.moduleLevel, logger.hasModuleLevel = lookupModuleLevel(f.moduleLevels, tag)
+	return logger
 }
 
 func (f *defaultFactory) Subscribe() (subscription observable.Subscription[Entry], done <-chan struct{}, err error) {
@@ -106,12 +120,18 @@ var _ ContextLogger = (*observableLogger)(nil)
 
 type observableLogger struct {
 	*defaultFactory
-	tag string
+	tag            string
+	moduleLevel    Level
+	hasModuleLevel bool
 }
 
 func (l *observableLogger) Log(ctx context.Context, level Level, args []any) {
 	level = OverrideLevelFromContext(level, ctx)
-	if level > l.level {
+	maxLevel := l.level
+	if l.hasModuleLevel {
+		maxLevel = l.moduleLevel
+	}
+	if level > maxLevel {
 		return
 	}
 	nowTime := time.Now()
@@ -0,0 +1,9 @@
+package option
+
+import "github.com/sagernet/sing/common/json/badoption"
+
+type CertificateOptions struct {
+	Store           string                     `json:"store,omitempty"`
+	Certificate     badoption.Listable[string] `json:"certificate,omitempty"`
+	CertificatePath badoption.Listable[string] `json:"certificate_path,omitempty"`
+}
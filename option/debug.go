@@ -1,7 +1,10 @@
 package option
 
 import (
+	"context"
+
 	"github.com/sagernet/sing-box/common/humanize"
+	"github.com/sagernet/sing-box/common/jsonschema"
 	"github.com/sagernet/sing/common/json"
 )
 
@@ -41,3 +44,7 @@ func (l *MemoryBytes) UnmarshalJSON(bytes []byte) error {
 	*l = MemoryBytes(parsedValue)
 	return nil
 }
+
+func (l *MemoryBytes) JSONSchema(ctx context.Context) any {
+	return jsonschema.AnyOf{int64(0), ""}
+}
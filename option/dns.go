@@ -16,23 +16,33 @@ type DNSOptions struct {
 }
 
 type DNSServerOptions struct {
-	Tag                  string                `json:"tag,omitempty"`
-	Address              string                `json:"address"`
-	AddressResolver      string                `json:"address_resolver,omitempty"`
-	AddressStrategy      DomainStrategy        `json:"address_strategy,omitempty"`
-	AddressFallbackDelay badoption.Duration    `json:"address_fallback_delay,omitempty"`
-	Strategy             DomainStrategy        `json:"strategy,omitempty"`
-	Detour               string                `json:"detour,omitempty"`
-	ClientSubnet         *badoption.Prefixable `json:"client_subnet,omitempty"`
+	Tag                  string             `json:"tag,omitempty"`
+	Address              string             `json:"address"`
+	AddressResolver      string             `json:"address_resolver,omitempty"`
+	AddressStrategy      DomainStrategy     `json:"address_strategy,omitempty"`
+	AddressFallbackDelay badoption.Duration `json:"address_fallback_delay,omitempty"`
+	Strategy             DomainStrategy     `json:"strategy,omitempty"`
+	Detour               string             `json:"detour,omitempty"`
+	ClientSubnet         *DNSClientSubnet   `json:"client_subnet,omitempty"`
+	Pipeline             bool               `json:"pipeline,omitempty"`
+	Group                *DNSServerGroup    `json:"group,omitempty"`
+}
+
+type DNSServerGroup struct {
+	Servers  badoption.Listable[string] `json:"servers"`
+	Strategy string                     `json:"strategy,omitempty"`
+	Timeout  badoption.Duration         `json:"timeout,omitempty"`
+	Cooldown badoption.Duration         `json:"cooldown,omitempty"`
 }
 
 type DNSClientOptions struct {
-	Strategy         DomainStrategy        `json:"strategy,omitempty"`
-	DisableCache     bool                  `json:"disable_cache,omitempty"`
-	DisableExpire    bool                  `json:"disable_expire,omitempty"`
-	IndependentCache bool                  `json:"independent_cache,omitempty"`
-	CacheCapacity    uint32                `json:"cache_capacity,omitempty"`
-	ClientSubnet     *badoption.Prefixable `json:"client_subnet,omitempty"`
+	Strategy         DomainStrategy     `json:"strategy,omitempty"`
+	DisableCache     bool               `json:"disable_cache,omitempty"`
+	DisableExpire    bool               `json:"disable_expire,omitempty"`
+	IndependentCache bool               `json:"independent_cache,omitempty"`
+	CacheCapacity    uint32             `json:"cache_capacity,omitempty"`
+	ServeStale       badoption.Duration `json:"serve_stale,omitempty"`
+	ClientSubnet     *DNSClientSubnet   `json:"client_subnet,omitempty"`
 }
 
 type DNSFakeIPOptions struct {
@@ -40,3 +50,21 @@ type DNSFakeIPOptions struct {
 	Inet4Range *netip.Prefix `json:"inet4_range,omitempty"`
 	Inet6Range *netip.Prefix `json:"inet6_range,omitempty"`
 }
+
+type DNSInboundOptions struct {
+	ListenOptions
+	Network  NetworkList `json:"network,omitempty"`
+	Protocol string      `json:"protocol,omitempty"`
+	Path     string      `json:"path,omitempty"`
+	InboundTLSOptionsContainer
+}
+
+type DNSOutboundOptions struct {
+	QueryTypeServer []DNSQueryTypeServerOptions `json:"query_type_server,omitempty"`
+	FakeIPPTR       bool                        `json:"fakeip_ptr,omitempty"`
+}
+
+type DNSQueryTypeServerOptions struct {
+	QueryType badoption.Listable[DNSQueryType] `json:"query_type,omitempty"`
+	Server    string                           `json:"server,omitempty"`
+}
@@ -0,0 +1,119 @@
+package option
+
+import (
+	"context"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/sagernet/sing-box/common/jsonschema"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+	"github.com/sagernet/sing/common/json"
+)
+
+const (
+	DefaultClientSubnetInet4Bits = 24
+	DefaultClientSubnetInet6Bits = 56
+)
+
+// DNSClientSubnet is a fixed EDNS client subnet, or the source address of the
+// client truncated to the given prefix lengths when Auto is set.
+type DNSClientSubnet struct {
+	Auto      bool
+	Prefix    netip.Prefix
+	Inet4Bits int
+	Inet6Bits int
+}
+
+func (s DNSClientSubnet) IsValid() bool {
+	return s.Auto || s.Prefix.IsValid()
+}
+
+func (s DNSClientSubnet) Build(source netip.Addr) netip.Prefix {
+	if !s.Auto {
+		return s.Prefix.Masked()
+	}
+	source = source.Unmap()
+	if !source.IsGlobalUnicast() || source.IsPrivate() {
+		return netip.Prefix{}
+	}
+	var bits int
+	if source.Is4() {
+		bits = s.Inet4Bits
+	} else {
+		bits = s.Inet6Bits
+	}
+	prefix, _ := source.Prefix(bits)
+	return prefix
+}
+
+func (s DNSClientSubnet) String() string {
+	if !s.Auto {
+		prefix := s.Prefix.Masked()
+		if prefix.Bits() == prefix.Addr().BitLen() {
+			return prefix.Addr().String()
+		}
+		return prefix.String()
+	}
+	if s.Inet6Bits != DefaultClientSubnetInet6Bits {
+		return F.ToString("auto/", s.Inet4Bits, "/", s.Inet6Bits)
+	} else if s.Inet4Bits != DefaultClientSubnetInet4Bits {
+		return F.ToString("auto/", s.Inet4Bits)
+	}
+	return "auto"
+}
+
+func (s DNSClientSubnet) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+func (s *DNSClientSubnet) UnmarshalJSON(content []byte) error {
+	var value string
+	err := json.Unmarshal(content, &value)
+	if err != nil {
+		return err
+	}
+	if value == "auto" || strings.HasPrefix(value, "auto/") {
+		bitsList := strings.Split(value, "/")[1:]
+		if len(bitsList) > 2 {
+			return E.New("invalid client subnet: ", value)
+		}
+		subnet := DNSClientSubnet{
+			Auto:      true,
+			Inet4Bits: DefaultClientSubnetInet4Bits,
+			Inet6Bits: DefaultClientSubnetInet6Bits,
+		}
+		for index, bitsString := range bitsList {
+			bits, bitsErr := strconv.Atoi(bitsString)
+			if index == 0 {
+				if bitsErr != nil || bits < 0 || bits > 32 {
+					return E.New("invalid IPv4 prefix length in client subnet: ", value)
+				}
+				subnet.Inet4Bits = bits
+			} else {
+				if bitsErr != nil || bits < 0 || bits > 128 {
+					return E.New("invalid IPv6 prefix length in client subnet: ", value)
+				}
+				subnet.Inet6Bits = bits
+			}
+		}
+		*s = subnet
+		return nil
+	}
+	prefix, prefixErr := netip.ParsePrefix(value)
+	if prefixErr == nil {
+		*s = DNSClientSubnet{Prefix: prefix.Masked()}
+		return nil
+	}
+	addr, addrErr := netip.ParseAddr(value)
+	if addrErr == nil {
+		*s = DNSClientSubnet{Prefix: netip.PrefixFrom(addr, addr.BitLen())}
+		return nil
+	}
+	return prefixErr
+}
+
+func (s *DNSClientSubnet) JSONSchema(ctx context.Context) any {
+	return jsonschema.Schema{"type": "string"}
+}
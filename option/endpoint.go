@@ -3,6 +3,7 @@ package option
 import (
 	"context"
 
+	"github.com/sagernet/sing-box/common/jsonschema"
 	E "github.com/sagernet/sing/common/exceptions"
 	"github.com/sagernet/sing/common/json"
 	"github.com/sagernet/sing/common/json/badjson"
@@ -11,6 +12,7 @@ import (
 
 type EndpointOptionsRegistry interface {
 	CreateOptions(endpointType string) (any, bool)
+	Types() []string
 }
 
 type _Endpoint struct {
@@ -45,3 +47,12 @@ func (h *Endpoint) UnmarshalJSONContext(ctx context.Context, content []byte) err
 	h.Options = options
 	return nil
 }
+
+func (h *Endpoint) JSONSchema(ctx context.Context) any {
+	registry := service.FromContext[EndpointOptionsRegistry](ctx)
+	variants := make(map[string]any)
+	for _, endpointType := range registry.Types() {
+		variants[endpointType], _ = registry.CreateOptions(endpointType)
+	}
+	return jsonschema.Union{Field: "type", Common: _Endpoint{}, Variants: variants}
+}
@@ -3,10 +3,13 @@ package option
 import "github.com/sagernet/sing/common/json/badoption"
 
 type ExperimentalOptions struct {
-	CacheFile *CacheFileOptions `json:"cache_file,omitempty"`
-	ClashAPI  *ClashAPIOptions  `json:"clash_api,omitempty"`
-	V2RayAPI  *V2RayAPIOptions  `json:"v2ray_api,omitempty"`
-	Debug     *DebugOptions     `json:"debug,omitempty"`
+	CacheFile      *CacheFileOptions      `json:"cache_file,omitempty"`
+	ClashAPI       *ClashAPIOptions       `json:"clash_api,omitempty"`
+	V2RayAPI       *V2RayAPIOptions       `json:"v2ray_api,omitempty"`
+	Debug          *DebugOptions          `json:"debug,omitempty"`
+	SSHTunnels     []SSHTunnelOptions     `json:"ssh_tunnels,omitempty"`
+	PortForwards   []PortForwardOptions   `json:"port_forwards,omitempty"`
+	PacketCaptures []PacketCaptureOptions `json:"packet_captures,omitempty"`
 }
 
 type CacheFileOptions struct {
@@ -16,18 +19,26 @@ type CacheFileOptions struct {
 	StoreFakeIP bool               `json:"store_fakeip,omitempty"`
 	StoreRDRC   bool               `json:"store_rdrc,omitempty"`
 	RDRCTimeout badoption.Duration `json:"rdrc_timeout,omitempty"`
+	Shared      bool               `json:"shared,omitempty"`
+	Role        string             `json:"role,omitempty"`
 }
 
 type ClashAPIOptions struct {
 	ExternalController               string                     `json:"external_controller,omitempty"`
 	ExternalUI                       string                     `json:"external_ui,omitempty"`
-	ExternalUIDownloadURL            string                     `json:"external_ui_download_url,omitempty"`
+	ExternalUIDownloadURL            badoption.Listable[string] `json:"external_ui_download_url,omitempty"`
+	ExternalUIDownloadSHA256         string                     `json:"external_ui_download_sha256,omitempty"`
 	ExternalUIDownloadDetour         string                     `json:"external_ui_download_detour,omitempty"`
+	ExternalUIUpdateInterval         badoption.Duration         `json:"external_ui_update_interval,omitempty"`
 	Secret                           string                     `json:"secret,omitempty"`
 	DefaultMode                      string                     `json:"default_mode,omitempty"`
 	ModeList                         []string                   `json:"-"`
+	Modes                            []ClashModeOptions         `json:"modes,omitempty"`
 	AccessControlAllowOrigin         badoption.Listable[string] `json:"access_control_allow_origin,omitempty"`
 	AccessControlAllowPrivateNetwork bool                       `json:"access_control_allow_private_network,omitempty"`
+	CoreUpgradeURL                   string                     `json:"core_upgrade_url,omitempty"`
+	CoreUpgradeChecksumURL           string                     `json:"core_upgrade_checksum_url,omitempty"`
+	CoreUpgradeDetour                string                     `json:"core_upgrade_detour,omitempty"`
 
 	// Deprecated: migrated to global cache file
 	CacheFile string `json:"cache_file,omitempty"`
@@ -41,6 +52,13 @@ type ClashAPIOptions struct {
 	StoreFakeIP bool `json:"store_fakeip,omitempty"`
 }
 
+type ClashModeOptions struct {
+	Name              string                     `json:"name"`
+	EnableRuleGroups  badoption.Listable[string] `json:"enable_rule_groups,omitempty"`
+	DisableRuleGroups badoption.Listable[string] `json:"disable_rule_groups,omitempty"`
+	Final             string                     `json:"final,omitempty"`
+}
+
 type V2RayAPIOptions struct {
 	Listen string                    `json:"listen,omitempty"`
 	Stats  *V2RayStatsServiceOptions `json:"stats,omitempty"`
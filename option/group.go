@@ -4,15 +4,35 @@ import "github.com/sagernet/sing/common/json/badoption"
 
 type SelectorOutboundOptions struct {
 	Outbounds                 []string `json:"outbounds"`
+	Include                   string   `json:"include,omitempty"`
+	Exclude                   string   `json:"exclude,omitempty"`
 	Default                   string   `json:"default,omitempty"`
 	InterruptExistConnections bool     `json:"interrupt_exist_connections,omitempty"`
 }
 
 type URLTestOutboundOptions struct {
 	Outbounds                 []string           `json:"outbounds"`
+	Include                   string             `json:"include,omitempty"`
+	Exclude                   string             `json:"exclude,omitempty"`
 	URL                       string             `json:"url,omitempty"`
 	Interval                  badoption.Duration `json:"interval,omitempty"`
 	Tolerance                 uint16             `json:"tolerance,omitempty"`
 	IdleTimeout               badoption.Duration `json:"idle_timeout,omitempty"`
 	InterruptExistConnections bool               `json:"interrupt_exist_connections,omitempty"`
 }
+
+type FallbackOutboundOptions struct {
+	Outbounds        []string                   `json:"outbounds"`
+	Trigger          badoption.Listable[string] `json:"trigger,omitempty"`
+	HandshakeTimeout badoption.Duration         `json:"handshake_timeout,omitempty"`
+	ResetBytes       uint32                     `json:"reset_bytes,omitempty"`
+	TTL              badoption.Duration         `json:"ttl,omitempty"`
+}
+
+type RotateOutboundOptions struct {
+	Outbounds   []string           `json:"outbounds"`
+	Interval    badoption.Duration `json:"interval,omitempty"`
+	Connections uint32             `json:"connections,omitempty"`
+	Bytes       MemoryBytes        `json:"bytes,omitempty"`
+	Random      bool               `json:"random,omitempty"`
+}
@@ -0,0 +1,24 @@
+package option
+
+import "github.com/sagernet/sing/common/json/badoption"
+
+type HTTP2OutboundOptions struct {
+	DialerOptions
+	ServerOptions
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	OutboundTLSOptionsContainer
+	Headers badoption.HTTPHeader `json:"headers,omitempty"`
+}
+
+type HTTP3OutboundOptions struct {
+	DialerOptions
+	ServerOptions
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	OutboundTLSOptionsContainer
+	Headers badoption.HTTPHeader `json:"headers,omitempty"`
+	Network NetworkList          `json:"network,omitempty"`
+	UDPPath string               `json:"udp_path,omitempty"`
+	QUICPathMTUOptions
+}
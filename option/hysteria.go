@@ -7,6 +7,7 @@ type HysteriaInboundOptions struct {
 	Down                string         `json:"down,omitempty"`
 	DownMbps            int            `json:"down_mbps,omitempty"`
 	Obfs                string         `json:"obfs,omitempty"`
+	Protocol            string         `json:"protocol,omitempty"`
 	Users               []HysteriaUser `json:"users,omitempty"`
 	ReceiveWindowConn   uint64         `json:"recv_window_conn,omitempty"`
 	ReceiveWindowClient uint64         `json:"recv_window_client,omitempty"`
@@ -29,6 +30,7 @@ type HysteriaOutboundOptions struct {
 	Down                string      `json:"down,omitempty"`
 	DownMbps            int         `json:"down_mbps,omitempty"`
 	Obfs                string      `json:"obfs,omitempty"`
+	Protocol            string      `json:"protocol,omitempty"`
 	Auth                []byte      `json:"auth,omitempty"`
 	AuthString          string      `json:"auth_str,omitempty"`
 	ReceiveWindowConn   uint64      `json:"recv_window_conn,omitempty"`
@@ -1,8 +1,10 @@
 package option
 
 import (
+	"context"
 	"net/url"
 
+	"github.com/sagernet/sing-box/common/jsonschema"
 	C "github.com/sagernet/sing-box/constant"
 	E "github.com/sagernet/sing/common/exceptions"
 	"github.com/sagernet/sing/common/json"
@@ -30,6 +32,7 @@ type Hysteria2Obfs struct {
 type Hysteria2User struct {
 	Name     string `json:"name,omitempty"`
 	Password string `json:"password,omitempty"`
+	UserQuotaOptions
 }
 
 type _Hysteria2Masquerade struct {
@@ -94,6 +97,20 @@ func (m *Hysteria2Masquerade) UnmarshalJSON(bytes []byte) error {
 	return badjson.UnmarshallExcluded(bytes, (*_Hysteria2Masquerade)(m), v)
 }
 
+func (m *Hysteria2Masquerade) JSONSchema(ctx context.Context) any {
+	return jsonschema.AnyOf{
+		jsonschema.Schema{"type": "string", "format": "uri"},
+		jsonschema.Union{
+			Field: "type",
+			Variants: map[string]any{
+				C.Hysterai2MasqueradeTypeFile:   Hysteria2MasqueradeFile{},
+				C.Hysterai2MasqueradeTypeProxy:  Hysteria2MasqueradeProxy{},
+				C.Hysterai2MasqueradeTypeString: Hysteria2MasqueradeString{},
+			},
+		},
+	}
+}
+
 type Hysteria2MasqueradeFile struct {
 	Directory string `json:"directory"`
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"time"
 
+	"github.com/sagernet/sing-box/common/jsonschema"
 	E "github.com/sagernet/sing/common/exceptions"
 	"github.com/sagernet/sing/common/json"
 	"github.com/sagernet/sing/common/json/badjson"
@@ -13,6 +14,7 @@ import (
 
 type InboundOptionsRegistry interface {
 	CreateOptions(outboundType string) (any, bool)
+	Types() []string
 }
 
 type _Inbound struct {
@@ -48,6 +50,15 @@ func (h *Inbound) UnmarshalJSONContext(ctx context.Context, content []byte) erro
 	return nil
 }
 
+func (h *Inbound) JSONSchema(ctx context.Context) any {
+	registry := service.FromContext[InboundOptionsRegistry](ctx)
+	variants := make(map[string]any)
+	for _, inboundType := range registry.Types() {
+		variants[inboundType], _ = registry.CreateOptions(inboundType)
+	}
+	return jsonschema.Union{Field: "type", Common: _Inbound{}, Variants: variants}
+}
+
 // Deprecated: Use rule action instead
 type InboundOptions struct {
 	SniffEnabled              bool               `json:"sniff,omitempty"`
@@ -56,6 +67,7 @@ type InboundOptions struct {
 	DomainStrategy            DomainStrategy     `json:"domain_strategy,omitempty"`
 	UDPDisableDomainUnmapping bool               `json:"udp_disable_domain_unmapping,omitempty"`
 	Detour                    string             `json:"detour,omitempty"`
+	SniffBufferLimit          MemoryBytes        `json:"sniff_buffer_limit,omitempty"`
 }
 
 type ListenOptions struct {
@@ -63,11 +75,16 @@ type ListenOptions struct {
 	ListenPort           uint16             `json:"listen_port,omitempty"`
 	TCPKeepAlive         badoption.Duration `json:"tcp_keep_alive,omitempty"`
 	TCPKeepAliveInterval badoption.Duration `json:"tcp_keep_alive_interval,omitempty"`
+	TCPKeepAliveCount    int                `json:"tcp_keep_alive_count,omitempty"`
 	TCPFastOpen          bool               `json:"tcp_fast_open,omitempty"`
 	TCPMultiPath         bool               `json:"tcp_multi_path,omitempty"`
+	TCPBacklog           int                `json:"tcp_backlog,omitempty"`
+	ReusePort            bool               `json:"reuse_port,omitempty"`
+	ReusePortListeners   int                `json:"reuse_port_listeners,omitempty"`
 	UDPFragment          *bool              `json:"udp_fragment,omitempty"`
 	UDPFragmentDefault   bool               `json:"-"`
 	UDPTimeout           UDPTimeoutCompat   `json:"udp_timeout,omitempty"`
+	Timeouts             *TimeoutOptions    `json:"timeouts,omitempty"`
 
 	// Deprecated: removed
 	ProxyProtocol bool `json:"proxy_protocol,omitempty"`
@@ -92,6 +109,10 @@ func (c *UDPTimeoutCompat) UnmarshalJSON(data []byte) error {
 	return json.Unmarshal(data, (*badoption.Duration)(c))
 }
 
+func (c *UDPTimeoutCompat) JSONSchema(ctx context.Context) any {
+	return jsonschema.AnyOf{int64(0), badoption.Duration(0)}
+}
+
 type ListenOptionsWrapper interface {
 	TakeListenOptions() ListenOptions
 	ReplaceListenOptions(options ListenOptions)
@@ -104,3 +125,8 @@ func (o *ListenOptions) TakeListenOptions() ListenOptions {
 func (o *ListenOptions) ReplaceListenOptions(options ListenOptions) {
 	*o = options
 }
+
+type UserQuotaOptions struct {
+	Quota    MemoryBytes `json:"quota,omitempty"`
+	ExpireAt string      `json:"expire_at,omitempty"`
+}
@@ -4,7 +4,8 @@ import "github.com/sagernet/sing/common/auth"
 
 type NaiveInboundOptions struct {
 	ListenOptions
-	Users   []auth.User `json:"users,omitempty"`
-	Network NetworkList `json:"network,omitempty"`
+	Users    []auth.User    `json:"users,omitempty"`
+	Network  NetworkList    `json:"network,omitempty"`
+	Fallback *ServerOptions `json:"fallback,omitempty"`
 	InboundTLSOptionsContainer
 }
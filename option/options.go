@@ -13,10 +13,12 @@ type _Options struct {
 	Log          *LogOptions          `json:"log,omitempty"`
 	DNS          *DNSOptions          `json:"dns,omitempty"`
 	NTP          *NTPOptions          `json:"ntp,omitempty"`
+	Certificate  *CertificateOptions  `json:"certificate,omitempty"`
 	Endpoints    []Endpoint           `json:"endpoints,omitempty"`
 	Inbounds     []Inbound            `json:"inbounds,omitempty"`
 	Outbounds    []Outbound           `json:"outbounds,omitempty"`
 	Route        *RouteOptions        `json:"route,omitempty"`
+	Timeouts     *TimeoutOptions      `json:"timeouts,omitempty"`
 	Experimental *ExperimentalOptions `json:"experimental,omitempty"`
 }
 
@@ -34,11 +36,12 @@ func (o *Options) UnmarshalJSONContext(ctx context.Context, content []byte) erro
 }
 
 type LogOptions struct {
-	Disabled     bool   `json:"disabled,omitempty"`
-	Level        string `json:"level,omitempty"`
-	Output       string `json:"output,omitempty"`
-	Timestamp    bool   `json:"timestamp,omitempty"`
-	DisableColor bool   `json:"-"`
+	Disabled     bool              `json:"disabled,omitempty"`
+	Level        string            `json:"level,omitempty"`
+	ModuleLevel  map[string]string `json:"module_level,omitempty"`
+	Output       string            `json:"output,omitempty"`
+	Timestamp    bool              `json:"timestamp,omitempty"`
+	DisableColor bool              `json:"-"`
 }
 
 type StubOptions struct{}
@@ -3,8 +3,10 @@ package option
 import (
 	"context"
 
+	"github.com/sagernet/sing-box/common/jsonschema"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/experimental/deprecated"
+	"github.com/sagernet/sing-dns"
 	E "github.com/sagernet/sing/common/exceptions"
 	"github.com/sagernet/sing/common/json"
 	"github.com/sagernet/sing/common/json/badjson"
@@ -15,6 +17,7 @@ import (
 
 type OutboundOptionsRegistry interface {
 	CreateOptions(outboundType string) (any, bool)
+	Types() []string
 }
 
 type _Outbound struct {
@@ -59,6 +62,15 @@ func (h *Outbound) UnmarshalJSONContext(ctx context.Context, content []byte) err
 	return nil
 }
 
+func (h *Outbound) JSONSchema(ctx context.Context) any {
+	registry := service.FromContext[OutboundOptionsRegistry](ctx)
+	variants := make(map[string]any)
+	for _, outboundType := range registry.Types() {
+		variants[outboundType], _ = registry.CreateOptions(outboundType)
+	}
+	return jsonschema.Union{Field: "type", Common: _Outbound{}, Variants: variants}
+}
+
 type DialerOptionsWrapper interface {
 	TakeDialerOptions() DialerOptions
 	ReplaceDialerOptions(options DialerOptions)
@@ -69,6 +81,8 @@ type DialerOptions struct {
 	BindInterface       string                            `json:"bind_interface,omitempty"`
 	Inet4BindAddress    *badoption.Addr                   `json:"inet4_bind_address,omitempty"`
 	Inet6BindAddress    *badoption.Addr                   `json:"inet6_bind_address,omitempty"`
+	Inet6BindPrefix     *badoption.Prefix                 `json:"inet6_bind_prefix,omitempty"`
+	Inet6BindPrefixMode string                            `json:"inet6_bind_prefix_mode,omitempty"`
 	ProtectPath         string                            `json:"protect_path,omitempty"`
 	RoutingMark         FwMark                            `json:"routing_mark,omitempty"`
 	ReuseAddr           bool                              `json:"reuse_addr,omitempty"`
@@ -78,13 +92,47 @@ type DialerOptions struct {
 	UDPFragment         *bool                             `json:"udp_fragment,omitempty"`
 	UDPFragmentDefault  bool                              `json:"-"`
 	DomainStrategy      DomainStrategy                    `json:"domain_strategy,omitempty"`
+	DomainResolver      *DomainResolveOptions             `json:"domain_resolver,omitempty"`
 	NetworkStrategy     *NetworkStrategy                  `json:"network_strategy,omitempty"`
 	NetworkType         badoption.Listable[InterfaceType] `json:"network_type,omitempty"`
 	FallbackNetworkType badoption.Listable[InterfaceType] `json:"fallback_network_type,omitempty"`
 	FallbackDelay       badoption.Duration                `json:"fallback_delay,omitempty"`
+	Timeouts            *TimeoutOptions                   `json:"timeouts,omitempty"`
+	SendProxyProtocol   bool                              `json:"send_proxy_protocol,omitempty"`
+	ForwardedHeader     *ForwardedHeaderOptions           `json:"forwarded_header,omitempty"`
 	IsWireGuardListener bool                              `json:"-"`
 }
 
+type ForwardedHeaderOptions struct {
+	Mode    string                     `json:"mode,omitempty"`
+	Headers badoption.Listable[string] `json:"headers,omitempty"`
+}
+
+type _DomainResolveOptions struct {
+	Server           string             `json:"server,omitempty"`
+	Strategy         DomainStrategy     `json:"strategy,omitempty"`
+	NegativeCacheTTL badoption.Duration `json:"negative_cache_ttl,omitempty"`
+}
+
+type DomainResolveOptions _DomainResolveOptions
+
+func (o DomainResolveOptions) MarshalJSON() ([]byte, error) {
+	if o.Strategy == DomainStrategy(dns.DomainStrategyAsIS) && o.NegativeCacheTTL == 0 {
+		return json.Marshal(o.Server)
+	}
+	return json.Marshal((_DomainResolveOptions)(o))
+}
+
+func (o *DomainResolveOptions) UnmarshalJSON(bytes []byte) error {
+	var stringValue string
+	err := json.Unmarshal(bytes, &stringValue)
+	if err == nil {
+		o.Server = stringValue
+		return nil
+	}
+	return json.Unmarshal(bytes, (*_DomainResolveOptions)(o))
+}
+
 func (o *DialerOptions) TakeDialerOptions() DialerOptions {
 	return *o
 }
@@ -0,0 +1,12 @@
+package option
+
+import "github.com/sagernet/sing/common/json/badoption"
+
+type PacketCaptureOptions struct {
+	Path     string                     `json:"path,omitempty"`
+	Inbound  badoption.Listable[string] `json:"inbound,omitempty"`
+	Outbound badoption.Listable[string] `json:"outbound,omitempty"`
+	Filter   string                     `json:"filter,omitempty"`
+	MaxSize  MemoryBytes                `json:"max_size,omitempty"`
+	Duration badoption.Duration         `json:"duration,omitempty"`
+}
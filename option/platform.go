@@ -1,6 +1,9 @@
 package option
 
 import (
+	"context"
+
+	"github.com/sagernet/sing-box/common/jsonschema"
 	E "github.com/sagernet/sing/common/exceptions"
 	"github.com/sagernet/sing/common/json"
 	"github.com/sagernet/sing/common/json/badoption"
@@ -63,6 +66,10 @@ func (r *OnDemandRuleAction) UnmarshalJSON(bytes []byte) error {
 	return nil
 }
 
+func (r *OnDemandRuleAction) JSONSchema(ctx context.Context) any {
+	return jsonschema.Enum{"connect", "disconnect", "evaluate_connection", "ignore"}
+}
+
 type OnDemandRuleInterfaceType int
 
 func (r *OnDemandRuleInterfaceType) MarshalJSON() ([]byte, error) {
@@ -103,3 +110,7 @@ func (r *OnDemandRuleInterfaceType) UnmarshalJSON(bytes []byte) error {
 	*r = OnDemandRuleInterfaceType(interfaceTypeValue)
 	return nil
 }
+
+func (r *OnDemandRuleInterfaceType) JSONSchema(ctx context.Context) any {
+	return jsonschema.Enum{"any", "wifi", "cellular"}
+}
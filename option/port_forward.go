@@ -0,0 +1,9 @@
+package option
+
+type PortForwardOptions struct {
+	ListenOptions
+	ServerOptions
+	Network           NetworkList `json:"network,omitempty"`
+	Outbound          string      `json:"outbound,omitempty"`
+	SendProxyProtocol bool        `json:"send_proxy_protocol,omitempty"`
+}
@@ -0,0 +1,9 @@
+package option
+
+import "github.com/sagernet/sing/common/json/badoption"
+
+type PreConnectOptions struct {
+	Enabled     bool               `json:"enabled,omitempty"`
+	Size        int                `json:"size,omitempty"`
+	IdleTimeout badoption.Duration `json:"idle_timeout,omitempty"`
+}
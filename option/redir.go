@@ -6,5 +6,6 @@ type RedirectInboundOptions struct {
 
 type TProxyInboundOptions struct {
 	ListenOptions
-	Network NetworkList `json:"network,omitempty"`
+	Network        NetworkList    `json:"network,omitempty"`
+	UDPNATStrategy UDPNATStrategy `json:"udp_nat_strategy,omitempty"`
 }
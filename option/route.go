@@ -3,30 +3,47 @@ package option
 import "github.com/sagernet/sing/common/json/badoption"
 
 type RouteOptions struct {
-	GeoIP                      *GeoIPOptions                     `json:"geoip,omitempty"`
-	Geosite                    *GeositeOptions                   `json:"geosite,omitempty"`
-	Rules                      []Rule                            `json:"rules,omitempty"`
-	RuleSet                    []RuleSet                         `json:"rule_set,omitempty"`
-	Final                      string                            `json:"final,omitempty"`
-	FindProcess                bool                              `json:"find_process,omitempty"`
-	AutoDetectInterface        bool                              `json:"auto_detect_interface,omitempty"`
-	OverrideAndroidVPN         bool                              `json:"override_android_vpn,omitempty"`
-	DefaultInterface           string                            `json:"default_interface,omitempty"`
-	DefaultMark                FwMark                            `json:"default_mark,omitempty"`
-	DefaultNetworkStrategy     *NetworkStrategy                  `json:"default_network_strategy,omitempty"`
-	DefaultNetworkType         badoption.Listable[InterfaceType] `json:"default_network_type,omitempty"`
-	DefaultFallbackNetworkType badoption.Listable[InterfaceType] `json:"default_fallback_network_type,omitempty"`
-	DefaultFallbackDelay       badoption.Duration                `json:"default_fallback_delay,omitempty"`
+	GeoIP                         *GeoIPOptions                     `json:"geoip,omitempty"`
+	Geosite                       *GeositeOptions                   `json:"geosite,omitempty"`
+	Rules                         []Rule                            `json:"rules,omitempty"`
+	RuleSet                       []RuleSet                         `json:"rule_set,omitempty"`
+	Final                         string                            `json:"final,omitempty"`
+	FindProcess                   bool                              `json:"find_process,omitempty"`
+	BypassPrivate                 string                            `json:"bypass_private,omitempty"`
+	AutoDetectInterface           bool                              `json:"auto_detect_interface,omitempty"`
+	OverrideAndroidVPN            bool                              `json:"override_android_vpn,omitempty"`
+	DefaultInterface              badoption.Listable[string]        `json:"default_interface,omitempty"`
+	DefaultInterfaceCheckURL      string                            `json:"default_interface_check_url,omitempty"`
+	DefaultInterfaceCheckInterval badoption.Duration                `json:"default_interface_check_interval,omitempty"`
+	DefaultMark                   FwMark                            `json:"default_mark,omitempty"`
+	DefaultNetworkStrategy        *NetworkStrategy                  `json:"default_network_strategy,omitempty"`
+	DefaultNetworkType            badoption.Listable[InterfaceType] `json:"default_network_type,omitempty"`
+	DefaultFallbackNetworkType    badoption.Listable[InterfaceType] `json:"default_fallback_network_type,omitempty"`
+	DefaultFallbackDelay          badoption.Duration                `json:"default_fallback_delay,omitempty"`
+	DrainTimeout                  badoption.Duration                `json:"drain_timeout,omitempty"`
+	UDPTimeout                    map[string]badoption.Duration     `json:"udp_timeout,omitempty"`
+	Contexts                      []RouteContextOptions             `json:"contexts,omitempty"`
+}
+
+type RouteContextOptions struct {
+	Name    string                     `json:"name"`
+	Inbound badoption.Listable[string] `json:"inbound"`
+	Rules   []Rule                     `json:"rules,omitempty"`
+	Final   string                     `json:"final,omitempty"`
 }
 
 type GeoIPOptions struct {
-	Path           string `json:"path,omitempty"`
-	DownloadURL    string `json:"download_url,omitempty"`
-	DownloadDetour string `json:"download_detour,omitempty"`
+	Path           string             `json:"path,omitempty"`
+	DownloadURL    string             `json:"download_url,omitempty"`
+	DownloadDetour string             `json:"download_detour,omitempty"`
+	ChecksumURL    string             `json:"checksum_url,omitempty"`
+	UpdateInterval badoption.Duration `json:"update_interval,omitempty"`
 }
 
 type GeositeOptions struct {
-	Path           string `json:"path,omitempty"`
-	DownloadURL    string `json:"download_url,omitempty"`
-	DownloadDetour string `json:"download_detour,omitempty"`
+	Path           string             `json:"path,omitempty"`
+	DownloadURL    string             `json:"download_url,omitempty"`
+	DownloadDetour string             `json:"download_detour,omitempty"`
+	ChecksumURL    string             `json:"checksum_url,omitempty"`
+	UpdateInterval badoption.Duration `json:"update_interval,omitempty"`
 }
@@ -1,8 +1,10 @@
 package option
 
 import (
+	"context"
 	"reflect"
 
+	"github.com/sagernet/sing-box/common/jsonschema"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing/common"
 	E "github.com/sagernet/sing/common/exceptions"
@@ -55,6 +57,28 @@ func (r *Rule) UnmarshalJSON(bytes []byte) error {
 	return nil
 }
 
+func (r *Rule) JSONSchema(ctx context.Context) any {
+	return jsonschema.Union{
+		Field:   "type",
+		Default: C.RuleTypeDefault,
+		Variants: map[string]any{
+			C.RuleTypeDefault: DefaultRule{},
+			C.RuleTypeLogical: LogicalRule{},
+		},
+	}
+}
+
+func (r Rule) Group() string {
+	switch r.Type {
+	case C.RuleTypeDefault:
+		return r.DefaultOptions.Group
+	case C.RuleTypeLogical:
+		return r.LogicalOptions.Group
+	default:
+		return ""
+	}
+}
+
 func (r Rule) IsValid() bool {
 	switch r.Type {
 	case C.RuleTypeDefault:
@@ -73,6 +97,9 @@ type RawDefaultRule struct {
 	AuthUser                 badoption.Listable[string]        `json:"auth_user,omitempty"`
 	Protocol                 badoption.Listable[string]        `json:"protocol,omitempty"`
 	Client                   badoption.Listable[string]        `json:"client,omitempty"`
+	HTTPPath                 badoption.Listable[string]        `json:"http_path,omitempty"`
+	HTTPUserAgent            badoption.Listable[string]        `json:"http_user_agent,omitempty"`
+	TLSFingerprint           badoption.Listable[string]        `json:"tls_fingerprint,omitempty"`
 	Domain                   badoption.Listable[string]        `json:"domain,omitempty"`
 	DomainSuffix             badoption.Listable[string]        `json:"domain_suffix,omitempty"`
 	DomainKeyword            badoption.Listable[string]        `json:"domain_keyword,omitempty"`
@@ -103,6 +130,7 @@ type RawDefaultRule struct {
 	RuleSet                  badoption.Listable[string]        `json:"rule_set,omitempty"`
 	RuleSetIPCIDRMatchSource bool                              `json:"rule_set_ip_cidr_match_source,omitempty"`
 	Invert                   bool                              `json:"invert,omitempty"`
+	Group                    string                            `json:"group,omitempty"`
 
 	// Deprecated: renamed to rule_set_ip_cidr_match_source
 	Deprecated_RulesetIPCIDRMatchSource bool `json:"rule_set_ipcidr_match_source,omitempty"`
@@ -125,9 +153,16 @@ func (r *DefaultRule) UnmarshalJSON(data []byte) error {
 	return badjson.UnmarshallExcluded(data, &r.RawDefaultRule, &r.RuleAction)
 }
 
+func (r *DefaultRule) JSONSchema(ctx context.Context) any {
+	union := (*RuleAction)(nil).JSONSchema(ctx).(jsonschema.Union)
+	union.Common = RawDefaultRule{}
+	return union
+}
+
 func (r *DefaultRule) IsValid() bool {
 	var defaultValue DefaultRule
 	defaultValue.Invert = r.Invert
+	defaultValue.Group = r.Group
 	defaultValue.Action = r.Action
 	return !reflect.DeepEqual(r, defaultValue)
 }
@@ -136,6 +171,7 @@ type RawLogicalRule struct {
 	Mode   string `json:"mode"`
 	Rules  []Rule `json:"rules,omitempty"`
 	Invert bool   `json:"invert,omitempty"`
+	Group  string `json:"group,omitempty"`
 }
 
 type LogicalRule struct {
@@ -155,6 +191,12 @@ func (r *LogicalRule) UnmarshalJSON(data []byte) error {
 	return badjson.UnmarshallExcluded(data, &r.RawLogicalRule, &r.RuleAction)
 }
 
+func (r *LogicalRule) JSONSchema(ctx context.Context) any {
+	union := (*RuleAction)(nil).JSONSchema(ctx).(jsonschema.Union)
+	union.Common = RawLogicalRule{}
+	return union
+}
+
 func (r *LogicalRule) IsValid() bool {
 	return len(r.Rules) > 0 && common.All(r.Rules, Rule.IsValid)
 }
@@ -6,12 +6,14 @@ import (
 	"net/netip"
 	"time"
 
+	"github.com/sagernet/sing-box/common/jsonschema"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-dns"
 	E "github.com/sagernet/sing/common/exceptions"
 	"github.com/sagernet/sing/common/json"
 	"github.com/sagernet/sing/common/json/badjson"
 	"github.com/sagernet/sing/common/json/badoption"
+	N "github.com/sagernet/sing/common/network"
 )
 
 type _RuleAction struct {
@@ -20,6 +22,7 @@ type _RuleAction struct {
 	RouteOptionsOptions RouteOptionsActionOptions `json:"-"`
 	DirectOptions       DirectActionOptions       `json:"-"`
 	RejectOptions       RejectActionOptions       `json:"-"`
+	HijackDNSOptions    RouteActionHijackDNS      `json:"-"`
 	SniffOptions        RouteActionSniff          `json:"-"`
 	ResolveOptions      RouteActionResolve        `json:"-"`
 }
@@ -42,7 +45,7 @@ func (r RuleAction) MarshalJSON() ([]byte, error) {
 	case C.RuleActionTypeReject:
 		v = r.RejectOptions
 	case C.RuleActionTypeHijackDNS:
-		v = nil
+		v = r.HijackDNSOptions
 	case C.RuleActionTypeSniff:
 		v = r.SniffOptions
 	case C.RuleActionTypeResolve:
@@ -73,7 +76,7 @@ func (r *RuleAction) UnmarshalJSON(data []byte) error {
 	case C.RuleActionTypeReject:
 		v = &r.RejectOptions
 	case C.RuleActionTypeHijackDNS:
-		v = nil
+		v = &r.HijackDNSOptions
 	case C.RuleActionTypeSniff:
 		v = &r.SniffOptions
 	case C.RuleActionTypeResolve:
@@ -88,6 +91,22 @@ func (r *RuleAction) UnmarshalJSON(data []byte) error {
 	return badjson.UnmarshallExcluded(data, (*_RuleAction)(r), v)
 }
 
+func (r *RuleAction) JSONSchema(ctx context.Context) any {
+	return jsonschema.Union{
+		Field:   "action",
+		Default: C.RuleActionTypeRoute,
+		Variants: map[string]any{
+			C.RuleActionTypeRoute:        RouteActionOptions{},
+			C.RuleActionTypeRouteOptions: RouteOptionsActionOptions{},
+			C.RuleActionTypeDirect:       DirectActionOptions{},
+			C.RuleActionTypeReject:       RejectActionOptions{},
+			C.RuleActionTypeHijackDNS:    RouteActionHijackDNS{},
+			C.RuleActionTypeSniff:        RouteActionSniff{},
+			C.RuleActionTypeResolve:      RouteActionResolve{},
+		},
+	}
+}
+
 type _DNSRuleAction struct {
 	Action              string                       `json:"action,omitempty"`
 	RouteOptions        DNSRouteActionOptions        `json:"-"`
@@ -133,7 +152,26 @@ func (r *DNSRuleAction) UnmarshalJSONContext(ctx context.Context, data []byte) e
 	default:
 		return E.New("unknown DNS rule action: " + r.Action)
 	}
-	return badjson.UnmarshallExcludedContext(ctx, data, (*_DNSRuleAction)(r), v)
+	err = badjson.UnmarshallExcludedContext(ctx, data, (*_DNSRuleAction)(r), v)
+	if err != nil {
+		return err
+	}
+	if r.Action == C.RuleActionTypeReject && (r.RejectOptions.TCPMethod != "" || r.RejectOptions.UDPMethod != "") {
+		return E.New("tcp_method and udp_method are not available in DNS rules")
+	}
+	return nil
+}
+
+func (r *DNSRuleAction) JSONSchema(ctx context.Context) any {
+	return jsonschema.Union{
+		Field:   "action",
+		Default: C.RuleActionTypeRoute,
+		Variants: map[string]any{
+			C.RuleActionTypeRoute:        DNSRouteActionOptions{},
+			C.RuleActionTypeRouteOptions: DNSRouteOptionsActionOptions{},
+			C.RuleActionTypeReject:       RejectActionOptions{},
+		},
+	}
 }
 
 type RouteActionOptions struct {
@@ -151,6 +189,9 @@ type RawRouteOptionsActionOptions struct {
 	UDPDisableDomainUnmapping bool               `json:"udp_disable_domain_unmapping,omitempty"`
 	UDPConnect                bool               `json:"udp_connect,omitempty"`
 	UDPTimeout                badoption.Duration `json:"udp_timeout,omitempty"`
+
+	DSCP *uint8 `json:"dscp,omitempty"`
+	ECN  *uint8 `json:"ecn,omitempty"`
 }
 
 type RouteOptionsActionOptions RawRouteOptionsActionOptions
@@ -167,16 +208,16 @@ func (r *RouteOptionsActionOptions) UnmarshalJSON(data []byte) error {
 }
 
 type DNSRouteActionOptions struct {
-	Server       string                `json:"server,omitempty"`
-	DisableCache bool                  `json:"disable_cache,omitempty"`
-	RewriteTTL   *uint32               `json:"rewrite_ttl,omitempty"`
-	ClientSubnet *badoption.Prefixable `json:"client_subnet,omitempty"`
+	Server       string           `json:"server,omitempty"`
+	DisableCache bool             `json:"disable_cache,omitempty"`
+	RewriteTTL   *uint32          `json:"rewrite_ttl,omitempty"`
+	ClientSubnet *DNSClientSubnet `json:"client_subnet,omitempty"`
 }
 
 type _DNSRouteOptionsActionOptions struct {
-	DisableCache bool                  `json:"disable_cache,omitempty"`
-	RewriteTTL   *uint32               `json:"rewrite_ttl,omitempty"`
-	ClientSubnet *badoption.Prefixable `json:"client_subnet,omitempty"`
+	DisableCache bool             `json:"disable_cache,omitempty"`
+	RewriteTTL   *uint32          `json:"rewrite_ttl,omitempty"`
+	ClientSubnet *DNSClientSubnet `json:"client_subnet,omitempty"`
 }
 
 type DNSRouteOptionsActionOptions _DNSRouteOptionsActionOptions
@@ -246,8 +287,10 @@ func (d *DirectActionOptions) UnmarshalJSON(data []byte) error {
 }
 
 type _RejectActionOptions struct {
-	Method string `json:"method,omitempty"`
-	NoDrop bool   `json:"no_drop,omitempty"`
+	Method    string `json:"method,omitempty"`
+	TCPMethod string `json:"tcp_method,omitempty"`
+	UDPMethod string `json:"udp_method,omitempty"`
+	NoDrop    bool   `json:"no_drop,omitempty"`
 }
 
 type RejectActionOptions _RejectActionOptions
@@ -264,18 +307,55 @@ func (r *RejectActionOptions) UnmarshalJSON(bytes []byte) error {
 	default:
 		return E.New("unknown reject method: " + r.Method)
 	}
-	if r.Method == C.RuleActionRejectMethodDrop && r.NoDrop {
+	switch r.TCPMethod {
+	case "", C.RuleActionRejectMethodReset, C.RuleActionRejectMethodDrop, C.RuleActionRejectMethodHTTP:
+	default:
+		return E.New("unknown TCP reject method: " + r.TCPMethod)
+	}
+	switch r.UDPMethod {
+	case "", C.RuleActionRejectMethodUnreachable, C.RuleActionRejectMethodDrop:
+	default:
+		return E.New("unknown UDP reject method: " + r.UDPMethod)
+	}
+	if r.NoDrop && r.NetworkMethod(N.NetworkTCP) == C.RuleActionRejectMethodDrop && r.NetworkMethod(N.NetworkUDP) == C.RuleActionRejectMethodDrop {
 		return E.New("no_drop is not available in current context")
 	}
 	return nil
 }
 
+// NetworkMethod returns the reject method used for connections of network,
+// which defaults to the one derived from method.
+func (r RejectActionOptions) NetworkMethod(network string) string {
+	var method string
+	if network == N.NetworkTCP {
+		method = r.TCPMethod
+	} else {
+		method = r.UDPMethod
+	}
+	if method != "" {
+		return method
+	}
+	if r.Method == C.RuleActionRejectMethodDrop {
+		return C.RuleActionRejectMethodDrop
+	}
+	if network == N.NetworkTCP {
+		return C.RuleActionRejectMethodReset
+	}
+	return C.RuleActionRejectMethodUnreachable
+}
+
+type RouteActionHijackDNS struct {
+	Rcode  string                     `json:"rcode,omitempty"`
+	Answer badoption.Listable[string] `json:"answer,omitempty"`
+}
+
 type RouteActionSniff struct {
 	Sniffer badoption.Listable[string] `json:"sniffer,omitempty"`
 	Timeout badoption.Duration         `json:"timeout,omitempty"`
 }
 
 type RouteActionResolve struct {
-	Strategy DomainStrategy `json:"strategy,omitempty"`
-	Server   string         `json:"server,omitempty"`
+	Strategy       DomainStrategy `json:"strategy,omitempty"`
+	Server         string         `json:"server,omitempty"`
+	SkipIfResolved bool           `json:"skip_if_resolved,omitempty"`
 }
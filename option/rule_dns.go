@@ -4,6 +4,7 @@ import (
 	"context"
 	"reflect"
 
+	"github.com/sagernet/sing-box/common/jsonschema"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing/common"
 	E "github.com/sagernet/sing/common/exceptions"
@@ -56,6 +57,28 @@ func (r *DNSRule) UnmarshalJSONContext(ctx context.Context, bytes []byte) error
 	return nil
 }
 
+func (r *DNSRule) JSONSchema(ctx context.Context) any {
+	return jsonschema.Union{
+		Field:   "type",
+		Default: C.RuleTypeDefault,
+		Variants: map[string]any{
+			C.RuleTypeDefault: DefaultDNSRule{},
+			C.RuleTypeLogical: LogicalDNSRule{},
+		},
+	}
+}
+
+func (r DNSRule) Group() string {
+	switch r.Type {
+	case C.RuleTypeDefault:
+		return r.DefaultOptions.Group
+	case C.RuleTypeLogical:
+		return r.LogicalOptions.Group
+	default:
+		return ""
+	}
+}
+
 func (r DNSRule) IsValid() bool {
 	switch r.Type {
 	case C.RuleTypeDefault:
@@ -106,6 +129,7 @@ type RawDefaultDNSRule struct {
 	RuleSetIPCIDRMatchSource bool                              `json:"rule_set_ip_cidr_match_source,omitempty"`
 	RuleSetIPCIDRAcceptEmpty bool                              `json:"rule_set_ip_cidr_accept_empty,omitempty"`
 	Invert                   bool                              `json:"invert,omitempty"`
+	Group                    string                            `json:"group,omitempty"`
 
 	// Deprecated: renamed to rule_set_ip_cidr_match_source
 	Deprecated_RulesetIPCIDRMatchSource bool `json:"rule_set_ipcidr_match_source,omitempty"`
@@ -128,9 +152,16 @@ func (r *DefaultDNSRule) UnmarshalJSONContext(ctx context.Context, data []byte)
 	return badjson.UnmarshallExcludedContext(ctx, data, &r.RawDefaultDNSRule, &r.DNSRuleAction)
 }
 
+func (r *DefaultDNSRule) JSONSchema(ctx context.Context) any {
+	union := (*DNSRuleAction)(nil).JSONSchema(ctx).(jsonschema.Union)
+	union.Common = RawDefaultDNSRule{}
+	return union
+}
+
 func (r DefaultDNSRule) IsValid() bool {
 	var defaultValue DefaultDNSRule
 	defaultValue.Invert = r.Invert
+	defaultValue.Group = r.Group
 	defaultValue.DNSRuleAction = r.DNSRuleAction
 	return !reflect.DeepEqual(r, defaultValue)
 }
@@ -139,6 +170,7 @@ type RawLogicalDNSRule struct {
 	Mode   string    `json:"mode"`
 	Rules  []DNSRule `json:"rules,omitempty"`
 	Invert bool      `json:"invert,omitempty"`
+	Group  string    `json:"group,omitempty"`
 }
 
 type LogicalDNSRule struct {
@@ -158,6 +190,12 @@ func (r *LogicalDNSRule) UnmarshalJSONContext(ctx context.Context, data []byte)
 	return badjson.UnmarshallExcludedContext(ctx, data, &r.RawLogicalDNSRule, &r.DNSRuleAction)
 }
 
+func (r *LogicalDNSRule) JSONSchema(ctx context.Context) any {
+	union := (*DNSRuleAction)(nil).JSONSchema(ctx).(jsonschema.Union)
+	union.Common = RawLogicalDNSRule{}
+	return union
+}
+
 func (r *LogicalDNSRule) IsValid() bool {
 	return len(r.Rules) > 0 && common.All(r.Rules, DNSRule.IsValid)
 }
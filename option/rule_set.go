@@ -1,8 +1,10 @@
 package option
 
 import (
+	"context"
 	"reflect"
 
+	"github.com/sagernet/sing-box/common/jsonschema"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing/common"
 	"github.com/sagernet/sing/common/domain"
@@ -19,6 +21,7 @@ type _RuleSet struct {
 	Type          string        `json:"type,omitempty"`
 	Tag           string        `json:"tag"`
 	Format        string        `json:"format,omitempty"`
+	Behavior      string        `json:"behavior,omitempty"`
 	InlineOptions PlainRuleSet  `json:"-"`
 	LocalOptions  LocalRuleSet  `json:"-"`
 	RemoteOptions RemoteRuleSet `json:"-"`
@@ -66,10 +69,17 @@ func (r *RuleSet) UnmarshalJSON(bytes []byte) error {
 		switch r.Format {
 		case "":
 			return E.New("missing format")
-		case C.RuleSetFormatSource, C.RuleSetFormatBinary:
+		case C.RuleSetFormatSource, C.RuleSetFormatBinary, C.RuleSetFormatAdGuard, C.RuleSetFormatAdblock:
+		case C.RuleSetFormatClash:
+			if r.Behavior == "" {
+				return E.New("missing behavior")
+			}
 		default:
 			return E.New("unknown rule-set format: " + r.Format)
 		}
+		if r.Behavior != "" && r.Format != C.RuleSetFormatClash {
+			return E.New("behavior is only available for clash format")
+		}
 	} else {
 		r.Format = C.RuleSetFormatSource
 	}
@@ -80,8 +90,25 @@ func (r *RuleSet) UnmarshalJSON(bytes []byte) error {
 	return nil
 }
 
+func (r *RuleSet) JSONSchema(ctx context.Context) any {
+	return jsonschema.Union{
+		Field:   "type",
+		Default: C.RuleSetTypeInline,
+		Common:  _RuleSet{},
+		Variants: map[string]any{
+			C.RuleSetTypeInline: PlainRuleSet{},
+			C.RuleSetTypeLocal:  LocalRuleSet{},
+			C.RuleSetTypeRemote: RemoteRuleSet{},
+		},
+	}
+}
+
 type LocalRuleSet struct {
-	Path string `json:"path,omitempty"`
+	Path           string             `json:"path,omitempty"`
+	DownloadURL    string             `json:"download_url,omitempty"`
+	DownloadDetour string             `json:"download_detour,omitempty"`
+	ChecksumURL    string             `json:"checksum_url,omitempty"`
+	UpdateInterval badoption.Duration `json:"update_interval,omitempty"`
 }
 
 type RemoteRuleSet struct {
@@ -134,6 +161,17 @@ func (r *HeadlessRule) UnmarshalJSON(bytes []byte) error {
 	return nil
 }
 
+func (r *HeadlessRule) JSONSchema(ctx context.Context) any {
+	return jsonschema.Union{
+		Field:   "type",
+		Default: C.RuleTypeDefault,
+		Variants: map[string]any{
+			C.RuleTypeDefault: DefaultHeadlessRule{},
+			C.RuleTypeLogical: LogicalHeadlessRule{},
+		},
+	}
+}
+
 func (r HeadlessRule) IsValid() bool {
 	switch r.Type {
 	case C.RuleTypeDefault, "":
@@ -234,6 +272,14 @@ func (r *PlainRuleSetCompat) UnmarshalJSON(bytes []byte) error {
 	return nil
 }
 
+func (r *PlainRuleSetCompat) JSONSchema(ctx context.Context) any {
+	variants := make(map[string]any)
+	for _, version := range []uint8{C.RuleSetVersion1, C.RuleSetVersion2, C.RuleSetVersion3} {
+		variants[F.ToString(version)] = PlainRuleSet{}
+	}
+	return jsonschema.Union{Field: "version", Variants: variants}
+}
+
 func (r PlainRuleSetCompat) Upgrade() (PlainRuleSet, error) {
 	switch r.Version {
 	case C.RuleSetVersion1, C.RuleSetVersion2, C.RuleSetVersion3:
@@ -1,13 +1,20 @@
 package option
 
+import "github.com/sagernet/sing/common/json/badoption"
+
 type ShadowsocksInboundOptions struct {
 	ListenOptions
-	Network      NetworkList              `json:"network,omitempty"`
-	Method       string                   `json:"method"`
-	Password     string                   `json:"password,omitempty"`
-	Users        []ShadowsocksUser        `json:"users,omitempty"`
-	Destinations []ShadowsocksDestination `json:"destinations,omitempty"`
-	Multiplex    *InboundMultiplexOptions `json:"multiplex,omitempty"`
+	Network             NetworkList              `json:"network,omitempty"`
+	Method              string                   `json:"method"`
+	Password            string                   `json:"password,omitempty"`
+	Users               []ShadowsocksUser        `json:"users,omitempty"`
+	Destinations        []ShadowsocksDestination `json:"destinations,omitempty"`
+	Multiplex           *InboundMultiplexOptions `json:"multiplex,omitempty"`
+	ReplayFilter        bool                     `json:"replay_filter,omitempty"`
+	DrainTimeout        badoption.Duration       `json:"drain_timeout,omitempty"`
+	Plugin              string                   `json:"plugin,omitempty"`
+	PluginOptions       string                   `json:"plugin_opts,omitempty"`
+	AcceptProxyProtocol bool                     `json:"accept_proxy_protocol,omitempty"`
 }
 
 type ShadowsocksUser struct {
@@ -24,11 +31,13 @@ type ShadowsocksDestination struct {
 type ShadowsocksOutboundOptions struct {
 	DialerOptions
 	ServerOptions
-	Method        string                    `json:"method"`
-	Password      string                    `json:"password"`
-	Plugin        string                    `json:"plugin,omitempty"`
-	PluginOptions string                    `json:"plugin_opts,omitempty"`
-	Network       NetworkList               `json:"network,omitempty"`
-	UDPOverTCP    *UDPOverTCPOptions        `json:"udp_over_tcp,omitempty"`
-	Multiplex     *OutboundMultiplexOptions `json:"multiplex,omitempty"`
+	Method         string                    `json:"method"`
+	Password       string                    `json:"password"`
+	Plugin         string                    `json:"plugin,omitempty"`
+	PluginOptions  string                    `json:"plugin_opts,omitempty"`
+	Network        NetworkList               `json:"network,omitempty"`
+	UDPOverTCP     *UDPOverTCPOptions        `json:"udp_over_tcp,omitempty"`
+	UDPSession     string                    `json:"udp_session,omitempty"`
+	UDPIdleTimeout badoption.Duration        `json:"udp_idle_timeout,omitempty"`
+	Multiplex      *OutboundMultiplexOptions `json:"multiplex,omitempty"`
 }
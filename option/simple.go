@@ -7,14 +7,29 @@ import (
 
 type SocksInboundOptions struct {
 	ListenOptions
-	Users []auth.User `json:"users,omitempty"`
+	Users          []auth.User          `json:"users,omitempty"`
+	ExternalAuth   *ExternalAuthOptions `json:"external_auth,omitempty"`
+	UDPNATStrategy UDPNATStrategy       `json:"udp_nat_strategy,omitempty"`
 }
 
 type HTTPMixedInboundOptions struct {
 	ListenOptions
-	Users          []auth.User `json:"users,omitempty"`
-	SetSystemProxy bool        `json:"set_system_proxy,omitempty"`
+	Users          []auth.User          `json:"users,omitempty"`
+	ExternalAuth   *ExternalAuthOptions `json:"external_auth,omitempty"`
+	SetSystemProxy bool                 `json:"set_system_proxy,omitempty"`
+	UDPNATStrategy UDPNATStrategy       `json:"udp_nat_strategy,omitempty"`
 	InboundTLSOptionsContainer
+	AcceptProxyProtocol bool `json:"accept_proxy_protocol,omitempty"`
+}
+
+type ExternalAuthOptions struct {
+	Type      string             `json:"type"`
+	URL       string             `json:"url,omitempty"`
+	Detour    string             `json:"detour,omitempty"`
+	Timeout   badoption.Duration `json:"timeout,omitempty"`
+	RateLimit int                `json:"rate_limit,omitempty"`
+	Path      string             `json:"path,omitempty"`
+	CacheTTL  badoption.Duration `json:"cache_ttl,omitempty"`
 }
 
 type SOCKSOutboundOptions struct {
@@ -5,6 +5,12 @@ import "github.com/sagernet/sing/common/json/badoption"
 type SSHOutboundOptions struct {
 	DialerOptions
 	ServerOptions
+	SSHClientOptions
+	MaxChannels       uint32             `json:"max_channels,omitempty"`
+	KeepaliveInterval badoption.Duration `json:"keepalive_interval,omitempty"`
+}
+
+type SSHClientOptions struct {
 	User                 string                     `json:"user,omitempty"`
 	Password             string                     `json:"password,omitempty"`
 	PrivateKey           badoption.Listable[string] `json:"private_key,omitempty"`
@@ -14,3 +20,18 @@ type SSHOutboundOptions struct {
 	HostKeyAlgorithms    badoption.Listable[string] `json:"host_key_algorithms,omitempty"`
 	ClientVersion        string                     `json:"client_version,omitempty"`
 }
+
+type SSHTunnelOptions struct {
+	DialerOptions
+	ServerOptions
+	SSHClientOptions
+	Forwards          []SSHTunnelForwardOptions `json:"forwards"`
+	KeepaliveInterval badoption.Duration        `json:"keepalive_interval,omitempty"`
+	ReconnectDelay    badoption.Duration        `json:"reconnect_delay,omitempty"`
+}
+
+type SSHTunnelForwardOptions struct {
+	Listen     string `json:"listen,omitempty"`
+	ListenPort uint16 `json:"listen_port"`
+	Inbound    string `json:"inbound"`
+}
@@ -0,0 +1,10 @@
+package option
+
+import "github.com/sagernet/sing/common/json/badoption"
+
+type TimeoutOptions struct {
+	TCPHandshake badoption.Duration `json:"tcp_handshake,omitempty"`
+	TLSHandshake badoption.Duration `json:"tls_handshake,omitempty"`
+	UDPNAT       badoption.Duration `json:"udp_nat,omitempty"`
+	RelayIdle    badoption.Duration `json:"relay_idle,omitempty"`
+}
@@ -14,6 +14,8 @@ type InboundTLSOptions struct {
 	CertificatePath string                     `json:"certificate_path,omitempty"`
 	Key             badoption.Listable[string] `json:"key,omitempty"`
 	KeyPath         string                     `json:"key_path,omitempty"`
+	ReadBufferSize  MemoryBytes                `json:"read_buffer_size,omitempty"`
+	KernelTX        bool                       `json:"kernel_tx,omitempty"`
 	ACME            *InboundACMEOptions        `json:"acme,omitempty"`
 	ECH             *InboundECHOptions         `json:"ech,omitempty"`
 	Reality         *InboundRealityOptions     `json:"reality,omitempty"`
@@ -37,19 +39,23 @@ func (o *InboundTLSOptionsContainer) ReplaceInboundTLSOptions(options *InboundTL
 }
 
 type OutboundTLSOptions struct {
-	Enabled         bool                       `json:"enabled,omitempty"`
-	DisableSNI      bool                       `json:"disable_sni,omitempty"`
-	ServerName      string                     `json:"server_name,omitempty"`
-	Insecure        bool                       `json:"insecure,omitempty"`
-	ALPN            badoption.Listable[string] `json:"alpn,omitempty"`
-	MinVersion      string                     `json:"min_version,omitempty"`
-	MaxVersion      string                     `json:"max_version,omitempty"`
-	CipherSuites    badoption.Listable[string] `json:"cipher_suites,omitempty"`
-	Certificate     badoption.Listable[string] `json:"certificate,omitempty"`
-	CertificatePath string                     `json:"certificate_path,omitempty"`
-	ECH             *OutboundECHOptions        `json:"ech,omitempty"`
-	UTLS            *OutboundUTLSOptions       `json:"utls,omitempty"`
-	Reality         *OutboundRealityOptions    `json:"reality,omitempty"`
+	Enabled                    bool                       `json:"enabled,omitempty"`
+	DisableSNI                 bool                       `json:"disable_sni,omitempty"`
+	ServerName                 string                     `json:"server_name,omitempty"`
+	Insecure                   bool                       `json:"insecure,omitempty"`
+	ALPN                       badoption.Listable[string] `json:"alpn,omitempty"`
+	MinVersion                 string                     `json:"min_version,omitempty"`
+	MaxVersion                 string                     `json:"max_version,omitempty"`
+	CipherSuites               badoption.Listable[string] `json:"cipher_suites,omitempty"`
+	Certificate                badoption.Listable[string] `json:"certificate,omitempty"`
+	CertificatePath            string                     `json:"certificate_path,omitempty"`
+	CertificatePaths           badoption.Listable[string] `json:"certificate_paths,omitempty"`
+	CertificatePublicKeySHA256 badoption.Listable[string] `json:"certificate_public_key_sha256,omitempty"`
+	ReadBufferSize             MemoryBytes                `json:"read_buffer_size,omitempty"`
+	KernelTX                   bool                       `json:"kernel_tx,omitempty"`
+	ECH                        *OutboundECHOptions        `json:"ech,omitempty"`
+	UTLS                       *OutboundUTLSOptions       `json:"utls,omitempty"`
+	Reality                    *OutboundRealityOptions    `json:"reality,omitempty"`
 }
 
 type OutboundTLSOptionsContainer struct {
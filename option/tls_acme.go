@@ -1,6 +1,9 @@
 package option
 
 import (
+	"context"
+
+	"github.com/sagernet/sing-box/common/jsonschema"
 	C "github.com/sagernet/sing-box/constant"
 	E "github.com/sagernet/sing/common/exceptions"
 	"github.com/sagernet/sing/common/json"
@@ -71,6 +74,16 @@ func (o *ACMEDNS01ChallengeOptions) UnmarshalJSON(bytes []byte) error {
 	return nil
 }
 
+func (o *ACMEDNS01ChallengeOptions) JSONSchema(ctx context.Context) any {
+	return jsonschema.Union{
+		Field: "provider",
+		Variants: map[string]any{
+			C.DNSProviderAliDNS:     ACMEDNS01AliDNSOptions{},
+			C.DNSProviderCloudflare: ACMEDNS01CloudflareOptions{},
+		},
+	}
+}
+
 type ACMEDNS01AliDNSOptions struct {
 	AccessKeyID     string `json:"access_key_id,omitempty"`
 	AccessKeySecret string `json:"access_key_secret,omitempty"`
@@ -2,25 +2,31 @@ package option
 
 type TrojanInboundOptions struct {
 	ListenOptions
-	Users []TrojanUser `json:"users,omitempty"`
+	Users     []TrojanUser `json:"users,omitempty"`
+	UsersPath string       `json:"users_path,omitempty"`
 	InboundTLSOptionsContainer
-	Fallback        *ServerOptions            `json:"fallback,omitempty"`
-	FallbackForALPN map[string]*ServerOptions `json:"fallback_for_alpn,omitempty"`
-	Multiplex       *InboundMultiplexOptions  `json:"multiplex,omitempty"`
-	Transport       *V2RayTransportOptions    `json:"transport,omitempty"`
+	Fallback            *ServerOptions            `json:"fallback,omitempty"`
+	FallbackForALPN     map[string]*ServerOptions `json:"fallback_for_alpn,omitempty"`
+	FallbackForPath     map[string]*ServerOptions `json:"fallback_for_path,omitempty"`
+	Multiplex           *InboundMultiplexOptions  `json:"multiplex,omitempty"`
+	Transport           *V2RayTransportOptions    `json:"transport,omitempty"`
+	AcceptProxyProtocol bool                      `json:"accept_proxy_protocol,omitempty"`
 }
 
 type TrojanUser struct {
 	Name     string `json:"name"`
 	Password string `json:"password"`
+	Flow     string `json:"flow,omitempty"`
 }
 
 type TrojanOutboundOptions struct {
 	DialerOptions
 	ServerOptions
 	Password string      `json:"password"`
+	Flow     string      `json:"flow,omitempty"`
 	Network  NetworkList `json:"network,omitempty"`
 	OutboundTLSOptionsContainer
-	Multiplex *OutboundMultiplexOptions `json:"multiplex,omitempty"`
-	Transport *V2RayTransportOptions    `json:"transport,omitempty"`
+	Multiplex  *OutboundMultiplexOptions `json:"multiplex,omitempty"`
+	Transport  *V2RayTransportOptions    `json:"transport,omitempty"`
+	PreConnect *PreConnectOptions        `json:"pre_connect,omitempty"`
 }
@@ -1,9 +1,11 @@
 package option
 
 import (
+	"context"
 	"net/netip"
 	"strconv"
 
+	"github.com/sagernet/sing-box/common/jsonschema"
 	E "github.com/sagernet/sing/common/exceptions"
 	F "github.com/sagernet/sing/common/format"
 	"github.com/sagernet/sing/common/json"
@@ -20,6 +22,7 @@ type TunInboundOptions struct {
 	AutoRedirect           bool                             `json:"auto_redirect,omitempty"`
 	AutoRedirectInputMark  FwMark                           `json:"auto_redirect_input_mark,omitempty"`
 	AutoRedirectOutputMark FwMark                           `json:"auto_redirect_output_mark,omitempty"`
+	AutoRedirectTableName  string                           `json:"auto_redirect_table_name,omitempty"`
 	StrictRoute            bool                             `json:"strict_route,omitempty"`
 	RouteAddress           badoption.Listable[netip.Prefix] `json:"route_address,omitempty"`
 	RouteAddressSet        badoption.Listable[string]       `json:"route_address_set,omitempty"`
@@ -35,8 +38,11 @@ type TunInboundOptions struct {
 	IncludePackage         badoption.Listable[string]       `json:"include_package,omitempty"`
 	ExcludePackage         badoption.Listable[string]       `json:"exclude_package,omitempty"`
 	UDPTimeout             UDPTimeoutCompat                 `json:"udp_timeout,omitempty"`
+	UDPNATStrategy         UDPNATStrategy                   `json:"udp_nat_strategy,omitempty"`
 	Stack                  string                           `json:"stack,omitempty"`
 	Platform               *TunPlatformOptions              `json:"platform,omitempty"`
+	TAP                    *TunTAPOptions                   `json:"tap,omitempty"`
+	EncryptedDNSHijack     *TunEncryptedDNSHijackOptions    `json:"encrypted_dns_hijack,omitempty"`
 	InboundOptions
 
 	// Deprecated: removed
@@ -57,6 +63,20 @@ type TunInboundOptions struct {
 	EndpointIndependentNat bool `json:"endpoint_independent_nat,omitempty"`
 }
 
+type TunTAPOptions struct {
+	Enabled    bool   `json:"enabled,omitempty"`
+	MACAddress string `json:"mac_address,omitempty"`
+	Bridge     string `json:"bridge,omitempty"`
+}
+
+type TunEncryptedDNSHijackOptions struct {
+	Enabled        bool                             `json:"enabled,omitempty"`
+	Domain         badoption.Listable[string]       `json:"domain,omitempty"`
+	Address        badoption.Listable[netip.Prefix] `json:"address,omitempty"`
+	RuleSet        badoption.Listable[string]       `json:"rule_set,omitempty"`
+	DisableDefault bool                             `json:"disable_default,omitempty"`
+}
+
 type FwMark uint32
 
 func (f FwMark) MarshalJSON() ([]byte, error) {
@@ -79,3 +99,7 @@ func (f *FwMark) UnmarshalJSON(bytes []byte) error {
 	*f = FwMark(intValue)
 	return nil
 }
+
+func (f *FwMark) JSONSchema(ctx context.Context) any {
+	return jsonschema.AnyOf{uint32(0), ""}
+}
@@ -1,8 +1,10 @@
 package option
 
 import (
+	"context"
 	"strings"
 
+	"github.com/sagernet/sing-box/common/jsonschema"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-dns"
 	E "github.com/sagernet/sing/common/exceptions"
@@ -38,6 +40,11 @@ func (v *NetworkList) UnmarshalJSON(content []byte) error {
 	return nil
 }
 
+func (v *NetworkList) JSONSchema(ctx context.Context) any {
+	network := jsonschema.Schema{"type": "string", "enum": []string{N.NetworkTCP, N.NetworkUDP}}
+	return jsonschema.AnyOf{network, jsonschema.Schema{"type": "array", "items": network}}
+}
+
 func (v NetworkList) Build() []string {
 	if v == "" {
 		return []string{N.NetworkTCP, N.NetworkUDP}
@@ -107,6 +114,10 @@ func (s *DomainStrategy) UnmarshalJSON(bytes []byte) error {
 	return nil
 }
 
+func (s *DomainStrategy) JSONSchema(ctx context.Context) any {
+	return jsonschema.Enum{"", "as_is", "prefer_ipv4", "prefer_ipv6", "ipv4_only", "ipv6_only"}
+}
+
 type DNSQueryType uint16
 
 func (t DNSQueryType) String() string {
@@ -144,6 +155,10 @@ func (t *DNSQueryType) UnmarshalJSON(bytes []byte) error {
 	return E.New("unknown DNS query type: ", string(bytes))
 }
 
+func (t *DNSQueryType) JSONSchema(ctx context.Context) any {
+	return jsonschema.AnyOf{uint16(0), ""}
+}
+
 func DNSQueryTypeToString(queryType uint16) string {
 	typeName, loaded := mDNS.TypeToString[queryType]
 	if loaded {
@@ -172,6 +187,34 @@ func (n *NetworkStrategy) UnmarshalJSON(content []byte) error {
 	return nil
 }
 
+func (n *NetworkStrategy) JSONSchema(ctx context.Context) any {
+	return jsonschema.EnumOf(C.StringToNetworkStrategy)
+}
+
+type UDPNATStrategy C.UDPNATStrategy
+
+func (s UDPNATStrategy) MarshalJSON() ([]byte, error) {
+	return json.Marshal(C.UDPNATStrategy(s).String())
+}
+
+func (s *UDPNATStrategy) UnmarshalJSON(content []byte) error {
+	var value string
+	err := json.Unmarshal(content, &value)
+	if err != nil {
+		return err
+	}
+	strategy, loaded := C.StringToUDPNATStrategy[value]
+	if !loaded {
+		return E.New("unknown UDP NAT strategy: ", value)
+	}
+	*s = UDPNATStrategy(strategy)
+	return nil
+}
+
+func (s *UDPNATStrategy) JSONSchema(ctx context.Context) any {
+	return jsonschema.EnumOf(C.StringToUDPNATStrategy)
+}
+
 type InterfaceType C.InterfaceType
 
 func (t InterfaceType) Build() C.InterfaceType {
@@ -195,3 +238,7 @@ func (t *InterfaceType) UnmarshalJSON(content []byte) error {
 	*t = InterfaceType(interfaceType)
 	return nil
 }
+
+func (t *InterfaceType) JSONSchema(ctx context.Context) any {
+	return jsonschema.EnumOf(C.StringToInterfaceType)
+}
@@ -1,6 +1,9 @@
 package option
 
 import (
+	"context"
+
+	"github.com/sagernet/sing-box/common/jsonschema"
 	"github.com/sagernet/sing/common/json"
 	"github.com/sagernet/sing/common/uot"
 )
@@ -28,3 +31,7 @@ func (o *UDPOverTCPOptions) UnmarshalJSON(bytes []byte) error {
 	}
 	return json.UnmarshalDisallowUnknownFields(bytes, (*_UDPOverTCPOptions)(o))
 }
+
+func (o *UDPOverTCPOptions) JSONSchema(ctx context.Context) any {
+	return jsonschema.AnyOf{false, _UDPOverTCPOptions{}}
+}
@@ -1,6 +1,9 @@
 package option
 
 import (
+	"context"
+
+	"github.com/sagernet/sing-box/common/jsonschema"
 	C "github.com/sagernet/sing-box/constant"
 	E "github.com/sagernet/sing/common/exceptions"
 	"github.com/sagernet/sing/common/json"
@@ -67,6 +70,19 @@ func (o *V2RayTransportOptions) UnmarshalJSON(bytes []byte) error {
 	return nil
 }
 
+func (o *V2RayTransportOptions) JSONSchema(ctx context.Context) any {
+	return jsonschema.Union{
+		Field: "type",
+		Variants: map[string]any{
+			C.V2RayTransportTypeHTTP:        V2RayHTTPOptions{},
+			C.V2RayTransportTypeWebsocket:   V2RayWebsocketOptions{},
+			C.V2RayTransportTypeQUIC:        V2RayQUICOptions{},
+			C.V2RayTransportTypeGRPC:        V2RayGRPCOptions{},
+			C.V2RayTransportTypeHTTPUpgrade: V2RayHTTPUpgradeOptions{},
+		},
+	}
+}
+
 type V2RayHTTPOptions struct {
 	Host        badoption.Listable[string] `json:"host,omitempty"`
 	Path        string                     `json:"path,omitempty"`
@@ -81,20 +97,36 @@ type V2RayWebsocketOptions struct {
 	Headers             badoption.HTTPHeader `json:"headers,omitempty"`
 	MaxEarlyData        uint32               `json:"max_early_data,omitempty"`
 	EarlyDataHeaderName string               `json:"early_data_header_name,omitempty"`
+	PathDetour          map[string]string    `json:"path_detour,omitempty"`
+	Fallback            *ServerOptions       `json:"fallback,omitempty"`
+	Compression         bool                 `json:"compression,omitempty"`
+	MaxFrameSize        uint32               `json:"max_frame_size,omitempty"`
 }
 
-type V2RayQUICOptions struct{}
+type V2RayQUICOptions struct {
+	QUICPathMTUOptions
+}
+
+type QUICPathMTUOptions struct {
+	InitialPacketSize       uint16 `json:"initial_packet_size,omitempty"`
+	DisablePathMTUDiscovery bool   `json:"disable_path_mtu_discovery,omitempty"`
+}
 
 type V2RayGRPCOptions struct {
-	ServiceName         string             `json:"service_name,omitempty"`
-	IdleTimeout         badoption.Duration `json:"idle_timeout,omitempty"`
-	PingTimeout         badoption.Duration `json:"ping_timeout,omitempty"`
-	PermitWithoutStream bool               `json:"permit_without_stream,omitempty"`
-	ForceLite           bool               `json:"-"` // for test
+	ServiceName                 string             `json:"service_name,omitempty"`
+	IdleTimeout                 badoption.Duration `json:"idle_timeout,omitempty"`
+	PingTimeout                 badoption.Duration `json:"ping_timeout,omitempty"`
+	PermitWithoutStream         bool               `json:"permit_without_stream,omitempty"`
+	MultiMode                   bool               `json:"multi_mode,omitempty"`
+	InitialWindowSize           int32              `json:"initial_window_size,omitempty"`
+	InitialConnectionWindowSize int32              `json:"initial_connection_window_size,omitempty"`
+	ForceLite                   bool               `json:"-"` // for test
 }
 
 type V2RayHTTPUpgradeOptions struct {
-	Host    string               `json:"host,omitempty"`
-	Path    string               `json:"path,omitempty"`
-	Headers badoption.HTTPHeader `json:"headers,omitempty"`
+	Host       string               `json:"host,omitempty"`
+	Path       string               `json:"path,omitempty"`
+	Headers    badoption.HTTPHeader `json:"headers,omitempty"`
+	PathDetour map[string]string    `json:"path_detour,omitempty"`
+	Fallback   *ServerOptions       `json:"fallback,omitempty"`
 }
@@ -2,10 +2,12 @@ package option
 
 type VLESSInboundOptions struct {
 	ListenOptions
-	Users []VLESSUser `json:"users,omitempty"`
+	Users     []VLESSUser `json:"users,omitempty"`
+	UsersPath string      `json:"users_path,omitempty"`
 	InboundTLSOptionsContainer
-	Multiplex *InboundMultiplexOptions `json:"multiplex,omitempty"`
-	Transport *V2RayTransportOptions   `json:"transport,omitempty"`
+	Multiplex           *InboundMultiplexOptions `json:"multiplex,omitempty"`
+	Transport           *V2RayTransportOptions   `json:"transport,omitempty"`
+	AcceptProxyProtocol bool                     `json:"accept_proxy_protocol,omitempty"`
 }
 
 type VLESSUser struct {
@@ -21,7 +23,9 @@ type VLESSOutboundOptions struct {
 	Flow    string      `json:"flow,omitempty"`
 	Network NetworkList `json:"network,omitempty"`
 	OutboundTLSOptionsContainer
-	Multiplex      *OutboundMultiplexOptions `json:"multiplex,omitempty"`
-	Transport      *V2RayTransportOptions    `json:"transport,omitempty"`
-	PacketEncoding *string                   `json:"packet_encoding,omitempty"`
+	Multiplex          *OutboundMultiplexOptions `json:"multiplex,omitempty"`
+	Transport          *V2RayTransportOptions    `json:"transport,omitempty"`
+	PacketEncoding     *string                   `json:"packet_encoding,omitempty"`
+	PacketFragmentSize uint16                    `json:"packet_fragment_size,omitempty"`
+	PreConnect         *PreConnectOptions        `json:"pre_connect,omitempty"`
 }
@@ -1,11 +1,18 @@
 package option
 
+import "github.com/sagernet/sing/common/json/badoption"
+
 type VMessInboundOptions struct {
 	ListenOptions
-	Users []VMessUser `json:"users,omitempty"`
+	Users     []VMessUser `json:"users,omitempty"`
+	UsersPath string      `json:"users_path,omitempty"`
 	InboundTLSOptionsContainer
-	Multiplex *InboundMultiplexOptions `json:"multiplex,omitempty"`
-	Transport *V2RayTransportOptions   `json:"transport,omitempty"`
+	Multiplex           *InboundMultiplexOptions `json:"multiplex,omitempty"`
+	Transport           *V2RayTransportOptions   `json:"transport,omitempty"`
+	LegacyDecoy         bool                     `json:"legacy_decoy,omitempty"`
+	AuthFailureLimit    int                      `json:"auth_failure_limit,omitempty"`
+	AuthFailureWindow   badoption.Duration       `json:"auth_failure_window,omitempty"`
+	AcceptProxyProtocol bool                     `json:"accept_proxy_protocol,omitempty"`
 }
 
 type VMessUser struct {
@@ -24,7 +31,9 @@ type VMessOutboundOptions struct {
 	AuthenticatedLength bool        `json:"authenticated_length,omitempty"`
 	Network             NetworkList `json:"network,omitempty"`
 	OutboundTLSOptionsContainer
-	PacketEncoding string                    `json:"packet_encoding,omitempty"`
-	Multiplex      *OutboundMultiplexOptions `json:"multiplex,omitempty"`
-	Transport      *V2RayTransportOptions    `json:"transport,omitempty"`
+	PacketEncoding     string                    `json:"packet_encoding,omitempty"`
+	PacketFragmentSize uint16                    `json:"packet_fragment_size,omitempty"`
+	Multiplex          *OutboundMultiplexOptions `json:"multiplex,omitempty"`
+	Transport          *V2RayTransportOptions    `json:"transport,omitempty"`
+	PreConnect         *PreConnectOptions        `json:"pre_connect,omitempty"`
 }
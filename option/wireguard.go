@@ -7,18 +7,40 @@ import (
 )
 
 type WireGuardEndpointOptions struct {
-	System     bool                             `json:"system,omitempty"`
-	Name       string                           `json:"name,omitempty"`
-	MTU        uint32                           `json:"mtu,omitempty"`
-	Address    badoption.Listable[netip.Prefix] `json:"address"`
-	PrivateKey string                           `json:"private_key"`
-	ListenPort uint16                           `json:"listen_port,omitempty"`
-	Peers      []WireGuardPeer                  `json:"peers,omitempty"`
-	UDPTimeout badoption.Duration               `json:"udp_timeout,omitempty"`
-	Workers    int                              `json:"workers,omitempty"`
+	System      bool                             `json:"system,omitempty"`
+	Kernel      bool                             `json:"kernel,omitempty"`
+	Name        string                           `json:"name,omitempty"`
+	MTU         uint32                           `json:"mtu,omitempty"`
+	Address     badoption.Listable[netip.Prefix] `json:"address"`
+	PrivateKey  string                           `json:"private_key"`
+	ListenPort  uint16                           `json:"listen_port,omitempty"`
+	Peers       []WireGuardPeer                  `json:"peers,omitempty"`
+	UDPTimeout  badoption.Duration               `json:"udp_timeout,omitempty"`
+	Workers     int                              `json:"workers,omitempty"`
+	Obfuscation *WireGuardObfuscationOptions     `json:"obfuscation,omitempty"`
+	Amnezia     *WireGuardAmneziaOptions         `json:"amnezia,omitempty"`
+	LazyStart   bool                             `json:"lazy_start,omitempty"`
+	IdleTimeout badoption.Duration               `json:"idle_timeout,omitempty"`
 	DialerOptions
 }
 
+type WireGuardObfuscationOptions struct {
+	Enabled  bool   `json:"enabled,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+type WireGuardAmneziaOptions struct {
+	JunkPacketCount            int    `json:"jc,omitempty"`
+	JunkPacketMinSize          int    `json:"jmin,omitempty"`
+	JunkPacketMaxSize          int    `json:"jmax,omitempty"`
+	InitPacketJunkSize         int    `json:"s1,omitempty"`
+	ResponsePacketJunkSize     int    `json:"s2,omitempty"`
+	InitPacketMagicHeader      uint32 `json:"h1,omitempty"`
+	ResponsePacketMagicHeader  uint32 `json:"h2,omitempty"`
+	UnderloadPacketMagicHeader uint32 `json:"h3,omitempty"`
+	TransportPacketMagicHeader uint32 `json:"h4,omitempty"`
+}
+
 type WireGuardPeer struct {
 	Address                     string                           `json:"address,omitempty"`
 	Port                        uint16                           `json:"port,omitempty"`
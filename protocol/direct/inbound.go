@@ -54,7 +54,7 @@ func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLo
 	if options.UDPTimeout != 0 {
 		udpTimeout = time.Duration(options.UDPTimeout)
 	} else {
-		udpTimeout = C.UDPTimeout
+		udpTimeout = adapter.TimeoutsFromContext(ctx).UDPNAT
 	}
 	inbound.udpNat = udpnat.New(inbound, inbound.preparePacketConnection, udpTimeout, false)
 	inbound.listener = listener.New(listener.Options{
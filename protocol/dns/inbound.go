@@ -0,0 +1,287 @@
+package dns
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/adapter/inbound"
+	"github.com/sagernet/sing-box/common/listener"
+	"github.com/sagernet/sing-box/common/tls"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/buf"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/logger"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+	aTLS "github.com/sagernet/sing/common/tls"
+
+	mDNS "github.com/miekg/dns"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// ListenQUICFunc serves DNS over QUIC, each stream is passed to handler with
+// the address of the client.
+var ListenQUICFunc func(listener *listener.Listener, tlsConfig tls.ServerConfig, logger logger.Logger, handler func(conn net.Conn, source M.Socksaddr)) (io.Closer, error)
+
+func RegisterInbound(registry *inbound.Registry) {
+	inbound.Register[option.DNSInboundOptions](registry, C.TypeDNS, NewInbound)
+}
+
+var (
+	_ adapter.TCPInjectableInbound = (*Inbound)(nil)
+	_ adapter.PacketHandlerEx      = (*Inbound)(nil)
+)
+
+type Inbound struct {
+	inbound.Adapter
+	ctx          context.Context
+	router       adapter.Router
+	logger       logger.ContextLogger
+	listener     *listener.Listener
+	protocol     string
+	path         string
+	tlsConfig    tls.ServerConfig
+	httpServer   *http.Server
+	quicListener io.Closer
+}
+
+func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.DNSInboundOptions) (adapter.Inbound, error) {
+	inbound := &Inbound{
+		Adapter:  inbound.NewAdapter(C.TypeDNS, tag),
+		ctx:      ctx,
+		router:   router,
+		logger:   logger,
+		protocol: options.Protocol,
+		path:     options.Path,
+	}
+	tlsEnabled := options.TLS != nil && options.TLS.Enabled
+	var network []string
+	switch options.Protocol {
+	case "":
+		if tlsEnabled {
+			return nil, E.New("TLS is only supported with protocol tls, https or quic")
+		}
+		network = options.Network.Build()
+	case C.DNSInboundProtocolTLS:
+		network = []string{N.NetworkTCP}
+	case C.DNSInboundProtocolHTTPS:
+		if inbound.path == "" {
+			inbound.path = "/dns-query"
+		}
+	case C.DNSInboundProtocolQUIC:
+		if ListenQUICFunc == nil {
+			return nil, C.ErrQUICNotIncluded
+		}
+	default:
+		return nil, E.New("unknown protocol: ", options.Protocol)
+	}
+	switch options.Protocol {
+	case C.DNSInboundProtocolTLS, C.DNSInboundProtocolQUIC:
+		if !tlsEnabled {
+			return nil, E.New("TLS is required for protocol ", options.Protocol)
+		}
+	}
+	if tlsEnabled {
+		tlsConfig, err := tls.NewServer(ctx, logger, common.PtrValueOrDefault(options.TLS))
+		if err != nil {
+			return nil, err
+		}
+		inbound.tlsConfig = tlsConfig
+	}
+	inbound.listener = listener.New(listener.Options{
+		Context:                  ctx,
+		Logger:                   logger,
+		Network:                  network,
+		Listen:                   options.ListenOptions,
+		ConnectionHandler:        inbound,
+		PacketHandler:            inbound,
+		ThreadUnsafePacketWriter: true,
+	})
+	return inbound, nil
+}
+
+func (h *Inbound) Start(stage adapter.StartStage) error {
+	if stage != adapter.StartStateStart {
+		return nil
+	}
+	if h.tlsConfig != nil {
+		switch h.protocol {
+		case C.DNSInboundProtocolTLS:
+			if len(h.tlsConfig.NextProtos()) == 0 {
+				h.tlsConfig.SetNextProtos([]string{"dot"})
+			}
+		case C.DNSInboundProtocolHTTPS:
+			if len(h.tlsConfig.NextProtos()) == 0 {
+				h.tlsConfig.SetNextProtos([]string{http2.NextProtoTLS, "http/1.1"})
+			}
+		case C.DNSInboundProtocolQUIC:
+			h.tlsConfig.SetNextProtos([]string{"doq"})
+		}
+		err := h.tlsConfig.Start()
+		if err != nil {
+			return E.Cause(err, "create TLS config")
+		}
+	}
+	switch h.protocol {
+	case C.DNSInboundProtocolHTTPS:
+		tcpListener, err := h.listener.ListenTCP()
+		if err != nil {
+			return err
+		}
+		if h.tlsConfig != nil {
+			tcpListener = aTLS.NewListener(tcpListener, h.tlsConfig)
+		}
+		h.httpServer = &http.Server{
+			// TLS connections negotiated h2 are served by the h2c handler, as
+			// they are not *tls.Conn
+			Handler:           h2c.NewHandler(h, new(http2.Server)),
+			ReadHeaderTimeout: C.TCPTimeout,
+			BaseContext: func(net.Listener) context.Context {
+				return h.ctx
+			},
+			ConnContext: func(ctx context.Context, c net.Conn) context.Context {
+				return log.ContextWithNewID(ctx)
+			},
+		}
+		go func() {
+			sErr := h.httpServer.Serve(tcpListener)
+			if sErr != nil && !E.IsClosedOrCanceled(sErr) && sErr != http.ErrServerClosed {
+				h.logger.Error("http server serve error: ", sErr)
+			}
+		}()
+		return nil
+	case C.DNSInboundProtocolQUIC:
+		quicListener, err := ListenQUICFunc(h.listener, h.tlsConfig, h.logger, h.newQUICStream)
+		if err != nil {
+			return err
+		}
+		h.quicListener = quicListener
+		return nil
+	default:
+		return h.listener.Start()
+	}
+}
+
+func (h *Inbound) Close() error {
+	return common.Close(
+		h.listener,
+		common.PtrOrNil(h.httpServer),
+		h.quicListener,
+		h.tlsConfig,
+	)
+}
+
+func (h *Inbound) NewConnectionEx(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
+	metadata.Inbound = h.Tag()
+	metadata.InboundType = h.Type()
+	if h.tlsConfig != nil {
+		tlsConn, err := tls.ServerHandshake(ctx, conn, h.tlsConfig)
+		if err != nil {
+			N.CloseOnHandshakeFailure(conn, onClose, err)
+			h.logger.ErrorContext(ctx, E.Cause(err, "process connection from ", metadata.Source, ": TLS handshake"))
+			return
+		}
+		conn = tlsConn
+	}
+	for {
+		conn.SetReadDeadline(time.Now().Add(C.DNSTimeout))
+		err := HandleStreamDNSRequest(ctx, h.router, conn, metadata)
+		if err != nil {
+			conn.Close()
+			if onClose != nil {
+				onClose(err)
+			}
+			return
+		}
+	}
+}
+
+func (h *Inbound) NewPacketEx(buffer *buf.Buffer, source M.Socksaddr) {
+	go func() {
+		defer buffer.Release()
+		ctx := log.ContextWithNewID(h.ctx)
+		var message mDNS.Msg
+		err := message.Unpack(buffer.Bytes())
+		if err != nil {
+			h.logger.DebugContext(ctx, E.Cause(err, "parse query from ", source))
+			return
+		}
+		response, err := h.exchange(ctx, source, &message)
+		if err != nil {
+			h.logger.ErrorContext(ctx, E.Cause(err, "process query from ", source))
+			return
+		}
+		responseBuffer := buf.NewPacket()
+		rawResponse, err := response.PackBuffer(responseBuffer.FreeBytes())
+		if err != nil {
+			responseBuffer.Release()
+			h.logger.ErrorContext(ctx, E.Cause(err, "pack response for ", source))
+			return
+		}
+		responseBuffer.Truncate(len(rawResponse))
+		err = h.listener.PacketWriter().WritePacket(responseBuffer, source)
+		if err != nil {
+			h.logger.DebugContext(ctx, E.Cause(err, "write response to ", source))
+		}
+	}()
+}
+
+// newQUICStream serves a single query on a DNS over QUIC stream, as defined
+// in RFC 9250.
+func (h *Inbound) newQUICStream(conn net.Conn, source M.Socksaddr) {
+	defer conn.Close()
+	ctx := log.ContextWithNewID(h.ctx)
+	var queryLength uint16
+	err := binary.Read(conn, binary.BigEndian, &queryLength)
+	if err != nil {
+		h.logger.DebugContext(ctx, E.Cause(err, "read query from ", source))
+		return
+	}
+	query := make([]byte, queryLength)
+	_, err = io.ReadFull(conn, query)
+	if err != nil {
+		h.logger.DebugContext(ctx, E.Cause(err, "read query from ", source))
+		return
+	}
+	var message mDNS.Msg
+	err = message.Unpack(query)
+	if err != nil {
+		h.logger.DebugContext(ctx, E.Cause(err, "parse query from ", source))
+		return
+	}
+	response, err := h.exchange(ctx, source, &message)
+	if err != nil {
+		h.logger.ErrorContext(ctx, E.Cause(err, "process query from ", source))
+		return
+	}
+	rawResponse, err := response.Pack()
+	if err != nil {
+		h.logger.ErrorContext(ctx, E.Cause(err, "pack response for ", source))
+		return
+	}
+	responseBuffer := buf.NewSize(2 + len(rawResponse))
+	defer responseBuffer.Release()
+	common.Must(binary.Write(responseBuffer, binary.BigEndian, uint16(len(rawResponse))))
+	common.Must1(responseBuffer.Write(rawResponse))
+	_, err = conn.Write(responseBuffer.Bytes())
+	if err != nil {
+		h.logger.DebugContext(ctx, E.Cause(err, "write response to ", source))
+	}
+}
+
+func (h *Inbound) exchange(ctx context.Context, source M.Socksaddr, message *mDNS.Msg) (*mDNS.Msg, error) {
+	var metadata adapter.InboundContext
+	metadata.Inbound = h.Tag()
+	metadata.InboundType = h.Type()
+	metadata.Source = source
+	return h.router.Exchange(adapter.WithContext(ctx, &metadata), message)
+}
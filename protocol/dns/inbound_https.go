@@ -0,0 +1,70 @@
+package dns
+
+import (
+	"encoding/base64"
+	"io"
+	"net/http"
+	"strconv"
+
+	E "github.com/sagernet/sing/common/exceptions"
+	sHttp "github.com/sagernet/sing/protocol/http"
+
+	mDNS "github.com/miekg/dns"
+)
+
+const dnsMessageMimeType = "application/dns-message"
+
+// ServeHTTP serves DNS over HTTPS queries, as defined in RFC 8484.
+func (h *Inbound) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	ctx := request.Context()
+	source := sHttp.SourceAddress(request)
+	if request.URL.Path != h.path {
+		writer.WriteHeader(http.StatusNotFound)
+		return
+	}
+	var (
+		query []byte
+		err   error
+	)
+	switch request.Method {
+	case http.MethodGet:
+		query, err = base64.RawURLEncoding.DecodeString(request.URL.Query().Get("dns"))
+	case http.MethodPost:
+		if request.Header.Get("Content-Type") != dnsMessageMimeType {
+			writer.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		query, err = io.ReadAll(io.LimitReader(request.Body, mDNS.MaxMsgSize))
+	default:
+		writer.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if err != nil {
+		h.logger.DebugContext(ctx, E.Cause(err, "read query from ", source))
+		writer.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var message mDNS.Msg
+	err = message.Unpack(query)
+	if err != nil {
+		h.logger.DebugContext(ctx, E.Cause(err, "parse query from ", source))
+		writer.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	response, err := h.exchange(ctx, source, &message)
+	if err != nil {
+		h.logger.ErrorContext(ctx, E.Cause(err, "process query from ", source))
+		writer.WriteHeader(http.StatusBadGateway)
+		return
+	}
+	rawResponse, err := response.Pack()
+	if err != nil {
+		h.logger.ErrorContext(ctx, E.Cause(err, "pack response for ", source))
+		writer.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	writer.Header().Set("Content-Type", dnsMessageMimeType)
+	writer.Header().Set("Content-Length", strconv.Itoa(len(rawResponse)))
+	writer.WriteHeader(http.StatusOK)
+	writer.Write(rawResponse)
+}
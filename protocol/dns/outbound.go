@@ -11,26 +11,45 @@ import (
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/log"
 	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
 	"github.com/sagernet/sing/common/logger"
 	M "github.com/sagernet/sing/common/metadata"
 	N "github.com/sagernet/sing/common/network"
+
+	mDNS "github.com/miekg/dns"
 )
 
 func RegisterOutbound(registry *outbound.Registry) {
-	outbound.Register[option.StubOptions](registry, C.TypeDNS, NewOutbound)
+	outbound.Register[option.DNSOutboundOptions](registry, C.TypeDNS, NewOutbound)
 }
 
 type Outbound struct {
 	outbound.Adapter
-	router adapter.Router
-	logger logger.ContextLogger
+	router          adapter.Router
+	logger          logger.ContextLogger
+	queryTypeServer map[uint16]string
+	fakeIPPTR       bool
 }
 
-func NewOutbound(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.StubOptions) (adapter.Outbound, error) {
+func NewOutbound(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.DNSOutboundOptions) (adapter.Outbound, error) {
+	queryTypeServer := make(map[uint16]string)
+	for i, serverOptions := range options.QueryTypeServer {
+		if serverOptions.Server == "" {
+			return nil, E.New("query_type_server[", i, "]: missing server")
+		}
+		if len(serverOptions.QueryType) == 0 {
+			return nil, E.New("query_type_server[", i, "]: missing query_type")
+		}
+		for _, queryType := range serverOptions.QueryType {
+			queryTypeServer[uint16(queryType)] = serverOptions.Server
+		}
+	}
 	return &Outbound{
-		Adapter: outbound.NewAdapter(C.TypeDNS, tag, []string{N.NetworkTCP, N.NetworkUDP}, nil),
-		router:  router,
-		logger:  logger,
+		Adapter:         outbound.NewAdapter(C.TypeDNS, tag, []string{N.NetworkTCP, N.NetworkUDP}, nil),
+		router:          router,
+		logger:          logger,
+		queryTypeServer: queryTypeServer,
+		fakeIPPTR:       options.FakeIPPTR,
 	}, nil
 }
 
@@ -44,9 +63,10 @@ func (d *Outbound) ListenPacket(ctx context.Context, destination M.Socksaddr) (n
 
 func (d *Outbound) NewConnectionEx(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
 	metadata.Destination = M.Socksaddr{}
+	router := d.exchangeRouter()
 	for {
 		conn.SetReadDeadline(time.Now().Add(C.DNSTimeout))
-		err := HandleStreamDNSRequest(ctx, d.router, conn, metadata)
+		err := HandleStreamDNSRequest(ctx, router, conn, metadata)
 		if err != nil {
 			conn.Close()
 			if onClose != nil {
@@ -58,5 +78,75 @@ func (d *Outbound) NewConnectionEx(ctx context.Context, conn net.Conn, metadata
 }
 
 func (d *Outbound) NewPacketConnectionEx(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
-	NewDNSPacketConnection(ctx, d.router, conn, nil, metadata)
+	NewDNSPacketConnection(ctx, d.exchangeRouter(), conn, nil, metadata)
+}
+
+func (d *Outbound) exchangeRouter() adapter.Router {
+	if len(d.queryTypeServer) == 0 && !d.fakeIPPTR {
+		return d.router
+	}
+	return &outboundRouter{d.router, d}
+}
+
+func (d *Outbound) exchange(ctx context.Context, message *mDNS.Msg) (*mDNS.Msg, error) {
+	if len(message.Question) != 1 {
+		return d.router.Exchange(ctx, message)
+	}
+	question := message.Question[0]
+	if d.fakeIPPTR && question.Qtype == mDNS.TypePTR {
+		response, loaded := d.exchangeFakeIPPTR(ctx, message)
+		if loaded {
+			return response, nil
+		}
+	}
+	if server, loaded := d.queryTypeServer[question.Qtype]; loaded {
+		ctx = adapter.ContextWithDNSServer(ctx, server)
+	}
+	return d.router.Exchange(ctx, message)
+}
+
+func (d *Outbound) exchangeFakeIPPTR(ctx context.Context, message *mDNS.Msg) (*mDNS.Msg, bool) {
+	fakeIPStore := d.router.FakeIPStore()
+	if fakeIPStore == nil {
+		return nil, false
+	}
+	question := message.Question[0]
+	address, err := parsePTRName(question.Name)
+	if err != nil || !fakeIPStore.Contains(address) {
+		return nil, false
+	}
+	response := mDNS.Msg{
+		MsgHdr: mDNS.MsgHdr{
+			Id:       message.Id,
+			Response: true,
+			Rcode:    mDNS.RcodeSuccess,
+		},
+		Question: message.Question,
+	}
+	domain, loaded := fakeIPStore.Lookup(address)
+	if loaded {
+		response.Answer = []mDNS.RR{&mDNS.PTR{
+			Hdr: mDNS.RR_Header{
+				Name:   question.Name,
+				Rrtype: mDNS.TypePTR,
+				Class:  mDNS.ClassINET,
+				Ttl:    1,
+			},
+			Ptr: mDNS.Fqdn(domain),
+		}}
+		d.logger.DebugContext(ctx, "answered PTR ", address, " => ", domain, " from fakeip")
+	} else {
+		response.Rcode = mDNS.RcodeNameError
+	}
+	return &response, true
+}
+
+// outboundRouter routes exchanges of hijacked queries through the outbound.
+type outboundRouter struct {
+	adapter.Router
+	outbound *Outbound
+}
+
+func (r *outboundRouter) Exchange(ctx context.Context, message *mDNS.Msg) (*mDNS.Msg, error) {
+	return r.outbound.exchange(ctx, message)
 }
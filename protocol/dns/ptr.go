@@ -0,0 +1,51 @@
+package dns
+
+import (
+	"net/netip"
+	"strconv"
+	"strings"
+
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+const (
+	inet4ReverseSuffix = ".in-addr.arpa."
+	inet6ReverseSuffix = ".ip6.arpa."
+)
+
+func parsePTRName(name string) (netip.Addr, error) {
+	name = strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(name, inet4ReverseSuffix):
+		labels := strings.Split(strings.TrimSuffix(name, inet4ReverseSuffix), ".")
+		if len(labels) != 4 {
+			return netip.Addr{}, E.New("invalid PTR name: ", name)
+		}
+		var address [4]byte
+		for i, label := range labels {
+			octet, err := strconv.ParseUint(label, 10, 8)
+			if err != nil {
+				return netip.Addr{}, E.Cause(err, "invalid PTR name: ", name)
+			}
+			address[3-i] = byte(octet)
+		}
+		return netip.AddrFrom4(address), nil
+	case strings.HasSuffix(name, inet6ReverseSuffix):
+		labels := strings.Split(strings.TrimSuffix(name, inet6ReverseSuffix), ".")
+		if len(labels) != 32 {
+			return netip.Addr{}, E.New("invalid PTR name: ", name)
+		}
+		var address [16]byte
+		for i, label := range labels {
+			nibble, err := strconv.ParseUint(label, 16, 4)
+			if err != nil || len(label) != 1 {
+				return netip.Addr{}, E.New("invalid PTR name: ", name)
+			}
+			index := 31 - i
+			address[index/2] |= byte(nibble) << (4 * (1 - index%2))
+		}
+		return netip.AddrFrom16(address), nil
+	default:
+		return netip.Addr{}, E.New("not a PTR name: ", name)
+	}
+}
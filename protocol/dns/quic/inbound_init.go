@@ -0,0 +1,58 @@
+package quic
+
+import (
+	"context"
+	"io"
+	"net"
+
+	"github.com/sagernet/quic-go"
+	"github.com/sagernet/sing-box/common/listener"
+	"github.com/sagernet/sing-box/common/tls"
+	"github.com/sagernet/sing-box/protocol/dns"
+	"github.com/sagernet/sing-box/transport/v2rayquic"
+	"github.com/sagernet/sing-quic"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/logger"
+	M "github.com/sagernet/sing/common/metadata"
+)
+
+func init() {
+	dns.ListenQUICFunc = func(listener *listener.Listener, tlsConfig tls.ServerConfig, logger logger.Logger, handler func(conn net.Conn, source M.Socksaddr)) (io.Closer, error) {
+		udpConn, err := listener.ListenUDP()
+		if err != nil {
+			return nil, err
+		}
+		quicListener, err := qtls.Listen(udpConn, tlsConfig, &quic.Config{
+			MaxIncomingStreams: 1 << 60,
+		})
+		if err != nil {
+			udpConn.Close()
+			return nil, err
+		}
+		go func() {
+			for {
+				conn, aErr := quicListener.Accept(context.Background())
+				if aErr != nil {
+					udpConn.Close()
+					if !E.IsClosedOrCanceled(aErr) {
+						logger.Error("quic listener closed: ", aErr)
+					}
+					return
+				}
+				go acceptStreams(conn, handler)
+			}
+		}()
+		return quicListener, nil
+	}
+}
+
+func acceptStreams(conn quic.Connection, handler func(conn net.Conn, source M.Socksaddr)) {
+	source := M.SocksaddrFromNet(conn.RemoteAddr()).Unwrap()
+	for {
+		stream, err := conn.AcceptStream(context.Background())
+		if err != nil {
+			return
+		}
+		go handler(&v2rayquic.StreamWrapper{Conn: conn, Stream: stream}, source)
+	}
+}
@@ -0,0 +1,509 @@
+package group
+
+import (
+	"context"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/adapter/outbound"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/atomic"
+	"github.com/sagernet/sing/common/cache"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+	"github.com/sagernet/sing/service"
+)
+
+const (
+	FallbackTriggerDialError        = "dial_error"
+	FallbackTriggerHandshakeTimeout = "handshake_timeout"
+	FallbackTriggerReset            = "reset"
+
+	fallbackDefaultHandshakeTimeout = 5 * time.Second
+	fallbackDefaultResetBytes       = 8192
+	fallbackDefaultTTL              = 10 * time.Minute
+)
+
+func RegisterFallback(registry *outbound.Registry) {
+	outbound.Register[option.FallbackOutboundOptions](registry, C.TypeFallback, NewFallback)
+}
+
+var (
+	_ adapter.OutboundGroup             = (*Fallback)(nil)
+	_ adapter.ConnectionHandlerEx       = (*Fallback)(nil)
+	_ adapter.PacketConnectionHandlerEx = (*Fallback)(nil)
+)
+
+// Fallback tries its outbounds in order for each connection, and remembers
+// the outbound that worked for the destination.
+type Fallback struct {
+	outbound.Adapter
+	outbound         adapter.OutboundManager
+	connection       adapter.ConnectionManager
+	logger           log.ContextLogger
+	tags             []string
+	outbounds        []adapter.Outbound
+	dialError        bool
+	reset            bool
+	handshakeTimeout time.Duration
+	replayLimit      int
+	remembered       *cache.LruCache[string, int]
+	last             atomic.TypedValue[adapter.Outbound]
+}
+
+func NewFallback(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.FallbackOutboundOptions) (adapter.Outbound, error) {
+	if len(options.Outbounds) == 0 {
+		return nil, E.New("missing tags")
+	}
+	outbound := &Fallback{
+		Adapter:    outbound.NewAdapter(C.TypeFallback, tag, []string{N.NetworkTCP, N.NetworkUDP}, options.Outbounds),
+		outbound:   service.FromContext[adapter.OutboundManager](ctx),
+		connection: service.FromContext[adapter.ConnectionManager](ctx),
+		logger:     logger,
+		tags:       options.Outbounds,
+	}
+	triggers := options.Trigger
+	if len(triggers) == 0 {
+		triggers = []string{FallbackTriggerDialError}
+	}
+	for _, trigger := range triggers {
+		switch trigger {
+		case FallbackTriggerDialError:
+			outbound.dialError = true
+		case FallbackTriggerHandshakeTimeout:
+			outbound.handshakeTimeout = time.Duration(options.HandshakeTimeout)
+			if outbound.handshakeTimeout == 0 {
+				outbound.handshakeTimeout = fallbackDefaultHandshakeTimeout
+			}
+		case FallbackTriggerReset:
+			outbound.reset = true
+		default:
+			return nil, E.New("unknown fallback trigger: ", trigger)
+		}
+	}
+	outbound.replayLimit = int(options.ResetBytes)
+	if outbound.replayLimit == 0 {
+		outbound.replayLimit = fallbackDefaultResetBytes
+	}
+	ttl := time.Duration(options.TTL)
+	if ttl == 0 {
+		ttl = fallbackDefaultTTL
+	}
+	if ttl > 0 {
+		if ttl < time.Second {
+			ttl = time.Second
+		}
+		outbound.remembered = cache.New(
+			cache.WithAge[string, int](int64(ttl/time.Second)),
+			cache.WithSize[string, int](4096),
+		)
+	}
+	return outbound, nil
+}
+
+func (s *Fallback) Start() error {
+	for i, tag := range s.tags {
+		detour, loaded := s.outbound.Outbound(tag)
+		if !loaded {
+			return E.New("outbound ", i, " not found: ", tag)
+		}
+		s.outbounds = append(s.outbounds, detour)
+	}
+	return nil
+}
+
+func (s *Fallback) Now() string {
+	last := s.last.Load()
+	if last == nil {
+		return s.tags[0]
+	}
+	return last.Tag()
+}
+
+func (s *Fallback) All() []string {
+	return s.tags
+}
+
+// candidates returns the indexes of the outbounds to try for the destination,
+// starting with the remembered one.
+func (s *Fallback) candidates(network string, destination M.Socksaddr) []int {
+	first := -1
+	if s.remembered != nil {
+		if index, loaded := s.remembered.Load(destination.String()); loaded {
+			first = index
+		}
+	}
+	candidates := make([]int, 0, len(s.outbounds))
+	if first >= 0 && common.Contains(s.outbounds[first].Network(), network) {
+		candidates = append(candidates, first)
+	}
+	for index, detour := range s.outbounds {
+		if index != first && common.Contains(detour.Network(), network) {
+			candidates = append(candidates, index)
+		}
+	}
+	return candidates
+}
+
+func (s *Fallback) remember(destination M.Socksaddr, index int) {
+	s.last.Store(s.outbounds[index])
+	if s.remembered == nil {
+		return
+	}
+	if index == 0 {
+		s.remembered.Delete(destination.String())
+	} else {
+		s.remembered.Store(destination.String(), index)
+	}
+}
+
+func (s *Fallback) dial(ctx context.Context, network string, destination M.Socksaddr, candidates []int) (net.Conn, []int, error) {
+	var errors []error
+	for len(candidates) > 0 {
+		index := candidates[0]
+		candidates = candidates[1:]
+		detour := s.outbounds[index]
+		dialCtx := ctx
+		var cancel context.CancelFunc
+		if s.handshakeTimeout > 0 {
+			dialCtx, cancel = context.WithTimeout(ctx, s.handshakeTimeout)
+		}
+		conn, err := detour.DialContext(dialCtx, network, destination)
+		if cancel != nil {
+			cancel()
+		}
+		if err == nil {
+			return &fallbackMember{conn, index}, candidates, nil
+		}
+		if ctx.Err() != nil {
+			return nil, nil, err
+		}
+		errors = append(errors, E.Cause(err, detour.Tag()))
+		if !s.dialError && (s.handshakeTimeout == 0 || dialCtx.Err() == nil) {
+			break
+		}
+		if len(candidates) > 0 {
+			s.logger.DebugContext(ctx, "outbound/", detour.Type(), "[", detour.Tag(), "] failed, fall back: ", err)
+		}
+	}
+	return nil, nil, E.Errors(errors...)
+}
+
+func (s *Fallback) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	conn, candidates, err := s.dial(ctx, network, destination, s.candidates(network, destination))
+	if err != nil {
+		return nil, err
+	}
+	member := conn.(*fallbackMember)
+	if N.NetworkName(network) != N.NetworkTCP || (s.handshakeTimeout == 0 && !s.reset) {
+		s.remember(destination, member.index)
+		return member.Conn, nil
+	}
+	return newFallbackConn(ctx, s, destination, member, candidates), nil
+}
+
+func (s *Fallback) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
+	var errors []error
+	for _, index := range s.candidates(N.NetworkUDP, destination) {
+		detour := s.outbounds[index]
+		conn, err := detour.ListenPacket(ctx, destination)
+		if err == nil {
+			s.remember(destination, index)
+			return conn, nil
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		errors = append(errors, E.Cause(err, detour.Tag()))
+		if !s.dialError {
+			break
+		}
+	}
+	return nil, E.Errors(errors...)
+}
+
+func (s *Fallback) NewConnectionEx(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
+	s.connection.NewConnection(ctx, s, conn, metadata, onClose)
+}
+
+func (s *Fallback) NewPacketConnectionEx(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
+	s.connection.NewPacketConnection(ctx, s, conn, metadata, onClose)
+}
+
+type fallbackMember struct {
+	net.Conn
+	index int
+}
+
+// fallbackConn keeps the data written to a new connection until the first
+// response arrives, so that it can be replayed to the next outbound when the
+// connection times out or is reset before.
+type fallbackConn struct {
+	ctx         context.Context
+	outbound    *Fallback
+	destination M.Socksaddr
+	candidates  []int
+	access      sync.Mutex
+	member      *fallbackMember
+	probing     bool
+	timedOut    bool
+	timer       *time.Timer
+	replay      []byte
+	writeClosed bool
+	closed      bool
+	switching   chan struct{}
+	cancel      context.CancelFunc
+}
+
+func newFallbackConn(ctx context.Context, outbound *Fallback, destination M.Socksaddr, member *fallbackMember, candidates []int) *fallbackConn {
+	conn := &fallbackConn{
+		ctx:         ctx,
+		outbound:    outbound,
+		destination: destination,
+		candidates:  candidates,
+		member:      member,
+		probing:     true,
+	}
+	conn.startTimer()
+	return conn
+}
+
+func (c *fallbackConn) startTimer() {
+	if c.outbound.handshakeTimeout == 0 {
+		return
+	}
+	member := c.member
+	c.timer = time.AfterFunc(c.outbound.handshakeTimeout, func() {
+		c.access.Lock()
+		defer c.access.Unlock()
+		if c.probing && c.member == member {
+			c.timedOut = true
+			member.Close()
+		}
+	})
+}
+
+func (c *fallbackConn) Read(p []byte) (n int, err error) {
+	for {
+		c.access.Lock()
+		member := c.member
+		probing := c.probing
+		c.access.Unlock()
+		n, err = member.Read(p)
+		if !probing {
+			return
+		}
+		if n > 0 {
+			c.commit(member)
+			return
+		}
+		if err == nil {
+			continue
+		}
+		if !c.fallback(member, err) {
+			return
+		}
+	}
+}
+
+func (c *fallbackConn) commit(member *fallbackMember) {
+	c.access.Lock()
+	defer c.access.Unlock()
+	if !c.probing || c.member != member {
+		return
+	}
+	c.probing = false
+	c.replay = nil
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+	c.outbound.remember(c.destination, member.index)
+}
+
+// waitSwitch waits for an ongoing fallback to finish, it is called and
+// returns with c.access held.
+func (c *fallbackConn) waitSwitch() {
+	for c.switching != nil {
+		switching := c.switching
+		c.access.Unlock()
+		<-switching
+		c.access.Lock()
+	}
+}
+
+// fallback replaces the failed member with the next outbound, and reports
+// whether the operation should be retried. The next outbound is dialed
+// without holding c.access, so that Close can cancel it.
+func (c *fallbackConn) fallback(failed *fallbackMember, cause error) bool {
+	c.access.Lock()
+	c.waitSwitch()
+	if c.member != failed {
+		defer c.access.Unlock()
+		return !c.closed
+	}
+	if !c.probing || c.closed {
+		c.access.Unlock()
+		return false
+	}
+	if c.timedOut {
+		cause = os.ErrDeadlineExceeded
+	} else if !c.outbound.reset {
+		c.access.Unlock()
+		return false
+	}
+	failed.Close()
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+	if c.cancel != nil {
+		c.cancel()
+	}
+	ctx, cancel := context.WithCancel(c.ctx)
+	switching := make(chan struct{})
+	c.switching = switching
+	c.cancel = cancel
+	candidates, replay, writeClosed := c.candidates, c.replay, c.writeClosed
+	c.access.Unlock()
+	member, candidates := c.switchMember(ctx, failed, cause, candidates, replay, writeClosed)
+	c.access.Lock()
+	defer c.access.Unlock()
+	c.switching = nil
+	close(switching)
+	c.candidates = candidates
+	if member == nil {
+		return false
+	}
+	if c.closed {
+		member.Close()
+		return false
+	}
+	c.member = member
+	c.timedOut = false
+	c.startTimer()
+	return true
+}
+
+// switchMember dials the remaining candidates in order, and returns the first
+// one that accepted the replayed data.
+func (c *fallbackConn) switchMember(ctx context.Context, failed *fallbackMember, cause error, candidates []int, replay []byte, writeClosed bool) (*fallbackMember, []int) {
+	for len(candidates) > 0 {
+		c.outbound.logger.DebugContext(c.ctx, "outbound/", c.outbound.outbounds[failed.index].Type(), "[", c.outbound.outbounds[failed.index].Tag(), "] failed, fall back: ", cause)
+		conn, nextCandidates, err := c.outbound.dial(ctx, N.NetworkTCP, c.destination, candidates)
+		if err != nil {
+			return nil, nil
+		}
+		candidates = nextCandidates
+		member := conn.(*fallbackMember)
+		err = replayTo(member, replay, writeClosed)
+		if err == nil {
+			return member, candidates
+		}
+		member.Close()
+		failed, cause = member, err
+	}
+	return nil, nil
+}
+
+func replayTo(member *fallbackMember, replay []byte, writeClosed bool) error {
+	if len(replay) > 0 {
+		_, err := member.Write(replay)
+		if err != nil {
+			return err
+		}
+	}
+	if writeClosed {
+		return N.CloseWrite(member.Conn)
+	}
+	return nil
+}
+
+func (c *fallbackConn) Write(p []byte) (n int, err error) {
+	c.access.Lock()
+	c.waitSwitch()
+	if c.probing {
+		if len(c.replay)+len(p) <= c.outbound.replayLimit {
+			c.replay = append(c.replay, p...)
+		} else {
+			// too much data to replay, stick to the current outbound
+			c.probing = false
+			c.replay = nil
+			if c.timer != nil {
+				c.timer.Stop()
+			}
+		}
+	}
+	member := c.member
+	probing := c.probing
+	c.access.Unlock()
+	n, err = member.Write(p)
+	if err != nil && probing && c.fallback(member, err) {
+		// written to the new outbound with the replayed data
+		return len(p), nil
+	}
+	return
+}
+
+func (c *fallbackConn) CloseWrite() error {
+	c.access.Lock()
+	defer c.access.Unlock()
+	c.waitSwitch()
+	c.writeClosed = true
+	if writeCloser, isWriteCloser := common.Cast[N.WriteCloser](c.member.Conn); isWriteCloser {
+		return writeCloser.CloseWrite()
+	}
+	c.closed = true
+	return c.member.Close()
+}
+
+func (c *fallbackConn) Close() error {
+	c.access.Lock()
+	defer c.access.Unlock()
+	c.closed = true
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+	if c.cancel != nil {
+		c.cancel()
+	}
+	return c.member.Close()
+}
+
+func (c *fallbackConn) LocalAddr() net.Addr {
+	c.access.Lock()
+	defer c.access.Unlock()
+	return c.member.LocalAddr()
+}
+
+func (c *fallbackConn) RemoteAddr() net.Addr {
+	c.access.Lock()
+	defer c.access.Unlock()
+	return c.member.RemoteAddr()
+}
+
+func (c *fallbackConn) SetDeadline(t time.Time) error {
+	c.access.Lock()
+	defer c.access.Unlock()
+	c.waitSwitch()
+	return c.member.SetDeadline(t)
+}
+
+func (c *fallbackConn) SetReadDeadline(t time.Time) error {
+	c.access.Lock()
+	defer c.access.Unlock()
+	c.waitSwitch()
+	return c.member.SetReadDeadline(t)
+}
+
+func (c *fallbackConn) SetWriteDeadline(t time.Time) error {
+	c.access.Lock()
+	defer c.access.Unlock()
+	c.waitSwitch()
+	return c.member.SetWriteDeadline(t)
+}
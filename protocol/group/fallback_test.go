@@ -0,0 +1,141 @@
+package group
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/adapter/outbound"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testOutbound struct {
+	outbound.Adapter
+	dial func(ctx context.Context) (net.Conn, error)
+}
+
+func newTestOutbound(tag string, dial func(ctx context.Context) (net.Conn, error)) *testOutbound {
+	return &testOutbound{
+		Adapter: outbound.NewAdapter(C.TypeDirect, tag, []string{N.NetworkTCP}, nil),
+		dial:    dial,
+	}
+}
+
+func (o *testOutbound) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	return o.dial(ctx)
+}
+
+func (o *testOutbound) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
+	return nil, net.ErrClosed
+}
+
+// pipeOutbound returns an outbound whose server side is handled by serve.
+func pipeOutbound(tag string, serve func(conn net.Conn)) *testOutbound {
+	return newTestOutbound(tag, func(ctx context.Context) (net.Conn, error) {
+		client, server := net.Pipe()
+		go serve(server)
+		return client, nil
+	})
+}
+
+func newTestFallback(outbounds ...adapter.Outbound) *Fallback {
+	return &Fallback{
+		logger:      log.NewNOPFactory().Logger(),
+		outbounds:   outbounds,
+		dialError:   true,
+		reset:       true,
+		replayLimit: fallbackDefaultResetBytes,
+	}
+}
+
+func resetAfterRead(conn net.Conn) {
+	conn.Read(make([]byte, 64))
+	conn.Close()
+}
+
+func TestFallbackReplay(t *testing.T) {
+	t.Parallel()
+	received := make(chan string, 1)
+	fallback := newTestFallback(
+		pipeOutbound("a", resetAfterRead),
+		pipeOutbound("b", func(conn net.Conn) {
+			defer conn.Close()
+			buffer := make([]byte, 64)
+			n, _ := conn.Read(buffer)
+			received <- string(buffer[:n])
+			conn.Write([]byte("world"))
+		}),
+	)
+	conn, err := fallback.DialContext(context.Background(), N.NetworkTCP, M.ParseSocksaddr("example.com:443"))
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("hello"))
+	require.NoError(t, err)
+	buffer := make([]byte, 64)
+	n, err := conn.Read(buffer)
+	require.NoError(t, err)
+	require.Equal(t, "world", string(buffer[:n]))
+	require.Equal(t, "hello", <-received)
+	require.Equal(t, "b", fallback.Now())
+}
+
+func TestFallbackCloseCancelsDial(t *testing.T) {
+	t.Parallel()
+	dialStarted := make(chan struct{})
+	fallback := newTestFallback(
+		pipeOutbound("a", resetAfterRead),
+		newTestOutbound("b", func(ctx context.Context) (net.Conn, error) {
+			close(dialStarted)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}),
+	)
+	conn, err := fallback.DialContext(context.Background(), N.NetworkTCP, M.ParseSocksaddr("example.com:443"))
+	require.NoError(t, err)
+	_, err = conn.Write([]byte("hello"))
+	require.NoError(t, err)
+	readDone := make(chan error, 1)
+	go func() {
+		_, readErr := conn.Read(make([]byte, 64))
+		readDone <- readErr
+	}()
+	<-dialStarted
+	closeDone := make(chan error, 1)
+	go func() {
+		closeDone <- conn.Close()
+	}()
+	select {
+	case <-closeDone:
+	case <-time.After(time.Second):
+		t.Fatal("close blocked by the fallback dial")
+	}
+	select {
+	case err = <-readDone:
+		require.Error(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("read not interrupted by close")
+	}
+}
+
+func TestFallbackExhausted(t *testing.T) {
+	t.Parallel()
+	fallback := newTestFallback(
+		pipeOutbound("a", resetAfterRead),
+		pipeOutbound("b", resetAfterRead),
+	)
+	conn, err := fallback.DialContext(context.Background(), N.NetworkTCP, M.ParseSocksaddr("example.com:443"))
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("hello"))
+	require.NoError(t, err)
+	_, err = conn.Read(make([]byte, 64))
+	require.ErrorIs(t, err, io.EOF)
+}
@@ -0,0 +1,85 @@
+package group
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/logger"
+	"github.com/sagernet/sing/service"
+)
+
+func filterTags(tags []string, filter adapter.OutboundGroupFilter) ([]string, error) {
+	var include, exclude *regexp.Regexp
+	var err error
+	if filter.Include != "" {
+		include, err = regexp.Compile(filter.Include)
+		if err != nil {
+			return nil, E.Cause(err, "parse include")
+		}
+	}
+	if filter.Exclude != "" {
+		exclude, err = regexp.Compile(filter.Exclude)
+		if err != nil {
+			return nil, E.Cause(err, "parse exclude")
+		}
+	}
+	filteredTags := common.Filter(tags, func(tag string) bool {
+		return (include == nil || include.MatchString(tag)) && (exclude == nil || !exclude.MatchString(tag))
+	})
+	if len(filteredTags) == 0 {
+		return nil, E.New("no outbounds matched by filter")
+	}
+	return filteredTags, nil
+}
+
+// loadFilter returns the filter saved by the Clash API if present and valid, or
+// the configured filter. A saved filter is dropped once the configured filter
+// is changed, so that the configuration takes effect.
+func loadFilter(ctx context.Context, logger logger.Logger, group string, tags []string, filter adapter.OutboundGroupFilter) (adapter.OutboundGroupFilter, []string, error) {
+	if group != "" {
+		cacheFile := service.FromContext[adapter.CacheFile](ctx)
+		if cacheFile != nil {
+			saved := cacheFile.LoadGroupFilter(group)
+			if saved != nil {
+				if saved.Configured == filter {
+					filteredTags, err := filterTags(tags, saved.Filter)
+					if err == nil {
+						return saved.Filter, filteredTags, nil
+					}
+					logger.Warn("ignore saved filter: ", err)
+				} else {
+					logger.Info("configured filter changed, drop saved filter")
+					err := cacheFile.StoreGroupFilter(group, nil)
+					if err != nil {
+						logger.Error("drop saved filter: ", err)
+					}
+				}
+			}
+		}
+	}
+	filteredTags, err := filterTags(tags, filter)
+	if err != nil {
+		return adapter.OutboundGroupFilter{}, nil, err
+	}
+	return filter, filteredTags, nil
+}
+
+func storeFilter(ctx context.Context, logger logger.Logger, group string, filter adapter.OutboundGroupFilter, configured adapter.OutboundGroupFilter) {
+	if group == "" {
+		return
+	}
+	cacheFile := service.FromContext[adapter.CacheFile](ctx)
+	if cacheFile == nil {
+		return
+	}
+	err := cacheFile.StoreGroupFilter(group, &adapter.SavedGroupFilter{
+		Filter:     filter,
+		Configured: configured,
+	})
+	if err != nil {
+		logger.Error("store filter: ", err)
+	}
+}
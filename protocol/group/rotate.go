@@ -0,0 +1,250 @@
+package group
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/adapter/outbound"
+	"github.com/sagernet/sing-box/common/urltest"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common/bufio"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+	"github.com/sagernet/sing/service"
+)
+
+const (
+	RotateTriggerInterval    = "interval"
+	RotateTriggerConnections = "connections"
+	RotateTriggerBytes       = "bytes"
+)
+
+func RegisterRotate(registry *outbound.Registry) {
+	outbound.Register[option.RotateOutboundOptions](registry, C.TypeRotate, NewRotate)
+}
+
+var (
+	_ adapter.OutboundGroup             = (*Rotate)(nil)
+	_ adapter.ConnectionHandlerEx       = (*Rotate)(nil)
+	_ adapter.PacketConnectionHandlerEx = (*Rotate)(nil)
+)
+
+// Rotate uses one of its outbounds for new connections, and switches to the
+// next one after an interval, a number of connections or a number of bytes.
+type Rotate struct {
+	outbound.Adapter
+	ctx             context.Context
+	outbound        adapter.OutboundManager
+	connection      adapter.ConnectionManager
+	logger          log.ContextLogger
+	tags            []string
+	outbounds       []adapter.Outbound
+	interval        time.Duration
+	connectionLimit uint32
+	byteLimit       uint64
+	random          bool
+	history         *urltest.HistoryStorage
+	access          sync.Mutex
+	index           int
+	connections     uint32
+	bytes           atomic.Uint64
+	done            chan struct{}
+}
+
+func NewRotate(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.RotateOutboundOptions) (adapter.Outbound, error) {
+	if len(options.Outbounds) == 0 {
+		return nil, E.New("missing tags")
+	}
+	if options.Interval == 0 && options.Connections == 0 && options.Bytes == 0 {
+		return nil, E.New("missing rotation interval, connections or bytes")
+	}
+	if options.Interval < 0 {
+		return nil, E.New("invalid rotation interval")
+	}
+	return &Rotate{
+		Adapter:         outbound.NewAdapter(C.TypeRotate, tag, []string{N.NetworkTCP, N.NetworkUDP}, options.Outbounds),
+		ctx:             ctx,
+		outbound:        service.FromContext[adapter.OutboundManager](ctx),
+		connection:      service.FromContext[adapter.ConnectionManager](ctx),
+		logger:          logger,
+		tags:            options.Outbounds,
+		interval:        time.Duration(options.Interval),
+		connectionLimit: options.Connections,
+		byteLimit:       uint64(options.Bytes),
+		random:          options.Random,
+		done:            make(chan struct{}),
+	}, nil
+}
+
+func (s *Rotate) Start() error {
+	if s.history = service.PtrFromContext[urltest.HistoryStorage](s.ctx); s.history == nil {
+		if clashServer := service.FromContext[adapter.ClashServer](s.ctx); clashServer != nil {
+			s.history = clashServer.HistoryStorage()
+		}
+	}
+	for i, tag := range s.tags {
+		detour, loaded := s.outbound.Outbound(tag)
+		if !loaded {
+			return E.New("outbound ", i, " not found: ", tag)
+		}
+		s.outbounds = append(s.outbounds, detour)
+	}
+	if s.random {
+		s.index = rand.Intn(len(s.outbounds))
+	}
+	if s.interval > 0 {
+		go s.loopRotate()
+	}
+	return nil
+}
+
+func (s *Rotate) Close() error {
+	select {
+	case <-s.done:
+	default:
+		close(s.done)
+	}
+	return nil
+}
+
+func (s *Rotate) Now() string {
+	s.access.Lock()
+	defer s.access.Unlock()
+	return s.tags[s.index]
+}
+
+func (s *Rotate) All() []string {
+	return s.tags
+}
+
+func (s *Rotate) loopRotate() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			s.access.Lock()
+			s.rotate(RotateTriggerInterval)
+			s.access.Unlock()
+		}
+	}
+}
+
+// rotate switches to the next outbound and resets the counters, must be called with access locked.
+func (s *Rotate) rotate(trigger string) {
+	s.connections = 0
+	s.bytes.Store(0)
+	if len(s.outbounds) < 2 {
+		return
+	}
+	previous := s.index
+	if s.random {
+		s.index = rand.Intn(len(s.outbounds) - 1)
+		if s.index >= previous {
+			s.index++
+		}
+	} else {
+		s.index = (previous + 1) % len(s.outbounds)
+	}
+	s.logger.Info("rotated to ", s.tags[s.index], " by ", trigger)
+	s.history.RecordEvent(urltest.Event{
+		Type:     urltest.EventTypeRotate,
+		Group:    s.Tag(),
+		Outbound: s.tags[s.index],
+		Previous: s.tags[previous],
+		Reason:   trigger,
+	})
+}
+
+// selectOutbound returns the outbound for a new connection, and counts the connection.
+func (s *Rotate) selectOutbound() adapter.Outbound {
+	s.access.Lock()
+	defer s.access.Unlock()
+	detour := s.outbounds[s.index]
+	if s.connectionLimit > 0 {
+		s.connections++
+		if s.connections >= s.connectionLimit {
+			s.rotate(RotateTriggerConnections)
+		}
+	}
+	return detour
+}
+
+func (s *Rotate) countBytes(n int64) {
+	if s.bytes.Add(uint64(n)) < s.byteLimit {
+		return
+	}
+	s.access.Lock()
+	defer s.access.Unlock()
+	// checked again, since other connections may have rotated
+	if s.bytes.Load() >= s.byteLimit {
+		s.rotate(RotateTriggerBytes)
+	}
+}
+
+func (s *Rotate) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	detour := s.selectOutbound()
+	conn, err := detour.DialContext(ctx, network, destination)
+	if err != nil {
+		return nil, err
+	}
+	if s.byteLimit == 0 {
+		return conn, nil
+	}
+	return bufio.NewCounterConn(conn, []N.CountFunc{s.countBytes}, []N.CountFunc{s.countBytes}), nil
+}
+
+func (s *Rotate) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
+	detour := s.selectOutbound()
+	conn, err := detour.ListenPacket(ctx, destination)
+	if err != nil {
+		return nil, err
+	}
+	if s.byteLimit == 0 {
+		return conn, nil
+	}
+	return &rotatePacketConn{conn, s}, nil
+}
+
+func (s *Rotate) NewConnectionEx(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
+	s.connection.NewConnection(ctx, s, conn, metadata, onClose)
+}
+
+func (s *Rotate) NewPacketConnectionEx(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
+	s.connection.NewPacketConnection(ctx, s, conn, metadata, onClose)
+}
+
+type rotatePacketConn struct {
+	net.PacketConn
+	outbound *Rotate
+}
+
+func (c *rotatePacketConn) ReadFrom(p []byte) (n int, addr net.Addr, err error) {
+	n, addr, err = c.PacketConn.ReadFrom(p)
+	if n > 0 {
+		c.outbound.countBytes(int64(n))
+	}
+	return
+}
+
+func (c *rotatePacketConn) WriteTo(p []byte, addr net.Addr) (n int, err error) {
+	n, err = c.PacketConn.WriteTo(p, addr)
+	if n > 0 {
+		c.outbound.countBytes(int64(n))
+	}
+	return
+}
+
+func (c *rotatePacketConn) Upstream() any {
+	return c.PacketConn
+}
@@ -3,13 +3,16 @@ package group
 import (
 	"context"
 	"net"
+	"sync"
 
 	"github.com/sagernet/sing-box/adapter"
 	"github.com/sagernet/sing-box/adapter/outbound"
 	"github.com/sagernet/sing-box/common/interrupt"
+	"github.com/sagernet/sing-box/common/urltest"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/log"
 	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
 	"github.com/sagernet/sing/common/atomic"
 	E "github.com/sagernet/sing/common/exceptions"
 	"github.com/sagernet/sing/common/logger"
@@ -24,6 +27,7 @@ func RegisterSelector(registry *outbound.Registry) {
 
 var (
 	_ adapter.OutboundGroup             = (*Selector)(nil)
+	_ adapter.FilterableOutboundGroup   = (*Selector)(nil)
 	_ adapter.ConnectionHandlerEx       = (*Selector)(nil)
 	_ adapter.PacketConnectionHandlerEx = (*Selector)(nil)
 )
@@ -34,12 +38,17 @@ type Selector struct {
 	outbound                     adapter.OutboundManager
 	connection                   adapter.ConnectionManager
 	logger                       logger.ContextLogger
+	allTags                      []string
+	access                       sync.RWMutex
 	tags                         []string
+	filter                       adapter.OutboundGroupFilter
+	configuredFilter             adapter.OutboundGroupFilter
 	defaultTag                   string
 	outbounds                    map[string]adapter.Outbound
 	selected                     atomic.TypedValue[adapter.Outbound]
 	interruptGroup               *interrupt.Group
 	interruptExternalConnections bool
+	history                      *urltest.HistoryStorage
 }
 
 func NewSelector(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.SelectorOutboundOptions) (adapter.Outbound, error) {
@@ -49,15 +58,22 @@ func NewSelector(ctx context.Context, router adapter.Router, logger log.ContextL
 		outbound:                     service.FromContext[adapter.OutboundManager](ctx),
 		connection:                   service.FromContext[adapter.ConnectionManager](ctx),
 		logger:                       logger,
-		tags:                         options.Outbounds,
+		allTags:                      options.Outbounds,
+		filter:                       adapter.OutboundGroupFilter{Include: options.Include, Exclude: options.Exclude},
+		configuredFilter:             adapter.OutboundGroupFilter{Include: options.Include, Exclude: options.Exclude},
 		defaultTag:                   options.Default,
 		outbounds:                    make(map[string]adapter.Outbound),
 		interruptGroup:               interrupt.NewGroup(),
 		interruptExternalConnections: options.InterruptExistConnections,
 	}
-	if len(outbound.tags) == 0 {
+	if len(outbound.allTags) == 0 {
 		return nil, E.New("missing tags")
 	}
+	var err error
+	outbound.tags, err = filterTags(outbound.allTags, outbound.filter)
+	if err != nil {
+		return nil, err
+	}
 	return outbound, nil
 }
 
@@ -70,19 +86,30 @@ func (s *Selector) Network() []string {
 }
 
 func (s *Selector) Start() error {
-	for i, tag := range s.tags {
+	if s.history = service.PtrFromContext[urltest.HistoryStorage](s.ctx); s.history == nil {
+		if clashServer := service.FromContext[adapter.ClashServer](s.ctx); clashServer != nil {
+			s.history = clashServer.HistoryStorage()
+		}
+	}
+	for i, tag := range s.allTags {
 		detour, loaded := s.outbound.Outbound(tag)
 		if !loaded {
 			return E.New("outbound ", i, " not found: ", tag)
 		}
 		s.outbounds[tag] = detour
 	}
+	filter, tags, err := loadFilter(s.ctx, s.logger, s.Tag(), s.allTags, s.configuredFilter)
+	if err != nil {
+		return err
+	}
+	s.filter = filter
+	s.tags = tags
 
 	if s.Tag() != "" {
 		cacheFile := service.FromContext[adapter.CacheFile](s.ctx)
 		if cacheFile != nil {
 			selected := cacheFile.LoadSelected(s.Tag())
-			if selected != "" {
+			if selected != "" && common.Contains(s.tags, selected) {
 				detour, loaded := s.outbounds[selected]
 				if loaded {
 					s.selected.Store(detour)
@@ -97,8 +124,10 @@ func (s *Selector) Start() error {
 		if !loaded {
 			return E.New("default outbound not found: ", s.defaultTag)
 		}
-		s.selected.Store(detour)
-		return nil
+		if common.Contains(s.tags, s.defaultTag) {
+			s.selected.Store(detour)
+			return nil
+		}
 	}
 
 	s.selected.Store(s.outbounds[s.tags[0]])
@@ -108,23 +137,70 @@ func (s *Selector) Start() error {
 func (s *Selector) Now() string {
 	selected := s.selected.Load()
 	if selected == nil {
-		return s.tags[0]
+		return s.All()[0]
 	}
 	return selected.Tag()
 }
 
 func (s *Selector) All() []string {
+	s.access.RLock()
+	defer s.access.RUnlock()
 	return s.tags
 }
 
+func (s *Selector) Filter() adapter.OutboundGroupFilter {
+	s.access.RLock()
+	defer s.access.RUnlock()
+	return s.filter
+}
+
+func (s *Selector) SetFilter(filter adapter.OutboundGroupFilter) error {
+	tags, err := filterTags(s.allTags, filter)
+	if err != nil {
+		return err
+	}
+	s.access.Lock()
+	s.filter = filter
+	s.tags = tags
+	s.access.Unlock()
+	storeFilter(s.ctx, s.logger, s.Tag(), filter, s.configuredFilter)
+	if common.Contains(tags, s.Now()) {
+		return nil
+	}
+	if s.defaultTag != "" && common.Contains(tags, s.defaultTag) {
+		s.selectOutbound(s.defaultTag, "filter updated")
+	} else {
+		s.selectOutbound(tags[0], "filter updated")
+	}
+	return nil
+}
+
 func (s *Selector) SelectOutbound(tag string) bool {
+	return s.selectOutbound(tag, "manual")
+}
+
+func (s *Selector) selectOutbound(tag string, reason string) bool {
+	if !common.Contains(s.All(), tag) {
+		return false
+	}
 	detour, loaded := s.outbounds[tag]
 	if !loaded {
 		return false
 	}
-	if s.selected.Swap(detour) == detour {
+	previous := s.selected.Swap(detour)
+	if previous == detour {
 		return true
 	}
+	event := urltest.Event{
+		Type:     urltest.EventTypeSelected,
+		Group:    s.Tag(),
+		Outbound: tag,
+		Reason:   reason,
+	}
+	if previous != nil {
+		event.Previous = previous.Tag()
+	}
+	s.history.RecordEvent(event)
 	if s.Tag() != "" {
 		cacheFile := service.FromContext[adapter.CacheFile](s.ctx)
 		if cacheFile != nil {
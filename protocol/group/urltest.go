@@ -3,6 +3,7 @@ package group
 import (
 	"context"
 	"net"
+	"strings"
 	"sync"
 	"time"
 
@@ -17,6 +18,7 @@ import (
 	"github.com/sagernet/sing/common/atomic"
 	"github.com/sagernet/sing/common/batch"
 	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
 	M "github.com/sagernet/sing/common/metadata"
 	N "github.com/sagernet/sing/common/network"
 	"github.com/sagernet/sing/service"
@@ -29,6 +31,7 @@ func RegisterURLTest(registry *outbound.Registry) {
 
 var (
 	_ adapter.OutboundGroup           = (*URLTest)(nil)
+	_ adapter.FilterableOutboundGroup = (*URLTest)(nil)
 	_ adapter.InterfaceUpdateListener = (*URLTest)(nil)
 )
 
@@ -39,7 +42,11 @@ type URLTest struct {
 	outbound                     adapter.OutboundManager
 	connection                   adapter.ConnectionManager
 	logger                       log.ContextLogger
+	allTags                      []string
+	access                       sync.RWMutex
 	tags                         []string
+	filter                       adapter.OutboundGroupFilter
+	configuredFilter             adapter.OutboundGroupFilter
 	link                         string
 	interval                     time.Duration
 	tolerance                    uint16
@@ -56,29 +63,40 @@ func NewURLTest(ctx context.Context, router adapter.Router, logger log.ContextLo
 		outbound:                     service.FromContext[adapter.OutboundManager](ctx),
 		connection:                   service.FromContext[adapter.ConnectionManager](ctx),
 		logger:                       logger,
-		tags:                         options.Outbounds,
+		allTags:                      options.Outbounds,
+		filter:                       adapter.OutboundGroupFilter{Include: options.Include, Exclude: options.Exclude},
+		configuredFilter:             adapter.OutboundGroupFilter{Include: options.Include, Exclude: options.Exclude},
 		link:                         options.URL,
 		interval:                     time.Duration(options.Interval),
 		tolerance:                    options.Tolerance,
 		idleTimeout:                  time.Duration(options.IdleTimeout),
 		interruptExternalConnections: options.InterruptExistConnections,
 	}
-	if len(outbound.tags) == 0 {
+	if len(outbound.allTags) == 0 {
 		return nil, E.New("missing tags")
 	}
+	var err error
+	outbound.tags, err = filterTags(outbound.allTags, outbound.filter)
+	if err != nil {
+		return nil, err
+	}
 	return outbound, nil
 }
 
 func (s *URLTest) Start() error {
-	outbounds := make([]adapter.Outbound, 0, len(s.tags))
-	for i, tag := range s.tags {
-		detour, loaded := s.outbound.Outbound(tag)
+	for i, tag := range s.allTags {
+		_, loaded := s.outbound.Outbound(tag)
 		if !loaded {
 			return E.New("outbound ", i, " not found: ", tag)
 		}
-		outbounds = append(outbounds, detour)
 	}
-	group, err := NewURLTestGroup(s.ctx, s.outbound, s.logger, outbounds, s.link, s.interval, s.tolerance, s.idleTimeout, s.interruptExternalConnections)
+	filter, tags, err := loadFilter(s.ctx, s.logger, s.Tag(), s.allTags, s.configuredFilter)
+	if err != nil {
+		return err
+	}
+	s.filter = filter
+	s.tags = tags
+	group, err := NewURLTestGroup(s.ctx, s.outbound, s.logger, s.Tag(), s.loadOutbounds(tags), s.link, s.interval, s.tolerance, s.idleTimeout, s.interruptExternalConnections)
 	if err != nil {
 		return err
 	}
@@ -107,9 +125,38 @@ func (s *URLTest) Now() string {
 }
 
 func (s *URLTest) All() []string {
+	s.access.RLock()
+	defer s.access.RUnlock()
 	return s.tags
 }
 
+func (s *URLTest) Filter() adapter.OutboundGroupFilter {
+	s.access.RLock()
+	defer s.access.RUnlock()
+	return s.filter
+}
+
+func (s *URLTest) SetFilter(filter adapter.OutboundGroupFilter) error {
+	tags, err := filterTags(s.allTags, filter)
+	if err != nil {
+		return err
+	}
+	s.access.Lock()
+	s.filter = filter
+	s.tags = tags
+	s.access.Unlock()
+	storeFilter(s.ctx, s.logger, s.Tag(), filter, s.configuredFilter)
+	s.group.SetOutbounds(s.loadOutbounds(tags))
+	return nil
+}
+
+func (s *URLTest) loadOutbounds(tags []string) []adapter.Outbound {
+	return common.Map(tags, func(tag string) adapter.Outbound {
+		detour, _ := s.outbound.Outbound(tag)
+		return detour
+	})
+}
+
 func (s *URLTest) URLTest(ctx context.Context) (map[string]uint16, error) {
 	return s.group.URLTest(ctx)
 }
@@ -140,7 +187,7 @@ func (s *URLTest) DialContext(ctx context.Context, network string, destination M
 		return s.group.interruptGroup.NewConn(conn, interrupt.IsExternalConnectionFromContext(ctx)), nil
 	}
 	s.logger.ErrorContext(ctx, err)
-	s.group.history.DeleteURLTestHistory(outbound.Tag())
+	s.group.history.DeleteURLTestHistoryWithError(outbound.Tag(), err)
 	return nil, err
 }
 
@@ -158,7 +205,7 @@ func (s *URLTest) ListenPacket(ctx context.Context, destination M.Socksaddr) (ne
 		return s.group.interruptGroup.NewPacketConn(conn, interrupt.IsExternalConnectionFromContext(ctx)), nil
 	}
 	s.logger.ErrorContext(ctx, err)
-	s.group.history.DeleteURLTestHistory(outbound.Tag())
+	s.group.history.DeleteURLTestHistoryWithError(outbound.Tag(), err)
 	return nil, err
 }
 
@@ -182,6 +229,9 @@ type URLTestGroup struct {
 	router                       adapter.Router
 	outboundManager              adapter.OutboundManager
 	logger                       log.Logger
+	tag                          string
+	cacheFile                    adapter.CacheFile
+	outboundsAccess              sync.RWMutex
 	outbounds                    []adapter.Outbound
 	link                         string
 	interval                     time.Duration
@@ -202,7 +252,7 @@ type URLTestGroup struct {
 	lastActive atomic.TypedValue[time.Time]
 }
 
-func NewURLTestGroup(ctx context.Context, outboundManager adapter.OutboundManager, logger log.Logger, outbounds []adapter.Outbound, link string, interval time.Duration, tolerance uint16, idleTimeout time.Duration, interruptExternalConnections bool) (*URLTestGroup, error) {
+func NewURLTestGroup(ctx context.Context, outboundManager adapter.OutboundManager, logger log.Logger, tag string, outbounds []adapter.Outbound, link string, interval time.Duration, tolerance uint16, idleTimeout time.Duration, interruptExternalConnections bool) (*URLTestGroup, error) {
 	if interval == 0 {
 		interval = C.DefaultURLTestInterval
 	}
@@ -222,10 +272,12 @@ func NewURLTestGroup(ctx context.Context, outboundManager adapter.OutboundManage
 	} else {
 		history = urltest.NewHistoryStorage()
 	}
-	return &URLTestGroup{
+	group := &URLTestGroup{
 		ctx:                          ctx,
 		outboundManager:              outboundManager,
 		logger:                       logger,
+		tag:                          tag,
+		cacheFile:                    service.FromContext[adapter.CacheFile](ctx),
 		outbounds:                    outbounds,
 		link:                         link,
 		interval:                     interval,
@@ -236,7 +288,73 @@ func NewURLTestGroup(ctx context.Context, outboundManager adapter.OutboundManage
 		pauseManager:                 service.FromContext[pause.Manager](ctx),
 		interruptGroup:               interrupt.NewGroup(),
 		interruptExternalConnections: interruptExternalConnections,
-	}, nil
+	}
+	group.loadSaved()
+	return group, nil
+}
+
+// loadSaved restores the selected outbound and test results from the cache
+// file, so connections before the first test do not go to an unavailable outbound.
+func (g *URLTestGroup) loadSaved() {
+	if g.cacheFile == nil || g.tag == "" {
+		return
+	}
+	saved := g.cacheFile.LoadURLTestGroup(g.tag)
+	if saved == nil {
+		return
+	}
+	for _, detour := range g.outbounds {
+		realTag := RealTag(detour)
+		delay, loaded := saved.Delays[realTag]
+		if !loaded || g.history.LoadURLTestHistory(realTag) != nil {
+			continue
+		}
+		g.history.StoreURLTestHistory(realTag, &urltest.History{
+			Time:  delay.Time,
+			Delay: delay.Delay,
+		})
+	}
+	for _, detour := range g.outbounds {
+		if detour.Tag() != saved.Selected || g.history.LoadURLTestHistory(RealTag(detour)) == nil {
+			continue
+		}
+		if common.Contains(detour.Network(), N.NetworkTCP) {
+			g.selectedOutboundTCP = detour
+		}
+		if common.Contains(detour.Network(), N.NetworkUDP) {
+			g.selectedOutboundUDP = detour
+		}
+		break
+	}
+}
+
+func (g *URLTestGroup) storeSaved() {
+	if g.cacheFile == nil || g.tag == "" {
+		return
+	}
+	saved := &adapter.SavedURLTestGroup{
+		Delays: make(map[string]adapter.SavedURLTestDelay),
+	}
+	if g.selectedOutboundTCP != nil {
+		saved.Selected = g.selectedOutboundTCP.Tag()
+	} else if g.selectedOutboundUDP != nil {
+		saved.Selected = g.selectedOutboundUDP.Tag()
+	}
+	for _, detour := range g.loadOutbounds() {
+		realTag := RealTag(detour)
+		history := g.history.LoadURLTestHistory(realTag)
+		if history == nil {
+			continue
+		}
+		saved.Delays[realTag] = adapter.SavedURLTestDelay{
+			Time:  history.Time,
+			Delay: history.Delay,
+		}
+	}
+	err := g.cacheFile.StoreURLTestGroup(g.tag, saved)
+	if err != nil {
+		g.logger.Error("store URL test results: ", err)
+	}
 }
 
 func (g *URLTestGroup) PostStart() {
@@ -271,6 +389,27 @@ func (g *URLTestGroup) Close() error {
 	return nil
 }
 
+// SetOutbounds replaces the outbounds of the group, and reselects if the selected outbound was removed.
+func (g *URLTestGroup) SetOutbounds(outbounds []adapter.Outbound) {
+	g.outboundsAccess.Lock()
+	g.outbounds = outbounds
+	g.outboundsAccess.Unlock()
+	if g.selectedOutboundTCP != nil && !common.Contains(outbounds, g.selectedOutboundTCP) {
+		g.selectedOutboundTCP = nil
+	}
+	if g.selectedOutboundUDP != nil && !common.Contains(outbounds, g.selectedOutboundUDP) {
+		g.selectedOutboundUDP = nil
+	}
+	g.performUpdateCheck()
+	go g.CheckOutbounds(false)
+}
+
+func (g *URLTestGroup) loadOutbounds() []adapter.Outbound {
+	g.outboundsAccess.RLock()
+	defer g.outboundsAccess.RUnlock()
+	return g.outbounds
+}
+
 func (g *URLTestGroup) Select(network string) (adapter.Outbound, bool) {
 	var minDelay uint16
 	var minOutbound adapter.Outbound
@@ -290,7 +429,8 @@ func (g *URLTestGroup) Select(network string) (adapter.Outbound, bool) {
 			}
 		}
 	}
-	for _, detour := range g.outbounds {
+	outbounds := g.loadOutbounds()
+	for _, detour := range outbounds {
 		if !common.Contains(detour.Network(), network) {
 			continue
 		}
@@ -304,7 +444,7 @@ func (g *URLTestGroup) Select(network string) (adapter.Outbound, bool) {
 		}
 	}
 	if minOutbound == nil {
-		for _, detour := range g.outbounds {
+		for _, detour := range outbounds {
 			if !common.Contains(detour.Network(), network) {
 				continue
 			}
@@ -355,7 +495,7 @@ func (g *URLTestGroup) urlTest(ctx context.Context, force bool) (map[string]uint
 	b, _ := batch.New(ctx, batch.WithConcurrencyNum[any](10))
 	checked := make(map[string]bool)
 	var resultAccess sync.Mutex
-	for _, detour := range g.outbounds {
+	for _, detour := range g.loadOutbounds() {
 		tag := detour.Tag()
 		realTag := RealTag(detour)
 		if checked[realTag] {
@@ -371,17 +511,18 @@ func (g *URLTestGroup) urlTest(ctx context.Context, force bool) (map[string]uint
 			continue
 		}
 		b.Go(realTag, func() (any, error) {
-			testCtx, cancel := context.WithTimeout(g.ctx, C.TCPTimeout)
+			testCtx, cancel := context.WithTimeout(urltest.ContextWithRootCAs(g.ctx, adapter.RootPoolFromContext(g.ctx)), C.TCPTimeout)
 			defer cancel()
-			t, err := urltest.URLTest(testCtx, g.link, p)
+			t, breakdown, err := urltest.URLTestBreakdown(testCtx, g.link, p)
 			if err != nil {
 				g.logger.Debug("outbound ", tag, " unavailable: ", err)
-				g.history.DeleteURLTestHistory(realTag)
+				g.history.DeleteURLTestHistoryWithError(realTag, err)
 			} else {
 				g.logger.Debug("outbound ", tag, " available: ", t, "ms")
 				g.history.StoreURLTestHistory(realTag, &urltest.History{
-					Time:  time.Now(),
-					Delay: t,
+					Time:      time.Now(),
+					Delay:     t,
+					Breakdown: breakdown,
 				})
 				resultAccess.Lock()
 				result[tag] = t
@@ -392,16 +533,19 @@ func (g *URLTestGroup) urlTest(ctx context.Context, force bool) (map[string]uint
 	}
 	b.Wait()
 	g.performUpdateCheck()
+	g.storeSaved()
 	return result, nil
 }
 
 func (g *URLTestGroup) performUpdateCheck() {
 	var updated bool
 	if outbound, exists := g.Select(N.NetworkTCP); outbound != nil && (g.selectedOutboundTCP == nil || (exists && outbound != g.selectedOutboundTCP)) {
+		g.recordSelected(N.NetworkTCP, g.selectedOutboundTCP, outbound)
 		g.selectedOutboundTCP = outbound
 		updated = true
 	}
 	if outbound, exists := g.Select(N.NetworkUDP); outbound != nil && (g.selectedOutboundUDP == nil || (exists && outbound != g.selectedOutboundUDP)) {
+		g.recordSelected(N.NetworkUDP, g.selectedOutboundUDP, outbound)
 		g.selectedOutboundUDP = outbound
 		updated = true
 	}
@@ -409,3 +553,31 @@ func (g *URLTestGroup) performUpdateCheck() {
 		g.interruptGroup.Interrupt(g.interruptExternalConnections)
 	}
 }
+
+func (g *URLTestGroup) recordSelected(network string, previous adapter.Outbound, outbound adapter.Outbound) {
+	event := urltest.Event{
+		Type:     urltest.EventTypeURLTest,
+		Group:    g.tag,
+		Network:  network,
+		Outbound: outbound.Tag(),
+	}
+	var reasons []string
+	if history := g.history.LoadURLTestHistory(RealTag(outbound)); history != nil {
+		reasons = append(reasons, F.ToString("delay ", history.Delay, "ms"))
+	} else if previous == nil {
+		// initial selection before any test
+		return
+	} else {
+		reasons = append(reasons, "no outbound available")
+	}
+	if previous != nil {
+		event.Previous = previous.Tag()
+		if history := g.history.LoadURLTestHistory(RealTag(previous)); history != nil {
+			reasons = append(reasons, F.ToString("previous delay ", history.Delay, "ms"))
+		} else {
+			reasons = append(reasons, "previous unavailable")
+		}
+	}
+	event.Reason = strings.Join(reasons, ", ")
+	g.history.RecordEvent(event)
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/sagernet/sing-box/adapter"
 	"github.com/sagernet/sing-box/adapter/inbound"
 	"github.com/sagernet/sing-box/common/listener"
+	"github.com/sagernet/sing-box/common/proxyauth"
 	"github.com/sagernet/sing-box/common/tls"
 	"github.com/sagernet/sing-box/common/uot"
 	C "github.com/sagernet/sing-box/constant"
@@ -32,6 +33,7 @@ type Inbound struct {
 	logger        log.ContextLogger
 	listener      *listener.Listener
 	authenticator *auth.Authenticator
+	externalAuth  *proxyauth.Authenticator
 	tlsConfig     tls.ServerConfig
 }
 
@@ -42,6 +44,13 @@ func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLo
 		logger:        logger,
 		authenticator: auth.NewAuthenticator(options.Users),
 	}
+	if options.ExternalAuth != nil {
+		externalAuth, err := proxyauth.New(ctx, logger, options.Users, *options.ExternalAuth)
+		if err != nil {
+			return nil, err
+		}
+		inbound.externalAuth = externalAuth
+	}
 	if options.TLS != nil {
 		tlsConfig, err := tls.NewServer(ctx, logger, common.PtrValueOrDefault(options.TLS))
 		if err != nil {
@@ -50,13 +59,14 @@ func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLo
 		inbound.tlsConfig = tlsConfig
 	}
 	inbound.listener = listener.New(listener.Options{
-		Context:           ctx,
-		Logger:            logger,
-		Network:           []string{N.NetworkTCP},
-		Listen:            options.ListenOptions,
-		ConnectionHandler: inbound,
-		SetSystemProxy:    options.SetSystemProxy,
-		SystemProxySOCKS:  false,
+		Context:             ctx,
+		Logger:              logger,
+		Network:             []string{N.NetworkTCP},
+		Listen:              options.ListenOptions,
+		AcceptProxyProtocol: options.AcceptProxyProtocol,
+		ConnectionHandler:   inbound,
+		SetSystemProxy:      options.SetSystemProxy,
+		SystemProxySOCKS:    false,
 	})
 	return inbound, nil
 }
@@ -71,6 +81,12 @@ func (h *Inbound) Start(stage adapter.StartStage) error {
 			return E.Cause(err, "create TLS config")
 		}
 	}
+	if h.externalAuth != nil {
+		err := h.externalAuth.Start()
+		if err != nil {
+			return err
+		}
+	}
 	return h.listener.Start()
 }
 
@@ -78,12 +94,13 @@ func (h *Inbound) Close() error {
 	return common.Close(
 		h.listener,
 		h.tlsConfig,
+		common.PtrOrNil(h.externalAuth),
 	)
 }
 
 func (h *Inbound) NewConnectionEx(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
 	if h.tlsConfig != nil {
-		tlsConn, err := tls.ServerHandshake(ctx, conn, h.tlsConfig)
+		tlsConn, err := tls.ServerHandshake(adapter.WithContext(ctx, &metadata), conn, h.tlsConfig)
 		if err != nil {
 			N.CloseOnHandshakeFailure(conn, onClose, err)
 			h.logger.ErrorContext(ctx, E.Cause(err, "process connection from ", metadata.Source, ": TLS handshake"))
@@ -91,7 +108,12 @@ func (h *Inbound) NewConnectionEx(ctx context.Context, conn net.Conn, metadata a
 		}
 		conn = tlsConn
 	}
-	err := http.HandleConnectionEx(ctx, conn, std_bufio.NewReader(conn), h.authenticator, adapter.NewUpstreamHandlerEx(metadata, h.newUserConnection, h.streamUserPacketConnection), metadata.Source, onClose)
+	reader := std_bufio.NewReader(conn)
+	authenticator := h.authenticator
+	if h.externalAuth != nil {
+		authenticator = h.externalAuth.HTTPAuthenticator(ctx, metadata.Source, reader)
+	}
+	err := http.HandleConnectionEx(ctx, conn, reader, authenticator, adapter.NewUpstreamHandlerEx(metadata, h.newUserConnection, h.streamUserPacketConnection), metadata.Source, onClose)
 	if err != nil {
 		N.CloseOnHandshakeFailure(conn, onClose, err)
 		h.logger.ErrorContext(ctx, E.Cause(err, "process connection from ", metadata.Source))
@@ -0,0 +1,215 @@
+package http2
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/adapter/outbound"
+	"github.com/sagernet/sing-box/common/dialer"
+	"github.com/sagernet/sing-box/common/tls"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/transport/v2rayhttp"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/logger"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/sync/singleflight"
+)
+
+func RegisterOutbound(registry *outbound.Registry) {
+	outbound.Register[option.HTTP2OutboundOptions](registry, C.TypeHTTP2, NewOutbound)
+}
+
+type Outbound struct {
+	outbound.Adapter
+	ctx        context.Context
+	logger     logger.ContextLogger
+	dialer     N.Dialer
+	serverAddr M.Socksaddr
+	tlsConfig  tls.Config
+	transport  *http2.Transport
+	headers    http.Header
+	connAccess sync.Mutex
+	clientConn *http2.ClientConn
+	connecting singleflight.Group
+	closed     bool
+}
+
+func NewOutbound(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.HTTP2OutboundOptions) (adapter.Outbound, error) {
+	outboundDialer, err := dialer.NewServer(ctx, options.DialerOptions, options.ServerOptions.Build())
+	if err != nil {
+		return nil, err
+	}
+	var tlsConfig tls.Config
+	if options.TLS != nil && options.TLS.Enabled {
+		tlsConfig, err = tls.NewClient(ctx, options.Server, common.PtrValueOrDefault(options.TLS))
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.SetNextProtos([]string{http2.NextProtoTLS})
+	}
+	headers := options.Headers.Build()
+	if options.Username != "" {
+		headers.Set("Proxy-Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(options.Username+":"+options.Password)))
+	}
+	return &Outbound{
+		Adapter:    outbound.NewAdapterWithDialerOptions(C.TypeHTTP2, tag, []string{N.NetworkTCP}, options.DialerOptions),
+		ctx:        ctx,
+		logger:     logger,
+		dialer:     outboundDialer,
+		serverAddr: options.ServerOptions.Build(),
+		tlsConfig:  tlsConfig,
+		transport: &http2.Transport{
+			AllowHTTP: tlsConfig == nil,
+		},
+		headers: headers,
+	}, nil
+}
+
+// offer returns the shared HTTP/2 connection, and connects a new one if it
+// cannot take new requests. Concurrent callers share a single connection
+// attempt, which is made without holding connAccess.
+func (h *Outbound) offer(ctx context.Context) (*http2.ClientConn, error) {
+	h.connAccess.Lock()
+	clientConn := h.clientConn
+	h.connAccess.Unlock()
+	if clientConn != nil && clientConn.CanTakeNewRequest() {
+		return clientConn, nil
+	}
+	connectResult := h.connecting.DoChan("", func() (any, error) {
+		newConn, err := h.connect()
+		if err != nil {
+			return nil, err
+		}
+		h.connAccess.Lock()
+		defer h.connAccess.Unlock()
+		if h.closed {
+			newConn.Close()
+			return nil, net.ErrClosed
+		}
+		h.clientConn = newConn
+		return newConn, nil
+	})
+	select {
+	case result := <-connectResult:
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		return result.Val.(*http2.ClientConn), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// connect dials the server and completes the TLS and HTTP/2 handshakes within C.TCPTimeout.
+// It is not bound to the context of a single request, since the connection is shared.
+func (h *Outbound) connect() (*http2.ClientConn, error) {
+	ctx, cancel := context.WithTimeout(h.ctx, C.TCPTimeout)
+	defer cancel()
+	conn, err := h.dialer.DialContext(ctx, N.NetworkTCP, h.serverAddr)
+	if err != nil {
+		return nil, err
+	}
+	if h.tlsConfig != nil {
+		tlsConn, err := tls.ClientHandshake(ctx, conn, h.tlsConfig)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+	clientConn, err := h.transport.NewClientConn(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return clientConn, nil
+}
+
+func (h *Outbound) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	ctx, metadata := adapter.ExtendContext(ctx)
+	metadata.Outbound = h.Tag()
+	metadata.Destination = destination
+	if N.NetworkName(network) != N.NetworkTCP {
+		return nil, E.New("unsupported network: ", network)
+	}
+	h.logger.InfoContext(ctx, "outbound connection to ", destination)
+	clientConn, err := h.offer(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// The stream is bound to the request context for its whole lifetime,
+	// so only the handshake is limited by the dial context.
+	requestCtx, cancel := context.WithCancel(h.ctx)
+	pipeReader, pipeWriter := io.Pipe()
+	request := (&http.Request{
+		Method:        http.MethodConnect,
+		URL:           &url.URL{Host: destination.String()},
+		Host:          destination.String(),
+		Header:        h.headers.Clone(),
+		Body:          pipeReader,
+		ContentLength: -1,
+	}).WithContext(requestCtx)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			cancel()
+		case <-done:
+		}
+	}()
+	response, err := clientConn.RoundTrip(request)
+	close(done)
+	if err != nil {
+		cancel()
+		pipeWriter.Close()
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		cancel()
+		pipeWriter.Close()
+		response.Body.Close()
+		return nil, E.New("CONNECT ", destination, ": ", response.Status)
+	}
+	return &connectConn{
+		HTTP2Conn: v2rayhttp.NewHTTPConn(response.Body, pipeWriter),
+		cancel:    cancel,
+	}, nil
+}
+
+func (h *Outbound) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
+	return nil, os.ErrInvalid
+}
+
+func (h *Outbound) Close() error {
+	h.connAccess.Lock()
+	defer h.connAccess.Unlock()
+	h.closed = true
+	if h.clientConn != nil {
+		h.clientConn.Close()
+		h.clientConn = nil
+	}
+	return nil
+}
+
+type connectConn struct {
+	v2rayhttp.HTTP2Conn
+	cancel context.CancelFunc
+}
+
+func (c *connectConn) Close() error {
+	c.cancel()
+	return c.HTTP2Conn.Close()
+}
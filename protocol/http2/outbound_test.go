@@ -0,0 +1,143 @@
+package http2
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/sagernet/sing-box/common/tls"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common/logger"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+)
+
+type pipeDialer struct {
+	serve func(conn net.Conn)
+	dials atomic.Int32
+	conns chan *closeRecordConn
+}
+
+type closeRecordConn struct {
+	net.Conn
+	closed atomic.Bool
+}
+
+func (c *closeRecordConn) Close() error {
+	c.closed.Store(true)
+	return c.Conn.Close()
+}
+
+func (d *pipeDialer) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	d.dials.Add(1)
+	client, server := net.Pipe()
+	go d.serve(server)
+	conn := &closeRecordConn{Conn: client}
+	if d.conns != nil {
+		d.conns <- conn
+	}
+	return conn, nil
+}
+
+func (d *pipeDialer) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
+	return nil, net.ErrClosed
+}
+
+func serveEchoConnect(conn net.Conn) {
+	(&http2.Server{}).ServeConn(conn, &http2.ServeConnOpts{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodConnect {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			buffer := make([]byte, 1024)
+			for {
+				n, err := r.Body.Read(buffer)
+				if n > 0 {
+					w.Write(buffer[:n])
+					w.(http.Flusher).Flush()
+				}
+				if err != nil {
+					return
+				}
+			}
+		}),
+	})
+}
+
+func newTestOutbound(dialer N.Dialer, tlsConfig tls.Config) *Outbound {
+	return &Outbound{
+		ctx:        context.Background(),
+		logger:     logger.NOP(),
+		dialer:     dialer,
+		serverAddr: M.ParseSocksaddr("127.0.0.1:443"),
+		tlsConfig:  tlsConfig,
+		transport:  &http2.Transport{AllowHTTP: tlsConfig == nil},
+		headers:    http.Header{},
+	}
+}
+
+func TestOfferSharesConnection(t *testing.T) {
+	t.Parallel()
+	dialer := &pipeDialer{serve: serveEchoConnect}
+	outbound := newTestOutbound(dialer, nil)
+	defer outbound.Close()
+	var wg sync.WaitGroup
+	clientConns := make([]*http2.ClientConn, 8)
+	for i := range clientConns {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			clientConn, err := outbound.offer(context.Background())
+			require.NoError(t, err)
+			clientConns[i] = clientConn
+		}(i)
+	}
+	wg.Wait()
+	require.Equal(t, int32(1), dialer.dials.Load())
+	for _, clientConn := range clientConns {
+		require.Same(t, clientConns[0], clientConn)
+	}
+}
+
+func TestConnect(t *testing.T) {
+	t.Parallel()
+	outbound := newTestOutbound(&pipeDialer{serve: serveEchoConnect}, nil)
+	defer outbound.Close()
+	conn, err := outbound.DialContext(context.Background(), N.NetworkTCP, M.ParseSocksaddr("example.com:80"))
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("hello"))
+	require.NoError(t, err)
+	buffer := make([]byte, 5)
+	_, err = io.ReadFull(conn, buffer)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(buffer))
+}
+
+func TestTLSHandshakeFailureClosesConn(t *testing.T) {
+	t.Parallel()
+	tlsConfig, err := tls.NewClient(context.Background(), "example.com", option.OutboundTLSOptions{Enabled: true})
+	require.NoError(t, err)
+	dialer := &pipeDialer{
+		serve: func(conn net.Conn) {
+			conn.Read(make([]byte, 1024))
+			conn.Close()
+		},
+		conns: make(chan *closeRecordConn, 1),
+	}
+	outbound := newTestOutbound(dialer, tlsConfig)
+	defer outbound.Close()
+	_, err = outbound.offer(context.Background())
+	require.Error(t, err)
+	require.True(t, (<-dialer.conns).closed.Load())
+}
@@ -0,0 +1,70 @@
+package http3
+
+import (
+	"io"
+	"net"
+	"os"
+	"time"
+
+	"github.com/sagernet/quic-go/http3"
+	"github.com/sagernet/quic-go/quicvarint"
+	"github.com/sagernet/sing-box/transport/v2rayquic"
+	"github.com/sagernet/sing/common/baderror"
+	M "github.com/sagernet/sing/common/metadata"
+)
+
+// datagramConn carries UDP payloads in HTTP datagrams with context ID 0 (RFC 9298).
+type datagramConn struct {
+	v2rayquic.StreamWrapper
+	stream      http3.RequestStream
+	destination M.Socksaddr
+}
+
+func (c *datagramConn) Read(p []byte) (n int, err error) {
+	for {
+		var datagram []byte
+		datagram, err = c.stream.ReceiveDatagram(c.stream.Context())
+		if err != nil {
+			return 0, baderror.WrapQUIC(err)
+		}
+		contextID, contextIDLen, parseErr := quicvarint.Parse(datagram)
+		if parseErr != nil || contextID != 0 {
+			continue
+		}
+		payload := datagram[contextIDLen:]
+		if len(payload) > len(p) {
+			return 0, io.ErrShortBuffer
+		}
+		return copy(p, payload), nil
+	}
+}
+
+func (c *datagramConn) Write(p []byte) (n int, err error) {
+	datagram := make([]byte, 1+len(p))
+	copy(datagram[1:], p)
+	err = c.stream.SendDatagram(datagram)
+	if err != nil {
+		return 0, baderror.WrapQUIC(err)
+	}
+	return len(p), nil
+}
+
+func (c *datagramConn) RemoteAddr() net.Addr {
+	return c.destination
+}
+
+func (c *datagramConn) SetDeadline(t time.Time) error {
+	return os.ErrInvalid
+}
+
+func (c *datagramConn) SetReadDeadline(t time.Time) error {
+	return os.ErrInvalid
+}
+
+func (c *datagramConn) SetWriteDeadline(t time.Time) error {
+	return os.ErrInvalid
+}
+
+func (c *datagramConn) NeedAdditionalReadDeadline() bool {
+	return true
+}
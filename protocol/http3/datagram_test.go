@@ -0,0 +1,50 @@
+package http3
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/sagernet/quic-go/http3"
+
+	"github.com/stretchr/testify/require"
+)
+
+type datagramStream struct {
+	http3.RequestStream
+	datagrams [][]byte
+}
+
+func (s *datagramStream) Context() context.Context {
+	return context.Background()
+}
+
+func (s *datagramStream) ReceiveDatagram(ctx context.Context) ([]byte, error) {
+	if len(s.datagrams) == 0 {
+		return nil, io.EOF
+	}
+	datagram := s.datagrams[0]
+	s.datagrams = s.datagrams[1:]
+	return datagram, nil
+}
+
+func TestDatagramRead(t *testing.T) {
+	t.Parallel()
+	conn := &datagramConn{stream: &datagramStream{datagrams: [][]byte{
+		{0x01, 'x'},
+		{0x00, 'h', 'e', 'l', 'l', 'o'},
+	}}}
+	buffer := make([]byte, 16)
+	n, err := conn.Read(buffer)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(buffer[:n]))
+}
+
+func TestDatagramReadShortBuffer(t *testing.T) {
+	t.Parallel()
+	conn := &datagramConn{stream: &datagramStream{datagrams: [][]byte{
+		{0x00, 'h', 'e', 'l', 'l', 'o'},
+	}}}
+	_, err := conn.Read(make([]byte, 4))
+	require.ErrorIs(t, err, io.ErrShortBuffer)
+}
@@ -0,0 +1,268 @@
+package http3
+
+import (
+	"context"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sagernet/quic-go"
+	"github.com/sagernet/quic-go/http3"
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/adapter/outbound"
+	"github.com/sagernet/sing-box/common/dialer"
+	"github.com/sagernet/sing-box/common/pmtud"
+	"github.com/sagernet/sing-box/common/tls"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/transport/v2rayquic"
+	"github.com/sagernet/sing-quic"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/bufio"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+	"github.com/sagernet/sing/common/logger"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+// defaultUDPPath is the default URI template of RFC 9298.
+const defaultUDPPath = "/.well-known/masque/udp/{target_host}/{target_port}/"
+
+func RegisterOutbound(registry *outbound.Registry) {
+	outbound.Register[option.HTTP3OutboundOptions](registry, C.TypeHTTP3, NewOutbound)
+}
+
+var _ adapter.InterfaceUpdateListener = (*Outbound)(nil)
+
+type Outbound struct {
+	outbound.Adapter
+	ctx        context.Context
+	logger     logger.ContextLogger
+	dialer     N.Dialer
+	serverAddr M.Socksaddr
+	tlsConfig  tls.Config
+	quicConfig *quic.Config
+	transport  *http3.Transport
+	headers    http.Header
+	udpPath    string
+	connAccess sync.Mutex
+	conn       quic.Connection
+	rawConn    net.Conn
+	clientConn *http3.ClientConn
+}
+
+func NewOutbound(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.HTTP3OutboundOptions) (adapter.Outbound, error) {
+	if options.TLS == nil || !options.TLS.Enabled {
+		return nil, C.ErrTLSRequired
+	}
+	tlsConfig, err := tls.NewClient(ctx, options.Server, common.PtrValueOrDefault(options.TLS))
+	if err != nil {
+		return nil, err
+	}
+	if len(tlsConfig.NextProtos()) == 0 {
+		tlsConfig.SetNextProtos([]string{http3.NextProtoH3})
+	}
+	outboundDialer, err := dialer.NewServer(ctx, options.DialerOptions, options.ServerOptions.Build())
+	if err != nil {
+		return nil, err
+	}
+	networkList := options.Network.Build()
+	enableDatagrams := common.Contains(networkList, N.NetworkUDP)
+	headers := options.Headers.Build()
+	if options.Username != "" {
+		headers.Set("Proxy-Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(options.Username+":"+options.Password)))
+	}
+	udpPath := options.UDPPath
+	if udpPath == "" {
+		udpPath = defaultUDPPath
+	}
+	return &Outbound{
+		Adapter:    outbound.NewAdapterWithDialerOptions(C.TypeHTTP3, tag, networkList, options.DialerOptions),
+		ctx:        ctx,
+		logger:     logger,
+		dialer:     outboundDialer,
+		serverAddr: options.ServerOptions.Build(),
+		tlsConfig:  tlsConfig,
+		quicConfig: &quic.Config{
+			InitialPacketSize:       options.InitialPacketSize,
+			DisablePathMTUDiscovery: !C.QUICPathMTUDiscovery || options.DisablePathMTUDiscovery,
+			EnableDatagrams:         enableDatagrams,
+		},
+		transport: &http3.Transport{
+			EnableDatagrams: enableDatagrams,
+		},
+		headers: headers,
+		udpPath: udpPath,
+	}, nil
+}
+
+func (h *Outbound) offer() (quic.Connection, *http3.ClientConn, error) {
+	h.connAccess.Lock()
+	defer h.connAccess.Unlock()
+	if h.conn != nil && !common.Done(h.conn.Context()) {
+		return h.conn, h.clientConn, nil
+	}
+	h.closeConn()
+	udpConn, err := h.dialer.DialContext(h.ctx, N.NetworkUDP, h.serverAddr)
+	if err != nil {
+		return nil, nil, err
+	}
+	packetConn := pmtud.NewPacketConn(udpConn)
+	quicConn, err := qtls.Dial(h.ctx, packetConn, udpConn.RemoteAddr(), h.tlsConfig, h.quicConfig)
+	if err != nil {
+		packetConn.Close()
+		return nil, nil, err
+	}
+	h.conn = quicConn
+	h.rawConn = udpConn
+	h.clientConn = h.transport.NewClientConn(quicConn)
+	return quicConn, h.clientConn, nil
+}
+
+func (h *Outbound) closeConn() {
+	if h.conn != nil {
+		h.conn.CloseWithError(0, "")
+	}
+	if h.rawConn != nil {
+		h.rawConn.Close()
+	}
+	h.conn = nil
+	h.rawConn = nil
+	h.clientConn = nil
+}
+
+func (h *Outbound) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	ctx, metadata := adapter.ExtendContext(ctx)
+	metadata.Outbound = h.Tag()
+	metadata.Destination = destination
+	switch N.NetworkName(network) {
+	case N.NetworkTCP:
+		h.logger.InfoContext(ctx, "outbound connection to ", destination)
+		request := &http.Request{
+			Method: http.MethodConnect,
+			URL:    &url.URL{Host: destination.String()},
+			Host:   destination.String(),
+			Header: h.headers.Clone(),
+		}
+		conn, stream, err := h.roundTrip(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+		return &v2rayquic.StreamWrapper{Conn: conn, Stream: stream}, nil
+	case N.NetworkUDP:
+		h.logger.InfoContext(ctx, "outbound packet connection to ", destination)
+		return h.dialUDP(ctx, destination)
+	default:
+		return nil, E.New("unsupported network: ", network)
+	}
+}
+
+func (h *Outbound) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
+	ctx, metadata := adapter.ExtendContext(ctx)
+	metadata.Outbound = h.Tag()
+	metadata.Destination = destination
+	h.logger.InfoContext(ctx, "outbound packet connection to ", destination)
+	conn, err := h.dialUDP(ctx, destination)
+	if err != nil {
+		return nil, err
+	}
+	return bufio.NewUnbindPacketConn(conn), nil
+}
+
+func (h *Outbound) dialUDP(ctx context.Context, destination M.Socksaddr) (net.Conn, error) {
+	targetHost := destination.AddrString()
+	if destination.IsIPv6() {
+		targetHost = strings.ReplaceAll(targetHost, ":", "%3A")
+	}
+	requestPath := strings.NewReplacer(
+		"{target_host}", targetHost,
+		"{target_port}", F.ToString(destination.Port),
+	).Replace(h.udpPath)
+	requestURL, err := url.Parse("https://" + h.serverAddr.String() + requestPath)
+	if err != nil {
+		return nil, E.Cause(err, "parse udp path")
+	}
+	header := h.headers.Clone()
+	header.Set("Capsule-Protocol", "?1")
+	request := &http.Request{
+		Method: http.MethodConnect,
+		Proto:  "connect-udp",
+		URL:    requestURL,
+		Host:   requestURL.Host,
+		Header: header,
+	}
+	conn, stream, err := h.roundTrip(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	return &datagramConn{
+		StreamWrapper: v2rayquic.StreamWrapper{Conn: conn, Stream: stream},
+		stream:        stream,
+		destination:   destination,
+	}, nil
+}
+
+func (h *Outbound) roundTrip(ctx context.Context, request *http.Request) (quic.Connection, http3.RequestStream, error) {
+	conn, clientConn, err := h.offer()
+	if err != nil {
+		return nil, nil, err
+	}
+	if request.Proto != "" {
+		select {
+		case <-clientConn.ReceivedSettings():
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		case <-conn.Context().Done():
+			return nil, nil, context.Cause(conn.Context())
+		}
+		settings := clientConn.Settings()
+		if !settings.EnableExtendedConnect || !settings.EnableDatagrams {
+			return nil, nil, E.New("server does not support UDP proxying")
+		}
+	}
+	stream, err := clientConn.OpenRequestStream(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = stream.SendRequestHeader(request)
+	if err != nil {
+		stream.CancelRead(0)
+		stream.Close()
+		return nil, nil, err
+	}
+	if deadline, loaded := ctx.Deadline(); loaded {
+		stream.SetReadDeadline(deadline)
+	}
+	response, err := stream.ReadResponse()
+	stream.SetReadDeadline(time.Time{})
+	if err != nil {
+		stream.CancelRead(0)
+		stream.Close()
+		return nil, nil, err
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		stream.CancelRead(0)
+		stream.Close()
+		return nil, nil, E.New(request.Method, " ", request.Host, ": ", response.Status)
+	}
+	return conn, stream, nil
+}
+
+func (h *Outbound) InterfaceUpdated() {
+	h.connAccess.Lock()
+	defer h.connAccess.Unlock()
+	h.closeConn()
+}
+
+func (h *Outbound) Close() error {
+	h.connAccess.Lock()
+	defer h.connAccess.Unlock()
+	h.closeConn()
+	return nil
+}
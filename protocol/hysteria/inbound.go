@@ -13,6 +13,7 @@ import (
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/log"
 	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/transport/faketcp"
 	"github.com/sagernet/sing-quic/hysteria"
 	"github.com/sagernet/sing/common"
 	"github.com/sagernet/sing/common/auth"
@@ -31,6 +32,7 @@ type Inbound struct {
 	logger       log.ContextLogger
 	listener     *listener.Listener
 	tlsConfig    tls.ServerConfig
+	protocol     string
 	service      *hysteria.Service[int]
 	userNameList []string
 }
@@ -40,6 +42,10 @@ func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLo
 	if options.TLS == nil || !options.TLS.Enabled {
 		return nil, C.ErrTLSRequired
 	}
+	protocol, err := parseProtocol(options.Protocol)
+	if err != nil {
+		return nil, err
+	}
 	tlsConfig, err := tls.NewServer(ctx, logger, common.PtrValueOrDefault(options.TLS))
 	if err != nil {
 		return nil, err
@@ -54,6 +60,7 @@ func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLo
 			Listen:  options.ListenOptions,
 		}),
 		tlsConfig: tlsConfig,
+		protocol:  protocol,
 	}
 	var sendBps, receiveBps uint64
 	if len(options.Up) > 0 {
@@ -76,7 +83,7 @@ func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLo
 	if options.UDPTimeout != 0 {
 		udpTimeout = time.Duration(options.UDPTimeout)
 	} else {
-		udpTimeout = C.UDPTimeout
+		udpTimeout = adapter.TimeoutsFromContext(ctx).UDPNAT
 	}
 	service, err := hysteria.NewService[int](hysteria.ServiceOptions{
 		Context:       ctx,
@@ -174,10 +181,26 @@ func (h *Inbound) Start(stage adapter.StartStage) error {
 			return err
 		}
 	}
-	packetConn, err := h.listener.ListenUDP()
+	var (
+		packetConn net.PacketConn
+		err        error
+	)
+	if h.protocol == C.HysteriaProtocolFakeTCP {
+		var tcpListener net.Listener
+		tcpListener, err = h.listener.ListenTCP()
+		if err != nil {
+			return err
+		}
+		packetConn, err = faketcp.Listen(tcpListener, h.logger)
+	} else {
+		packetConn, err = h.listener.ListenUDP()
+	}
 	if err != nil {
 		return err
 	}
+	if h.protocol == C.HysteriaProtocolWeChatVideo {
+		packetConn = newWeChatPacketConn(packetConn)
+	}
 	return h.service.Start(packetConn)
 }
 
@@ -43,14 +43,24 @@ func NewOutbound(ctx context.Context, router adapter.Router, logger log.ContextL
 	if options.TLS == nil || !options.TLS.Enabled {
 		return nil, C.ErrTLSRequired
 	}
+	protocol, err := parseProtocol(options.Protocol)
+	if err != nil {
+		return nil, err
+	}
 	tlsConfig, err := tls.NewClient(ctx, options.Server, common.PtrValueOrDefault(options.TLS))
 	if err != nil {
 		return nil, err
 	}
-	outboundDialer, err := dialer.New(ctx, options.DialerOptions)
+	outboundDialer, err := dialer.NewServer(ctx, options.DialerOptions, options.ServerOptions.Build())
 	if err != nil {
 		return nil, err
 	}
+	switch protocol {
+	case C.HysteriaProtocolWeChatVideo:
+		outboundDialer = &wechatDialer{outboundDialer}
+	case C.HysteriaProtocolFakeTCP:
+		outboundDialer = &fakeTCPDialer{outboundDialer, logger}
+	}
 	networkList := options.Network.Build()
 	var password string
 	if options.AuthString != "" {
@@ -0,0 +1,43 @@
+package hysteria
+
+import (
+	"context"
+	"net"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/transport/faketcp"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/logger"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+func parseProtocol(protocol string) (string, error) {
+	switch protocol {
+	case "", C.HysteriaProtocolUDP:
+		return C.HysteriaProtocolUDP, nil
+	case C.HysteriaProtocolWeChatVideo:
+		return protocol, nil
+	case C.HysteriaProtocolFakeTCP:
+		if !C.IsLinux {
+			return "", E.New("protocol faketcp is only supported on Linux")
+		}
+		return protocol, nil
+	default:
+		return "", E.New("unknown protocol: ", protocol)
+	}
+}
+
+// fakeTCPDialer carries the UDP connections of the client in the segments of
+// a TCP connection.
+type fakeTCPDialer struct {
+	N.Dialer
+	logger logger.ContextLogger
+}
+
+func (d *fakeTCPDialer) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	if N.NetworkName(network) != N.NetworkUDP {
+		return d.Dialer.DialContext(ctx, network, destination)
+	}
+	return faketcp.Dial(ctx, d.Dialer, destination, d.logger)
+}
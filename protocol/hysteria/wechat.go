@@ -0,0 +1,164 @@
+package hysteria
+
+import (
+	"context"
+	"encoding/binary"
+	"math/rand"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/sagernet/sing/common/buf"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+// wechatHeaderLen is the length of the fake WeChat video call header used by
+// the wechat-video protocol of Hysteria v1.
+const wechatHeaderLen = 13
+
+type wechatHeader struct {
+	sequence atomic.Uint32
+}
+
+func newWeChatHeader() *wechatHeader {
+	var header wechatHeader
+	header.sequence.Store(rand.Uint32())
+	return &header
+}
+
+func (h *wechatHeader) writeTo(buffer *buf.Buffer) {
+	header := buffer.Extend(wechatHeaderLen)
+	header[0] = 0xa1
+	header[1] = 0x08
+	binary.BigEndian.PutUint32(header[2:], h.sequence.Add(1)&0xffff)
+	copy(header[6:], []byte{0x00, 0x10, 0x11, 0x18, 0x30, 0x22, 0x30})
+}
+
+func (h *wechatHeader) pack(p []byte) *buf.Buffer {
+	buffer := buf.NewSize(wechatHeaderLen + len(p))
+	h.writeTo(buffer)
+	buffer.Write(p)
+	return buffer
+}
+
+// The wrapped connections do not expose the underlying connection, so
+// vectorised writes of the upper layer can not skip the header.
+
+type wechatPacketConn struct {
+	conn   net.PacketConn
+	header *wechatHeader
+}
+
+func newWeChatPacketConn(conn net.PacketConn) net.PacketConn {
+	return &wechatPacketConn{conn: conn, header: newWeChatHeader()}
+}
+
+func (c *wechatPacketConn) ReadFrom(p []byte) (n int, addr net.Addr, err error) {
+	for {
+		n, addr, err = c.conn.ReadFrom(p)
+		if err != nil {
+			return
+		} else if n <= wechatHeaderLen {
+			continue
+		}
+		n = copy(p, p[wechatHeaderLen:n])
+		return
+	}
+}
+
+func (c *wechatPacketConn) WriteTo(p []byte, addr net.Addr) (n int, err error) {
+	buffer := c.header.pack(p)
+	defer buffer.Release()
+	_, err = c.conn.WriteTo(buffer.Bytes(), addr)
+	if err != nil {
+		return
+	}
+	return len(p), nil
+}
+
+func (c *wechatPacketConn) Close() error {
+	return c.conn.Close()
+}
+
+func (c *wechatPacketConn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+func (c *wechatPacketConn) SetDeadline(t time.Time) error {
+	return c.conn.SetDeadline(t)
+}
+
+func (c *wechatPacketConn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+func (c *wechatPacketConn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
+
+type wechatConn struct {
+	conn   net.Conn
+	header *wechatHeader
+}
+
+func (c *wechatConn) Read(p []byte) (n int, err error) {
+	for {
+		n, err = c.conn.Read(p)
+		if err != nil {
+			return
+		} else if n <= wechatHeaderLen {
+			continue
+		}
+		n = copy(p, p[wechatHeaderLen:n])
+		return
+	}
+}
+
+func (c *wechatConn) Write(p []byte) (n int, err error) {
+	buffer := c.header.pack(p)
+	defer buffer.Release()
+	_, err = c.conn.Write(buffer.Bytes())
+	if err != nil {
+		return
+	}
+	return len(p), nil
+}
+
+func (c *wechatConn) Close() error {
+	return c.conn.Close()
+}
+
+func (c *wechatConn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+func (c *wechatConn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+func (c *wechatConn) SetDeadline(t time.Time) error {
+	return c.conn.SetDeadline(t)
+}
+
+func (c *wechatConn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+func (c *wechatConn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
+
+// wechatDialer wraps UDP connections of the client with the wechat-video
+// header.
+type wechatDialer struct {
+	N.Dialer
+}
+
+func (d *wechatDialer) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	conn, err := d.Dialer.DialContext(ctx, network, destination)
+	if err != nil || N.NetworkName(network) != N.NetworkUDP {
+		return conn, err
+	}
+	return &wechatConn{conn: conn, header: newWeChatHeader()}, nil
+}
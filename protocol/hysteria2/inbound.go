@@ -12,6 +12,7 @@ import (
 	"github.com/sagernet/sing-box/adapter/inbound"
 	"github.com/sagernet/sing-box/common/listener"
 	"github.com/sagernet/sing-box/common/tls"
+	"github.com/sagernet/sing-box/common/userquota"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/log"
 	"github.com/sagernet/sing-box/option"
@@ -30,12 +31,14 @@ func RegisterInbound(registry *inbound.Registry) {
 
 type Inbound struct {
 	inbound.Adapter
-	router       adapter.Router
-	logger       log.ContextLogger
-	listener     *listener.Listener
-	tlsConfig    tls.ServerConfig
-	service      *hysteria2.Service[int]
-	userNameList []string
+	router           adapter.Router
+	logger           log.ContextLogger
+	listener         *listener.Listener
+	tlsConfig        tls.ServerConfig
+	service          *hysteria2.Service[int]
+	userNameList     []string
+	userPasswordList []string
+	userQuota        *userquota.Manager
 }
 
 func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.Hysteria2InboundOptions) (adapter.Inbound, error) {
@@ -111,7 +114,7 @@ func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLo
 	if options.UDPTimeout != 0 {
 		udpTimeout = time.Duration(options.UDPTimeout)
 	} else {
-		udpTimeout = C.UDPTimeout
+		udpTimeout = adapter.TimeoutsFromContext(ctx).UDPNAT
 	}
 	service, err := hysteria2.NewService[int](hysteria2.ServiceOptions{
 		Context:               ctx,
@@ -129,20 +132,43 @@ func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLo
 	if err != nil {
 		return nil, err
 	}
-	userList := make([]int, 0, len(options.Users))
 	userNameList := make([]string, 0, len(options.Users))
 	userPasswordList := make([]string, 0, len(options.Users))
-	for index, user := range options.Users {
-		userList = append(userList, index)
+	userQuotaList := make([]option.UserQuotaOptions, 0, len(options.Users))
+	for _, user := range options.Users {
 		userNameList = append(userNameList, user.Name)
 		userPasswordList = append(userPasswordList, user.Password)
+		userQuotaList = append(userQuotaList, user.UserQuotaOptions)
 	}
-	service.UpdateUsers(userList, userPasswordList)
 	inbound.service = service
 	inbound.userNameList = userNameList
+	inbound.userPasswordList = userPasswordList
+	inbound.userQuota, err = userquota.NewManager(ctx, logger, tag, userNameList, userQuotaList)
+	if err != nil {
+		return nil, err
+	}
 	return inbound, nil
 }
 
+// updateUsers sets users to the service once at start, excluding users that
+// exceeded their quota or expired. Users disabled later are rejected by the
+// Available check of new connections, as the service does not support
+// updating users while running.
+func (h *Inbound) updateUsers() {
+	var (
+		userList         []int
+		userPasswordList []string
+	)
+	for index := range h.userNameList {
+		if !h.userQuota.Available(index) {
+			continue
+		}
+		userList = append(userList, index)
+		userPasswordList = append(userPasswordList, h.userPasswordList[index])
+	}
+	h.service.UpdateUsers(userList, userPasswordList)
+}
+
 func (h *Inbound) NewConnectionEx(ctx context.Context, conn net.Conn, source M.Socksaddr, destination M.Socksaddr, onClose N.CloseHandlerFunc) {
 	ctx = log.ContextWithNewID(ctx)
 	var metadata adapter.InboundContext
@@ -163,6 +189,11 @@ func (h *Inbound) NewConnectionEx(ctx context.Context, conn net.Conn, source M.S
 	} else {
 		h.logger.InfoContext(ctx, "inbound connection to ", metadata.Destination)
 	}
+	if !h.userQuota.Available(userID) {
+		N.CloseOnHandshakeFailure(conn, onClose, E.New("user ", metadata.User, " exceeded quota or expired"))
+		return
+	}
+	conn, onClose = h.userQuota.NewConn(userID, conn, onClose)
 	h.router.RouteConnectionEx(ctx, conn, metadata, onClose)
 }
 
@@ -186,6 +217,11 @@ func (h *Inbound) NewPacketConnectionEx(ctx context.Context, conn N.PacketConn,
 	} else {
 		h.logger.InfoContext(ctx, "inbound packet connection to ", metadata.Destination)
 	}
+	if !h.userQuota.Available(userID) {
+		N.CloseOnHandshakeFailure(conn, onClose, E.New("user ", metadata.User, " exceeded quota or expired"))
+		return
+	}
+	conn, onClose = h.userQuota.NewPacketConn(userID, conn, onClose)
 	h.router.RoutePacketConnectionEx(ctx, conn, metadata, onClose)
 }
 
@@ -199,6 +235,11 @@ func (h *Inbound) Start(stage adapter.StartStage) error {
 			return err
 		}
 	}
+	err := h.userQuota.Start()
+	if err != nil {
+		return err
+	}
+	h.updateUsers()
 	packetConn, err := h.listener.ListenUDP()
 	if err != nil {
 		return err
@@ -211,5 +252,6 @@ func (h *Inbound) Close() error {
 		h.listener,
 		h.tlsConfig,
 		common.PtrOrNil(h.service),
+		common.PtrOrNil(h.userQuota),
 	)
 }
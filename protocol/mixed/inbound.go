@@ -8,10 +8,12 @@ import (
 	"github.com/sagernet/sing-box/adapter"
 	"github.com/sagernet/sing-box/adapter/inbound"
 	"github.com/sagernet/sing-box/common/listener"
+	"github.com/sagernet/sing-box/common/proxyauth"
 	"github.com/sagernet/sing-box/common/uot"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/log"
 	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
 	"github.com/sagernet/sing/common/auth"
 	E "github.com/sagernet/sing/common/exceptions"
 	N "github.com/sagernet/sing/common/network"
@@ -29,27 +31,38 @@ var _ adapter.TCPInjectableInbound = (*Inbound)(nil)
 
 type Inbound struct {
 	inbound.Adapter
-	router        adapter.ConnectionRouterEx
-	logger        log.ContextLogger
-	listener      *listener.Listener
-	authenticator *auth.Authenticator
+	router         adapter.ConnectionRouterEx
+	logger         log.ContextLogger
+	listener       *listener.Listener
+	authenticator  *auth.Authenticator
+	externalAuth   *proxyauth.Authenticator
+	udpNATStrategy C.UDPNATStrategy
 }
 
 func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.HTTPMixedInboundOptions) (adapter.Inbound, error) {
 	inbound := &Inbound{
-		Adapter:       inbound.NewAdapter(C.TypeMixed, tag),
-		router:        uot.NewRouter(router, logger),
-		logger:        logger,
-		authenticator: auth.NewAuthenticator(options.Users),
+		Adapter:        inbound.NewAdapter(C.TypeMixed, tag),
+		router:         uot.NewRouter(router, logger),
+		logger:         logger,
+		authenticator:  auth.NewAuthenticator(options.Users),
+		udpNATStrategy: C.UDPNATStrategy(options.UDPNATStrategy),
+	}
+	if options.ExternalAuth != nil {
+		externalAuth, err := proxyauth.New(ctx, logger, options.Users, *options.ExternalAuth)
+		if err != nil {
+			return nil, err
+		}
+		inbound.externalAuth = externalAuth
 	}
 	inbound.listener = listener.New(listener.Options{
-		Context:           ctx,
-		Logger:            logger,
-		Network:           []string{N.NetworkTCP},
-		Listen:            options.ListenOptions,
-		ConnectionHandler: inbound,
-		SetSystemProxy:    options.SetSystemProxy,
-		SystemProxySOCKS:  true,
+		Context:             ctx,
+		Logger:              logger,
+		Network:             []string{N.NetworkTCP},
+		Listen:              options.ListenOptions,
+		AcceptProxyProtocol: options.AcceptProxyProtocol,
+		ConnectionHandler:   inbound,
+		SetSystemProxy:      options.SetSystemProxy,
+		SystemProxySOCKS:    true,
 	})
 	return inbound, nil
 }
@@ -58,11 +71,20 @@ func (h *Inbound) Start(stage adapter.StartStage) error {
 	if stage != adapter.StartStateStart {
 		return nil
 	}
+	if h.externalAuth != nil {
+		err := h.externalAuth.Start()
+		if err != nil {
+			return err
+		}
+	}
 	return h.listener.Start()
 }
 
 func (h *Inbound) Close() error {
-	return h.listener.Close()
+	return common.Close(
+		h.listener,
+		common.PtrOrNil(h.externalAuth),
+	)
 }
 
 func (h *Inbound) NewConnectionEx(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
@@ -83,11 +105,22 @@ func (h *Inbound) newConnection(ctx context.Context, conn net.Conn, metadata ada
 	if err != nil {
 		return E.Cause(err, "peek first byte")
 	}
+	authenticator := h.authenticator
 	switch headerBytes[0] {
 	case socks4.Version, socks5.Version:
-		return socks.HandleConnectionEx(ctx, conn, reader, h.authenticator, adapter.NewUpstreamHandlerEx(metadata, h.newUserConnection, h.streamUserPacketConnection), metadata.Source, onClose)
+		if h.externalAuth != nil {
+			ctx, conn, reader, err = h.externalAuth.HandshakeSOCKS(ctx, conn, metadata.Source, reader)
+			if err != nil {
+				return err
+			}
+			authenticator = nil
+		}
+		return socks.HandleConnectionEx(ctx, conn, reader, authenticator, adapter.NewUpstreamHandlerEx(metadata, h.newUserConnection, h.streamUserPacketConnection), metadata.Source, onClose)
 	default:
-		return http.HandleConnectionEx(ctx, conn, reader, h.authenticator, adapter.NewUpstreamHandlerEx(metadata, h.newUserConnection, h.streamUserPacketConnection), metadata.Source, onClose)
+		if h.externalAuth != nil {
+			authenticator = h.externalAuth.HTTPAuthenticator(ctx, metadata.Source, reader)
+		}
+		return http.HandleConnectionEx(ctx, conn, reader, authenticator, adapter.NewUpstreamHandlerEx(metadata, h.newUserConnection, h.streamUserPacketConnection), metadata.Source, onClose)
 	}
 }
 
@@ -108,6 +141,7 @@ func (h *Inbound) newUserConnection(ctx context.Context, conn net.Conn, metadata
 func (h *Inbound) streamUserPacketConnection(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
 	metadata.Inbound = h.Tag()
 	metadata.InboundType = h.Type()
+	metadata.UDPNATStrategy = h.udpNATStrategy
 	user, loaded := auth.UserFromContext[string](ctx)
 	if !loaded {
 		if !metadata.Destination.IsValid() {
@@ -6,6 +6,9 @@ import (
 	"math/rand"
 	"net"
 	"net/http"
+	"net/http/httputil"
+	"strconv"
+	"strings"
 
 	"github.com/sagernet/sing-box/adapter"
 	"github.com/sagernet/sing-box/adapter/inbound"
@@ -19,6 +22,7 @@ import (
 	"github.com/sagernet/sing/common"
 	"github.com/sagernet/sing/common/auth"
 	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
 	"github.com/sagernet/sing/common/logger"
 	M "github.com/sagernet/sing/common/metadata"
 	N "github.com/sagernet/sing/common/network"
@@ -43,6 +47,7 @@ type Inbound struct {
 	tlsConfig        tls.ServerConfig
 	httpServer       *http.Server
 	h3Server         io.Closer
+	fallback         *httputil.ReverseProxy
 }
 
 func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.NaiveInboundOptions) (adapter.Inbound, error) {
@@ -75,6 +80,27 @@ func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLo
 		}
 		inbound.tlsConfig = tlsConfig
 	}
+	if options.Fallback != nil && options.Fallback.Server != "" {
+		fallbackAddr := options.Fallback.Build()
+		if !fallbackAddr.IsValid() || fallbackAddr.Port == 0 {
+			return nil, E.New("invalid fallback address: ", fallbackAddr)
+		}
+		inbound.fallback = &httputil.ReverseProxy{
+			Director: func(request *http.Request) {
+				request.URL.Scheme = "http"
+				request.URL.Host = fallbackAddr.String()
+			},
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+					return N.SystemDialer.DialContext(ctx, N.NetworkTCP, fallbackAddr)
+				},
+			},
+			ErrorHandler: func(writer http.ResponseWriter, request *http.Request, err error) {
+				logger.ErrorContext(request.Context(), E.Cause(err, "fallback request from ", request.RemoteAddr))
+				writer.WriteHeader(http.StatusBadGateway)
+			},
+		}
+	}
 	return inbound, nil
 }
 
@@ -144,12 +170,16 @@ func (n *Inbound) Close() error {
 func (n *Inbound) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
 	ctx := log.ContextWithNewID(request.Context())
 	if request.Method != "CONNECT" {
-		rejectHTTP(writer, http.StatusBadRequest)
-		n.badRequest(ctx, request, E.New("not CONNECT request"))
+		n.reject(ctx, writer, request, http.StatusBadRequest, E.New("not CONNECT request"))
 		return
-	} else if request.Header.Get("Padding") == "" {
-		rejectHTTP(writer, http.StatusBadRequest)
-		n.badRequest(ctx, request, E.New("missing naive padding"))
+	}
+	if request.Header.Get("Padding") == "" {
+		n.reject(ctx, writer, request, http.StatusBadRequest, E.New("missing naive padding"))
+		return
+	}
+	paddingType, paddingOk := negotiatePaddingType(request)
+	if !paddingOk {
+		n.reject(ctx, writer, request, http.StatusBadRequest, E.New("unsupported naive padding type: ", request.Header.Get("Padding-Type-Request")))
 		return
 	}
 	userName, password, authOk := sHttp.ParseBasicAuth(request.Header.Get("Proxy-Authorization"))
@@ -157,11 +187,13 @@ func (n *Inbound) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
 		authOk = n.authenticator.Verify(userName, password)
 	}
 	if !authOk {
-		rejectHTTP(writer, http.StatusProxyAuthRequired)
-		n.badRequest(ctx, request, E.New("authorization failed"))
+		n.reject(ctx, writer, request, http.StatusProxyAuthRequired, E.New("authorization failed"))
 		return
 	}
 	writer.Header().Set("Padding", generateNaivePaddingHeader())
+	if request.Header.Get("Padding-Type-Request") != "" {
+		writer.Header().Set("Padding-Type-Reply", strconv.Itoa(paddingType))
+	}
 	writer.WriteHeader(http.StatusOK)
 	writer.(http.Flusher).Flush()
 
@@ -178,9 +210,19 @@ func (n *Inbound) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
 			n.badRequest(ctx, request, E.New("hijack failed"))
 			return
 		}
-		n.newConnection(ctx, false, &naiveH1Conn{Conn: conn}, userName, source, destination)
+		h1Conn := &naiveH1Conn{Conn: conn}
+		if paddingType == paddingTypeNone {
+			h1Conn.readPadding = kFirstPaddings
+			h1Conn.writePadding = kFirstPaddings
+		}
+		n.newConnection(ctx, false, h1Conn, userName, source, destination)
 	} else {
-		n.newConnection(ctx, true, &naiveH2Conn{reader: request.Body, writer: writer, flusher: writer.(http.Flusher)}, userName, source, destination)
+		h2Conn := &naiveH2Conn{reader: request.Body, writer: writer, flusher: writer.(http.Flusher)}
+		if paddingType == paddingTypeNone {
+			h2Conn.readPadding = kFirstPaddings
+			h2Conn.writePadding = kFirstPaddings
+		}
+		n.newConnection(ctx, true, h2Conn, userName, source, destination)
 	}
 }
 
@@ -220,6 +262,20 @@ func (n *Inbound) badRequest(ctx context.Context, request *http.Request, err err
 	n.logger.ErrorContext(ctx, E.Cause(err, "process connection from ", request.RemoteAddr))
 }
 
+// reject forwards the request to the fallback server if configured,
+// so that probes see the same responses as from the origin.
+func (n *Inbound) reject(ctx context.Context, writer http.ResponseWriter, request *http.Request, statusCode int, err error) {
+	n.badRequest(ctx, request, err)
+	if n.fallback != nil {
+		if n.h3Server != nil && request.ProtoMajor < 3 {
+			writer.Header().Set("Alt-Svc", "h3=\":"+F.ToString(n.listener.ListenOptions().ListenPort)+"\"; ma=2592000")
+		}
+		n.fallback.ServeHTTP(writer, request)
+		return
+	}
+	rejectHTTP(writer, statusCode)
+}
+
 func rejectHTTP(writer http.ResponseWriter, statusCode int) {
 	hijacker, ok := writer.(http.Hijacker)
 	if !ok {
@@ -237,6 +293,31 @@ func rejectHTTP(writer http.ResponseWriter, statusCode int) {
 	conn.Close()
 }
 
+const (
+	paddingTypeNone     = 0
+	paddingTypeVariant1 = 1
+)
+
+// negotiatePaddingType selects the first supported type listed in Padding-Type-Request,
+// clients without the header use the variant1 padding.
+func negotiatePaddingType(request *http.Request) (int, bool) {
+	typeRequest := request.Header.Get("Padding-Type-Request")
+	if typeRequest == "" {
+		return paddingTypeVariant1, true
+	}
+	for _, typeString := range strings.Split(typeRequest, ",") {
+		paddingType, err := strconv.Atoi(strings.TrimSpace(typeString))
+		if err != nil {
+			continue
+		}
+		switch paddingType {
+		case paddingTypeNone, paddingTypeVariant1:
+			return paddingType, true
+		}
+	}
+	return 0, false
+}
+
 func generateNaivePaddingHeader() string {
 	paddingLen := rand.Intn(32) + 30
 	padding := make([]byte, paddingLen)
@@ -0,0 +1,55 @@
+package naive
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sagernet/sing/common/auth"
+	"github.com/sagernet/sing/common/logger"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiatePaddingType(t *testing.T) {
+	t.Parallel()
+	for _, testCase := range []struct {
+		typeRequest string
+		paddingType int
+		ok          bool
+	}{
+		{"", paddingTypeVariant1, true},
+		{"0", paddingTypeNone, true},
+		{"1, 0", paddingTypeVariant1, true},
+		{"x, 0", paddingTypeNone, true},
+		{"2", 0, false},
+	} {
+		request := httptest.NewRequest(http.MethodConnect, "https://example.org:443", nil)
+		request.Header.Set("Padding", "~~~~")
+		if testCase.typeRequest != "" {
+			request.Header.Set("Padding-Type-Request", testCase.typeRequest)
+		}
+		paddingType, ok := negotiatePaddingType(request)
+		require.Equal(t, testCase.ok, ok, testCase.typeRequest)
+		require.Equal(t, testCase.paddingType, paddingType, testCase.typeRequest)
+	}
+}
+
+func TestInboundRejectsMissingPadding(t *testing.T) {
+	t.Parallel()
+	inbound := &Inbound{
+		logger:        logger.NOP(),
+		authenticator: auth.NewAuthenticator([]auth.User{{Username: "user", Password: "password"}}),
+	}
+	for _, typeRequest := range []string{"", "0"} {
+		request := httptest.NewRequest(http.MethodConnect, "https://example.org:443", nil)
+		request.SetBasicAuth("user", "password")
+		request.Header.Set("Proxy-Authorization", request.Header.Get("Authorization"))
+		if typeRequest != "" {
+			request.Header.Set("Padding-Type-Request", typeRequest)
+		}
+		recorder := httptest.NewRecorder()
+		inbound.ServeHTTP(recorder, request)
+		require.Equal(t, http.StatusBadRequest, recorder.Code)
+	}
+}
@@ -29,25 +29,27 @@ func RegisterTProxy(registry *inbound.Registry) {
 
 type TProxy struct {
 	inbound.Adapter
-	ctx      context.Context
-	router   adapter.Router
-	logger   log.ContextLogger
-	listener *listener.Listener
-	udpNat   *udpnat.Service
+	ctx            context.Context
+	router         adapter.Router
+	logger         log.ContextLogger
+	listener       *listener.Listener
+	udpNat         *udpnat.Service
+	udpNATStrategy C.UDPNATStrategy
 }
 
 func NewTProxy(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.TProxyInboundOptions) (adapter.Inbound, error) {
 	tproxy := &TProxy{
-		Adapter: inbound.NewAdapter(C.TypeTProxy, tag),
-		ctx:     ctx,
-		router:  router,
-		logger:  logger,
+		Adapter:        inbound.NewAdapter(C.TypeTProxy, tag),
+		ctx:            ctx,
+		router:         router,
+		logger:         logger,
+		udpNATStrategy: C.UDPNATStrategy(options.UDPNATStrategy),
 	}
 	var udpTimeout time.Duration
 	if options.UDPTimeout != 0 {
 		udpTimeout = time.Duration(options.UDPTimeout)
 	} else {
-		udpTimeout = C.UDPTimeout
+		udpTimeout = adapter.TimeoutsFromContext(ctx).UDPNAT
 	}
 	tproxy.udpNat = udpnat.New(tproxy, tproxy.preparePacketConnection, udpTimeout, false)
 	tproxy.listener = listener.New(listener.Options{
@@ -69,7 +71,7 @@ func (t *TProxy) Start(stage adapter.StartStage) error {
 	if err != nil {
 		return err
 	}
-	if listener := t.listener.TCPListener(); listener != nil {
+	for _, listener := range t.listener.TCPListeners() {
 		err = control.Conn(common.MustCast[syscall.Conn](listener), func(fd uintptr) error {
 			return redir.TProxy(fd, M.SocksaddrFromNet(listener.Addr()).Addr.Is6())
 		})
@@ -109,6 +111,7 @@ func (t *TProxy) NewPacketConnectionEx(ctx context.Context, conn N.PacketConn, s
 	metadata.Source = source
 	metadata.Destination = destination
 	metadata.OriginDestination = t.listener.UDPAddr()
+	metadata.UDPNATStrategy = t.udpNATStrategy
 	t.router.RoutePacketConnectionEx(ctx, conn, metadata, onClose)
 }
 
@@ -13,6 +13,7 @@ import (
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/log"
 	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/transport/sip003"
 	"github.com/sagernet/sing-shadowsocks"
 	"github.com/sagernet/sing-shadowsocks/shadowaead"
 	"github.com/sagernet/sing-shadowsocks/shadowaead_2022"
@@ -46,11 +47,13 @@ var _ adapter.TCPInjectableInbound = (*Inbound)(nil)
 
 type Inbound struct {
 	inbound.Adapter
-	ctx      context.Context
-	router   adapter.ConnectionRouterEx
-	logger   logger.ContextLogger
-	listener *listener.Listener
-	service  shadowsocks.Service
+	ctx          context.Context
+	router       adapter.ConnectionRouterEx
+	logger       logger.ContextLogger
+	listener     *listener.Listener
+	service      shadowsocks.Service
+	probeDefense *probeDefense
+	plugin       sip003.ServerPlugin
 }
 
 func newInbound(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.ShadowsocksInboundOptions) (*Inbound, error) {
@@ -65,11 +68,19 @@ func newInbound(ctx context.Context, router adapter.Router, logger log.ContextLo
 	if err != nil {
 		return nil, err
 	}
+	inbound.probeDefense, err = newProbeDefense(options)
+	if err != nil {
+		return nil, err
+	}
+	inbound.plugin, err = newPlugin(ctx, logger, options, inbound.newConnectionEx)
+	if err != nil {
+		return nil, err
+	}
 	var udpTimeout time.Duration
 	if options.UDPTimeout != 0 {
 		udpTimeout = time.Duration(options.UDPTimeout)
 	} else {
-		udpTimeout = C.UDPTimeout
+		udpTimeout = adapter.TimeoutsFromContext(ctx).UDPNAT
 	}
 	switch {
 	case options.Method == shadowsocks.MethodNone:
@@ -86,6 +97,7 @@ func newInbound(ctx context.Context, router adapter.Router, logger log.ContextLo
 		Logger:                   logger,
 		Network:                  options.Network.Build(),
 		Listen:                   options.ListenOptions,
+		AcceptProxyProtocol:      options.AcceptProxyProtocol,
 		ConnectionHandler:        inbound,
 		PacketHandler:            inbound,
 		ThreadUnsafePacketWriter: true,
@@ -97,16 +109,35 @@ func (h *Inbound) Start(stage adapter.StartStage) error {
 	if stage != adapter.StartStateStart {
 		return nil
 	}
+	if h.plugin != nil {
+		err := h.plugin.Start()
+		if err != nil {
+			return err
+		}
+	}
 	return h.listener.Start()
 }
 
 func (h *Inbound) Close() error {
-	return h.listener.Close()
+	return common.Close(
+		h.listener,
+		h.plugin,
+	)
 }
 
-//nolint:staticcheck
 func (h *Inbound) NewConnectionEx(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
-	err := h.service.NewConnection(ctx, conn, adapter.UpstreamMetadata(metadata))
+	if h.plugin != nil {
+		h.plugin.NewConnectionEx(contextWithPluginMetadata(ctx, metadata), conn, metadata.Source, onClose)
+		return
+	}
+	h.newConnectionEx(ctx, conn, metadata, onClose)
+}
+
+//nolint:staticcheck
+func (h *Inbound) newConnectionEx(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
+	err := h.probeDefense.NewConnection(ctx, conn, func(ctx context.Context, conn net.Conn) error {
+		return h.service.NewConnection(ctx, conn, adapter.UpstreamMetadata(metadata))
+	})
 	N.CloseOnHandshakeFailure(conn, onClose, err)
 	if err != nil {
 		if E.IsClosedOrCanceled(err) {
@@ -126,6 +157,7 @@ func (h *Inbound) NewPacketEx(buffer *buf.Buffer, source M.Socksaddr) {
 }
 
 func (h *Inbound) newConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) error {
+	markHandshakeDone(ctx)
 	h.logger.InfoContext(ctx, "inbound connection to ", metadata.Destination)
 	metadata.Inbound = h.Tag()
 	metadata.InboundType = h.Type()
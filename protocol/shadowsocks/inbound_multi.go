@@ -14,6 +14,7 @@ import (
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/log"
 	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/transport/sip003"
 	"github.com/sagernet/sing-shadowsocks"
 	"github.com/sagernet/sing-shadowsocks/shadowaead"
 	"github.com/sagernet/sing-shadowsocks/shadowaead_2022"
@@ -32,12 +33,14 @@ var _ adapter.TCPInjectableInbound = (*MultiInbound)(nil)
 
 type MultiInbound struct {
 	inbound.Adapter
-	ctx      context.Context
-	router   adapter.ConnectionRouterEx
-	logger   logger.ContextLogger
-	listener *listener.Listener
-	service  shadowsocks.MultiService[int]
-	users    []option.ShadowsocksUser
+	ctx          context.Context
+	router       adapter.ConnectionRouterEx
+	logger       logger.ContextLogger
+	listener     *listener.Listener
+	service      shadowsocks.MultiService[int]
+	users        []option.ShadowsocksUser
+	probeDefense *probeDefense
+	plugin       sip003.ServerPlugin
 }
 
 func newMultiInbound(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.ShadowsocksInboundOptions) (*MultiInbound, error) {
@@ -52,11 +55,19 @@ func newMultiInbound(ctx context.Context, router adapter.Router, logger log.Cont
 	if err != nil {
 		return nil, err
 	}
+	inbound.probeDefense, err = newProbeDefense(options)
+	if err != nil {
+		return nil, err
+	}
+	inbound.plugin, err = newPlugin(ctx, logger, options, inbound.newConnectionEx)
+	if err != nil {
+		return nil, err
+	}
 	var udpTimeout time.Duration
 	if options.UDPTimeout != 0 {
 		udpTimeout = time.Duration(options.UDPTimeout)
 	} else {
-		udpTimeout = C.UDPTimeout
+		udpTimeout = adapter.TimeoutsFromContext(ctx).UDPNAT
 	}
 	var service shadowsocks.MultiService[int]
 	if common.Contains(shadowaead_2022.List, options.Method) {
@@ -94,6 +105,7 @@ func newMultiInbound(ctx context.Context, router adapter.Router, logger log.Cont
 		Logger:                   logger,
 		Network:                  options.Network.Build(),
 		Listen:                   options.ListenOptions,
+		AcceptProxyProtocol:      options.AcceptProxyProtocol,
 		ConnectionHandler:        inbound,
 		PacketHandler:            inbound,
 		ThreadUnsafePacketWriter: true,
@@ -105,16 +117,35 @@ func (h *MultiInbound) Start(stage adapter.StartStage) error {
 	if stage != adapter.StartStateStart {
 		return nil
 	}
+	if h.plugin != nil {
+		err := h.plugin.Start()
+		if err != nil {
+			return err
+		}
+	}
 	return h.listener.Start()
 }
 
 func (h *MultiInbound) Close() error {
-	return h.listener.Close()
+	return common.Close(
+		h.listener,
+		h.plugin,
+	)
 }
 
-//nolint:staticcheck
 func (h *MultiInbound) NewConnectionEx(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
-	err := h.service.NewConnection(ctx, conn, adapter.UpstreamMetadata(metadata))
+	if h.plugin != nil {
+		h.plugin.NewConnectionEx(contextWithPluginMetadata(ctx, metadata), conn, metadata.Source, onClose)
+		return
+	}
+	h.newConnectionEx(ctx, conn, metadata, onClose)
+}
+
+//nolint:staticcheck
+func (h *MultiInbound) newConnectionEx(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
+	err := h.probeDefense.NewConnection(ctx, conn, func(ctx context.Context, conn net.Conn) error {
+		return h.service.NewConnection(ctx, conn, adapter.UpstreamMetadata(metadata))
+	})
 	N.CloseOnHandshakeFailure(conn, onClose, err)
 	if err != nil {
 		if E.IsClosedOrCanceled(err) {
@@ -134,6 +165,7 @@ func (h *MultiInbound) NewPacketEx(buffer *buf.Buffer, source M.Socksaddr) {
 }
 
 func (h *MultiInbound) newConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) error {
+	markHandshakeDone(ctx)
 	userIndex, loaded := auth.UserFromContext[int](ctx)
 	if !loaded {
 		return os.ErrInvalid
@@ -14,6 +14,7 @@ import (
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/log"
 	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/transport/sip003"
 	"github.com/sagernet/sing-shadowsocks/shadowaead_2022"
 	"github.com/sagernet/sing/common"
 	"github.com/sagernet/sing/common/auth"
@@ -35,6 +36,8 @@ type RelayInbound struct {
 	listener     *listener.Listener
 	service      *shadowaead_2022.RelayService[int]
 	destinations []option.ShadowsocksDestination
+	probeDefense *probeDefense
+	plugin       sip003.ServerPlugin
 }
 
 func newRelayInbound(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.ShadowsocksInboundOptions) (*RelayInbound, error) {
@@ -50,11 +53,19 @@ func newRelayInbound(ctx context.Context, router adapter.Router, logger log.Cont
 	if err != nil {
 		return nil, err
 	}
+	inbound.probeDefense, err = newProbeDefense(options)
+	if err != nil {
+		return nil, err
+	}
+	inbound.plugin, err = newPlugin(ctx, logger, options, inbound.newConnectionEx)
+	if err != nil {
+		return nil, err
+	}
 	var udpTimeout time.Duration
 	if options.UDPTimeout != 0 {
 		udpTimeout = time.Duration(options.UDPTimeout)
 	} else {
-		udpTimeout = C.UDPTimeout
+		udpTimeout = adapter.TimeoutsFromContext(ctx).UDPNAT
 	}
 	service, err := shadowaead_2022.NewRelayServiceWithPassword[int](
 		options.Method,
@@ -79,6 +90,7 @@ func newRelayInbound(ctx context.Context, router adapter.Router, logger log.Cont
 		Logger:                   logger,
 		Network:                  options.Network.Build(),
 		Listen:                   options.ListenOptions,
+		AcceptProxyProtocol:      options.AcceptProxyProtocol,
 		ConnectionHandler:        inbound,
 		PacketHandler:            inbound,
 		ThreadUnsafePacketWriter: true,
@@ -90,16 +102,35 @@ func (h *RelayInbound) Start(stage adapter.StartStage) error {
 	if stage != adapter.StartStateStart {
 		return nil
 	}
+	if h.plugin != nil {
+		err := h.plugin.Start()
+		if err != nil {
+			return err
+		}
+	}
 	return h.listener.Start()
 }
 
 func (h *RelayInbound) Close() error {
-	return h.listener.Close()
+	return common.Close(
+		h.listener,
+		h.plugin,
+	)
 }
 
-//nolint:staticcheck
 func (h *RelayInbound) NewConnectionEx(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
-	err := h.service.NewConnection(ctx, conn, adapter.UpstreamMetadata(metadata))
+	if h.plugin != nil {
+		h.plugin.NewConnectionEx(contextWithPluginMetadata(ctx, metadata), conn, metadata.Source, onClose)
+		return
+	}
+	h.newConnectionEx(ctx, conn, metadata, onClose)
+}
+
+//nolint:staticcheck
+func (h *RelayInbound) newConnectionEx(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
+	err := h.probeDefense.NewConnection(ctx, conn, func(ctx context.Context, conn net.Conn) error {
+		return h.service.NewConnection(ctx, conn, adapter.UpstreamMetadata(metadata))
+	})
 	N.CloseOnHandshakeFailure(conn, onClose, err)
 	if err != nil {
 		if E.IsClosedOrCanceled(err) {
@@ -119,6 +150,7 @@ func (h *RelayInbound) NewPacketEx(buffer *buf.Buffer, source M.Socksaddr) {
 }
 
 func (h *RelayInbound) newConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) error {
+	markHandshakeDone(ctx)
 	destinationIndex, loaded := auth.UserFromContext[int](ctx)
 	if !loaded {
 		return os.ErrInvalid
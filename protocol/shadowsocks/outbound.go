@@ -3,6 +3,7 @@ package shadowsocks
 import (
 	"context"
 	"net"
+	"time"
 
 	"github.com/sagernet/sing-box/adapter"
 	"github.com/sagernet/sing-box/adapter/outbound"
@@ -34,6 +35,7 @@ type Outbound struct {
 	serverAddr      M.Socksaddr
 	plugin          sip003.Plugin
 	uotClient       *uot.Client
+	sharedPool      *sharedPacketPool
 	multiplexDialer *mux.Client
 }
 
@@ -44,7 +46,7 @@ func NewOutbound(ctx context.Context, router adapter.Router, logger log.ContextL
 	if err != nil {
 		return nil, err
 	}
-	outboundDialer, err := dialer.New(ctx, options.DialerOptions)
+	outboundDialer, err := dialer.NewServer(ctx, options.DialerOptions, options.ServerOptions.Build())
 	if err != nil {
 		return nil, err
 	}
@@ -74,6 +76,16 @@ func NewOutbound(ctx context.Context, router adapter.Router, logger log.ContextL
 			Version: uotOptions.Version,
 		}
 	}
+	switch options.UDPSession {
+	case "", UDPSessionSocket:
+	case UDPSessionShared:
+		if uotOptions.Enabled || outbound.multiplexDialer != nil {
+			return nil, E.New("shared UDP session is conflict with `udp_over_tcp` and `multiplex`")
+		}
+		outbound.sharedPool = newSharedPacketPool((*shadowsocksDialer)(outbound), time.Duration(options.UDPIdleTimeout))
+	default:
+		return nil, E.New("unknown UDP session mode: ", options.UDPSession)
+	}
 	return outbound, nil
 }
 
@@ -89,6 +101,13 @@ func (h *Outbound) DialContext(ctx context.Context, network string, destination
 			if h.uotClient != nil {
 				h.logger.InfoContext(ctx, "outbound UoT connect packet connection to ", destination)
 				return h.uotClient.DialContext(ctx, network, destination)
+			} else if h.sharedPool != nil {
+				h.logger.InfoContext(ctx, "outbound shared packet connection to ", destination)
+				packetConn, err := h.sharedPool.ListenPacket(ctx, destination)
+				if err != nil {
+					return nil, err
+				}
+				return bufio.NewBindPacketConn(packetConn, destination), nil
 			} else {
 				h.logger.InfoContext(ctx, "outbound packet connection to ", destination)
 			}
@@ -113,6 +132,9 @@ func (h *Outbound) ListenPacket(ctx context.Context, destination M.Socksaddr) (n
 		if h.uotClient != nil {
 			h.logger.InfoContext(ctx, "outbound UoT packet connection to ", destination)
 			return h.uotClient.ListenPacket(ctx, destination)
+		} else if h.sharedPool != nil {
+			h.logger.InfoContext(ctx, "outbound shared packet connection to ", destination)
+			return h.sharedPool.ListenPacket(ctx, destination)
 		} else {
 			h.logger.InfoContext(ctx, "outbound packet connection to ", destination)
 		}
@@ -128,10 +150,16 @@ func (h *Outbound) InterfaceUpdated() {
 	if h.multiplexDialer != nil {
 		h.multiplexDialer.Reset()
 	}
+	if h.sharedPool != nil {
+		h.sharedPool.Reset()
+	}
 	return
 }
 
 func (h *Outbound) Close() error {
+	if h.sharedPool != nil {
+		h.sharedPool.Reset()
+	}
 	return common.Close(common.PtrOrNil(h.multiplexDialer))
 }
 
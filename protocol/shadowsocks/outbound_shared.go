@@ -0,0 +1,294 @@
+package shadowsocks
+
+import (
+	"context"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing/common/buf"
+	M "github.com/sagernet/sing/common/metadata"
+	"github.com/sagernet/sing/common/pipe"
+)
+
+const (
+	UDPSessionSocket = "socket"
+	UDPSessionShared = "shared"
+)
+
+const defaultUDPIdleTimeout = time.Minute
+
+// sharedPacketPool carries UDP sessions over shared packet connections, so that
+// short flows do not create a socket and a server session each. Responses are
+// delivered by their source address, so a destination is owned by one session
+// on each connection, and a session sending to a destination owned by another
+// session on its connection uses another connection for it.
+type sharedPacketPool struct {
+	dialer      *shadowsocksDialer
+	idleTimeout time.Duration
+	access      sync.Mutex
+	conns       []*sharedPacketConn
+}
+
+type sharedPacketConn struct {
+	pool        *sharedPacketPool
+	conn        net.PacketConn
+	writeAccess sync.Mutex
+	sessions    map[M.Socksaddr]*sharedPacketSession
+	members     map[*sharedPacketSession]bool
+	idleTimer   *time.Timer
+	closed      bool
+}
+
+type sharedPacket struct {
+	data   []byte
+	source M.Socksaddr
+}
+
+func newSharedPacketPool(dialer *shadowsocksDialer, idleTimeout time.Duration) *sharedPacketPool {
+	if idleTimeout == 0 {
+		idleTimeout = defaultUDPIdleTimeout
+	}
+	return &sharedPacketPool{
+		dialer:      dialer,
+		idleTimeout: idleTimeout,
+	}
+}
+
+func (p *sharedPacketPool) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
+	session := &sharedPacketSession{
+		ctx:          ctx,
+		pool:         p,
+		conns:        make(map[M.Socksaddr]*sharedPacketConn),
+		packets:      make(chan *sharedPacket, 64),
+		done:         make(chan struct{}),
+		readDeadline: pipe.MakeDeadline(),
+	}
+	p.access.Lock()
+	defer p.access.Unlock()
+	conn, err := p.connFor(ctx, session, destination)
+	if err != nil {
+		return nil, err
+	}
+	session.primary = conn
+	return session, nil
+}
+
+// connFor returns the connection of the session to send to the destination,
+// claiming the destination on a connection where it is not owned by another
+// session. Must be called with the pool locked.
+func (p *sharedPacketPool) connFor(ctx context.Context, session *sharedPacketSession, destination M.Socksaddr) (*sharedPacketConn, error) {
+	if conn, loaded := session.conns[destination]; loaded {
+		return conn, nil
+	}
+	if !destination.IsValid() && session.primary != nil {
+		return session.primary, nil
+	}
+	candidates := p.conns
+	if session.primary != nil {
+		candidates = append([]*sharedPacketConn{session.primary}, candidates...)
+	}
+	for _, conn := range candidates {
+		if conn.closed {
+			continue
+		}
+		if _, used := conn.sessions[destination]; used && destination.IsValid() {
+			continue
+		}
+		conn.add(session, destination)
+		return conn, nil
+	}
+	packetConn, err := p.dialer.ListenPacket(ctx, destination)
+	if err != nil {
+		return nil, err
+	}
+	conn := &sharedPacketConn{
+		pool:     p,
+		conn:     packetConn,
+		sessions: make(map[M.Socksaddr]*sharedPacketSession),
+		members:  make(map[*sharedPacketSession]bool),
+	}
+	p.conns = append(p.conns, conn)
+	conn.add(session, destination)
+	go conn.loopRead()
+	return conn, nil
+}
+
+func (p *sharedPacketPool) Reset() {
+	p.access.Lock()
+	conns := p.conns
+	p.conns = nil
+	p.access.Unlock()
+	for _, conn := range conns {
+		conn.conn.Close()
+	}
+}
+
+// add must be called with the pool locked.
+func (c *sharedPacketConn) add(session *sharedPacketSession, destination M.Socksaddr) {
+	c.members[session] = true
+	if c.idleTimer != nil {
+		c.idleTimer.Stop()
+		c.idleTimer = nil
+	}
+	if destination.IsValid() {
+		c.sessions[destination] = session
+		session.conns[destination] = c
+	}
+}
+
+// remove must be called with the pool locked.
+func (c *sharedPacketConn) remove(session *sharedPacketSession) {
+	for destination, it := range c.sessions {
+		if it == session {
+			delete(c.sessions, destination)
+		}
+	}
+	delete(c.members, session)
+	if len(c.members) == 0 && !c.closed && c.idleTimer == nil {
+		c.idleTimer = time.AfterFunc(c.pool.idleTimeout, c.closeIdle)
+	}
+}
+
+func (c *sharedPacketConn) closeIdle() {
+	c.pool.access.Lock()
+	if len(c.members) > 0 || c.closed {
+		c.pool.access.Unlock()
+		return
+	}
+	c.removeLocked()
+	c.pool.access.Unlock()
+	c.conn.Close()
+}
+
+// removeLocked removes the connection from the pool, must be called with the pool locked.
+func (c *sharedPacketConn) removeLocked() {
+	c.closed = true
+	for i, conn := range c.pool.conns {
+		if conn == c {
+			c.pool.conns = append(c.pool.conns[:i], c.pool.conns[i+1:]...)
+			break
+		}
+	}
+}
+
+func (c *sharedPacketConn) writeTo(p []byte, destination M.Socksaddr) (int, error) {
+	c.writeAccess.Lock()
+	defer c.writeAccess.Unlock()
+	return c.conn.WriteTo(p, destination.UDPAddr())
+}
+
+func (c *sharedPacketConn) loopRead() {
+	defer c.closeRead()
+	buffer := make([]byte, buf.UDPBufferSize)
+	for {
+		n, addr, err := c.conn.ReadFrom(buffer)
+		if err != nil {
+			return
+		}
+		source := M.SocksaddrFromNet(addr).Unwrap()
+		c.pool.access.Lock()
+		session := c.sessions[source]
+		c.pool.access.Unlock()
+		if session == nil {
+			continue
+		}
+		packet := &sharedPacket{data: append([]byte(nil), buffer[:n]...), source: source}
+		select {
+		case session.packets <- packet:
+		default:
+			// drop packets if the session is not reading, like a full socket buffer
+		}
+	}
+}
+
+func (c *sharedPacketConn) closeRead() {
+	c.pool.access.Lock()
+	if !c.closed {
+		c.removeLocked()
+	}
+	members := c.members
+	c.members = make(map[*sharedPacketSession]bool)
+	c.pool.access.Unlock()
+	c.conn.Close()
+	for session := range members {
+		session.Close()
+	}
+}
+
+type sharedPacketSession struct {
+	ctx          context.Context
+	pool         *sharedPacketPool
+	primary      *sharedPacketConn
+	conns        map[M.Socksaddr]*sharedPacketConn
+	packets      chan *sharedPacket
+	done         chan struct{}
+	closeOnce    sync.Once
+	readDeadline pipe.Deadline
+}
+
+func (s *sharedPacketSession) ReadFrom(p []byte) (n int, addr net.Addr, err error) {
+	select {
+	case packet := <-s.packets:
+		n = copy(p, packet.data)
+		return n, packet.source.UDPAddr(), nil
+	case <-s.done:
+		return 0, nil, net.ErrClosed
+	case <-s.readDeadline.Wait():
+		return 0, nil, os.ErrDeadlineExceeded
+	}
+}
+
+func (s *sharedPacketSession) WriteTo(p []byte, addr net.Addr) (n int, err error) {
+	select {
+	case <-s.done:
+		return 0, net.ErrClosed
+	default:
+	}
+	destination := M.SocksaddrFromNet(addr).Unwrap()
+	s.pool.access.Lock()
+	conn, err := s.pool.connFor(s.ctx, s, destination)
+	s.pool.access.Unlock()
+	if err != nil {
+		return 0, err
+	}
+	return conn.writeTo(p, destination)
+}
+
+func (s *sharedPacketSession) Close() error {
+	var removed bool
+	s.closeOnce.Do(func() {
+		close(s.done)
+		removed = true
+	})
+	if removed {
+		s.pool.access.Lock()
+		s.primary.remove(s)
+		for _, conn := range s.conns {
+			if conn != s.primary {
+				conn.remove(s)
+			}
+		}
+		s.pool.access.Unlock()
+	}
+	return nil
+}
+
+func (s *sharedPacketSession) LocalAddr() net.Addr {
+	return s.primary.conn.LocalAddr()
+}
+
+func (s *sharedPacketSession) SetDeadline(t time.Time) error {
+	s.readDeadline.Set(t)
+	return nil
+}
+
+func (s *sharedPacketSession) SetReadDeadline(t time.Time) error {
+	s.readDeadline.Set(t)
+	return nil
+}
+
+func (s *sharedPacketSession) SetWriteDeadline(t time.Time) error {
+	return nil
+}
@@ -0,0 +1,38 @@
+package shadowsocks
+
+import (
+	"context"
+	"net"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/transport/sip003"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+// newPlugin creates the SIP003 server plugin unwrapping TCP connections
+// before they are passed to handler, UDP is never handled by plugins.
+func newPlugin(ctx context.Context, logger log.ContextLogger, options option.ShadowsocksInboundOptions, handler pluginHandler) (sip003.ServerPlugin, error) {
+	if options.Plugin == "" {
+		return nil, nil
+	}
+	return sip003.CreateServerPlugin(ctx, logger, options.Plugin, options.PluginOptions, handler)
+}
+
+type pluginMetadataKey struct{}
+
+// contextWithPluginMetadata keeps the listener metadata of a connection passed to the plugin.
+func contextWithPluginMetadata(ctx context.Context, metadata adapter.InboundContext) context.Context {
+	return context.WithValue(ctx, pluginMetadataKey{}, metadata)
+}
+
+type pluginHandler func(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc)
+
+func (f pluginHandler) NewConnectionEx(ctx context.Context, conn net.Conn, source M.Socksaddr, destination M.Socksaddr, onClose N.CloseHandlerFunc) {
+	metadata, _ := ctx.Value(pluginMetadataKey{}).(adapter.InboundContext)
+	metadata.Source = source
+	metadata.Destination = destination
+	f(ctx, conn, metadata, onClose)
+}
@@ -0,0 +1,95 @@
+package shadowsocks
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/sagernet/sing-box/common/replay"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-shadowsocks/shadowaead"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/buf"
+	"github.com/sagernet/sing/common/bufio"
+	E "github.com/sagernet/sing/common/exceptions"
+	sReplay "github.com/sagernet/sing/common/replay"
+)
+
+const (
+	replayFilterCapacity          = 100000
+	replayFilterFalsePositiveRate = 1e-6
+)
+
+// probeDefense makes failed handshakes indistinguishable from slow clients:
+// replayed salts are rejected, and invalid connections are drained instead of being closed immediately.
+type probeDefense struct {
+	replayFilter sReplay.Filter
+	saltLength   int
+	drainTimeout time.Duration
+}
+
+type handshakeStateKey struct{}
+
+func newProbeDefense(options option.ShadowsocksInboundOptions) (*probeDefense, error) {
+	defense := &probeDefense{
+		drainTimeout: time.Duration(options.DrainTimeout),
+	}
+	if options.ReplayFilter {
+		// 2022 methods have built-in replay protection
+		if !common.Contains(shadowaead.List, options.Method) {
+			return nil, E.New("replay filter is only available for AEAD methods")
+		}
+		switch options.Method {
+		case "aes-128-gcm":
+			defense.saltLength = 16
+		case "aes-192-gcm":
+			defense.saltLength = 24
+		default:
+			defense.saltLength = 32
+		}
+		defense.replayFilter = replay.NewBloom(replayFilterCapacity, replayFilterFalsePositiveRate)
+	}
+	return defense, nil
+}
+
+func (d *probeDefense) NewConnection(ctx context.Context, conn net.Conn, handler func(ctx context.Context, conn net.Conn) error) error {
+	if d.replayFilter == nil && d.drainTimeout == 0 {
+		return handler(ctx, conn)
+	}
+	if d.replayFilter != nil {
+		salt := make([]byte, d.saltLength)
+		_, err := io.ReadFull(conn, salt)
+		if err != nil {
+			return E.Cause(err, "read salt")
+		}
+		if !d.replayFilter.Check(salt) {
+			d.drain(conn)
+			return E.New("salt not unique")
+		}
+		conn = bufio.NewCachedConn(conn, buf.As(salt))
+	}
+	var handshakeDone atomic.Bool
+	err := handler(context.WithValue(ctx, handshakeStateKey{}, &handshakeDone), conn)
+	if err != nil && !handshakeDone.Load() && !E.IsClosedOrCanceled(err) {
+		d.drain(conn)
+	}
+	return err
+}
+
+func (d *probeDefense) drain(conn net.Conn) {
+	if d.drainTimeout == 0 {
+		return
+	}
+	conn.SetReadDeadline(time.Now().Add(d.drainTimeout))
+	io.Copy(io.Discard, conn)
+}
+
+// markHandshakeDone is called once the request is authenticated,
+// so that errors from the routed connection do not trigger draining.
+func markHandshakeDone(ctx context.Context) {
+	if handshakeDone, loaded := ctx.Value(handshakeStateKey{}).(*atomic.Bool); loaded {
+		handshakeDone.Store(true)
+	}
+}
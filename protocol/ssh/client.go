@@ -0,0 +1,91 @@
+package ssh
+
+import (
+	"bytes"
+	"encoding/base64"
+	"math/rand"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// NewClientConfig creates the SSH client configuration shared by the outbound and the tunnel service.
+func NewClientConfig(options option.SSHClientOptions) (*ssh.ClientConfig, error) {
+	user := options.User
+	if user == "" {
+		user = "root"
+	}
+	clientVersion := options.ClientVersion
+	if clientVersion == "" {
+		clientVersion = randomVersion()
+	}
+	var authMethod []ssh.AuthMethod
+	if options.Password != "" {
+		authMethod = append(authMethod, ssh.Password(options.Password))
+	}
+	if len(options.PrivateKey) > 0 || options.PrivateKeyPath != "" {
+		var privateKey []byte
+		if len(options.PrivateKey) > 0 {
+			privateKey = []byte(strings.Join(options.PrivateKey, "\n"))
+		} else {
+			var err error
+			privateKey, err = os.ReadFile(os.ExpandEnv(options.PrivateKeyPath))
+			if err != nil {
+				return nil, E.Cause(err, "read private key")
+			}
+		}
+		var signer ssh.Signer
+		var err error
+		if options.PrivateKeyPassphrase == "" {
+			signer, err = ssh.ParsePrivateKey(privateKey)
+		} else {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(privateKey, []byte(options.PrivateKeyPassphrase))
+		}
+		if err != nil {
+			return nil, E.Cause(err, "parse private key")
+		}
+		authMethod = append(authMethod, ssh.PublicKeys(signer))
+	}
+	var hostKeys []ssh.PublicKey
+	for _, hostKey := range options.HostKey {
+		key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(hostKey))
+		if err != nil {
+			return nil, E.New("parse host key ", key)
+		}
+		hostKeys = append(hostKeys, key)
+	}
+	return &ssh.ClientConfig{
+		User:              user,
+		Auth:              authMethod,
+		ClientVersion:     clientVersion,
+		HostKeyAlgorithms: options.HostKeyAlgorithms,
+		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			if len(hostKeys) == 0 {
+				return nil
+			}
+			serverKey := key.Marshal()
+			for _, hostKey := range hostKeys {
+				if bytes.Equal(serverKey, hostKey.Marshal()) {
+					return nil
+				}
+			}
+			return E.New("host key mismatch, server send ", key.Type(), " ", base64.StdEncoding.EncodeToString(serverKey))
+		},
+	}, nil
+}
+
+func randomVersion() string {
+	version := "SSH-2.0-OpenSSH_"
+	if rand.Intn(2) == 0 {
+		version += "7." + strconv.Itoa(rand.Intn(10))
+	} else {
+		version += "8." + strconv.Itoa(rand.Intn(9))
+	}
+	return version
+}
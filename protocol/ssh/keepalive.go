@@ -0,0 +1,43 @@
+package ssh
+
+import (
+	"time"
+
+	"github.com/sagernet/sing/common/logger"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const DefaultKeepaliveInterval = 30 * time.Second
+
+// Keepalive sends keepalive requests until done is closed, and closes the client
+// if a request fails or is not answered within the interval.
+func Keepalive(logger logger.Logger, client *ssh.Client, interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		result := make(chan error, 1)
+		go func() {
+			_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+			result <- err
+		}()
+		select {
+		case err := <-result:
+			if err == nil {
+				continue
+			}
+			logger.Debug("keepalive: ", err)
+		case <-time.After(interval):
+			logger.Debug("keepalive: timeout")
+		case <-done:
+			return
+		}
+		client.Close()
+		return
+	}
+}
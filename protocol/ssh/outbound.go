@@ -1,15 +1,12 @@
 package ssh
 
 import (
-	"bytes"
 	"context"
-	"encoding/base64"
-	"math/rand"
+	"errors"
 	"net"
 	"os"
-	"strconv"
-	"strings"
 	"sync"
+	"time"
 
 	"github.com/sagernet/sing-box/adapter"
 	"github.com/sagernet/sing-box/adapter/outbound"
@@ -24,6 +21,7 @@ import (
 	N "github.com/sagernet/sing/common/network"
 
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/sync/singleflight"
 )
 
 func RegisterOutbound(registry *outbound.Registry) {
@@ -38,18 +36,28 @@ type Outbound struct {
 	logger            logger.ContextLogger
 	dialer            N.Dialer
 	serverAddr        M.Socksaddr
-	user              string
-	hostKey           []ssh.PublicKey
-	hostKeyAlgorithms []string
-	clientVersion     string
-	authMethod        []ssh.AuthMethod
+	config            *ssh.ClientConfig
+	maxChannels       int
+	keepaliveInterval time.Duration
 	clientAccess      sync.Mutex
-	clientConn        net.Conn
-	client            *ssh.Client
+	clients           []*outboundClient
+	connectGroup      singleflight.Group
+}
+
+// outboundClient is an SSH connection shared by forwarded connections.
+type outboundClient struct {
+	*ssh.Client
+	conn     net.Conn
+	channels int
+	done     chan struct{}
 }
 
 func NewOutbound(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.SSHOutboundOptions) (adapter.Outbound, error) {
-	outboundDialer, err := dialer.New(ctx, options.DialerOptions)
+	outboundDialer, err := dialer.NewServer(ctx, options.DialerOptions, options.ServerOptions.Build())
+	if err != nil {
+		return nil, err
+	}
+	config, err := NewClientConfig(options.SSHClientOptions)
 	if err != nil {
 		return nil, err
 	}
@@ -59,141 +67,160 @@ func NewOutbound(ctx context.Context, router adapter.Router, logger log.ContextL
 		logger:            logger,
 		dialer:            outboundDialer,
 		serverAddr:        options.ServerOptions.Build(),
-		user:              options.User,
-		hostKeyAlgorithms: options.HostKeyAlgorithms,
-		clientVersion:     options.ClientVersion,
+		config:            config,
+		maxChannels:       int(options.MaxChannels),
+		keepaliveInterval: time.Duration(options.KeepaliveInterval),
 	}
 	if outbound.serverAddr.Port == 0 {
 		outbound.serverAddr.Port = 22
 	}
-	if outbound.user == "" {
-		outbound.user = "root"
-	}
-	if outbound.clientVersion == "" {
-		outbound.clientVersion = randomVersion()
+	if outbound.keepaliveInterval == 0 {
+		outbound.keepaliveInterval = DefaultKeepaliveInterval
 	}
-	if options.Password != "" {
-		outbound.authMethod = append(outbound.authMethod, ssh.Password(options.Password))
-	}
-	if len(options.PrivateKey) > 0 || options.PrivateKeyPath != "" {
-		var privateKey []byte
-		if len(options.PrivateKey) > 0 {
-			privateKey = []byte(strings.Join(options.PrivateKey, "\n"))
-		} else {
-			var err error
-			privateKey, err = os.ReadFile(os.ExpandEnv(options.PrivateKeyPath))
-			if err != nil {
-				return nil, E.Cause(err, "read private key")
+	return outbound, nil
+}
+
+// acquire returns a connection with a free channel, and connects a new one if
+// all are full. Concurrent callers share a single connection attempt, which is
+// made without holding clientAccess.
+func (s *Outbound) acquire(ctx context.Context) (*outboundClient, error) {
+	for {
+		s.clientAccess.Lock()
+		for _, client := range s.clients {
+			if s.maxChannels == 0 || client.channels < s.maxChannels {
+				client.channels++
+				s.clientAccess.Unlock()
+				return client, nil
 			}
 		}
-		var signer ssh.Signer
-		var err error
-		if options.PrivateKeyPassphrase == "" {
-			signer, err = ssh.ParsePrivateKey(privateKey)
-		} else {
-			signer, err = ssh.ParsePrivateKeyWithPassphrase(privateKey, []byte(options.PrivateKeyPassphrase))
-		}
-		if err != nil {
-			return nil, E.Cause(err, "parse private key")
-		}
-		outbound.authMethod = append(outbound.authMethod, ssh.PublicKeys(signer))
-	}
-	if len(options.HostKey) > 0 {
-		for _, hostKey := range options.HostKey {
-			key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(hostKey))
+		s.clientAccess.Unlock()
+		connectResult := s.connectGroup.DoChan("", func() (any, error) {
+			client, err := s.connect()
 			if err != nil {
-				return nil, E.New("parse host key ", key)
+				return nil, err
 			}
-			outbound.hostKey = append(outbound.hostKey, key)
+			s.clientAccess.Lock()
+			s.clients = append(s.clients, client)
+			s.clientAccess.Unlock()
+			return client, nil
+		})
+		select {
+		case result := <-connectResult:
+			if result.Err != nil {
+				return nil, result.Err
+			}
+		case <-ctx.Done():
+			return nil, ctx.Err()
 		}
 	}
-	return outbound, nil
-}
-
-func randomVersion() string {
-	version := "SSH-2.0-OpenSSH_"
-	if rand.Intn(2) == 0 {
-		version += "7." + strconv.Itoa(rand.Intn(10))
-	} else {
-		version += "8." + strconv.Itoa(rand.Intn(9))
-	}
-	return version
 }
 
-func (s *Outbound) connect() (*ssh.Client, error) {
-	if s.client != nil {
-		return s.client, nil
-	}
-
+func (s *Outbound) release(client *outboundClient) {
 	s.clientAccess.Lock()
-	defer s.clientAccess.Unlock()
-
-	if s.client != nil {
-		return s.client, nil
-	}
+	client.channels--
+	s.clientAccess.Unlock()
+}
 
-	conn, err := s.dialer.DialContext(s.ctx, N.NetworkTCP, s.serverAddr)
+// connect dials the server and completes the SSH handshake within C.TCPTimeout.
+// It is not bound to the context of a single request, since the connection is
+// shared by all waiting requests.
+func (s *Outbound) connect() (*outboundClient, error) {
+	ctx, cancel := context.WithTimeout(s.ctx, C.TCPTimeout)
+	defer cancel()
+	conn, err := s.dialer.DialContext(ctx, N.NetworkTCP, s.serverAddr)
 	if err != nil {
 		return nil, err
 	}
-	config := &ssh.ClientConfig{
-		User:              s.user,
-		Auth:              s.authMethod,
-		ClientVersion:     s.clientVersion,
-		HostKeyAlgorithms: s.hostKeyAlgorithms,
-		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
-			if len(s.hostKey) == 0 {
-				return nil
-			}
-			serverKey := key.Marshal()
-			for _, hostKey := range s.hostKey {
-				if bytes.Equal(serverKey, hostKey.Marshal()) {
-					return nil
-				}
-			}
-			return E.New("host key mismatch, server send ", key.Type(), " ", base64.StdEncoding.EncodeToString(serverKey))
-		},
-	}
-	clientConn, chans, reqs, err := ssh.NewClientConn(conn, s.serverAddr.Addr.String(), config)
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+	clientConn, chans, reqs, err := ssh.NewClientConn(conn, s.serverAddr.Addr.String(), s.config)
 	if err != nil {
 		conn.Close()
 		return nil, E.Cause(err, "connect to ssh server")
 	}
-
-	client := ssh.NewClient(clientConn, chans, reqs)
-
-	s.clientConn = conn
-	s.client = client
-
+	conn.SetDeadline(time.Time{})
+	client := &outboundClient{
+		Client: ssh.NewClient(clientConn, chans, reqs),
+		conn:   conn,
+		done:   make(chan struct{}),
+	}
+	go Keepalive(s.logger, client.Client, s.keepaliveInterval, client.done)
 	go func() {
 		client.Wait()
 		conn.Close()
-		s.clientAccess.Lock()
-		s.client = nil
-		s.clientConn = nil
-		s.clientAccess.Unlock()
+		close(client.done)
+		s.remove(client)
 	}()
-
 	return client, nil
 }
 
+func (s *Outbound) remove(client *outboundClient) {
+	s.clientAccess.Lock()
+	defer s.clientAccess.Unlock()
+	s.clients = common.Filter(s.clients, func(it *outboundClient) bool {
+		return it != client
+	})
+}
+
+func (s *Outbound) closeClients() error {
+	s.clientAccess.Lock()
+	clients := s.clients
+	s.clients = nil
+	s.clientAccess.Unlock()
+	return common.Close(common.Map(clients, func(it *outboundClient) any {
+		return it.conn
+	})...)
+}
+
 func (s *Outbound) InterfaceUpdated() {
-	common.Close(s.clientConn)
-	return
+	s.closeClients()
 }
 
 func (s *Outbound) Close() error {
-	return common.Close(s.clientConn)
+	return s.closeClients()
 }
 
 func (s *Outbound) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
-	client, err := s.connect()
-	if err != nil {
-		return nil, err
+	for retry := false; ; retry = true {
+		client, err := s.acquire(ctx)
+		if err != nil {
+			return nil, err
+		}
+		conn, err := client.Dial(network, destination.String())
+		if err == nil {
+			return &outboundConn{Conn: conn, outbound: s, client: client}, nil
+		}
+		s.release(client)
+		var openErr *ssh.OpenChannelError
+		if retry || errors.As(err, &openErr) {
+			return nil, err
+		}
+		// the channel is rejected by the server only with OpenChannelError,
+		// otherwise the connection is lost, so reconnect and open the channel again.
+		s.logger.DebugContext(ctx, "reconnecting after connection lost: ", err)
+		client.conn.Close()
+		s.remove(client)
 	}
-	return client.Dial(network, destination.String())
 }
 
 func (s *Outbound) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
 	return nil, os.ErrInvalid
 }
+
+type outboundConn struct {
+	net.Conn
+	outbound  *Outbound
+	client    *outboundClient
+	closeOnce sync.Once
+}
+
+func (c *outboundConn) Close() error {
+	c.closeOnce.Do(func() {
+		c.outbound.release(c.client)
+	})
+	return c.Conn.Close()
+}
+
+func (c *outboundConn) Upstream() any {
+	return c.Conn
+}
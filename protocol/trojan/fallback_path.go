@@ -0,0 +1,94 @@
+package trojan
+
+import (
+	"bytes"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common/buf"
+	"github.com/sagernet/sing/common/bufio"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+)
+
+const maxRequestLineSize = 8192
+
+type pathFallback struct {
+	prefix      string
+	destination M.Socksaddr
+}
+
+func newPathFallbacks(options map[string]*option.ServerOptions) ([]pathFallback, error) {
+	fallbacks := make([]pathFallback, 0, len(options))
+	for prefix, destination := range options {
+		if !strings.HasPrefix(prefix, "/") {
+			return nil, E.New("invalid fallback path: ", prefix, ": must start with /")
+		}
+		fallbackAddr := destination.Build()
+		if !fallbackAddr.IsValid() {
+			return nil, E.New("invalid fallback address for path ", prefix, ": ", fallbackAddr)
+		}
+		fallbacks = append(fallbacks, pathFallback{prefix, fallbackAddr})
+	}
+	sort.Slice(fallbacks, func(i, j int) bool {
+		return len(fallbacks[i].prefix) > len(fallbacks[j].prefix)
+	})
+	return fallbacks, nil
+}
+
+func matchPathFallback(fallbacks []pathFallback, path string) M.Socksaddr {
+	for _, fallback := range fallbacks {
+		if strings.HasPrefix(path, fallback.prefix) {
+			return fallback.destination
+		}
+	}
+	return M.Socksaddr{}
+}
+
+// readRequestPath reads the HTTP/1 request line from conn and returns a conn
+// with the consumed bytes rewound, so that the fallback backend receives
+// the original request.
+func readRequestPath(conn net.Conn) (net.Conn, string, error) {
+	buffer := buf.NewSize(maxRequestLineSize)
+	conn.SetReadDeadline(time.Now().Add(C.ReadPayloadTimeout))
+	defer conn.SetReadDeadline(time.Time{})
+	for {
+		_, err := buffer.ReadOnceFrom(conn)
+		if err != nil {
+			if buffer.IsEmpty() {
+				buffer.Release()
+				return conn, "", err
+			}
+			return bufio.NewCachedConn(conn, buffer), "", err
+		}
+		lineEnd := bytes.IndexByte(buffer.Bytes(), '\n')
+		if lineEnd != -1 {
+			return bufio.NewCachedConn(conn, buffer), parseRequestPath(buffer.To(lineEnd)), nil
+		}
+		if buffer.IsFull() {
+			return bufio.NewCachedConn(conn, buffer), "", E.New("request line too long")
+		}
+	}
+}
+
+func parseRequestPath(requestLine []byte) string {
+	fields := strings.Fields(string(requestLine))
+	if len(fields) != 3 || !strings.HasPrefix(fields[2], "HTTP/") {
+		return ""
+	}
+	target := fields[1]
+	if strings.HasPrefix(target, "/") {
+		return target
+	}
+	// absolute-form request target
+	requestURL, err := url.Parse(target)
+	if err != nil {
+		return ""
+	}
+	return requestURL.Path
+}
@@ -4,12 +4,14 @@ import (
 	"context"
 	"net"
 	"os"
+	"sync"
 
 	"github.com/sagernet/sing-box/adapter"
 	"github.com/sagernet/sing-box/adapter/inbound"
 	"github.com/sagernet/sing-box/common/listener"
 	"github.com/sagernet/sing-box/common/mux"
 	"github.com/sagernet/sing-box/common/tls"
+	"github.com/sagernet/sing-box/common/userfile"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/log"
 	"github.com/sagernet/sing-box/option"
@@ -35,10 +37,13 @@ type Inbound struct {
 	logger                   log.ContextLogger
 	listener                 *listener.Listener
 	service                  *trojan.Service[int]
+	access                   sync.RWMutex
 	users                    []option.TrojanUser
+	usersWatcher             *userfile.Watcher[option.TrojanUser]
 	tlsConfig                tls.ServerConfig
 	fallbackAddr             M.Socksaddr
 	fallbackAddrTLSNextProto map[string]M.Socksaddr
+	fallbackAddrPath         []pathFallback
 	transport                adapter.V2RayServerTransport
 }
 
@@ -47,7 +52,6 @@ func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLo
 		Adapter: inbound.NewAdapter(C.TypeTrojan, tag),
 		router:  router,
 		logger:  logger,
-		users:   options.Users,
 	}
 	if options.TLS != nil {
 		tlsConfig, err := tls.NewServer(ctx, logger, common.PtrValueOrDefault(options.TLS))
@@ -57,7 +61,7 @@ func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLo
 		inbound.tlsConfig = tlsConfig
 	}
 	var fallbackHandler N.TCPConnectionHandlerEx
-	if options.Fallback != nil && options.Fallback.Server != "" || len(options.FallbackForALPN) > 0 {
+	if options.Fallback != nil && options.Fallback.Server != "" || len(options.FallbackForALPN) > 0 || len(options.FallbackForPath) > 0 {
 		if options.Fallback != nil && options.Fallback.Server != "" {
 			inbound.fallbackAddr = options.Fallback.Build()
 			if !inbound.fallbackAddr.IsValid() {
@@ -78,34 +82,44 @@ func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLo
 			}
 			inbound.fallbackAddrTLSNextProto = fallbackAddrNextProto
 		}
+		if len(options.FallbackForPath) > 0 {
+			fallbackAddrPath, err := newPathFallbacks(options.FallbackForPath)
+			if err != nil {
+				return nil, err
+			}
+			inbound.fallbackAddrPath = fallbackAddrPath
+		}
 		fallbackHandler = adapter.NewUpstreamContextHandlerEx(inbound.fallbackConnection, nil)
 	}
-	service := trojan.NewService[int](adapter.NewUpstreamContextHandlerEx(inbound.newConnection, inbound.newPacketConnection), fallbackHandler, logger)
-	err := service.UpdateUsers(common.MapIndexed(options.Users, func(index int, it option.TrojanUser) int {
-		return index
-	}), common.Map(options.Users, func(it option.TrojanUser) string {
-		return it.Password
-	}))
-	if err != nil {
-		return nil, err
-	}
+	inbound.service = trojan.NewService[int](adapter.NewUpstreamContextHandlerEx(inbound.newConnection, inbound.newPacketConnection), fallbackHandler, logger)
+	var err error
 	if options.Transport != nil {
 		inbound.transport, err = v2ray.NewServerTransport(ctx, logger, common.PtrValueOrDefault(options.Transport), inbound.tlsConfig, (*inboundTransportHandler)(inbound))
 		if err != nil {
 			return nil, E.Cause(err, "create server transport: ", options.Transport.Type)
 		}
 	}
+	if options.UsersPath != "" {
+		inbound.usersWatcher, err = userfile.NewWatcher(ctx, logger, options.UsersPath, func(users []option.TrojanUser) error {
+			return inbound.updateUsers(append(append([]option.TrojanUser(nil), options.Users...), users...))
+		})
+	} else {
+		err = inbound.updateUsers(options.Users)
+	}
+	if err != nil {
+		return nil, err
+	}
 	inbound.router, err = mux.NewRouterWithOptions(inbound.router, logger, common.PtrValueOrDefault(options.Multiplex))
 	if err != nil {
 		return nil, err
 	}
-	inbound.service = service
 	inbound.listener = listener.New(listener.Options{
-		Context:           ctx,
-		Logger:            logger,
-		Network:           []string{N.NetworkTCP},
-		Listen:            options.ListenOptions,
-		ConnectionHandler: inbound,
+		Context:             ctx,
+		Logger:              logger,
+		Network:             []string{N.NetworkTCP},
+		Listen:              options.ListenOptions,
+		AcceptProxyProtocol: options.AcceptProxyProtocol,
+		ConnectionHandler:   inbound,
 	})
 	return inbound, nil
 }
@@ -114,6 +128,12 @@ func (h *Inbound) Start(stage adapter.StartStage) error {
 	if stage != adapter.StartStateStart {
 		return nil
 	}
+	if h.usersWatcher != nil {
+		err := h.usersWatcher.Start()
+		if err != nil {
+			return err
+		}
+	}
 	if h.tlsConfig != nil {
 		err := h.tlsConfig.Start()
 		if err != nil {
@@ -150,8 +170,40 @@ func (h *Inbound) Start(stage adapter.StartStage) error {
 	return nil
 }
 
+func (h *Inbound) updateUsers(users []option.TrojanUser) error {
+	if common.Any(users, func(it option.TrojanUser) bool {
+		return it.Flow == trojan.FlowVision
+	}) && (h.tlsConfig == nil || h.transport != nil) {
+		return E.New(trojan.FlowVision, " flow requires TLS without V2Ray transport")
+	}
+	h.access.Lock()
+	defer h.access.Unlock()
+	err := h.service.UpdateUsers(common.MapIndexed(users, func(index int, it option.TrojanUser) int {
+		return index
+	}), common.Map(users, func(it option.TrojanUser) string {
+		return it.Password
+	}), common.Map(users, func(it option.TrojanUser) string {
+		return it.Flow
+	}))
+	if err != nil {
+		return err
+	}
+	h.users = users
+	return nil
+}
+
+func (h *Inbound) userName(userIndex int) string {
+	h.access.RLock()
+	defer h.access.RUnlock()
+	if userIndex >= len(h.users) {
+		return ""
+	}
+	return h.users[userIndex].Name
+}
+
 func (h *Inbound) Close() error {
 	return common.Close(
+		common.PtrOrNil(h.usersWatcher),
 		h.listener,
 		h.tlsConfig,
 		h.transport,
@@ -160,7 +212,7 @@ func (h *Inbound) Close() error {
 
 func (h *Inbound) NewConnectionEx(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
 	if h.tlsConfig != nil && h.transport == nil {
-		tlsConn, err := tls.ServerHandshake(ctx, conn, h.tlsConfig)
+		tlsConn, err := tls.ServerHandshake(adapter.WithContext(ctx, &metadata), conn, h.tlsConfig)
 		if err != nil {
 			N.CloseOnHandshakeFailure(conn, onClose, err)
 			h.logger.ErrorContext(ctx, E.Cause(err, "process connection from ", metadata.Source, ": TLS handshake"))
@@ -183,7 +235,7 @@ func (h *Inbound) newConnection(ctx context.Context, conn net.Conn, metadata ada
 		N.CloseOnHandshakeFailure(conn, onClose, os.ErrInvalid)
 		return
 	}
-	user := h.users[userIndex].Name
+	user := h.userName(userIndex)
 	if user == "" {
 		user = F.ToString(userIndex)
 	} else {
@@ -201,7 +253,7 @@ func (h *Inbound) newPacketConnection(ctx context.Context, conn N.PacketConn, me
 		N.CloseOnHandshakeFailure(conn, onClose, os.ErrInvalid)
 		return
 	}
-	user := h.users[userIndex].Name
+	user := h.userName(userIndex)
 	if user == "" {
 		user = F.ToString(userIndex)
 	} else {
@@ -217,7 +269,7 @@ func (h *Inbound) fallbackConnection(ctx context.Context, conn net.Conn, metadat
 		if tlsConn, loaded := common.Cast[tls.Conn](conn); loaded {
 			connectionState := tlsConn.ConnectionState()
 			if connectionState.NegotiatedProtocol != "" {
-				if fallbackAddr, loaded = h.fallbackAddrTLSNextProto[connectionState.NegotiatedProtocol]; !loaded {
+				if fallbackAddr, loaded = h.fallbackAddrTLSNextProto[connectionState.NegotiatedProtocol]; !loaded && len(h.fallbackAddrPath) == 0 {
 					h.logger.DebugContext(ctx, "process connection from ", metadata.Source, ": fallback disabled for ALPN: ", connectionState.NegotiatedProtocol)
 					N.CloseOnHandshakeFailure(conn, onClose, os.ErrInvalid)
 					return
@@ -225,6 +277,21 @@ func (h *Inbound) fallbackConnection(ctx context.Context, conn net.Conn, metadat
 			}
 		}
 	}
+	if !fallbackAddr.IsValid() && len(h.fallbackAddrPath) > 0 {
+		var (
+			path string
+			err  error
+		)
+		conn, path, err = readRequestPath(conn)
+		if err != nil {
+			h.logger.DebugContext(ctx, "process connection from ", metadata.Source, ": fallback: ", err)
+		} else if path != "" {
+			fallbackAddr = matchPathFallback(h.fallbackAddrPath, path)
+			if fallbackAddr.IsValid() {
+				h.logger.DebugContext(ctx, "fallback by path: ", path)
+			}
+		}
+	}
 	if !fallbackAddr.IsValid() {
 		if !h.fallbackAddr.IsValid() {
 			h.logger.DebugContext(ctx, "process connection from ", metadata.Source, ": fallback disabled by default")
@@ -8,6 +8,7 @@ import (
 	"github.com/sagernet/sing-box/adapter/outbound"
 	"github.com/sagernet/sing-box/common/dialer"
 	"github.com/sagernet/sing-box/common/mux"
+	"github.com/sagernet/sing-box/common/preconnect"
 	"github.com/sagernet/sing-box/common/tls"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/log"
@@ -32,13 +33,15 @@ type Outbound struct {
 	dialer          N.Dialer
 	serverAddr      M.Socksaddr
 	key             [56]byte
+	flow            string
 	multiplexDialer *mux.Client
 	tlsConfig       tls.Config
 	transport       adapter.V2RayClientTransport
+	preConnect      *preconnect.Pool
 }
 
 func NewOutbound(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.TrojanOutboundOptions) (adapter.Outbound, error) {
-	outboundDialer, err := dialer.New(ctx, options.DialerOptions)
+	outboundDialer, err := dialer.NewServer(ctx, options.DialerOptions, options.ServerOptions.Build())
 	if err != nil {
 		return nil, err
 	}
@@ -48,6 +51,14 @@ func NewOutbound(ctx context.Context, router adapter.Router, logger log.ContextL
 		dialer:     outboundDialer,
 		serverAddr: options.ServerOptions.Build(),
 		key:        trojan.Key(options.Password),
+		flow:       options.Flow,
+	}
+	err = trojan.CheckFlow(options.Flow)
+	if err != nil {
+		return nil, err
+	}
+	if options.Flow == trojan.FlowVision && (options.TLS == nil || !options.TLS.Enabled || options.Transport != nil) {
+		return nil, E.New(trojan.FlowVision, " flow requires TLS without V2Ray transport")
 	}
 	if options.TLS != nil {
 		outbound.tlsConfig, err = tls.NewClient(ctx, options.Server, common.PtrValueOrDefault(options.TLS))
@@ -65,6 +76,12 @@ func NewOutbound(ctx context.Context, router adapter.Router, logger log.ContextL
 	if err != nil {
 		return nil, err
 	}
+	if options.PreConnect != nil && options.PreConnect.Enabled {
+		if outbound.multiplexDialer != nil {
+			return nil, E.New("pre-connect is not available when multiplex is enabled")
+		}
+		outbound.preConnect = preconnect.New(ctx, logger, *options.PreConnect, outbound.connectServer)
+	}
 	return outbound, nil
 }
 
@@ -105,11 +122,32 @@ func (h *Outbound) InterfaceUpdated() {
 	if h.multiplexDialer != nil {
 		h.multiplexDialer.Reset()
 	}
+	if h.preConnect != nil {
+		h.preConnect.Reset()
+	}
 	return
 }
 
+func (h *Outbound) Start(stage adapter.StartStage) error {
+	if stage == adapter.StartStateStarted && h.preConnect != nil {
+		h.preConnect.Start()
+	}
+	return nil
+}
+
 func (h *Outbound) Close() error {
-	return common.Close(common.PtrOrNil(h.multiplexDialer), h.transport)
+	return common.Close(common.PtrOrNil(h.preConnect), common.PtrOrNil(h.multiplexDialer), h.transport)
+}
+
+func (h *Outbound) connectServer(ctx context.Context) (net.Conn, error) {
+	if h.transport != nil {
+		return h.transport.DialContext(ctx)
+	}
+	conn, err := h.dialer.DialContext(ctx, N.NetworkTCP, h.serverAddr)
+	if err == nil && h.tlsConfig != nil {
+		conn, err = tls.ClientHandshake(ctx, conn, h.tlsConfig)
+	}
+	return conn, err
 }
 
 type trojanDialer Outbound
@@ -120,13 +158,10 @@ func (h *trojanDialer) DialContext(ctx context.Context, network string, destinat
 	metadata.Destination = destination
 	var conn net.Conn
 	var err error
-	if h.transport != nil {
-		conn, err = h.transport.DialContext(ctx)
+	if h.preConnect != nil {
+		conn, err = h.preConnect.DialContext(ctx)
 	} else {
-		conn, err = h.dialer.DialContext(ctx, N.NetworkTCP, h.serverAddr)
-		if err == nil && h.tlsConfig != nil {
-			conn, err = tls.ClientHandshake(ctx, conn, h.tlsConfig)
-		}
+		conn, err = (*Outbound)(h).connectServer(ctx)
 	}
 	if err != nil {
 		common.Close(conn)
@@ -134,6 +169,14 @@ func (h *trojanDialer) DialContext(ctx context.Context, network string, destinat
 	}
 	switch N.NetworkName(network) {
 	case N.NetworkTCP:
+		if h.flow == trojan.FlowVision {
+			visionConn, err := trojan.NewClientVisionConn(conn, h.key, destination, h.logger)
+			if err != nil {
+				common.Close(conn)
+				return nil, err
+			}
+			return visionConn, nil
+		}
 		return trojan.NewClientConn(conn, h.key, destination), nil
 	case N.NetworkUDP:
 		return bufio.NewBindPacketConn(trojan.NewClientPacketConn(conn, h.key), destination), nil
@@ -10,6 +10,7 @@ import (
 	"github.com/sagernet/sing-box/common/listener"
 	"github.com/sagernet/sing-box/common/tls"
 	"github.com/sagernet/sing-box/common/uot"
+	"github.com/sagernet/sing-box/common/userquota"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/log"
 	"github.com/sagernet/sing-box/option"
@@ -29,12 +30,15 @@ func RegisterInbound(registry *inbound.Registry) {
 
 type Inbound struct {
 	inbound.Adapter
-	router       adapter.ConnectionRouterEx
-	logger       log.ContextLogger
-	listener     *listener.Listener
-	tlsConfig    tls.ServerConfig
-	server       *tuic.Service[int]
-	userNameList []string
+	router           adapter.ConnectionRouterEx
+	logger           log.ContextLogger
+	listener         *listener.Listener
+	tlsConfig        tls.ServerConfig
+	server           *tuic.Service[int]
+	userNameList     []string
+	userUUIDList     [][16]byte
+	userPasswordList []string
+	userQuota        *userquota.Manager
 }
 
 func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.TUICInboundOptions) (adapter.Inbound, error) {
@@ -61,7 +65,7 @@ func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLo
 	if options.UDPTimeout != 0 {
 		udpTimeout = time.Duration(options.UDPTimeout)
 	} else {
-		udpTimeout = C.UDPTimeout
+		udpTimeout = adapter.TimeoutsFromContext(ctx).UDPNAT
 	}
 	service, err := tuic.NewService[int](tuic.ServiceOptions{
 		Context:           ctx,
@@ -77,10 +81,10 @@ func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLo
 	if err != nil {
 		return nil, err
 	}
-	var userList []int
 	var userNameList []string
 	var userUUIDList [][16]byte
 	var userPasswordList []string
+	var userQuotaList []option.UserQuotaOptions
 	for index, user := range options.Users {
 		if user.UUID == "" {
 			return nil, E.New("missing uuid for user ", index)
@@ -89,17 +93,43 @@ func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLo
 		if err != nil {
 			return nil, E.Cause(err, "invalid uuid for user ", index)
 		}
-		userList = append(userList, index)
 		userNameList = append(userNameList, user.Name)
 		userUUIDList = append(userUUIDList, userUUID)
 		userPasswordList = append(userPasswordList, user.Password)
+		userQuotaList = append(userQuotaList, user.UserQuotaOptions)
 	}
-	service.UpdateUsers(userList, userUUIDList, userPasswordList)
 	inbound.server = service
 	inbound.userNameList = userNameList
+	inbound.userUUIDList = userUUIDList
+	inbound.userPasswordList = userPasswordList
+	inbound.userQuota, err = userquota.NewManager(ctx, logger, tag, userNameList, userQuotaList)
+	if err != nil {
+		return nil, err
+	}
 	return inbound, nil
 }
 
+// updateUsers sets users to the service once at start, excluding users that
+// exceeded their quota or expired. Users disabled later are rejected by the
+// Available check of new connections, as the service does not support
+// updating users while running.
+func (h *Inbound) updateUsers() {
+	var (
+		userList         []int
+		userUUIDList     [][16]byte
+		userPasswordList []string
+	)
+	for index := range h.userNameList {
+		if !h.userQuota.Available(index) {
+			continue
+		}
+		userList = append(userList, index)
+		userUUIDList = append(userUUIDList, h.userUUIDList[index])
+		userPasswordList = append(userPasswordList, h.userPasswordList[index])
+	}
+	h.server.UpdateUsers(userList, userUUIDList, userPasswordList)
+}
+
 func (h *Inbound) NewConnectionEx(ctx context.Context, conn net.Conn, source M.Socksaddr, destination M.Socksaddr, onClose N.CloseHandlerFunc) {
 	ctx = log.ContextWithNewID(ctx)
 	var metadata adapter.InboundContext
@@ -120,6 +150,11 @@ func (h *Inbound) NewConnectionEx(ctx context.Context, conn net.Conn, source M.S
 	} else {
 		h.logger.InfoContext(ctx, "inbound connection to ", metadata.Destination)
 	}
+	if !h.userQuota.Available(userID) {
+		N.CloseOnHandshakeFailure(conn, onClose, E.New("user ", metadata.User, " exceeded quota or expired"))
+		return
+	}
+	conn, onClose = h.userQuota.NewConn(userID, conn, onClose)
 	h.router.RouteConnectionEx(ctx, conn, metadata, onClose)
 }
 
@@ -143,6 +178,11 @@ func (h *Inbound) NewPacketConnectionEx(ctx context.Context, conn N.PacketConn,
 	} else {
 		h.logger.InfoContext(ctx, "inbound packet connection to ", metadata.Destination)
 	}
+	if !h.userQuota.Available(userID) {
+		N.CloseOnHandshakeFailure(conn, onClose, E.New("user ", metadata.User, " exceeded quota or expired"))
+		return
+	}
+	conn, onClose = h.userQuota.NewPacketConn(userID, conn, onClose)
 	h.router.RoutePacketConnectionEx(ctx, conn, metadata, onClose)
 }
 
@@ -156,6 +196,11 @@ func (h *Inbound) Start(stage adapter.StartStage) error {
 			return err
 		}
 	}
+	err := h.userQuota.Start()
+	if err != nil {
+		return err
+	}
+	h.updateUsers()
 	packetConn, err := h.listener.ListenUDP()
 	if err != nil {
 		return err
@@ -168,5 +213,6 @@ func (h *Inbound) Close() error {
 		h.listener,
 		h.tlsConfig,
 		common.PtrOrNil(h.server),
+		common.PtrOrNil(h.userQuota),
 	)
 }
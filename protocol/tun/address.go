@@ -0,0 +1,142 @@
+package tun
+
+import (
+	"net"
+	"net/netip"
+
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+
+	"go4.org/netipx"
+)
+
+var (
+	autoInet4AddressBase = netip.MustParseAddr("172.18.0.1")
+	autoInet6AddressBase = netip.MustParseAddr("fdfe:dcba:9876::1")
+)
+
+const (
+	autoInet4AddressBits = 30
+	autoInet6AddressBits = 126
+	autoAddressCount     = 14
+)
+
+// Index of the address byte incremented for each candidate,
+// 172.18.0.1, 172.19.0.1 ... and fdfe:dcba:9876::1, fdfe:dcba:9877::1 ...
+const (
+	autoInet4AddressStepIndex = 1
+	autoInet6AddressStepIndex = 5
+)
+
+// assignAddress picks interface addresses not used by existing interfaces or routes
+// when address is not configured, rejects configured addresses that overlap addresses of other
+// interfaces, and warns about configured addresses that overlap routes.
+func (t *Inbound) assignAddress() error {
+	interfaceAddress, err := localInterfaceAddress(t.tunOptions.Name)
+	if err != nil {
+		t.logger.Warn("scan interface addresses: ", err)
+	}
+	routeAddress, err := localRouteAddress(t.tunOptions.Name)
+	if err != nil {
+		t.logger.Warn("scan routes: ", err)
+	}
+	if len(t.tunOptions.Inet4Address) == 0 && len(t.tunOptions.Inet6Address) == 0 {
+		inet4Address, loaded := pickAddress(autoInet4AddressBase, autoInet4AddressBits, autoInet4AddressStepIndex, interfaceAddress, routeAddress)
+		if !loaded {
+			return E.New("no available IPv4 address for tun interface in 172.18.0.0/16 to 172.31.0.0/16, specify address manually")
+		}
+		inet6Address, loaded := pickAddress(autoInet6AddressBase, autoInet6AddressBits, autoInet6AddressStepIndex, interfaceAddress, routeAddress)
+		if !loaded {
+			return E.New("no available IPv6 address for tun interface, specify address manually")
+		}
+		t.tunOptions.Inet4Address = []netip.Prefix{inet4Address}
+		t.tunOptions.Inet6Address = []netip.Prefix{inet6Address}
+		t.logger.Info("auto assigned address: ", inet4Address, ", ", inet6Address)
+		return nil
+	}
+	for _, address := range append(t.tunOptions.Inet4Address, t.tunOptions.Inet6Address...) {
+		if conflict, loaded := findInterfaceConflict(address, interfaceAddress); loaded {
+			return E.New("address ", address, " conflicts with existing interface address ", conflict, ", choose another range or remove address to assign automatically")
+		}
+		if conflict, loaded := findOverlap(address, routeAddress); loaded {
+			t.logger.Warn("address ", address, " overlaps existing route ", conflict)
+		}
+	}
+	return nil
+}
+
+// pickAddress tries autoAddressCount subnets starting from base,
+// incrementing the address byte at stepIndex each time.
+func pickAddress(base netip.Addr, bits int, stepIndex int, excludeList ...[]netip.Prefix) (netip.Prefix, bool) {
+	addressSlice := base.AsSlice()
+	for i := 0; i < autoAddressCount; i++ {
+		address, _ := netip.AddrFromSlice(addressSlice)
+		prefix := netip.PrefixFrom(address, bits)
+		var conflict bool
+		for _, exclude := range excludeList {
+			if _, conflict = findOverlap(prefix, exclude); conflict {
+				break
+			}
+		}
+		if !conflict {
+			return prefix, true
+		}
+		addressSlice[stepIndex]++
+	}
+	return netip.Prefix{}, false
+}
+
+// findInterfaceConflict finds an interface address overlapping the configured address. An interface
+// with the same prefix is the interface of a running instance, such as when checking before reload.
+func findInterfaceConflict(address netip.Prefix, interfaceAddress []netip.Prefix) (netip.Prefix, bool) {
+	return findOverlap(address, common.Filter(interfaceAddress, func(it netip.Prefix) bool {
+		return it != address.Masked()
+	}))
+}
+
+func findOverlap(prefix netip.Prefix, prefixList []netip.Prefix) (netip.Prefix, bool) {
+	for _, it := range prefixList {
+		if it.Overlaps(prefix.Masked()) {
+			return it, true
+		}
+	}
+	return netip.Prefix{}, false
+}
+
+func localInterfaceAddress(excludeName string) ([]netip.Prefix, error) {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	var prefixList []netip.Prefix
+	for _, netInterface := range interfaces {
+		if netInterface.Name == excludeName || netInterface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addresses, err := netInterface.Addrs()
+		if err != nil {
+			return nil, E.Cause(err, "get addresses of ", netInterface.Name)
+		}
+		for _, address := range addresses {
+			ipNet, isIPNet := address.(*net.IPNet)
+			if !isIPNet {
+				continue
+			}
+			prefix, loaded := netipx.FromStdIPNet(ipNet)
+			if !loaded || prefix.Addr().IsLinkLocalUnicast() {
+				continue
+			}
+			prefixList = append(prefixList, prefix.Masked())
+		}
+	}
+	return prefixList, nil
+}
+
+// isSplitDefaultRoute reports whether prefix is too wide to be a LAN range,
+// such as 0.0.0.0/1 installed by VPN clients to override the default route.
+func isSplitDefaultRoute(prefix netip.Prefix) bool {
+	if prefix.Addr().Is4() {
+		return prefix.Bits() < 8
+	}
+	return prefix.Bits() < 16
+}
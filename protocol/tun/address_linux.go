@@ -0,0 +1,34 @@
+package tun
+
+import (
+	"net/netip"
+
+	"github.com/sagernet/netlink"
+
+	"go4.org/netipx"
+)
+
+func localRouteAddress(excludeName string) ([]netip.Prefix, error) {
+	routes, err := netlink.RouteList(nil, netlink.FAMILY_ALL)
+	if err != nil {
+		return nil, err
+	}
+	var prefixList []netip.Prefix
+	for _, route := range routes {
+		if route.Dst == nil {
+			continue
+		}
+		if excludeName != "" && route.LinkIndex > 0 {
+			link, err := netlink.LinkByIndex(route.LinkIndex)
+			if err == nil && link.Attrs().Name == excludeName {
+				continue
+			}
+		}
+		prefix, loaded := netipx.FromStdIPNet(route.Dst)
+		if !loaded || isSplitDefaultRoute(prefix) || prefix.Addr().IsLinkLocalUnicast() || prefix.Addr().IsMulticast() {
+			continue
+		}
+		prefixList = append(prefixList, prefix.Masked())
+	}
+	return prefixList, nil
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package tun
+
+import "net/netip"
+
+func localRouteAddress(excludeName string) ([]netip.Prefix, error) {
+	return nil, nil
+}
@@ -0,0 +1,32 @@
+package tun
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindInterfaceConflict(t *testing.T) {
+	t.Parallel()
+	interfaceAddress := []netip.Prefix{
+		netip.MustParsePrefix("192.168.1.0/24"),
+		netip.MustParsePrefix("172.19.0.0/30"),
+	}
+	conflict, loaded := findInterfaceConflict(netip.MustParsePrefix("192.168.1.1/30"), interfaceAddress)
+	require.True(t, loaded)
+	require.Equal(t, netip.MustParsePrefix("192.168.1.0/24"), conflict)
+	_, loaded = findInterfaceConflict(netip.MustParsePrefix("172.19.0.1/30"), interfaceAddress)
+	require.False(t, loaded)
+	_, loaded = findInterfaceConflict(netip.MustParsePrefix("10.0.0.1/30"), interfaceAddress)
+	require.False(t, loaded)
+}
+
+func TestPickAddress(t *testing.T) {
+	t.Parallel()
+	address, loaded := pickAddress(autoInet4AddressBase, autoInet4AddressBits, autoInet4AddressStepIndex, []netip.Prefix{
+		netip.MustParsePrefix("172.18.0.0/16"),
+	})
+	require.True(t, loaded)
+	require.Equal(t, netip.MustParsePrefix("172.19.0.1/30"), address)
+}
@@ -0,0 +1,197 @@
+package tun
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"strings"
+	"syscall"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/sniff"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common/buf"
+	"github.com/sagernet/sing/common/bufio"
+	"github.com/sagernet/sing/common/domain"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+
+	"go4.org/netipx"
+)
+
+// Well-known public DoH/DoT resolvers, matched with their subdomains.
+var encryptedDNSDefaultDomain = []string{
+	"dns.google",
+	"dns.google.com",
+	"cloudflare-dns.com",
+	"one.one.one.one",
+	"dns.quad9.net",
+	"doh.opendns.com",
+	"dns.adguard.com",
+	"dns.adguard-dns.com",
+	"dns.nextdns.io",
+	"doh.cleanbrowsing.org",
+	"dns.controld.com",
+	"freedns.controld.com",
+	"dns.mullvad.net",
+	"doh.mullvad.net",
+	"doh.dns.sb",
+	"dns.alidns.com",
+	"doh.pub",
+	"dns.pub",
+	"doh.360.cn",
+	"dns.twnic.tw",
+}
+
+var encryptedDNSDefaultAddress = []string{
+	"8.8.8.8/32",
+	"8.8.4.4/32",
+	"2001:4860:4860::8888/128",
+	"2001:4860:4860::8844/128",
+	"1.1.1.1/32",
+	"1.0.0.1/32",
+	"2606:4700:4700::1111/128",
+	"2606:4700:4700::1001/128",
+	"104.16.248.249/32",
+	"104.16.249.249/32",
+	"9.9.9.9/32",
+	"149.112.112.112/32",
+	"2620:fe::fe/128",
+	"2620:fe::9/128",
+	"208.67.222.222/32",
+	"208.67.220.220/32",
+	"94.140.14.14/32",
+	"94.140.15.15/32",
+	"45.90.28.0/24",
+	"45.90.30.0/24",
+	"185.222.222.222/32",
+	"45.11.45.11/32",
+	"223.5.5.5/32",
+	"223.6.6.6/32",
+	"1.12.12.12/32",
+	"120.53.53.53/32",
+}
+
+// encryptedDNSHijack rejects DoH, DoT and DoQ connections to known resolvers,
+// so that clients fall back to plain DNS, which can be hijacked by route rules.
+type encryptedDNSHijack struct {
+	domainMatcher *domain.Matcher
+	addressSet    *netipx.IPSet
+	ruleSets      []adapter.RuleSet
+}
+
+func newEncryptedDNSHijack(router adapter.Router, options option.TunEncryptedDNSHijackOptions) (*encryptedDNSHijack, error) {
+	var (
+		domains        []string
+		addressBuilder netipx.IPSetBuilder
+	)
+	if !options.DisableDefault {
+		domains = append(domains, encryptedDNSDefaultDomain...)
+		for _, prefix := range encryptedDNSDefaultAddress {
+			addressBuilder.AddPrefix(netip.MustParsePrefix(prefix))
+		}
+	}
+	domains = append(domains, options.Domain...)
+	for _, prefix := range options.Address {
+		addressBuilder.AddPrefix(prefix)
+	}
+	addressSet, err := addressBuilder.IPSet()
+	if err != nil {
+		return nil, E.Cause(err, "build address set")
+	}
+	domainSuffix := make([]string, 0, len(domains))
+	for _, domainName := range domains {
+		domainSuffix = append(domainSuffix, "."+strings.TrimPrefix(domainName, "."))
+	}
+	hijack := &encryptedDNSHijack{
+		domainMatcher: domain.NewMatcher(domains, domainSuffix, false),
+		addressSet:    addressSet,
+	}
+	for _, tag := range options.RuleSet {
+		ruleSet, loaded := router.RuleSet(tag)
+		if !loaded {
+			return nil, E.New("rule-set not found: ", tag)
+		}
+		ruleSet.IncRef()
+		hijack.ruleSets = append(hijack.ruleSets, ruleSet)
+	}
+	return hijack, nil
+}
+
+// MatchDestination reports whether the connection is to a DoT or DoQ port, or to the HTTPS port of a known resolver.
+func (h *encryptedDNSHijack) MatchDestination(network string, destination M.Socksaddr) bool {
+	switch destination.Port {
+	case 853:
+		return true
+	case 443:
+		if h.addressSet.Contains(destination.Addr) {
+			return true
+		}
+		return h.matchRuleSet(&adapter.InboundContext{
+			Network:     network,
+			Destination: destination,
+		})
+	default:
+		return false
+	}
+}
+
+// MatchServerName reports whether the server name is of a known resolver.
+func (h *encryptedDNSHijack) MatchServerName(network string, destination M.Socksaddr, serverName string) bool {
+	if h.domainMatcher.Match(serverName) {
+		return true
+	}
+	return h.matchRuleSet(&adapter.InboundContext{
+		Network:     network,
+		Destination: destination,
+		Domain:      serverName,
+	})
+}
+
+func (h *encryptedDNSHijack) matchRuleSet(metadata *adapter.InboundContext) bool {
+	for _, ruleSet := range h.ruleSets {
+		if ruleSet.Match(metadata) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *Inbound) prepareEncryptedDNSConnection(network string, source M.Socksaddr, destination M.Socksaddr) error {
+	if t.encryptedDNSHijack == nil || !t.encryptedDNSHijack.MatchDestination(network, destination) {
+		return nil
+	}
+	t.logger.Debug("rejected encrypted DNS ", network, " connection from ", source, " to ", destination)
+	return syscall.ECONNREFUSED
+}
+
+// checkEncryptedDNSConnection peeks the TLS client hello of HTTPS connections, and rejects connections to known resolvers.
+func (t *Inbound) checkEncryptedDNSConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) (net.Conn, bool) {
+	if t.encryptedDNSHijack == nil {
+		return conn, true
+	}
+	if t.encryptedDNSHijack.MatchDestination(N.NetworkTCP, metadata.Destination) {
+		t.logger.DebugContext(ctx, "rejected encrypted DNS connection to ", metadata.Destination)
+		N.CloseOnHandshakeFailure(conn, onClose, E.New("encrypted DNS rejected: ", metadata.Destination))
+		return nil, false
+	}
+	if metadata.Destination.Port != 443 {
+		return conn, true
+	}
+	var sniffMetadata adapter.InboundContext
+	buffer := buf.NewPacket()
+	err := sniff.PeekStream(ctx, &sniffMetadata, conn, buffer, C.ReadPayloadTimeout, sniff.TLSClientHello)
+	if err == nil && t.encryptedDNSHijack.MatchServerName(N.NetworkTCP, metadata.Destination, sniffMetadata.Domain) {
+		buffer.Release()
+		t.logger.DebugContext(ctx, "rejected encrypted DNS connection to ", sniffMetadata.Domain)
+		N.CloseOnHandshakeFailure(conn, onClose, E.New("encrypted DNS rejected: ", sniffMetadata.Domain))
+		return nil, false
+	}
+	if buffer.IsEmpty() {
+		buffer.Release()
+		return conn, true
+	}
+	return bufio.NewCachedConn(conn, buffer), true
+}
@@ -45,11 +45,15 @@ type Inbound struct {
 	inboundOptions              option.InboundOptions
 	tunOptions                  tun.Options
 	udpTimeout                  time.Duration
+	udpNATStrategy              C.UDPNATStrategy
 	stack                       string
 	tunIf                       tun.Tun
 	tunStack                    tun.Stack
 	platformInterface           platform.Interface
 	platformOptions             option.TunPlatformOptions
+	tapOptions                  *option.TunTAPOptions
+	tapHardwareAddr             net.HardwareAddr
+	tapDevice                   *tapDevice
 	autoRedirect                tun.AutoRedirect
 	routeRuleSet                []adapter.RuleSet
 	routeRuleSetCallback        []*list.Element[adapter.RuleSetUpdateCallback]
@@ -57,6 +61,7 @@ type Inbound struct {
 	routeExcludeRuleSetCallback []*list.Element[adapter.RuleSetUpdateCallback]
 	routeAddressSet             []*netipx.IPSet
 	routeExcludeAddressSet      []*netipx.IPSet
+	encryptedDNSHijack          *encryptedDNSHijack
 }
 
 func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.TunInboundOptions) (adapter.Inbound, error) {
@@ -132,13 +137,17 @@ func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLo
 
 	tunMTU := options.MTU
 	if tunMTU == 0 {
-		tunMTU = 9000
+		if options.TAP != nil && options.TAP.Enabled {
+			tunMTU = 1500
+		} else {
+			tunMTU = 9000
+		}
 	}
 	var udpTimeout time.Duration
 	if options.UDPTimeout != 0 {
 		udpTimeout = time.Duration(options.UDPTimeout)
 	} else {
-		udpTimeout = C.UDPTimeout
+		udpTimeout = adapter.TimeoutsFromContext(ctx).UDPNAT
 	}
 	var err error
 	includeUID := uidToRange(options.IncludeUID)
@@ -172,6 +181,19 @@ func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLo
 	if outputMark == 0 {
 		outputMark = tun.DefaultAutoRedirectOutputMark
 	}
+	if options.AutoRoute {
+		switch tableIndex {
+		case 253, 254, 255: // default, main and local
+			return nil, E.New("iproute2_table_index: table ", tableIndex, " is reserved by the system")
+		}
+		// auto-route uses rule priorities from rule index to rule index + 10
+		if ruleIndex < 1 || ruleIndex+10 >= 32766 {
+			return nil, E.New("iproute2_rule_index: rule priorities ", ruleIndex, "-", ruleIndex+10, " overlap with rules of the system")
+		}
+	}
+	if options.AutoRedirect && inputMark == outputMark {
+		return nil, E.New("auto_redirect_input_mark and auto_redirect_output_mark must be different")
+	}
 	networkManager := service.FromContext[adapter.NetworkManager](ctx)
 	inbound := &Inbound{
 		tag:            tag,
@@ -180,6 +202,7 @@ func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLo
 		networkManager: networkManager,
 		logger:         logger,
 		inboundOptions: options.InboundOptions,
+		udpNATStrategy: C.UDPNATStrategy(options.UDPNATStrategy),
 		tunOptions: tun.Options{
 			Name:                     options.InterfaceName,
 			MTU:                      tunMTU,
@@ -225,10 +248,32 @@ func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLo
 		ruleSet.IncRef()
 		inbound.routeExcludeRuleSet = append(inbound.routeExcludeRuleSet, ruleSet)
 	}
+	if options.EncryptedDNSHijack != nil && options.EncryptedDNSHijack.Enabled {
+		inbound.encryptedDNSHijack, err = newEncryptedDNSHijack(router, *options.EncryptedDNSHijack)
+		if err != nil {
+			return nil, E.Cause(err, "parse encrypted_dns_hijack")
+		}
+	}
+	if options.TAP != nil && options.TAP.Enabled {
+		err = inbound.prepareTAP(options)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if inbound.platformInterface == nil && inbound.tapOptions == nil {
+		err = inbound.assignAddress()
+		if err != nil {
+			return nil, err
+		}
+	}
 	if options.AutoRedirect {
 		if !options.AutoRoute {
 			return nil, E.New("`auto_route` is required by `auto_redirect`")
 		}
+		tableName := options.AutoRedirectTableName
+		if tableName == "" {
+			tableName = "sing-box"
+		}
 		disableNFTables, dErr := strconv.ParseBool(os.Getenv("DISABLE_NFTABLES"))
 		inbound.autoRedirect, err = tun.NewAutoRedirect(tun.AutoRedirectOptions{
 			TunOptions:             &inbound.tunOptions,
@@ -237,7 +282,7 @@ func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLo
 			Logger:                 logger,
 			NetworkMonitor:         networkManager.NetworkMonitor(),
 			InterfaceFinder:        networkManager.InterfaceFinder(),
-			TableName:              "sing-box",
+			TableName:              tableName,
 			DisableNFTables:        dErr == nil && disableNFTables,
 			RouteAddressSet:        &inbound.routeAddressSet,
 			RouteExcludeAddressSet: &inbound.routeExcludeAddressSet,
@@ -303,7 +348,11 @@ func (t *Inbound) Start(stage adapter.StartStage) error {
 			t.tunOptions.BuildAndroidRules(t.networkManager.PackageManager())
 		}
 		if t.tunOptions.Name == "" {
-			t.tunOptions.Name = tun.CalculateInterfaceName("")
+			if t.tapOptions != nil {
+				t.tunOptions.Name = tun.CalculateInterfaceName("tap")
+			} else {
+				t.tunOptions.Name = tun.CalculateInterfaceName("")
+			}
 		}
 		if t.platformInterface == nil || runtime.GOOS != "android" {
 			t.routeAddressSet = common.FlatMap(t.routeRuleSet, adapter.RuleSet.ExtractIPSet)
@@ -353,9 +402,20 @@ func (t *Inbound) Start(stage adapter.StartStage) error {
 				}
 			}
 		}
+		if t.platformInterface == nil && t.tapOptions == nil && t.tunOptions.AutoRoute {
+			err = checkRouteCollision(t.tunOptions)
+			if err != nil {
+				return E.Cause(err, "check auto-route collision")
+			}
+		}
 		monitor.Start("open interface")
-		if t.platformInterface != nil {
-			tunInterface, err = t.platformInterface.OpenTun(&tunOptions, t.platformOptions)
+		if t.tapOptions != nil {
+			t.tapDevice, err = newTAPDevice(tunOptions, t.tapHardwareAddr, t.tapOptions.Bridge)
+			if err == nil {
+				tunInterface = t.tapDevice
+			}
+		} else if t.platformInterface != nil {
+			tunInterface, err = t.platformInterface.OpenTun(&tunOptions, t.platformOptions, t.platformRouteAddressSet())
 		} else {
 			tunInterface, err = tun.New(tunOptions)
 		}
@@ -389,7 +449,11 @@ func (t *Inbound) Start(stage adapter.StartStage) error {
 			return err
 		}
 		t.tunStack = tunStack
-		t.logger.Info("started at ", t.tunOptions.Name)
+		if t.tapDevice != nil {
+			t.logger.Info("started at ", t.tunOptions.Name, " (tap, ", t.tapHardwareAddr, ")")
+		} else {
+			t.logger.Info("started at ", t.tunOptions.Name)
+		}
 	case adapter.StartStatePostStart:
 		monitor := taskmonitor.New(t.logger, C.StartTimeout)
 		monitor.Start("starting tun stack")
@@ -444,7 +508,7 @@ func (t *Inbound) updateRouteAddressSet(it adapter.RuleSet) {
 			}
 		}
 		if t.platformInterface != nil {
-			err := t.platformInterface.UpdateRouteOptions(&tunOptions, t.platformOptions)
+			err := t.platformInterface.UpdateRouteOptions(&tunOptions, t.platformOptions, t.platformRouteAddressSet())
 			if err != nil {
 				t.logger.Error("update route addresses: ", err)
 			}
@@ -460,6 +524,13 @@ func (t *Inbound) updateRouteAddressSet(it adapter.RuleSet) {
 	t.routeExcludeAddressSet = nil
 }
 
+func (t *Inbound) platformRouteAddressSet() platform.TunRouteAddressSet {
+	return platform.TunRouteAddressSet{
+		Include: t.routeAddressSet,
+		Exclude: t.routeExcludeAddressSet,
+	}
+}
+
 func (t *Inbound) Close() error {
 	return common.Close(
 		t.tunStack,
@@ -468,7 +539,48 @@ func (t *Inbound) Close() error {
 	)
 }
 
+func (t *Inbound) prepareTAP(options option.TunInboundOptions) error {
+	if runtime.GOOS != "linux" || t.platformInterface != nil {
+		return E.New("TAP mode is only supported on Linux")
+	}
+	if !tun.WithGVisor {
+		return E.New("TAP mode requires gVisor, rebuild with -tags with_gvisor")
+	}
+	if t.stack != "" && t.stack != "gvisor" {
+		return E.New("TAP mode is only supported by the gvisor stack")
+	}
+	if options.AutoRoute {
+		return E.New("`auto_route` is not supported in TAP mode")
+	}
+	if len(t.tunOptions.Inet4Address) == 0 && len(t.tunOptions.Inet6Address) == 0 {
+		return E.New("missing gateway address for TAP mode")
+	}
+	hardwareAddr, err := newTAPHardwareAddr(options.TAP.MACAddress)
+	if err != nil {
+		return err
+	}
+	t.stack = "gvisor"
+	t.tapOptions = options.TAP
+	t.tapHardwareAddr = hardwareAddr
+	return nil
+}
+
+// loadSourceMACAddress records the hardware address of the source host on the
+// link in TAP mode.
+func (t *Inbound) loadSourceMACAddress(metadata *adapter.InboundContext) {
+	if t.tapDevice == nil {
+		return
+	}
+	if hardwareAddr, loaded := t.tapDevice.Neighbor(metadata.Source.Addr); loaded {
+		metadata.SourceMACAddress = hardwareAddr
+	}
+}
+
 func (t *Inbound) PrepareConnection(network string, source M.Socksaddr, destination M.Socksaddr) error {
+	err := t.prepareEncryptedDNSConnection(network, source, destination)
+	if err != nil {
+		return err
+	}
 	return t.router.PreMatch(adapter.InboundContext{
 		Inbound:        t.tag,
 		InboundType:    C.TypeTun,
@@ -488,8 +600,13 @@ func (t *Inbound) NewConnectionEx(ctx context.Context, conn net.Conn, source M.S
 	metadata.Destination = destination
 	//nolint:staticcheck
 	metadata.InboundOptions = t.inboundOptions
+	t.loadSourceMACAddress(&metadata)
 	t.logger.InfoContext(ctx, "inbound connection from ", metadata.Source)
 	t.logger.InfoContext(ctx, "inbound connection to ", metadata.Destination)
+	conn, loaded := t.checkEncryptedDNSConnection(ctx, conn, metadata, onClose)
+	if !loaded {
+		return
+	}
 	t.router.RouteConnectionEx(ctx, conn, metadata, onClose)
 }
 
@@ -502,6 +619,8 @@ func (t *Inbound) NewPacketConnectionEx(ctx context.Context, conn N.PacketConn,
 	metadata.Destination = destination
 	//nolint:staticcheck
 	metadata.InboundOptions = t.inboundOptions
+	metadata.UDPNATStrategy = t.udpNATStrategy
+	t.loadSourceMACAddress(&metadata)
 	t.logger.InfoContext(ctx, "inbound packet connection from ", metadata.Source)
 	t.logger.InfoContext(ctx, "inbound packet connection to ", metadata.Destination)
 	t.router.RoutePacketConnectionEx(ctx, conn, metadata, onClose)
@@ -520,5 +639,9 @@ func (t *autoRedirectHandler) NewConnectionEx(ctx context.Context, conn net.Conn
 	metadata.InboundOptions = t.inboundOptions
 	t.logger.InfoContext(ctx, "inbound redirect connection from ", metadata.Source)
 	t.logger.InfoContext(ctx, "inbound connection to ", metadata.Destination)
+	conn, loaded := (*Inbound)(t).checkEncryptedDNSConnection(ctx, conn, metadata, onClose)
+	if !loaded {
+		return
+	}
 	t.router.RouteConnectionEx(ctx, conn, metadata, onClose)
 }
@@ -0,0 +1,65 @@
+package tun
+
+import (
+	"github.com/sagernet/netlink"
+	"github.com/sagernet/sing-tun"
+	E "github.com/sagernet/sing/common/exceptions"
+
+	"golang.org/x/sys/unix"
+)
+
+// checkRouteCollision returns an error if the route table, rule priorities or
+// firewall marks used by auto-route are already used by other policy routing
+// software, such as Tailscale (table 52, rule priorities 5210-5270).
+//
+// Rules left over by an unclean exit are not considered as collisions, since
+// they are replaced on start.
+func checkRouteCollision(options tun.Options) error {
+	ruleStart := options.IPRoute2RuleIndex
+	ruleEnd := ruleStart + 10
+	ownMark := func(mark uint32) bool {
+		return options.AutoRedirectMarkMode && (mark == options.AutoRedirectInputMark || mark == options.AutoRedirectOutputMark)
+	}
+	ruleList, err := netlink.RuleList(netlink.FAMILY_ALL)
+	if err != nil {
+		return E.Cause(err, "list rules")
+	}
+	for _, rule := range ruleList {
+		if rule.Priority >= ruleStart && rule.Priority <= ruleEnd {
+			if rule.Table > 0 && rule.Table != options.IPRoute2TableIndex && rule.Table != unix.RT_TABLE_MAIN ||
+				rule.Goto > 0 && (rule.Goto < ruleStart || rule.Goto > ruleEnd) ||
+				rule.Mark != 0 && !ownMark(rule.Mark) {
+				return E.New("rule priority ", rule.Priority, " is used by another program, set `iproute2_rule_index` to a free range")
+			}
+			continue
+		}
+		if rule.Table == options.IPRoute2TableIndex {
+			return E.New("route table ", rule.Table, " is used by another program (rule priority ", rule.Priority, "), set `iproute2_table_index` to a free table")
+		}
+		if options.AutoRedirectMarkMode && rule.Mark != 0 {
+			mask := uint32(0xffffffff)
+			if rule.Mask >= 0 {
+				mask = uint32(rule.Mask)
+			}
+			for _, mark := range []uint32{options.AutoRedirectInputMark, options.AutoRedirectOutputMark} {
+				if mark&mask == rule.Mark&mask {
+					return E.New("firewall mark ", mark, " is matched by another program (rule priority ", rule.Priority, "), set `auto_redirect_input_mark` and `auto_redirect_output_mark` to free values")
+				}
+			}
+		}
+	}
+	routeList, err := netlink.RouteListFiltered(netlink.FAMILY_ALL, &netlink.Route{Table: options.IPRoute2TableIndex}, netlink.RT_FILTER_TABLE)
+	if err != nil {
+		return E.Cause(err, "list routes")
+	}
+	for _, route := range routeList {
+		if route.LinkIndex > 0 {
+			link, lErr := netlink.LinkByIndex(route.LinkIndex)
+			if lErr == nil && link.Attrs().Name == options.Name {
+				continue
+			}
+		}
+		return E.New("route table ", options.IPRoute2TableIndex, " is used by another program, set `iproute2_table_index` to a free table")
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package tun
+
+import "github.com/sagernet/sing-tun"
+
+func checkRouteCollision(options tun.Options) error {
+	return nil
+}
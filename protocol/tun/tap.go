@@ -0,0 +1,416 @@
+package tun
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/netip"
+
+	"github.com/sagernet/sing-tun"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/buf"
+	"github.com/sagernet/sing/common/cache"
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+const (
+	ethernetHeaderLen = 14
+	etherTypeIPv4     = 0x0800
+	etherTypeARP      = 0x0806
+	etherTypeIPv6     = 0x86dd
+
+	arpPacketLen    = 28
+	arpOpRequest    = 1
+	arpOpReply      = 2
+	ipv6HeaderLen   = 40
+	icmpv6Protocol  = 58
+	ndpSolicitation = 135
+	ndpAdvertise    = 136
+
+	ndpOptionSourceLinkAddress = 1
+	ndpOptionTargetLinkAddress = 2
+
+	// neighbors not heard from within this time are solicited again
+	tapNeighborTimeout = 600
+)
+
+var broadcastHardwareAddr = net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+
+var _ tun.Tun = (*tapDevice)(nil)
+
+// tapDevice exposes a TAP interface as a TUN device: it answers ARP and NDP for
+// the interface addresses, and strips or adds the Ethernet header of IP packets.
+type tapDevice struct {
+	name          string
+	file          io.ReadWriteCloser
+	hardwareAddr  net.HardwareAddr
+	inet4Address  []netip.Prefix
+	inet6Address  []netip.Prefix
+	broadcastAddr netip.Addr
+	frameBuffer   []byte
+	neighbors     *cache.LruCache[netip.Addr, net.HardwareAddr]
+}
+
+func newTAPHardwareAddr(address string) (net.HardwareAddr, error) {
+	if address != "" {
+		hardwareAddr, err := net.ParseMAC(address)
+		if err != nil {
+			return nil, E.Cause(err, "parse mac_address")
+		}
+		if len(hardwareAddr) != 6 || hardwareAddr[0]&1 != 0 {
+			return nil, E.New("mac_address must be an unicast EUI-48 address")
+		}
+		return hardwareAddr, nil
+	}
+	hardwareAddr := make(net.HardwareAddr, 6)
+	_, err := rand.Read(hardwareAddr)
+	if err != nil {
+		return nil, err
+	}
+	// locally administered unicast
+	hardwareAddr[0] = hardwareAddr[0]&0xfe | 0x02
+	return hardwareAddr, nil
+}
+
+func newTAPDeviceFromFile(name string, file io.ReadWriteCloser, options tun.Options, hardwareAddr net.HardwareAddr) *tapDevice {
+	return &tapDevice{
+		name:          name,
+		file:          file,
+		hardwareAddr:  hardwareAddr,
+		inet4Address:  options.Inet4Address,
+		inet6Address:  options.Inet6Address,
+		broadcastAddr: tun.BroadcastAddr(options.Inet4Address),
+		frameBuffer:   make([]byte, ethernetHeaderLen+int(options.MTU)),
+		neighbors: cache.New(
+			cache.WithAge[netip.Addr, net.HardwareAddr](tapNeighborTimeout),
+			cache.WithSize[netip.Addr, net.HardwareAddr](4096),
+		),
+	}
+}
+
+func (d *tapDevice) Name() (string, error) {
+	return d.name, nil
+}
+
+func (d *tapDevice) Start() error {
+	return nil
+}
+
+func (d *tapDevice) Close() error {
+	return d.file.Close()
+}
+
+func (d *tapDevice) UpdateRouteOptions(tunOptions tun.Options) error {
+	return nil
+}
+
+// Neighbor returns the hardware address of a host on the link.
+func (d *tapDevice) Neighbor(address netip.Addr) (net.HardwareAddr, bool) {
+	return d.neighbors.Load(address.Unmap())
+}
+
+// Read returns the next IP packet sent to the gateway, handling link-layer
+// traffic in place.
+func (d *tapDevice) Read(p []byte) (int, error) {
+	for {
+		n, err := d.file.Read(d.frameBuffer)
+		if err != nil {
+			return 0, err
+		}
+		if n < ethernetHeaderLen {
+			continue
+		}
+		frame := d.frameBuffer[:n]
+		destinationHardwareAddr := net.HardwareAddr(frame[0:6])
+		sourceHardwareAddr := net.HardwareAddr(frame[6:12])
+		if destinationHardwareAddr[0]&1 == 0 && !bytes.Equal(destinationHardwareAddr, d.hardwareAddr) {
+			continue
+		}
+		payload := frame[ethernetHeaderLen:]
+		var source, destination netip.Addr
+		switch binary.BigEndian.Uint16(frame[12:14]) {
+		case etherTypeARP:
+			d.handleARP(payload)
+			continue
+		case etherTypeIPv4:
+			if len(payload) < 20 || payload[0]>>4 != 4 {
+				continue
+			}
+			totalLen := int(binary.BigEndian.Uint16(payload[2:4]))
+			if totalLen < 20 || totalLen > len(payload) {
+				continue
+			}
+			payload = payload[:totalLen]
+			source = netip.AddrFrom4([4]byte(payload[12:16]))
+			destination = netip.AddrFrom4([4]byte(payload[16:20]))
+		case etherTypeIPv6:
+			if len(payload) < ipv6HeaderLen || payload[0]>>4 != 6 {
+				continue
+			}
+			totalLen := ipv6HeaderLen + int(binary.BigEndian.Uint16(payload[4:6]))
+			if totalLen > len(payload) {
+				continue
+			}
+			payload = payload[:totalLen]
+			if d.handleNDP(sourceHardwareAddr, payload) {
+				continue
+			}
+			source = netip.AddrFrom16([16]byte(payload[8:24]))
+			destination = netip.AddrFrom16([16]byte(payload[24:40]))
+		default:
+			continue
+		}
+		// broadcast and link-local traffic stays on the link
+		if destination == d.broadcastAddr || !destination.IsGlobalUnicast() {
+			continue
+		}
+		d.learn(source, sourceHardwareAddr)
+		return copy(p, payload), nil
+	}
+}
+
+func (d *tapDevice) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	var (
+		etherType   uint16
+		destination netip.Addr
+	)
+	switch p[0] >> 4 {
+	case 4:
+		if len(p) < 20 {
+			return 0, E.New("invalid IPv4 packet")
+		}
+		etherType = etherTypeIPv4
+		destination = netip.AddrFrom4([4]byte(p[16:20]))
+	case 6:
+		if len(p) < ipv6HeaderLen {
+			return 0, E.New("invalid IPv6 packet")
+		}
+		etherType = etherTypeIPv6
+		destination = netip.AddrFrom16([16]byte(p[24:40]))
+	default:
+		return 0, E.New("unknown IP version ", p[0]>>4)
+	}
+	destinationHardwareAddr, loaded := d.resolve(destination)
+	if !loaded {
+		// dropped as if lost, the sender retries after the host answers
+		d.solicit(destination)
+		return len(p), nil
+	}
+	err := d.writeFrame(destinationHardwareAddr, etherType, p)
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (d *tapDevice) WriteVectorised(buffers []*buf.Buffer) error {
+	defer buf.ReleaseMulti(buffers)
+	packetLen := buf.LenMulti(buffers)
+	packet := buf.NewSize(packetLen)
+	defer packet.Release()
+	buf.CopyMulti(packet.Extend(packetLen), buffers)
+	_, err := d.Write(packet.Bytes())
+	return err
+}
+
+func (d *tapDevice) writeFrame(destination net.HardwareAddr, etherType uint16, payload []byte) error {
+	frame := buf.NewSize(ethernetHeaderLen + len(payload))
+	defer frame.Release()
+	common.Must1(frame.Write(destination))
+	common.Must1(frame.Write(d.hardwareAddr))
+	binary.BigEndian.PutUint16(frame.Extend(2), etherType)
+	common.Must1(frame.Write(payload))
+	_, err := d.file.Write(frame.Bytes())
+	return err
+}
+
+func (d *tapDevice) learn(address netip.Addr, hardwareAddr net.HardwareAddr) {
+	if !address.IsValid() || address.IsUnspecified() || address.IsMulticast() || hardwareAddr[0]&1 != 0 {
+		return
+	}
+	if current, loaded := d.neighbors.Load(address); loaded && bytes.Equal(current, hardwareAddr) {
+		// refresh the age without copying the address again
+		d.neighbors.Store(address, current)
+		return
+	}
+	d.neighbors.Store(address, bytes.Clone(hardwareAddr))
+}
+
+func (d *tapDevice) resolve(address netip.Addr) (net.HardwareAddr, bool) {
+	switch {
+	case address == d.broadcastAddr || address == netip.AddrFrom4([4]byte{255, 255, 255, 255}):
+		return broadcastHardwareAddr, true
+	case address.Is4() && address.IsMulticast():
+		address4 := address.As4()
+		return net.HardwareAddr{0x01, 0x00, 0x5e, address4[1] & 0x7f, address4[2], address4[3]}, true
+	case address.Is6() && address.IsMulticast():
+		address16 := address.As16()
+		return net.HardwareAddr{0x33, 0x33, address16[12], address16[13], address16[14], address16[15]}, true
+	}
+	return d.neighbors.Load(address)
+}
+
+func (d *tapDevice) solicit(address netip.Addr) {
+	if address.Is4() {
+		source, loaded := sourceAddressFor(d.inet4Address, address)
+		if !loaded {
+			return
+		}
+		request := make([]byte, arpPacketLen)
+		buildARP(request, arpOpRequest, d.hardwareAddr, source, make(net.HardwareAddr, 6), address)
+		d.writeFrame(broadcastHardwareAddr, etherTypeARP, request)
+	} else {
+		source, loaded := sourceAddressFor(d.inet6Address, address)
+		if !loaded {
+			return
+		}
+		address16 := address.As16()
+		solicitedNode := netip.AddrFrom16([16]byte{0: 0xff, 1: 0x02, 11: 0x01, 12: 0xff, 13: address16[13], 14: address16[14], 15: address16[15]})
+		destinationHardwareAddr, _ := d.resolve(solicitedNode)
+		message := make([]byte, 32)
+		message[0] = ndpSolicitation
+		copy(message[8:24], address16[:])
+		message[24] = ndpOptionSourceLinkAddress
+		message[25] = 1
+		copy(message[26:32], d.hardwareAddr)
+		d.writeFrame(destinationHardwareAddr, etherTypeIPv6, buildICMPv6(source, solicitedNode, message))
+	}
+}
+
+func (d *tapDevice) handleARP(packet []byte) {
+	if len(packet) < arpPacketLen ||
+		binary.BigEndian.Uint16(packet[0:2]) != 1 ||
+		binary.BigEndian.Uint16(packet[2:4]) != etherTypeIPv4 ||
+		packet[4] != 6 || packet[5] != 4 {
+		return
+	}
+	senderHardwareAddr := net.HardwareAddr(packet[8:14])
+	if senderHardwareAddr[0]&1 != 0 {
+		return
+	}
+	senderAddress := netip.AddrFrom4([4]byte(packet[14:18]))
+	targetAddress := netip.AddrFrom4([4]byte(packet[24:28]))
+	d.learn(senderAddress, senderHardwareAddr)
+	if binary.BigEndian.Uint16(packet[6:8]) != arpOpRequest || !containsAddress(d.inet4Address, targetAddress) {
+		return
+	}
+	reply := make([]byte, arpPacketLen)
+	buildARP(reply, arpOpReply, d.hardwareAddr, targetAddress, senderHardwareAddr, senderAddress)
+	d.writeFrame(senderHardwareAddr, etherTypeARP, reply)
+}
+
+// handleNDP answers neighbor solicitations for the interface addresses and
+// learns neighbors from NDP messages, it reports whether the packet is consumed.
+func (d *tapDevice) handleNDP(sourceHardwareAddr net.HardwareAddr, packet []byte) bool {
+	if packet[6] != icmpv6Protocol || len(packet) < ipv6HeaderLen+24 {
+		return false
+	}
+	message := packet[ipv6HeaderLen:]
+	messageType := message[0]
+	if messageType != ndpSolicitation && messageType != ndpAdvertise {
+		return false
+	}
+	source := netip.AddrFrom16([16]byte(packet[8:24]))
+	target := netip.AddrFrom16([16]byte(message[8:24]))
+	linkAddress := sourceHardwareAddr
+	for options := message[24:]; len(options) >= 8 && options[1] > 0 && int(options[1])*8 <= len(options); options = options[int(options[1])*8:] {
+		if options[0] == ndpOptionSourceLinkAddress || options[0] == ndpOptionTargetLinkAddress {
+			linkAddress = net.HardwareAddr(options[2:8])
+		}
+	}
+	if messageType == ndpAdvertise {
+		d.learn(target, linkAddress)
+		return true
+	}
+	d.learn(source, linkAddress)
+	if !containsAddress(d.inet6Address, target) {
+		return true
+	}
+	destination := source
+	flags := byte(0xa0) // router, override
+	if source.IsUnspecified() {
+		destination = netip.MustParseAddr("ff02::1")
+	} else {
+		flags |= 0x40 // solicited
+	}
+	advertisement := make([]byte, 32)
+	advertisement[0] = ndpAdvertise
+	advertisement[4] = flags
+	copy(advertisement[8:24], message[8:24])
+	advertisement[24] = ndpOptionTargetLinkAddress
+	advertisement[25] = 1
+	copy(advertisement[26:32], d.hardwareAddr)
+	destinationHardwareAddr, loaded := d.resolve(destination)
+	if !loaded {
+		destinationHardwareAddr = linkAddress
+	}
+	d.writeFrame(destinationHardwareAddr, etherTypeIPv6, buildICMPv6(target, destination, advertisement))
+	return true
+}
+
+func buildARP(packet []byte, operation uint16, senderHardwareAddr net.HardwareAddr, senderAddress netip.Addr, targetHardwareAddr net.HardwareAddr, targetAddress netip.Addr) {
+	binary.BigEndian.PutUint16(packet[0:2], 1)
+	binary.BigEndian.PutUint16(packet[2:4], etherTypeIPv4)
+	packet[4] = 6
+	packet[5] = 4
+	binary.BigEndian.PutUint16(packet[6:8], operation)
+	copy(packet[8:14], senderHardwareAddr)
+	senderAddress4 := senderAddress.As4()
+	copy(packet[14:18], senderAddress4[:])
+	copy(packet[18:24], targetHardwareAddr)
+	targetAddress4 := targetAddress.As4()
+	copy(packet[24:28], targetAddress4[:])
+}
+
+func buildICMPv6(source netip.Addr, destination netip.Addr, message []byte) []byte {
+	packet := make([]byte, ipv6HeaderLen+len(message))
+	packet[0] = 0x60
+	binary.BigEndian.PutUint16(packet[4:6], uint16(len(message)))
+	packet[6] = icmpv6Protocol
+	packet[7] = 255
+	source16 := source.As16()
+	copy(packet[8:24], source16[:])
+	destination16 := destination.As16()
+	copy(packet[24:40], destination16[:])
+	copy(packet[ipv6HeaderLen:], message)
+	var sum uint32
+	addSum := func(data []byte) {
+		for i := 0; i+1 < len(data); i += 2 {
+			sum += uint32(binary.BigEndian.Uint16(data[i:]))
+		}
+		if len(data)%2 == 1 {
+			sum += uint32(data[len(data)-1]) << 8
+		}
+	}
+	addSum(packet[8:40])
+	sum += uint32(len(message)) + icmpv6Protocol
+	addSum(packet[ipv6HeaderLen:])
+	for sum > 0xffff {
+		sum = sum>>16 + sum&0xffff
+	}
+	binary.BigEndian.PutUint16(packet[ipv6HeaderLen+2:], ^uint16(sum))
+	return packet
+}
+
+func containsAddress(prefixes []netip.Prefix, address netip.Addr) bool {
+	return common.Any(prefixes, func(it netip.Prefix) bool {
+		return it.Addr() == address
+	})
+}
+
+func sourceAddressFor(prefixes []netip.Prefix, destination netip.Addr) (netip.Addr, bool) {
+	if len(prefixes) == 0 {
+		return netip.Addr{}, false
+	}
+	for _, prefix := range prefixes {
+		if prefix.Contains(destination) {
+			return prefix.Addr(), true
+		}
+	}
+	return prefixes[0].Addr(), true
+}
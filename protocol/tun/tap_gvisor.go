@@ -0,0 +1,128 @@
+//go:build with_gvisor
+
+package tun
+
+import (
+	"github.com/sagernet/gvisor/pkg/buffer"
+	"github.com/sagernet/gvisor/pkg/tcpip"
+	"github.com/sagernet/gvisor/pkg/tcpip/header"
+	"github.com/sagernet/gvisor/pkg/tcpip/stack"
+	"github.com/sagernet/sing-tun"
+	"github.com/sagernet/sing/common/bufio"
+)
+
+var _ tun.GVisorTun = (*tapDevice)(nil)
+
+func (d *tapDevice) NewEndpoint() (stack.LinkEndpoint, error) {
+	return &tapEndpoint{device: d, mtu: uint32(len(d.frameBuffer) - ethernetHeaderLen)}, nil
+}
+
+var _ stack.LinkEndpoint = (*tapEndpoint)(nil)
+
+// tapEndpoint passes the IP packets of a tapDevice to the gVisor stack, the
+// link layer is handled by the device.
+type tapEndpoint struct {
+	device     *tapDevice
+	mtu        uint32
+	dispatcher stack.NetworkDispatcher
+}
+
+func (e *tapEndpoint) MTU() uint32 {
+	return e.mtu
+}
+
+func (e *tapEndpoint) SetMTU(mtu uint32) {
+}
+
+func (e *tapEndpoint) MaxHeaderLength() uint16 {
+	return 0
+}
+
+func (e *tapEndpoint) LinkAddress() tcpip.LinkAddress {
+	return ""
+}
+
+func (e *tapEndpoint) SetLinkAddress(addr tcpip.LinkAddress) {
+}
+
+func (e *tapEndpoint) Capabilities() stack.LinkEndpointCapabilities {
+	return 0
+}
+
+func (e *tapEndpoint) Attach(dispatcher stack.NetworkDispatcher) {
+	if dispatcher == nil && e.dispatcher != nil {
+		e.dispatcher = nil
+		return
+	}
+	if dispatcher != nil && e.dispatcher == nil {
+		e.dispatcher = dispatcher
+		go e.dispatchLoop()
+	}
+}
+
+func (e *tapEndpoint) dispatchLoop() {
+	packetBuffer := make([]byte, e.mtu)
+	for {
+		n, err := e.device.Read(packetBuffer)
+		if err != nil {
+			break
+		}
+		var networkProtocol tcpip.NetworkProtocolNumber
+		switch header.IPVersion(packetBuffer[:n]) {
+		case header.IPv4Version:
+			networkProtocol = header.IPv4ProtocolNumber
+		case header.IPv6Version:
+			networkProtocol = header.IPv6ProtocolNumber
+		default:
+			continue
+		}
+		pkt := stack.NewPacketBuffer(stack.PacketBufferOptions{
+			Payload:           buffer.MakeWithData(packetBuffer[:n]),
+			IsForwardedPacket: true,
+		})
+		pkt.NetworkProtocolNumber = networkProtocol
+		dispatcher := e.dispatcher
+		if dispatcher == nil {
+			pkt.DecRef()
+			return
+		}
+		dispatcher.DeliverNetworkPacket(networkProtocol, pkt)
+		pkt.DecRef()
+	}
+}
+
+func (e *tapEndpoint) IsAttached() bool {
+	return e.dispatcher != nil
+}
+
+func (e *tapEndpoint) Wait() {
+}
+
+func (e *tapEndpoint) ARPHardwareType() header.ARPHardwareType {
+	return header.ARPHardwareNone
+}
+
+func (e *tapEndpoint) AddHeader(buffer *stack.PacketBuffer) {
+}
+
+func (e *tapEndpoint) ParseHeader(ptr *stack.PacketBuffer) bool {
+	return true
+}
+
+func (e *tapEndpoint) WritePackets(packetBufferList stack.PacketBufferList) (int, tcpip.Error) {
+	var n int
+	for _, packet := range packetBufferList.AsSlice() {
+		_, err := bufio.WriteVectorised(e.device, packet.AsSlices())
+		if err != nil {
+			return n, &tcpip.ErrAborted{}
+		}
+		n++
+	}
+	return n, nil
+}
+
+func (e *tapEndpoint) Close() {
+}
+
+func (e *tapEndpoint) SetOnCloseAction(f func()) {
+}
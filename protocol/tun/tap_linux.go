@@ -0,0 +1,60 @@
+package tun
+
+import (
+	"net"
+	"os"
+
+	"github.com/sagernet/netlink"
+	"github.com/sagernet/sing-tun"
+	E "github.com/sagernet/sing/common/exceptions"
+
+	"golang.org/x/sys/unix"
+)
+
+func newTAPDevice(options tun.Options, hardwareAddr net.HardwareAddr, bridge string) (*tapDevice, error) {
+	fd, err := unix.Open("/dev/net/tun", unix.O_RDWR|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+	err = configureTAP(fd, options, bridge)
+	if err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+	return newTAPDeviceFromFile(options.Name, os.NewFile(uintptr(fd), "tap"), options, hardwareAddr), nil
+}
+
+func configureTAP(fd int, options tun.Options, bridge string) error {
+	ifr, err := unix.NewIfreq(options.Name)
+	if err != nil {
+		return err
+	}
+	ifr.SetUint16(unix.IFF_TAP | unix.IFF_NO_PI)
+	err = unix.IoctlIfreq(fd, unix.TUNSETIFF, ifr)
+	if err != nil {
+		return E.Cause(err, "create tap interface")
+	}
+	err = unix.SetNonblock(fd, true)
+	if err != nil {
+		return err
+	}
+	tapLink, err := netlink.LinkByName(options.Name)
+	if err != nil {
+		return err
+	}
+	err = netlink.LinkSetMTU(tapLink, int(options.MTU))
+	if err != nil {
+		return E.Cause(err, "set mtu")
+	}
+	if bridge != "" {
+		bridgeLink, err := netlink.LinkByName(bridge)
+		if err != nil {
+			return E.Cause(err, "find bridge ", bridge)
+		}
+		err = netlink.LinkSetMaster(tapLink, bridgeLink)
+		if err != nil {
+			return E.Cause(err, "attach to bridge ", bridge)
+		}
+	}
+	return netlink.LinkSetUp(tapLink)
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package tun
+
+import (
+	"net"
+	"os"
+
+	"github.com/sagernet/sing-tun"
+)
+
+func newTAPDevice(options tun.Options, hardwareAddr net.HardwareAddr, bridge string) (*tapDevice, error) {
+	return nil, os.ErrInvalid
+}
@@ -0,0 +1,294 @@
+package tun
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/sagernet/sing-tun"
+
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testGatewayHardwareAddr = net.HardwareAddr{0x02, 0, 0, 0, 0, 1}
+	testHostHardwareAddr    = net.HardwareAddr{0x02, 0, 0, 0, 0, 2}
+	testGateway4            = netip.MustParseAddr("172.19.0.1")
+	testHost4               = netip.MustParseAddr("172.19.0.2")
+	testGateway6            = netip.MustParseAddr("fdfe:dcba:9876::1")
+	testHost6               = netip.MustParseAddr("fdfe:dcba:9876::2")
+)
+
+// frameFile serves queued frames to reads and records written frames.
+type frameFile struct {
+	frames  [][]byte
+	written [][]byte
+}
+
+func (f *frameFile) Read(p []byte) (int, error) {
+	if len(f.frames) == 0 {
+		return 0, io.EOF
+	}
+	frame := f.frames[0]
+	f.frames = f.frames[1:]
+	return copy(p, frame), nil
+}
+
+func (f *frameFile) Write(p []byte) (int, error) {
+	f.written = append(f.written, append([]byte(nil), p...))
+	return len(p), nil
+}
+
+func (f *frameFile) Close() error {
+	return nil
+}
+
+func newTestTAPDevice(frames ...[]byte) (*tapDevice, *frameFile) {
+	file := &frameFile{frames: frames}
+	device := newTAPDeviceFromFile("tap0", file, tun.Options{
+		Inet4Address: []netip.Prefix{netip.PrefixFrom(testGateway4, 30)},
+		Inet6Address: []netip.Prefix{netip.PrefixFrom(testGateway6, 126)},
+		MTU:          1500,
+	}, testGatewayHardwareAddr)
+	return device, file
+}
+
+func ethernetFrame(destination net.HardwareAddr, source net.HardwareAddr, etherType uint16, payload []byte) []byte {
+	frame := append(append([]byte(nil), destination...), source...)
+	frame = binary.BigEndian.AppendUint16(frame, etherType)
+	return append(frame, payload...)
+}
+
+func arpPacket(operation uint16, senderHardwareAddr net.HardwareAddr, senderAddress netip.Addr, targetAddress netip.Addr) []byte {
+	packet := make([]byte, arpPacketLen)
+	buildARP(packet, operation, senderHardwareAddr, senderAddress, make(net.HardwareAddr, 6), targetAddress)
+	return packet
+}
+
+func ipv4Packet(source netip.Addr, destination netip.Addr, payload string) []byte {
+	packet := make([]byte, 20, 20+len(payload))
+	packet[0] = 0x45
+	binary.BigEndian.PutUint16(packet[2:4], uint16(20+len(payload)))
+	packet[9] = 17
+	source4, destination4 := source.As4(), destination.As4()
+	copy(packet[12:16], source4[:])
+	copy(packet[16:20], destination4[:])
+	return append(packet, payload...)
+}
+
+func ndpMessage(messageType byte, target netip.Addr, optionType byte, hardwareAddr net.HardwareAddr) []byte {
+	message := make([]byte, 24)
+	message[0] = messageType
+	target16 := target.As16()
+	copy(message[8:24], target16[:])
+	if hardwareAddr != nil {
+		message = append(message, optionType, 1)
+		message = append(message, hardwareAddr...)
+	}
+	return message
+}
+
+func requireICMPv6Checksum(t *testing.T, packet []byte) {
+	var sum uint32
+	for i := 8; i < 40; i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(packet[i:]))
+	}
+	sum += uint32(len(packet)-ipv6HeaderLen) + icmpv6Protocol
+	for i := ipv6HeaderLen; i+1 < len(packet); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(packet[i:]))
+	}
+	for sum > 0xffff {
+		sum = sum>>16 + sum&0xffff
+	}
+	require.Equal(t, uint32(0xffff), sum)
+}
+
+func TestTAPARP(t *testing.T) {
+	t.Parallel()
+	device, file := newTestTAPDevice(
+		ethernetFrame(broadcastHardwareAddr, testHostHardwareAddr, etherTypeARP, arpPacket(arpOpRequest, testHostHardwareAddr, testHost4, testGateway4)),
+		// request for another host, only learned
+		ethernetFrame(broadcastHardwareAddr, testHostHardwareAddr, etherTypeARP, arpPacket(arpOpRequest, testHostHardwareAddr, testHost4, netip.MustParseAddr("172.19.0.3"))),
+	)
+	_, err := device.Read(make([]byte, 1500))
+	require.ErrorIs(t, err, io.EOF)
+	require.Len(t, file.written, 1)
+	reply := file.written[0]
+	require.Equal(t, ethernetFrame(testHostHardwareAddr, testGatewayHardwareAddr, etherTypeARP, nil), reply[:ethernetHeaderLen])
+	expected := make([]byte, arpPacketLen)
+	buildARP(expected, arpOpReply, testGatewayHardwareAddr, testGateway4, testHostHardwareAddr, testHost4)
+	require.Equal(t, expected, reply[ethernetHeaderLen:])
+	hardwareAddr, loaded := device.Neighbor(testHost4)
+	require.True(t, loaded)
+	require.Equal(t, testHostHardwareAddr, hardwareAddr)
+}
+
+func TestTAPMalformedARP(t *testing.T) {
+	t.Parallel()
+	request := arpPacket(arpOpRequest, testHostHardwareAddr, testHost4, testGateway4)
+	badHardwareType := append([]byte(nil), request...)
+	badHardwareType[1] = 6
+	badProtocolType := append([]byte(nil), request...)
+	binary.BigEndian.PutUint16(badProtocolType[2:4], etherTypeIPv6)
+	badAddressLen := append([]byte(nil), request...)
+	badAddressLen[5] = 16
+	multicastSender := arpPacket(arpOpRequest, net.HardwareAddr{0x01, 0, 0, 0, 0, 2}, testHost4, testGateway4)
+	var frames [][]byte
+	for _, packet := range [][]byte{request[:arpPacketLen-1], badHardwareType, badProtocolType, badAddressLen, multicastSender} {
+		frames = append(frames, ethernetFrame(broadcastHardwareAddr, testHostHardwareAddr, etherTypeARP, packet))
+	}
+	device, file := newTestTAPDevice(frames...)
+	_, err := device.Read(make([]byte, 1500))
+	require.ErrorIs(t, err, io.EOF)
+	require.Empty(t, file.written)
+	_, loaded := device.Neighbor(testHost4)
+	require.False(t, loaded)
+}
+
+func TestTAPNDP(t *testing.T) {
+	t.Parallel()
+	solicitation := buildICMPv6(testHost6, testGateway6, ndpMessage(ndpSolicitation, testGateway6, ndpOptionSourceLinkAddress, testHostHardwareAddr))
+	otherHost := netip.MustParseAddr("fdfe:dcba:9876::3")
+	otherHardwareAddr := net.HardwareAddr{0x02, 0, 0, 0, 0, 3}
+	advertisement := buildICMPv6(otherHost, testGateway6, ndpMessage(ndpAdvertise, otherHost, ndpOptionTargetLinkAddress, otherHardwareAddr))
+	device, file := newTestTAPDevice(
+		ethernetFrame(testGatewayHardwareAddr, testHostHardwareAddr, etherTypeIPv6, solicitation),
+		ethernetFrame(testGatewayHardwareAddr, otherHardwareAddr, etherTypeIPv6, advertisement),
+	)
+	_, err := device.Read(make([]byte, 1500))
+	require.ErrorIs(t, err, io.EOF)
+	require.Len(t, file.written, 1)
+	reply := file.written[0]
+	require.Equal(t, ethernetFrame(testHostHardwareAddr, testGatewayHardwareAddr, etherTypeIPv6, nil), reply[:ethernetHeaderLen])
+	packet := reply[ethernetHeaderLen:]
+	requireICMPv6Checksum(t, packet)
+	require.Equal(t, testGateway6, netip.AddrFrom16([16]byte(packet[8:24])))
+	require.Equal(t, testHost6, netip.AddrFrom16([16]byte(packet[24:40])))
+	message := packet[ipv6HeaderLen:]
+	require.Equal(t, byte(ndpAdvertise), message[0])
+	require.Equal(t, byte(0xe0), message[4])
+	require.Equal(t, testGateway6, netip.AddrFrom16([16]byte(message[8:24])))
+	require.Equal(t, []byte{ndpOptionTargetLinkAddress, 1}, message[24:26])
+	require.Equal(t, []byte(testGatewayHardwareAddr), message[26:32])
+	for address, hardwareAddr := range map[netip.Addr]net.HardwareAddr{testHost6: testHostHardwareAddr, otherHost: otherHardwareAddr} {
+		learned, loaded := device.Neighbor(address)
+		require.True(t, loaded)
+		require.Equal(t, hardwareAddr, learned)
+	}
+}
+
+func TestTAPNDPDuplicateAddressDetection(t *testing.T) {
+	t.Parallel()
+	solicitation := buildICMPv6(netip.IPv6Unspecified(), netip.MustParseAddr("ff02::1:ff00:1"), ndpMessage(ndpSolicitation, testGateway6, 0, nil))
+	device, file := newTestTAPDevice(ethernetFrame(net.HardwareAddr{0x33, 0x33, 0xff, 0, 0, 1}, testHostHardwareAddr, etherTypeIPv6, solicitation))
+	_, err := device.Read(make([]byte, 1500))
+	require.ErrorIs(t, err, io.EOF)
+	require.Len(t, file.written, 1)
+	reply := file.written[0]
+	require.Equal(t, []byte{0x33, 0x33, 0, 0, 0, 1}, reply[:6])
+	packet := reply[ethernetHeaderLen:]
+	requireICMPv6Checksum(t, packet)
+	require.Equal(t, netip.MustParseAddr("ff02::1"), netip.AddrFrom16([16]byte(packet[24:40])))
+	require.Equal(t, byte(0xa0), packet[ipv6HeaderLen+4])
+}
+
+func TestTAPMalformedNDP(t *testing.T) {
+	t.Parallel()
+	// a zero option length must not loop, a truncated option is ignored
+	zeroLengthOption := ndpMessage(ndpAdvertise, testHost6, ndpOptionTargetLinkAddress, net.HardwareAddr{0x02, 0, 0, 0, 0, 9})
+	zeroLengthOption[25] = 0
+	truncatedOption := ndpMessage(ndpAdvertise, testHost6, ndpOptionTargetLinkAddress, net.HardwareAddr{0x02, 0, 0, 0, 0, 9})
+	truncatedOption[25] = 2
+	device, file := newTestTAPDevice(
+		ethernetFrame(testGatewayHardwareAddr, testHostHardwareAddr, etherTypeIPv6, buildICMPv6(testHost6, testGateway6, zeroLengthOption)),
+		ethernetFrame(testGatewayHardwareAddr, testHostHardwareAddr, etherTypeIPv6, buildICMPv6(testHost6, testGateway6, truncatedOption)),
+	)
+	_, err := device.Read(make([]byte, 1500))
+	require.ErrorIs(t, err, io.EOF)
+	require.Empty(t, file.written)
+	hardwareAddr, loaded := device.Neighbor(testHost6)
+	require.True(t, loaded)
+	require.Equal(t, testHostHardwareAddr, hardwareAddr)
+}
+
+func TestTAPRead(t *testing.T) {
+	t.Parallel()
+	packet := ipv4Packet(testHost4, netip.MustParseAddr("1.1.1.1"), "hello")
+	truncated := ipv4Packet(testHost4, netip.MustParseAddr("1.1.1.1"), "hello")
+	binary.BigEndian.PutUint16(truncated[2:4], 100)
+	badVersion := ipv4Packet(testHost4, netip.MustParseAddr("1.1.1.1"), "hello")
+	badVersion[0] = 0x65
+	truncated6 := make([]byte, ipv6HeaderLen)
+	truncated6[0] = 0x60
+	binary.BigEndian.PutUint16(truncated6[4:6], 8)
+	device, _ := newTestTAPDevice(
+		ethernetFrame(testGatewayHardwareAddr, testHostHardwareAddr, etherTypeIPv4, nil)[:ethernetHeaderLen-1],
+		// another host on the link
+		ethernetFrame(net.HardwareAddr{0x02, 0, 0, 0, 0, 3}, testHostHardwareAddr, etherTypeIPv4, packet),
+		ethernetFrame(testGatewayHardwareAddr, testHostHardwareAddr, etherTypeIPv4, packet[:19]),
+		ethernetFrame(testGatewayHardwareAddr, testHostHardwareAddr, etherTypeIPv4, truncated),
+		ethernetFrame(testGatewayHardwareAddr, testHostHardwareAddr, etherTypeIPv4, badVersion),
+		ethernetFrame(testGatewayHardwareAddr, testHostHardwareAddr, etherTypeIPv6, truncated6),
+		ethernetFrame(testGatewayHardwareAddr, testHostHardwareAddr, 0x88cc, packet),
+		ethernetFrame(broadcastHardwareAddr, testHostHardwareAddr, etherTypeIPv4, ipv4Packet(testHost4, netip.MustParseAddr("172.19.0.3"), "hello")),
+		ethernetFrame(broadcastHardwareAddr, testHostHardwareAddr, etherTypeIPv4, ipv4Packet(testHost4, netip.MustParseAddr("169.254.0.1"), "hello")),
+		// padded to the minimum frame size
+		ethernetFrame(testGatewayHardwareAddr, testHostHardwareAddr, etherTypeIPv4, append(append([]byte(nil), packet...), make([]byte, 21)...)),
+	)
+	buffer := make([]byte, 1500)
+	n, err := device.Read(buffer)
+	require.NoError(t, err)
+	require.Equal(t, packet, buffer[:n])
+	hardwareAddr, loaded := device.Neighbor(testHost4)
+	require.True(t, loaded)
+	require.Equal(t, testHostHardwareAddr, hardwareAddr)
+	_, err = device.Read(buffer)
+	require.ErrorIs(t, err, io.EOF)
+}
+
+func TestTAPWrite(t *testing.T) {
+	t.Parallel()
+	device, file := newTestTAPDevice()
+	packet := ipv4Packet(netip.MustParseAddr("1.1.1.1"), testHost4, "hello")
+
+	// unknown neighbors are solicited and the packet is dropped
+	n, err := device.Write(packet)
+	require.NoError(t, err)
+	require.Equal(t, len(packet), n)
+	require.Len(t, file.written, 1)
+	require.Equal(t, ethernetFrame(broadcastHardwareAddr, testGatewayHardwareAddr, etherTypeARP, nil), file.written[0][:ethernetHeaderLen])
+	expected := make([]byte, arpPacketLen)
+	buildARP(expected, arpOpRequest, testGatewayHardwareAddr, testGateway4, make(net.HardwareAddr, 6), testHost4)
+	require.Equal(t, expected, file.written[0][ethernetHeaderLen:])
+
+	packet6 := make([]byte, ipv6HeaderLen)
+	packet6[0] = 0x60
+	testHost16 := testHost6.As16()
+	copy(packet6[24:40], testHost16[:])
+	_, err = device.Write(packet6)
+	require.NoError(t, err)
+	require.Len(t, file.written, 2)
+	solicitation := file.written[1]
+	require.Equal(t, []byte{0x33, 0x33, 0xff, 0, 0, 2}, solicitation[:6])
+	requireICMPv6Checksum(t, solicitation[ethernetHeaderLen:])
+	require.Equal(t, netip.MustParseAddr("ff02::1:ff00:2"), netip.AddrFrom16([16]byte(solicitation[ethernetHeaderLen+24:ethernetHeaderLen+40])))
+	require.Equal(t, byte(ndpSolicitation), solicitation[ethernetHeaderLen+ipv6HeaderLen])
+
+	device.learn(testHost4, testHostHardwareAddr)
+	_, err = device.Write(packet)
+	require.NoError(t, err)
+	require.Equal(t, ethernetFrame(testHostHardwareAddr, testGatewayHardwareAddr, etherTypeIPv4, packet), file.written[2])
+
+	_, err = device.Write(ipv4Packet(testGateway4, netip.MustParseAddr("224.0.0.251"), "mdns"))
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x01, 0x00, 0x5e, 0x00, 0x00, 0xfb}, file.written[3][:6])
+
+	_, err = device.Write(packet[:19])
+	require.Error(t, err)
+	_, err = device.Write(packet6[:ipv6HeaderLen-1])
+	require.Error(t, err)
+	_, err = device.Write([]byte{0x50})
+	require.Error(t, err)
+}
@@ -4,13 +4,17 @@ import (
 	"context"
 	"net"
 	"os"
+	"sync"
+	"sync/atomic"
 
 	"github.com/sagernet/sing-box/adapter"
 	"github.com/sagernet/sing-box/adapter/inbound"
 	"github.com/sagernet/sing-box/common/listener"
 	"github.com/sagernet/sing-box/common/mux"
+	"github.com/sagernet/sing-box/common/packetfragment"
 	"github.com/sagernet/sing-box/common/tls"
 	"github.com/sagernet/sing-box/common/uot"
+	"github.com/sagernet/sing-box/common/userfile"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/log"
 	"github.com/sagernet/sing-box/option"
@@ -35,14 +39,16 @@ var _ adapter.TCPInjectableInbound = (*Inbound)(nil)
 
 type Inbound struct {
 	inbound.Adapter
-	ctx       context.Context
-	router    adapter.ConnectionRouterEx
-	logger    logger.ContextLogger
-	listener  *listener.Listener
-	users     []option.VLESSUser
-	service   *vless.Service[int]
-	tlsConfig tls.ServerConfig
-	transport adapter.V2RayServerTransport
+	ctx          context.Context
+	router       adapter.ConnectionRouterEx
+	logger       logger.ContextLogger
+	listener     *listener.Listener
+	access       sync.RWMutex
+	users        []option.VLESSUser
+	usersWatcher *userfile.Watcher[option.VLESSUser]
+	service      atomic.Pointer[vless.Service[int]]
+	tlsConfig    tls.ServerConfig
+	transport    adapter.V2RayServerTransport
 }
 
 func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.VLESSInboundOptions) (adapter.Inbound, error) {
@@ -51,22 +57,23 @@ func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLo
 		ctx:     ctx,
 		router:  uot.NewRouter(router, logger),
 		logger:  logger,
-		users:   options.Users,
 	}
 	var err error
 	inbound.router, err = mux.NewRouterWithOptions(inbound.router, logger, common.PtrValueOrDefault(options.Multiplex))
 	if err != nil {
 		return nil, err
 	}
-	service := vless.NewService[int](logger, adapter.NewUpstreamContextHandlerEx(inbound.newConnectionEx, inbound.newPacketConnectionEx))
-	service.UpdateUsers(common.MapIndexed(inbound.users, func(index int, _ option.VLESSUser) int {
-		return index
-	}), common.Map(inbound.users, func(it option.VLESSUser) string {
-		return it.UUID
-	}), common.Map(inbound.users, func(it option.VLESSUser) string {
-		return it.Flow
-	}))
-	inbound.service = service
+	if options.UsersPath != "" {
+		inbound.usersWatcher, err = userfile.NewWatcher(ctx, logger, options.UsersPath, func(users []option.VLESSUser) error {
+			inbound.updateUsers(append(append([]option.VLESSUser(nil), options.Users...), users...))
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		inbound.updateUsers(options.Users)
+	}
 	if options.TLS != nil {
 		inbound.tlsConfig, err = tls.NewServer(ctx, logger, common.PtrValueOrDefault(options.TLS))
 		if err != nil {
@@ -80,19 +87,52 @@ func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLo
 		}
 	}
 	inbound.listener = listener.New(listener.Options{
-		Context:           ctx,
-		Logger:            logger,
-		Network:           []string{N.NetworkTCP},
-		Listen:            options.ListenOptions,
-		ConnectionHandler: inbound,
+		Context:             ctx,
+		Logger:              logger,
+		Network:             []string{N.NetworkTCP},
+		Listen:              options.ListenOptions,
+		AcceptProxyProtocol: options.AcceptProxyProtocol,
+		ConnectionHandler:   inbound,
 	})
 	return inbound, nil
 }
 
+// updateUsers builds a new service for the users and swaps it in, as the users
+// of a running service can not be updated while handshakes read them.
+func (h *Inbound) updateUsers(users []option.VLESSUser) {
+	service := vless.NewService[int](h.logger, adapter.NewUpstreamContextHandlerEx(h.newConnectionEx, h.newPacketConnectionEx))
+	service.UpdateUsers(common.MapIndexed(users, func(index int, _ option.VLESSUser) int {
+		return index
+	}), common.Map(users, func(it option.VLESSUser) string {
+		return it.UUID
+	}), common.Map(users, func(it option.VLESSUser) string {
+		return it.Flow
+	}))
+	h.access.Lock()
+	h.users = users
+	h.service.Store(service)
+	h.access.Unlock()
+}
+
+func (h *Inbound) userName(userIndex int) string {
+	h.access.RLock()
+	defer h.access.RUnlock()
+	if userIndex >= len(h.users) {
+		return ""
+	}
+	return h.users[userIndex].Name
+}
+
 func (h *Inbound) Start(stage adapter.StartStage) error {
 	if stage != adapter.StartStateStart {
 		return nil
 	}
+	if h.usersWatcher != nil {
+		err := h.usersWatcher.Start()
+		if err != nil {
+			return err
+		}
+	}
 	if h.tlsConfig != nil {
 		err := h.tlsConfig.Start()
 		if err != nil {
@@ -131,7 +171,7 @@ func (h *Inbound) Start(stage adapter.StartStage) error {
 
 func (h *Inbound) Close() error {
 	return common.Close(
-		h.service,
+		common.PtrOrNil(h.usersWatcher),
 		h.listener,
 		h.tlsConfig,
 		h.transport,
@@ -140,7 +180,7 @@ func (h *Inbound) Close() error {
 
 func (h *Inbound) NewConnectionEx(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
 	if h.tlsConfig != nil && h.transport == nil {
-		tlsConn, err := tls.ServerHandshake(ctx, conn, h.tlsConfig)
+		tlsConn, err := tls.ServerHandshake(adapter.WithContext(ctx, &metadata), conn, h.tlsConfig)
 		if err != nil {
 			N.CloseOnHandshakeFailure(conn, onClose, err)
 			h.logger.ErrorContext(ctx, E.Cause(err, "process connection from ", metadata.Source, ": TLS handshake"))
@@ -148,7 +188,7 @@ func (h *Inbound) NewConnectionEx(ctx context.Context, conn net.Conn, metadata a
 		}
 		conn = tlsConn
 	}
-	err := h.service.NewConnection(adapter.WithContext(ctx, &metadata), conn, metadata.Source, onClose)
+	err := h.service.Load().NewConnection(adapter.WithContext(ctx, &metadata), conn, metadata.Source, onClose)
 	if err != nil {
 		N.CloseOnHandshakeFailure(conn, onClose, err)
 		h.logger.ErrorContext(ctx, E.Cause(err, "process connection from ", metadata.Source))
@@ -163,7 +203,7 @@ func (h *Inbound) newConnectionEx(ctx context.Context, conn net.Conn, metadata a
 		N.CloseOnHandshakeFailure(conn, onClose, os.ErrInvalid)
 		return
 	}
-	user := h.users[userIndex].Name
+	user := h.userName(userIndex)
 	if user == "" {
 		user = F.ToString(userIndex)
 	} else {
@@ -181,13 +221,27 @@ func (h *Inbound) newPacketConnectionEx(ctx context.Context, conn N.PacketConn,
 		N.CloseOnHandshakeFailure(conn, onClose, os.ErrInvalid)
 		return
 	}
-	user := h.users[userIndex].Name
+	user := h.userName(userIndex)
 	if user == "" {
 		user = F.ToString(userIndex)
 	} else {
 		metadata.User = user
 	}
-	if metadata.Destination.Fqdn == packetaddr.SeqPacketMagicAddress {
+	if metadata.Destination.Fqdn == packetfragment.MagicAddress {
+		fragmentSize := int(metadata.Destination.Port)
+		if fragmentSize < packetfragment.MinSize {
+			N.CloseOnHandshakeFailure(conn, onClose, E.New("invalid packet fragment size: ", fragmentSize))
+			return
+		}
+		metadata.Destination = M.Socksaddr{}
+		fragmentConn, err := packetfragment.NewServerConn(packetaddr.NewConn(conn.(vmess.PacketConn), metadata.Destination), fragmentSize)
+		if err != nil {
+			N.CloseOnHandshakeFailure(conn, onClose, err)
+			return
+		}
+		conn = fragmentConn
+		h.logger.InfoContext(ctx, "[", user, "] inbound packet addr connection with fragment size ", fragmentSize)
+	} else if metadata.Destination.Fqdn == packetaddr.SeqPacketMagicAddress {
 		metadata.Destination = M.Socksaddr{}
 		conn = packetaddr.NewConn(conn.(vmess.PacketConn), metadata.Destination)
 		h.logger.InfoContext(ctx, "[", user, "] inbound packet addr connection")
@@ -8,6 +8,8 @@ import (
 	"github.com/sagernet/sing-box/adapter/outbound"
 	"github.com/sagernet/sing-box/common/dialer"
 	"github.com/sagernet/sing-box/common/mux"
+	"github.com/sagernet/sing-box/common/packetfragment"
+	"github.com/sagernet/sing-box/common/preconnect"
 	"github.com/sagernet/sing-box/common/tls"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/log"
@@ -29,19 +31,21 @@ func RegisterOutbound(registry *outbound.Registry) {
 
 type Outbound struct {
 	outbound.Adapter
-	logger          logger.ContextLogger
-	dialer          N.Dialer
-	client          *vless.Client
-	serverAddr      M.Socksaddr
-	multiplexDialer *mux.Client
-	tlsConfig       tls.Config
-	transport       adapter.V2RayClientTransport
-	packetAddr      bool
-	xudp            bool
+	logger             logger.ContextLogger
+	dialer             N.Dialer
+	client             *vless.Client
+	serverAddr         M.Socksaddr
+	multiplexDialer    *mux.Client
+	tlsConfig          tls.Config
+	transport          adapter.V2RayClientTransport
+	preConnect         *preconnect.Pool
+	packetAddr         bool
+	xudp               bool
+	packetFragmentSize uint16
 }
 
 func NewOutbound(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.VLESSOutboundOptions) (adapter.Outbound, error) {
-	outboundDialer, err := dialer.New(ctx, options.DialerOptions)
+	outboundDialer, err := dialer.NewServer(ctx, options.DialerOptions, options.ServerOptions.Build())
 	if err != nil {
 		return nil, err
 	}
@@ -64,7 +68,7 @@ func NewOutbound(ctx context.Context, router adapter.Router, logger log.ContextL
 		}
 	}
 	if options.PacketEncoding == nil {
-		outbound.xudp = true
+		outbound.xudp = options.PacketFragmentSize == 0
 	} else {
 		switch *options.PacketEncoding {
 		case "":
@@ -84,6 +88,24 @@ func NewOutbound(ctx context.Context, router adapter.Router, logger log.ContextL
 	if err != nil {
 		return nil, err
 	}
+	if options.PreConnect != nil && options.PreConnect.Enabled {
+		if outbound.multiplexDialer != nil {
+			return nil, E.New("pre-connect is not available when multiplex is enabled")
+		}
+		outbound.preConnect = preconnect.New(ctx, logger, *options.PreConnect, outbound.connectServer)
+	}
+	if options.PacketFragmentSize > 0 {
+		if options.PacketFragmentSize < packetfragment.MinSize {
+			return nil, E.New("packet fragment size must be at least ", packetfragment.MinSize)
+		}
+		if outbound.xudp {
+			return nil, E.New("packet fragment is not available with xudp packet encoding")
+		}
+		if outbound.multiplexDialer != nil {
+			return nil, E.New("packet fragment is not available when multiplex is enabled")
+		}
+		outbound.packetFragmentSize = options.PacketFragmentSize
+	}
 	return outbound, nil
 }
 
@@ -124,11 +146,32 @@ func (h *Outbound) InterfaceUpdated() {
 	if h.multiplexDialer != nil {
 		h.multiplexDialer.Reset()
 	}
+	if h.preConnect != nil {
+		h.preConnect.Reset()
+	}
 	return
 }
 
+func (h *Outbound) Start(stage adapter.StartStage) error {
+	if stage == adapter.StartStateStarted && h.preConnect != nil {
+		h.preConnect.Start()
+	}
+	return nil
+}
+
 func (h *Outbound) Close() error {
-	return common.Close(common.PtrOrNil(h.multiplexDialer), h.transport)
+	return common.Close(common.PtrOrNil(h.preConnect), common.PtrOrNil(h.multiplexDialer), h.transport)
+}
+
+func (h *Outbound) connectServer(ctx context.Context) (net.Conn, error) {
+	if h.transport != nil {
+		return h.transport.DialContext(ctx)
+	}
+	conn, err := h.dialer.DialContext(ctx, N.NetworkTCP, h.serverAddr)
+	if err == nil && h.tlsConfig != nil {
+		conn, err = tls.ClientHandshake(ctx, conn, h.tlsConfig)
+	}
+	return conn, err
 }
 
 type vlessDialer Outbound
@@ -139,13 +182,10 @@ func (h *vlessDialer) DialContext(ctx context.Context, network string, destinati
 	metadata.Destination = destination
 	var conn net.Conn
 	var err error
-	if h.transport != nil {
-		conn, err = h.transport.DialContext(ctx)
+	if h.preConnect != nil {
+		conn, err = h.preConnect.DialContext(ctx)
 	} else {
-		conn, err = h.dialer.DialContext(ctx, N.NetworkTCP, h.serverAddr)
-		if err == nil && h.tlsConfig != nil {
-			conn, err = tls.ClientHandshake(ctx, conn, h.tlsConfig)
-		}
+		conn, err = (*Outbound)(h).connectServer(ctx)
 	}
 	if err != nil {
 		return nil, err
@@ -158,6 +198,15 @@ func (h *vlessDialer) DialContext(ctx context.Context, network string, destinati
 		h.logger.InfoContext(ctx, "outbound packet connection to ", destination)
 		if h.xudp {
 			return h.client.DialEarlyXUDPPacketConn(conn, destination)
+		} else if h.packetFragmentSize > 0 {
+			if destination.IsFqdn() {
+				return nil, E.New("packet fragment: domain destination is not supported")
+			}
+			packetConn, err := h.client.DialEarlyPacketConn(conn, M.Socksaddr{Fqdn: packetfragment.MagicAddress, Port: h.packetFragmentSize})
+			if err != nil {
+				return nil, err
+			}
+			return bufio.NewBindPacketConn(packetfragment.NewClientConn(packetaddr.NewConn(packetConn, destination), int(h.packetFragmentSize)), destination), nil
 		} else if h.packetAddr {
 			if destination.IsFqdn() {
 				return nil, E.New("packetaddr: domain destination is not supported")
@@ -182,13 +231,10 @@ func (h *vlessDialer) ListenPacket(ctx context.Context, destination M.Socksaddr)
 	metadata.Destination = destination
 	var conn net.Conn
 	var err error
-	if h.transport != nil {
-		conn, err = h.transport.DialContext(ctx)
+	if h.preConnect != nil {
+		conn, err = h.preConnect.DialContext(ctx)
 	} else {
-		conn, err = h.dialer.DialContext(ctx, N.NetworkTCP, h.serverAddr)
-		if err == nil && h.tlsConfig != nil {
-			conn, err = tls.ClientHandshake(ctx, conn, h.tlsConfig)
-		}
+		conn, err = (*Outbound)(h).connectServer(ctx)
 	}
 	if err != nil {
 		common.Close(conn)
@@ -196,6 +242,15 @@ func (h *vlessDialer) ListenPacket(ctx context.Context, destination M.Socksaddr)
 	}
 	if h.xudp {
 		return h.client.DialEarlyXUDPPacketConn(conn, destination)
+	} else if h.packetFragmentSize > 0 {
+		if destination.IsFqdn() {
+			return nil, E.New("packet fragment: domain destination is not supported")
+		}
+		conn, err := h.client.DialEarlyPacketConn(conn, M.Socksaddr{Fqdn: packetfragment.MagicAddress, Port: h.packetFragmentSize})
+		if err != nil {
+			return nil, err
+		}
+		return packetfragment.NewClientConn(packetaddr.NewConn(conn, destination), int(h.packetFragmentSize)), nil
 	} else if h.packetAddr {
 		if destination.IsFqdn() {
 			return nil, E.New("packetaddr: domain destination is not supported")
@@ -4,13 +4,18 @@ import (
 	"context"
 	"net"
 	"os"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/sagernet/sing-box/adapter"
 	"github.com/sagernet/sing-box/adapter/inbound"
 	"github.com/sagernet/sing-box/common/listener"
 	"github.com/sagernet/sing-box/common/mux"
+	"github.com/sagernet/sing-box/common/packetfragment"
 	"github.com/sagernet/sing-box/common/tls"
 	"github.com/sagernet/sing-box/common/uot"
+	"github.com/sagernet/sing-box/common/userfile"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/log"
 	"github.com/sagernet/sing-box/option"
@@ -35,14 +40,20 @@ var _ adapter.TCPInjectableInbound = (*Inbound)(nil)
 
 type Inbound struct {
 	inbound.Adapter
-	ctx       context.Context
-	router    adapter.ConnectionRouterEx
-	logger    logger.ContextLogger
-	listener  *listener.Listener
-	service   *vmess.Service[int]
-	users     []option.VMessUser
-	tlsConfig tls.ServerConfig
-	transport adapter.V2RayServerTransport
+	ctx            context.Context
+	router         adapter.ConnectionRouterEx
+	logger         logger.ContextLogger
+	listener       *listener.Listener
+	service        atomic.Pointer[vmess.Service[int]]
+	serviceOptions []vmess.ServiceOption
+	access         sync.RWMutex
+	started        bool
+	users          []option.VMessUser
+	usersWatcher   *userfile.Watcher[option.VMessUser]
+	tlsConfig      tls.ServerConfig
+	transport      adapter.V2RayServerTransport
+	legacy         *legacyDetector
+	limiter        *authFailureLimiter
 }
 
 func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.VMessInboundOptions) (adapter.Inbound, error) {
@@ -51,7 +62,6 @@ func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLo
 		ctx:     ctx,
 		router:  uot.NewRouter(router, logger),
 		logger:  logger,
-		users:   options.Users,
 	}
 	var err error
 	inbound.router, err = mux.NewRouterWithOptions(inbound.router, logger, common.PtrValueOrDefault(options.Multiplex))
@@ -59,21 +69,27 @@ func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLo
 		return nil, err
 	}
 	var serviceOptions []vmess.ServiceOption
-	if timeFunc := ntp.TimeFuncFromContext(ctx); timeFunc != nil {
+	timeFunc := ntp.TimeFuncFromContext(ctx)
+	if timeFunc != nil {
 		serviceOptions = append(serviceOptions, vmess.ServiceWithTimeFunc(timeFunc))
 	}
 	if options.Transport != nil && options.Transport.Type != "" {
 		serviceOptions = append(serviceOptions, vmess.ServiceWithDisableHeaderProtection())
 	}
-	service := vmess.NewService[int](adapter.NewUpstreamContextHandlerEx(inbound.newConnectionEx, inbound.newPacketConnectionEx), serviceOptions...)
-	inbound.service = service
-	err = service.UpdateUsers(common.MapIndexed(options.Users, func(index int, it option.VMessUser) int {
-		return index
-	}), common.Map(options.Users, func(it option.VMessUser) string {
-		return it.UUID
-	}), common.Map(options.Users, func(it option.VMessUser) int {
-		return it.AlterId
-	}))
+	inbound.serviceOptions = serviceOptions
+	if options.LegacyDecoy {
+		inbound.legacy = newLegacyDetector(timeFunc)
+	}
+	if options.AuthFailureLimit > 0 {
+		inbound.limiter = newAuthFailureLimiter(options.AuthFailureLimit, time.Duration(options.AuthFailureWindow))
+	}
+	if options.UsersPath != "" {
+		inbound.usersWatcher, err = userfile.NewWatcher(ctx, logger, options.UsersPath, func(users []option.VMessUser) error {
+			return inbound.updateUsers(append(append([]option.VMessUser(nil), options.Users...), users...))
+		})
+	} else {
+		err = inbound.updateUsers(options.Users)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -90,23 +106,66 @@ func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLo
 		}
 	}
 	inbound.listener = listener.New(listener.Options{
-		Context:           ctx,
-		Logger:            logger,
-		Network:           []string{N.NetworkTCP},
-		Listen:            options.ListenOptions,
-		ConnectionHandler: inbound,
+		Context:             ctx,
+		Logger:              logger,
+		Network:             []string{N.NetworkTCP},
+		Listen:              options.ListenOptions,
+		AcceptProxyProtocol: options.AcceptProxyProtocol,
+		ConnectionHandler:   inbound,
 	})
 	return inbound, nil
 }
 
+// updateUsers builds a new service for the users and swaps it in, as the users
+// of a running service can not be updated while handshakes read them.
+func (h *Inbound) updateUsers(users []option.VMessUser) error {
+	service := vmess.NewService[int](adapter.NewUpstreamContextHandlerEx(h.newConnectionEx, h.newPacketConnectionEx), h.serviceOptions...)
+	err := service.UpdateUsers(common.MapIndexed(users, func(index int, it option.VMessUser) int {
+		return index
+	}), common.Map(users, func(it option.VMessUser) string {
+		return it.UUID
+	}), common.Map(users, func(it option.VMessUser) int {
+		return it.AlterId
+	}))
+	if err != nil {
+		return err
+	}
+	h.access.Lock()
+	defer h.access.Unlock()
+	if h.started {
+		err = service.Start()
+		if err != nil {
+			return err
+		}
+	}
+	if h.legacy != nil {
+		h.legacy.UpdateUsers(users)
+	}
+	h.users = users
+	oldService := h.service.Swap(service)
+	if h.started {
+		oldService.Close()
+	}
+	return nil
+}
+
 func (h *Inbound) Start(stage adapter.StartStage) error {
 	if stage != adapter.StartStateStart {
 		return nil
 	}
-	err := h.service.Start()
+	h.access.Lock()
+	h.started = true
+	err := h.service.Load().Start()
+	h.access.Unlock()
 	if err != nil {
 		return err
 	}
+	if h.usersWatcher != nil {
+		err = h.usersWatcher.Start()
+		if err != nil {
+			return err
+		}
+	}
 	if h.tlsConfig != nil {
 		err = h.tlsConfig.Start()
 		if err != nil {
@@ -144,8 +203,13 @@ func (h *Inbound) Start(stage adapter.StartStage) error {
 }
 
 func (h *Inbound) Close() error {
+	h.access.Lock()
+	h.started = false
+	service := h.service.Load()
+	h.access.Unlock()
 	return common.Close(
-		h.service,
+		service,
+		common.PtrOrNil(h.usersWatcher),
 		h.listener,
 		h.tlsConfig,
 		h.transport,
@@ -153,22 +217,66 @@ func (h *Inbound) Close() error {
 }
 
 func (h *Inbound) NewConnectionEx(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
+	if h.limiter != nil && !h.limiter.Allow(metadata.Source.Addr) {
+		N.CloseOnHandshakeFailure(conn, onClose, os.ErrPermission)
+		h.logger.DebugContext(ctx, "process connection from ", metadata.Source, ": too many authentication failures")
+		return
+	}
 	if h.tlsConfig != nil && h.transport == nil {
-		tlsConn, err := tls.ServerHandshake(ctx, conn, h.tlsConfig)
+		tlsConn, err := tls.ServerHandshake(adapter.WithContext(ctx, &metadata), conn, h.tlsConfig)
 		if err != nil {
+			if h.limiter != nil {
+				h.limiter.Fail(metadata.Source.Addr)
+			}
 			N.CloseOnHandshakeFailure(conn, onClose, err)
 			h.logger.ErrorContext(ctx, E.Cause(err, "process connection from ", metadata.Source, ": TLS handshake"))
 			return
 		}
 		conn = tlsConn
 	}
-	err := h.service.NewConnection(adapter.WithContext(ctx, &metadata), conn, metadata.Source, onClose)
+	serviceConn := conn
+	var recordConn *authIDRecordConn
+	if h.legacy != nil {
+		recordConn = &authIDRecordConn{Conn: conn}
+		serviceConn = recordConn
+	}
+	err := h.service.Load().NewConnection(adapter.WithContext(ctx, &metadata), serviceConn, metadata.Source, onClose)
 	if err != nil {
+		if h.limiter != nil {
+			h.limiter.Fail(metadata.Source.Addr)
+		}
+		if recordConn != nil {
+			if authID, loaded := recordConn.AuthID(); loaded {
+				if userIndex, isLegacy := h.legacy.Detect(authID); isLegacy {
+					h.logger.WarnContext(ctx, "rejected legacy VMess request from ", metadata.Source, " for user ", h.userName(userIndex), ", alterId > 0 is not enabled")
+					writeLegacyDecoy(conn)
+					N.CloseOnHandshakeFailure(conn, onClose, err)
+					return
+				}
+			}
+		}
 		N.CloseOnHandshakeFailure(conn, onClose, err)
 		h.logger.ErrorContext(ctx, E.Cause(err, "process connection from ", metadata.Source))
 	}
 }
 
+func (h *Inbound) userName(userIndex int) string {
+	user := h.loadUserName(userIndex)
+	if user == "" {
+		user = F.ToString(userIndex)
+	}
+	return user
+}
+
+func (h *Inbound) loadUserName(userIndex int) string {
+	h.access.RLock()
+	defer h.access.RUnlock()
+	if userIndex >= len(h.users) {
+		return ""
+	}
+	return h.users[userIndex].Name
+}
+
 func (h *Inbound) newConnectionEx(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
 	metadata.Inbound = h.Tag()
 	metadata.InboundType = h.Type()
@@ -177,7 +285,7 @@ func (h *Inbound) newConnectionEx(ctx context.Context, conn net.Conn, metadata a
 		N.CloseOnHandshakeFailure(conn, onClose, os.ErrInvalid)
 		return
 	}
-	user := h.users[userIndex].Name
+	user := h.loadUserName(userIndex)
 	if user == "" {
 		user = F.ToString(userIndex)
 	} else {
@@ -195,13 +303,27 @@ func (h *Inbound) newPacketConnectionEx(ctx context.Context, conn N.PacketConn,
 		N.CloseOnHandshakeFailure(conn, onClose, os.ErrInvalid)
 		return
 	}
-	user := h.users[userIndex].Name
+	user := h.loadUserName(userIndex)
 	if user == "" {
 		user = F.ToString(userIndex)
 	} else {
 		metadata.User = user
 	}
-	if metadata.Destination.Fqdn == packetaddr.SeqPacketMagicAddress {
+	if metadata.Destination.Fqdn == packetfragment.MagicAddress {
+		fragmentSize := int(metadata.Destination.Port)
+		if fragmentSize < packetfragment.MinSize {
+			N.CloseOnHandshakeFailure(conn, onClose, E.New("invalid packet fragment size: ", fragmentSize))
+			return
+		}
+		metadata.Destination = M.Socksaddr{}
+		fragmentConn, err := packetfragment.NewServerConn(packetaddr.NewConn(conn.(vmess.PacketConn), metadata.Destination), fragmentSize)
+		if err != nil {
+			N.CloseOnHandshakeFailure(conn, onClose, err)
+			return
+		}
+		conn = fragmentConn
+		h.logger.InfoContext(ctx, "[", user, "] inbound packet addr connection with fragment size ", fragmentSize)
+	} else if metadata.Destination.Fqdn == packetaddr.SeqPacketMagicAddress {
 		metadata.Destination = M.Socksaddr{}
 		conn = packetaddr.NewConn(conn.(vmess.PacketConn), metadata.Destination)
 		h.logger.InfoContext(ctx, "[", user, "] inbound packet addr connection")
@@ -0,0 +1,217 @@
+package vmess
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"encoding/binary"
+	"net"
+	"net/http"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing-box/option"
+	F "github.com/sagernet/sing/common/format"
+
+	"github.com/gofrs/uuid/v5"
+)
+
+// legacyTimeWindow is the accepted clock difference of legacy auth IDs in seconds.
+const legacyTimeWindow = 120
+
+const legacyDecoyBody = "<html>\r\n<head><title>400 Bad Request</title></head>\r\n<body>\r\n<center><h1>400 Bad Request</h1></center>\r\n<hr><center>nginx</center>\r\n</body>\r\n</html>\r\n"
+
+// legacyDetector recognizes non-AEAD (alterId > 0) requests from configured users,
+// which the service rejects when the user has no alterId. Auth IDs of the time
+// window are generated as time passes and looked up by value, like the legacy
+// user validator of v2ray, so that a failed handshake costs no hashing.
+type legacyDetector struct {
+	timeFunc func() time.Time
+	access   sync.Mutex
+	userIDs  [][16]byte
+	authIDs  map[[16]byte]int
+	// auth IDs of each timestamp from first to last, to be expired
+	timeIDs map[int64][][16]byte
+	first   int64
+	last    int64
+}
+
+func newLegacyDetector(timeFunc func() time.Time) *legacyDetector {
+	if timeFunc == nil {
+		timeFunc = time.Now
+	}
+	return &legacyDetector{timeFunc: timeFunc}
+}
+
+func (d *legacyDetector) UpdateUsers(users []option.VMessUser) {
+	userIDs := make([][16]byte, 0, len(users))
+	for _, user := range users {
+		userUUID := uuid.FromStringOrNil(user.UUID)
+		if userUUID == uuid.Nil {
+			userUUID = uuid.NewV5(userUUID, user.UUID)
+		}
+		userIDs = append(userIDs, userUUID)
+	}
+	d.access.Lock()
+	d.userIDs = userIDs
+	d.authIDs = nil
+	d.access.Unlock()
+}
+
+// Detect returns the index of the user whose primary ID generated the legacy auth ID.
+func (d *legacyDetector) Detect(authID [16]byte) (int, bool) {
+	d.access.Lock()
+	defer d.access.Unlock()
+	d.update(d.timeFunc().Unix())
+	index, loaded := d.authIDs[authID]
+	return index, loaded
+}
+
+// update generates auth IDs of timestamps entering the window and removes those leaving it.
+func (d *legacyDetector) update(nowSec int64) {
+	first, last := nowSec-legacyTimeWindow, nowSec+legacyTimeWindow
+	if d.authIDs == nil || first < d.first || first > d.last {
+		// users updated, or the clock jumped
+		d.authIDs = make(map[[16]byte]int, len(d.userIDs)*(legacyTimeWindow*2+1))
+		d.timeIDs = make(map[int64][][16]byte, legacyTimeWindow*2+1)
+		d.first, d.last = first, first-1
+	}
+	for ; d.first < first; d.first++ {
+		for _, authID := range d.timeIDs[d.first] {
+			delete(d.authIDs, authID)
+		}
+		delete(d.timeIDs, d.first)
+	}
+	for d.last < last {
+		d.last++
+		authIDs := make([][16]byte, 0, len(d.userIDs))
+		for index, userID := range d.userIDs {
+			authID := legacyAuthID(userID, d.last)
+			d.authIDs[authID] = index
+			authIDs = append(authIDs, authID)
+		}
+		d.timeIDs[d.last] = authIDs
+	}
+}
+
+func legacyAuthID(userID [16]byte, timestamp int64) (authID [16]byte) {
+	idHash := hmac.New(md5.New, userID[:])
+	binary.Write(idHash, binary.BigEndian, uint64(timestamp))
+	idHash.Sum(authID[:0])
+	return
+}
+
+func writeLegacyDecoy(conn net.Conn) error {
+	response := "HTTP/1.1 400 Bad Request\r\n" +
+		"Server: nginx\r\n" +
+		"Date: " + time.Now().UTC().Format(http.TimeFormat) + "\r\n" +
+		"Content-Type: text/html\r\n" +
+		"Content-Length: " + F.ToString(len(legacyDecoyBody)) + "\r\n" +
+		"Connection: close\r\n\r\n" +
+		legacyDecoyBody
+	_, err := conn.Write([]byte(response))
+	return err
+}
+
+// authIDRecordConn records the auth ID of the request for legacy detection.
+type authIDRecordConn struct {
+	net.Conn
+	authID [16]byte
+	n      int
+}
+
+func (c *authIDRecordConn) Read(p []byte) (n int, err error) {
+	n, err = c.Conn.Read(p)
+	if c.n < len(c.authID) {
+		c.n += copy(c.authID[c.n:], p[:n])
+	}
+	return
+}
+
+func (c *authIDRecordConn) AuthID() ([16]byte, bool) {
+	return c.authID, c.n == len(c.authID)
+}
+
+func (c *authIDRecordConn) Upstream() any {
+	return c.Conn
+}
+
+func (c *authIDRecordConn) UpstreamReader() any {
+	return c.Conn
+}
+
+func (c *authIDRecordConn) ReaderReplaceable() bool {
+	return c.n == len(c.authID)
+}
+
+// authFailureLimiter rejects sources with too many failed handshakes in the window.
+// Sources are grouped by /24 for IPv4 and /64 for IPv6, so that a client can not
+// escape the limit by rotating addresses in its own network.
+type authFailureLimiter struct {
+	access    sync.Mutex
+	limit     int
+	window    time.Duration
+	failures  map[netip.Prefix]*authFailure
+	lastClean time.Time
+}
+
+type authFailure struct {
+	count int
+	start time.Time
+}
+
+func newAuthFailureLimiter(limit int, window time.Duration) *authFailureLimiter {
+	if window == 0 {
+		window = time.Minute
+	}
+	return &authFailureLimiter{
+		limit:     limit,
+		window:    window,
+		failures:  make(map[netip.Prefix]*authFailure),
+		lastClean: time.Now(),
+	}
+}
+
+func authFailureKey(source netip.Addr) netip.Prefix {
+	source = source.Unmap()
+	if source.Is4() {
+		return netip.PrefixFrom(source, 24).Masked()
+	}
+	return netip.PrefixFrom(source, 64).Masked()
+}
+
+func (l *authFailureLimiter) Allow(source netip.Addr) bool {
+	key := authFailureKey(source)
+	l.access.Lock()
+	defer l.access.Unlock()
+	failure, loaded := l.failures[key]
+	if !loaded {
+		return true
+	}
+	if time.Since(failure.start) >= l.window {
+		delete(l.failures, key)
+		return true
+	}
+	return failure.count < l.limit
+}
+
+func (l *authFailureLimiter) Fail(source netip.Addr) {
+	key := authFailureKey(source)
+	l.access.Lock()
+	defer l.access.Unlock()
+	now := time.Now()
+	if now.Sub(l.lastClean) >= l.window {
+		for failureKey, failure := range l.failures {
+			if now.Sub(failure.start) >= l.window {
+				delete(l.failures, failureKey)
+			}
+		}
+		l.lastClean = now
+	}
+	failure, loaded := l.failures[key]
+	if !loaded || now.Sub(failure.start) >= l.window {
+		l.failures[key] = &authFailure{count: 1, start: now}
+		return
+	}
+	failure.count++
+}
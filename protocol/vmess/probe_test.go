@@ -0,0 +1,39 @@
+package vmess
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthFailureLimiter(t *testing.T) {
+	t.Parallel()
+	limiter := newAuthFailureLimiter(2, time.Minute)
+	source := netip.MustParseAddr("192.0.2.1")
+	neighbor := netip.MustParseAddr("192.0.2.200")
+	require.True(t, limiter.Allow(source))
+	limiter.Fail(source)
+	require.True(t, limiter.Allow(neighbor))
+	limiter.Fail(neighbor)
+	require.False(t, limiter.Allow(source))
+	require.False(t, limiter.Allow(netip.MustParseAddr("::ffff:192.0.2.3")))
+	require.True(t, limiter.Allow(netip.MustParseAddr("198.51.100.1")))
+
+	source6 := netip.MustParseAddr("2001:db8::1")
+	limiter.Fail(source6)
+	limiter.Fail(netip.MustParseAddr("2001:db8::ffff:1"))
+	require.False(t, limiter.Allow(source6))
+	require.True(t, limiter.Allow(netip.MustParseAddr("2001:db8:0:1::1")))
+}
+
+func TestAuthFailureLimiterWindow(t *testing.T) {
+	t.Parallel()
+	limiter := newAuthFailureLimiter(1, 10*time.Millisecond)
+	source := netip.MustParseAddr("192.0.2.1")
+	limiter.Fail(source)
+	require.False(t, limiter.Allow(source))
+	time.Sleep(20 * time.Millisecond)
+	require.True(t, limiter.Allow(source))
+}
@@ -48,6 +48,19 @@ func NewEndpoint(ctx context.Context, router adapter.Router, logger log.ContextL
 		logger:         logger,
 		localAddresses: options.Address,
 	}
+	if options.Kernel {
+		if options.Detour != "" {
+			return nil, E.New("detour is not supported in kernel mode")
+		}
+		// connections from peers are handled by the system network stack
+		// instead of being routed by sing-box in kernel mode
+		if options.UDPTimeout != 0 {
+			return nil, E.New("udp_timeout is not supported in kernel mode")
+		}
+		if options.Workers != 0 {
+			return nil, E.New("workers is not supported in kernel mode")
+		}
+	}
 	if options.Detour == "" {
 		options.IsWireGuardListener = true
 	}
@@ -59,15 +72,37 @@ func NewEndpoint(ctx context.Context, router adapter.Router, logger log.ContextL
 	if options.UDPTimeout != 0 {
 		udpTimeout = time.Duration(options.UDPTimeout)
 	} else {
-		udpTimeout = C.UDPTimeout
+		udpTimeout = adapter.TimeoutsFromContext(ctx).UDPNAT
+	}
+	var obfuscation *wireguard.ObfuscationOptions
+	if options.Obfuscation != nil && options.Obfuscation.Enabled {
+		obfuscation = &wireguard.ObfuscationOptions{
+			Password: options.Obfuscation.Password,
+		}
+	}
+	var amnezia *wireguard.AmneziaOptions
+	if options.Amnezia != nil {
+		amnezia = &wireguard.AmneziaOptions{
+			JunkPacketCount:            options.Amnezia.JunkPacketCount,
+			JunkPacketMinSize:          options.Amnezia.JunkPacketMinSize,
+			JunkPacketMaxSize:          options.Amnezia.JunkPacketMaxSize,
+			InitPacketJunkSize:         options.Amnezia.InitPacketJunkSize,
+			ResponsePacketJunkSize:     options.Amnezia.ResponsePacketJunkSize,
+			InitPacketMagicHeader:      options.Amnezia.InitPacketMagicHeader,
+			ResponsePacketMagicHeader:  options.Amnezia.ResponsePacketMagicHeader,
+			UnderloadPacketMagicHeader: options.Amnezia.UnderloadPacketMagicHeader,
+			TransportPacketMagicHeader: options.Amnezia.TransportPacketMagicHeader,
+		}
 	}
 	wgEndpoint, err := wireguard.NewEndpoint(wireguard.EndpointOptions{
-		Context:    ctx,
-		Logger:     logger,
-		System:     options.System,
-		Handler:    ep,
-		UDPTimeout: udpTimeout,
-		Dialer:     outboundDialer,
+		Context:     ctx,
+		Logger:      logger,
+		System:      options.System,
+		Kernel:      options.Kernel,
+		RoutingMark: uint32(options.RoutingMark),
+		Handler:     ep,
+		UDPTimeout:  udpTimeout,
+		Dialer:      outboundDialer,
 		CreateDialer: func(interfaceName string) N.Dialer {
 			return common.Must1(dialer.NewDefault(ctx, option.DialerOptions{
 				BindInterface: interfaceName,
@@ -95,7 +130,11 @@ func NewEndpoint(ctx context.Context, router adapter.Router, logger log.ContextL
 				Reserved:                    it.Reserved,
 			}
 		}),
-		Workers: options.Workers,
+		Workers:     options.Workers,
+		Obfuscation: obfuscation,
+		Amnezia:     amnezia,
+		LazyStart:   options.LazyStart,
+		IdleTimeout: time.Duration(options.IdleTimeout),
 	})
 	if err != nil {
 		return nil, err
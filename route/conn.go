@@ -5,6 +5,7 @@ import (
 	"io"
 	"net"
 	"net/netip"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -16,6 +17,7 @@ import (
 	"github.com/sagernet/sing/common/bufio"
 	"github.com/sagernet/sing/common/canceler"
 	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
 	"github.com/sagernet/sing/common/logger"
 	M "github.com/sagernet/sing/common/metadata"
 	N "github.com/sagernet/sing/common/network"
@@ -26,13 +28,16 @@ var _ adapter.ConnectionManager = (*ConnectionManager)(nil)
 
 type ConnectionManager struct {
 	logger      logger.ContextLogger
+	udpTimeout  map[string]time.Duration
 	access      sync.Mutex
 	connections list.List[io.Closer]
+	drained     chan struct{}
 }
 
-func NewConnectionManager(logger logger.ContextLogger) *ConnectionManager {
+func NewConnectionManager(logger logger.ContextLogger, udpTimeout map[string]time.Duration) *ConnectionManager {
 	return &ConnectionManager{
-		logger: logger,
+		logger:     logger,
+		udpTimeout: udpTimeout,
 	}
 }
 
@@ -47,10 +52,47 @@ func (m *ConnectionManager) Close() error {
 		common.Close(element.Value)
 	}
 	m.connections.Init()
+	if m.drained != nil {
+		close(m.drained)
+		m.drained = nil
+	}
 	return nil
 }
 
+// Drain waits for active connections to finish until ctx is done,
+// and returns the number of connections still open.
+func (m *ConnectionManager) Drain(ctx context.Context) int {
+	m.access.Lock()
+	if m.connections.Len() == 0 {
+		m.access.Unlock()
+		return 0
+	}
+	if m.drained == nil {
+		m.drained = make(chan struct{})
+	}
+	drained := m.drained
+	m.access.Unlock()
+	select {
+	case <-drained:
+	case <-ctx.Done():
+	}
+	m.access.Lock()
+	defer m.access.Unlock()
+	return m.connections.Len()
+}
+
+func (m *ConnectionManager) removeConnection(element *list.Element[io.Closer]) {
+	m.access.Lock()
+	defer m.access.Unlock()
+	m.connections.Remove(element)
+	if m.drained != nil && m.connections.Len() == 0 {
+		close(m.drained)
+		m.drained = nil
+	}
+}
+
 func (m *ConnectionManager) NewConnection(ctx context.Context, this N.Dialer, conn net.Conn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
+	metadata.DialHops = new(adapter.DialHops)
 	ctx = adapter.WithContext(ctx, &metadata)
 	var (
 		remoteConn net.Conn
@@ -67,6 +109,7 @@ func (m *ConnectionManager) NewConnection(ctx context.Context, this N.Dialer, co
 		m.logger.ErrorContext(ctx, err)
 		return
 	}
+	logDialHops(ctx, m.logger, metadata.DialHops.List())
 	err = N.ReportConnHandshakeSuccess(conn, remoteConn)
 	if err != nil {
 		err = E.Cause(err, "report handshake success")
@@ -75,13 +118,20 @@ func (m *ConnectionManager) NewConnection(ctx context.Context, this N.Dialer, co
 		m.logger.ErrorContext(ctx, err)
 		return
 	}
+	if status, loaded := dialer.ReadMultiPathTCPStatus(remoteConn); loaded {
+		logMultiPathTCP(ctx, m.logger, status)
+		if !status.Fallback {
+			remoteConn = newMultiPathConn(ctx, m.logger, remoteConn)
+		}
+	}
+	if relayIdle := adapter.TimeoutsFromContext(ctx).RelayIdle; relayIdle > 0 {
+		conn = newIdleConn(conn, relayIdle)
+	}
 	m.access.Lock()
 	element := m.connections.PushBack(conn)
 	m.access.Unlock()
 	onClose = N.AppendClose(onClose, func(it error) {
-		m.access.Lock()
-		defer m.access.Unlock()
-		m.connections.Remove(element)
+		m.removeConnection(element)
 	})
 	var done atomic.Bool
 	go m.connectionCopy(ctx, conn, remoteConn, false, &done, onClose)
@@ -89,6 +139,7 @@ func (m *ConnectionManager) NewConnection(ctx context.Context, this N.Dialer, co
 }
 
 func (m *ConnectionManager) NewPacketConnection(ctx context.Context, this N.Dialer, conn N.PacketConn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
+	metadata.DialHops = new(adapter.DialHops)
 	ctx = adapter.WithContext(ctx, &metadata)
 	var (
 		remotePacketConn   net.PacketConn
@@ -135,6 +186,7 @@ func (m *ConnectionManager) NewPacketConnection(ctx context.Context, this N.Dial
 			return
 		}
 	}
+	logDialHops(ctx, m.logger, metadata.DialHops.List())
 	err = N.ReportPacketConnHandshakeSuccess(conn, remotePacketConn)
 	if err != nil {
 		conn.Close()
@@ -169,26 +221,43 @@ func (m *ConnectionManager) NewPacketConnection(ctx context.Context, this N.Dial
 			protocol = C.PortProtocols[metadata.Destination.Port]
 		}
 		if protocol != "" {
-			udpTimeout = C.ProtocolTimeouts[protocol]
+			if protocolTimeout, loaded := m.udpTimeout[protocol]; loaded {
+				udpTimeout = protocolTimeout
+			} else {
+				udpTimeout = C.ProtocolTimeouts[protocol]
+			}
 		}
 	}
 	if udpTimeout > 0 {
-		ctx, conn = canceler.NewPacketConn(ctx, conn, udpTimeout)
+		if metadata.Protocol == C.ProtocolQUIC {
+			ctx, conn = newQUICIdlePacketConn(ctx, conn, udpTimeout)
+		} else {
+			ctx, conn = canceler.NewPacketConn(ctx, conn, udpTimeout)
+		}
 	}
-	destination := bufio.NewPacketConn(remotePacketConn)
+	destination := newNATFilterPacketConn(bufio.NewPacketConn(remotePacketConn), metadata.UDPNATStrategy)
 	m.access.Lock()
 	element := m.connections.PushBack(conn)
 	m.access.Unlock()
 	onClose = N.AppendClose(onClose, func(it error) {
-		m.access.Lock()
-		defer m.access.Unlock()
-		m.connections.Remove(element)
+		m.removeConnection(element)
 	})
 	var done atomic.Bool
 	go m.packetConnectionCopy(ctx, conn, destination, false, &done, onClose)
 	go m.packetConnectionCopy(ctx, destination, conn, true, &done, onClose)
 }
 
+func logDialHops(ctx context.Context, logger logger.ContextLogger, hops []adapter.DialHop) {
+	if len(hops) == 0 {
+		return
+	}
+	hopDescriptions := make([]string, 0, len(hops))
+	for i := len(hops) - 1; i >= 0; i-- {
+		hopDescriptions = append(hopDescriptions, F.ToString(hops[i].Outbound, " (", hops[i].Latency.Milliseconds(), "ms)"))
+	}
+	logger.DebugContext(ctx, "dialed via detour chain: ", strings.Join(hopDescriptions, " -> "))
+}
+
 func (m *ConnectionManager) connectionCopy(ctx context.Context, source io.Reader, destination io.Writer, direction bool, done *atomic.Bool, onClose N.CloseHandlerFunc) {
 	originSource := source
 	originDestination := destination
@@ -225,7 +294,7 @@ func (m *ConnectionManager) connectionCopy(ctx context.Context, source io.Reader
 		}
 		break
 	}
-	_, err := bufio.CopyWithCounters(destination, source, originSource, readCounters, writeCounters)
+	_, err := copyWithCounters(destination, source, originSource, readCounters, writeCounters)
 	if err != nil {
 		common.Close(originDestination)
 	} else if duplexDst, isDuplex := destination.(N.WriteCloser); isDuplex {
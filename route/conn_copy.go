@@ -0,0 +1,132 @@
+package route
+
+import (
+	"errors"
+	"io"
+	"syscall"
+
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/buf"
+	"github.com/sagernet/sing/common/bufio"
+	N "github.com/sagernet/sing/common/network"
+)
+
+// Relay buffers are taken from the size classes of the buf allocator, so
+// stepping between them never allocates outside the pool.
+const (
+	relayBufferSmall   = 4 * 1024
+	relayBufferDefault = 16 * 1024
+	relayBufferLarge   = 64 * 1024
+
+	// number of consecutive full or small reads before the buffer size
+	// steps up or down
+	relayBufferStepThreshold = 4
+)
+
+// copyWithCounters relays like bufio.CopyWithCounters, but when neither
+// splice nor a read waiter is available, the buffer size adapts to the
+// throughput: bulk transfers step up to the large class to reduce read
+// and write calls, while mostly idle connections step down to the small
+// class so a pending read holds less memory.
+func copyWithCounters(destination io.Writer, source io.Reader, originSource io.Reader, readCounters []N.CountFunc, writeCounters []N.CountFunc) (n int64, err error) {
+	if common.LowMemory {
+		return bufio.CopyWithCounters(destination, source, originSource, readCounters, writeCounters)
+	}
+	_, srcIsSyscall := source.(syscall.Conn)
+	_, dstIsSyscall := destination.(syscall.Conn)
+	if srcIsSyscall && dstIsSyscall {
+		return bufio.CopyWithCounters(destination, source, originSource, readCounters, writeCounters)
+	}
+	extendedSource := bufio.NewExtendedReader(source)
+	extendedDestination := bufio.NewExtendedWriter(destination)
+	if _, isReadWaiter := bufio.CreateReadWaiter(extendedSource); isReadWaiter || N.CalculateMTU(extendedSource, extendedDestination) > 0 {
+		return bufio.CopyExtended(originSource, extendedDestination, extendedSource, readCounters, writeCounters)
+	}
+	return copyAdaptive(originSource, extendedDestination, extendedSource, readCounters, writeCounters)
+}
+
+func copyAdaptive(originSource io.Reader, destination N.ExtendedWriter, source N.ExtendedReader, readCounters []N.CountFunc, writeCounters []N.CountFunc) (n int64, err error) {
+	frontHeadroom := N.CalculateFrontHeadroom(destination)
+	rearHeadroom := N.CalculateRearHeadroom(destination)
+	sizer := newRelayBufferSizer(frontHeadroom + rearHeadroom)
+	var notFirstTime bool
+	for {
+		buffer := buf.NewSize(frontHeadroom + sizer.size + rearHeadroom)
+		buffer.Resize(frontHeadroom, 0)
+		buffer.Reserve(rearHeadroom)
+		err = source.ReadBuffer(buffer)
+		if err != nil {
+			buffer.Release()
+			if errors.Is(err, io.EOF) {
+				err = nil
+			}
+			return
+		}
+		dataLen := buffer.Len()
+		buffer.OverCap(rearHeadroom)
+		err = destination.WriteBuffer(buffer)
+		if err != nil {
+			buffer.Leak()
+			if !notFirstTime {
+				err = N.ReportHandshakeFailure(originSource, err)
+			}
+			return
+		}
+		n += int64(dataLen)
+		for _, counter := range readCounters {
+			counter(int64(dataLen))
+		}
+		for _, counter := range writeCounters {
+			counter(int64(dataLen))
+		}
+		sizer.update(dataLen)
+		notFirstTime = true
+	}
+}
+
+type relayBufferSizer struct {
+	size      int
+	largeSize int
+	fullReads int
+	tinyReads int
+}
+
+func newRelayBufferSizer(headroom int) *relayBufferSizer {
+	return &relayBufferSizer{
+		size: relayBufferDefault,
+		// keep the whole buffer within the largest pooled class
+		largeSize: relayBufferLarge - headroom,
+	}
+}
+
+func (s *relayBufferSizer) update(dataLen int) {
+	switch {
+	case dataLen >= s.size:
+		s.fullReads++
+		s.tinyReads = 0
+		if s.fullReads >= relayBufferStepThreshold {
+			s.fullReads = 0
+			switch s.size {
+			case relayBufferSmall:
+				s.size = relayBufferDefault
+			case relayBufferDefault:
+				s.size = s.largeSize
+			}
+		}
+	case dataLen <= s.size/8:
+		s.tinyReads++
+		s.fullReads = 0
+		if s.tinyReads >= relayBufferStepThreshold {
+			s.tinyReads = 0
+			switch s.size {
+			case s.largeSize:
+				s.size = relayBufferDefault
+			case relayBufferDefault:
+				s.size = relayBufferSmall
+			}
+		}
+	default:
+		s.fullReads = 0
+		s.tinyReads = 0
+	}
+}
@@ -0,0 +1,109 @@
+package route
+
+import (
+	"io"
+	"net"
+	"time"
+
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/buf"
+	"github.com/sagernet/sing/common/bufio"
+	N "github.com/sagernet/sing/common/network"
+)
+
+var (
+	_ N.ReadCounter  = (*idleConn)(nil)
+	_ N.WriteCounter = (*idleConn)(nil)
+)
+
+// idleConn closes the connection when no data is read or written in either
+// direction within the timeout.
+//
+// Copies unwrap it as a counter, so splice and read waiters of the upstream
+// connection keep working and still reset the timer.
+type idleConn struct {
+	N.ExtendedConn
+	timeout time.Duration
+	timer   *time.Timer
+}
+
+func newIdleConn(conn net.Conn, timeout time.Duration) *idleConn {
+	return &idleConn{
+		ExtendedConn: bufio.NewExtendedConn(conn),
+		timeout:      timeout,
+		timer: time.AfterFunc(timeout, func() {
+			conn.Close()
+		}),
+	}
+}
+
+func (c *idleConn) Read(p []byte) (n int, err error) {
+	n, err = c.ExtendedConn.Read(p)
+	if n > 0 {
+		c.timer.Reset(c.timeout)
+	}
+	return
+}
+
+func (c *idleConn) ReadBuffer(buffer *buf.Buffer) error {
+	err := c.ExtendedConn.ReadBuffer(buffer)
+	if err == nil && !buffer.IsEmpty() {
+		c.timer.Reset(c.timeout)
+	}
+	return err
+}
+
+func (c *idleConn) Write(p []byte) (n int, err error) {
+	n, err = c.ExtendedConn.Write(p)
+	if n > 0 {
+		c.timer.Reset(c.timeout)
+	}
+	return
+}
+
+func (c *idleConn) WriteBuffer(buffer *buf.Buffer) error {
+	dataLen := buffer.Len()
+	err := c.ExtendedConn.WriteBuffer(buffer)
+	if err == nil && dataLen > 0 {
+		c.timer.Reset(c.timeout)
+	}
+	return err
+}
+
+func (c *idleConn) count(n int64) {
+	c.timer.Reset(c.timeout)
+}
+
+func (c *idleConn) UnwrapReader() (io.Reader, []N.CountFunc) {
+	return c.ExtendedConn, []N.CountFunc{c.count}
+}
+
+func (c *idleConn) UnwrapWriter() (io.Writer, []N.CountFunc) {
+	return c.ExtendedConn, []N.CountFunc{c.count}
+}
+
+func (c *idleConn) CloseWrite() error {
+	if writeCloser, isWriteCloser := common.Cast[N.WriteCloser](c.ExtendedConn); isWriteCloser {
+		return writeCloser.CloseWrite()
+	}
+	return c.Close()
+}
+
+func (c *idleConn) Close() error {
+	c.timer.Stop()
+	return c.ExtendedConn.Close()
+}
+
+func (c *idleConn) Upstream() any {
+	return c.ExtendedConn
+}
+
+// ReaderReplaceable and WriterReplaceable are false, since replacing the
+// connection would drop the counters resetting the timer.
+func (c *idleConn) ReaderReplaceable() bool {
+	return false
+}
+
+func (c *idleConn) WriterReplaceable() bool {
+	return false
+}
@@ -0,0 +1,73 @@
+package route
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sagernet/sing/common/bufio"
+	N "github.com/sagernet/sing/common/network"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTCPPair(t *testing.T) (net.Conn, net.Conn) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	clientConn, err := net.Dial("tcp", listener.Addr().String())
+	require.NoError(t, err)
+	serverConn, err := listener.Accept()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		clientConn.Close()
+		serverConn.Close()
+	})
+	return clientConn, serverConn
+}
+
+func TestIdleConnUnwrap(t *testing.T) {
+	t.Parallel()
+	_, serverConn := newTCPPair(t)
+	conn := newIdleConn(serverConn, time.Minute)
+	defer conn.Close()
+	reader, readCounters := N.UnwrapCountReader(conn, nil)
+	require.Equal(t, serverConn, reader)
+	require.Len(t, readCounters, 1)
+	writer, writeCounters := N.UnwrapCountWriter(conn, nil)
+	require.Equal(t, serverConn, writer)
+	require.Len(t, writeCounters, 1)
+}
+
+func TestIdleConnCopy(t *testing.T) {
+	t.Parallel()
+	sourceClient, sourceServer := newTCPPair(t)
+	destinationClient, destinationServer := newTCPPair(t)
+	conn := newIdleConn(sourceServer, 200*time.Millisecond)
+	copyDone := make(chan error, 1)
+	go func() {
+		_, err := bufio.Copy(destinationClient, conn)
+		copyDone <- err
+	}()
+	// active for longer than the timeout through the unwrapped copy
+	message := make([]byte, 5)
+	for i := 0; i < 6; i++ {
+		_, err := sourceClient.Write([]byte("hello"))
+		require.NoError(t, err)
+		_, err = io.ReadFull(destinationServer, message)
+		require.NoError(t, err)
+		require.Equal(t, "hello", string(message))
+		time.Sleep(100 * time.Millisecond)
+	}
+	select {
+	case err := <-copyDone:
+		t.Fatal("closed while active: ", err)
+	default:
+	}
+	select {
+	case <-copyDone:
+	case <-time.After(time.Second):
+		t.Fatal("not closed after idle")
+	}
+}
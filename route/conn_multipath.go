@@ -0,0 +1,58 @@
+package route
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"github.com/sagernet/sing-box/common/dialer"
+	"github.com/sagernet/sing/common/logger"
+)
+
+func logMultiPathTCP(ctx context.Context, logger logger.ContextLogger, status dialer.MultiPathTCPStatus) {
+	if status.Fallback {
+		logger.DebugContext(ctx, "multipath TCP is not supported by the remote, fallback to TCP")
+	} else if status.Subflows > 0 {
+		logger.DebugContext(ctx, "multipath TCP established with ", status.Subflows, " subflows")
+	} else {
+		logger.DebugContext(ctx, "multipath TCP established")
+	}
+}
+
+// multiPathConn logs the subflows of a Multipath TCP connection when it is
+// closed, as the path manager adds them after the handshake.
+type multiPathConn struct {
+	net.Conn
+	ctx       context.Context
+	logger    logger.ContextLogger
+	closeOnce sync.Once
+}
+
+func newMultiPathConn(ctx context.Context, logger logger.ContextLogger, conn net.Conn) *multiPathConn {
+	return &multiPathConn{
+		Conn:   conn,
+		ctx:    ctx,
+		logger: logger,
+	}
+}
+
+func (c *multiPathConn) Close() error {
+	c.closeOnce.Do(func() {
+		if status, loaded := dialer.ReadMultiPathTCPStatus(c.Conn); loaded && status.Subflows > 0 {
+			c.logger.DebugContext(c.ctx, "multipath TCP closed with ", status.Subflows, " subflows")
+		}
+	})
+	return c.Conn.Close()
+}
+
+func (c *multiPathConn) Upstream() any {
+	return c.Conn
+}
+
+func (c *multiPathConn) ReaderReplaceable() bool {
+	return true
+}
+
+func (c *multiPathConn) WriterReplaceable() bool {
+	return true
+}
@@ -0,0 +1,123 @@
+package route
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/buf"
+	"github.com/sagernet/sing/common/canceler"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+// quicIdlePacketConn refreshes the idle timer only when QUIC packets are seen in both directions,
+// so keep-alives of a live connection (PING and ACK) keep the NAT entry,
+// while flows with only one side sending, such as retransmissions to a dead peer, time out.
+//
+// The original destination connection ID of the client Initial is tracked,
+// a new connection on the same flow resets the activity state.
+type quicIdlePacketConn struct {
+	N.PacketConn
+	instance         *canceler.Instance
+	timeout          time.Duration
+	access           sync.Mutex
+	connectionID     []byte
+	lastClientPacket time.Time
+	lastServerPacket time.Time
+}
+
+func newQUICIdlePacketConn(ctx context.Context, conn N.PacketConn, timeout time.Duration) (context.Context, N.PacketConn) {
+	ctx, cancel := common.ContextWithCancelCause(ctx)
+	return ctx, &quicIdlePacketConn{
+		PacketConn: conn,
+		instance:   canceler.New(ctx, cancel, timeout),
+		timeout:    timeout,
+	}
+}
+
+func (c *quicIdlePacketConn) ReadPacket(buffer *buf.Buffer) (destination M.Socksaddr, err error) {
+	destination, err = c.PacketConn.ReadPacket(buffer)
+	if err == nil {
+		c.update(buffer.Bytes(), true)
+	}
+	return
+}
+
+func (c *quicIdlePacketConn) WritePacket(buffer *buf.Buffer, destination M.Socksaddr) error {
+	c.update(buffer.Bytes(), false)
+	return c.PacketConn.WritePacket(buffer, destination)
+}
+
+func (c *quicIdlePacketConn) update(packet []byte, fromClient bool) {
+	// fixed bit must be set in both long and short header packets
+	if len(packet) == 0 || packet[0]&0x40 == 0 {
+		return
+	}
+	now := time.Now()
+	c.access.Lock()
+	if fromClient {
+		if connectionID, isInitial := quicInitialConnectionID(packet); isInitial && !bytes.Equal(connectionID, c.connectionID) {
+			c.connectionID = append([]byte(nil), connectionID...)
+			c.lastServerPacket = time.Time{}
+		}
+		c.lastClientPacket = now
+	} else {
+		c.lastServerPacket = now
+	}
+	isActive := now.Sub(c.lastClientPacket) < c.timeout && now.Sub(c.lastServerPacket) < c.timeout
+	c.access.Unlock()
+	if isActive {
+		c.instance.Update()
+	}
+}
+
+func (c *quicIdlePacketConn) Timeout() time.Duration {
+	return c.instance.Timeout()
+}
+
+func (c *quicIdlePacketConn) SetTimeout(timeout time.Duration) bool {
+	c.access.Lock()
+	c.timeout = timeout
+	c.access.Unlock()
+	return c.instance.SetTimeout(timeout)
+}
+
+func (c *quicIdlePacketConn) Close() error {
+	return common.Close(
+		c.PacketConn,
+		c.instance,
+	)
+}
+
+func (c *quicIdlePacketConn) Upstream() any {
+	return c.PacketConn
+}
+
+// quicInitialConnectionID returns the destination connection ID of a QUIC v1 or v2 Initial packet.
+func quicInitialConnectionID(packet []byte) ([]byte, bool) {
+	if len(packet) < 6 || packet[0]&0x80 == 0 {
+		return nil, false
+	}
+	version := uint32(packet[1])<<24 | uint32(packet[2])<<16 | uint32(packet[3])<<8 | uint32(packet[4])
+	packetType := (packet[0] >> 4) & 0x03
+	switch version {
+	case 0x00000001:
+		if packetType != 0 {
+			return nil, false
+		}
+	case 0x6b3343cf:
+		if packetType != 1 {
+			return nil, false
+		}
+	default:
+		return nil, false
+	}
+	connectionIDLength := int(packet[5])
+	if connectionIDLength > 20 || len(packet) < 6+connectionIDLength {
+		return nil, false
+	}
+	return packet[6 : 6+connectionIDLength], true
+}
@@ -0,0 +1,32 @@
+package route
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestQUICIdleConnectionIDCopied(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, conn := newQUICIdlePacketConn(ctx, nil, time.Minute)
+	idleConn := conn.(*quicIdlePacketConn)
+	// QUIC v1 Initial with a 4-byte destination connection ID
+	packet := []byte{0xc0, 0x00, 0x00, 0x00, 0x01, 0x04, 0x01, 0x02, 0x03, 0x04, 0x00}
+	idleConn.update(packet, true)
+	idleConn.update([]byte{0x40}, false)
+	serverPacket := idleConn.lastServerPacket
+	require.False(t, serverPacket.IsZero())
+	// the read buffer is reused for the next packet
+	copy(packet[6:], []byte{0x05, 0x06, 0x07, 0x08})
+	require.Equal(t, []byte{0x01, 0x02, 0x03, 0x04}, idleConn.connectionID)
+	// a retransmitted Initial of the same connection keeps the activity state
+	idleConn.update([]byte{0xc0, 0x00, 0x00, 0x00, 0x01, 0x04, 0x01, 0x02, 0x03, 0x04, 0x00}, true)
+	require.Equal(t, serverPacket, idleConn.lastServerPacket)
+	// a new connection on the same flow resets it
+	idleConn.update(packet, true)
+	require.True(t, idleConn.lastServerPacket.IsZero())
+}
@@ -0,0 +1,71 @@
+package route
+
+import (
+	"sync"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing/common/buf"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+// natFilterPacketConn drops packets from remote addresses the client has not
+// sent to, with or without regard to the port depending on the strategy.
+type natFilterPacketConn struct {
+	N.PacketConn
+	portRestricted bool
+	access         sync.RWMutex
+	allowed        map[M.Socksaddr]struct{}
+}
+
+func newNATFilterPacketConn(conn N.PacketConn, strategy C.UDPNATStrategy) N.PacketConn {
+	if strategy == C.UDPNATStrategyFullCone {
+		return conn
+	}
+	return &natFilterPacketConn{
+		PacketConn:     conn,
+		portRestricted: strategy == C.UDPNATStrategyPortRestricted,
+		allowed:        make(map[M.Socksaddr]struct{}),
+	}
+}
+
+func (c *natFilterPacketConn) ReadPacket(buffer *buf.Buffer) (destination M.Socksaddr, err error) {
+	for {
+		destination, err = c.PacketConn.ReadPacket(buffer)
+		if err != nil {
+			return
+		}
+		c.access.RLock()
+		_, allowed := c.allowed[c.key(destination)]
+		c.access.RUnlock()
+		if allowed {
+			return
+		}
+		buffer.Reset()
+	}
+}
+
+func (c *natFilterPacketConn) WritePacket(buffer *buf.Buffer, destination M.Socksaddr) error {
+	key := c.key(destination)
+	c.access.RLock()
+	_, allowed := c.allowed[key]
+	c.access.RUnlock()
+	if !allowed {
+		c.access.Lock()
+		c.allowed[key] = struct{}{}
+		c.access.Unlock()
+	}
+	return c.PacketConn.WritePacket(buffer, destination)
+}
+
+func (c *natFilterPacketConn) key(address M.Socksaddr) M.Socksaddr {
+	address = address.Unwrap()
+	if !c.portRestricted {
+		address.Port = 0
+	}
+	return address
+}
+
+func (c *natFilterPacketConn) Upstream() any {
+	return c.PacketConn
+}
@@ -9,10 +9,12 @@ import (
 	"github.com/sagernet/sing-box/adapter"
 	C "github.com/sagernet/sing-box/constant"
 	dnsOutbound "github.com/sagernet/sing-box/protocol/dns"
+	"github.com/sagernet/sing-box/route/rule"
 	"github.com/sagernet/sing-dns"
 	"github.com/sagernet/sing-tun"
 	"github.com/sagernet/sing/common/buf"
 	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/logger"
 	M "github.com/sagernet/sing/common/metadata"
 	N "github.com/sagernet/sing/common/network"
 	"github.com/sagernet/sing/common/udpnat2"
@@ -20,48 +22,72 @@ import (
 	mDNS "github.com/miekg/dns"
 )
 
-func (r *Router) hijackDNSStream(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) error {
+func (r *Router) hijackDNSStream(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, action *rule.RuleActionHijackDNS) error {
+	router := r.hijackDNSRouter(action)
 	metadata.Destination = M.Socksaddr{}
 	for {
 		conn.SetReadDeadline(time.Now().Add(C.DNSTimeout))
-		err := dnsOutbound.HandleStreamDNSRequest(ctx, r, conn, metadata)
+		err := dnsOutbound.HandleStreamDNSRequest(ctx, router, conn, metadata)
 		if err != nil {
 			return err
 		}
 	}
 }
 
-func (r *Router) hijackDNSPacket(ctx context.Context, conn N.PacketConn, packetBuffers []*N.PacketBuffer, metadata adapter.InboundContext) {
+func (r *Router) hijackDNSPacket(ctx context.Context, conn N.PacketConn, packetBuffers []*N.PacketBuffer, metadata adapter.InboundContext, action *rule.RuleActionHijackDNS) {
+	router := r.hijackDNSRouter(action)
 	if natConn, isNatConn := conn.(udpnat.Conn); isNatConn {
 		metadata.Destination = M.Socksaddr{}
 		for _, packet := range packetBuffers {
 			buffer := packet.Buffer
 			destination := packet.Destination
 			N.PutPacketBuffer(packet)
-			go ExchangeDNSPacket(ctx, r, natConn, buffer, metadata, destination)
+			go ExchangeDNSPacket(ctx, router, r.dnsLogger, natConn, buffer, metadata, destination)
 		}
 		natConn.SetHandler(&dnsHijacker{
-			router:   r,
+			router:   router,
+			logger:   r.dnsLogger,
 			conn:     conn,
 			ctx:      ctx,
 			metadata: metadata,
 		})
 		return
 	}
-	err := dnsOutbound.NewDNSPacketConnection(ctx, r, conn, packetBuffers, metadata)
+	err := dnsOutbound.NewDNSPacketConnection(ctx, router, conn, packetBuffers, metadata)
 	if err != nil && !E.IsClosedOrCanceled(err) {
 		r.dnsLogger.ErrorContext(ctx, E.Cause(err, "process packet connection"))
 	}
 }
 
-func ExchangeDNSPacket(ctx context.Context, router *Router, conn N.PacketConn, buffer *buf.Buffer, metadata adapter.InboundContext, destination M.Socksaddr) {
+func (r *Router) hijackDNSRouter(action *rule.RuleActionHijackDNS) adapter.Router {
+	if !action.Predefined() {
+		return r
+	}
+	return &predefinedDNSRouter{r, action}
+}
+
+// predefinedDNSRouter answers hijacked DNS requests with the response configured
+// in the hijack-dns action, instead of exchanging them with the DNS module.
+type predefinedDNSRouter struct {
+	*Router
+	action *rule.RuleActionHijackDNS
+}
+
+func (r *predefinedDNSRouter) Exchange(ctx context.Context, message *mDNS.Msg) (*mDNS.Msg, error) {
+	if len(message.Question) > 0 {
+		r.dnsLogger.DebugContext(ctx, "predefined response for ", formatQuestion(message.Question[0].String()))
+	}
+	return r.action.Response(message), nil
+}
+
+func ExchangeDNSPacket(ctx context.Context, router adapter.Router, logger logger.ContextLogger, conn N.PacketConn, buffer *buf.Buffer, metadata adapter.InboundContext, destination M.Socksaddr) {
 	err := exchangeDNSPacket(ctx, router, conn, buffer, metadata, destination)
 	if err != nil && !errors.Is(err, tun.ErrDrop) && !E.IsClosedOrCanceled(err) {
-		router.dnsLogger.ErrorContext(ctx, E.Cause(err, "process packet connection"))
+		logger.ErrorContext(ctx, E.Cause(err, "process packet connection"))
 	}
 }
 
-func exchangeDNSPacket(ctx context.Context, router *Router, conn N.PacketConn, buffer *buf.Buffer, metadata adapter.InboundContext, destination M.Socksaddr) error {
+func exchangeDNSPacket(ctx context.Context, router adapter.Router, conn N.PacketConn, buffer *buf.Buffer, metadata adapter.InboundContext, destination M.Socksaddr) error {
 	var message mDNS.Msg
 	err := message.Unpack(buffer.Bytes())
 	buffer.Release()
@@ -81,12 +107,13 @@ func exchangeDNSPacket(ctx context.Context, router *Router, conn N.PacketConn, b
 }
 
 type dnsHijacker struct {
-	router   *Router
+	router   adapter.Router
+	logger   logger.ContextLogger
 	conn     N.PacketConn
 	ctx      context.Context
 	metadata adapter.InboundContext
 }
 
 func (h *dnsHijacker) NewPacketEx(buffer *buf.Buffer, destination M.Socksaddr) {
-	go ExchangeDNSPacket(h.ctx, h.router, h.conn, buffer, h.metadata, destination)
+	go ExchangeDNSPacket(h.ctx, h.router, h.logger, h.conn, buffer, h.metadata, destination)
 }
@@ -0,0 +1,78 @@
+package route
+
+import (
+	"context"
+	"net/netip"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-dns"
+
+	mDNS "github.com/miekg/dns"
+)
+
+var _ dns.Transport = (*clientSubnetTransport)(nil)
+
+// clientSubnetTransport sends a fixed client subnet with queries that do not
+// carry one, and strips it from responses before they are cached.
+type clientSubnetTransport struct {
+	dns.Transport
+	clientSubnet netip.Prefix
+}
+
+func (t *clientSubnetTransport) Exchange(ctx context.Context, message *mDNS.Msg) (*mDNS.Msg, error) {
+	if hasClientSubnet(message) {
+		return t.Transport.Exchange(ctx, message)
+	}
+	response, err := t.Transport.Exchange(ctx, dns.SetClientSubnet(message, t.clientSubnet, false))
+	if err != nil {
+		return nil, err
+	}
+	stripClientSubnet(response)
+	return response, nil
+}
+
+func (r *Router) applyClientSubnet(options *dns.QueryOptions, transport dns.Transport, metadata *adapter.InboundContext) {
+	if !options.ClientSubnet.IsValid() {
+		if clientSubnet, loaded := r.transportClientSubnet[transport]; loaded {
+			options.ClientSubnet = clientSubnet.Build(metadata.Source.Addr)
+		}
+	}
+	if options.ClientSubnet.IsValid() {
+		// responses depend on the subnet, so they must not be served from or saved to the shared cache
+		options.DisableCache = true
+	}
+}
+
+func hasClientSubnet(message *mDNS.Msg) bool {
+	for _, record := range message.Extra {
+		optRecord, isOPTRecord := record.(*mDNS.OPT)
+		if !isOPTRecord {
+			continue
+		}
+		for _, option := range optRecord.Option {
+			if _, isSubnet := option.(*mDNS.EDNS0_SUBNET); isSubnet {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func stripClientSubnet(message *mDNS.Msg) {
+	if message == nil {
+		return
+	}
+	for _, record := range message.Extra {
+		optRecord, isOPTRecord := record.(*mDNS.OPT)
+		if !isOPTRecord {
+			continue
+		}
+		options := optRecord.Option[:0]
+		for _, option := range optRecord.Option {
+			if _, isSubnet := option.(*mDNS.EDNS0_SUBNET); !isSubnet {
+				options = append(options, option)
+			}
+		}
+		optRecord.Option = options
+	}
+}
@@ -0,0 +1,251 @@
+package route
+
+import (
+	"context"
+	"math/rand"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-dns"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+	"github.com/sagernet/sing/common/logger"
+
+	mDNS "github.com/miekg/dns"
+)
+
+const (
+	defaultDNSGroupTimeout  = 3 * time.Second
+	defaultDNSGroupCooldown = 30 * time.Second
+)
+
+var _ dns.Transport = (*groupTransport)(nil)
+
+// groupTransport sends queries to a list of DNS servers. A server that fails
+// is marked unavailable for the cooldown, and is only queried after the
+// available servers have failed too.
+type groupTransport struct {
+	name     string
+	logger   logger.ContextLogger
+	client   *dns.Client
+	members  []*groupMember
+	strategy string
+	timeout  time.Duration
+	cooldown time.Duration
+	raw      bool
+}
+
+type groupMember struct {
+	tag       string
+	transport dns.Transport
+	access    sync.Mutex
+	failedAt  time.Time
+}
+
+type groupResult struct {
+	response  *mDNS.Msg
+	addresses []netip.Addr
+	err       error
+}
+
+func newGroupTransport(name string, logger logger.ContextLogger, client *dns.Client, tags []string, transports []dns.Transport, strategy string, timeout time.Duration, cooldown time.Duration) (*groupTransport, error) {
+	switch strategy {
+	case "":
+		strategy = C.DNSGroupStrategyFallback
+	case C.DNSGroupStrategyFallback, C.DNSGroupStrategyRace, C.DNSGroupStrategyRandom:
+	default:
+		return nil, E.New("unknown group strategy: ", strategy)
+	}
+	if len(transports) == 0 {
+		return nil, E.New("missing group servers")
+	}
+	if timeout == 0 {
+		timeout = defaultDNSGroupTimeout
+	}
+	if cooldown == 0 {
+		cooldown = defaultDNSGroupCooldown
+	}
+	members := make([]*groupMember, 0, len(transports))
+	raw := true
+	for i, transport := range transports {
+		members = append(members, &groupMember{tag: tags[i], transport: transport})
+		if !transport.Raw() {
+			raw = false
+		}
+	}
+	return &groupTransport{
+		name:     name,
+		logger:   logger,
+		client:   client,
+		members:  members,
+		strategy: strategy,
+		timeout:  timeout,
+		cooldown: cooldown,
+		raw:      raw,
+	}, nil
+}
+
+func (t *groupTransport) Name() string {
+	return t.name
+}
+
+func (t *groupTransport) Start() error {
+	return nil
+}
+
+func (t *groupTransport) Reset() {
+}
+
+func (t *groupTransport) Close() error {
+	return nil
+}
+
+func (t *groupTransport) Raw() bool {
+	return t.raw
+}
+
+func (t *groupTransport) Exchange(ctx context.Context, message *mDNS.Msg) (*mDNS.Msg, error) {
+	result := t.query(ctx, func(ctx context.Context, member *groupMember) groupResult {
+		response, err := member.transport.Exchange(ctx, message.Copy())
+		if err == nil && (response.Rcode == mDNS.RcodeServerFailure || response.Rcode == mDNS.RcodeRefused) {
+			return groupResult{response: response, err: E.New(mDNS.RcodeToString[response.Rcode])}
+		}
+		return groupResult{response: response, err: err}
+	})
+	if result.err != nil && result.response != nil {
+		// all servers failed, pass the last error response through
+		return result.response, nil
+	}
+	return result.response, result.err
+}
+
+func (t *groupTransport) Lookup(ctx context.Context, domain string, strategy dns.DomainStrategy) ([]netip.Addr, error) {
+	result := t.query(ctx, func(ctx context.Context, member *groupMember) groupResult {
+		var (
+			addresses []netip.Addr
+			err       error
+		)
+		if member.transport.Raw() {
+			addresses, err = t.client.Lookup(ctx, member.transport, domain, dns.QueryOptions{Strategy: strategy, DisableCache: true})
+		} else {
+			addresses, err = member.transport.Lookup(ctx, domain, strategy)
+		}
+		return groupResult{addresses: addresses, err: err}
+	})
+	return result.addresses, result.err
+}
+
+func (t *groupTransport) query(ctx context.Context, exchange func(ctx context.Context, member *groupMember) groupResult) groupResult {
+	members := t.orderedMembers()
+	if t.strategy == C.DNSGroupStrategyRace {
+		return t.race(ctx, members, exchange)
+	}
+	var lastResult groupResult
+	for _, member := range members {
+		result := t.exchange(ctx, member, exchange)
+		if result.err == nil {
+			return result
+		}
+		if result.response != nil || lastResult.response == nil {
+			lastResult = result
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return lastResult
+}
+
+func (t *groupTransport) race(ctx context.Context, members []*groupMember, exchange func(ctx context.Context, member *groupMember) groupResult) groupResult {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make(chan groupResult, len(members))
+	for _, member := range members {
+		go func(member *groupMember) {
+			results <- t.exchange(ctx, member, exchange)
+		}(member)
+	}
+	var lastResult groupResult
+	for range members {
+		result := <-results
+		if result.err == nil {
+			return result
+		}
+		if result.response != nil || lastResult.response == nil {
+			lastResult = result
+		}
+	}
+	return lastResult
+}
+
+func (t *groupTransport) exchange(ctx context.Context, member *groupMember, exchange func(ctx context.Context, member *groupMember) groupResult) groupResult {
+	exchangeCtx, cancel := context.WithTimeout(ctx, t.timeout)
+	result := exchange(exchangeCtx, member)
+	cancel()
+	member.access.Lock()
+	defer member.access.Unlock()
+	if result.err == nil {
+		member.failedAt = time.Time{}
+	} else if ctx.Err() == nil {
+		if member.failedAt.IsZero() || time.Since(member.failedAt) >= t.cooldown {
+			t.logger.WarnContext(ctx, "server ", member.tag, " marked unavailable for ", t.cooldown, ": ", result.err)
+		}
+		member.failedAt = time.Now()
+	}
+	return result
+}
+
+// orderedMembers returns available servers first, in configured order or
+// shuffled by the random strategy, followed by those in cooldown.
+func (t *groupTransport) orderedMembers() []*groupMember {
+	members := common.Filter(t.members, t.available)
+	unavailable := common.Filter(t.members, func(it *groupMember) bool {
+		return !t.available(it)
+	})
+	if t.strategy == C.DNSGroupStrategyRandom {
+		rand.Shuffle(len(members), func(i, j int) {
+			members[i], members[j] = members[j], members[i]
+		})
+	}
+	if t.strategy == C.DNSGroupStrategyRace && len(members) > 0 {
+		return members
+	}
+	return append(members, unavailable...)
+}
+
+func (t *groupTransport) available(member *groupMember) bool {
+	member.access.Lock()
+	defer member.access.Unlock()
+	return member.failedAt.IsZero() || time.Since(member.failedAt) >= t.cooldown
+}
+
+// newGroupTransport returns nil if a server of the group is not created yet.
+func (r *Router) newGroupTransport(logFactory log.Factory, tag string, server option.DNSServerOptions, transportTagMap map[string]bool, transportMap map[string]dns.Transport) (dns.Transport, error) {
+	if server.Address != "" {
+		return nil, E.New("`address` is conflict with `group`")
+	}
+	if server.Detour != "" || server.AddressResolver != "" || server.ClientSubnet != nil {
+		return nil, E.New("`detour`, `address_resolver` and `client_subnet` are not supported in group")
+	}
+	memberTransports := make([]dns.Transport, 0, len(server.Group.Servers))
+	for _, memberTag := range server.Group.Servers {
+		if memberTag == tag || !transportTagMap[memberTag] {
+			return nil, E.New("group server not found: ", memberTag)
+		}
+		memberTransport, loaded := transportMap[memberTag]
+		if !loaded {
+			return nil, nil
+		}
+		if _, isFakeIP := memberTransport.(adapter.FakeIPTransport); isFakeIP {
+			return nil, E.New("fakeip server is not allowed in group: ", memberTag)
+		}
+		memberTransports = append(memberTransports, memberTransport)
+	}
+	return newGroupTransport(tag, logFactory.NewLogger(F.ToString("dns/group[", tag, "]")), r.dnsClient, server.Group.Servers, memberTransports, server.Group.Strategy, time.Duration(server.Group.Timeout), time.Duration(server.Group.Cooldown))
+}
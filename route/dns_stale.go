@@ -0,0 +1,163 @@
+package route
+
+import (
+	"context"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-dns"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/logger"
+	"github.com/sagernet/sing/contrab/freelru"
+	"github.com/sagernet/sing/contrab/maphash"
+
+	mDNS "github.com/miekg/dns"
+)
+
+// staleTTL is the TTL of stale responses, as recommended by RFC 8767.
+const staleTTL = 30
+
+var _ dns.Transport = (*staleTransport)(nil)
+
+// staleTransport keeps responses after they expire from the DNS cache. An
+// expired response is served for up to maxStale while a refresh runs in the
+// background, and is also served when the upstream fails.
+type staleTransport struct {
+	dns.Transport
+	logger     logger.ContextLogger
+	maxStale   time.Duration
+	cache      freelru.Cache[staleCacheKey, *staleCacheEntry]
+	access     sync.Mutex
+	refreshing map[staleCacheKey]bool
+}
+
+type staleCacheKey struct {
+	question     mDNS.Question
+	clientSubnet netip.Prefix
+}
+
+type staleCacheEntry struct {
+	response *mDNS.Msg
+	expireAt time.Time
+}
+
+func newStaleTransport(transport dns.Transport, logger logger.ContextLogger, maxStale time.Duration, capacity uint32) *staleTransport {
+	if capacity < 1024 {
+		capacity = 1024
+	}
+	return &staleTransport{
+		Transport:  transport,
+		logger:     logger,
+		maxStale:   maxStale,
+		cache:      common.Must1(freelru.NewSharded[staleCacheKey, *staleCacheEntry](capacity, maphash.NewHasher[staleCacheKey]().Hash32)),
+		refreshing: make(map[staleCacheKey]bool),
+	}
+}
+
+func (t *staleTransport) Exchange(ctx context.Context, message *mDNS.Msg) (*mDNS.Msg, error) {
+	if len(message.Question) != 1 {
+		return t.Transport.Exchange(ctx, message)
+	}
+	key := newStaleCacheKey(message)
+	entry, loaded := t.cache.Get(key)
+	if loaded && time.Now().After(entry.expireAt) {
+		t.logger.DebugContext(ctx, "serve stale response for ", formatQuestion(message.Question[0].String()))
+		t.refresh(key, message)
+		return staleResponse(entry.response, message.Id), nil
+	}
+	response, err := t.Transport.Exchange(ctx, message)
+	if err != nil {
+		if loaded {
+			t.logger.DebugContext(ctx, "serve stale response for ", formatQuestion(message.Question[0].String()), " after exchange failed: ", err)
+			return staleResponse(entry.response, message.Id), nil
+		}
+		return nil, err
+	}
+	t.store(key, response)
+	return response, nil
+}
+
+func (t *staleTransport) refresh(key staleCacheKey, message *mDNS.Msg) {
+	t.access.Lock()
+	if t.refreshing[key] {
+		t.access.Unlock()
+		return
+	}
+	t.refreshing[key] = true
+	t.access.Unlock()
+	message = message.Copy()
+	go func() {
+		defer func() {
+			t.access.Lock()
+			delete(t.refreshing, key)
+			t.access.Unlock()
+		}()
+		ctx, cancel := context.WithTimeout(context.Background(), C.DNSTimeout)
+		defer cancel()
+		response, err := t.Transport.Exchange(ctx, message)
+		if err != nil {
+			t.logger.Debug("refresh stale response for ", formatQuestion(message.Question[0].String()), ": ", err)
+			return
+		}
+		t.store(key, response)
+	}()
+}
+
+func (t *staleTransport) store(key staleCacheKey, response *mDNS.Msg) {
+	if response.Rcode != mDNS.RcodeSuccess || len(response.Answer) == 0 {
+		return
+	}
+	var timeToLive uint32
+	for i, record := range response.Answer {
+		if i == 0 || record.Header().Ttl < timeToLive {
+			timeToLive = record.Header().Ttl
+		}
+	}
+	if timeToLive == 0 {
+		return
+	}
+	lifetime := time.Second * time.Duration(timeToLive)
+	t.cache.AddWithLifetime(key, &staleCacheEntry{
+		response: response.Copy(),
+		expireAt: time.Now().Add(lifetime),
+	}, lifetime+t.maxStale)
+}
+
+func (t *staleTransport) ClearCache() {
+	t.cache.Purge()
+}
+
+func newStaleCacheKey(message *mDNS.Msg) staleCacheKey {
+	question := message.Question[0]
+	question.Name = strings.ToLower(question.Name)
+	key := staleCacheKey{question: question}
+	for _, record := range message.Extra {
+		optRecord, isOPTRecord := record.(*mDNS.OPT)
+		if !isOPTRecord {
+			continue
+		}
+		for _, option := range optRecord.Option {
+			if subnet, isSubnet := option.(*mDNS.EDNS0_SUBNET); isSubnet {
+				address, _ := netip.AddrFromSlice(subnet.Address)
+				key.clientSubnet = netip.PrefixFrom(address.Unmap(), int(subnet.SourceNetmask))
+			}
+		}
+	}
+	return key
+}
+
+func staleResponse(response *mDNS.Msg, id uint16) *mDNS.Msg {
+	response = response.Copy()
+	response.Id = id
+	for _, recordList := range [][]mDNS.RR{response.Answer, response.Ns, response.Extra} {
+		for _, record := range recordList {
+			if record.Header().Rrtype != mDNS.TypeOPT {
+				record.Header().Ttl = staleTTL
+			}
+		}
+	}
+	return response
+}
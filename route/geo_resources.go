@@ -2,29 +2,59 @@ package route
 
 import (
 	"context"
+	"crypto/tls"
 	"io"
 	"net"
 	"net/http"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/autoupdate"
 	"github.com/sagernet/sing-box/common/geoip"
 	"github.com/sagernet/sing-box/common/geosite"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/experimental/deprecated"
 	R "github.com/sagernet/sing-box/route/rule"
+	"github.com/sagernet/sing/common"
 	E "github.com/sagernet/sing/common/exceptions"
 	M "github.com/sagernet/sing/common/metadata"
 	"github.com/sagernet/sing/common/rw"
 	"github.com/sagernet/sing/service/filemanager"
 )
 
+const (
+	defaultGeoIPDownloadURL   = "https://github.com/SagerNet/sing-geoip/releases/latest/download/geoip.db"
+	defaultGeositeDownloadURL = "https://github.com/SagerNet/sing-geosite/releases/latest/download/geosite.db"
+)
+
 func (r *Router) GeoIPReader() *geoip.Reader {
-	return r.geoIPReader
+	return r.geoIPReader.Load()
 }
 
+// UpdateGeoDatabases updates the geoip and geosite databases which have
+// update_interval configured.
+func (r *Router) UpdateGeoDatabases(ctx context.Context) error {
+	var errors []error
+	for _, updater := range []*autoupdate.Updater{r.geoIPUpdater, r.geositeUpdater} {
+		if updater == nil {
+			continue
+		}
+		err := updater.Update(ctx)
+		if err != nil {
+			errors = append(errors, E.Cause(err, "update ", updater.Name()))
+		}
+	}
+	return E.Errors(errors...)
+}
+
+// LoadGeosite is called by geosite rule items while the router holds
+// geositeAccess to initialize or reload the database.
 func (r *Router) LoadGeosite(code string) (adapter.Rule, error) {
+	if r.geositeReader == nil {
+		return nil, E.New("geosite database not loaded")
+	}
 	rule, cached := r.geositeCache[code]
 	if cached {
 		return rule, nil
@@ -79,12 +109,51 @@ func (r *Router) prepareGeoIPDatabase() error {
 			return err
 		}
 	}
-	geoReader, codes, err := geoip.Open(geoPath)
+	err := r.reloadGeoIPDatabase(geoPath)
+	if err != nil {
+		return err
+	}
+	if r.geoIPOptions.UpdateInterval > 0 {
+		downloadURL := r.geoIPOptions.DownloadURL
+		if downloadURL == "" {
+			downloadURL = defaultGeoIPDownloadURL
+		}
+		r.geoIPUpdater, err = autoupdate.New(r.ctx, r.logger, "geoip database", autoupdate.Options{
+			Path:           geoPath,
+			DownloadURL:    downloadURL,
+			DownloadDetour: r.geoIPOptions.DownloadDetour,
+			ChecksumURL:    r.geoIPOptions.ChecksumURL,
+			UpdateInterval: time.Duration(r.geoIPOptions.UpdateInterval),
+		}, r.reloadGeoIPDatabase)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Router) reloadGeoIPDatabase(path string) error {
+	var (
+		geoReader *geoip.Reader
+		codes     []string
+		err       error
+	)
+	if r.geoIPOptions.UpdateInterval > 0 {
+		// the updater renames the file, which fails on Windows while it is mapped
+		geoReader, codes, err = geoip.Load(path)
+	} else {
+		geoReader, codes, err = geoip.Open(path)
+	}
 	if err != nil {
 		return E.Cause(err, "open geoip database")
 	}
 	r.logger.Info("loaded geoip database: ", len(codes), " codes")
-	r.geoIPReader = geoReader
+	if oldReader := r.geoIPReader.Swap(geoReader); oldReader != nil {
+		// rules may still be looking up addresses in the previous database
+		time.AfterFunc(C.TCPTimeout, func() {
+			oldReader.Close()
+		})
+	}
 	return nil
 }
 
@@ -132,6 +201,54 @@ func (r *Router) prepareGeositeDatabase() error {
 	} else {
 		return E.Cause(err, "open geosite database")
 	}
+	if r.geositeOptions.UpdateInterval > 0 {
+		downloadURL := r.geositeOptions.DownloadURL
+		if downloadURL == "" {
+			downloadURL = defaultGeositeDownloadURL
+		}
+		r.geositeUpdater, err = autoupdate.New(r.ctx, r.logger, "geosite database", autoupdate.Options{
+			Path:           geoPath,
+			DownloadURL:    downloadURL,
+			DownloadDetour: r.geositeOptions.DownloadDetour,
+			ChecksumURL:    r.geositeOptions.ChecksumURL,
+			UpdateInterval: time.Duration(r.geositeOptions.UpdateInterval),
+		}, r.reloadGeositeDatabase)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Router) reloadGeositeDatabase(path string) error {
+	geoReader, codes, err := geosite.Open(path)
+	if err != nil {
+		return E.Cause(err, "open geosite database")
+	}
+	r.geositeAccess.Lock()
+	defer r.geositeAccess.Unlock()
+	r.geositeReader = geoReader
+	r.geositeCache = make(map[string]adapter.Rule)
+	// rule items swap their matchers atomically, rules that are not updated
+	// yet keep matching with the previous database
+	defer func() {
+		common.Close(geoReader)
+		r.geositeCache = nil
+		r.geositeReader = nil
+	}()
+	for _, rule := range r.allRules() {
+		err = rule.UpdateGeosite()
+		if err != nil {
+			return E.Cause(err, "update geosite")
+		}
+	}
+	for _, rule := range r.dnsRules {
+		err = rule.UpdateGeosite()
+		if err != nil {
+			return E.Cause(err, "update geosite")
+		}
+	}
+	r.logger.Info("loaded geosite database: ", len(codes), " codes")
 	return nil
 }
 
@@ -140,7 +257,7 @@ func (r *Router) downloadGeoIPDatabase(savePath string) error {
 	if r.geoIPOptions.DownloadURL != "" {
 		downloadURL = r.geoIPOptions.DownloadURL
 	} else {
-		downloadURL = "https://github.com/SagerNet/sing-geoip/releases/latest/download/geoip.db"
+		downloadURL = defaultGeoIPDownloadURL
 	}
 	r.logger.Info("downloading geoip database")
 	var detour adapter.Outbound
@@ -165,6 +282,9 @@ func (r *Router) downloadGeoIPDatabase(savePath string) error {
 			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
 				return detour.DialContext(ctx, network, M.ParseSocksaddr(addr))
 			},
+			TLSClientConfig: &tls.Config{
+				RootCAs: adapter.RootPoolFromContext(r.ctx),
+			},
 		},
 	}
 	defer httpClient.CloseIdleConnections()
@@ -195,7 +315,7 @@ func (r *Router) downloadGeositeDatabase(savePath string) error {
 	if r.geositeOptions.DownloadURL != "" {
 		downloadURL = r.geositeOptions.DownloadURL
 	} else {
-		downloadURL = "https://github.com/SagerNet/sing-geosite/releases/latest/download/geosite.db"
+		downloadURL = defaultGeositeDownloadURL
 	}
 	r.logger.Info("downloading geosite database")
 	var detour adapter.Outbound
@@ -220,6 +340,9 @@ func (r *Router) downloadGeositeDatabase(savePath string) error {
 			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
 				return detour.DialContext(ctx, network, M.ParseSocksaddr(addr))
 			},
+			TLSClientConfig: &tls.Config{
+				RootCAs: adapter.RootPoolFromContext(r.ctx),
+			},
 		},
 	}
 	defer httpClient.CloseIdleConnections()
@@ -34,6 +34,7 @@ import (
 var _ adapter.NetworkManager = (*NetworkManager)(nil)
 
 type NetworkManager struct {
+	ctx               context.Context
 	logger            logger.ContextLogger
 	interfaceFinder   *control.DefaultInterfaceFinder
 	networkInterfaces atomic.TypedValue[[]adapter.NetworkInterface]
@@ -52,15 +53,27 @@ type NetworkManager struct {
 	outbound               adapter.OutboundManager
 	wifiState              adapter.WIFIState
 	started                bool
+
+	defaultInterfaces             []string
+	defaultInterfaceCheckURL      string
+	defaultInterfaceCheckInterval time.Duration
+	selectedInterface             atomic.TypedValue[string]
+	defaultInterfaceCheck         chan struct{}
+	defaultInterfaceCancel        context.CancelFunc
 }
 
 func NewNetworkManager(ctx context.Context, logger logger.ContextLogger, routeOptions option.RouteOptions) (*NetworkManager, error) {
+	var defaultInterface string
+	if len(routeOptions.DefaultInterface) > 0 {
+		defaultInterface = routeOptions.DefaultInterface[0]
+	}
 	nm := &NetworkManager{
+		ctx:                 ctx,
 		logger:              logger,
 		interfaceFinder:     control.NewDefaultInterfaceFinder(),
 		autoDetectInterface: routeOptions.AutoDetectInterface,
 		defaultOptions: adapter.NetworkOptions{
-			BindInterface:       routeOptions.DefaultInterface,
+			BindInterface:       defaultInterface,
 			RoutingMark:         uint32(routeOptions.DefaultMark),
 			NetworkStrategy:     (*C.NetworkStrategy)(routeOptions.DefaultNetworkStrategy),
 			NetworkType:         common.Map(routeOptions.DefaultNetworkType, option.InterfaceType.Build),
@@ -72,9 +85,20 @@ func NewNetworkManager(ctx context.Context, logger logger.ContextLogger, routeOp
 		endpoint:          service.FromContext[adapter.EndpointManager](ctx),
 		inbound:           service.FromContext[adapter.InboundManager](ctx),
 		outbound:          service.FromContext[adapter.OutboundManager](ctx),
+
+		defaultInterfaces:             routeOptions.DefaultInterface,
+		defaultInterfaceCheckURL:      routeOptions.DefaultInterfaceCheckURL,
+		defaultInterfaceCheckInterval: time.Duration(routeOptions.DefaultInterfaceCheckInterval),
+	}
+	if len(nm.defaultInterfaces) > 1 {
+		if nm.defaultInterfaceCheckInterval == 0 {
+			nm.defaultInterfaceCheckInterval = C.DefaultURLTestInterval
+		}
+		nm.selectedInterface.Store(nm.defaultInterfaces[0])
+		nm.defaultInterfaceCheck = make(chan struct{}, 1)
 	}
 	if routeOptions.DefaultNetworkStrategy != nil {
-		if routeOptions.DefaultInterface != "" {
+		if len(routeOptions.DefaultInterface) > 0 {
 			return nil, E.New("`default_network_strategy` is conflict with `default_interface`")
 		}
 		if !routeOptions.AutoDetectInterface {
@@ -167,6 +191,11 @@ func (r *NetworkManager) Start(stage adapter.StartStage) error {
 		}
 	case adapter.StartStatePostStart:
 		r.started = true
+		if len(r.defaultInterfaces) > 1 {
+			ctx, cancel := context.WithCancel(r.ctx)
+			r.defaultInterfaceCancel = cancel
+			go r.loopCheckDefaultInterface(ctx)
+		}
 	}
 	return nil
 }
@@ -174,6 +203,9 @@ func (r *NetworkManager) Start(stage adapter.StartStage) error {
 func (r *NetworkManager) Close() error {
 	monitor := taskmonitor.New(r.logger, C.StopTimeout)
 	var err error
+	if r.defaultInterfaceCancel != nil {
+		r.defaultInterfaceCancel()
+	}
 	if r.packageManager != nil {
 		monitor.Start("close package manager")
 		err = E.Append(err, r.packageManager.Close(), func(err error) error {
@@ -427,6 +459,7 @@ func (r *NetworkManager) notifyInterfaceUpdate(defaultInterface *control.Interfa
 	if !r.started {
 		return
 	}
+	r.triggerDefaultInterfaceCheck()
 	r.ResetNetwork()
 }
 
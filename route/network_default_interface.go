@@ -0,0 +1,82 @@
+package route
+
+import (
+	"context"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/dialer"
+	"github.com/sagernet/sing-box/common/urltest"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common/control"
+	N "github.com/sagernet/sing/common/network"
+)
+
+func (r *NetworkManager) BindDefaultInterfaceFunc() control.Func {
+	if len(r.defaultInterfaces) <= 1 {
+		return control.BindToInterface(r.interfaceFinder, r.defaultOptions.BindInterface, -1)
+	}
+	return control.BindToInterfaceFunc(r.interfaceFinder, func(network string, address string) (interfaceName string, interfaceIndex int, err error) {
+		return r.selectedInterface.Load(), -1, nil
+	})
+}
+
+func (r *NetworkManager) triggerDefaultInterfaceCheck() {
+	if r.defaultInterfaceCheck == nil {
+		return
+	}
+	select {
+	case r.defaultInterfaceCheck <- struct{}{}:
+	default:
+	}
+}
+
+func (r *NetworkManager) loopCheckDefaultInterface(ctx context.Context) {
+	interfaceDialers := make([]N.Dialer, 0, len(r.defaultInterfaces))
+	for _, interfaceName := range r.defaultInterfaces {
+		interfaceDialer, err := dialer.NewDefault(ctx, option.DialerOptions{
+			BindInterface: interfaceName,
+		})
+		if err != nil {
+			r.logger.Error("create dialer for default interface ", interfaceName, ": ", err)
+			return
+		}
+		interfaceDialers = append(interfaceDialers, interfaceDialer)
+	}
+	ticker := time.NewTicker(r.defaultInterfaceCheckInterval)
+	defer ticker.Stop()
+	for {
+		r.checkDefaultInterface(ctx, interfaceDialers)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-r.defaultInterfaceCheck:
+		}
+	}
+}
+
+// checkDefaultInterface selects the first healthy interface in the configured order,
+// the current selection is kept if all interfaces are unavailable.
+func (r *NetworkManager) checkDefaultInterface(ctx context.Context, interfaceDialers []N.Dialer) {
+	for i, interfaceDialer := range interfaceDialers {
+		interfaceName := r.defaultInterfaces[i]
+		testCtx, cancel := context.WithTimeout(urltest.ContextWithRootCAs(ctx, adapter.RootPoolFromContext(r.ctx)), C.TCPTimeout)
+		_, err := urltest.URLTest(testCtx, r.defaultInterfaceCheckURL, interfaceDialer)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			r.logger.Debug("default interface ", interfaceName, " unavailable: ", err)
+			continue
+		}
+		if r.selectedInterface.Swap(interfaceName) != interfaceName {
+			r.logger.Info("switched default interface to ", interfaceName)
+			r.ResetNetwork()
+		}
+		return
+	}
+	r.logger.Error("all default interfaces unavailable")
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"net"
+	"net/http"
 	"net/netip"
 	"os"
 	"os/user"
@@ -98,6 +99,9 @@ func (r *Router) routeConnection(ctx context.Context, conn net.Conn, metadata ad
 	if deadline.NeedAdditionalReadDeadline(conn) {
 		conn = deadline.NewConn(conn)
 	}
+	if metadata.TLSFingerprintJA3 != "" {
+		r.logger.DebugContext(ctx, "inbound TLS fingerprint: ja3=", metadata.TLSFingerprintJA3, ", ja4=", metadata.TLSFingerprintJA4)
+	}
 	selectedRule, _, buffers, _, err := r.matchRule(ctx, &metadata, false, conn, nil)
 	if err != nil {
 		return err
@@ -118,18 +122,26 @@ func (r *Router) routeConnection(ctx context.Context, conn net.Conn, metadata ad
 			}
 		case *rule.RuleActionReject:
 			buf.ReleaseMulti(buffers)
-			N.CloseOnHandshakeFailure(conn, onClose, action.Error(ctx))
+			if action.NetworkMethod(N.NetworkTCP) == C.RuleActionRejectMethodHTTP && metadata.Protocol == C.ProtocolHTTP {
+				r.rejectHTTP(ctx, conn, onClose)
+				return nil
+			}
+			N.CloseOnHandshakeFailure(conn, onClose, action.Error(ctx, N.NetworkTCP))
 			return nil
 		case *rule.RuleActionHijackDNS:
 			for _, buffer := range buffers {
 				conn = bufio.NewCachedConn(conn, buffer)
 			}
-			r.hijackDNSStream(ctx, conn, metadata)
+			r.hijackDNSStream(ctx, conn, metadata, action)
 			return nil
 		}
 	}
 	if selectedRule == nil {
-		defaultOutbound := r.outbound.Default()
+		defaultOutbound, err := r.defaultOutbound(&metadata)
+		if err != nil {
+			buf.ReleaseMulti(buffers)
+			return err
+		}
 		if !common.Contains(defaultOutbound.Network(), N.NetworkTCP) {
 			buf.ReleaseMulti(buffers)
 			return E.New("TCP is not supported by default outbound: ", defaultOutbound.Tag())
@@ -140,8 +152,8 @@ func (r *Router) routeConnection(ctx context.Context, conn net.Conn, metadata ad
 	for _, buffer := range buffers {
 		conn = bufio.NewCachedConn(conn, buffer)
 	}
-	if r.tracker != nil {
-		conn = r.tracker.RoutedConnection(ctx, conn, metadata, selectedRule, selectedOutbound)
+	for _, tracker := range r.trackers {
+		conn = tracker.RoutedConnection(ctx, conn, metadata, selectedRule, selectedOutbound)
 	}
 	if outboundHandler, isHandler := selectedOutbound.(adapter.ConnectionHandlerEx); isHandler {
 		outboundHandler.NewConnectionEx(ctx, conn, metadata, onClose)
@@ -239,15 +251,19 @@ func (r *Router) routePacketConnection(ctx context.Context, conn N.PacketConn, m
 			}
 		case *rule.RuleActionReject:
 			N.ReleaseMultiPacketBuffer(packetBuffers)
-			N.CloseOnHandshakeFailure(conn, onClose, action.Error(ctx))
+			N.CloseOnHandshakeFailure(conn, onClose, action.Error(ctx, N.NetworkUDP))
 			return nil
 		case *rule.RuleActionHijackDNS:
-			r.hijackDNSPacket(ctx, conn, packetBuffers, metadata)
+			r.hijackDNSPacket(ctx, conn, packetBuffers, metadata, action)
 			return nil
 		}
 	}
 	if selectedRule == nil || selectReturn {
-		defaultOutbound := r.outbound.Default()
+		defaultOutbound, err := r.defaultOutbound(&metadata)
+		if err != nil {
+			N.ReleaseMultiPacketBuffer(packetBuffers)
+			return err
+		}
 		if !common.Contains(defaultOutbound.Network(), N.NetworkUDP) {
 			N.ReleaseMultiPacketBuffer(packetBuffers)
 			return E.New("UDP is not supported by outbound: ", defaultOutbound.Tag())
@@ -258,8 +274,8 @@ func (r *Router) routePacketConnection(ctx context.Context, conn N.PacketConn, m
 		conn = bufio.NewCachedPacketConn(conn, buffer.Buffer, buffer.Destination)
 		N.PutPacketBuffer(buffer)
 	}
-	if r.tracker != nil {
-		conn = r.tracker.RoutedPacketConnection(ctx, conn, metadata, selectedRule, selectedOutbound)
+	for _, tracker := range r.trackers {
+		conn = tracker.RoutedPacketConnection(ctx, conn, metadata, selectedRule, selectedOutbound)
 	}
 	if metadata.FakeIP {
 		conn = bufio.NewNATPacketConn(bufio.NewNetPacketConn(conn), metadata.OriginDestination, metadata.Destination)
@@ -284,7 +300,29 @@ func (r *Router) PreMatch(metadata adapter.InboundContext) error {
 	if !isReject {
 		return nil
 	}
-	return rejectAction.Error(context.Background())
+	if rejectAction.NetworkMethod(metadata.Network) == C.RuleActionRejectMethodHTTP {
+		// the connection must be established to reply
+		return nil
+	}
+	return rejectAction.Error(context.Background(), metadata.Network)
+}
+
+func (r *Router) rejectHTTP(ctx context.Context, conn net.Conn, onClose N.CloseHandlerFunc) {
+	response := &http.Response{
+		StatusCode: http.StatusForbidden,
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Close:      true,
+	}
+	err := response.Write(conn)
+	if err != nil {
+		r.logger.DebugContext(ctx, E.Cause(err, "write HTTP reject response"))
+	}
+	conn.Close()
+	if onClose != nil {
+		onClose(err)
+	}
 }
 
 func (r *Router) matchRule(
@@ -294,6 +332,14 @@ func (r *Router) matchRule(
 	selectedRule adapter.Rule, selectedRuleIndex int,
 	buffers []*buf.Buffer, packetBuffers []*N.PacketBuffer, fatalErr error,
 ) {
+	if r.privateRule != nil && r.privateRule.Match(metadata) {
+		if !preMatch {
+			r.logger.DebugContext(ctx, "match[private] => ", r.privateRule.Action())
+		}
+		selectedRule = r.privateRule
+		selectedRuleIndex = -1
+		return
+	}
 	if r.processSearcher != nil && metadata.ProcessInfo == nil {
 		var originDestination netip.AddrPort
 		if metadata.OriginDestination.IsValid() {
@@ -380,11 +426,14 @@ func (r *Router) matchRule(
 		if metadata.InboundOptions.UDPDisableDomainUnmapping {
 			metadata.UDPDisableDomainUnmapping = true
 		}
-		metadata.InboundOptions = option.InboundOptions{}
+		// the sniff buffer limit is also used by sniff actions
+		metadata.InboundOptions = option.InboundOptions{
+			SniffBufferLimit: metadata.InboundOptions.SniffBufferLimit,
+		}
 	}
 
 match:
-	for currentRuleIndex, currentRule := range r.rules {
+	for currentRuleIndex, currentRule := range r.contextRules(metadata) {
 		metadata.ResetRuleCache()
 		if !currentRule.Match(metadata) {
 			continue
@@ -415,45 +464,7 @@ match:
 			routeOptions = action
 		}
 		if routeOptions != nil {
-			// TODO: add nat
-			if (routeOptions.OverrideAddress.IsValid() || routeOptions.OverridePort > 0) && !metadata.RouteOriginalDestination.IsValid() {
-				metadata.RouteOriginalDestination = metadata.Destination
-			}
-			if routeOptions.OverrideAddress.IsValid() {
-				metadata.Destination = M.Socksaddr{
-					Addr: routeOptions.OverrideAddress.Addr,
-					Port: metadata.Destination.Port,
-					Fqdn: routeOptions.OverrideAddress.Fqdn,
-				}
-			}
-			if routeOptions.OverridePort > 0 {
-				metadata.Destination = M.Socksaddr{
-					Addr: metadata.Destination.Addr,
-					Port: routeOptions.OverridePort,
-					Fqdn: metadata.Destination.Fqdn,
-				}
-			}
-			if routeOptions.NetworkStrategy != nil {
-				metadata.NetworkStrategy = routeOptions.NetworkStrategy
-			}
-			if len(routeOptions.NetworkType) > 0 {
-				metadata.NetworkType = routeOptions.NetworkType
-			}
-			if len(routeOptions.FallbackNetworkType) > 0 {
-				metadata.FallbackNetworkType = routeOptions.FallbackNetworkType
-			}
-			if routeOptions.FallbackDelay != 0 {
-				metadata.FallbackDelay = routeOptions.FallbackDelay
-			}
-			if routeOptions.UDPDisableDomainUnmapping {
-				metadata.UDPDisableDomainUnmapping = true
-			}
-			if routeOptions.UDPConnect {
-				metadata.UDPConnect = true
-			}
-			if routeOptions.UDPTimeout > 0 {
-				metadata.UDPTimeout = routeOptions.UDPTimeout
-			}
+			applyRouteOptions(metadata, routeOptions)
 		}
 		switch action := currentRule.Action().(type) {
 		case *rule.RuleActionSniff:
@@ -508,6 +519,54 @@ match:
 	return
 }
 
+func applyRouteOptions(metadata *adapter.InboundContext, routeOptions *rule.RuleActionRouteOptions) {
+	// TODO: add nat
+	if (routeOptions.OverrideAddress.IsValid() || routeOptions.OverridePort > 0) && !metadata.RouteOriginalDestination.IsValid() {
+		metadata.RouteOriginalDestination = metadata.Destination
+	}
+	if routeOptions.OverrideAddress.IsValid() {
+		metadata.Destination = M.Socksaddr{
+			Addr: routeOptions.OverrideAddress.Addr,
+			Port: metadata.Destination.Port,
+			Fqdn: routeOptions.OverrideAddress.Fqdn,
+		}
+	}
+	if routeOptions.OverridePort > 0 {
+		metadata.Destination = M.Socksaddr{
+			Addr: metadata.Destination.Addr,
+			Port: routeOptions.OverridePort,
+			Fqdn: metadata.Destination.Fqdn,
+		}
+	}
+	if routeOptions.NetworkStrategy != nil {
+		metadata.NetworkStrategy = routeOptions.NetworkStrategy
+	}
+	if len(routeOptions.NetworkType) > 0 {
+		metadata.NetworkType = routeOptions.NetworkType
+	}
+	if len(routeOptions.FallbackNetworkType) > 0 {
+		metadata.FallbackNetworkType = routeOptions.FallbackNetworkType
+	}
+	if routeOptions.FallbackDelay != 0 {
+		metadata.FallbackDelay = routeOptions.FallbackDelay
+	}
+	if routeOptions.UDPDisableDomainUnmapping {
+		metadata.UDPDisableDomainUnmapping = true
+	}
+	if routeOptions.UDPConnect {
+		metadata.UDPConnect = true
+	}
+	if routeOptions.UDPTimeout > 0 {
+		metadata.UDPTimeout = routeOptions.UDPTimeout
+	}
+	if routeOptions.DSCP != nil {
+		metadata.DSCP = routeOptions.DSCP
+	}
+	if routeOptions.ECN != nil {
+		metadata.ECN = routeOptions.ECN
+	}
+}
+
 func (r *Router) actionSniff(
 	ctx context.Context, metadata *adapter.InboundContext, action *rule.RuleActionSniff,
 	inputConn net.Conn, inputPacketConn N.PacketConn,
@@ -515,6 +574,11 @@ func (r *Router) actionSniff(
 	if sniff.Skip(metadata) {
 		return
 	} else if inputConn != nil {
+		if !r.acquireSniffBuffer(metadata) {
+			r.logger.DebugContext(ctx, "sniff skipped: buffer limit of inbound exceeded")
+			return
+		}
+		defer r.releaseSniffBuffer(metadata)
 		sniffBuffer := buf.NewPacket()
 		var streamSniffers []sniff.StreamSniffer
 		if len(action.StreamSniffers) > 0 {
@@ -527,6 +591,7 @@ func (r *Router) actionSniff(
 				sniff.BitTorrent,
 				sniff.SSH,
 				sniff.RDP,
+				sniff.Minecraft,
 			}
 		}
 		err := sniff.PeekStream(
@@ -552,6 +617,9 @@ func (r *Router) actionSniff(
 			} else {
 				r.logger.DebugContext(ctx, "sniffed protocol: ", metadata.Protocol)
 			}
+			if metadata.HTTPMethod != "" {
+				r.logger.DebugContext(ctx, "sniffed http request: ", metadata.HTTPMethod, " ", metadata.HTTPPath, ", user agent: ", metadata.HTTPUserAgent)
+			}
 		}
 		if !sniffBuffer.IsEmpty() {
 			buffer = sniffBuffer
@@ -560,6 +628,11 @@ func (r *Router) actionSniff(
 		}
 	} else if inputPacketConn != nil {
 		for {
+			if !r.acquireSniffBuffer(metadata) {
+				r.logger.DebugContext(ctx, "sniff skipped: buffer limit of inbound exceeded")
+				return
+			}
+			defer r.releaseSniffBuffer(metadata)
 			var (
 				sniffBuffer = buf.NewPacket()
 				destination M.Socksaddr
@@ -612,6 +685,11 @@ func (r *Router) actionSniff(
 							sniff.UTP,
 							sniff.UDPTracker,
 							sniff.DTLSRecord,
+							sniff.NTP,
+							sniff.Syslog,
+							sniff.SIP,
+							sniff.RakNet,
+							sniff.A2S,
 						}
 					}
 					err = sniff.PeekPacket(
@@ -656,6 +734,9 @@ func (r *Router) actionSniff(
 }
 
 func (r *Router) actionResolve(ctx context.Context, metadata *adapter.InboundContext, action *rule.RuleActionResolve) error {
+	if action.SkipIfResolved && len(metadata.DestinationAddresses) > 0 {
+		return nil
+	}
 	if metadata.Destination.IsFqdn() {
 		metadata.DNSServer = action.Server
 		addresses, err := r.Lookup(adapter.WithContext(ctx, metadata), metadata.Destination.Fqdn, action.Strategy)
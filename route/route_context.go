@@ -0,0 +1,99 @@
+package route
+
+import (
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/option"
+	R "github.com/sagernet/sing-box/route/rule"
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+type routeContext struct {
+	name  string
+	rules []adapter.Rule
+	final string
+}
+
+func (r *Router) initializeRouteContexts(options []option.RouteContextOptions) error {
+	if len(options) == 0 {
+		return nil
+	}
+	r.routeContextByInbound = make(map[string]*routeContext)
+	nameMap := make(map[string]bool)
+	for i, contextOptions := range options {
+		if contextOptions.Name == "" {
+			return E.New("parse route context[", i, "]: missing name")
+		}
+		if nameMap[contextOptions.Name] {
+			return E.New("duplicate route context name: ", contextOptions.Name)
+		}
+		nameMap[contextOptions.Name] = true
+		if len(contextOptions.Inbound) == 0 {
+			return E.New("parse route context[", contextOptions.Name, "]: missing inbound")
+		}
+		currentContext := &routeContext{
+			name:  contextOptions.Name,
+			rules: make([]adapter.Rule, 0, len(contextOptions.Rules)),
+			final: contextOptions.Final,
+		}
+		for j, ruleOptions := range contextOptions.Rules {
+			routeRule, err := R.NewRule(r.ctx, r.logger, ruleOptions, true)
+			if err != nil {
+				return E.Cause(err, "parse route context[", contextOptions.Name, "]: parse rule[", j, "]")
+			}
+			currentContext.rules = append(currentContext.rules, r.newGroupRule(ruleOptions.Group(), routeRule))
+		}
+		for _, inboundTag := range contextOptions.Inbound {
+			if existsContext, loaded := r.routeContextByInbound[inboundTag]; loaded {
+				return E.New("inbound ", inboundTag, " is assigned to both route context ", existsContext.name, " and ", contextOptions.Name)
+			}
+			r.routeContextByInbound[inboundTag] = currentContext
+		}
+		r.routeContexts = append(r.routeContexts, currentContext)
+	}
+	return nil
+}
+
+func (r *Router) contextRules(metadata *adapter.InboundContext) []adapter.Rule {
+	if currentContext, loaded := r.routeContextByInbound[metadata.Inbound]; loaded {
+		return currentContext.rules
+	}
+	return r.rules
+}
+
+func (r *Router) defaultOutbound(metadata *adapter.InboundContext) (adapter.Outbound, error) {
+	if currentContext, loaded := r.routeContextByInbound[metadata.Inbound]; loaded && currentContext.final != "" {
+		outbound, loaded := r.outbound.Outbound(currentContext.final)
+		if !loaded {
+			return nil, E.New("final outbound of route context ", currentContext.name, " not found: ", currentContext.final)
+		}
+		return outbound, nil
+	}
+	if modeFinal := r.modeFinal.Load(); modeFinal != nil {
+		return modeFinal, nil
+	}
+	return r.outbound.Default(), nil
+}
+
+func (r *Router) allRules() []adapter.Rule {
+	if len(r.routeContexts) == 0 {
+		return r.rules
+	}
+	rules := make([]adapter.Rule, 0, len(r.rules))
+	rules = append(rules, r.rules...)
+	for _, currentContext := range r.routeContexts {
+		rules = append(rules, currentContext.rules...)
+	}
+	return rules
+}
+
+func routeContextRules(options option.RouteOptions) []option.Rule {
+	if len(options.Contexts) == 0 {
+		return options.Rules
+	}
+	rules := make([]option.Rule, 0, len(options.Rules))
+	rules = append(rules, options.Rules...)
+	for _, contextOptions := range options.Contexts {
+		rules = append(rules, contextOptions.Rules...)
+	}
+	return rules
+}
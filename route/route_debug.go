@@ -0,0 +1,69 @@
+package route
+
+import (
+	"context"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/route/rule"
+
+	mDNS "github.com/miekg/dns"
+)
+
+// TestRoute evaluates DNS and route rules against the given metadata without
+// sniffing, resolving or dialing anything.
+func (r *Router) TestRoute(ctx context.Context, metadata adapter.InboundContext) adapter.RouteTestResult {
+	var result adapter.RouteTestResult
+	if currentContext, loaded := r.routeContextByInbound[metadata.Inbound]; loaded {
+		result.Context = currentContext.name
+	}
+	if metadata.Destination.IsIPv4() {
+		metadata.IPVersion = 4
+	} else if metadata.Destination.IsIPv6() {
+		metadata.IPVersion = 6
+	}
+	if metadata.Destination.IsFqdn() {
+		dnsMetadata := metadata
+		dnsMetadata.Domain = metadata.Destination.Fqdn
+		dnsMetadata.QueryType = mDNS.TypeA
+		transport, _, dnsRule, dnsRuleIndex := r.matchDNS(adapter.WithContext(ctx, &dnsMetadata), true, -1, true)
+		if dnsRule != nil {
+			result.DNSRule = &adapter.RouteTestMatch{
+				Index:  dnsRuleIndex,
+				Rule:   dnsRule.String(),
+				Action: dnsRule.Action().String(),
+			}
+		}
+		if transport != nil {
+			result.DNSServer = transport.Name()
+		}
+	}
+	for currentRuleIndex, currentRule := range r.contextRules(&metadata) {
+		metadata.ResetRuleCache()
+		if !currentRule.Match(&metadata) {
+			continue
+		}
+		result.Rules = append(result.Rules, adapter.RouteTestMatch{
+			Index:  currentRuleIndex,
+			Rule:   currentRule.String(),
+			Action: currentRule.Action().String(),
+		})
+		switch action := currentRule.Action().(type) {
+		case *rule.RuleActionRoute:
+			applyRouteOptions(&metadata, &action.RuleActionRouteOptions)
+			result.Outbound = action.Outbound
+			return result
+		case *rule.RuleActionRouteOptions:
+			applyRouteOptions(&metadata, action)
+		}
+		switch currentRule.Action().Type() {
+		case C.RuleActionTypeReject, C.RuleActionTypeHijackDNS:
+			return result
+		}
+	}
+	defaultOutbound, err := r.defaultOutbound(&metadata)
+	if err == nil {
+		result.Outbound = defaultOutbound.Tag()
+	}
+	return result
+}
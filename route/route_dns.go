@@ -3,6 +3,8 @@ package route
 import (
 	"context"
 	"errors"
+	"net"
+	"net/http/httptrace"
 	"net/netip"
 	"strings"
 	"time"
@@ -12,6 +14,7 @@ import (
 	R "github.com/sagernet/sing-box/route/rule"
 	"github.com/sagernet/sing-dns"
 	"github.com/sagernet/sing-tun"
+	"github.com/sagernet/sing/common"
 	"github.com/sagernet/sing/common/cache"
 	E "github.com/sagernet/sing/common/exceptions"
 	F "github.com/sagernet/sing/common/format"
@@ -79,9 +82,10 @@ func (r *Router) matchDNS(ctx context.Context, allowFakeIP bool, ruleIndex int,
 				if action.RewriteTTL != nil {
 					options.RewriteTTL = action.RewriteTTL
 				}
-				if action.ClientSubnet.IsValid() {
-					options.ClientSubnet = action.ClientSubnet
+				if clientSubnet := action.ClientSubnet.Build(metadata.Source.Addr); clientSubnet.IsValid() {
+					options.ClientSubnet = clientSubnet
 				}
+				r.applyClientSubnet(&options, transport, metadata)
 				if domainStrategy, dsLoaded := r.transportDomainStrategy[transport]; dsLoaded {
 					options.Strategy = domainStrategy
 				} else {
@@ -96,8 +100,8 @@ func (r *Router) matchDNS(ctx context.Context, allowFakeIP bool, ruleIndex int,
 				if action.RewriteTTL != nil {
 					options.RewriteTTL = action.RewriteTTL
 				}
-				if action.ClientSubnet.IsValid() {
-					options.ClientSubnet = action.ClientSubnet
+				if clientSubnet := action.ClientSubnet.Build(metadata.Source.Addr); clientSubnet.IsValid() {
+					options.ClientSubnet = clientSubnet
 				}
 				r.logger.DebugContext(ctx, "match[", currentRuleIndex, "] => ", currentRule.Action())
 			case *R.RuleActionReject:
@@ -106,6 +110,7 @@ func (r *Router) matchDNS(ctx context.Context, allowFakeIP bool, ruleIndex int,
 			}
 		}
 	}
+	r.applyClientSubnet(&options, r.defaultTransport, metadata)
 	if domainStrategy, dsLoaded := r.transportDomainStrategy[r.defaultTransport]; dsLoaded {
 		options.Strategy = domainStrategy
 	} else {
@@ -133,8 +138,16 @@ func (r *Router) Exchange(ctx context.Context, message *mDNS.Msg) (*mDNS.Msg, er
 		transport dns.Transport
 		err       error
 	)
-	response, cached = r.dnsClient.ExchangeCache(ctx, message)
+	dnsClient := r.dnsClientFor(ctx)
+	response, cached = dnsClient.ExchangeCache(ctx, message)
 	if !cached {
+		withClientSubnet := hasClientSubnet(message)
+		// only the server selected explicitly for this query bypasses DNS rules,
+		// DNSServer of the metadata is set by resolve actions and domain resolvers for Lookup.
+		server := adapter.DNSServerFromContext(ctx)
+		if server != "" {
+			ctx = adapter.ContextWithDNSServer(ctx, "")
+		}
 		var metadata *adapter.InboundContext
 		ctx, metadata = adapter.ExtendContext(ctx)
 		metadata.Destination = M.Socksaddr{}
@@ -155,10 +168,25 @@ func (r *Router) Exchange(ctx context.Context, message *mDNS.Msg) (*mDNS.Msg, er
 		for {
 			dnsCtx := adapter.OverrideContext(ctx)
 			var addressLimit bool
-			transport, options, rule, ruleIndex = r.matchDNS(ctx, true, ruleIndex, isAddressQuery(message))
+			if server != "" {
+				var loaded bool
+				transport, loaded = r.transportMap[server]
+				if !loaded {
+					return nil, E.New("transport not found: ", server)
+				}
+				if domainStrategy, dsLoaded := r.transportDomainStrategy[transport]; dsLoaded {
+					options.Strategy = domainStrategy
+				} else {
+					options.Strategy = r.defaultDomainStrategy
+				}
+				r.applyClientSubnet(&options, transport, metadata)
+			} else {
+				transport, options, rule, ruleIndex = r.matchDNS(ctx, true, ruleIndex, isAddressQuery(message))
+			}
 			if rule != nil {
 				switch action := rule.Action().(type) {
 				case *R.RuleActionReject:
+					r.trackDNSQuery(ctx, message.Question[0], true)
 					switch action.Method {
 					case C.RuleActionRejectMethodDefault:
 						return dns.FixedResponse(message.Id, message.Question[0], nil, 0), nil
@@ -170,13 +198,13 @@ func (r *Router) Exchange(ctx context.Context, message *mDNS.Msg) (*mDNS.Msg, er
 			r.dnsLogger.DebugContext(ctx, "exchange ", formatQuestion(message.Question[0].String()), " via ", transport.Name())
 			if rule != nil && rule.WithAddressLimit() {
 				addressLimit = true
-				response, err = r.dnsClient.ExchangeWithResponseCheck(dnsCtx, transport, message, options, func(responseAddrs []netip.Addr) bool {
+				response, err = dnsClient.ExchangeWithResponseCheck(dnsCtx, transport, message, options, func(responseAddrs []netip.Addr) bool {
 					metadata.DestinationAddresses = responseAddrs
 					return rule.MatchAddressLimit(metadata)
 				})
 			} else {
 				addressLimit = false
-				response, err = r.dnsClient.Exchange(dnsCtx, transport, message, options)
+				response, err = dnsClient.Exchange(dnsCtx, transport, message, options)
 			}
 			var rejected bool
 			if err != nil {
@@ -197,10 +225,14 @@ func (r *Router) Exchange(ctx context.Context, message *mDNS.Msg) (*mDNS.Msg, er
 			}
 			break
 		}
+		if !withClientSubnet {
+			stripClientSubnet(response)
+		}
 	}
 	if err != nil {
 		return nil, err
 	}
+	r.trackDNSQuery(ctx, message.Question[0], false)
 	if r.dnsReverseMapping != nil && response != nil && len(response.Answer) > 0 {
 		if _, isFakeIP := transport.(adapter.FakeIPTransport); !isFakeIP {
 			for _, answer := range response.Answer {
@@ -216,7 +248,38 @@ func (r *Router) Exchange(ctx context.Context, message *mDNS.Msg) (*mDNS.Msg, er
 	return response, nil
 }
 
+func (r *Router) trackDNSQuery(ctx context.Context, question mDNS.Question, blocked bool) {
+	if r.dnsQueryTracker == nil {
+		return
+	}
+	var source netip.Addr
+	if metadata := adapter.ContextFrom(ctx); metadata != nil {
+		source = metadata.Source.Addr
+	}
+	r.dnsQueryTracker.TrackDNSQuery(fqdnToDomain(question.Name), source, blocked)
+}
+
 func (r *Router) Lookup(ctx context.Context, domain string, strategy dns.DomainStrategy) ([]netip.Addr, error) {
+	trace := httptrace.ContextClientTrace(ctx)
+	if trace == nil {
+		return r.lookup(ctx, domain, strategy)
+	}
+	if trace.DNSStart != nil {
+		trace.DNSStart(httptrace.DNSStartInfo{Host: domain})
+	}
+	addresses, err := r.lookup(ctx, domain, strategy)
+	if trace.DNSDone != nil {
+		trace.DNSDone(httptrace.DNSDoneInfo{
+			Addrs: common.Map(addresses, func(it netip.Addr) net.IPAddr {
+				return net.IPAddr{IP: it.AsSlice(), Zone: it.Zone()}
+			}),
+			Err: err,
+		})
+	}
+	return addresses, err
+}
+
+func (r *Router) lookup(ctx context.Context, domain string, strategy dns.DomainStrategy) ([]netip.Addr, error) {
 	var (
 		responseAddrs []netip.Addr
 		cached        bool
@@ -236,7 +299,8 @@ func (r *Router) Lookup(ctx context.Context, domain string, strategy dns.DomainS
 			err = dns.RCodeNameError
 		}
 	}
-	responseAddrs, cached = r.dnsClient.LookupCache(ctx, domain, strategy)
+	dnsClient := r.dnsClientFor(ctx)
+	responseAddrs, cached = dnsClient.LookupCache(ctx, domain, strategy)
 	if cached {
 		if len(responseAddrs) == 0 {
 			return nil, dns.RCodeNameError
@@ -259,7 +323,9 @@ func (r *Router) Lookup(ctx context.Context, domain string, strategy dns.DomainS
 				strategy = r.defaultDomainStrategy
 			}
 		}
-		responseAddrs, err = r.dnsClient.Lookup(ctx, transport, domain, dns.QueryOptions{Strategy: strategy})
+		options := dns.QueryOptions{Strategy: strategy}
+		r.applyClientSubnet(&options, transport, metadata)
+		responseAddrs, err = dnsClient.Lookup(ctx, transport, domain, options)
 	} else {
 		var (
 			transport dns.Transport
@@ -288,13 +354,13 @@ func (r *Router) Lookup(ctx context.Context, domain string, strategy dns.DomainS
 			}
 			if rule != nil && rule.WithAddressLimit() {
 				addressLimit = true
-				responseAddrs, err = r.dnsClient.LookupWithResponseCheck(dnsCtx, transport, domain, options, func(responseAddrs []netip.Addr) bool {
+				responseAddrs, err = dnsClient.LookupWithResponseCheck(dnsCtx, transport, domain, options, func(responseAddrs []netip.Addr) bool {
 					metadata.DestinationAddresses = responseAddrs
 					return rule.MatchAddressLimit(metadata)
 				})
 			} else {
 				addressLimit = false
-				responseAddrs, err = r.dnsClient.Lookup(dnsCtx, transport, domain, options)
+				responseAddrs, err = dnsClient.Lookup(dnsCtx, transport, domain, options)
 			}
 			if !addressLimit || err == nil {
 				break
@@ -313,8 +379,25 @@ func (r *Router) LookupDefault(ctx context.Context, domain string) ([]netip.Addr
 	return r.Lookup(ctx, domain, dns.DomainStrategyAsIS)
 }
 
+// dnsClientFor returns the client with the cache for the inbound of the query.
+func (r *Router) dnsClientFor(ctx context.Context) *dns.Client {
+	if r.inboundDNSClient != nil {
+		metadata := adapter.ContextFrom(ctx)
+		if metadata != nil && r.dnsRuleInbounds[metadata.Inbound] {
+			return r.inboundDNSClient
+		}
+	}
+	return r.dnsClient
+}
+
 func (r *Router) ClearDNSCache() {
 	r.dnsClient.ClearCache()
+	if r.inboundDNSClient != nil {
+		r.inboundDNSClient.ClearCache()
+	}
+	for _, transport := range r.staleTransports {
+		transport.ClearCache()
+	}
 	if r.platformInterface != nil {
 		r.platformInterface.ClearDNSCache()
 	}
@@ -0,0 +1,67 @@
+package route
+
+import (
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+	R "github.com/sagernet/sing-box/route/rule"
+	"github.com/sagernet/sing/common/control"
+)
+
+var _ adapter.Rule = (*privateRule)(nil)
+
+// privateRule matches destinations on loopback, link-local and private
+// networks, and on the networks of any local interface. Interface networks
+// are read from the interface finder, which is refreshed by the network
+// monitor, so a changed LAN subnet is bypassed as soon as it is assigned.
+type privateRule struct {
+	interfaceFinder control.InterfaceFinder
+	action          *R.RuleActionRoute
+}
+
+func newPrivateRule(interfaceFinder control.InterfaceFinder, outbound string) *privateRule {
+	return &privateRule{
+		interfaceFinder: interfaceFinder,
+		action:          &R.RuleActionRoute{Outbound: outbound},
+	}
+}
+
+func (r *privateRule) Type() string {
+	return C.RuleTypeDefault
+}
+
+func (r *privateRule) Start() error {
+	return nil
+}
+
+func (r *privateRule) Close() error {
+	return nil
+}
+
+func (r *privateRule) UpdateGeosite() error {
+	return nil
+}
+
+func (r *privateRule) Match(metadata *adapter.InboundContext) bool {
+	// DNS queries are left to rules, so hijack-dns still applies to them
+	if !metadata.Destination.IsIP() || metadata.Destination.Port == 53 {
+		return false
+	}
+	addr := metadata.Destination.Addr.Unmap()
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsUnspecified() {
+		return true
+	}
+	_, err := r.interfaceFinder.ByAddr(addr)
+	return err == nil
+}
+
+func (r *privateRule) RuleCount() uint64 {
+	return 0
+}
+
+func (r *privateRule) Action() adapter.RuleAction {
+	return r.action
+}
+
+func (r *privateRule) String() string {
+	return "private"
+}
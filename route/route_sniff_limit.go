@@ -0,0 +1,63 @@
+package route
+
+import (
+	"sync/atomic"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing/common/buf"
+)
+
+// sniffBudget accounts the sniff buffers held by the connections of an
+// inbound, so that a flood of connections that never send anything can not
+// hold more memory than the limit while waiting for the sniff timeout.
+type sniffBudget struct {
+	limit   int64
+	used    atomic.Int64
+	skipped atomic.Uint64
+}
+
+func (r *Router) acquireSniffBuffer(metadata *adapter.InboundContext) bool {
+	limit := int64(metadata.InboundOptions.SniffBufferLimit)
+	if limit == 0 {
+		return true
+	}
+	budget := r.loadSniffBudget(metadata.Inbound, limit)
+	if budget.used.Add(buf.UDPBufferSize) > limit {
+		budget.used.Add(-buf.UDPBufferSize)
+		budget.skipped.Add(1)
+		return false
+	}
+	return true
+}
+
+func (r *Router) releaseSniffBuffer(metadata *adapter.InboundContext) {
+	if metadata.InboundOptions.SniffBufferLimit == 0 {
+		return
+	}
+	r.loadSniffBudget(metadata.Inbound, int64(metadata.InboundOptions.SniffBufferLimit)).used.Add(-buf.UDPBufferSize)
+}
+
+func (r *Router) loadSniffBudget(inbound string, limit int64) *sniffBudget {
+	r.sniffBudgetAccess.Lock()
+	defer r.sniffBudgetAccess.Unlock()
+	budget, loaded := r.sniffBudgets[inbound]
+	if !loaded {
+		budget = &sniffBudget{limit: limit}
+		r.sniffBudgets[inbound] = budget
+	}
+	return budget
+}
+
+func (r *Router) SniffBufferUsage() map[string]adapter.SniffBufferUsage {
+	r.sniffBudgetAccess.Lock()
+	defer r.sniffBudgetAccess.Unlock()
+	usage := make(map[string]adapter.SniffBufferUsage, len(r.sniffBudgets))
+	for inbound, budget := range r.sniffBudgets {
+		usage[inbound] = adapter.SniffBufferUsage{
+			Used:    budget.used.Load(),
+			Limit:   budget.limit,
+			Skipped: budget.skipped.Load(),
+		}
+	}
+	return usage
+}
@@ -7,9 +7,11 @@ import (
 	"os"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/autoupdate"
 	"github.com/sagernet/sing-box/common/dialer"
 	"github.com/sagernet/sing-box/common/geoip"
 	"github.com/sagernet/sing-box/common/geosite"
@@ -20,9 +22,12 @@ import (
 	"github.com/sagernet/sing-box/log"
 	"github.com/sagernet/sing-box/option"
 	R "github.com/sagernet/sing-box/route/rule"
+	"github.com/sagernet/sing-box/transport/dnshttps"
+	"github.com/sagernet/sing-box/transport/dnsstream"
 	"github.com/sagernet/sing-box/transport/fakeip"
 	dns "github.com/sagernet/sing-dns"
 	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/atomic"
 	E "github.com/sagernet/sing/common/exceptions"
 	F "github.com/sagernet/sing/common/format"
 	M "github.com/sagernet/sing/common/metadata"
@@ -43,34 +48,51 @@ type Router struct {
 	connection              adapter.ConnectionManager
 	network                 adapter.NetworkManager
 	rules                   []adapter.Rule
+	routeContexts           []*routeContext
+	routeContextByInbound   map[string]*routeContext
+	privateRule             *privateRule
+	sniffBudgetAccess       sync.Mutex
+	sniffBudgets            map[string]*sniffBudget
 	needGeoIPDatabase       bool
 	needGeositeDatabase     bool
 	geoIPOptions            option.GeoIPOptions
 	geositeOptions          option.GeositeOptions
-	geoIPReader             *geoip.Reader
+	geoIPReader             atomic.TypedValue[*geoip.Reader]
+	geoIPUpdater            *autoupdate.Updater
+	geositeUpdater          *autoupdate.Updater
+	geositeAccess           sync.Mutex
 	geositeReader           *geosite.Reader
 	geositeCache            map[string]adapter.Rule
 	needFindProcess         bool
 	dnsClient               *dns.Client
+	inboundDNSClient        *dns.Client
+	dnsRuleInbounds         map[string]bool
 	defaultDomainStrategy   dns.DomainStrategy
 	dnsRules                []adapter.DNSRule
+	ruleGroups              []*ruleGroup
+	ruleGroupMap            map[string]*ruleGroup
+	modeFinal               atomic.TypedValue[adapter.Outbound]
 	ruleSets                []adapter.RuleSet
 	ruleSetMap              map[string]adapter.RuleSet
 	defaultTransport        dns.Transport
 	transports              []dns.Transport
 	transportMap            map[string]dns.Transport
 	transportDomainStrategy map[dns.Transport]dns.DomainStrategy
+	transportClientSubnet   map[dns.Transport]option.DNSClientSubnet
+	staleTransports         []*staleTransport
 	dnsReverseMapping       *DNSReverseMapping
 	fakeIPStore             adapter.FakeIPStore
 	processSearcher         process.Searcher
 	pauseManager            pause.Manager
-	tracker                 adapter.ConnectionTracker
+	trackers                []adapter.ConnectionTracker
+	dnsQueryTracker         adapter.DNSQueryTracker
 	platformInterface       platform.Interface
 	needWIFIState           bool
 	started                 bool
 }
 
 func NewRouter(ctx context.Context, logFactory log.Factory, options option.RouteOptions, dnsOptions option.DNSOptions) (*Router, error) {
+	routeRules := routeContextRules(options)
 	router := &Router{
 		ctx:                   ctx,
 		logger:                logFactory.NewLogger("router"),
@@ -81,20 +103,22 @@ func NewRouter(ctx context.Context, logFactory log.Factory, options option.Route
 		network:               service.FromContext[adapter.NetworkManager](ctx),
 		rules:                 make([]adapter.Rule, 0, len(options.Rules)),
 		dnsRules:              make([]adapter.DNSRule, 0, len(dnsOptions.Rules)),
+		ruleGroupMap:          make(map[string]*ruleGroup),
 		ruleSetMap:            make(map[string]adapter.RuleSet),
-		needGeoIPDatabase:     hasRule(options.Rules, isGeoIPRule) || hasDNSRule(dnsOptions.Rules, isGeoIPDNSRule),
-		needGeositeDatabase:   hasRule(options.Rules, isGeositeRule) || hasDNSRule(dnsOptions.Rules, isGeositeDNSRule),
+		needGeoIPDatabase:     hasRule(routeRules, isGeoIPRule) || hasDNSRule(dnsOptions.Rules, isGeoIPDNSRule),
+		needGeositeDatabase:   hasRule(routeRules, isGeositeRule) || hasDNSRule(dnsOptions.Rules, isGeositeDNSRule),
 		geoIPOptions:          common.PtrValueOrDefault(options.GeoIP),
 		geositeOptions:        common.PtrValueOrDefault(options.Geosite),
 		geositeCache:          make(map[string]adapter.Rule),
-		needFindProcess:       hasRule(options.Rules, isProcessRule) || hasDNSRule(dnsOptions.Rules, isProcessDNSRule) || options.FindProcess,
+		sniffBudgets:          make(map[string]*sniffBudget),
+		needFindProcess:       hasRule(routeRules, isProcessRule) || hasDNSRule(dnsOptions.Rules, isProcessDNSRule) || options.FindProcess,
 		defaultDomainStrategy: dns.DomainStrategy(dnsOptions.Strategy),
 		pauseManager:          service.FromContext[pause.Manager](ctx),
 		platformInterface:     service.FromContext[platform.Interface](ctx),
-		needWIFIState:         hasRule(options.Rules, isWIFIRule) || hasDNSRule(dnsOptions.Rules, isWIFIDNSRule),
+		needWIFIState:         hasRule(routeRules, isWIFIRule) || hasDNSRule(dnsOptions.Rules, isWIFIDNSRule),
 	}
 	service.MustRegister[adapter.Router](ctx, router)
-	router.dnsClient = dns.NewClient(dns.ClientOptions{
+	dnsClientOptions := dns.ClientOptions{
 		DisableCache:     dnsOptions.DNSClientOptions.DisableCache,
 		DisableExpire:    dnsOptions.DNSClientOptions.DisableExpire,
 		IndependentCache: dnsOptions.DNSClientOptions.IndependentCache,
@@ -110,20 +134,38 @@ func NewRouter(ctx context.Context, logFactory log.Factory, options option.Route
 			return cacheFile
 		},
 		Logger: router.dnsLogger,
-	})
+	}
+	router.dnsClient = dns.NewClient(dnsClientOptions)
+	if !dnsClientOptions.IndependentCache && !dnsClientOptions.DisableCache {
+		// Responses to queries from inbounds used in DNS rules may be resolved by other servers,
+		// so they are cached per server, and other queries keep the shared cache.
+		router.dnsRuleInbounds = make(map[string]bool)
+		collectDNSRuleInbounds(dnsOptions.Rules, router.dnsRuleInbounds)
+		if len(router.dnsRuleInbounds) > 0 {
+			dnsClientOptions.IndependentCache = true
+			router.inboundDNSClient = dns.NewClient(dnsClientOptions)
+		}
+	}
 	for i, ruleOptions := range options.Rules {
 		routeRule, err := R.NewRule(ctx, router.logger, ruleOptions, true)
 		if err != nil {
 			return nil, E.Cause(err, "parse rule[", i, "]")
 		}
-		router.rules = append(router.rules, routeRule)
+		router.rules = append(router.rules, router.newGroupRule(ruleOptions.Group(), routeRule))
+	}
+	err := router.initializeRouteContexts(options.Contexts)
+	if err != nil {
+		return nil, err
+	}
+	if options.BypassPrivate != "" {
+		router.privateRule = newPrivateRule(router.network.InterfaceFinder(), options.BypassPrivate)
 	}
 	for i, dnsRuleOptions := range dnsOptions.Rules {
 		dnsRule, err := R.NewDNSRule(ctx, router.logger, dnsRuleOptions, true)
 		if err != nil {
 			return nil, E.Cause(err, "parse dns rule[", i, "]")
 		}
-		router.dnsRules = append(router.dnsRules, dnsRule)
+		router.dnsRules = append(router.dnsRules, router.newGroupDNSRule(dnsRuleOptions.Group(), dnsRule))
 	}
 	for i, ruleSetOptions := range options.RuleSet {
 		if _, exists := router.ruleSetMap[ruleSetOptions.Tag]; exists {
@@ -143,6 +185,7 @@ func NewRouter(ctx context.Context, logFactory log.Factory, options option.Route
 	transportTags := make([]string, len(dnsOptions.Servers))
 	transportTagMap := make(map[string]bool)
 	transportDomainStrategy := make(map[dns.Transport]dns.DomainStrategy)
+	transportClientSubnet := make(map[dns.Transport]option.DNSClientSubnet)
 	for i, server := range dnsOptions.Servers {
 		var tag string
 		if server.Tag != "" {
@@ -164,6 +207,25 @@ func NewRouter(ctx context.Context, logFactory log.Factory, options option.Route
 			if _, exists := dummyTransportMap[tag]; exists {
 				continue
 			}
+			if server.Group != nil {
+				transport, err := router.newGroupTransport(logFactory, tag, server, transportTagMap, dummyTransportMap)
+				if err != nil {
+					return nil, E.Cause(err, "parse dns server[", tag, "]")
+				}
+				if transport == nil {
+					continue
+				}
+				transports[i] = transport
+				dummyTransportMap[tag] = transport
+				if server.Tag != "" {
+					transportMap[server.Tag] = transport
+				}
+				strategy := dns.DomainStrategy(server.Strategy)
+				if strategy != dns.DomainStrategyAsIS {
+					transportDomainStrategy[transport] = strategy
+				}
+				continue
+			}
 			var detour N.Dialer
 			if server.Detour == "" {
 				detour = dialer.NewDefaultOutbound(outboundManager)
@@ -202,26 +264,37 @@ func NewRouter(ctx context.Context, logFactory log.Factory, options option.Route
 					return nil, E.New("parse dns server[", tag, "]: missing address_resolver")
 				}
 			}
-			var clientSubnet netip.Prefix
+			var clientSubnet option.DNSClientSubnet
 			if server.ClientSubnet != nil {
-				clientSubnet = netip.Prefix(common.PtrValueOrDefault(server.ClientSubnet))
+				clientSubnet = *server.ClientSubnet
 			} else if dnsOptions.ClientSubnet != nil {
-				clientSubnet = netip.Prefix(common.PtrValueOrDefault(dnsOptions.ClientSubnet))
+				clientSubnet = *dnsOptions.ClientSubnet
 			}
 			if serverProtocol == "" {
 				serverProtocol = "transport"
 			}
-			transport, err := dns.CreateTransport(dns.TransportOptions{
-				Context:      ctx,
-				Logger:       logFactory.NewLogger(F.ToString("dns/", serverProtocol, "[", tag, "]")),
-				Name:         tag,
-				Dialer:       detour,
-				Address:      server.Address,
-				ClientSubnet: clientSubnet,
-			})
+			transport, err := createDNSTransport(dns.TransportOptions{
+				Context: ctx,
+				Logger:  logFactory.NewLogger(F.ToString("dns/", serverProtocol, "[", tag, "]")),
+				Name:    tag,
+				Dialer:  detour,
+				Address: server.Address,
+			}, serverProtocol, server.Pipeline)
 			if err != nil {
 				return nil, E.Cause(err, "parse dns server[", tag, "]")
 			}
+			if dnsOptions.ServeStale > 0 && !dnsOptions.DisableCache && transport.Raw() {
+				staleTransport := newStaleTransport(transport, router.dnsLogger, time.Duration(dnsOptions.ServeStale), dnsOptions.CacheCapacity)
+				router.staleTransports = append(router.staleTransports, staleTransport)
+				transport = staleTransport
+			}
+			if _, isFakeIP := transport.(adapter.FakeIPTransport); !isFakeIP {
+				if clientSubnet.Auto {
+					transportClientSubnet[transport] = clientSubnet
+				} else if clientSubnet.Prefix.IsValid() {
+					transport = &clientSubnetTransport{transport, clientSubnet.Build(netip.Addr{})}
+				}
+			}
 			transports[i] = transport
 			dummyTransportMap[tag] = transport
 			if server.Tag != "" {
@@ -274,6 +347,7 @@ func NewRouter(ctx context.Context, logFactory log.Factory, options option.Route
 	router.transports = transports
 	router.transportMap = transportMap
 	router.transportDomainStrategy = transportDomainStrategy
+	router.transportClientSubnet = transportClientSubnet
 
 	if dnsOptions.ReverseMapping {
 		router.dnsReverseMapping = NewDNSReverseMapping()
@@ -293,10 +367,42 @@ func NewRouter(ctx context.Context, logFactory log.Factory, options option.Route
 	return router, nil
 }
 
+// createDNSTransport creates the transport of a DNS server. The sing-dns TCP
+// and TLS transports are replaced with the pipelining one when pipeline is
+// enabled. The sing-dns DNS-over-HTTPS transport always verifies against the
+// system roots, so it is replaced with the built-in one when a certificate
+// store is configured.
+func createDNSTransport(options dns.TransportOptions, serverProtocol string, pipeline bool) (dns.Transport, error) {
+	rootPool := adapter.RootPoolFromContext(options.Context)
+	switch serverProtocol {
+	case "tcp":
+		if pipeline {
+			return dnsstream.NewTransport(options, false)
+		}
+	case "tls":
+		if pipeline {
+			return dnsstream.NewTransport(options, true)
+		}
+		if rootPool != nil {
+			options.Logger.Warn("certificate store is not applied to DNS over tls without pipeline")
+		}
+	case "https":
+		if rootPool != nil {
+			return dnshttps.NewTransport(options), nil
+		}
+	case "quic", "h3":
+		if rootPool != nil {
+			options.Logger.Warn("certificate store is not applied to DNS over ", serverProtocol)
+		}
+	}
+	return dns.CreateTransport(options)
+}
+
 func (r *Router) Start(stage adapter.StartStage) error {
 	monitor := taskmonitor.New(r.logger, C.StartTimeout)
 	switch stage {
 	case adapter.StartStateInitialize:
+		r.initializeRuleGroups()
 		if r.fakeIPStore != nil {
 			monitor.Start("initialize fakeip store")
 			err := r.fakeIPStore.Start()
@@ -306,6 +412,19 @@ func (r *Router) Start(stage adapter.StartStage) error {
 			}
 		}
 	case adapter.StartStateStart:
+		for _, currentContext := range r.routeContexts {
+			if currentContext.final == "" {
+				continue
+			}
+			if _, loaded := r.outbound.Outbound(currentContext.final); !loaded {
+				return E.New("final outbound of route context ", currentContext.name, " not found: ", currentContext.final)
+			}
+		}
+		if r.privateRule != nil {
+			if _, loaded := r.outbound.Outbound(r.privateRule.action.Outbound); !loaded {
+				return E.New("bypass private outbound not found: ", r.privateRule.action.Outbound)
+			}
+		}
 		if r.needGeoIPDatabase {
 			monitor.Start("initialize geoip database")
 			err := r.prepareGeoIPDatabase()
@@ -322,8 +441,18 @@ func (r *Router) Start(stage adapter.StartStage) error {
 				return err
 			}
 		}
+		for _, updater := range []*autoupdate.Updater{r.geoIPUpdater, r.geositeUpdater} {
+			if updater == nil {
+				continue
+			}
+			err := updater.Start()
+			if err != nil {
+				return err
+			}
+		}
 		if r.needGeositeDatabase {
-			for _, rule := range r.rules {
+			r.geositeAccess.Lock()
+			for _, rule := range r.allRules() {
 				err := rule.UpdateGeosite()
 				if err != nil {
 					r.logger.Error("failed to initialize geosite: ", err)
@@ -336,15 +465,19 @@ func (r *Router) Start(stage adapter.StartStage) error {
 				}
 			}
 			err := common.Close(r.geositeReader)
+			r.geositeCache = nil
+			r.geositeReader = nil
+			r.geositeAccess.Unlock()
 			if err != nil {
 				return err
 			}
-			r.geositeCache = nil
-			r.geositeReader = nil
 		}
 
 		monitor.Start("initialize DNS client")
 		r.dnsClient.Start()
+		if r.inboundDNSClient != nil {
+			r.inboundDNSClient.Start()
+		}
 		monitor.Finish()
 
 		for i, rule := range r.dnsRules {
@@ -366,7 +499,7 @@ func (r *Router) Start(stage adapter.StartStage) error {
 		var cacheContext *adapter.HTTPStartContext
 		if len(r.ruleSets) > 0 {
 			monitor.Start("initialize rule-set")
-			cacheContext = adapter.NewHTTPStartContext()
+			cacheContext = adapter.NewHTTPStartContext(r.ctx)
 			var ruleSetStartGroup task.Group
 			for i, ruleSet := range r.ruleSets {
 				ruleSetInPlace := ruleSet
@@ -419,7 +552,7 @@ func (r *Router) Start(stage adapter.StartStage) error {
 			}
 		}
 	case adapter.StartStatePostStart:
-		for i, rule := range r.rules {
+		for i, rule := range r.allRules() {
 			monitor.Start("initialize rule[", i, "]")
 			err := rule.Start()
 			monitor.Finish()
@@ -435,6 +568,12 @@ func (r *Router) Start(stage adapter.StartStage) error {
 				return E.Cause(err, "post start rule_set[", ruleSet.Name(), "]")
 			}
 		}
+		if r.geoIPUpdater != nil {
+			r.geoIPUpdater.PostStart()
+		}
+		if r.geositeUpdater != nil {
+			r.geositeUpdater.PostStart()
+		}
 		r.started = true
 		return nil
 	case adapter.StartStateStarted:
@@ -449,7 +588,7 @@ func (r *Router) Start(stage adapter.StartStage) error {
 func (r *Router) Close() error {
 	monitor := taskmonitor.New(r.logger, C.StopTimeout)
 	var err error
-	for i, rule := range r.rules {
+	for i, rule := range r.allRules() {
 		monitor.Start("close rule[", i, "]")
 		err = E.Append(err, rule.Close(), func(err error) error {
 			return E.Cause(err, "close rule[", i, "]")
@@ -470,9 +609,14 @@ func (r *Router) Close() error {
 		})
 		monitor.Finish()
 	}
-	if r.geoIPReader != nil {
+	for _, updater := range []*autoupdate.Updater{r.geoIPUpdater, r.geositeUpdater} {
+		if updater != nil {
+			updater.Close()
+		}
+	}
+	if geoIPReader := r.geoIPReader.Load(); geoIPReader != nil {
 		monitor.Start("close geoip reader")
-		err = E.Append(err, r.geoIPReader.Close(), func(err error) error {
+		err = E.Append(err, geoIPReader.Close(), func(err error) error {
 			return E.Cause(err, "close geoip reader")
 		})
 		monitor.Finish()
@@ -516,8 +660,12 @@ func (r *Router) DefaultDNSServer() string {
 	return r.defaultTransport.Name()
 }
 
-func (r *Router) SetTracker(tracker adapter.ConnectionTracker) {
-	r.tracker = tracker
+func (r *Router) AppendTracker(tracker adapter.ConnectionTracker) {
+	r.trackers = append(r.trackers, tracker)
+}
+
+func (r *Router) SetDNSQueryTracker(tracker adapter.DNSQueryTracker) {
+	r.dnsQueryTracker = tracker
 }
 
 func (r *Router) ResetNetwork() {
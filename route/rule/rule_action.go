@@ -2,7 +2,6 @@ package rule
 
 import (
 	"context"
-	"net/netip"
 	"strings"
 	"sync"
 	"syscall"
@@ -21,6 +20,8 @@ import (
 	"github.com/sagernet/sing/common/logger"
 	M "github.com/sagernet/sing/common/metadata"
 	N "github.com/sagernet/sing/common/network"
+
+	mDNS "github.com/miekg/dns"
 )
 
 func NewRuleAction(ctx context.Context, logger logger.ContextLogger, action option.RuleAction) (adapter.RuleAction, error) {
@@ -28,6 +29,10 @@ func NewRuleAction(ctx context.Context, logger logger.ContextLogger, action opti
 	case "":
 		return nil, nil
 	case C.RuleActionTypeRoute:
+		err := validateTrafficClass(action.RouteOptions.DSCP, action.RouteOptions.ECN)
+		if err != nil {
+			return nil, err
+		}
 		return &RuleActionRoute{
 			Outbound: action.RouteOptions.Outbound,
 			RuleActionRouteOptions: RuleActionRouteOptions{
@@ -37,9 +42,15 @@ func NewRuleAction(ctx context.Context, logger logger.ContextLogger, action opti
 				FallbackDelay:             time.Duration(action.RouteOptions.FallbackDelay),
 				UDPDisableDomainUnmapping: action.RouteOptions.UDPDisableDomainUnmapping,
 				UDPConnect:                action.RouteOptions.UDPConnect,
+				DSCP:                      action.RouteOptions.DSCP,
+				ECN:                       action.RouteOptions.ECN,
 			},
 		}, nil
 	case C.RuleActionTypeRouteOptions:
+		err := validateTrafficClass(action.RouteOptionsOptions.DSCP, action.RouteOptionsOptions.ECN)
+		if err != nil {
+			return nil, err
+		}
 		return &RuleActionRouteOptions{
 			OverrideAddress:           M.ParseSocksaddrHostPort(action.RouteOptionsOptions.OverrideAddress, 0),
 			OverridePort:              action.RouteOptionsOptions.OverridePort,
@@ -48,6 +59,8 @@ func NewRuleAction(ctx context.Context, logger logger.ContextLogger, action opti
 			UDPDisableDomainUnmapping: action.RouteOptionsOptions.UDPDisableDomainUnmapping,
 			UDPConnect:                action.RouteOptionsOptions.UDPConnect,
 			UDPTimeout:                time.Duration(action.RouteOptionsOptions.UDPTimeout),
+			DSCP:                      action.RouteOptionsOptions.DSCP,
+			ECN:                       action.RouteOptionsOptions.ECN,
 		}, nil
 	case C.RuleActionTypeDirect:
 		directDialer, err := dialer.New(ctx, option.DialerOptions(action.DirectOptions))
@@ -71,12 +84,18 @@ func NewRuleAction(ctx context.Context, logger logger.ContextLogger, action opti
 		}, nil
 	case C.RuleActionTypeReject:
 		return &RuleActionReject{
-			Method: action.RejectOptions.Method,
-			NoDrop: action.RejectOptions.NoDrop,
-			logger: logger,
+			Method:    action.RejectOptions.Method,
+			TCPMethod: action.RejectOptions.NetworkMethod(N.NetworkTCP),
+			UDPMethod: action.RejectOptions.NetworkMethod(N.NetworkUDP),
+			NoDrop:    action.RejectOptions.NoDrop,
+			logger:    logger,
 		}, nil
 	case C.RuleActionTypeHijackDNS:
-		return &RuleActionHijackDNS{}, nil
+		hijackAction := &RuleActionHijackDNS{
+			rcodeName:       action.HijackDNSOptions.Rcode,
+			answerTemplates: action.HijackDNSOptions.Answer,
+		}
+		return hijackAction, hijackAction.build()
 	case C.RuleActionTypeSniff:
 		sniffAction := &RuleActionSniff{
 			snifferNames: action.SniffOptions.Sniffer,
@@ -85,8 +104,9 @@ func NewRuleAction(ctx context.Context, logger logger.ContextLogger, action opti
 		return sniffAction, sniffAction.build()
 	case C.RuleActionTypeResolve:
 		return &RuleActionResolve{
-			Strategy: dns.DomainStrategy(action.ResolveOptions.Strategy),
-			Server:   action.ResolveOptions.Server,
+			Strategy:       dns.DomainStrategy(action.ResolveOptions.Strategy),
+			Server:         action.ResolveOptions.Server,
+			SkipIfResolved: action.ResolveOptions.SkipIfResolved,
 		}, nil
 	default:
 		panic(F.ToString("unknown rule action: ", action.Action))
@@ -103,14 +123,14 @@ func NewDNSRuleAction(logger logger.ContextLogger, action option.DNSRuleAction)
 			RuleActionDNSRouteOptions: RuleActionDNSRouteOptions{
 				DisableCache: action.RouteOptions.DisableCache,
 				RewriteTTL:   action.RouteOptions.RewriteTTL,
-				ClientSubnet: netip.Prefix(common.PtrValueOrDefault(action.RouteOptions.ClientSubnet)),
+				ClientSubnet: common.PtrValueOrDefault(action.RouteOptions.ClientSubnet),
 			},
 		}
 	case C.RuleActionTypeRouteOptions:
 		return &RuleActionDNSRouteOptions{
 			DisableCache: action.RouteOptionsOptions.DisableCache,
 			RewriteTTL:   action.RouteOptionsOptions.RewriteTTL,
-			ClientSubnet: netip.Prefix(common.PtrValueOrDefault(action.RouteOptionsOptions.ClientSubnet)),
+			ClientSubnet: common.PtrValueOrDefault(action.RouteOptionsOptions.ClientSubnet),
 		}
 	case C.RuleActionTypeReject:
 		return &RuleActionReject{
@@ -141,6 +161,12 @@ func (r *RuleActionRoute) String() string {
 	if r.UDPConnect {
 		descriptions = append(descriptions, "udp-connect")
 	}
+	if r.DSCP != nil {
+		descriptions = append(descriptions, F.ToString("dscp=", *r.DSCP))
+	}
+	if r.ECN != nil {
+		descriptions = append(descriptions, F.ToString("ecn=", *r.ECN))
+	}
 	return F.ToString("route(", strings.Join(descriptions, ","), ")")
 }
 
@@ -154,6 +180,8 @@ type RuleActionRouteOptions struct {
 	UDPDisableDomainUnmapping bool
 	UDPConnect                bool
 	UDPTimeout                time.Duration
+	DSCP                      *uint8
+	ECN                       *uint8
 }
 
 func (r *RuleActionRouteOptions) Type() string {
@@ -168,9 +196,25 @@ func (r *RuleActionRouteOptions) String() string {
 	if r.UDPConnect {
 		descriptions = append(descriptions, "udp-connect")
 	}
+	if r.DSCP != nil {
+		descriptions = append(descriptions, F.ToString("dscp=", *r.DSCP))
+	}
+	if r.ECN != nil {
+		descriptions = append(descriptions, F.ToString("ecn=", *r.ECN))
+	}
 	return F.ToString("route-options(", strings.Join(descriptions, ","), ")")
 }
 
+func validateTrafficClass(dscp *uint8, ecn *uint8) error {
+	if dscp != nil && *dscp > 63 {
+		return E.New("invalid dscp: ", *dscp)
+	}
+	if ecn != nil && *ecn > 3 {
+		return E.New("invalid ecn: ", *ecn)
+	}
+	return nil
+}
+
 type RuleActionDNSRoute struct {
 	Server string
 	RuleActionDNSRouteOptions
@@ -198,7 +242,7 @@ func (r *RuleActionDNSRoute) String() string {
 type RuleActionDNSRouteOptions struct {
 	DisableCache bool
 	RewriteTTL   *uint32
-	ClientSubnet netip.Prefix
+	ClientSubnet option.DNSClientSubnet
 }
 
 func (r *RuleActionDNSRouteOptions) Type() string {
@@ -234,6 +278,8 @@ func (r *RuleActionDirect) String() string {
 
 type RuleActionReject struct {
 	Method      string
+	TCPMethod   string
+	UDPMethod   string
 	NoDrop      bool
 	logger      logger.ContextLogger
 	dropAccess  sync.Mutex
@@ -245,21 +291,43 @@ func (r *RuleActionReject) Type() string {
 }
 
 func (r *RuleActionReject) String() string {
-	if r.Method == C.RuleActionRejectMethodDefault {
+	var descriptions []string
+	if r.Method != C.RuleActionRejectMethodDefault {
+		descriptions = append(descriptions, r.Method)
+	}
+	// network methods are empty for DNS rules
+	defaultMethods := option.RejectActionOptions{Method: r.Method}
+	if r.TCPMethod != "" && r.TCPMethod != defaultMethods.NetworkMethod(N.NetworkTCP) {
+		descriptions = append(descriptions, "tcp="+r.TCPMethod)
+	}
+	if r.UDPMethod != "" && r.UDPMethod != defaultMethods.NetworkMethod(N.NetworkUDP) {
+		descriptions = append(descriptions, "udp="+r.UDPMethod)
+	}
+	if len(descriptions) == 0 {
 		return "reject"
 	}
-	return F.ToString("reject(", r.Method, ")")
+	return "reject(" + strings.Join(descriptions, ",") + ")"
 }
 
-func (r *RuleActionReject) Error(ctx context.Context) error {
+// NetworkMethod returns the reject method for connections of network.
+func (r *RuleActionReject) NetworkMethod(network string) string {
+	if network == N.NetworkTCP {
+		return r.TCPMethod
+	}
+	return r.UDPMethod
+}
+
+func (r *RuleActionReject) Error(ctx context.Context, network string) error {
 	var returnErr error
-	switch r.Method {
-	case C.RuleActionRejectMethodDefault:
+	switch r.NetworkMethod(network) {
+	case C.RuleActionRejectMethodReset, C.RuleActionRejectMethodUnreachable, C.RuleActionRejectMethodHTTP:
+		// the tun stack replies to any error other than ErrDrop with a TCP reset
+		// or ICMP port unreachable, the HTTP response is written by the router.
 		returnErr = syscall.ECONNREFUSED
 	case C.RuleActionRejectMethodDrop:
 		return tun.ErrDrop
 	default:
-		panic(F.ToString("unknown reject method: ", r.Method))
+		panic(F.ToString("unknown reject method: ", r.NetworkMethod(network)))
 	}
 	r.dropAccess.Lock()
 	defer r.dropAccess.Unlock()
@@ -277,14 +345,76 @@ func (r *RuleActionReject) Error(ctx context.Context) error {
 	return returnErr
 }
 
-type RuleActionHijackDNS struct{}
+type RuleActionHijackDNS struct {
+	rcodeName       string
+	answerTemplates []string
+	predefined      bool
+	rcode           int
+	answer          []mDNS.RR
+}
 
 func (r *RuleActionHijackDNS) Type() string {
 	return C.RuleActionTypeHijackDNS
 }
 
 func (r *RuleActionHijackDNS) String() string {
-	return "hijack-dns"
+	if !r.predefined {
+		return "hijack-dns"
+	}
+	var descriptions []string
+	if r.rcodeName != "" {
+		descriptions = append(descriptions, "rcode="+mDNS.RcodeToString[r.rcode])
+	}
+	for _, template := range r.answerTemplates {
+		descriptions = append(descriptions, "answer="+template)
+	}
+	return F.ToString("hijack-dns(", strings.Join(descriptions, ","), ")")
+}
+
+func (r *RuleActionHijackDNS) build() error {
+	if r.rcodeName != "" {
+		rcode, loaded := mDNS.StringToRcode[strings.ToUpper(r.rcodeName)]
+		if !loaded {
+			return E.New("unknown DNS rcode: ", r.rcodeName)
+		}
+		r.rcode = rcode
+		r.predefined = true
+	}
+	for _, template := range r.answerTemplates {
+		// templates omit the owner name, which is replaced by the question name in responses
+		record, err := mDNS.NewRR(". " + template)
+		if err != nil {
+			return E.Cause(err, "parse answer template: ", template)
+		} else if record == nil {
+			return E.New("empty answer template")
+		}
+		r.answer = append(r.answer, record)
+		r.predefined = true
+	}
+	return nil
+}
+
+// Predefined reports whether requests are answered with the configured
+// response instead of being handled by the DNS module.
+func (r *RuleActionHijackDNS) Predefined() bool {
+	return r.predefined
+}
+
+func (r *RuleActionHijackDNS) Response(request *mDNS.Msg) *mDNS.Msg {
+	response := new(mDNS.Msg)
+	response.SetRcode(request, r.rcode)
+	response.RecursionAvailable = true
+	for _, question := range response.Question {
+		for _, template := range r.answer {
+			if template.Header().Rrtype != question.Qtype {
+				continue
+			}
+			record := mDNS.Copy(template)
+			record.Header().Name = question.Name
+			response.Answer = append(response.Answer, record)
+		}
+	}
+	return response
 }
 
 type RuleActionSniff struct {
@@ -324,6 +454,20 @@ func (r *RuleActionSniff) build() error {
 			r.StreamSniffers = append(r.StreamSniffers, sniff.SSH)
 		case C.ProtocolRDP:
 			r.StreamSniffers = append(r.StreamSniffers, sniff.RDP)
+		case C.ProtocolNTP:
+			r.PacketSniffers = append(r.PacketSniffers, sniff.NTP)
+		case C.ProtocolSyslog:
+			r.PacketSniffers = append(r.PacketSniffers, sniff.Syslog)
+		case C.ProtocolSIP:
+			r.PacketSniffers = append(r.PacketSniffers, sniff.SIP)
+		case C.ProtocolRTP:
+			r.PacketSniffers = append(r.PacketSniffers, sniff.RTP)
+		case C.ProtocolMinecraft:
+			r.StreamSniffers = append(r.StreamSniffers, sniff.Minecraft)
+		case C.ProtocolRakNet:
+			r.PacketSniffers = append(r.PacketSniffers, sniff.RakNet)
+		case C.ProtocolA2S:
+			r.PacketSniffers = append(r.PacketSniffers, sniff.A2S)
 		default:
 			return E.New("unknown sniffer: ", name)
 		}
@@ -344,8 +488,9 @@ func (r *RuleActionSniff) String() string {
 }
 
 type RuleActionResolve struct {
-	Strategy dns.DomainStrategy
-	Server   string
+	Strategy       dns.DomainStrategy
+	Server         string
+	SkipIfResolved bool
 }
 
 func (r *RuleActionResolve) Type() string {
@@ -353,13 +498,18 @@ func (r *RuleActionResolve) Type() string {
 }
 
 func (r *RuleActionResolve) String() string {
-	if r.Strategy == dns.DomainStrategyAsIS && r.Server == "" {
-		return F.ToString("resolve")
-	} else if r.Strategy != dns.DomainStrategyAsIS && r.Server == "" {
-		return F.ToString("resolve(", option.DomainStrategy(r.Strategy).String(), ")")
-	} else if r.Strategy == dns.DomainStrategyAsIS && r.Server != "" {
-		return F.ToString("resolve(", r.Server, ")")
-	} else {
-		return F.ToString("resolve(", option.DomainStrategy(r.Strategy).String(), ",", r.Server, ")")
+	var descriptions []string
+	if r.Strategy != dns.DomainStrategyAsIS {
+		descriptions = append(descriptions, option.DomainStrategy(r.Strategy).String())
+	}
+	if r.Server != "" {
+		descriptions = append(descriptions, r.Server)
+	}
+	if r.SkipIfResolved {
+		descriptions = append(descriptions, "skip-if-resolved")
+	}
+	if len(descriptions) == 0 {
+		return "resolve"
 	}
+	return F.ToString("resolve(", strings.Join(descriptions, ","), ")")
 }
@@ -2,6 +2,7 @@ package rule
 
 import (
 	"strings"
+	"sync/atomic"
 
 	"github.com/sagernet/sing-box/adapter"
 	"github.com/sagernet/sing-box/log"
@@ -14,7 +15,7 @@ type GeositeItem struct {
 	router   adapter.Router
 	logger   log.ContextLogger
 	codes    []string
-	matchers []adapter.Rule
+	matchers atomic.Pointer[[]adapter.Rule]
 }
 
 func NewGeositeItem(router adapter.Router, logger log.ContextLogger, codes []string) *GeositeItem {
@@ -34,12 +35,16 @@ func (r *GeositeItem) Update() error {
 		}
 		matchers = append(matchers, matcher)
 	}
-	r.matchers = matchers
+	r.matchers.Store(&matchers)
 	return nil
 }
 
 func (r *GeositeItem) Match(metadata *adapter.InboundContext) bool {
-	for _, matcher := range r.matchers {
+	matchers := r.matchers.Load()
+	if matchers == nil {
+		return false
+	}
+	for _, matcher := range *matchers {
 		if matcher.Match(metadata) {
 			return true
 		}
@@ -0,0 +1,75 @@
+package rule
+
+import (
+	"strings"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing/common"
+)
+
+var _ RuleItem = (*HTTPPathItem)(nil)
+
+type HTTPPathItem struct {
+	prefixes []string
+}
+
+func NewHTTPPathItem(prefixes []string) *HTTPPathItem {
+	return &HTTPPathItem{prefixes}
+}
+
+func (r *HTTPPathItem) Match(metadata *adapter.InboundContext) bool {
+	if metadata.HTTPMethod == "" {
+		return false
+	}
+	for _, prefix := range r.prefixes {
+		if strings.HasPrefix(metadata.HTTPPath, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *HTTPPathItem) String() string {
+	pLen := len(r.prefixes)
+	if pLen == 1 {
+		return "http_path=" + r.prefixes[0]
+	} else if pLen > 3 {
+		return "http_path=[" + strings.Join(r.prefixes[:3], " ") + "...]"
+	} else {
+		return "http_path=[" + strings.Join(r.prefixes, " ") + "]"
+	}
+}
+
+var _ RuleItem = (*HTTPUserAgentItem)(nil)
+
+type HTTPUserAgentItem struct {
+	keywords []string
+}
+
+func NewHTTPUserAgentItem(keywords []string) *HTTPUserAgentItem {
+	return &HTTPUserAgentItem{common.Map(keywords, strings.ToLower)}
+}
+
+func (r *HTTPUserAgentItem) Match(metadata *adapter.InboundContext) bool {
+	if metadata.HTTPUserAgent == "" {
+		return false
+	}
+	userAgent := strings.ToLower(metadata.HTTPUserAgent)
+	for _, keyword := range r.keywords {
+		if strings.Contains(userAgent, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *HTTPUserAgentItem) String() string {
+	kLen := len(r.keywords)
+	if kLen == 1 {
+		return "http_user_agent=" + r.keywords[0]
+	} else if kLen > 3 {
+		return "http_user_agent=[" + strings.Join(r.keywords[:3], " ") + "...]"
+	} else {
+		return "http_user_agent=[" + strings.Join(r.keywords, " ") + "]"
+	}
+}
@@ -0,0 +1,20 @@
+package rule
+
+import (
+	"testing"
+
+	"github.com/sagernet/sing-box/adapter"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPUserAgentItem(t *testing.T) {
+	t.Parallel()
+	keywords := []string{"Curl", "Mozilla"}
+	item := NewHTTPUserAgentItem(keywords)
+	require.Equal(t, []string{"Curl", "Mozilla"}, keywords)
+	require.True(t, item.Match(&adapter.InboundContext{HTTPUserAgent: "curl/8.0"}))
+	require.True(t, item.Match(&adapter.InboundContext{HTTPUserAgent: "MOZILLA/5.0"}))
+	require.False(t, item.Match(&adapter.InboundContext{HTTPUserAgent: "wget"}))
+	require.False(t, item.Match(&adapter.InboundContext{}))
+}
@@ -0,0 +1,46 @@
+package rule
+
+import (
+	"bytes"
+
+	"github.com/sagernet/sing-box/common/convertor/adblock"
+	"github.com/sagernet/sing-box/common/convertor/adguard"
+	"github.com/sagernet/sing-box/common/convertor/clash"
+	"github.com/sagernet/sing-box/common/srs"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/json"
+	"github.com/sagernet/sing/common/logger"
+)
+
+// readRuleSet decodes rule-set content in the given format. Filter lists are
+// converted to headless rules on load, the convertor logs the lines it skips.
+func readRuleSet(content []byte, format string, behavior string, logger logger.Logger) ([]option.HeadlessRule, error) {
+	var (
+		ruleSet option.PlainRuleSetCompat
+		err     error
+	)
+	switch format {
+	case C.RuleSetFormatSource, "":
+		ruleSet, err = json.UnmarshalExtended[option.PlainRuleSetCompat](content)
+	case C.RuleSetFormatBinary:
+		ruleSet, err = srs.Read(bytes.NewReader(content), false)
+	case C.RuleSetFormatAdGuard:
+		return adguard.Convert(bytes.NewReader(content), logger)
+	case C.RuleSetFormatAdblock:
+		return adblock.Convert(bytes.NewReader(content), logger)
+	case C.RuleSetFormatClash:
+		return clash.Convert(bytes.NewReader(content), behavior, logger)
+	default:
+		return nil, E.New("unknown rule-set format: ", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	plainRuleSet, err := ruleSet.Upgrade()
+	if err != nil {
+		return nil, err
+	}
+	return plainRuleSet.Rules, nil
+}
@@ -9,15 +9,15 @@ import (
 
 	"github.com/sagernet/fswatch"
 	"github.com/sagernet/sing-box/adapter"
-	"github.com/sagernet/sing-box/common/srs"
+	"github.com/sagernet/sing-box/common/autoupdate"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/option"
 	"github.com/sagernet/sing/common"
 	"github.com/sagernet/sing/common/atomic"
 	E "github.com/sagernet/sing/common/exceptions"
 	F "github.com/sagernet/sing/common/format"
-	"github.com/sagernet/sing/common/json"
 	"github.com/sagernet/sing/common/logger"
+	"github.com/sagernet/sing/common/rw"
 	"github.com/sagernet/sing/common/x/list"
 	"github.com/sagernet/sing/service/filemanager"
 
@@ -28,22 +28,25 @@ var _ adapter.RuleSet = (*LocalRuleSet)(nil)
 
 type LocalRuleSet struct {
 	ctx        context.Context
-	logger     logger.Logger
+	logger     logger.ContextLogger
 	tag        string
 	rules      []adapter.HeadlessRule
 	metadata   adapter.RuleSetMetadata
 	fileFormat string
+	behavior   string
 	watcher    *fswatch.Watcher
+	updater    *autoupdate.Updater
 	refs       atomic.Int32
 	ruleCount   uint64
 }
 
-func NewLocalRuleSet(ctx context.Context, logger logger.Logger, options option.RuleSet) (*LocalRuleSet, error) {
+func NewLocalRuleSet(ctx context.Context, logger logger.ContextLogger, options option.RuleSet) (*LocalRuleSet, error) {
 	ruleSet := &LocalRuleSet{
 		ctx:        ctx,
 		logger:     logger,
 		tag:        options.Tag,
 		fileFormat: options.Format,
+		behavior:   options.Behavior,
 	}
 	if options.Type == C.RuleSetTypeInline {
 		if len(options.InlineOptions.Rules) == 0 {
@@ -54,9 +57,26 @@ func NewLocalRuleSet(ctx context.Context, logger logger.Logger, options option.R
 			return nil, err
 		}
 	} else {
-		err := ruleSet.reloadFile(filemanager.BasePath(ctx, options.LocalOptions.Path))
-		if err != nil {
-			return nil, err
+		filePath := filemanager.BasePath(ctx, options.LocalOptions.Path)
+		if options.LocalOptions.UpdateInterval > 0 {
+			updater, err := autoupdate.New(ctx, logger, F.ToString("rule-set ", options.Tag), autoupdate.Options{
+				Path:           filePath,
+				DownloadURL:    options.LocalOptions.DownloadURL,
+				DownloadDetour: options.LocalOptions.DownloadDetour,
+				ChecksumURL:    options.LocalOptions.ChecksumURL,
+				UpdateInterval: time.Duration(options.LocalOptions.UpdateInterval),
+			}, ruleSet.reloadFile)
+			if err != nil {
+				return nil, err
+			}
+			ruleSet.updater = updater
+		}
+		// a missing file is downloaded by the updater on start
+		if ruleSet.updater == nil || rw.IsFile(filePath) {
+			err := ruleSet.reloadFile(filePath)
+			if err != nil {
+				return nil, err
+			}
 		}
 	}
 	if options.Type == C.RuleSetTypeLocal {
@@ -95,6 +115,18 @@ func (s *LocalRuleSet) String() string {
 }
 
 func (s *LocalRuleSet) StartContext(ctx context.Context, startContext *adapter.HTTPStartContext) error {
+	if s.updater != nil {
+		err := s.updater.Start()
+		if err != nil {
+			return err
+		}
+		if s.metadata.LastUpdated.IsZero() {
+			err = s.updater.Update(ctx)
+			if err != nil {
+				return E.Cause(err, "initial rule-set: ", s.tag)
+			}
+		}
+	}
 	if s.watcher != nil {
 		err := s.watcher.Start()
 		if err != nil {
@@ -105,35 +137,15 @@ func (s *LocalRuleSet) StartContext(ctx context.Context, startContext *adapter.H
 }
 
 func (s *LocalRuleSet) reloadFile(path string) error {
-	var ruleSet option.PlainRuleSetCompat
-	switch s.fileFormat {
-	case C.RuleSetFormatSource, "":
-		content, err := os.ReadFile(path)
-		if err != nil {
-			return err
-		}
-		ruleSet, err = json.UnmarshalExtended[option.PlainRuleSetCompat](content)
-		if err != nil {
-			return err
-		}
-
-	case C.RuleSetFormatBinary:
-		setFile, err := os.Open(path)
-		if err != nil {
-			return err
-		}
-		ruleSet, err = srs.Read(setFile, false)
-		if err != nil {
-			return err
-		}
-	default:
-		return E.New("unknown rule-set format: ", s.fileFormat)
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
 	}
-	plainRuleSet, err := ruleSet.Upgrade()
+	rules, err := readRuleSet(content, s.fileFormat, s.behavior, s.logger)
 	if err != nil {
 		return err
 	}
-	return s.reloadRules(plainRuleSet.Rules)
+	return s.reloadRules(rules)
 }
 
 func (s *LocalRuleSet) reloadRules(headlessRules []option.HeadlessRule) error {
@@ -160,6 +172,9 @@ func (s *LocalRuleSet) reloadRules(headlessRules []option.HeadlessRule) error {
 }
 
 func (s *LocalRuleSet) PostStart() error {
+	if s.updater != nil {
+		s.updater.PostStart()
+	}
 	return nil
 }
 
@@ -196,7 +211,7 @@ func (s *LocalRuleSet) UnregisterCallback(element *list.Element[adapter.RuleSetU
 
 func (s *LocalRuleSet) Close() error {
 	s.rules = nil
-	return common.Close(common.PtrOrNil(s.watcher))
+	return common.Close(common.PtrOrNil(s.watcher), common.PtrOrNil(s.updater))
 }
 
 func (s *LocalRuleSet) Match(metadata *adapter.InboundContext) bool {
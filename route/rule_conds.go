@@ -73,3 +73,16 @@ func isWIFIRule(rule option.DefaultRule) bool {
 func isWIFIDNSRule(rule option.DefaultDNSRule) bool {
 	return len(rule.WIFISSID) > 0 || len(rule.WIFIBSSID) > 0
 }
+
+func collectDNSRuleInbounds(rules []option.DNSRule, inbounds map[string]bool) {
+	for _, rule := range rules {
+		switch rule.Type {
+		case C.RuleTypeDefault:
+			for _, inbound := range rule.DefaultOptions.Inbound {
+				inbounds[inbound] = true
+			}
+		case C.RuleTypeLogical:
+			collectDNSRuleInbounds(rule.LogicalOptions.Rules, inbounds)
+		}
+	}
+}
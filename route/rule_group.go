@@ -0,0 +1,164 @@
+package route
+
+import (
+	"sync/atomic"
+
+	"github.com/sagernet/sing-box/adapter"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/service"
+)
+
+type ruleGroup struct {
+	name         string
+	enabled      atomic.Bool
+	override     atomic.Pointer[bool]
+	ruleCount    int
+	dnsRuleCount int
+}
+
+// Enabled returns the state set by the current Clash mode if any, or the
+// state set by the API.
+func (g *ruleGroup) Enabled() bool {
+	if override := g.override.Load(); override != nil {
+		return *override
+	}
+	return g.enabled.Load()
+}
+
+func (r *Router) loadRuleGroup(name string) *ruleGroup {
+	group, loaded := r.ruleGroupMap[name]
+	if !loaded {
+		group = &ruleGroup{name: name}
+		group.enabled.Store(true)
+		r.ruleGroupMap[name] = group
+		r.ruleGroups = append(r.ruleGroups, group)
+	}
+	return group
+}
+
+func (r *Router) newGroupRule(name string, rule adapter.Rule) adapter.Rule {
+	if name == "" {
+		return rule
+	}
+	group := r.loadRuleGroup(name)
+	group.ruleCount++
+	return &groupRule{rule, group}
+}
+
+func (r *Router) newGroupDNSRule(name string, rule adapter.DNSRule) adapter.DNSRule {
+	if name == "" {
+		return rule
+	}
+	group := r.loadRuleGroup(name)
+	group.dnsRuleCount++
+	return &groupDNSRule{rule, group}
+}
+
+func (r *Router) initializeRuleGroups() {
+	cacheFile := service.FromContext[adapter.CacheFile](r.ctx)
+	if cacheFile == nil {
+		return
+	}
+	for _, group := range r.ruleGroups {
+		if enabled, loaded := cacheFile.LoadRuleGroup(group.name); loaded {
+			group.enabled.Store(enabled)
+			if !enabled {
+				r.logger.Info("rule group ", group.name, " disabled")
+			}
+		}
+	}
+}
+
+func (r *Router) RuleGroups() []adapter.RuleGroup {
+	groups := make([]adapter.RuleGroup, 0, len(r.ruleGroups))
+	for _, group := range r.ruleGroups {
+		groups = append(groups, adapter.RuleGroup{
+			Name:         group.name,
+			Enabled:      group.Enabled(),
+			Overridden:   group.override.Load() != nil,
+			RuleCount:    group.ruleCount,
+			DNSRuleCount: group.dnsRuleCount,
+		})
+	}
+	return groups
+}
+
+func (r *Router) SetRuleGroupEnabled(name string, enabled bool) error {
+	group, loaded := r.ruleGroupMap[name]
+	if !loaded {
+		return E.New("rule group not found: ", name)
+	}
+	if group.enabled.Swap(enabled) == enabled {
+		return nil
+	}
+	if enabled {
+		r.logger.Info("rule group ", name, " enabled")
+	} else {
+		r.logger.Info("rule group ", name, " disabled")
+	}
+	if group.override.Load() != nil {
+		r.logger.Warn("rule group ", name, " is overridden by the current mode, the change applies after switching mode")
+	}
+	// cached responses may have been resolved by other rules
+	r.ClearDNSCache()
+	cacheFile := service.FromContext[adapter.CacheFile](r.ctx)
+	if cacheFile != nil {
+		err := cacheFile.StoreRuleGroup(name, enabled)
+		if err != nil {
+			return E.Cause(err, "save rule group")
+		}
+	}
+	return nil
+}
+
+// SetModeOverlay overrides the state of rule groups and the final outbound
+// for the current Clash mode, groups not in ruleGroups are restored to the
+// state set by the API.
+func (r *Router) SetModeOverlay(ruleGroups map[string]bool, final string) error {
+	for name := range ruleGroups {
+		if _, loaded := r.ruleGroupMap[name]; !loaded {
+			return E.New("rule group not found: ", name)
+		}
+	}
+	var finalOutbound adapter.Outbound
+	if final != "" {
+		var loaded bool
+		finalOutbound, loaded = r.outbound.Outbound(final)
+		if !loaded {
+			return E.New("final outbound not found: ", final)
+		}
+	}
+	for _, group := range r.ruleGroups {
+		if enabled, loaded := ruleGroups[group.name]; loaded {
+			group.override.Store(&enabled)
+		} else {
+			group.override.Store(nil)
+		}
+	}
+	r.modeFinal.Store(finalOutbound)
+	return nil
+}
+
+// groupRule does not match while its group is disabled, regardless of
+// invert.
+type groupRule struct {
+	adapter.Rule
+	group *ruleGroup
+}
+
+func (r *groupRule) Match(metadata *adapter.InboundContext) bool {
+	return r.group.Enabled() && r.Rule.Match(metadata)
+}
+
+type groupDNSRule struct {
+	adapter.DNSRule
+	group *ruleGroup
+}
+
+func (r *groupDNSRule) Match(metadata *adapter.InboundContext) bool {
+	return r.group.Enabled() && r.DNSRule.Match(metadata)
+}
+
+func (r *groupDNSRule) MatchAddressLimit(metadata *adapter.InboundContext) bool {
+	return r.group.Enabled() && r.DNSRule.MatchAddressLimit(metadata)
+}
@@ -0,0 +1,185 @@
+package dnsstream
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/buf"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/logger"
+
+	mDNS "github.com/miekg/dns"
+)
+
+// defaultIdleTimeout is how long a connection without pending queries is kept open.
+const defaultIdleTimeout = 30 * time.Second
+
+type pipelineConn struct {
+	net.Conn
+	logger      logger.ContextLogger
+	idleTimeout time.Duration
+	access      sync.Mutex
+	writeLock   sync.Mutex
+	queryId     uint16
+	callbacks   map[uint16]chan *mDNS.Msg
+	done        chan struct{}
+	err         error
+}
+
+func newPipelineConn(conn net.Conn, logger logger.ContextLogger, idleTimeout time.Duration) *pipelineConn {
+	pipeline := &pipelineConn{
+		Conn:        conn,
+		logger:      logger,
+		idleTimeout: idleTimeout,
+		callbacks:   make(map[uint16]chan *mDNS.Msg),
+		done:        make(chan struct{}),
+	}
+	go pipeline.loopRead()
+	return pipeline
+}
+
+func (c *pipelineConn) isAlive() bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+		return true
+	}
+}
+
+func (c *pipelineConn) exchange(ctx context.Context, message *mDNS.Msg) (*mDNS.Msg, error) {
+	callback := make(chan *mDNS.Msg, 1)
+	c.access.Lock()
+	if c.err != nil {
+		c.access.Unlock()
+		return nil, c.err
+	}
+	var queryId uint16
+	for {
+		c.queryId++
+		queryId = c.queryId
+		if _, loaded := c.callbacks[queryId]; !loaded {
+			break
+		}
+	}
+	c.callbacks[queryId] = callback
+	c.access.Unlock()
+	defer c.removeCallback(queryId)
+	c.writeLock.Lock()
+	deadline, loaded := ctx.Deadline()
+	if !loaded {
+		deadline = time.Now().Add(C.DNSTimeout)
+	}
+	c.SetWriteDeadline(deadline)
+	err := writeMessage(c.Conn, queryId, message)
+	c.SetWriteDeadline(time.Time{})
+	c.writeLock.Unlock()
+	if err != nil {
+		c.close(err)
+		return nil, E.Cause(err, "write request")
+	}
+	c.access.Lock()
+	c.SetReadDeadline(time.Now().Add(C.DNSTimeout))
+	c.access.Unlock()
+	select {
+	case response := <-callback:
+		response.Id = message.Id
+		return response, nil
+	case <-c.done:
+		return nil, E.Cause(c.err, "read response")
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (c *pipelineConn) removeCallback(queryId uint16) {
+	c.access.Lock()
+	delete(c.callbacks, queryId)
+	c.access.Unlock()
+}
+
+// loopRead dispatches responses to pending queries. The read deadline is
+// refreshed before each read, so the connection is closed when the server
+// stops answering pending queries or when it stays idle for idleTimeout.
+func (c *pipelineConn) loopRead() {
+	for {
+		c.access.Lock()
+		if len(c.callbacks) > 0 {
+			c.SetReadDeadline(time.Now().Add(C.DNSTimeout))
+		} else {
+			c.SetReadDeadline(time.Now().Add(c.idleTimeout))
+		}
+		c.access.Unlock()
+		response, err := readMessage(c.Conn)
+		if err != nil {
+			c.close(err)
+			return
+		}
+		c.access.Lock()
+		callback, loaded := c.callbacks[response.Id]
+		c.access.Unlock()
+		if !loaded {
+			c.logger.Trace("dropped response with unknown id ", response.Id)
+			continue
+		}
+		select {
+		case callback <- response:
+		default:
+		}
+	}
+}
+
+func (c *pipelineConn) close(err error) {
+	c.access.Lock()
+	defer c.access.Unlock()
+	if c.err != nil {
+		return
+	}
+	if err == nil || E.IsClosed(err) {
+		err = net.ErrClosed
+	}
+	c.err = err
+	close(c.done)
+	c.Conn.Close()
+}
+
+func readMessage(reader io.Reader) (*mDNS.Msg, error) {
+	var responseLen uint16
+	err := binary.Read(reader, binary.BigEndian, &responseLen)
+	if err != nil {
+		return nil, err
+	}
+	if responseLen < 10 {
+		return nil, mDNS.ErrShortRead
+	}
+	buffer := buf.NewSize(int(responseLen))
+	defer buffer.Release()
+	_, err = buffer.ReadFullFrom(reader, int(responseLen))
+	if err != nil {
+		return nil, err
+	}
+	var message mDNS.Msg
+	err = message.Unpack(buffer.Bytes())
+	return &message, err
+}
+
+func writeMessage(writer io.Writer, messageId uint16, message *mDNS.Msg) error {
+	exMessage := *message
+	exMessage.Id = messageId
+	exMessage.Compress = true
+	rawMessage, err := exMessage.Pack()
+	if err != nil {
+		return err
+	}
+	buffer := buf.NewSize(2 + len(rawMessage))
+	defer buffer.Release()
+	common.Must(binary.Write(buffer, binary.BigEndian, uint16(len(rawMessage))))
+	common.Must1(buffer.Write(rawMessage))
+	return common.Error(writer.Write(buffer.Bytes()))
+}
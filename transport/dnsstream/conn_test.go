@@ -0,0 +1,163 @@
+package dnsstream
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sagernet/sing/common/logger"
+
+	mDNS "github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+)
+
+type deadlineConn struct {
+	net.Conn
+	access        sync.Mutex
+	writeDeadline []time.Time
+}
+
+func (c *deadlineConn) SetWriteDeadline(t time.Time) error {
+	c.access.Lock()
+	c.writeDeadline = append(c.writeDeadline, t)
+	c.access.Unlock()
+	return c.Conn.SetWriteDeadline(t)
+}
+
+func newQuery(domain string, queryType uint16) *mDNS.Msg {
+	message := new(mDNS.Msg)
+	message.SetQuestion(mDNS.Fqdn(domain), queryType)
+	return message
+}
+
+// serveReversed answers each pair of queries in reverse order.
+func serveReversed(conn net.Conn) {
+	for {
+		var queries []*mDNS.Msg
+		for len(queries) < 2 {
+			query, err := readMessage(conn)
+			if err != nil {
+				return
+			}
+			queries = append(queries, query)
+		}
+		for i := len(queries) - 1; i >= 0; i-- {
+			response := new(mDNS.Msg)
+			response.SetReply(queries[i])
+			if writeMessage(conn, queries[i].Id, response) != nil {
+				return
+			}
+		}
+	}
+}
+
+func TestPipelineExchange(t *testing.T) {
+	t.Parallel()
+	client, server := net.Pipe()
+	defer server.Close()
+	go serveReversed(server)
+	conn := newPipelineConn(client, logger.NOP(), defaultIdleTimeout)
+	defer conn.close(nil)
+	queries := []*mDNS.Msg{newQuery("example.com", mDNS.TypeA), newQuery("example.com", mDNS.TypeAAAA)}
+	var wg sync.WaitGroup
+	for _, query := range queries {
+		wg.Add(1)
+		go func(query *mDNS.Msg) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			response, err := conn.exchange(ctx, query)
+			require.NoError(t, err)
+			require.Equal(t, query.Id, response.Id)
+			require.Equal(t, query.Question, response.Question)
+		}(query)
+	}
+	wg.Wait()
+	require.True(t, conn.isAlive())
+}
+
+func TestPipelineWriteDeadlineCleared(t *testing.T) {
+	t.Parallel()
+	client, server := net.Pipe()
+	defer server.Close()
+	go func() {
+		for {
+			query, err := readMessage(server)
+			if err != nil {
+				return
+			}
+			response := new(mDNS.Msg)
+			response.SetReply(query)
+			if writeMessage(server, query.Id, response) != nil {
+				return
+			}
+		}
+	}()
+	wrapper := &deadlineConn{Conn: client}
+	conn := newPipelineConn(wrapper, logger.NOP(), defaultIdleTimeout)
+	defer conn.close(nil)
+	_, err := conn.exchange(context.Background(), newQuery("example.com", mDNS.TypeA))
+	require.NoError(t, err)
+	wrapper.access.Lock()
+	defer wrapper.access.Unlock()
+	require.Len(t, wrapper.writeDeadline, 2)
+	require.False(t, wrapper.writeDeadline[0].IsZero())
+	require.True(t, wrapper.writeDeadline[1].IsZero())
+}
+
+func TestPipelineIdleTimeout(t *testing.T) {
+	t.Parallel()
+	client, server := net.Pipe()
+	defer server.Close()
+	conn := newPipelineConn(client, logger.NOP(), 50*time.Millisecond)
+	select {
+	case <-conn.done:
+	case <-time.After(time.Second):
+		t.Fatal("idle connection not closed")
+	}
+	_, err := conn.exchange(context.Background(), newQuery("example.com", mDNS.TypeA))
+	require.Error(t, err)
+}
+
+func TestPipelineServerClosed(t *testing.T) {
+	t.Parallel()
+	client, server := net.Pipe()
+	conn := newPipelineConn(client, logger.NOP(), defaultIdleTimeout)
+	go func() {
+		readMessage(server)
+		server.Close()
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err := conn.exchange(ctx, newQuery("example.com", mDNS.TypeA))
+	require.Error(t, err)
+	require.NotErrorIs(t, err, context.DeadlineExceeded)
+	require.False(t, conn.isAlive())
+}
+
+func TestWriteMessageLength(t *testing.T) {
+	t.Parallel()
+	message := newQuery("example.com", mDNS.TypeA)
+	response := new(mDNS.Msg)
+	response.SetReply(message)
+	for i := 0; i < 4; i++ {
+		response.Answer = append(response.Answer, &mDNS.CNAME{
+			Hdr:    mDNS.RR_Header{Name: "example.com.", Rrtype: mDNS.TypeCNAME, Class: mDNS.ClassINET, Ttl: 60},
+			Target: "target.example.com.",
+		})
+	}
+	var buffer bytes.Buffer
+	require.NoError(t, writeMessage(&buffer, 1234, response))
+	require.NoError(t, writeMessage(&buffer, 1235, message))
+	decoded, err := readMessage(&buffer)
+	require.NoError(t, err)
+	require.Equal(t, uint16(1234), decoded.Id)
+	require.Len(t, decoded.Answer, 4)
+	decoded, err = readMessage(&buffer)
+	require.NoError(t, err)
+	require.Equal(t, uint16(1235), decoded.Id)
+	require.Zero(t, buffer.Len())
+}
@@ -0,0 +1,176 @@
+package dnsstream
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/netip"
+	"net/url"
+	"os"
+	"sync"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-dns"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/logger"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+
+	mDNS "github.com/miekg/dns"
+)
+
+var _ dns.Transport = (*Transport)(nil)
+
+// Transport is a TCP or DNS-over-TLS transport that pipelines concurrent
+// queries over a single upstream connection, so that the A and AAAA
+// queries of a dual-stack lookup share one handshake. It is used instead of
+// the sing-dns transport when the server enables pipeline.
+type Transport struct {
+	name       string
+	dialer     N.Dialer
+	logger     logger.ContextLogger
+	serverAddr M.Socksaddr
+	tlsConfig  *tls.Config
+	access     sync.Mutex
+	conn       *pipelineConn
+	dialing    *pendingConn
+}
+
+// pendingConn is a connection being dialed, shared by the queries started
+// meanwhile so that they are pipelined over it as well.
+type pendingConn struct {
+	done chan struct{}
+	conn *pipelineConn
+	err  error
+}
+
+func NewTransport(options dns.TransportOptions, enableTLS bool) (*Transport, error) {
+	serverURL, err := url.Parse(options.Address)
+	if err != nil {
+		return nil, err
+	}
+	serverAddr := M.ParseSocksaddr(serverURL.Host)
+	if !serverAddr.IsValid() {
+		return nil, E.New("invalid server address")
+	}
+	transport := &Transport{
+		name:   options.Name,
+		dialer: options.Dialer,
+		logger: options.Logger,
+	}
+	if enableTLS {
+		if serverAddr.Port == 0 {
+			serverAddr.Port = 853
+		}
+		transport.tlsConfig = &tls.Config{
+			ServerName: serverAddr.AddrString(),
+			RootCAs:    adapter.RootPoolFromContext(options.Context),
+		}
+	} else if serverAddr.Port == 0 {
+		serverAddr.Port = 53
+	}
+	transport.serverAddr = serverAddr
+	return transport, nil
+}
+
+func (t *Transport) Name() string {
+	return t.name
+}
+
+func (t *Transport) Start() error {
+	return nil
+}
+
+func (t *Transport) Reset() {
+	t.access.Lock()
+	defer t.access.Unlock()
+	if t.conn != nil {
+		t.conn.close(net.ErrClosed)
+		t.conn = nil
+	}
+	t.dialing = nil
+}
+
+func (t *Transport) Close() error {
+	t.Reset()
+	return nil
+}
+
+func (t *Transport) Raw() bool {
+	return true
+}
+
+func (t *Transport) Exchange(ctx context.Context, message *mDNS.Msg) (*mDNS.Msg, error) {
+	conn, reused, err := t.open(ctx)
+	if err != nil {
+		return nil, err
+	}
+	response, err := conn.exchange(ctx, message)
+	if err != nil && reused && !conn.isAlive() {
+		// The pooled connection was closed by the server while idle, retry once on a fresh one.
+		conn, _, err = t.open(ctx)
+		if err != nil {
+			return nil, err
+		}
+		response, err = conn.exchange(ctx, message)
+	}
+	return response, err
+}
+
+func (t *Transport) open(ctx context.Context) (*pipelineConn, bool, error) {
+	t.access.Lock()
+	if t.conn != nil && t.conn.isAlive() {
+		t.access.Unlock()
+		return t.conn, true, nil
+	}
+	pending := t.dialing
+	if pending != nil {
+		t.access.Unlock()
+		select {
+		case <-pending.done:
+			return pending.conn, false, pending.err
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		}
+	}
+	pending = &pendingConn{done: make(chan struct{})}
+	t.dialing = pending
+	t.access.Unlock()
+	conn, err := t.dial(ctx)
+	t.access.Lock()
+	if t.dialing == pending {
+		t.dialing = nil
+		if err == nil {
+			t.conn = conn
+		}
+	} else if err == nil {
+		// reset while dialing
+		conn.close(net.ErrClosed)
+		conn, err = nil, net.ErrClosed
+	}
+	t.access.Unlock()
+	pending.conn, pending.err = conn, err
+	close(pending.done)
+	return conn, false, err
+}
+
+func (t *Transport) dial(ctx context.Context) (*pipelineConn, error) {
+	conn, err := t.dialer.DialContext(ctx, N.NetworkTCP, t.serverAddr)
+	if err != nil {
+		return nil, err
+	}
+	if t.tlsConfig != nil {
+		tlsConn := tls.Client(conn, t.tlsConfig.Clone())
+		err = tlsConn.HandshakeContext(ctx)
+		if err != nil {
+			conn.Close()
+			return nil, E.Cause(err, "TLS handshake")
+		}
+		conn = tlsConn
+	}
+	return newPipelineConn(conn, t.logger, defaultIdleTimeout), nil
+}
+
+func (t *Transport) Lookup(ctx context.Context, domain string, strategy dns.DomainStrategy) ([]netip.Addr, error) {
+	return nil, os.ErrInvalid
+}
@@ -0,0 +1,90 @@
+package dnsstream
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sagernet/sing/common/logger"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+
+	mDNS "github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+)
+
+type blockingDialer struct {
+	release chan struct{}
+	dials   atomic.Int32
+}
+
+func (d *blockingDialer) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	d.dials.Add(1)
+	<-d.release
+	client, server := net.Pipe()
+	go serveReversed(server)
+	return client, nil
+}
+
+func (d *blockingDialer) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
+	return nil, net.ErrClosed
+}
+
+func newTestTransport(dialer N.Dialer) *Transport {
+	return &Transport{
+		dialer:     dialer,
+		logger:     logger.NOP(),
+		serverAddr: M.ParseSocksaddrHostPort("127.0.0.1", 53),
+	}
+}
+
+func TestTransportSharesDial(t *testing.T) {
+	t.Parallel()
+	dialer := &blockingDialer{release: make(chan struct{})}
+	transport := newTestTransport(dialer)
+	defer transport.Close()
+	var group sync.WaitGroup
+	for _, queryType := range []uint16{mDNS.TypeA, mDNS.TypeAAAA} {
+		group.Add(1)
+		go func(queryType uint16) {
+			defer group.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_, err := transport.Exchange(ctx, newQuery("example.com", queryType))
+			require.NoError(t, err)
+		}(queryType)
+	}
+	time.Sleep(100 * time.Millisecond)
+	close(dialer.release)
+	group.Wait()
+	require.Equal(t, int32(1), dialer.dials.Load())
+}
+
+func TestTransportResetWhileDialing(t *testing.T) {
+	t.Parallel()
+	dialer := &blockingDialer{release: make(chan struct{})}
+	transport := newTestTransport(dialer)
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := transport.open(context.Background())
+		done <- err
+	}()
+	require.Eventually(t, func() bool {
+		return dialer.dials.Load() == 1
+	}, time.Second, 10*time.Millisecond)
+	reset := make(chan struct{})
+	go func() {
+		transport.Reset()
+		close(reset)
+	}()
+	select {
+	case <-reset:
+	case <-time.After(time.Second):
+		t.Fatal("reset blocked by dial")
+	}
+	close(dialer.release)
+	require.ErrorIs(t, <-done, net.ErrClosed)
+}
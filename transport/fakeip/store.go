@@ -18,6 +18,7 @@ type Store struct {
 	inet4Range   netip.Prefix
 	inet6Range   netip.Prefix
 	storage      adapter.FakeIPStorage
+	readOnly     bool
 	inet4Current netip.Addr
 	inet6Current netip.Addr
 }
@@ -36,10 +37,16 @@ func (s *Store) Start() error {
 	cacheFile := service.FromContext[adapter.CacheFile](s.ctx)
 	if cacheFile != nil && cacheFile.StoreFakeIP() {
 		storage = cacheFile
+		s.readOnly = cacheFile.FakeIPReadOnly()
 	}
 	if storage == nil {
 		storage = NewMemoryStorage()
 	}
+	if s.readOnly {
+		// addresses are allocated by the instance sharing the cache file
+		s.storage = storage
+		return nil
+	}
 	metadata := storage.FakeIPMetadata()
 	if metadata != nil && metadata.Inet4Range == s.inet4Range && metadata.Inet6Range == s.inet6Range {
 		s.inet4Current = metadata.Inet4Current
@@ -62,7 +69,7 @@ func (s *Store) Contains(address netip.Addr) bool {
 }
 
 func (s *Store) Close() error {
-	if s.storage == nil {
+	if s.storage == nil || s.readOnly {
 		return nil
 	}
 	return s.storage.FakeIPSaveMetadata(&adapter.FakeIPMetadata{
@@ -77,6 +84,9 @@ func (s *Store) Create(domain string, isIPv6 bool) (netip.Addr, error) {
 	if address, loaded := s.storage.FakeIPLoadDomain(domain, isIPv6); loaded {
 		return address, nil
 	}
+	if s.readOnly {
+		return netip.Addr{}, E.New("fakeip address for ", domain, " is not allocated by the DNS instance")
+	}
 	var address netip.Addr
 	if !isIPv6 {
 		if !s.inet4Current.IsValid() {
@@ -114,5 +124,8 @@ func (s *Store) Lookup(address netip.Addr) (string, bool) {
 }
 
 func (s *Store) Reset() error {
+	if s.readOnly {
+		return E.New("fakeip is reset by the DNS instance")
+	}
 	return s.storage.FakeIPReset()
 }
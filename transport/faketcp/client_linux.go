@@ -0,0 +1,185 @@
+package faketcp
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/buf"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+	"github.com/sagernet/sing/common/logger"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+var _ net.Conn = (*clientConn)(nil)
+
+type clientConn struct {
+	rawConn    *net.IPConn
+	tcpConn    net.Conn
+	dropRule   *dropRule
+	localAddr  netip.AddrPort
+	remoteAddr netip.AddrPort
+	access     sync.Mutex
+	flow       flow
+}
+
+// Dial connects to destination with a real TCP handshake, then exchanges
+// packets as segments of that connection through a raw socket.
+func Dial(ctx context.Context, dialer N.Dialer, destination M.Socksaddr, logger logger.ContextLogger) (net.Conn, error) {
+	// The raw sockets must exist before the handshake to see the SYN-ACK.
+	rawConn4, err4 := listenRaw(netip.Addr{}, false)
+	rawConn6, err6 := listenRaw(netip.Addr{}, true)
+	if err4 != nil && err6 != nil {
+		return nil, E.Cause(E.Errors(err4, err6), "open raw socket")
+	}
+	tcpConn, err := dialer.DialContext(ctx, N.NetworkTCP, destination)
+	if err != nil {
+		closeRaw(rawConn4)
+		closeRaw(rawConn6)
+		return nil, err
+	}
+	conn := &clientConn{
+		tcpConn:    tcpConn,
+		localAddr:  unmapAddrPort(tcpConn.LocalAddr()),
+		remoteAddr: unmapAddrPort(tcpConn.RemoteAddr()),
+	}
+	if conn.remoteAddr.Addr().Is4() {
+		conn.rawConn = rawConn4
+		closeRaw(rawConn6)
+	} else {
+		conn.rawConn = rawConn6
+		closeRaw(rawConn4)
+	}
+	if conn.rawConn == nil {
+		tcpConn.Close()
+		return nil, E.New("faketcp: raw socket unavailable for ", conn.remoteAddr.Addr())
+	}
+	err = conn.start(logger)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (c *clientConn) start(logger logger.ContextLogger) error {
+	err := inheritSocketOptions(c.tcpConn, c.rawConn, c.localAddr.Addr())
+	if err != nil {
+		return err
+	}
+	err = setTTL(c.tcpConn)
+	if err != nil {
+		return E.Cause(err, "set TTL")
+	}
+	c.dropRule, err = installDropRule(!c.remoteAddr.Addr().Is4(), "-d", c.remoteAddr.Addr().String(), "--dport", F.ToString(c.remoteAddr.Port()))
+	if err != nil {
+		logger.Warn(E.Cause(err, "faketcp: install drop rule"))
+	}
+	err = c.waitHandshake()
+	if err != nil {
+		return E.Cause(err, "faketcp: read handshake")
+	}
+	go io.Copy(io.Discard, c.tcpConn)
+	return nil
+}
+
+// waitHandshake reads the SYN-ACK queued on the raw socket while dialing.
+func (c *clientConn) waitHandshake() error {
+	err := c.rawConn.SetReadDeadline(time.Now().Add(C.TCPTimeout))
+	if err != nil {
+		return err
+	}
+	defer c.rawConn.SetReadDeadline(time.Time{})
+	buffer := buf.Get(buf.UDPBufferSize)
+	defer buf.Put(buffer)
+	for {
+		tcpSegment, err := c.readSegment(buffer)
+		if err != nil {
+			return err
+		}
+		if tcpSegment.flags&(flagSYN|flagACK) == flagSYN|flagACK {
+			return nil
+		}
+	}
+}
+
+// readSegment reads the next segment of the connection and updates the flow.
+func (c *clientConn) readSegment(buffer []byte) (segment, error) {
+	for {
+		n, addr, err := c.rawConn.ReadFromIP(buffer)
+		if err != nil {
+			return segment{}, err
+		}
+		tcpSegment, loaded := parseSegment(buffer[:n])
+		if !loaded || tcpSegment.sourcePort != c.remoteAddr.Port() || tcpSegment.destinationPort != c.localAddr.Port() {
+			continue
+		}
+		if sourceAddr, _ := netip.AddrFromSlice(addr.IP); sourceAddr.Unmap() != c.remoteAddr.Addr() {
+			continue
+		}
+		c.access.Lock()
+		c.flow.update(tcpSegment)
+		c.access.Unlock()
+		return tcpSegment, nil
+	}
+}
+
+func (c *clientConn) Read(p []byte) (n int, err error) {
+	buffer := buf.Get(buf.UDPBufferSize)
+	defer buf.Put(buffer)
+	for {
+		tcpSegment, err := c.readSegment(buffer)
+		if err != nil {
+			return 0, err
+		}
+		if tcpSegment.flags&flagPSH == 0 || len(tcpSegment.payload) == 0 {
+			continue
+		}
+		if len(tcpSegment.payload) > len(p) {
+			return 0, io.ErrShortBuffer
+		}
+		return copy(p, tcpSegment.payload), nil
+	}
+}
+
+func (c *clientConn) Write(p []byte) (n int, err error) {
+	c.access.Lock()
+	seq, ack := c.flow.next(len(p))
+	c.access.Unlock()
+	err = writeSegment(c.rawConn, c.localAddr, c.remoteAddr, seq, ack, p)
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (c *clientConn) Close() error {
+	return common.Close(c.rawConn, c.tcpConn, common.PtrOrNil(c.dropRule))
+}
+
+func (c *clientConn) LocalAddr() net.Addr {
+	return M.SocksaddrFromNetIP(c.localAddr).UDPAddr()
+}
+
+func (c *clientConn) RemoteAddr() net.Addr {
+	return M.SocksaddrFromNetIP(c.remoteAddr).UDPAddr()
+}
+
+func (c *clientConn) SetDeadline(t time.Time) error {
+	return c.rawConn.SetDeadline(t)
+}
+
+func (c *clientConn) SetReadDeadline(t time.Time) error {
+	return c.rawConn.SetReadDeadline(t)
+}
+
+func (c *clientConn) SetWriteDeadline(t time.Time) error {
+	return c.rawConn.SetWriteDeadline(t)
+}
@@ -0,0 +1,52 @@
+package faketcp
+
+import (
+	"context"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/sagernet/sing/common/logger"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoopback(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("raw sockets require root")
+	}
+	tcpListener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	serverConn, err := Listen(tcpListener, logger.NOP())
+	require.NoError(t, err)
+	defer serverConn.Close()
+	clientConn, err := Dial(context.Background(), N.SystemDialer, M.SocksaddrFromNet(tcpListener.Addr()), logger.NOP())
+	require.NoError(t, err)
+	defer clientConn.Close()
+	require.NoError(t, serverConn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	require.NoError(t, clientConn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	buffer := make([]byte, 1500)
+	var clientAddr net.Addr
+	for i := 0; i < 3; i++ {
+		// the first packets may arrive before the connection is accepted
+		_, err = clientConn.Write([]byte("ping"))
+		require.NoError(t, err)
+		require.NoError(t, serverConn.SetReadDeadline(time.Now().Add(time.Second)))
+		var n int
+		n, clientAddr, err = serverConn.ReadFrom(buffer)
+		if err == nil {
+			require.Equal(t, "ping", string(buffer[:n]))
+			break
+		}
+	}
+	require.NoError(t, err)
+	require.Equal(t, clientConn.LocalAddr().String(), clientAddr.String())
+	_, err = serverConn.WriteTo([]byte("pong"), clientAddr)
+	require.NoError(t, err)
+	n, err := clientConn.Read(buffer)
+	require.NoError(t, err)
+	require.Equal(t, "pong", string(buffer[:n]))
+}
@@ -0,0 +1,21 @@
+//go:build !linux
+
+package faketcp
+
+import (
+	"context"
+	"net"
+	"os"
+
+	"github.com/sagernet/sing/common/logger"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+func Dial(ctx context.Context, dialer N.Dialer, destination M.Socksaddr, logger logger.ContextLogger) (net.Conn, error) {
+	return nil, os.ErrInvalid
+}
+
+func Listen(listener net.Listener, logger logger.ContextLogger) (net.PacketConn, error) {
+	return nil, os.ErrInvalid
+}
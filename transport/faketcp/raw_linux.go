@@ -0,0 +1,163 @@
+package faketcp
+
+import (
+	"net"
+	"net/netip"
+	"syscall"
+
+	"github.com/sagernet/sing/common/buf"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	"github.com/sagernet/sing/common/shell"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+	"golang.org/x/sys/unix"
+)
+
+// listenRaw opens a raw socket receiving the TCP segments sent to address,
+// or to every local address of the family if address is unspecified. The IP
+// header is stripped from received packets.
+func listenRaw(address netip.Addr, isIPv6 bool) (*net.IPConn, error) {
+	network := "ip4:tcp"
+	if isIPv6 {
+		network = "ip6:tcp"
+	}
+	var bindAddr *net.IPAddr
+	if address.IsValid() && !address.IsUnspecified() {
+		bindAddr = &net.IPAddr{IP: address.AsSlice()}
+	}
+	return net.ListenIP(network, bindAddr)
+}
+
+func closeRaw(conn *net.IPConn) {
+	if conn != nil {
+		conn.Close()
+	}
+}
+
+func writeSegment(conn *net.IPConn, source netip.AddrPort, destination netip.AddrPort, seq uint32, ack uint32, payload []byte) error {
+	buffer := buf.Get(headerLen + len(payload))
+	defer buf.Put(buffer)
+	packet := appendSegment(buffer[:0], source, destination, seq, ack, payload)
+	var oob []byte
+	if source.Addr().Is4() {
+		oob = (&ipv4.ControlMessage{Src: source.Addr().AsSlice()}).Marshal()
+	} else {
+		oob = (&ipv6.ControlMessage{Src: source.Addr().AsSlice()}).Marshal()
+	}
+	_, _, err := conn.WriteMsgIP(packet, oob, &net.IPAddr{IP: destination.Addr().AsSlice()})
+	return err
+}
+
+func control(conn syscall.Conn, fn func(fd int) error) error {
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var innerErr error
+	err = rawConn.Control(func(fd uintptr) {
+		innerErr = fn(int(fd))
+	})
+	if err != nil {
+		return err
+	}
+	return innerErr
+}
+
+// setTTL makes the segments of the real connection expire at the first hop,
+// or be dropped by the rule installed by installDropRule.
+func setTTL(conn net.Conn) error {
+	syscallConn, isSyscallConn := conn.(syscall.Conn)
+	if !isSyscallConn {
+		return E.New("faketcp requires a direct TCP connection")
+	}
+	return control(syscallConn, func(fd int) error {
+		ipv4Err := unix.SetsockoptInt(fd, unix.IPPROTO_IP, unix.IP_TTL, 1)
+		ipv6Err := unix.SetsockoptInt(fd, unix.IPPROTO_IPV6, unix.IPV6_UNICAST_HOPS, 1)
+		if ipv4Err != nil && ipv6Err != nil {
+			return E.Errors(ipv4Err, ipv6Err)
+		}
+		return nil
+	})
+}
+
+// inheritSocketOptions applies the routing mark and the bound interface of the
+// real connection to the raw socket, so that the segments take the same route.
+func inheritSocketOptions(conn net.Conn, rawConn *net.IPConn, localAddr netip.Addr) error {
+	syscallConn, isSyscallConn := conn.(syscall.Conn)
+	if !isSyscallConn {
+		return E.New("faketcp requires a direct TCP connection")
+	}
+	var (
+		routingMark   int
+		interfaceName string
+	)
+	err := control(syscallConn, func(fd int) error {
+		var err error
+		routingMark, err = unix.GetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_MARK)
+		if err != nil {
+			return err
+		}
+		interfaceName, err = unix.GetsockoptString(fd, unix.SOL_SOCKET, unix.SO_BINDTODEVICE)
+		return err
+	})
+	if err != nil {
+		return E.Cause(err, "read socket options")
+	}
+	return control(rawConn, func(fd int) error {
+		if routingMark != 0 {
+			err := unix.SetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_MARK, routingMark)
+			if err != nil {
+				return E.Cause(err, "set routing mark")
+			}
+		}
+		if interfaceName != "" {
+			err := unix.BindToDevice(fd, interfaceName)
+			if err != nil {
+				return E.Cause(err, "bind to interface")
+			}
+		}
+		var sockaddr unix.Sockaddr
+		if localAddr.Is4() {
+			sockaddr = &unix.SockaddrInet4{Addr: localAddr.As4()}
+		} else {
+			sockaddr = &unix.SockaddrInet6{Addr: localAddr.As16()}
+		}
+		return unix.Bind(fd, sockaddr)
+	})
+}
+
+// dropRule drops the outgoing segments of the real connection sent with TTL 1,
+// otherwise its acknowledgements reach the first hop and are answered with
+// ICMP time exceeded.
+type dropRule struct {
+	command   string
+	arguments []string
+}
+
+func installDropRule(isIPv6 bool, match ...string) (*dropRule, error) {
+	rule := &dropRule{command: "iptables"}
+	ttlMatch := []string{"-m", "ttl", "--ttl-eq", "1"}
+	if isIPv6 {
+		rule.command = "ip6tables"
+		ttlMatch = []string{"-m", "hl", "--hl-eq", "1"}
+	}
+	rule.arguments = append([]string{"OUTPUT", "-p", "tcp"}, match...)
+	rule.arguments = append(rule.arguments, ttlMatch...)
+	rule.arguments = append(rule.arguments, "-j", "DROP")
+	err := shell.Exec(rule.command, append([]string{"-w", "-I"}, rule.arguments...)...).Run()
+	if err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (r *dropRule) Close() error {
+	return shell.Exec(r.command, append([]string{"-w", "-D"}, r.arguments...)...).Run()
+}
+
+func unmapAddrPort(addr net.Addr) netip.AddrPort {
+	addrPort := M.AddrPortFromNet(addr)
+	return netip.AddrPortFrom(addrPort.Addr().Unmap(), addrPort.Port())
+}
@@ -0,0 +1,120 @@
+package faketcp
+
+import (
+	"encoding/binary"
+	"net/netip"
+)
+
+const (
+	headerLen = 20
+
+	flagSYN = 0x02
+	flagRST = 0x04
+	flagPSH = 0x08
+	flagACK = 0x10
+
+	window = 0xffff
+)
+
+type segment struct {
+	sourcePort      uint16
+	destinationPort uint16
+	seq             uint32
+	ack             uint32
+	flags           uint8
+	payload         []byte
+}
+
+// parseSegment parses a TCP segment without the IP header, the payload
+// references the packet.
+func parseSegment(packet []byte) (segment, bool) {
+	if len(packet) < headerLen {
+		return segment{}, false
+	}
+	dataOffset := int(packet[12]>>4) * 4
+	if dataOffset < headerLen || dataOffset > len(packet) {
+		return segment{}, false
+	}
+	return segment{
+		sourcePort:      binary.BigEndian.Uint16(packet),
+		destinationPort: binary.BigEndian.Uint16(packet[2:]),
+		seq:             binary.BigEndian.Uint32(packet[4:]),
+		ack:             binary.BigEndian.Uint32(packet[8:]),
+		flags:           packet[13],
+		payload:         packet[dataOffset:],
+	}, true
+}
+
+// appendSegment appends a PSH/ACK segment carrying payload to buffer, the
+// addresses are only used for the checksum.
+func appendSegment(buffer []byte, source netip.AddrPort, destination netip.AddrPort, seq uint32, ack uint32, payload []byte) []byte {
+	start := len(buffer)
+	buffer = binary.BigEndian.AppendUint16(buffer, source.Port())
+	buffer = binary.BigEndian.AppendUint16(buffer, destination.Port())
+	buffer = binary.BigEndian.AppendUint32(buffer, seq)
+	buffer = binary.BigEndian.AppendUint32(buffer, ack)
+	buffer = append(buffer, headerLen/4<<4, flagPSH|flagACK)
+	buffer = binary.BigEndian.AppendUint16(buffer, window)
+	buffer = append(buffer, 0, 0, 0, 0)
+	buffer = append(buffer, payload...)
+	tcpSegment := buffer[start:]
+	binary.BigEndian.PutUint16(tcpSegment[16:], checksum(source.Addr(), destination.Addr(), tcpSegment))
+	return buffer
+}
+
+func checksum(source netip.Addr, destination netip.Addr, tcpSegment []byte) uint16 {
+	var sum uint32
+	sum = sumBytes(sum, source.AsSlice())
+	sum = sumBytes(sum, destination.AsSlice())
+	// protocol number and TCP length of the pseudo header
+	sum += 6
+	sum += uint32(len(tcpSegment))
+	sum = sumBytes(sum, tcpSegment)
+	for sum>>16 != 0 {
+		sum = sum&0xffff + sum>>16
+	}
+	return ^uint16(sum)
+}
+
+func sumBytes(sum uint32, data []byte) uint32 {
+	for len(data) >= 2 {
+		sum += uint32(binary.BigEndian.Uint16(data))
+		data = data[2:]
+	}
+	if len(data) == 1 {
+		sum += uint32(data[0]) << 8
+	}
+	return sum
+}
+
+// flow tracks the sequence numbers of one fake connection like tcpraw, which
+// the faketcp protocol of Hysteria v1 is built on: the sequence number follows
+// the acknowledgements of the peer and the acknowledgement number follows the
+// in-order data of the peer. Unlike tcpraw, the sequence number never moves
+// backwards when a stale acknowledgement arrives.
+type flow struct {
+	seq          uint32
+	ack          uint32
+	synchronized bool
+}
+
+func (f *flow) update(s segment) {
+	if s.flags&flagACK != 0 && (!f.synchronized || int32(s.ack-f.seq) > 0) {
+		f.seq = s.ack
+		f.synchronized = true
+	}
+	if s.flags&flagSYN != 0 {
+		f.ack = s.seq + 1
+	}
+	if s.flags&flagPSH != 0 && f.ack == s.seq {
+		f.ack = s.seq + uint32(len(s.payload))
+	}
+}
+
+// next returns the sequence numbers for a segment carrying n bytes and
+// advances the flow.
+func (f *flow) next(n int) (seq uint32, ack uint32) {
+	seq, ack = f.seq, f.ack
+	f.seq += uint32(n)
+	return
+}
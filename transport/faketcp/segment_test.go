@@ -0,0 +1,74 @@
+package faketcp
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSegment(t *testing.T) {
+	t.Parallel()
+	for _, addresses := range [][2]string{
+		{"192.0.2.1:40000", "198.51.100.1:443"},
+		{"[2001:db8::1]:40000", "[2001:db8::2]:443"},
+	} {
+		source := netip.MustParseAddrPort(addresses[0])
+		destination := netip.MustParseAddrPort(addresses[1])
+		for _, payload := range [][]byte{[]byte("hello"), []byte("odd"), nil} {
+			packet := appendSegment(nil, source, destination, 1000, 2000, payload)
+			require.Len(t, packet, headerLen+len(payload))
+			require.Zero(t, checksum(source.Addr(), destination.Addr(), packet))
+			tcpSegment, loaded := parseSegment(packet)
+			require.True(t, loaded)
+			require.Equal(t, source.Port(), tcpSegment.sourcePort)
+			require.Equal(t, destination.Port(), tcpSegment.destinationPort)
+			require.Equal(t, uint32(1000), tcpSegment.seq)
+			require.Equal(t, uint32(2000), tcpSegment.ack)
+			require.Equal(t, uint8(flagPSH|flagACK), tcpSegment.flags)
+			require.Equal(t, len(payload), len(tcpSegment.payload))
+		}
+	}
+}
+
+func TestParseMalformedSegment(t *testing.T) {
+	t.Parallel()
+	packet := appendSegment(nil, netip.MustParseAddrPort("192.0.2.1:1"), netip.MustParseAddrPort("192.0.2.2:2"), 0, 0, []byte("payload"))
+	_, loaded := parseSegment(packet[:headerLen-1])
+	require.False(t, loaded)
+	packet[12] = 4 << 4
+	_, loaded = parseSegment(packet)
+	require.False(t, loaded)
+	packet[12] = 15 << 4
+	_, loaded = parseSegment(packet)
+	require.False(t, loaded)
+	packet[12] = 6 << 4
+	tcpSegment, loaded := parseSegment(packet)
+	require.True(t, loaded)
+	require.Equal(t, "oad", string(tcpSegment.payload))
+}
+
+func TestFlow(t *testing.T) {
+	t.Parallel()
+	var clientFlow, serverFlow flow
+	// handshake
+	serverFlow.update(segment{seq: 100, flags: flagSYN})
+	clientFlow.update(segment{seq: 5000, ack: 101, flags: flagSYN | flagACK})
+	serverFlow.update(segment{seq: 101, ack: 5001, flags: flagACK})
+	seq, ack := clientFlow.next(10)
+	require.Equal(t, uint32(101), seq)
+	require.Equal(t, uint32(5001), ack)
+	serverFlow.update(segment{seq: seq, ack: ack, flags: flagPSH | flagACK, payload: make([]byte, 10)})
+	seq, ack = serverFlow.next(20)
+	require.Equal(t, uint32(5001), seq)
+	require.Equal(t, uint32(111), ack)
+	clientFlow.update(segment{seq: seq, ack: ack, flags: flagPSH | flagACK, payload: make([]byte, 20)})
+	require.Equal(t, uint32(5021), clientFlow.ack)
+	// a stale acknowledgement does not move the sequence number backwards
+	clientFlow.next(30)
+	clientFlow.update(segment{seq: 5021, ack: 111, flags: flagACK})
+	require.Equal(t, uint32(141), clientFlow.seq)
+	// out of order data does not move the acknowledgement number
+	clientFlow.update(segment{seq: 6000, ack: 141, flags: flagPSH | flagACK, payload: make([]byte, 10)})
+	require.Equal(t, uint32(5021), clientFlow.ack)
+}
@@ -0,0 +1,299 @@
+package faketcp
+
+import (
+	"io"
+	"net"
+	"net/netip"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing/common/buf"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+	"github.com/sagernet/sing/common/logger"
+	M "github.com/sagernet/sing/common/metadata"
+	"github.com/sagernet/sing/common/pipe"
+)
+
+// flowTimeout closes fake connections without segments from the client, the
+// FIN of the real connection never arrives since it is sent with TTL 1.
+const flowTimeout = time.Minute
+
+var _ net.PacketConn = (*serverConn)(nil)
+
+type serverConn struct {
+	listener     net.Listener
+	logger       logger.ContextLogger
+	localAddr    netip.AddrPort
+	rawConn4     *net.IPConn
+	rawConn6     *net.IPConn
+	dropRules    []*dropRule
+	packets      chan *serverPacket
+	readDeadline pipe.Deadline
+	done         chan struct{}
+	closeOnce    sync.Once
+	err          error
+	access       sync.Mutex
+	flows        map[netip.AddrPort]*serverFlow
+}
+
+type serverFlow struct {
+	flow
+	conn      net.Conn
+	localAddr netip.AddrPort
+	lastSeen  time.Time
+}
+
+type serverPacket struct {
+	buffer *buf.Buffer
+	source netip.AddrPort
+}
+
+// Listen accepts real TCP connections from listener and exchanges packets as
+// segments of them through raw sockets.
+func Listen(listener net.Listener, logger logger.ContextLogger) (net.PacketConn, error) {
+	conn := &serverConn{
+		listener:     listener,
+		logger:       logger,
+		localAddr:    M.AddrPortFromNet(listener.Addr()),
+		packets:      make(chan *serverPacket, 64),
+		readDeadline: pipe.MakeDeadline(),
+		done:         make(chan struct{}),
+		flows:        make(map[netip.AddrPort]*serverFlow),
+	}
+	listenAddr := conn.localAddr.Addr()
+	var err4, err6 error
+	if listenAddr.Is4() || listenAddr.Is4In6() || listenAddr.IsUnspecified() {
+		conn.rawConn4, err4 = listenRaw(listenAddr.Unmap(), false)
+	}
+	if listenAddr.Is6() && !listenAddr.Is4In6() {
+		conn.rawConn6, err6 = listenRaw(listenAddr, true)
+	}
+	if conn.rawConn4 == nil && conn.rawConn6 == nil {
+		return nil, E.Cause(E.Errors(err4, err6), "open raw socket")
+	}
+	for _, rawConn := range []*net.IPConn{conn.rawConn4, conn.rawConn6} {
+		if rawConn == nil {
+			continue
+		}
+		rule, err := installDropRule(rawConn == conn.rawConn6, "--sport", F.ToString(conn.localAddr.Port()))
+		if err != nil {
+			logger.Warn(E.Cause(err, "faketcp: install drop rule"))
+		} else {
+			conn.dropRules = append(conn.dropRules, rule)
+		}
+		go conn.loopRead(rawConn)
+	}
+	go conn.loopAccept()
+	go conn.loopClean()
+	return conn, nil
+}
+
+func (c *serverConn) loopAccept() {
+	for {
+		conn, err := c.listener.Accept()
+		if err != nil {
+			//nolint:staticcheck
+			if netError, isNetError := err.(net.Error); isNetError && netError.Temporary() {
+				c.logger.Error(err)
+				continue
+			}
+			c.closeWithError(err)
+			return
+		}
+		err = setTTL(conn)
+		if err != nil {
+			c.logger.Error(E.Cause(err, "faketcp: set TTL"))
+			conn.Close()
+			continue
+		}
+		source := unmapAddrPort(conn.RemoteAddr())
+		c.access.Lock()
+		currentFlow := c.loadFlow(source)
+		if currentFlow.conn != nil {
+			currentFlow.conn.Close()
+		}
+		currentFlow.conn = conn
+		currentFlow.localAddr = unmapAddrPort(conn.LocalAddr())
+		c.access.Unlock()
+		go c.discard(source, conn)
+	}
+}
+
+// discard drains the real connection, the data of the fake connection is
+// also delivered to it.
+func (c *serverConn) discard(source netip.AddrPort, conn net.Conn) {
+	io.Copy(io.Discard, conn)
+	c.access.Lock()
+	if currentFlow := c.flows[source]; currentFlow != nil && currentFlow.conn == conn {
+		delete(c.flows, source)
+	}
+	c.access.Unlock()
+	conn.Close()
+}
+
+func (c *serverConn) loadFlow(source netip.AddrPort) *serverFlow {
+	currentFlow := c.flows[source]
+	if currentFlow == nil {
+		currentFlow = &serverFlow{}
+		c.flows[source] = currentFlow
+	}
+	currentFlow.lastSeen = time.Now()
+	return currentFlow
+}
+
+func (c *serverConn) loopRead(rawConn *net.IPConn) {
+	for {
+		buffer := buf.NewPacket()
+		n, addr, err := rawConn.ReadFromIP(buffer.FreeBytes())
+		if err != nil {
+			buffer.Release()
+			c.closeWithError(err)
+			return
+		}
+		tcpSegment, loaded := parseSegment(buffer.FreeBytes()[:n])
+		if !loaded || tcpSegment.destinationPort != c.localAddr.Port() {
+			buffer.Release()
+			continue
+		}
+		sourceAddr, _ := netip.AddrFromSlice(addr.IP)
+		source := netip.AddrPortFrom(sourceAddr.Unmap(), tcpSegment.sourcePort)
+		var established bool
+		c.access.Lock()
+		currentFlow := c.flows[source]
+		if currentFlow == nil && tcpSegment.flags&flagSYN != 0 {
+			currentFlow = c.loadFlow(source)
+		}
+		if currentFlow != nil {
+			currentFlow.update(tcpSegment)
+			currentFlow.lastSeen = time.Now()
+			established = currentFlow.conn != nil
+		}
+		c.access.Unlock()
+		if !established || tcpSegment.flags&flagPSH == 0 || len(tcpSegment.payload) == 0 {
+			buffer.Release()
+			continue
+		}
+		buffer.Resize(n-len(tcpSegment.payload), len(tcpSegment.payload))
+		select {
+		case c.packets <- &serverPacket{buffer, source}:
+		case <-c.done:
+			buffer.Release()
+			return
+		}
+	}
+}
+
+func (c *serverConn) loopClean() {
+	ticker := time.NewTicker(flowTimeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-c.done:
+			return
+		}
+		c.access.Lock()
+		for source, currentFlow := range c.flows {
+			if time.Since(currentFlow.lastSeen) > flowTimeout {
+				delete(c.flows, source)
+				if currentFlow.conn != nil {
+					currentFlow.conn.Close()
+				}
+			}
+		}
+		c.access.Unlock()
+	}
+}
+
+func (c *serverConn) ReadFrom(p []byte) (n int, addr net.Addr, err error) {
+	select {
+	case packet := <-c.packets:
+		defer packet.buffer.Release()
+		if packet.buffer.Len() > len(p) {
+			return 0, nil, io.ErrShortBuffer
+		}
+		return copy(p, packet.buffer.Bytes()), M.SocksaddrFromNetIP(packet.source).UDPAddr(), nil
+	case <-c.done:
+		return 0, nil, c.err
+	case <-c.readDeadline.Wait():
+		return 0, nil, os.ErrDeadlineExceeded
+	}
+}
+
+// WriteTo drops packets to unknown clients like a lost datagram.
+func (c *serverConn) WriteTo(p []byte, addr net.Addr) (n int, err error) {
+	destination := unmapAddrPort(addr)
+	c.access.Lock()
+	currentFlow := c.flows[destination]
+	if currentFlow == nil || currentFlow.conn == nil {
+		c.access.Unlock()
+		return len(p), nil
+	}
+	seq, ack := currentFlow.next(len(p))
+	localAddr := currentFlow.localAddr
+	c.access.Unlock()
+	rawConn := c.rawConn4
+	if !destination.Addr().Is4() {
+		rawConn = c.rawConn6
+	}
+	if rawConn == nil {
+		return 0, E.New("faketcp: raw socket unavailable for ", destination.Addr())
+	}
+	err = writeSegment(rawConn, localAddr, destination, seq, ack, p)
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (c *serverConn) closeWithError(err error) {
+	c.closeOnce.Do(func() {
+		c.err = err
+		close(c.done)
+		c.listener.Close()
+		closeRaw(c.rawConn4)
+		closeRaw(c.rawConn6)
+		for _, rule := range c.dropRules {
+			rule.Close()
+		}
+		c.access.Lock()
+		for source, currentFlow := range c.flows {
+			delete(c.flows, source)
+			if currentFlow.conn != nil {
+				currentFlow.conn.Close()
+			}
+		}
+		c.access.Unlock()
+	})
+}
+
+func (c *serverConn) Close() error {
+	c.closeWithError(net.ErrClosed)
+	return nil
+}
+
+func (c *serverConn) LocalAddr() net.Addr {
+	return M.SocksaddrFromNetIP(c.localAddr).UDPAddr()
+}
+
+func (c *serverConn) SetDeadline(t time.Time) error {
+	c.readDeadline.Set(t)
+	return c.SetWriteDeadline(t)
+}
+
+func (c *serverConn) SetReadDeadline(t time.Time) error {
+	c.readDeadline.Set(t)
+	return nil
+}
+
+func (c *serverConn) SetWriteDeadline(t time.Time) error {
+	var errors []error
+	for _, rawConn := range []*net.IPConn{c.rawConn4, c.rawConn6} {
+		if rawConn != nil {
+			errors = append(errors, rawConn.SetWriteDeadline(t))
+		}
+	}
+	return E.Errors(errors...)
+}
@@ -0,0 +1,157 @@
+package shadowsocksr
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/rc4"
+	"io"
+	"net"
+
+	"github.com/sagernet/sing-shadowsocks"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+
+	"golang.org/x/crypto/chacha20"
+)
+
+var MethodList = []string{
+	"none",
+	"aes-128-ctr",
+	"aes-192-ctr",
+	"aes-256-ctr",
+	"aes-128-cfb",
+	"aes-192-cfb",
+	"aes-256-cfb",
+	"rc4-md5",
+	"chacha20-ietf",
+}
+
+type streamMethod struct {
+	keyLength          int
+	ivLength           int
+	encryptConstructor func(key []byte, iv []byte) (cipher.Stream, error)
+	decryptConstructor func(key []byte, iv []byte) (cipher.Stream, error)
+}
+
+func newStreamMethod(method string) (*streamMethod, error) {
+	switch method {
+	case "none":
+		return &streamMethod{keyLength: 16}, nil
+	case "aes-128-ctr":
+		return &streamMethod{16, aes.BlockSize, blockStream(cipher.NewCTR), blockStream(cipher.NewCTR)}, nil
+	case "aes-192-ctr":
+		return &streamMethod{24, aes.BlockSize, blockStream(cipher.NewCTR), blockStream(cipher.NewCTR)}, nil
+	case "aes-256-ctr":
+		return &streamMethod{32, aes.BlockSize, blockStream(cipher.NewCTR), blockStream(cipher.NewCTR)}, nil
+	case "aes-128-cfb":
+		return &streamMethod{16, aes.BlockSize, blockStream(cipher.NewCFBEncrypter), blockStream(cipher.NewCFBDecrypter)}, nil
+	case "aes-192-cfb":
+		return &streamMethod{24, aes.BlockSize, blockStream(cipher.NewCFBEncrypter), blockStream(cipher.NewCFBDecrypter)}, nil
+	case "aes-256-cfb":
+		return &streamMethod{32, aes.BlockSize, blockStream(cipher.NewCFBEncrypter), blockStream(cipher.NewCFBDecrypter)}, nil
+	case "rc4-md5":
+		return &streamMethod{16, 16, rc4MD5Stream, rc4MD5Stream}, nil
+	case "chacha20-ietf":
+		return &streamMethod{chacha20.KeySize, chacha20.NonceSize, chacha20Stream, chacha20Stream}, nil
+	default:
+		return nil, E.New("unsupported method: ", method)
+	}
+}
+
+func blockStream(streamCreator func(block cipher.Block, iv []byte) cipher.Stream) func([]byte, []byte) (cipher.Stream, error) {
+	return func(key []byte, iv []byte) (cipher.Stream, error) {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		return streamCreator(block, iv), nil
+	}
+}
+
+func rc4MD5Stream(key []byte, iv []byte) (cipher.Stream, error) {
+	h := md5.New()
+	h.Write(key)
+	h.Write(iv)
+	return rc4.NewCipher(h.Sum(nil))
+}
+
+func chacha20Stream(key []byte, iv []byte) (cipher.Stream, error) {
+	return chacha20.NewUnauthenticatedCipher(key, iv)
+}
+
+// kdf is EVP_BytesToKey with MD5, as used by the original implementation.
+func kdf(password string, keyLength int) []byte {
+	return shadowsocks.Key([]byte(password), keyLength)
+}
+
+// streamConn is a legacy shadowsocks stream cipher connection whose write IV
+// is known before the first write, since protocols authenticate with it.
+type streamConn struct {
+	net.Conn
+	method      *streamMethod
+	key         []byte
+	writeIV     []byte
+	writeStream cipher.Stream
+	readStream  cipher.Stream
+}
+
+func newStreamConn(conn net.Conn, method *streamMethod, key []byte) *streamConn {
+	writeIV := make([]byte, method.ivLength)
+	common.Must1(io.ReadFull(rand.Reader, writeIV))
+	return &streamConn{
+		Conn:    conn,
+		method:  method,
+		key:     key,
+		writeIV: writeIV,
+	}
+}
+
+func (c *streamConn) Read(p []byte) (n int, err error) {
+	if c.method.decryptConstructor == nil {
+		return c.Conn.Read(p)
+	}
+	if c.readStream == nil {
+		readIV := make([]byte, c.method.ivLength)
+		_, err = io.ReadFull(c.Conn, readIV)
+		if err != nil {
+			return
+		}
+		c.readStream, err = c.method.decryptConstructor(c.key, readIV)
+		if err != nil {
+			return
+		}
+	}
+	n, err = c.Conn.Read(p)
+	c.readStream.XORKeyStream(p[:n], p[:n])
+	return
+}
+
+func (c *streamConn) Write(p []byte) (n int, err error) {
+	if c.method.encryptConstructor == nil {
+		return c.Conn.Write(p)
+	}
+	var buffer []byte
+	if c.writeStream == nil {
+		c.writeStream, err = c.method.encryptConstructor(c.key, c.writeIV)
+		if err != nil {
+			return
+		}
+		buffer = make([]byte, len(c.writeIV)+len(p))
+		copy(buffer, c.writeIV)
+		c.writeStream.XORKeyStream(buffer[len(c.writeIV):], p)
+	} else {
+		buffer = make([]byte, len(p))
+		c.writeStream.XORKeyStream(buffer, p)
+	}
+	_, err = c.Conn.Write(buffer)
+	if err != nil {
+		return
+	}
+	return len(p), nil
+}
+
+func (c *streamConn) Upstream() any {
+	return c.Conn
+}
@@ -0,0 +1,135 @@
+package shadowsocksr
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// bufferConn records writes and serves reads from reader.
+type bufferConn struct {
+	net.Conn
+	reader  io.Reader
+	written bytes.Buffer
+}
+
+func (c *bufferConn) Read(p []byte) (n int, err error) {
+	return c.reader.Read(p)
+}
+
+func (c *bufferConn) Write(p []byte) (n int, err error) {
+	return c.written.Write(p)
+}
+
+func mustDecodeHex(s string) []byte {
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func TestKDF(t *testing.T) {
+	t.Parallel()
+	require.Equal(t, "5f4dcc3b5aa765d61d8327deb882cf99", hex.EncodeToString(kdf("password", 16)))
+	require.Equal(t, "5f4dcc3b5aa765d61d8327deb882cf992b95990a9151374abd8ff8c5a7a0fe08", hex.EncodeToString(kdf("password", 32)))
+}
+
+func TestStreamMethodVectors(t *testing.T) {
+	t.Parallel()
+	for _, testCase := range []struct {
+		method     string
+		key        string
+		iv         string
+		plaintext  string
+		ciphertext string
+	}{
+		// NIST SP 800-38A F.5.1
+		{
+			"aes-128-ctr",
+			"2b7e151628aed2a6abf7158809cf4f3c",
+			"f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff",
+			"6bc1bee22e409f96e93d7e117393172a",
+			"874d6191b620e3261bef6864990db6ce",
+		},
+		// NIST SP 800-38A F.3.13
+		{
+			"aes-128-cfb",
+			"2b7e151628aed2a6abf7158809cf4f3c",
+			"000102030405060708090a0b0c0d0e0f",
+			"6bc1bee22e409f96e93d7e117393172a",
+			"3b3fd92eb72dad20333449f8e83cfb4a",
+		},
+		// RFC 8439 A.1 #1
+		{
+			"chacha20-ietf",
+			"0000000000000000000000000000000000000000000000000000000000000000",
+			"000000000000000000000000",
+			"00000000000000000000000000000000",
+			"76b8e0ada0f13d90405d6ae55386bd28",
+		},
+		// RC4 with MD5(key + iv)
+		{
+			"rc4-md5",
+			"5f4dcc3b5aa765d61d8327deb882cf99",
+			"000102030405060708090a0b0c0d0e0f",
+			"00000000000000000000000000000000",
+			"c3497a33b771d2d36110f12ea7258a4c",
+		},
+	} {
+		method, err := newStreamMethod(testCase.method)
+		require.NoError(t, err)
+		key := mustDecodeHex(testCase.key)
+		iv := mustDecodeHex(testCase.iv)
+		require.Len(t, key, method.keyLength, testCase.method)
+		require.Len(t, iv, method.ivLength, testCase.method)
+		encrypter, err := method.encryptConstructor(key, iv)
+		require.NoError(t, err)
+		ciphertext := make([]byte, len(testCase.plaintext)/2)
+		encrypter.XORKeyStream(ciphertext, mustDecodeHex(testCase.plaintext))
+		require.Equal(t, testCase.ciphertext, hex.EncodeToString(ciphertext), testCase.method)
+		decrypter, err := method.decryptConstructor(key, iv)
+		require.NoError(t, err)
+		decrypter.XORKeyStream(ciphertext, ciphertext)
+		require.Equal(t, testCase.plaintext, hex.EncodeToString(ciphertext), testCase.method)
+	}
+}
+
+func TestStreamConn(t *testing.T) {
+	t.Parallel()
+	for _, methodName := range MethodList {
+		method, err := newStreamMethod(methodName)
+		require.NoError(t, err)
+		key := kdf("password", method.keyLength)
+		clientConn := &bufferConn{}
+		client := newStreamConn(clientConn, method, key)
+		_, err = client.Write([]byte("hello "))
+		require.NoError(t, err)
+		_, err = client.Write([]byte("world"))
+		require.NoError(t, err)
+		written := clientConn.written.Bytes()
+		require.Len(t, written, method.ivLength+11, methodName)
+		require.Equal(t, client.writeIV, written[:method.ivLength])
+		if methodName != "none" {
+			require.NotContains(t, string(written), "hello")
+		}
+		server := newStreamConn(&bufferConn{reader: bytes.NewReader(written)}, method, key)
+		message, err := io.ReadAll(server)
+		require.NoError(t, err)
+		require.Equal(t, "hello world", string(message), methodName)
+	}
+}
+
+func TestUnsupportedMethod(t *testing.T) {
+	t.Parallel()
+	_, err := newStreamMethod("aes-128-gcm")
+	require.Error(t, err)
+	_, err = NewClient(ClientOptions{Method: "aes-128-ctr", Password: "password", Protocol: "auth_sha1_v4"})
+	require.Error(t, err)
+	_, err = NewClient(ClientOptions{Method: "aes-128-ctr", Password: "password", Obfs: "random_head"})
+	require.Error(t, err)
+}
@@ -0,0 +1,146 @@
+package shadowsocksr
+
+import (
+	"bytes"
+	"net"
+	"sync"
+
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+type ClientOptions struct {
+	Server        M.Socksaddr
+	Method        string
+	Password      string
+	Obfs          string
+	ObfsParam     string
+	Protocol      string
+	ProtocolParam string
+}
+
+// Client is a ShadowsocksR TCP client. Connections are layered as
+// protocol over stream cipher over obfs.
+type Client struct {
+	method              *streamMethod
+	key                 []byte
+	obfsConstructor     obfsConstructor
+	obfsInfo            *obfsInfo
+	protocolConstructor protocolConstructor
+	protocolParam       string
+	overhead            int
+	authData            *authData
+}
+
+func NewClient(options ClientOptions) (*Client, error) {
+	if options.Password == "" {
+		return nil, E.New("missing password")
+	}
+	method, err := newStreamMethod(options.Method)
+	if err != nil {
+		return nil, err
+	}
+	obfsConstructor, obfsOverhead, err := newObfs(options.Obfs)
+	if err != nil {
+		return nil, err
+	}
+	protocolConstructor, protocolOverhead, err := newProtocol(options.Protocol)
+	if err != nil {
+		return nil, err
+	}
+	key := kdf(options.Password, method.keyLength)
+	return &Client{
+		method:          method,
+		key:             key,
+		obfsConstructor: obfsConstructor,
+		obfsInfo: &obfsInfo{
+			host:     options.Server.AddrString(),
+			port:     options.Server.Port,
+			param:    options.ObfsParam,
+			key:      key,
+			ivLength: method.ivLength,
+			tlsData:  newTLSData(),
+		},
+		protocolConstructor: protocolConstructor,
+		protocolParam:       options.ProtocolParam,
+		overhead:            obfsOverhead + protocolOverhead,
+		authData:            &authData{},
+	}, nil
+}
+
+func (c *Client) DialEarlyConn(conn net.Conn, destination M.Socksaddr) net.Conn {
+	cipherConn := newStreamConn(c.obfsConstructor(conn, c.obfsInfo), c.method, c.key)
+	return &clientConn{
+		Conn: c.protocolConstructor(cipherConn, &protocolInfo{
+			key:      c.key,
+			iv:       cipherConn.writeIV,
+			overhead: c.overhead,
+			param:    c.protocolParam,
+			authData: c.authData,
+		}),
+		destination:    destination,
+		requestWritten: make(chan struct{}),
+		done:           make(chan struct{}),
+	}
+}
+
+var _ N.EarlyConn = (*clientConn)(nil)
+
+// clientConn sends the destination with the first payload. Protocols keep
+// their state in the request, so reads wait until it is written.
+type clientConn struct {
+	net.Conn
+	destination    M.Socksaddr
+	headerWritten  bool
+	requestWritten chan struct{}
+	done           chan struct{}
+	closeOnce      sync.Once
+}
+
+func (c *clientConn) NeedHandshake() bool {
+	return !c.headerWritten
+}
+
+func (c *clientConn) Write(p []byte) (n int, err error) {
+	if c.headerWritten {
+		return c.Conn.Write(p)
+	}
+	var buffer bytes.Buffer
+	err = M.SocksaddrSerializer.WriteAddrPort(&buffer, c.destination)
+	if err != nil {
+		return
+	}
+	buffer.Write(p)
+	_, err = c.Conn.Write(buffer.Bytes())
+	if err != nil {
+		return
+	}
+	c.headerWritten = true
+	close(c.requestWritten)
+	return len(p), nil
+}
+
+func (c *clientConn) Read(p []byte) (n int, err error) {
+	select {
+	case <-c.requestWritten:
+	case <-c.done:
+		return 0, net.ErrClosed
+	}
+	return c.Conn.Read(p)
+}
+
+func (c *clientConn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.done)
+	})
+	return c.Conn.Close()
+}
+
+func (c *clientConn) NeedAdditionalReadDeadline() bool {
+	return true
+}
+
+func (c *clientConn) Upstream() any {
+	return c.Conn
+}
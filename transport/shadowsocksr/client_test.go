@@ -0,0 +1,51 @@
+package shadowsocksr
+
+import (
+	"io"
+	"net"
+	"testing"
+
+	M "github.com/sagernet/sing/common/metadata"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient(t *testing.T) {
+	t.Parallel()
+	client, err := NewClient(ClientOptions{
+		Server:   M.ParseSocksaddr("127.0.0.1:8388"),
+		Method:   "aes-256-cfb",
+		Password: "password",
+	})
+	require.NoError(t, err)
+	clientPipe, serverPipe := net.Pipe()
+	defer clientPipe.Close()
+	serverDone := make(chan error, 1)
+	go func() {
+		defer serverPipe.Close()
+		serverConn := newStreamConn(serverPipe, client.method, kdf("password", 32))
+		destination, err := M.SocksaddrSerializer.ReadAddrPort(serverConn)
+		if err != nil {
+			serverDone <- err
+			return
+		}
+		if destination.String() != "example.org:443" {
+			serverDone <- io.ErrUnexpectedEOF
+			return
+		}
+		message := make([]byte, 5)
+		_, err = io.ReadFull(serverConn, message)
+		if err == nil {
+			_, err = serverConn.Write(message)
+		}
+		serverDone <- err
+	}()
+	conn := client.DialEarlyConn(clientPipe, M.ParseSocksaddr("example.org:443"))
+	_, err = conn.Write([]byte("hello"))
+	require.NoError(t, err)
+	message := make([]byte, 5)
+	_, err = io.ReadFull(conn, message)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(message))
+	require.NoError(t, <-serverDone)
+}
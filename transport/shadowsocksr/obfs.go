@@ -0,0 +1,176 @@
+package shadowsocksr
+
+import (
+	"bytes"
+	"encoding/hex"
+	mRand "math/rand"
+	"net"
+	"strconv"
+	"strings"
+
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+var ObfsList = []string{
+	"plain",
+	"http_simple",
+	"http_post",
+	"tls1.2_ticket_auth",
+}
+
+type obfsInfo struct {
+	host     string
+	port     uint16
+	param    string
+	key      []byte
+	ivLength int
+	tlsData  *tlsData
+}
+
+type obfsConstructor func(conn net.Conn, info *obfsInfo) net.Conn
+
+func newObfs(obfs string) (constructor obfsConstructor, overhead int, err error) {
+	switch obfs {
+	case "", "plain":
+		return func(conn net.Conn, info *obfsInfo) net.Conn {
+			return conn
+		}, 0, nil
+	case "http_simple":
+		return func(conn net.Conn, info *obfsInfo) net.Conn {
+			return &httpConn{Conn: conn, info: info}
+		}, 0, nil
+	case "http_post":
+		return func(conn net.Conn, info *obfsInfo) net.Conn {
+			return &httpConn{Conn: conn, info: info, post: true}
+		}, 0, nil
+	case "tls1.2_ticket_auth":
+		return newTLSConn, tlsOverhead, nil
+	default:
+		return nil, 0, E.New("unsupported obfs: ", obfs)
+	}
+}
+
+var httpUserAgents = []string{
+	"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+	"Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:121.0) Gecko/20100101 Firefox/121.0",
+	"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Safari/605.1.15",
+	"Mozilla/5.0 (Linux; Android 14) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36",
+}
+
+// httpConn sends the head of the first payload URL-encoded in a fake HTTP
+// request, and strips the header of the fake HTTP response.
+type httpConn struct {
+	net.Conn
+	info           *obfsInfo
+	post           bool
+	requestWritten bool
+	responseRead   bool
+	readBuffer     []byte
+}
+
+func (c *httpConn) Write(p []byte) (n int, err error) {
+	if c.requestWritten {
+		return c.Conn.Write(p)
+	}
+	headLength := c.info.ivLength + 30
+	if len(p)-headLength > 64 {
+		headLength += mRand.Intn(65)
+	} else {
+		headLength = len(p)
+	}
+	host := c.info.host
+	var body string
+	if c.info.param != "" {
+		host = c.info.param
+		if hostList, customHeader, loaded := strings.Cut(host, "#"); loaded {
+			host = hostList
+			body = strings.ReplaceAll(strings.ReplaceAll(customHeader, "\n", "\r\n"), "\\n", "\r\n")
+		}
+	}
+	hosts := strings.Split(host, ",")
+	host = hosts[mRand.Intn(len(hosts))]
+	if c.info.port != 80 {
+		host = net.JoinHostPort(host, strconv.Itoa(int(c.info.port)))
+	}
+
+	var request bytes.Buffer
+	if c.post {
+		request.WriteString("POST /")
+	} else {
+		request.WriteString("GET /")
+	}
+	encoded := hex.EncodeToString(p[:headLength])
+	for i := 0; i < len(encoded); i += 2 {
+		request.WriteByte('%')
+		request.WriteString(encoded[i : i+2])
+	}
+	request.WriteString(" HTTP/1.1\r\nHost: ")
+	request.WriteString(host)
+	request.WriteString("\r\n")
+	if body != "" {
+		request.WriteString(body)
+		request.WriteString("\r\n\r\n")
+	} else {
+		request.WriteString("User-Agent: ")
+		request.WriteString(httpUserAgents[mRand.Intn(len(httpUserAgents))])
+		request.WriteString("\r\nAccept: text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8\r\nAccept-Language: en-US,en;q=0.8\r\nAccept-Encoding: gzip, deflate\r\n")
+		if c.post {
+			request.WriteString("Content-Type: multipart/form-data; boundary=")
+			request.WriteString(httpBoundary())
+			request.WriteString("\r\n")
+		}
+		request.WriteString("DNT: 1\r\nConnection: keep-alive\r\n\r\n")
+	}
+	request.Write(p[headLength:])
+	_, err = c.Conn.Write(request.Bytes())
+	if err != nil {
+		return
+	}
+	c.requestWritten = true
+	return len(p), nil
+}
+
+func httpBoundary() string {
+	const set = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+	boundary := make([]byte, 32)
+	for i := range boundary {
+		boundary[i] = set[mRand.Intn(len(set))]
+	}
+	return string(boundary)
+}
+
+func (c *httpConn) Read(p []byte) (n int, err error) {
+	if len(c.readBuffer) > 0 && c.responseRead {
+		n = copy(p, c.readBuffer)
+		c.readBuffer = c.readBuffer[n:]
+		return
+	}
+	if c.responseRead {
+		return c.Conn.Read(p)
+	}
+	buffer := make([]byte, 8192)
+	for {
+		n, err = c.Conn.Read(buffer)
+		c.readBuffer = append(c.readBuffer, buffer[:n]...)
+		if index := bytes.Index(c.readBuffer, []byte("\r\n\r\n")); index >= 0 {
+			c.responseRead = true
+			c.readBuffer = c.readBuffer[index+4:]
+			if len(c.readBuffer) == 0 && err == nil {
+				return c.Conn.Read(p)
+			}
+			n = copy(p, c.readBuffer)
+			c.readBuffer = c.readBuffer[n:]
+			return
+		}
+		if err != nil {
+			return 0, err
+		}
+		if len(c.readBuffer) > 8192 {
+			return 0, E.New("http obfs: response header too large")
+		}
+	}
+}
+
+func (c *httpConn) Upstream() any {
+	return c.Conn
+}
@@ -0,0 +1,149 @@
+package shadowsocksr
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestObfsInfo(param string) *obfsInfo {
+	return &obfsInfo{
+		host:     "example.org",
+		port:     8080,
+		param:    param,
+		key:      kdf("password", 16),
+		ivLength: 16,
+		tlsData:  newTLSData(),
+	}
+}
+
+func TestHTTPSimple(t *testing.T) {
+	t.Parallel()
+	for _, post := range []bool{false, true} {
+		clientConn := &bufferConn{}
+		conn := &httpConn{Conn: clientConn, info: newTestObfsInfo("a.example.org,b.example.org"), post: post}
+		payload := testPayload(200)
+		_, err := conn.Write(payload)
+		require.NoError(t, err)
+		_, err = conn.Write([]byte("next"))
+		require.NoError(t, err)
+		reader := bufio.NewReader(&clientConn.written)
+		request, err := http.ReadRequest(reader)
+		require.NoError(t, err)
+		if post {
+			require.Equal(t, http.MethodPost, request.Method)
+			require.True(t, strings.HasPrefix(request.Header.Get("Content-Type"), "multipart/form-data; boundary="))
+		} else {
+			require.Equal(t, http.MethodGet, request.Method)
+		}
+		require.Contains(t, []string{"a.example.org:8080", "b.example.org:8080"}, request.Host)
+		head, err := url.PathUnescape(request.URL.RawPath[1:])
+		require.NoError(t, err)
+		body, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		require.Equal(t, append(payload, "next"...), append([]byte(head), body...))
+	}
+}
+
+func TestHTTPSimpleCustomHeader(t *testing.T) {
+	t.Parallel()
+	clientConn := &bufferConn{}
+	info := newTestObfsInfo("example.com#User-Agent: test\\nX-Test: 1")
+	info.port = 80
+	conn := &httpConn{Conn: clientConn, info: info}
+	_, err := conn.Write([]byte("hello"))
+	require.NoError(t, err)
+	request, err := http.ReadRequest(bufio.NewReader(&clientConn.written))
+	require.NoError(t, err)
+	require.Equal(t, "example.com", request.Host)
+	require.Equal(t, "test", request.UserAgent())
+	require.Equal(t, "1", request.Header.Get("X-Test"))
+	require.Equal(t, "/%68%65%6c%6c%6f", request.URL.RawPath)
+}
+
+func TestHTTPSimpleResponse(t *testing.T) {
+	t.Parallel()
+	response := "HTTP/1.1 200 OK\r\nConnection: keep-alive\r\n\r\nhello world"
+	conn := &httpConn{Conn: &bufferConn{reader: iotest.OneByteReader(strings.NewReader(response))}, info: newTestObfsInfo("")}
+	message, err := io.ReadAll(conn)
+	require.NoError(t, err)
+	require.Equal(t, "hello world", string(message))
+
+	conn = &httpConn{Conn: &bufferConn{reader: strings.NewReader(strings.Repeat("a", 20000))}, info: newTestObfsInfo("")}
+	_, err = io.ReadAll(conn)
+	require.ErrorContains(t, err, "too large")
+}
+
+// tlsServerHello builds the fake server hello of the original server.
+func tlsServerHello(info *obfsInfo) []byte {
+	macKey := append(append([]byte(nil), info.key...), info.tlsData.clientID[:]...)
+	authData := make([]byte, 22)
+	authData = append(authData, hmacSum(sha1.New, macKey, authData)[:10]...)
+	hello := append([]byte{0x03, 0x03}, authData...)
+	hello = append(hello, 0x20)
+	hello = append(hello, info.tlsData.clientID[:]...)
+	hello = append(hello, mustDecodeHex("c02f000005ff01000100")...)
+	hello = append([]byte{0x02, 0x00, byte(len(hello) >> 8), byte(len(hello))}, hello...)
+	data := append([]byte{tlsRecordHandshake, 0x03, 0x03, byte(len(hello) >> 8), byte(len(hello))}, hello...)
+	data = append(data, tlsRecordChangeCipherSpec, 0x03, 0x03, 0x00, 0x01, 0x01)
+	data = append(data, tlsRecordHandshake, 0x03, 0x03, 0x00, 0x20)
+	data = append(data, make([]byte, 22)...)
+	return append(data, hmacSum(sha1.New, macKey, data)[:10]...)
+}
+
+func TestTLSTicketAuth(t *testing.T) {
+	t.Parallel()
+	info := newTestObfsInfo("cloudflare.com")
+	macKey := append(append([]byte(nil), info.key...), info.tlsData.clientID[:]...)
+	serverHello := tlsServerHello(info)
+	response := append(append([]byte(nil), serverHello...), tlsRecordApplicationData, 0x03, 0x03, 0x00, 0x05)
+	response = append(response, "hello"...)
+	clientConn := &bufferConn{reader: bytes.NewReader(response)}
+	conn := newTLSConn(clientConn, info)
+
+	_, err := conn.Write([]byte("request"))
+	require.NoError(t, err)
+	clientHello := clientConn.written.Bytes()
+	require.Equal(t, []byte{tlsRecordHandshake, 0x03, 0x01}, clientHello[:3])
+	require.Equal(t, len(clientHello)-5, int(binary.BigEndian.Uint16(clientHello[3:])))
+	require.Equal(t, byte(0x01), clientHello[5])
+	require.True(t, hmac.Equal(hmacSum(sha1.New, macKey, clientHello[11:33])[:10], clientHello[33:43]))
+	require.Equal(t, byte(32), clientHello[43])
+	require.Equal(t, info.tlsData.clientID[:], clientHello[44:76])
+	require.Contains(t, string(clientHello), "\x00\x0ecloudflare.com")
+	clientConn.written.Reset()
+
+	message := make([]byte, 1024)
+	n, err := conn.Read(message)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(message[:n]))
+
+	// the fake finished message and the buffered request follow the server hello
+	finished := clientConn.written.Bytes()
+	require.Equal(t, []byte{tlsRecordChangeCipherSpec, 0x03, 0x03, 0x00, 0x01, 0x01, tlsRecordHandshake, 0x03, 0x03, 0x00, 0x20}, finished[:11])
+	require.True(t, hmac.Equal(hmacSum(sha1.New, macKey, finished[:33])[:10], finished[33:43]))
+	require.Equal(t, []byte{tlsRecordApplicationData, 0x03, 0x03, 0x00, 0x07}, finished[43:48])
+	require.Equal(t, "request", string(finished[48:]))
+}
+
+func TestTLSTicketAuthBadServerHello(t *testing.T) {
+	t.Parallel()
+	info := newTestObfsInfo("")
+	serverHello := tlsServerHello(info)
+	serverHello[len(serverHello)-1] ^= 0xff
+	conn := newTLSConn(&bufferConn{reader: bytes.NewReader(serverHello)}, info)
+	_, err := conn.Write([]byte("request"))
+	require.NoError(t, err)
+	_, err = conn.Read(make([]byte, 1024))
+	require.ErrorContains(t, err, "bad server finished checksum")
+}
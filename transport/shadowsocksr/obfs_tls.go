@@ -0,0 +1,280 @@
+package shadowsocksr
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	mRand "math/rand"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+const (
+	tlsOverhead = 5
+
+	tlsRecordHandshake        = 0x16
+	tlsRecordChangeCipherSpec = 0x14
+	tlsRecordApplicationData  = 0x17
+)
+
+var (
+	tlsVersion           = []byte{0x03, 0x03}
+	tlsClientHelloSuffix = common.Must1(hex.DecodeString("001cc02bc02fcca9cca8cc14cc13c00ac014c009c013009c0035002f000a0100"))
+	tlsExtensionsSuffix  = common.Must1(hex.DecodeString("000d001600140601060305010503040104030301030302010203" + "000500050100000000" + "00120000" + "75500000" + "000b00020100" + "000a0006000400170018"))
+)
+
+// tlsData is the fake session ID shared by all connections of a client.
+type tlsData struct {
+	clientID [32]byte
+}
+
+func newTLSData() *tlsData {
+	var data tlsData
+	common.Must1(io.ReadFull(rand.Reader, data.clientID[:]))
+	return &data
+}
+
+const (
+	tlsHandshakeInit = iota
+	tlsHandshakeSent
+	tlsHandshakeDone
+)
+
+// tlsConn imitates a TLS 1.2 session resumption: payloads written before the
+// server hello are buffered, and sent after the fake client finished message.
+type tlsConn struct {
+	net.Conn
+	info            *obfsInfo
+	access          sync.Mutex
+	handshakeStatus int
+	sendBuffer      bytes.Buffer
+	readBuffer      []byte
+	decoded         []byte
+}
+
+func newTLSConn(conn net.Conn, info *obfsInfo) net.Conn {
+	return &tlsConn{Conn: conn, info: info}
+}
+
+func (c *tlsConn) Write(p []byte) (n int, err error) {
+	c.access.Lock()
+	if c.handshakeStatus == tlsHandshakeDone {
+		c.access.Unlock()
+		var buffer bytes.Buffer
+		data := p
+		for len(data) > 2048 {
+			size := common.Min(mRand.Intn(4096)+100, len(data))
+			writeTLSRecord(&buffer, tlsRecordApplicationData, data[:size])
+			data = data[size:]
+		}
+		if len(data) > 0 {
+			writeTLSRecord(&buffer, tlsRecordApplicationData, data)
+		}
+		_, err = c.Conn.Write(buffer.Bytes())
+		if err != nil {
+			return
+		}
+		return len(p), nil
+	}
+	defer c.access.Unlock()
+	if len(p) > 0 {
+		writeTLSRecord(&c.sendBuffer, tlsRecordApplicationData, p)
+	}
+	if c.handshakeStatus == tlsHandshakeInit {
+		_, err = c.Conn.Write(c.clientHello())
+		if err != nil {
+			return
+		}
+		c.handshakeStatus = tlsHandshakeSent
+	}
+	return len(p), nil
+}
+
+func writeTLSRecord(buffer *bytes.Buffer, recordType byte, data []byte) {
+	buffer.WriteByte(recordType)
+	buffer.Write(tlsVersion)
+	common.Must(binary.Write(buffer, binary.BigEndian, uint16(len(data))))
+	buffer.Write(data)
+}
+
+func (c *tlsConn) hmac(data ...[]byte) []byte {
+	return hmacSum(sha1.New, append(append([]byte(nil), c.info.key...), c.info.tlsData.clientID[:]...), data...)[:10]
+}
+
+func (c *tlsConn) authData() []byte {
+	data := make([]byte, 22, 32)
+	binary.BigEndian.PutUint32(data, uint32(time.Now().Unix()))
+	common.Must1(io.ReadFull(rand.Reader, data[4:]))
+	return append(data, c.hmac(data)...)
+}
+
+func (c *tlsConn) clientHello() []byte {
+	host := c.info.param
+	if host == "" {
+		host = c.info.host
+	}
+	if host != "" && host[len(host)-1] >= '0' && host[len(host)-1] <= '9' {
+		host = ""
+	}
+	hosts := strings.Split(host, ",")
+	host = hosts[mRand.Intn(len(hosts))]
+
+	var extensions bytes.Buffer
+	extensions.Write([]byte{0xff, 0x01, 0x00, 0x01, 0x00})
+	// server name
+	extensions.Write([]byte{0x00, 0x00})
+	common.Must(binary.Write(&extensions, binary.BigEndian, uint16(len(host)+5)))
+	common.Must(binary.Write(&extensions, binary.BigEndian, uint16(len(host)+3)))
+	extensions.WriteByte(0x00)
+	common.Must(binary.Write(&extensions, binary.BigEndian, uint16(len(host))))
+	extensions.WriteString(host)
+	// extended master secret
+	extensions.Write([]byte{0x00, 0x17, 0x00, 0x00})
+	// session ticket
+	ticket := make([]byte, (mRand.Intn(17)+8)*16)
+	common.Must1(io.ReadFull(rand.Reader, ticket))
+	extensions.Write([]byte{0x00, 0x23})
+	common.Must(binary.Write(&extensions, binary.BigEndian, uint16(len(ticket))))
+	extensions.Write(ticket)
+	extensions.Write(tlsExtensionsSuffix)
+
+	var hello bytes.Buffer
+	hello.Write(tlsVersion)
+	hello.Write(c.authData())
+	hello.WriteByte(32)
+	hello.Write(c.info.tlsData.clientID[:])
+	hello.Write(tlsClientHelloSuffix)
+	common.Must(binary.Write(&hello, binary.BigEndian, uint16(extensions.Len())))
+	hello.Write(extensions.Bytes())
+
+	var buffer bytes.Buffer
+	buffer.Write([]byte{tlsRecordHandshake, 0x03, 0x01})
+	common.Must(binary.Write(&buffer, binary.BigEndian, uint16(hello.Len()+4)))
+	buffer.Write([]byte{0x01, 0x00})
+	common.Must(binary.Write(&buffer, binary.BigEndian, uint16(hello.Len())))
+	buffer.Write(hello.Bytes())
+	return buffer.Bytes()
+}
+
+func (c *tlsConn) Read(p []byte) (n int, err error) {
+	if len(c.decoded) > 0 {
+		n = copy(p, c.decoded)
+		c.decoded = c.decoded[n:]
+		return
+	}
+	c.access.Lock()
+	handshakeStatus := c.handshakeStatus
+	c.access.Unlock()
+	if handshakeStatus != tlsHandshakeDone {
+		err = c.readServerHello()
+		if err != nil {
+			return
+		}
+	}
+	for {
+		if len(c.readBuffer) >= 5 {
+			if c.readBuffer[0] != tlsRecordApplicationData {
+				return 0, E.New("tls1.2_ticket_auth: unexpected record type ", c.readBuffer[0])
+			}
+			length := int(binary.BigEndian.Uint16(c.readBuffer[3:5]))
+			if len(c.readBuffer) >= 5+length {
+				n = copy(p, c.readBuffer[5:5+length])
+				c.decoded = append(c.decoded[:0], c.readBuffer[5+n:5+length]...)
+				c.readBuffer = c.readBuffer[5+length:]
+				if n > 0 {
+					return
+				}
+				continue
+			}
+		}
+		err = c.fillReadBuffer()
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (c *tlsConn) fillReadBuffer() error {
+	buffer := make([]byte, 8192)
+	n, err := c.Conn.Read(buffer)
+	if n > 0 {
+		c.readBuffer = append(c.readBuffer, buffer[:n]...)
+	}
+	if n == 0 && err == nil {
+		return io.ErrNoProgress
+	}
+	return err
+}
+
+// readServerHello verifies the fake server hello, change cipher spec and
+// finished messages, then sends the buffered payload.
+func (c *tlsConn) readServerHello() error {
+	var (
+		length                 int
+		changeCipherSpecLoaded bool
+	)
+	for {
+		if len(c.readBuffer) >= length+5 {
+			recordType := c.readBuffer[length]
+			recordLength := int(binary.BigEndian.Uint16(c.readBuffer[length+3:]))
+			if len(c.readBuffer) >= length+5+recordLength {
+				length += 5 + recordLength
+				if recordType == tlsRecordChangeCipherSpec {
+					changeCipherSpecLoaded = true
+				} else if recordType != tlsRecordHandshake {
+					return E.New("tls1.2_ticket_auth: unexpected record type ", recordType)
+				} else if changeCipherSpecLoaded {
+					break
+				}
+				continue
+			}
+		}
+		err := c.fillReadBuffer()
+		if err != nil {
+			return err
+		}
+	}
+	serverHello := c.readBuffer[:length]
+	if len(serverHello) < 11+32+1+32 {
+		return E.New("tls1.2_ticket_auth: bad server hello")
+	}
+	if !hmac.Equal(c.hmac(serverHello[11:33]), serverHello[33:43]) {
+		return E.New("tls1.2_ticket_auth: bad server hello checksum")
+	}
+	if !hmac.Equal(c.hmac(serverHello[:length-10]), serverHello[length-10:]) {
+		return E.New("tls1.2_ticket_auth: bad server finished checksum")
+	}
+	c.readBuffer = c.readBuffer[length:]
+
+	var buffer bytes.Buffer
+	buffer.Write([]byte{tlsRecordChangeCipherSpec, 0x03, 0x03, 0x00, 0x01, 0x01})
+	buffer.Write([]byte{tlsRecordHandshake, 0x03, 0x03, 0x00, 0x20})
+	finished := make([]byte, 22)
+	common.Must1(io.ReadFull(rand.Reader, finished))
+	buffer.Write(finished)
+	buffer.Write(c.hmac(buffer.Bytes()))
+
+	c.access.Lock()
+	defer c.access.Unlock()
+	buffer.Write(c.sendBuffer.Bytes())
+	c.sendBuffer.Reset()
+	_, err := c.Conn.Write(buffer.Bytes())
+	if err != nil {
+		return err
+	}
+	c.handshakeStatus = tlsHandshakeDone
+	return nil
+}
+
+func (c *tlsConn) Upstream() any {
+	return c.Conn
+}
@@ -0,0 +1,171 @@
+package shadowsocksr
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"hash"
+	"io"
+	"math"
+	mRand "math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+var ProtocolList = []string{
+	"origin",
+	"auth_aes128_md5",
+	"auth_aes128_sha1",
+	"auth_chain_a",
+}
+
+type protocolInfo struct {
+	// key and iv of the stream cipher
+	key []byte
+	iv  []byte
+	// overhead of obfs and protocol together
+	overhead int
+	param    string
+	authData *authData
+}
+
+type protocolConstructor func(conn net.Conn, info *protocolInfo) net.Conn
+
+func newProtocol(protocol string) (constructor protocolConstructor, overhead int, err error) {
+	switch protocol {
+	case "", "origin":
+		return func(conn net.Conn, info *protocolInfo) net.Conn {
+			return conn
+		}, 0, nil
+	case "auth_aes128_md5":
+		return func(conn net.Conn, info *protocolInfo) net.Conn {
+			return newAuthAES128Conn(conn, info, md5.New, "auth_aes128_md5")
+		}, authAES128Overhead, nil
+	case "auth_aes128_sha1":
+		return func(conn net.Conn, info *protocolInfo) net.Conn {
+			return newAuthAES128Conn(conn, info, sha1.New, "auth_aes128_sha1")
+		}, authAES128Overhead, nil
+	case "auth_chain_a":
+		return newAuthChainAConn, authChainAOverhead, nil
+	default:
+		return nil, 0, E.New("unsupported protocol: ", protocol)
+	}
+}
+
+// authData is the client identity shared by all connections of a client.
+type authData struct {
+	access       sync.Mutex
+	clientID     [4]byte
+	connectionID uint32
+}
+
+func (d *authData) next() []byte {
+	d.access.Lock()
+	defer d.access.Unlock()
+	if d.connectionID == 0 || d.connectionID > 0xff000000 {
+		common.Must1(io.ReadFull(rand.Reader, d.clientID[:]))
+		d.connectionID = mRand.Uint32() & 0xffffff
+	}
+	d.connectionID++
+	data := make([]byte, 12)
+	binary.LittleEndian.PutUint32(data, uint32(time.Now().Unix()))
+	copy(data[4:], d.clientID[:])
+	binary.LittleEndian.PutUint32(data[8:], d.connectionID)
+	return data
+}
+
+// parseUser parses a protocol param in the format `id:key` for single-port
+// multi-user servers.
+func parseUser(param string) (userID uint32, userKey string, loaded bool) {
+	id, key, found := strings.Cut(param, ":")
+	if !found {
+		return
+	}
+	parsedID, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return
+	}
+	return uint32(parsedID), key, true
+}
+
+func randomUserID() uint32 {
+	var userID [4]byte
+	common.Must1(io.ReadFull(rand.Reader, userID[:]))
+	return binary.LittleEndian.Uint32(userID[:])
+}
+
+func hmacSum(hashFunc func() hash.Hash, key []byte, data ...[]byte) []byte {
+	h := hmac.New(hashFunc, key)
+	for _, chunk := range data {
+		h.Write(chunk)
+	}
+	return h.Sum(nil)
+}
+
+func appendUint32(key []byte, id uint32) []byte {
+	return binary.LittleEndian.AppendUint32(append([]byte(nil), key...), id)
+}
+
+// headSize returns the length of the socks address at the start of the
+// first payload.
+func headSize(data []byte, defaultValue int) int {
+	if len(data) < 2 {
+		return defaultValue
+	}
+	switch data[0] & 0x7 {
+	case 1:
+		return 7
+	case 4:
+		return 19
+	case 3:
+		return 4 + int(data[1])
+	default:
+		return defaultValue
+	}
+}
+
+func trapezoidRandom(max int, d float64) int {
+	s := mRand.Float64()
+	a := 1 - d
+	return int((math.Sqrt(a*a+4*d*s) - a) / (2 * d) * float64(max))
+}
+
+type xorShift128Plus struct {
+	s [2]uint64
+}
+
+func (r *xorShift128Plus) Next() uint64 {
+	x := r.s[0]
+	y := r.s[1]
+	r.s[0] = y
+	x ^= x << 23
+	x ^= y ^ (x >> 17) ^ (y >> 26)
+	r.s[1] = x
+	return x + y
+}
+
+func (r *xorShift128Plus) InitFromBin(bin []byte) {
+	var full [16]byte
+	copy(full[:], bin)
+	r.s[0] = binary.LittleEndian.Uint64(full[:8])
+	r.s[1] = binary.LittleEndian.Uint64(full[8:])
+}
+
+func (r *xorShift128Plus) InitFromBinAndLength(bin []byte, length int) {
+	var full [16]byte
+	copy(full[:], bin)
+	binary.LittleEndian.PutUint16(full[:], uint16(length))
+	r.s[0] = binary.LittleEndian.Uint64(full[:8])
+	r.s[1] = binary.LittleEndian.Uint64(full[8:])
+	for i := 0; i < 4; i++ {
+		r.Next()
+	}
+}
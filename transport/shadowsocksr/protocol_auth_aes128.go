@@ -0,0 +1,221 @@
+package shadowsocksr
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"hash"
+	"io"
+	mRand "math/rand"
+	"net"
+
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+const (
+	authAES128Overhead   = 9
+	authAES128UnitLength = 8100
+	authAES128TCPMSS     = 1460
+	authAES128BufferSize = 32*1024 - authAES128Overhead
+)
+
+type authAES128Conn struct {
+	net.Conn
+	info          *protocolInfo
+	hashFunc      func() hash.Hash
+	salt          string
+	userID        uint32
+	userKey       []byte
+	headerWritten bool
+	packID        uint32
+	recvID        uint32
+	readBuffer    []byte
+	decoded       bytes.Buffer
+}
+
+func newAuthAES128Conn(conn net.Conn, info *protocolInfo, hashFunc func() hash.Hash, salt string) net.Conn {
+	c := &authAES128Conn{
+		Conn:     conn,
+		info:     info,
+		hashFunc: hashFunc,
+		salt:     salt,
+		packID:   1,
+		recvID:   1,
+	}
+	if userID, userKey, loaded := parseUser(info.param); loaded {
+		c.userID = userID
+		h := hashFunc()
+		h.Write([]byte(userKey))
+		c.userKey = h.Sum(nil)
+	} else {
+		c.userID = randomUserID()
+		c.userKey = info.key
+	}
+	return c
+}
+
+func (c *authAES128Conn) Write(p []byte) (n int, err error) {
+	var buffer bytes.Buffer
+	data := p
+	if !c.headerWritten {
+		headerLength := common.Min(len(data), mRand.Intn(32)+headSize(data, 30))
+		err = c.packAuthData(&buffer, data[:headerLength])
+		if err != nil {
+			return
+		}
+		data = data[headerLength:]
+		c.headerWritten = true
+	}
+	for len(data) > authAES128UnitLength {
+		c.packData(&buffer, data[:authAES128UnitLength], len(p))
+		data = data[authAES128UnitLength:]
+	}
+	if len(data) > 0 {
+		c.packData(&buffer, data, len(p))
+	}
+	_, err = c.Conn.Write(buffer.Bytes())
+	if err != nil {
+		return
+	}
+	return len(p), nil
+}
+
+func (c *authAES128Conn) packAuthData(buffer *bytes.Buffer, data []byte) error {
+	var randomLength int
+	if len(data) > 400 {
+		randomLength = mRand.Intn(512)
+	} else {
+		randomLength = mRand.Intn(1024)
+	}
+	dataLength := 7 + 4 + 16 + 4 + len(data) + randomLength + 4
+	macKey := append(append([]byte(nil), c.info.iv...), c.info.key...)
+
+	plain := make([]byte, 16)
+	copy(plain, c.info.authData.next())
+	binary.LittleEndian.PutUint16(plain[12:], uint16(dataLength))
+	binary.LittleEndian.PutUint16(plain[14:], uint16(randomLength))
+	block, err := aes.NewCipher(kdf(base64.StdEncoding.EncodeToString(c.userKey)+c.salt, 16))
+	if err != nil {
+		return err
+	}
+	encrypted := make([]byte, 4+16)
+	binary.LittleEndian.PutUint32(encrypted, c.userID)
+	cipher.NewCBCEncrypter(block, make([]byte, aes.BlockSize)).CryptBlocks(encrypted[4:], plain)
+
+	header := make([]byte, 7)
+	common.Must1(io.ReadFull(rand.Reader, header[:1]))
+	copy(header[1:], hmacSum(c.hashFunc, macKey, header[:1])[:6])
+
+	start := buffer.Len()
+	buffer.Write(header)
+	buffer.Write(encrypted)
+	buffer.Write(hmacSum(c.hashFunc, macKey, encrypted)[:4])
+	padding := make([]byte, randomLength)
+	common.Must1(io.ReadFull(rand.Reader, padding))
+	buffer.Write(padding)
+	buffer.Write(data)
+	buffer.Write(hmacSum(c.hashFunc, c.userKey, buffer.Bytes()[start:])[:4])
+	return nil
+}
+
+func (c *authAES128Conn) packData(buffer *bytes.Buffer, data []byte, fullLength int) {
+	randomLength := c.randomDataLength(len(data), fullLength)
+	padding := make([]byte, randomLength+1)
+	common.Must1(io.ReadFull(rand.Reader, padding))
+	if randomLength < 128 {
+		padding[0] = byte(randomLength + 1)
+	} else {
+		padding[0] = 0xff
+		binary.LittleEndian.PutUint16(padding[1:], uint16(randomLength+1))
+	}
+	dataLength := 4 + len(padding) + len(data) + 4
+	macKey := appendUint32(c.userKey, c.packID)
+
+	start := buffer.Len()
+	common.Must(binary.Write(buffer, binary.LittleEndian, uint16(dataLength)))
+	buffer.Write(hmacSum(c.hashFunc, macKey, buffer.Bytes()[start:])[:2])
+	buffer.Write(padding)
+	buffer.Write(data)
+	buffer.Write(hmacSum(c.hashFunc, macKey, buffer.Bytes()[start:])[:4])
+	c.packID++
+}
+
+func (c *authAES128Conn) randomDataLength(dataLength int, fullLength int) int {
+	if fullLength >= authAES128BufferSize {
+		return 0
+	}
+	revLength := authAES128TCPMSS - dataLength - authAES128Overhead
+	if revLength == 0 {
+		return 0
+	}
+	if revLength < 0 {
+		if revLength > -authAES128TCPMSS {
+			return trapezoidRandom(revLength+authAES128TCPMSS, -0.3)
+		}
+		return mRand.Intn(32)
+	}
+	if dataLength > 900 {
+		return mRand.Intn(revLength)
+	}
+	return trapezoidRandom(revLength, -0.3)
+}
+
+func (c *authAES128Conn) Read(p []byte) (n int, err error) {
+	for c.decoded.Len() == 0 {
+		err = c.readPacket()
+		if err != nil {
+			return
+		}
+	}
+	return c.decoded.Read(p)
+}
+
+func (c *authAES128Conn) readPacket() error {
+	for {
+		if len(c.readBuffer) > 4 {
+			macKey := appendUint32(c.userKey, c.recvID)
+			if !bytes.Equal(hmacSum(c.hashFunc, macKey, c.readBuffer[:2])[:2], c.readBuffer[2:4]) {
+				return E.New(c.salt, ": bad length checksum")
+			}
+			length := int(binary.LittleEndian.Uint16(c.readBuffer))
+			if length >= 8192 || length < 7 {
+				return E.New(c.salt, ": bad length: ", length)
+			}
+			if length <= len(c.readBuffer) {
+				packet := c.readBuffer[:length]
+				if !bytes.Equal(hmacSum(c.hashFunc, macKey, packet[:length-4])[:4], packet[length-4:]) {
+					return E.New(c.salt, ": bad checksum")
+				}
+				position := int(packet[4])
+				if position < 0xff {
+					position += 4
+				} else {
+					position = int(binary.LittleEndian.Uint16(packet[5:])) + 4
+				}
+				if position > length-4 {
+					return E.New(c.salt, ": bad padding length")
+				}
+				c.decoded.Write(packet[position : length-4])
+				c.readBuffer = c.readBuffer[length:]
+				c.recvID++
+				return nil
+			}
+		}
+		buffer := make([]byte, 8192)
+		n, err := c.Conn.Read(buffer)
+		if n > 0 {
+			c.readBuffer = append(c.readBuffer, buffer[:n]...)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (c *authAES128Conn) Upstream() any {
+	return c.Conn
+}
@@ -0,0 +1,229 @@
+package shadowsocksr
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/rc4"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	mRand "math/rand"
+	"net"
+
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+const (
+	authChainAOverhead   = 4
+	authChainAUnitLength = 2800
+	authChainASalt       = "auth_chain_a"
+)
+
+type authChainAConn struct {
+	net.Conn
+	info           *protocolInfo
+	userID         uint32
+	userKey        []byte
+	headerWritten  bool
+	packID         uint32
+	recvID         uint32
+	lastClientHash []byte
+	lastServerHash []byte
+	randomClient   xorShift128Plus
+	randomServer   xorShift128Plus
+	encrypter      cipher.Stream
+	decrypter      cipher.Stream
+	readBuffer     []byte
+	decoded        bytes.Buffer
+	// the first two bytes from the server are its TCP MSS
+	mssRemaining int
+}
+
+func newAuthChainAConn(conn net.Conn, info *protocolInfo) net.Conn {
+	c := &authChainAConn{
+		Conn:         conn,
+		info:         info,
+		packID:       1,
+		recvID:       1,
+		mssRemaining: 2,
+	}
+	if userID, userKey, loaded := parseUser(info.param); loaded {
+		c.userID = userID
+		c.userKey = []byte(userKey)
+	} else {
+		c.userID = randomUserID()
+		c.userKey = info.key
+	}
+	return c
+}
+
+func (c *authChainAConn) Write(p []byte) (n int, err error) {
+	var buffer bytes.Buffer
+	data := p
+	if !c.headerWritten {
+		headerLength := common.Min(len(data), mRand.Intn(32)+headSize(data, 30))
+		err = c.packAuthData(&buffer, data[:headerLength])
+		if err != nil {
+			return
+		}
+		data = data[headerLength:]
+		c.headerWritten = true
+	}
+	for len(data) > authChainAUnitLength {
+		c.packData(&buffer, data[:authChainAUnitLength])
+		data = data[authChainAUnitLength:]
+	}
+	if len(data) > 0 {
+		c.packData(&buffer, data)
+	}
+	_, err = c.Conn.Write(buffer.Bytes())
+	if err != nil {
+		return
+	}
+	return len(p), nil
+}
+
+func (c *authChainAConn) packAuthData(buffer *bytes.Buffer, data []byte) error {
+	macKey := append(append([]byte(nil), c.info.iv...), c.info.key...)
+
+	header := make([]byte, 4)
+	common.Must1(io.ReadFull(rand.Reader, header))
+	c.lastClientHash = hmacSum(md5.New, macKey, header)
+
+	plain := make([]byte, 16)
+	copy(plain, c.info.authData.next())
+	binary.LittleEndian.PutUint16(plain[12:], uint16(c.info.overhead))
+	block, err := aes.NewCipher(kdf(base64.StdEncoding.EncodeToString(c.userKey)+authChainASalt, 16))
+	if err != nil {
+		return err
+	}
+	encrypted := make([]byte, 4+16)
+	binary.LittleEndian.PutUint32(encrypted, c.userID^binary.LittleEndian.Uint32(c.lastClientHash[8:12]))
+	cipher.NewCBCEncrypter(block, make([]byte, aes.BlockSize)).CryptBlocks(encrypted[4:], plain)
+	c.lastServerHash = hmacSum(md5.New, c.userKey, encrypted)
+
+	buffer.Write(header)
+	buffer.Write(c.lastClientHash[:8])
+	buffer.Write(encrypted)
+	buffer.Write(c.lastServerHash[:4])
+
+	key := kdf(base64.StdEncoding.EncodeToString(c.userKey)+base64.StdEncoding.EncodeToString(c.lastClientHash), 16)
+	c.encrypter, err = rc4.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	c.decrypter, err = rc4.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	c.packData(buffer, data)
+	return nil
+}
+
+func (c *authChainAConn) packData(buffer *bytes.Buffer, data []byte) {
+	encrypted := make([]byte, len(data))
+	c.encrypter.XORKeyStream(encrypted, data)
+	randomLength := authChainARandomLength(len(data), c.lastClientHash, &c.randomClient)
+	padding := make([]byte, randomLength)
+	common.Must1(io.ReadFull(rand.Reader, padding))
+	var startPosition int
+	if len(data) > 0 {
+		startPosition = authChainAStartPosition(randomLength, &c.randomClient)
+	}
+	macKey := appendUint32(c.userKey, c.packID)
+
+	start := buffer.Len()
+	common.Must(binary.Write(buffer, binary.LittleEndian, uint16(len(data))^binary.LittleEndian.Uint16(c.lastClientHash[14:16])))
+	buffer.Write(padding[:startPosition])
+	buffer.Write(encrypted)
+	buffer.Write(padding[startPosition:])
+	c.lastClientHash = hmacSum(md5.New, macKey, buffer.Bytes()[start:])
+	buffer.Write(c.lastClientHash[:2])
+	c.packID++
+}
+
+func authChainARandomLength(dataLength int, lastHash []byte, random *xorShift128Plus) int {
+	if dataLength > 1440 {
+		return 0
+	}
+	random.InitFromBinAndLength(lastHash, dataLength)
+	if dataLength > 1300 {
+		return int(random.Next() % 31)
+	}
+	if dataLength > 900 {
+		return int(random.Next() % 127)
+	}
+	if dataLength > 400 {
+		return int(random.Next() % 521)
+	}
+	return int(random.Next() % 1021)
+}
+
+func authChainAStartPosition(randomLength int, random *xorShift128Plus) int {
+	if randomLength > 0 {
+		return int(random.Next() % 8589934609 % uint64(randomLength))
+	}
+	return 0
+}
+
+func (c *authChainAConn) Read(p []byte) (n int, err error) {
+	for c.decoded.Len() == 0 {
+		err = c.readPacket()
+		if err != nil {
+			return
+		}
+	}
+	return c.decoded.Read(p)
+}
+
+func (c *authChainAConn) readPacket() error {
+	for {
+		if len(c.readBuffer) > 4 {
+			dataLength := int(binary.LittleEndian.Uint16(c.readBuffer) ^ binary.LittleEndian.Uint16(c.lastServerHash[14:16]))
+			randomLength := authChainARandomLength(dataLength, c.lastServerHash, &c.randomServer)
+			length := dataLength + randomLength
+			if length >= 4096 {
+				return E.New("auth_chain_a: bad length: ", length)
+			}
+			if length+4 <= len(c.readBuffer) {
+				macKey := appendUint32(c.userKey, c.recvID)
+				serverHash := hmacSum(md5.New, macKey, c.readBuffer[:length+2])
+				if !bytes.Equal(serverHash[:2], c.readBuffer[length+2:length+4]) {
+					return E.New("auth_chain_a: bad checksum")
+				}
+				position := 2
+				if dataLength > 0 && randomLength > 0 {
+					position += authChainAStartPosition(randomLength, &c.randomServer)
+				}
+				data := make([]byte, dataLength)
+				c.decrypter.XORKeyStream(data, c.readBuffer[position:position+dataLength])
+				if c.mssRemaining > 0 {
+					skip := common.Min(c.mssRemaining, len(data))
+					data = data[skip:]
+					c.mssRemaining -= skip
+				}
+				c.decoded.Write(data)
+				c.lastServerHash = serverHash
+				c.readBuffer = c.readBuffer[length+4:]
+				c.recvID++
+				return nil
+			}
+		}
+		buffer := make([]byte, 8192)
+		n, err := c.Conn.Read(buffer)
+		if n > 0 {
+			c.readBuffer = append(c.readBuffer, buffer[:n]...)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (c *authChainAConn) Upstream() any {
+	return c.Conn
+}
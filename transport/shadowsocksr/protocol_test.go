@@ -0,0 +1,285 @@
+package shadowsocksr
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"crypto/rc4"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"hash"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Python implementation of the original project
+func TestXorShift128Plus(t *testing.T) {
+	t.Parallel()
+	var random xorShift128Plus
+	random.InitFromBinAndLength([]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}, 1000)
+	require.Equal(t, uint64(17632952985403927757), random.Next())
+	require.Equal(t, uint64(12516270974224151062), random.Next())
+	require.Equal(t, uint64(7046899678584040758), random.Next())
+	randomLength := authChainARandomLength(100, []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}, &random)
+	require.Equal(t, 580, randomLength)
+	require.Equal(t, 424, authChainAStartPosition(randomLength, &random))
+}
+
+func TestHeadSize(t *testing.T) {
+	t.Parallel()
+	require.Equal(t, 7, headSize([]byte{1, 127, 0, 0, 1, 0, 80}, 30))
+	require.Equal(t, 19, headSize([]byte{4, 0}, 30))
+	require.Equal(t, 15, headSize([]byte{3, 11}, 30))
+	require.Equal(t, 30, headSize([]byte{3}, 30))
+}
+
+func newTestProtocolInfo(param string) *protocolInfo {
+	return &protocolInfo{
+		key:      kdf("password", 16),
+		iv:       []byte("0123456789abcdef"),
+		overhead: authAES128Overhead,
+		param:    param,
+		authData: &authData{},
+	}
+}
+
+func testPayload(length int) []byte {
+	payload := make([]byte, length)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+	return payload
+}
+
+// authAES128Server decodes the client stream as the original server does.
+type authAES128Server struct {
+	hashFunc func() hash.Hash
+	salt     string
+	info     *protocolInfo
+	userKeys map[uint32][]byte
+	userKey  []byte
+	userID   uint32
+	recvID   uint32
+}
+
+func (s *authAES128Server) decode(t *testing.T, data []byte) []byte {
+	macKey := append(append([]byte(nil), s.info.iv...), s.info.key...)
+	require.Equal(t, hmacSum(s.hashFunc, macKey, data[:1])[:6], data[1:7])
+	require.Equal(t, hmacSum(s.hashFunc, macKey, data[7:27])[:4], data[27:31])
+	s.userID = binary.LittleEndian.Uint32(data[7:11])
+	s.userKey = s.info.key
+	if userKey, loaded := s.userKeys[s.userID]; loaded {
+		s.userKey = userKey
+	}
+	block, err := aes.NewCipher(kdf(base64.StdEncoding.EncodeToString(s.userKey)+s.salt, 16))
+	require.NoError(t, err)
+	head := make([]byte, 16)
+	cipher.NewCBCDecrypter(block, make([]byte, aes.BlockSize)).CryptBlocks(head, data[11:27])
+	length := int(binary.LittleEndian.Uint16(head[12:]))
+	randomLength := int(binary.LittleEndian.Uint16(head[14:]))
+	require.LessOrEqual(t, length, len(data))
+	require.Equal(t, hmacSum(s.hashFunc, s.userKey, data[:length-4])[:4], data[length-4:length])
+	decoded := append([]byte(nil), data[31+randomLength:length-4]...)
+	data = data[length:]
+	s.recvID = 1
+	for len(data) > 0 {
+		macKey = appendUint32(s.userKey, s.recvID)
+		require.Equal(t, hmacSum(s.hashFunc, macKey, data[:2])[:2], data[2:4])
+		length = int(binary.LittleEndian.Uint16(data))
+		require.Equal(t, hmacSum(s.hashFunc, macKey, data[:length-4])[:4], data[length-4:length])
+		position := int(data[4])
+		if position < 0xff {
+			position += 4
+		} else {
+			position = int(binary.LittleEndian.Uint16(data[5:])) + 4
+		}
+		decoded = append(decoded, data[position:length-4]...)
+		data = data[length:]
+		s.recvID++
+	}
+	return decoded
+}
+
+func TestAuthAES128(t *testing.T) {
+	t.Parallel()
+	for _, testCase := range []struct {
+		name     string
+		hashFunc func() hash.Hash
+		param    string
+	}{
+		{"auth_aes128_md5", md5.New, ""},
+		{"auth_aes128_sha1", sha1.New, ""},
+		{"auth_aes128_md5", md5.New, "1024:user_password"},
+	} {
+		constructor, _, err := newProtocol(testCase.name)
+		require.NoError(t, err)
+		info := newTestProtocolInfo(testCase.param)
+		userKey := testCase.hashFunc()
+		userKey.Write([]byte("user_password"))
+		server := &authAES128Server{
+			hashFunc: testCase.hashFunc,
+			salt:     testCase.name,
+			info:     info,
+			userKeys: map[uint32][]byte{1024: userKey.Sum(nil)},
+		}
+		clientConn := &bufferConn{}
+		conn := constructor(clientConn, info)
+		request := append([]byte{1, 127, 0, 0, 1, 0, 80}, testPayload(10000)...)
+		_, err = conn.Write(request)
+		require.NoError(t, err)
+		_, err = conn.Write([]byte("next"))
+		require.NoError(t, err)
+		require.Equal(t, append(request, "next"...), server.decode(t, clientConn.written.Bytes()))
+		if testCase.param != "" {
+			require.Equal(t, uint32(1024), server.userID)
+		}
+
+		// the server encodes like the data packets of the client
+		serverWriter := newAuthAES128Conn(&bufferConn{}, info, testCase.hashFunc, testCase.name).(*authAES128Conn)
+		serverWriter.userKey = server.userKey
+		var response bytes.Buffer
+		serverWriter.packData(&response, []byte("hello"), 5)
+		serverWriter.packData(&response, testPayload(3000), 3000)
+		clientConn.reader = bytes.NewReader(response.Bytes())
+		message, err := io.ReadAll(conn)
+		require.NoError(t, err)
+		require.Equal(t, append([]byte("hello"), testPayload(3000)...), message)
+	}
+}
+
+func TestAuthAES128BadChecksum(t *testing.T) {
+	t.Parallel()
+	info := newTestProtocolInfo("")
+	serverWriter := newAuthAES128Conn(&bufferConn{}, info, md5.New, "auth_aes128_md5").(*authAES128Conn)
+	var response bytes.Buffer
+	serverWriter.packData(&response, []byte("hello"), 5)
+	corrupted := response.Bytes()
+	corrupted[len(corrupted)-1] ^= 0xff
+	conn := newAuthAES128Conn(&bufferConn{reader: bytes.NewReader(corrupted)}, info, md5.New, "auth_aes128_md5").(*authAES128Conn)
+	conn.userKey = serverWriter.userKey
+	_, err := conn.Read(make([]byte, 1024))
+	require.ErrorContains(t, err, "bad checksum")
+}
+
+// authChainAServer decodes the client stream and encodes the server stream
+// as the original server does.
+type authChainAServer struct {
+	info           *protocolInfo
+	userKey        []byte
+	lastClientHash []byte
+	lastServerHash []byte
+	randomClient   xorShift128Plus
+	randomServer   xorShift128Plus
+	decrypter      cipher.Stream
+	encrypter      cipher.Stream
+	recvID         uint32
+	packID         uint32
+}
+
+func (s *authChainAServer) decode(t *testing.T, data []byte) []byte {
+	macKey := append(append([]byte(nil), s.info.iv...), s.info.key...)
+	s.lastClientHash = hmacSum(md5.New, macKey, data[:4])
+	require.Equal(t, s.lastClientHash[:8], data[4:12])
+	encrypted := data[12:32]
+	s.lastServerHash = hmacSum(md5.New, s.userKey, encrypted)
+	require.Equal(t, s.lastServerHash[:4], data[32:36])
+	block, err := aes.NewCipher(kdf(base64.StdEncoding.EncodeToString(s.userKey)+authChainASalt, 16))
+	require.NoError(t, err)
+	head := make([]byte, 16)
+	cipher.NewCBCDecrypter(block, make([]byte, aes.BlockSize)).CryptBlocks(head, encrypted[4:])
+	require.Equal(t, uint16(s.info.overhead), binary.LittleEndian.Uint16(head[12:]))
+	key := kdf(base64.StdEncoding.EncodeToString(s.userKey)+base64.StdEncoding.EncodeToString(s.lastClientHash), 16)
+	s.decrypter, err = rc4.NewCipher(key)
+	require.NoError(t, err)
+	s.encrypter, err = rc4.NewCipher(key)
+	require.NoError(t, err)
+	data = data[36:]
+	var decoded []byte
+	s.recvID = 1
+	for len(data) > 0 {
+		dataLength := int(binary.LittleEndian.Uint16(data) ^ binary.LittleEndian.Uint16(s.lastClientHash[14:]))
+		randomLength := authChainARandomLength(dataLength, s.lastClientHash, &s.randomClient)
+		length := dataLength + randomLength
+		s.lastClientHash = hmacSum(md5.New, appendUint32(s.userKey, s.recvID), data[:length+2])
+		require.Equal(t, s.lastClientHash[:2], data[length+2:length+4])
+		position := 2
+		if dataLength > 0 && randomLength > 0 {
+			position += authChainAStartPosition(randomLength, &s.randomClient)
+		}
+		plaintext := make([]byte, dataLength)
+		s.decrypter.XORKeyStream(plaintext, data[position:position+dataLength])
+		decoded = append(decoded, plaintext...)
+		data = data[length+4:]
+		s.recvID++
+	}
+	return decoded
+}
+
+func (s *authChainAServer) encode(buffer *bytes.Buffer, data []byte) {
+	if s.packID == 0 {
+		s.packID = 1
+		data = append([]byte{0xdc, 0x05}, data...)
+	}
+	encrypted := make([]byte, len(data))
+	s.encrypter.XORKeyStream(encrypted, data)
+	randomLength := authChainARandomLength(len(data), s.lastServerHash, &s.randomServer)
+	var startPosition int
+	if randomLength > 0 {
+		startPosition = authChainAStartPosition(randomLength, &s.randomServer)
+	}
+	padding := make([]byte, randomLength)
+	start := buffer.Len()
+	binary.Write(buffer, binary.LittleEndian, uint16(len(data))^binary.LittleEndian.Uint16(s.lastServerHash[14:]))
+	buffer.Write(padding[:startPosition])
+	buffer.Write(encrypted)
+	buffer.Write(padding[startPosition:])
+	s.lastServerHash = hmacSum(md5.New, appendUint32(s.userKey, s.packID), buffer.Bytes()[start:])
+	buffer.Write(s.lastServerHash[:2])
+	s.packID++
+}
+
+func TestAuthChainA(t *testing.T) {
+	t.Parallel()
+	for _, param := range []string{"", "1024:user_password"} {
+		info := newTestProtocolInfo(param)
+		info.overhead = authChainAOverhead
+		clientConn := &bufferConn{}
+		conn := newAuthChainAConn(clientConn, info)
+		server := &authChainAServer{info: info, userKey: info.key}
+		if param != "" {
+			server.userKey = []byte("user_password")
+		}
+		request := append([]byte{1, 127, 0, 0, 1, 0, 80}, testPayload(6000)...)
+		_, err := conn.Write(request)
+		require.NoError(t, err)
+		_, err = conn.Write([]byte("next"))
+		require.NoError(t, err)
+		require.Equal(t, append(request, "next"...), server.decode(t, clientConn.written.Bytes()))
+
+		var response bytes.Buffer
+		server.encode(&response, []byte("hello"))
+		server.encode(&response, testPayload(1000))
+		server.encode(&response, nil)
+		server.encode(&response, []byte("!"))
+		clientConn.reader = bytes.NewReader(response.Bytes())
+		message, err := io.ReadAll(conn)
+		require.NoError(t, err)
+		require.Equal(t, append(append([]byte("hello"), testPayload(1000)...), '!'), message)
+	}
+}
+
+func TestParseUser(t *testing.T) {
+	t.Parallel()
+	userID, userKey, loaded := parseUser("1024:password:with:colons")
+	require.True(t, loaded)
+	require.Equal(t, uint32(1024), userID)
+	require.Equal(t, "password:with:colons", userKey)
+	_, _, loaded = parseUser("password")
+	require.False(t, loaded)
+	_, _, loaded = parseUser("user:password")
+	require.False(t, loaded)
+}
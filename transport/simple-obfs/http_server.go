@@ -0,0 +1,97 @@
+package obfs
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+// HTTPObfsServer is shadowsocks http simple-obfs server implementation
+type HTTPObfsServer struct {
+	net.Conn
+	buf           []byte
+	offset        int
+	firstRequest  bool
+	firstResponse bool
+}
+
+func (ho *HTTPObfsServer) Read(b []byte) (int, error) {
+	if ho.firstRequest {
+		err := ho.readRequest()
+		if err != nil {
+			return 0, err
+		}
+		ho.firstRequest = false
+	}
+	if ho.buf != nil {
+		n := copy(b, ho.buf[ho.offset:])
+		ho.offset += n
+		if ho.offset == len(ho.buf) {
+			ho.buf = nil
+		}
+		return n, nil
+	}
+	return ho.Conn.Read(b)
+}
+
+func (ho *HTTPObfsServer) readRequest() error {
+	reader := bufio.NewReader(ho.Conn)
+	request, err := http.ReadRequest(reader)
+	if err != nil {
+		return E.Cause(err, "read obfs request")
+	}
+	if !strings.EqualFold(request.Header.Get("Upgrade"), "websocket") {
+		return E.New("bad obfs request: missing websocket upgrade")
+	}
+	content, err := io.ReadAll(request.Body)
+	if err != nil {
+		return E.Cause(err, "read obfs request body")
+	}
+	if reader.Buffered() > 0 {
+		buffered, _ := reader.Peek(reader.Buffered())
+		content = append(content, buffered...)
+	}
+	if len(content) > 0 {
+		ho.buf = content
+	}
+	return nil
+}
+
+func (ho *HTTPObfsServer) Write(b []byte) (int, error) {
+	if ho.firstResponse {
+		randBytes := make([]byte, 16)
+		rand.Read(randBytes)
+		buf := &bytes.Buffer{}
+		buf.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+		fmt.Fprintf(buf, "Server: nginx/1.%d.%d\r\n", rand.Int()%11, rand.Int()%12)
+		fmt.Fprintf(buf, "Date: %s\r\n", time.Now().UTC().Format(http.TimeFormat))
+		buf.WriteString("Upgrade: websocket\r\n")
+		buf.WriteString("Connection: Upgrade\r\n")
+		fmt.Fprintf(buf, "Sec-WebSocket-Accept: %s\r\n\r\n", base64.URLEncoding.EncodeToString(randBytes))
+		// the client expects the header and the first payload in a single read
+		buf.Write(b)
+		_, err := ho.Conn.Write(buf.Bytes())
+		ho.firstResponse = false
+		return len(b), err
+	}
+
+	return ho.Conn.Write(b)
+}
+
+// NewHTTPObfsServer return a HTTPObfsServer
+func NewHTTPObfsServer(conn net.Conn) net.Conn {
+	return &HTTPObfsServer{
+		Conn:          conn,
+		firstRequest:  true,
+		firstResponse: true,
+	}
+}
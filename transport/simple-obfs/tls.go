@@ -37,13 +37,15 @@ func (to *TLSObfs) read(b []byte, discardN int) (int, error) {
 		return 0, err
 	}
 
-	sizeBuf := make([]byte, 2)
+	sizeBuf := B.Get(2)
 	_, err = io.ReadFull(to.Conn, sizeBuf)
 	if err != nil {
+		B.Put(sizeBuf)
 		return 0, nil
 	}
 
 	length := int(binary.BigEndian.Uint16(sizeBuf))
+	B.Put(sizeBuf)
 	if length > len(b) {
 		n, err := to.Conn.Read(b)
 		if err != nil {
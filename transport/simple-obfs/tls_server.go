@@ -0,0 +1,274 @@
+package obfs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math/rand"
+	"net"
+	"time"
+
+	B "github.com/sagernet/sing/common/buf"
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+const (
+	recordTypeChangeCipherSpec = 0x14
+	recordTypeHandshake        = 0x16
+
+	extensionSessionTicket = 0x0023
+)
+
+// TLSObfsServer is shadowsocks tls simple-obfs server implementation
+type TLSObfsServer struct {
+	net.Conn
+	sessionID     []byte
+	buf           []byte
+	offset        int
+	remain        int
+	firstRequest  bool
+	firstResponse bool
+}
+
+func (to *TLSObfsServer) Read(b []byte) (int, error) {
+	if to.firstRequest {
+		err := to.readClientHello()
+		if err != nil {
+			return 0, err
+		}
+		to.firstRequest = false
+	}
+
+	if to.buf != nil {
+		n := copy(b, to.buf[to.offset:])
+		to.offset += n
+		if to.offset == len(to.buf) {
+			to.buf = nil
+		}
+		return n, nil
+	}
+
+	if to.remain > 0 {
+		length := to.remain
+		if length > len(b) {
+			length = len(b)
+		}
+
+		n, err := io.ReadFull(to.Conn, b[:length])
+		to.remain -= n
+		return n, err
+	}
+
+	for {
+		header := B.Get(5)
+		_, err := io.ReadFull(to.Conn, header)
+		if err != nil {
+			B.Put(header)
+			return 0, err
+		}
+		length := int(binary.BigEndian.Uint16(header[3:]))
+		recordType := header[0]
+		B.Put(header)
+		if recordType == recordTypeChangeCipherSpec {
+			_, err = io.CopyN(io.Discard, to.Conn, int64(length))
+			if err != nil {
+				return 0, err
+			}
+			continue
+		}
+		if length == 0 {
+			continue
+		}
+		if length > len(b) {
+			n, err := to.Conn.Read(b)
+			if err != nil {
+				return n, err
+			}
+			to.remain = length - n
+			return n, nil
+		}
+		return io.ReadFull(to.Conn, b[:length])
+	}
+}
+
+func (to *TLSObfsServer) readClientHello() error {
+	header := make([]byte, 5)
+	_, err := io.ReadFull(to.Conn, header)
+	if err != nil {
+		return E.Cause(err, "read obfs client hello")
+	}
+	if header[0] != recordTypeHandshake {
+		return E.New("bad obfs client hello: unexpected record type ", header[0])
+	}
+	record := make([]byte, binary.BigEndian.Uint16(header[3:]))
+	_, err = io.ReadFull(to.Conn, record)
+	if err != nil {
+		return E.Cause(err, "read obfs client hello")
+	}
+	sessionID, data, err := parseClientHello(record)
+	if err != nil {
+		return E.Cause(err, "bad obfs client hello")
+	}
+	to.sessionID = sessionID
+	if len(data) > 0 {
+		to.buf = data
+	}
+	return nil
+}
+
+func parseClientHello(record []byte) (sessionID []byte, data []byte, err error) {
+	reader := bytes.NewReader(record)
+	// handshake type, length, version, random
+	if reader.Len() < 1+3+2+32 || record[0] != 1 {
+		return nil, nil, E.New("not a client hello")
+	}
+	reader.Seek(1+3+2+32, io.SeekCurrent)
+	sessionID, err = readVector(reader, 1)
+	if err != nil {
+		return
+	}
+	// cipher suites
+	_, err = readVector(reader, 2)
+	if err != nil {
+		return
+	}
+	// compression methods
+	_, err = readVector(reader, 1)
+	if err != nil {
+		return
+	}
+	extensions, err := readVector(reader, 2)
+	if err != nil {
+		return
+	}
+	extensionReader := bytes.NewReader(extensions)
+	for extensionReader.Len() > 0 {
+		var extensionType uint16
+		err = binary.Read(extensionReader, binary.BigEndian, &extensionType)
+		if err != nil {
+			return
+		}
+		var content []byte
+		content, err = readVector(extensionReader, 2)
+		if err != nil {
+			return
+		}
+		if extensionType == extensionSessionTicket {
+			return sessionID, content, nil
+		}
+	}
+	return nil, nil, E.New("missing session ticket")
+}
+
+func readVector(reader *bytes.Reader, lengthSize int) ([]byte, error) {
+	lengthBytes := make([]byte, lengthSize)
+	_, err := io.ReadFull(reader, lengthBytes)
+	if err != nil {
+		return nil, err
+	}
+	var length int
+	for _, b := range lengthBytes {
+		length = length<<8 | int(b)
+	}
+	content := make([]byte, length)
+	_, err = io.ReadFull(reader, content)
+	if err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+func (to *TLSObfsServer) Write(b []byte) (int, error) {
+	length := len(b)
+	for i := 0; i < length; i += chunkSize {
+		end := i + chunkSize
+		if end > length {
+			end = length
+		}
+
+		n, err := to.write(b[i:end])
+		if err != nil {
+			return n, err
+		}
+	}
+	return length, nil
+}
+
+func (to *TLSObfsServer) write(b []byte) (int, error) {
+	if to.firstResponse {
+		helloMsg := makeServerHelloMsg(b, to.sessionID)
+		_, err := to.Conn.Write(helloMsg)
+		to.firstResponse = false
+		return len(b), err
+	}
+
+	buf := B.NewSize(5 + len(b))
+	defer buf.Release()
+	buf.Write([]byte{0x17, 0x03, 0x03})
+	binary.Write(buf, binary.BigEndian, uint16(len(b)))
+	buf.Write(b)
+	_, err := to.Conn.Write(buf.Bytes())
+	return len(b), err
+}
+
+// NewTLSObfsServer return a TLSObfsServer
+func NewTLSObfsServer(conn net.Conn) net.Conn {
+	return &TLSObfsServer{
+		Conn:          conn,
+		firstRequest:  true,
+		firstResponse: true,
+	}
+}
+
+func makeServerHelloMsg(data []byte, sessionID []byte) []byte {
+	random := make([]byte, 28)
+	rand.Read(random)
+	if len(sessionID) != 32 {
+		sessionID = make([]byte, 32)
+		rand.Read(sessionID)
+	}
+
+	buf := &bytes.Buffer{}
+
+	// handshake, TLS 1.0 version, length
+	buf.WriteByte(22)
+	buf.Write([]byte{0x03, 0x01})
+	binary.Write(buf, binary.BigEndian, uint16(91))
+
+	// serverHello, length, TLS 1.2 version
+	buf.WriteByte(2)
+	buf.WriteByte(0)
+	binary.Write(buf, binary.BigEndian, uint16(87))
+	buf.Write([]byte{0x03, 0x03})
+
+	// random with timestamp, sid len, sid
+	binary.Write(buf, binary.BigEndian, uint32(time.Now().Unix()))
+	buf.Write(random)
+	buf.WriteByte(32)
+	buf.Write(sessionID)
+
+	// cipher suite, compression
+	buf.Write([]byte{0xcc, 0xa8, 0x00})
+
+	// extension length
+	binary.Write(buf, binary.BigEndian, uint16(15))
+
+	// renegotiation info
+	buf.Write([]byte{0xff, 0x01, 0x00, 0x01, 0x00})
+
+	// extended master secret
+	buf.Write([]byte{0x00, 0x17, 0x00, 0x00})
+
+	// ec_point
+	buf.Write([]byte{0x00, 0x0b, 0x00, 0x02, 0x01, 0x00})
+
+	// change cipher spec
+	buf.Write([]byte{0x14, 0x03, 0x03, 0x00, 0x01, 0x01})
+
+	// encrypted handshake carrying the first payload
+	buf.Write([]byte{0x16, 0x03, 0x03})
+	binary.Write(buf, binary.BigEndian, uint16(len(data)))
+	buf.Write(data)
+
+	return buf.Bytes()
+}
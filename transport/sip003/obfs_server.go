@@ -0,0 +1,59 @@
+package sip003
+
+import (
+	"context"
+	"net"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/transport/simple-obfs"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+var _ ServerPlugin = (*ObfsServer)(nil)
+
+func init() {
+	RegisterServerPlugin("obfs-server", newObfsServer)
+}
+
+func newObfsServer(ctx context.Context, logger log.ContextLogger, pluginOpts Args, handler adapter.V2RayServerTransportHandler) (ServerPlugin, error) {
+	plugin := &ObfsServer{
+		handler: handler,
+	}
+	mode := "http"
+	if obfsMode, loaded := pluginOpts.Get("obfs"); loaded {
+		mode = obfsMode
+	}
+	switch mode {
+	case "http":
+	case "tls":
+		plugin.tls = true
+	default:
+		return nil, E.New("unknown obfs mode ", mode)
+	}
+	return plugin, nil
+}
+
+type ObfsServer struct {
+	handler adapter.V2RayServerTransportHandler
+	tls     bool
+}
+
+func (o *ObfsServer) Start() error {
+	return nil
+}
+
+func (o *ObfsServer) Close() error {
+	return nil
+}
+
+func (o *ObfsServer) NewConnectionEx(ctx context.Context, conn net.Conn, source M.Socksaddr, onClose N.CloseHandlerFunc) {
+	if !o.tls {
+		conn = obfs.NewHTTPObfsServer(conn)
+	} else {
+		conn = obfs.NewTLSObfsServer(conn)
+	}
+	o.handler.NewConnectionEx(ctx, conn, source, M.Socksaddr{}, onClose)
+}
@@ -0,0 +1,43 @@
+package sip003
+
+import (
+	"context"
+	"net"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/log"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+type ServerPluginConstructor func(ctx context.Context, logger log.ContextLogger, pluginArgs Args, handler adapter.V2RayServerTransportHandler) (ServerPlugin, error)
+
+// ServerPlugin unwraps connections from clients using the corresponding
+// plugin and passes them to the handler.
+type ServerPlugin interface {
+	Start() error
+	Close() error
+	NewConnectionEx(ctx context.Context, conn net.Conn, source M.Socksaddr, onClose N.CloseHandlerFunc)
+}
+
+var serverPlugins map[string]ServerPluginConstructor
+
+func RegisterServerPlugin(name string, constructor ServerPluginConstructor) {
+	if serverPlugins == nil {
+		serverPlugins = make(map[string]ServerPluginConstructor)
+	}
+	serverPlugins[name] = constructor
+}
+
+func CreateServerPlugin(ctx context.Context, logger log.ContextLogger, name string, pluginArgs string, handler adapter.V2RayServerTransportHandler) (ServerPlugin, error) {
+	pluginOptions, err := ParsePluginOptions(pluginArgs)
+	if err != nil {
+		return nil, E.Cause(err, "parse plugin_opts")
+	}
+	constructor, loaded := serverPlugins[name]
+	if !loaded {
+		return nil, E.New("server plugin not found: ", name)
+	}
+	return constructor(ctx, logger, pluginOptions, handler)
+}
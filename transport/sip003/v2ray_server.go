@@ -0,0 +1,193 @@
+package sip003
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/pipelistener"
+	"github.com/sagernet/sing-box/common/tls"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/transport/v2ray"
+	"github.com/sagernet/sing-vmess"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/buf"
+	"github.com/sagernet/sing/common/bufio"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+var _ ServerPlugin = (*V2RayServer)(nil)
+
+func init() {
+	RegisterServerPlugin("v2ray-plugin", newV2RayServer)
+}
+
+func newV2RayServer(ctx context.Context, logger log.ContextLogger, pluginOpts Args, handler adapter.V2RayServerTransportHandler) (ServerPlugin, error) {
+	var tlsOptions option.InboundTLSOptions
+	if _, loaded := pluginOpts.Get("tls"); loaded {
+		tlsOptions.Enabled = true
+	}
+	if certPath, certLoaded := pluginOpts.Get("cert"); certLoaded {
+		tlsOptions.CertificatePath = certPath
+	}
+	if certRaw, certLoaded := pluginOpts.Get("certRaw"); certLoaded {
+		certHead := "-----BEGIN CERTIFICATE-----"
+		certTail := "-----END CERTIFICATE-----"
+		fixedCert := certHead + "\n" + certRaw + "\n" + certTail
+		tlsOptions.Certificate = []string{fixedCert}
+	}
+	if keyPath, keyLoaded := pluginOpts.Get("key"); keyLoaded {
+		tlsOptions.KeyPath = keyPath
+	}
+	if hostOpt, loaded := pluginOpts.Get("host"); loaded {
+		tlsOptions.ServerName = hostOpt
+	}
+
+	mode := "websocket"
+	if modeOpt, loaded := pluginOpts.Get("mode"); loaded {
+		mode = modeOpt
+	}
+
+	path := "/"
+	if pathOpt, loaded := pluginOpts.Get("path"); loaded {
+		path = pathOpt
+	}
+
+	var transportOptions option.V2RayTransportOptions
+	switch mode {
+	case "websocket":
+		transportOptions = option.V2RayTransportOptions{
+			Type: C.V2RayTransportTypeWebsocket,
+			WebsocketOptions: option.V2RayWebsocketOptions{
+				Path: path,
+			},
+		}
+	case "quic":
+		return nil, E.New("v2ray-plugin: quic mode is not supported on server")
+	default:
+		return nil, E.New("v2ray-plugin: unknown mode: " + mode)
+	}
+
+	plugin := &V2RayServer{
+		logger:   logger,
+		handler:  handler,
+		listener: pipelistener.New(16),
+	}
+	if tlsOptions.Enabled {
+		tlsConfig, err := tls.NewServer(ctx, logger, tlsOptions)
+		if err != nil {
+			return nil, err
+		}
+		plugin.tlsConfig = tlsConfig
+	}
+	transport, err := v2ray.NewServerTransport(ctx, logger, transportOptions, plugin.tlsConfig, (*v2rayServerHandler)(plugin))
+	if err != nil {
+		return nil, err
+	}
+	plugin.transport = transport
+	return plugin, nil
+}
+
+// V2RayServer serves v2ray-plugin clients, with or without mux.
+type V2RayServer struct {
+	logger    log.ContextLogger
+	handler   adapter.V2RayServerTransportHandler
+	tlsConfig tls.ServerConfig
+	transport adapter.V2RayServerTransport
+	listener  *pipelistener.Listener
+}
+
+func (s *V2RayServer) Start() error {
+	if s.tlsConfig != nil {
+		err := s.tlsConfig.Start()
+		if err != nil {
+			return err
+		}
+	}
+	go func() {
+		sErr := s.transport.Serve(s.listener)
+		if sErr != nil && !E.IsClosed(sErr) {
+			s.logger.Error("v2ray-plugin serve error: ", sErr)
+		}
+	}()
+	return nil
+}
+
+func (s *V2RayServer) Close() error {
+	return common.Close(
+		s.listener,
+		s.tlsConfig,
+		s.transport,
+	)
+}
+
+func (s *V2RayServer) NewConnectionEx(ctx context.Context, conn net.Conn, source M.Socksaddr, onClose N.CloseHandlerFunc) {
+	if onClose != nil {
+		conn = &closeHandlerConn{Conn: conn, onClose: N.OnceClose(onClose)}
+	}
+	s.listener.ServeContext(ctx, conn)
+}
+
+type v2rayServerHandler V2RayServer
+
+func (h *v2rayServerHandler) NewConnectionEx(ctx context.Context, conn net.Conn, source M.Socksaddr, destination M.Socksaddr, onClose N.CloseHandlerFunc) {
+	h.logger.InfoContext(ctx, "inbound v2ray-plugin connection from ", source)
+	header := buf.NewSize(8)
+	_, err := header.ReadFullFrom(conn, 8)
+	if err != nil {
+		header.Release()
+		N.CloseOnHandshakeFailure(conn, onClose, E.Cause(err, "read v2ray-plugin header"))
+		return
+	}
+	isMux := isMuxFrame(header.Bytes())
+	conn = bufio.NewCachedConn(conn, header)
+	if !isMux {
+		h.handler.NewConnectionEx(ctx, conn, source, destination, onClose)
+		return
+	}
+	err = vmess.HandleMuxConnection(ctx, conn, source, (*v2rayMuxHandler)(h))
+	N.CloseOnHandshakeFailure(conn, onClose, err)
+	if err != nil && !E.IsClosedOrCanceled(err) {
+		h.logger.ErrorContext(ctx, E.Cause(err, "process v2ray-plugin mux connection from ", source))
+	}
+}
+
+// isMuxFrame reports whether header starts a Mux.Cool session, as sent by
+// v2ray-plugin clients with mux enabled, instead of a raw shadowsocks stream.
+func isMuxFrame(header []byte) bool {
+	metadataLength := binary.BigEndian.Uint16(header)
+	if metadataLength < 5 || metadataLength > 5+M.MaxSocksaddrLength {
+		return false
+	}
+	return header[4] == vmess.StatusNew && (header[6] == vmess.NetworkTCP || header[6] == vmess.NetworkUDP)
+}
+
+type v2rayMuxHandler V2RayServer
+
+func (h *v2rayMuxHandler) NewConnectionEx(ctx context.Context, conn net.Conn, source M.Socksaddr, destination M.Socksaddr, onClose N.CloseHandlerFunc) {
+	h.handler.NewConnectionEx(ctx, conn, source, M.Socksaddr{}, onClose)
+}
+
+func (h *v2rayMuxHandler) NewPacketConnectionEx(ctx context.Context, conn N.PacketConn, source M.Socksaddr, destination M.Socksaddr, onClose N.CloseHandlerFunc) {
+	N.CloseOnHandshakeFailure(conn, onClose, E.New("v2ray-plugin: UDP mux streams are not supported"))
+}
+
+type closeHandlerConn struct {
+	net.Conn
+	onClose N.CloseHandlerFunc
+}
+
+func (c *closeHandlerConn) Close() error {
+	err := c.Conn.Close()
+	c.onClose(err)
+	return err
+}
+
+func (c *closeHandlerConn) Upstream() any {
+	return c.Conn
+}
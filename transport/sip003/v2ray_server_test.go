@@ -0,0 +1,50 @@
+package sip003
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sagernet/sing/common/logger"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+	"github.com/sagernet/ws"
+
+	"github.com/stretchr/testify/require"
+)
+
+type contextTestKey struct{}
+
+type contextHandler chan context.Context
+
+func (h contextHandler) NewConnectionEx(ctx context.Context, conn net.Conn, source M.Socksaddr, destination M.Socksaddr, onClose N.CloseHandlerFunc) {
+	h <- ctx
+	conn.Close()
+}
+
+func TestV2RayServerKeepsContext(t *testing.T) {
+	t.Parallel()
+	handler := make(contextHandler, 1)
+	plugin, err := newV2RayServer(context.Background(), logger.NOP(), Args{}, handler)
+	require.NoError(t, err)
+	require.NoError(t, plugin.Start())
+	defer plugin.Close()
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	plugin.NewConnectionEx(context.WithValue(context.Background(), contextTestKey{}, "value"), serverConn, M.Socksaddr{}, nil)
+	dialer := ws.Dialer{
+		NetDial: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return clientConn, nil
+		},
+	}
+	conn, _, _, err := dialer.Dial(context.Background(), "ws://localhost/")
+	require.NoError(t, err)
+	go ws.WriteFrame(conn, ws.MaskFrame(ws.NewBinaryFrame(make([]byte, 16))))
+	select {
+	case ctx := <-handler:
+		require.Equal(t, "value", ctx.Value(contextTestKey{}))
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler not called")
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"encoding/binary"
 	"net"
+	"sync"
 
+	"github.com/sagernet/sing-vmess/vless"
 	"github.com/sagernet/sing/common/auth"
 	"github.com/sagernet/sing/common/buf"
 	"github.com/sagernet/sing/common/bufio"
@@ -21,8 +23,10 @@ type Handler interface {
 }
 
 type Service[K comparable] struct {
+	access          sync.RWMutex
 	users           map[K][56]byte
 	keys            map[[56]byte]K
+	flows           map[K]string
 	handler         Handler
 	fallbackHandler N.TCPConnectionHandlerEx
 	logger          logger.ContextLogger
@@ -32,6 +36,7 @@ func NewService[K comparable](handler Handler, fallbackHandler N.TCPConnectionHa
 	return &Service[K]{
 		users:           make(map[K][56]byte),
 		keys:            make(map[[56]byte]K),
+		flows:           make(map[K]string),
 		handler:         handler,
 		fallbackHandler: fallbackHandler,
 		logger:          logger,
@@ -40,9 +45,10 @@ func NewService[K comparable](handler Handler, fallbackHandler N.TCPConnectionHa
 
 var ErrUserExists = E.New("user already exists")
 
-func (s *Service[K]) UpdateUsers(userList []K, passwordList []string) error {
+func (s *Service[K]) UpdateUsers(userList []K, passwordList []string, flowList []string) error {
 	users := make(map[K][56]byte)
 	keys := make(map[[56]byte]K)
+	flows := make(map[K]string)
 	for i, user := range userList {
 		if _, loaded := users[user]; loaded {
 			return ErrUserExists
@@ -51,11 +57,21 @@ func (s *Service[K]) UpdateUsers(userList []K, passwordList []string) error {
 		if oldUser, loaded := keys[key]; loaded {
 			return E.Extend(ErrUserExists, "password used by ", oldUser)
 		}
+		if flowList[i] != "" {
+			err := CheckFlow(flowList[i])
+			if err != nil {
+				return E.Extend(err, "for user ", user)
+			}
+			flows[user] = flowList[i]
+		}
 		users[user] = key
 		keys[key] = user
 	}
+	s.access.Lock()
 	s.users = users
 	s.keys = keys
+	s.flows = flows
+	s.access.Unlock()
 	return nil
 }
 
@@ -68,7 +84,11 @@ func (s *Service[K]) NewConnection(ctx context.Context, conn net.Conn, source M.
 		return s.fallback(ctx, conn, source, key[:n], E.New("bad request size"), onClose)
 	}
 
-	if user, loaded := s.keys[key]; loaded {
+	s.access.RLock()
+	user, loaded := s.keys[key]
+	flow := s.flows[user]
+	s.access.RUnlock()
+	if loaded {
 		ctx = auth.ContextWithUser(ctx, user)
 	} else {
 		return s.fallback(ctx, conn, source, key[:], E.New("bad request"), onClose)
@@ -104,6 +124,12 @@ func (s *Service[K]) NewConnection(ctx context.Context, conn net.Conn, source M.
 
 	switch command {
 	case CommandTCP:
+		if flow == FlowVision {
+			conn, err = vless.NewVisionConn(conn, conn, VisionUUID(key), s.logger)
+			if err != nil {
+				return E.Cause(err, "initialize vision")
+			}
+		}
 		s.handler.NewConnectionEx(ctx, conn, source, destination, onClose)
 	case CommandUDP:
 		s.handler.NewPacketConnectionEx(ctx, &PacketConn{Conn: conn}, source, destination, onClose)
@@ -0,0 +1,43 @@
+package trojan
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/sagernet/sing/common/logger"
+	M "github.com/sagernet/sing/common/metadata"
+
+	"github.com/stretchr/testify/require"
+)
+
+type readOnlyConn struct {
+	net.Conn
+	reader *bytes.Reader
+}
+
+func (c *readOnlyConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+func TestServiceUpdateUsersConcurrent(t *testing.T) {
+	t.Parallel()
+	service := NewService[int](nil, nil, logger.NOP())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			require.NoError(t, service.UpdateUsers([]int{0}, []string{strconv.Itoa(i)}, []string{""}))
+		}
+	}()
+	key := Key("unknown")
+	for i := 0; i < 100; i++ {
+		conn := &readOnlyConn{reader: bytes.NewReader(key[:])}
+		require.Error(t, service.NewConnection(context.Background(), conn, M.Socksaddr{}, nil))
+	}
+	wg.Wait()
+}
@@ -0,0 +1,47 @@
+package trojan
+
+import (
+	"encoding/hex"
+	"net"
+
+	"github.com/sagernet/sing-vmess/vless"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/logger"
+	M "github.com/sagernet/sing/common/metadata"
+)
+
+const FlowVision = vless.FlowVision
+
+func CheckFlow(flow string) error {
+	switch flow {
+	case "", FlowVision:
+		return nil
+	default:
+		return E.New("unsupported flow: ", flow)
+	}
+}
+
+// VisionUUID returns the user identifier carried by vision padding. Trojan
+// has no user ID, so sing-box uses the leading 16 bytes of the SHA-224
+// password hash. No other implementation defines vision for trojan, so peers
+// must both be sing-box.
+func VisionUUID(key [KeyLength]byte) [16]byte {
+	var uuid [16]byte
+	common.Must1(hex.Decode(uuid[:], key[:32]))
+	return uuid
+}
+
+// NewClientVisionConn writes the request header immediately and wraps the
+// TLS connection to the server with vision.
+func NewClientVisionConn(conn net.Conn, key [KeyLength]byte, destination M.Socksaddr, logger logger.Logger) (net.Conn, error) {
+	err := ClientHandshake(conn, key, destination, nil)
+	if err != nil {
+		return nil, err
+	}
+	visionConn, err := vless.NewVisionConn(conn, conn, VisionUUID(key), logger)
+	if err != nil {
+		return nil, E.Cause(err, "initialize vision")
+	}
+	return visionConn, nil
+}
@@ -0,0 +1,96 @@
+package trojan
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/hex"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	sTLS "github.com/sagernet/sing-box/common/tls"
+	"github.com/sagernet/sing/common/logger"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVisionUUID(t *testing.T) {
+	t.Parallel()
+	// SHA-224 of "password" is d63dc919e201d7bc4c825630d2cf25fdc93d4b2f0d46706d29038d01
+	uuid := VisionUUID(Key("password"))
+	require.Equal(t, "d63dc919e201d7bc4c825630d2cf25fd", hex.EncodeToString(uuid[:]))
+}
+
+func TestCheckFlow(t *testing.T) {
+	t.Parallel()
+	require.NoError(t, CheckFlow(""))
+	require.NoError(t, CheckFlow(FlowVision))
+	require.Error(t, CheckFlow("xtls-rprx-direct"))
+	service := NewService[int](nil, nil, logger.NOP())
+	require.Error(t, service.UpdateUsers([]int{0}, []string{"password"}, []string{"xtls-rprx-direct"}))
+}
+
+type echoHandler struct {
+	destination chan M.Socksaddr
+}
+
+func (h *echoHandler) NewConnectionEx(ctx context.Context, conn net.Conn, source M.Socksaddr, destination M.Socksaddr, onClose N.CloseHandlerFunc) {
+	h.destination <- destination
+	message := make([]byte, 5)
+	_, err := io.ReadFull(conn, message)
+	if err == nil {
+		conn.Write(message)
+	}
+	conn.Close()
+}
+
+func (h *echoHandler) NewPacketConnectionEx(ctx context.Context, conn N.PacketConn, source M.Socksaddr, destination M.Socksaddr, onClose N.CloseHandlerFunc) {
+	conn.Close()
+}
+
+func TestVision(t *testing.T) {
+	t.Parallel()
+	certificate, err := sTLS.GenerateCertificate(time.Now, "example.org")
+	require.NoError(t, err)
+	handler := &echoHandler{destination: make(chan M.Socksaddr, 1)}
+	service := NewService[int](handler, nil, logger.NOP())
+	require.NoError(t, service.UpdateUsers([]int{0}, []string{"password"}, []string{FlowVision}))
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	serverDone := make(chan error, 1)
+	go func() {
+		rawConn, err := listener.Accept()
+		if err != nil {
+			serverDone <- err
+			return
+		}
+		serverConn := tls.Server(rawConn, &tls.Config{Certificates: []tls.Certificate{*certificate}})
+		err = serverConn.Handshake()
+		if err != nil {
+			rawConn.Close()
+			serverDone <- err
+			return
+		}
+		serverDone <- service.NewConnection(context.Background(), serverConn, M.Socksaddr{}, nil)
+	}()
+	rawConn, err := net.Dial("tcp", listener.Addr().String())
+	require.NoError(t, err)
+	defer rawConn.Close()
+	clientConn := tls.Client(rawConn, &tls.Config{ServerName: "example.org", InsecureSkipVerify: true})
+	require.NoError(t, clientConn.Handshake())
+	destination := M.ParseSocksaddr("example.org:443")
+	conn, err := NewClientVisionConn(clientConn, Key("password"), destination, logger.NOP())
+	require.NoError(t, err)
+	_, err = conn.Write([]byte("hello"))
+	require.NoError(t, err)
+	message := make([]byte, 5)
+	_, err = io.ReadFull(conn, message)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(message))
+	require.Equal(t, destination, <-handler.destination)
+	require.NoError(t, <-serverDone)
+}
@@ -0,0 +1,109 @@
+package v2rayfallback
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/logger"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+	"github.com/sagernet/sing/service"
+)
+
+// Dispatcher lets a single HTTP based transport listener serve several
+// endpoints: upgraded connections on extra paths are injected into other
+// inbounds, and requests for unknown paths are proxied to a fallback HTTP
+// server.
+type Dispatcher struct {
+	logger         logger.ContextLogger
+	inboundManager adapter.InboundManager
+	pathDetour     map[string]string
+	fallback       *httputil.ReverseProxy
+}
+
+func NewDispatcher(ctx context.Context, logger logger.ContextLogger, pathDetour map[string]string, fallback *option.ServerOptions) (*Dispatcher, error) {
+	dispatcher := &Dispatcher{
+		logger:     logger,
+		pathDetour: make(map[string]string, len(pathDetour)),
+	}
+	for path, tag := range pathDetour {
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+		if tag == "" {
+			return nil, E.New("missing inbound tag for path: ", path)
+		}
+		dispatcher.pathDetour[path] = tag
+	}
+	if len(dispatcher.pathDetour) > 0 {
+		dispatcher.inboundManager = service.FromContext[adapter.InboundManager](ctx)
+		if dispatcher.inboundManager == nil {
+			return nil, E.New("missing inbound manager")
+		}
+	}
+	if fallback != nil && fallback.Server != "" {
+		fallbackAddr := fallback.Build()
+		if !fallbackAddr.IsValid() || fallbackAddr.Port == 0 {
+			return nil, E.New("invalid fallback address: ", fallbackAddr)
+		}
+		dispatcher.fallback = &httputil.ReverseProxy{
+			Director: func(request *http.Request) {
+				request.URL.Scheme = "http"
+				request.URL.Host = fallbackAddr.String()
+			},
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+					return N.SystemDialer.DialContext(ctx, N.NetworkTCP, fallbackAddr)
+				},
+			},
+			ErrorHandler: func(writer http.ResponseWriter, request *http.Request, err error) {
+				logger.ErrorContext(request.Context(), E.Cause(err, "fallback request from ", request.RemoteAddr))
+				writer.WriteHeader(http.StatusBadGateway)
+			},
+		}
+	}
+	return dispatcher, nil
+}
+
+func (d *Dispatcher) Detour(path string) (string, bool) {
+	tag, loaded := d.pathDetour[path]
+	return tag, loaded
+}
+
+func (d *Dispatcher) NewConnectionEx(ctx context.Context, tag string, conn net.Conn, source M.Socksaddr, onClose N.CloseHandlerFunc) {
+	inbound, loaded := d.inboundManager.Get(tag)
+	if !loaded {
+		N.CloseOnHandshakeFailure(conn, onClose, E.New("inbound detour not found: ", tag))
+		d.logger.ErrorContext(ctx, "process connection from ", source, ": inbound detour not found: ", tag)
+		return
+	}
+	injectable, isInjectable := inbound.(adapter.TCPInjectableInbound)
+	if !isInjectable {
+		N.CloseOnHandshakeFailure(conn, onClose, E.New("inbound detour is not TCP injectable: ", tag))
+		d.logger.ErrorContext(ctx, "process connection from ", source, ": inbound detour is not TCP injectable: ", tag)
+		return
+	}
+	var metadata adapter.InboundContext
+	metadata.Inbound = tag
+	metadata.InboundType = inbound.Type()
+	metadata.Source = source
+	d.logger.DebugContext(ctx, "dispatch connection from ", source, " to inbound/", inbound.Type(), "[", tag, "]")
+	injectable.NewConnectionEx(ctx, conn, metadata, onClose)
+}
+
+// ServeFallback proxies the request to the fallback server, returning false
+// if no fallback server is configured.
+func (d *Dispatcher) ServeFallback(writer http.ResponseWriter, request *http.Request) bool {
+	if d.fallback == nil {
+		return false
+	}
+	d.logger.DebugContext(request.Context(), "fallback request from ", request.RemoteAddr, ": ", request.URL.Path)
+	d.fallback.ServeHTTP(writer, request)
+	return true
+}
@@ -28,6 +28,7 @@ type Client struct {
 	dialer      N.Dialer
 	serverAddr  string
 	serviceName string
+	multiMode   bool
 	dialOptions []grpc.DialOption
 	conn        *grpc.ClientConn
 	connAccess  sync.Mutex
@@ -50,6 +51,12 @@ func NewClient(ctx context.Context, dialer N.Dialer, serverAddr M.Socksaddr, opt
 			PermitWithoutStream: options.PermitWithoutStream,
 		}))
 	}
+	if options.InitialWindowSize > 0 {
+		dialOptions = append(dialOptions, grpc.WithInitialWindowSize(options.InitialWindowSize))
+	}
+	if options.InitialConnectionWindowSize > 0 {
+		dialOptions = append(dialOptions, grpc.WithInitialConnWindowSize(options.InitialConnectionWindowSize))
+	}
 	dialOptions = append(dialOptions, grpc.WithConnectParams(grpc.ConnectParams{
 		Backoff: backoff.Config{
 			BaseDelay:  500 * time.Millisecond,
@@ -68,6 +75,7 @@ func NewClient(ctx context.Context, dialer N.Dialer, serverAddr M.Socksaddr, opt
 		dialer:      dialer,
 		serverAddr:  serverAddr.String(),
 		serviceName: options.ServiceName,
+		multiMode:   options.MultiMode,
 		dialOptions: dialOptions,
 	}, nil
 }
@@ -100,6 +108,14 @@ func (c *Client) DialContext(ctx context.Context) (net.Conn, error) {
 	}
 	client := NewGunServiceClient(clientConn).(GunServiceCustomNameClient)
 	ctx, cancel := common.ContextWithCancelCause(ctx)
+	if c.multiMode {
+		stream, err := client.TunMultiCustomName(ctx, c.serviceName)
+		if err != nil {
+			cancel(err)
+			return nil, err
+		}
+		return NewGRPCMultiConn(stream), nil
+	}
 	stream, err := client.TunCustomName(ctx, c.serviceName)
 	if err != nil {
 		cancel(err)
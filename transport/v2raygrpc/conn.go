@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/sagernet/sing/common/baderror"
+	"github.com/sagernet/sing/common/buf"
 	M "github.com/sagernet/sing/common/metadata"
 	N "github.com/sagernet/sing/common/network"
 )
@@ -84,6 +85,77 @@ func (c *GRPCConn) Upstream() any {
 	return c.GunService
 }
 
+var _ N.VectorisedWriter = (*GRPCMultiConn)(nil)
+
+type GRPCMultiConn struct {
+	*GRPCConn
+	service MultiGunService
+}
+
+func NewGRPCMultiConn(service MultiGunService) *GRPCMultiConn {
+	var hunkService GunService
+	if client, isClient := service.(GunService_TunMultiClient); isClient {
+		hunkService = &clientMultiConnWrapper{multiHunkWrapper{MultiGunService: service}, client}
+	} else {
+		hunkService = &multiHunkWrapper{MultiGunService: service}
+	}
+	return &GRPCMultiConn{
+		GRPCConn: &GRPCConn{GunService: hunkService},
+		service:  service,
+	}
+}
+
+func (c *GRPCMultiConn) WriteVectorised(buffers []*buf.Buffer) error {
+	defer buf.ReleaseMulti(buffers)
+	data := make([][]byte, 0, len(buffers))
+	for _, buffer := range buffers {
+		if !buffer.IsEmpty() {
+			data = append(data, buffer.Bytes())
+		}
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	return baderror.WrapGRPC(c.service.Send(&MultiHunk{Data: data}))
+}
+
+type multiHunkWrapper struct {
+	MultiGunService
+	pending [][]byte
+}
+
+func (w *multiHunkWrapper) Send(hunk *Hunk) error {
+	return w.MultiGunService.Send(&MultiHunk{Data: [][]byte{hunk.Data}})
+}
+
+func (w *multiHunkWrapper) Recv() (*Hunk, error) {
+	for len(w.pending) == 0 {
+		multiHunk, err := w.MultiGunService.Recv()
+		if err != nil {
+			return nil, err
+		}
+		w.pending = multiHunk.Data
+	}
+	data := w.pending[0]
+	w.pending = w.pending[1:]
+	return &Hunk{Data: data}, nil
+}
+
+func (w *multiHunkWrapper) Upstream() any {
+	return w.MultiGunService
+}
+
+var _ N.WriteCloser = (*clientMultiConnWrapper)(nil)
+
+type clientMultiConnWrapper struct {
+	multiHunkWrapper
+	client GunService_TunMultiClient
+}
+
+func (c *clientMultiConnWrapper) CloseWrite() error {
+	return c.client.CloseSend()
+}
+
 var _ N.WriteCloser = (*clientConnWrapper)(nil)
 
 type clientConnWrapper struct {
@@ -12,6 +12,12 @@ type GunService interface {
 	Recv() (*Hunk, error)
 }
 
+type MultiGunService interface {
+	Context() context.Context
+	Send(*MultiHunk) error
+	Recv() (*MultiHunk, error)
+}
+
 func ServerDesc(name string) grpc.ServiceDesc {
 	return grpc.ServiceDesc{
 		ServiceName: name,
@@ -24,6 +30,12 @@ func ServerDesc(name string) grpc.ServiceDesc {
 				ServerStreams: true,
 				ClientStreams: true,
 			},
+			{
+				StreamName:    "TunMulti",
+				Handler:       _GunService_TunMulti_Handler,
+				ServerStreams: true,
+				ClientStreams: true,
+			},
 		},
 		Metadata: "gun.proto",
 	}
@@ -38,11 +50,22 @@ func (c *gunServiceClient) TunCustomName(ctx context.Context, name string, opts
 	return x, nil
 }
 
+func (c *gunServiceClient) TunMultiCustomName(ctx context.Context, name string, opts ...grpc.CallOption) (GunService_TunMultiClient, error) {
+	stream, err := c.cc.NewStream(ctx, &ServerDesc(name).Streams[1], "/"+name+"/TunMulti", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &gunServiceTunMultiClient{stream}
+	return x, nil
+}
+
 var _ GunServiceCustomNameClient = (*gunServiceClient)(nil)
 
 type GunServiceCustomNameClient interface {
 	TunCustomName(ctx context.Context, name string, opts ...grpc.CallOption) (GunService_TunClient, error)
 	Tun(ctx context.Context, opts ...grpc.CallOption) (GunService_TunClient, error)
+	TunMultiCustomName(ctx context.Context, name string, opts ...grpc.CallOption) (GunService_TunMultiClient, error)
+	TunMulti(ctx context.Context, opts ...grpc.CallOption) (GunService_TunMultiClient, error)
 }
 
 func RegisterGunServiceCustomNameServer(s *grpc.Server, srv GunServiceServer, name string) {
@@ -46,18 +46,33 @@ func NewServer(ctx context.Context, logger logger.ContextLogger, options option.
 			Timeout: time.Duration(options.PingTimeout),
 		}))
 	}
+	if options.InitialWindowSize > 0 {
+		serverOptions = append(serverOptions, grpc.InitialWindowSize(options.InitialWindowSize))
+	}
+	if options.InitialConnectionWindowSize > 0 {
+		serverOptions = append(serverOptions, grpc.InitialConnWindowSize(options.InitialConnectionWindowSize))
+	}
 	server := &Server{ctx, logger, handler, grpc.NewServer(serverOptions...)}
 	RegisterGunServiceCustomNameServer(server.server, server, options.ServiceName)
 	return server, nil
 }
 
 func (s *Server) Tun(server GunService_TunServer) error {
-	conn := NewGRPCConn(server)
+	s.handleConn(server.Context(), NewGRPCConn(server))
+	return nil
+}
+
+func (s *Server) TunMulti(server GunService_TunMultiServer) error {
+	s.handleConn(server.Context(), NewGRPCMultiConn(server))
+	return nil
+}
+
+func (s *Server) handleConn(ctx context.Context, conn net.Conn) {
 	var source M.Socksaddr
-	if remotePeer, loaded := peer.FromContext(server.Context()); loaded {
+	if remotePeer, loaded := peer.FromContext(ctx); loaded {
 		source = M.SocksaddrFromNet(remotePeer.Addr)
 	}
-	if grpcMetadata, loaded := gM.FromIncomingContext(server.Context()); loaded {
+	if grpcMetadata, loaded := gM.FromIncomingContext(ctx); loaded {
 		forwardFrom := strings.Join(grpcMetadata.Get("X-Forwarded-For"), ",")
 		if forwardFrom != "" {
 			for _, from := range strings.Split(forwardFrom, ",") {
@@ -73,7 +88,6 @@ func (s *Server) Tun(server GunService_TunServer) error {
 		close(done)
 	}))
 	<-done
-	return nil
 }
 
 func (s *Server) mustEmbedUnimplementedGunServiceServer() {
@@ -14,7 +14,8 @@ import (
 const _ = grpc.SupportPackageIsVersion7
 
 const (
-	GunService_Tun_FullMethodName = "/transport.v2raygrpc.GunService/Tun"
+	GunService_Tun_FullMethodName      = "/transport.v2raygrpc.GunService/Tun"
+	GunService_TunMulti_FullMethodName = "/transport.v2raygrpc.GunService/TunMulti"
 )
 
 // GunServiceClient is the client API for GunService service.
@@ -22,6 +23,7 @@ const (
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type GunServiceClient interface {
 	Tun(ctx context.Context, opts ...grpc.CallOption) (GunService_TunClient, error)
+	TunMulti(ctx context.Context, opts ...grpc.CallOption) (GunService_TunMultiClient, error)
 }
 
 type gunServiceClient struct {
@@ -63,11 +65,43 @@ func (x *gunServiceTunClient) Recv() (*Hunk, error) {
 	return m, nil
 }
 
+func (c *gunServiceClient) TunMulti(ctx context.Context, opts ...grpc.CallOption) (GunService_TunMultiClient, error) {
+	stream, err := c.cc.NewStream(ctx, &GunService_ServiceDesc.Streams[1], GunService_TunMulti_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &gunServiceTunMultiClient{stream}
+	return x, nil
+}
+
+type GunService_TunMultiClient interface {
+	Send(*MultiHunk) error
+	Recv() (*MultiHunk, error)
+	grpc.ClientStream
+}
+
+type gunServiceTunMultiClient struct {
+	grpc.ClientStream
+}
+
+func (x *gunServiceTunMultiClient) Send(m *MultiHunk) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *gunServiceTunMultiClient) Recv() (*MultiHunk, error) {
+	m := new(MultiHunk)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // GunServiceServer is the server API for GunService service.
 // All implementations must embed UnimplementedGunServiceServer
 // for forward compatibility
 type GunServiceServer interface {
 	Tun(GunService_TunServer) error
+	TunMulti(GunService_TunMultiServer) error
 	mustEmbedUnimplementedGunServiceServer()
 }
 
@@ -77,6 +111,10 @@ type UnimplementedGunServiceServer struct{}
 func (UnimplementedGunServiceServer) Tun(GunService_TunServer) error {
 	return status.Errorf(codes.Unimplemented, "method Tun not implemented")
 }
+
+func (UnimplementedGunServiceServer) TunMulti(GunService_TunMultiServer) error {
+	return status.Errorf(codes.Unimplemented, "method TunMulti not implemented")
+}
 func (UnimplementedGunServiceServer) mustEmbedUnimplementedGunServiceServer() {}
 
 // UnsafeGunServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -116,6 +154,32 @@ func (x *gunServiceTunServer) Recv() (*Hunk, error) {
 	return m, nil
 }
 
+func _GunService_TunMulti_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(GunServiceServer).TunMulti(&gunServiceTunMultiServer{stream})
+}
+
+type GunService_TunMultiServer interface {
+	Send(*MultiHunk) error
+	Recv() (*MultiHunk, error)
+	grpc.ServerStream
+}
+
+type gunServiceTunMultiServer struct {
+	grpc.ServerStream
+}
+
+func (x *gunServiceTunMultiServer) Send(m *MultiHunk) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *gunServiceTunMultiServer) Recv() (*MultiHunk, error) {
+	m := new(MultiHunk)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // GunService_ServiceDesc is the grpc.ServiceDesc for GunService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -130,6 +194,12 @@ var GunService_ServiceDesc = grpc.ServiceDesc{
 			ServerStreams: true,
 			ClientStreams: true,
 		},
+		{
+			StreamName:    "TunMulti",
+			Handler:       _GunService_TunMulti_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
 	},
 	Metadata: "transport/v2raygrpc/stream.proto",
 }
@@ -44,6 +44,10 @@ func NewClient(ctx context.Context, dialer N.Dialer, serverAddr M.Socksaddr, opt
 	} else {
 		host = serverAddr.String()
 	}
+	methodName := "Tun"
+	if options.MultiMode {
+		methodName = "TunMulti"
+	}
 	client := &Client{
 		ctx:        ctx,
 		dialer:     dialer,
@@ -57,8 +61,8 @@ func NewClient(ctx context.Context, dialer N.Dialer, serverAddr M.Socksaddr, opt
 		url: &url.URL{
 			Scheme:  "https",
 			Host:    serverAddr.String(),
-			Path:    "/" + options.ServiceName + "/Tun",
-			RawPath: "/" + url.PathEscape(options.ServiceName) + "/Tun",
+			Path:    "/" + options.ServiceName + "/" + methodName,
+			RawPath: "/" + url.PathEscape(options.ServiceName) + "/" + methodName,
 		},
 		host: host,
 	}
@@ -105,6 +109,9 @@ func (c *Client) DialContext(ctx context.Context) (net.Conn, error) {
 			conn.setup(response.Body, nil)
 		}
 	}()
+	if c.options.MultiMode {
+		return &GunMultiConn{conn}, nil
+	}
 	return conn, nil
 }
 
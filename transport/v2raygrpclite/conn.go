@@ -12,7 +12,9 @@ import (
 	"github.com/sagernet/sing/common"
 	"github.com/sagernet/sing/common/baderror"
 	"github.com/sagernet/sing/common/buf"
+	E "github.com/sagernet/sing/common/exceptions"
 	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
 	"github.com/sagernet/sing/common/varbin"
 )
 
@@ -25,8 +27,9 @@ type GunConn struct {
 	writer        io.Writer
 	flusher       http.Flusher
 	create        chan struct{}
-	err           error
-	readRemaining int
+	err              error
+	readRemaining    int
+	messageRemaining int
 }
 
 func newGunConn(reader io.Reader, writer io.Writer, flusher http.Flusher) *GunConn {
@@ -65,31 +68,37 @@ func (c *GunConn) read(b []byte) (n int, err error) {
 		}
 	}
 
-	if c.readRemaining > 0 {
-		if len(b) > c.readRemaining {
-			b = b[:c.readRemaining]
+	for c.readRemaining == 0 {
+		if c.messageRemaining == 0 {
+			// gRPC message header: compressed flag and message length
+			var header [5]byte
+			_, err = io.ReadFull(c.reader, header[:])
+			if err != nil {
+				return
+			}
+			c.messageRemaining = int(binary.BigEndian.Uint32(header[1:]))
+			continue
 		}
-		n, err = c.reader.Read(b)
-		c.readRemaining -= n
-		return
-	}
-
-	_, err = c.reader.Discard(6)
-	if err != nil {
-		return
-	}
-
-	dataLen, err := binary.ReadUvarint(c.reader)
-	if err != nil {
-		return
+		// protobuf tag of the data field, repeated in MultiHunk
+		_, err = c.reader.Discard(1)
+		if err != nil {
+			return
+		}
+		var dataLen uint64
+		dataLen, err = binary.ReadUvarint(c.reader)
+		if err != nil {
+			return
+		}
+		c.messageRemaining -= 1 + varbin.UvarintLen(dataLen) + int(dataLen)
+		if c.messageRemaining < 0 {
+			return 0, E.New("v2ray-grpc: bad message length")
+		}
+		c.readRemaining = int(dataLen)
 	}
 
-	readLen := int(dataLen)
-	c.readRemaining = readLen
-	if len(b) > readLen {
-		b = b[:readLen]
+	if len(b) > c.readRemaining {
+		b = b[:c.readRemaining]
 	}
-
 	n, err = c.reader.Read(b)
 	c.readRemaining -= n
 	return
@@ -133,6 +142,36 @@ func (c *GunConn) WriteBuffer(buffer *buf.Buffer) error {
 	return nil
 }
 
+// writeVectorised writes all buffers in a single MultiHunk message,
+// only valid for connections using multi mode.
+func (c *GunConn) writeVectorised(buffers []*buf.Buffer) error {
+	defer buf.ReleaseMulti(buffers)
+	var messageLen int
+	for _, buffer := range buffers {
+		messageLen += 1 + varbin.UvarintLen(uint64(buffer.Len())) + buffer.Len()
+	}
+	message := buf.NewSize(5 + messageLen)
+	defer message.Release()
+	header := message.Extend(5)
+	header[0] = 0x00
+	binary.BigEndian.PutUint32(header[1:5], uint32(messageLen))
+	for _, buffer := range buffers {
+		dataLen := buffer.Len()
+		fieldHeader := message.Extend(1 + varbin.UvarintLen(uint64(dataLen)))
+		fieldHeader[0] = 0x0A
+		binary.PutUvarint(fieldHeader[1:], uint64(dataLen))
+		common.Must1(message.Write(buffer.Bytes()))
+	}
+	_, err := c.writer.Write(message.Bytes())
+	if err != nil {
+		return baderror.WrapH2(err)
+	}
+	if c.flusher != nil {
+		c.flusher.Flush()
+	}
+	return nil
+}
+
 func (c *GunConn) FrontHeadroom() int {
 	return 6 + binary.MaxVarintLen64
 }
@@ -164,3 +203,17 @@ func (c *GunConn) SetWriteDeadline(t time.Time) error {
 func (c *GunConn) NeedAdditionalReadDeadline() bool {
 	return true
 }
+
+var _ N.VectorisedWriter = (*GunMultiConn)(nil)
+
+type GunMultiConn struct {
+	*GunConn
+}
+
+func (c *GunMultiConn) WriteVectorised(buffers []*buf.Buffer) error {
+	return c.writeVectorised(buffers)
+}
+
+func (c *GunMultiConn) Upstream() any {
+	return c.GunConn
+}
@@ -35,6 +35,7 @@ type Server struct {
 	h2Server   *http2.Server
 	h2cHandler http.Handler
 	path       string
+	multiPath  string
 }
 
 func NewServer(ctx context.Context, logger logger.ContextLogger, options option.V2RayGRPCOptions, tlsConfig tls.ServerConfig, handler adapter.V2RayServerTransportHandler) (*Server, error) {
@@ -43,8 +44,11 @@ func NewServer(ctx context.Context, logger logger.ContextLogger, options option.
 		logger:    logger,
 		handler:   handler,
 		path:      "/" + options.ServiceName + "/Tun",
+		multiPath: "/" + options.ServiceName + "/TunMulti",
 		h2Server: &http2.Server{
-			IdleTimeout: time.Duration(options.IdleTimeout),
+			IdleTimeout:                  time.Duration(options.IdleTimeout),
+			MaxUploadBufferPerStream:     options.InitialWindowSize,
+			MaxUploadBufferPerConnection: options.InitialConnectionWindowSize,
 		},
 	}
 	server.httpServer = &http.Server{
@@ -65,7 +69,7 @@ func (s *Server) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
 		s.h2cHandler.ServeHTTP(writer, request)
 		return
 	}
-	if request.URL.Path != s.path {
+	if request.URL.Path != s.path && request.URL.Path != s.multiPath {
 		s.invalidRequest(writer, request, http.StatusNotFound, E.New("bad path: ", request.URL.Path))
 		return
 	}
@@ -81,7 +85,13 @@ func (s *Server) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
 	writer.Header().Set("TE", "trailers")
 	writer.WriteHeader(http.StatusOK)
 	done := make(chan struct{})
-	conn := v2rayhttp.NewHTTP2Wrapper(newGunConn(request.Body, writer, writer.(http.Flusher)))
+	gunConn := newGunConn(request.Body, writer, writer.(http.Flusher))
+	var conn *v2rayhttp.HTTP2ConnWrapper
+	if request.URL.Path == s.multiPath {
+		conn = v2rayhttp.NewHTTP2Wrapper(&GunMultiConn{gunConn})
+	} else {
+		conn = v2rayhttp.NewHTTP2Wrapper(gunConn)
+	}
 	s.handler.NewConnectionEx(request.Context(), conn, sHttp.SourceAddress(request), M.Socksaddr{}, N.OnceClose(func(it error) {
 		close(done)
 	}))
@@ -12,6 +12,7 @@ import (
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/log"
 	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/transport/v2rayfallback"
 	"github.com/sagernet/sing/common"
 	E "github.com/sagernet/sing/common/exceptions"
 	"github.com/sagernet/sing/common/logger"
@@ -32,16 +33,23 @@ type Server struct {
 	host       string
 	path       string
 	headers    http.Header
+	dispatcher *v2rayfallback.Dispatcher
 }
 
 func NewServer(ctx context.Context, logger logger.ContextLogger, options option.V2RayHTTPUpgradeOptions, tlsConfig tls.ServerConfig, handler adapter.V2RayServerTransportHandler) (*Server, error) {
+	dispatcher, err := v2rayfallback.NewDispatcher(ctx, logger, options.PathDetour, options.Fallback)
+	if err != nil {
+		return nil, err
+	}
 	server := &Server{
-		ctx:       ctx,
-		tlsConfig: tlsConfig,
-		handler:   handler,
-		host:      options.Host,
-		path:      options.Path,
-		headers:   options.Headers.Build(),
+		ctx:        ctx,
+		logger:     logger,
+		tlsConfig:  tlsConfig,
+		handler:    handler,
+		host:       options.Host,
+		path:       options.Path,
+		headers:    options.Headers.Build(),
+		dispatcher: dispatcher,
 	}
 	if !strings.HasPrefix(server.path, "/") {
 		server.path = "/" + server.path
@@ -71,24 +79,25 @@ func (s *Server) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
 		s.invalidRequest(writer, request, http.StatusBadRequest, E.New("bad host: ", host))
 		return
 	}
-	if request.URL.Path != s.path {
-		s.invalidRequest(writer, request, http.StatusNotFound, E.New("bad path: ", request.URL.Path))
+	detour, isDetour := s.dispatcher.Detour(request.URL.Path)
+	if !isDetour && request.URL.Path != s.path {
+		s.fallbackRequest(writer, request, http.StatusNotFound, E.New("bad path: ", request.URL.Path))
 		return
 	}
 	if request.Method != http.MethodGet {
-		s.invalidRequest(writer, request, http.StatusNotFound, E.New("bad method: ", request.Method))
+		s.fallbackRequest(writer, request, http.StatusNotFound, E.New("bad method: ", request.Method))
 		return
 	}
 	if !strings.EqualFold(request.Header.Get("Connection"), "upgrade") {
-		s.invalidRequest(writer, request, http.StatusNotFound, E.New("not a upgrade request"))
+		s.fallbackRequest(writer, request, http.StatusNotFound, E.New("not a upgrade request"))
 		return
 	}
 	if !strings.EqualFold(request.Header.Get("Upgrade"), "websocket") {
-		s.invalidRequest(writer, request, http.StatusNotFound, E.New("not a websocket request"))
+		s.fallbackRequest(writer, request, http.StatusNotFound, E.New("not a websocket request"))
 		return
 	}
 	if request.Header.Get("Sec-WebSocket-Key") != "" {
-		s.invalidRequest(writer, request, http.StatusNotFound, E.New("real websocket request received"))
+		s.fallbackRequest(writer, request, http.StatusNotFound, E.New("real websocket request received"))
 		return
 	}
 	writer.Header().Set("Connection", "upgrade")
@@ -110,9 +119,20 @@ func (s *Server) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
 		s.invalidRequest(writer, request, http.StatusInternalServerError, E.Cause(err, "hijack failed"))
 		return
 	}
+	if isDetour {
+		s.dispatcher.NewConnectionEx(request.Context(), detour, conn, sHttp.SourceAddress(request), nil)
+		return
+	}
 	s.handler.NewConnectionEx(request.Context(), conn, sHttp.SourceAddress(request), M.Socksaddr{}, nil)
 }
 
+func (s *Server) fallbackRequest(writer http.ResponseWriter, request *http.Request, statusCode int, err error) {
+	if s.dispatcher.ServeFallback(writer, request) {
+		return
+	}
+	s.invalidRequest(writer, request, statusCode, err)
+}
+
 func (s *Server) invalidRequest(writer http.ResponseWriter, request *http.Request, statusCode int, err error) {
 	if statusCode > 0 {
 		writer.WriteHeader(statusCode)
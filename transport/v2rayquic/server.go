@@ -35,7 +35,8 @@ type Server struct {
 
 func NewServer(ctx context.Context, logger logger.ContextLogger, options option.V2RayQUICOptions, tlsConfig tls.ServerConfig, handler adapter.V2RayServerTransportHandler) (adapter.V2RayServerTransport, error) {
 	quicConfig := &quic.Config{
-		DisablePathMTUDiscovery: !C.IsLinux && !C.IsWindows,
+		InitialPacketSize:       options.InitialPacketSize,
+		DisablePathMTUDiscovery: !C.QUICPathMTUDiscovery || options.DisablePathMTUDiscovery,
 	}
 	if len(tlsConfig.NextProtos()) == 0 {
 		tlsConfig.SetNextProtos([]string{http3.NextProtoH3})
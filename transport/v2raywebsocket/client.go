@@ -20,6 +20,9 @@ import (
 	N "github.com/sagernet/sing/common/network"
 	sHTTP "github.com/sagernet/sing/protocol/http"
 	"github.com/sagernet/ws"
+	"github.com/sagernet/ws/wsflate"
+
+	"github.com/gobwas/httphead"
 )
 
 var _ adapter.V2RayClientTransport = (*Client)(nil)
@@ -32,6 +35,8 @@ type Client struct {
 	headers             http.Header
 	maxEarlyData        uint32
 	earlyDataHeaderName string
+	compression         bool
+	maxFrameSize        uint32
 }
 
 func NewClient(ctx context.Context, dialer N.Dialer, serverAddr M.Socksaddr, options option.V2RayWebsocketOptions, tlsConfig tls.Config) (adapter.V2RayClientTransport, error) {
@@ -71,6 +76,8 @@ func NewClient(ctx context.Context, dialer N.Dialer, serverAddr M.Socksaddr, opt
 		headers,
 		options.MaxEarlyData,
 		options.EarlyDataHeaderName,
+		options.Compression,
+		options.MaxFrameSize,
 	}, nil
 }
 
@@ -100,11 +107,30 @@ func (c *Client) dialContext(ctx context.Context, requestURL *url.URL, headers h
 		protocols = []string{protocolHeader}
 		headers.Del("Sec-WebSocket-Protocol")
 	}
-	reader, _, err := ws.Dialer{Header: ws.HandshakeHeaderHTTP(headers), Protocols: protocols}.Upgrade(deadlineConn, requestURL)
+	var extensions []httphead.Option
+	if c.compression {
+		extensions = []httphead.Option{wsflate.DefaultParameters.Option()}
+	}
+	reader, handshake, err := ws.Dialer{Header: ws.HandshakeHeaderHTTP(headers), Protocols: protocols, Extensions: extensions}.Upgrade(deadlineConn, requestURL)
 	deadlineConn.SetDeadline(time.Time{})
 	if err != nil {
 		return nil, err
 	}
+	var compression bool
+	for _, extension := range handshake.Extensions {
+		if string(extension.Name) != wsflate.ExtensionName {
+			continue
+		}
+		var parameters wsflate.Parameters
+		err = parameters.Parse(extension)
+		if err != nil {
+			return nil, E.Cause(err, "parse permessage-deflate parameters")
+		}
+		if !parameters.ServerNoContextTakeover {
+			return nil, E.New("server rejected permessage-deflate without context takeover")
+		}
+		compression = true
+	}
 	if reader != nil {
 		buffer := buf.NewSize(reader.Buffered())
 		_, err = buffer.ReadFullFrom(reader, buffer.Len())
@@ -113,7 +139,7 @@ func (c *Client) dialContext(ctx context.Context, requestURL *url.URL, headers h
 		}
 		conn = bufio.NewCachedConn(conn, buffer)
 	}
-	return NewConn(conn, nil, ws.StateClientSide), nil
+	return NewConn(conn, nil, ws.StateClientSide, compression, c.maxFrameSize), nil
 }
 
 func (c *Client) DialContext(ctx context.Context) (net.Conn, error) {
@@ -1,6 +1,7 @@
 package v2raywebsocket
 
 import (
+	"compress/flate"
 	"context"
 	"encoding/base64"
 	"io"
@@ -16,6 +17,7 @@ import (
 	E "github.com/sagernet/sing/common/exceptions"
 	M "github.com/sagernet/sing/common/metadata"
 	"github.com/sagernet/ws"
+	"github.com/sagernet/ws/wsflate"
 	"github.com/sagernet/ws/wsutil"
 )
 
@@ -26,23 +28,37 @@ type WebsocketConn struct {
 	reader         *wsutil.Reader
 	controlHandler wsutil.FrameHandlerFunc
 	remoteAddr     net.Addr
+	messageState   *wsflate.MessageState
+	decompressor   *wsflate.Reader
+	decompressing  bool
 }
 
-func NewConn(conn net.Conn, remoteAddr net.Addr, state ws.State) *WebsocketConn {
+// NewConn creates a websocket connection over an upgraded conn. compression
+// reports whether permessage-deflate is negotiated, and messages larger than
+// maxFrameSize are written as fragments if it is not zero.
+func NewConn(conn net.Conn, remoteAddr net.Addr, state ws.State, compression bool, maxFrameSize uint32) *WebsocketConn {
 	controlHandler := wsutil.ControlFrameHandler(conn, state)
-	return &WebsocketConn{
+	wsConn := &WebsocketConn{
 		Conn:  conn,
 		state: state,
 		reader: &wsutil.Reader{
-			Source:          conn,
-			State:           state,
-			SkipHeaderCheck: !debug.Enabled,
+			Source: conn,
+			State:  state,
+			// compressed frames fail the reserved bits check
+			SkipHeaderCheck: !debug.Enabled || compression,
 			OnIntermediate:  controlHandler,
 		},
 		controlHandler: controlHandler,
 		remoteAddr:     remoteAddr,
 		Writer:         NewWriter(conn, state),
 	}
+	wsConn.Writer.maxFrameSize = int(maxFrameSize)
+	if compression {
+		wsConn.messageState = new(wsflate.MessageState)
+		wsConn.reader.Extensions = []wsutil.RecvExtension{wsConn.messageState}
+		wsConn.Writer.enableCompression()
+	}
+	return wsConn
 }
 
 func (c *WebsocketConn) Close() error {
@@ -61,6 +77,17 @@ func (c *WebsocketConn) Close() error {
 func (c *WebsocketConn) Read(b []byte) (n int, err error) {
 	var header ws.Header
 	for {
+		if c.decompressing {
+			n, err = c.decompressor.Read(b)
+			if err == io.EOF {
+				c.decompressing = false
+				err = nil
+				if n == 0 {
+					continue
+				}
+			}
+			return
+		}
 		n, err = c.reader.Read(b)
 		if n > 0 {
 			err = nil
@@ -87,11 +114,35 @@ func (c *WebsocketConn) Read(b []byte) (n int, err error) {
 			}
 			continue
 		}
+		if c.messageState != nil && c.messageState.IsCompressed() {
+			if c.decompressor == nil {
+				c.decompressor = wsflate.NewReader(c.reader, func(reader io.Reader) wsflate.Decompressor {
+					return flateReader{flate.NewReader(reader)}
+				})
+			} else {
+				c.decompressor.Reset(c.reader)
+			}
+			c.decompressing = true
+		}
 	}
 }
 
+// flateReader lets wsflate.Reader reset the decompressor for each message
+// instead of creating a new one.
+type flateReader struct {
+	io.ReadCloser
+}
+
+func (r flateReader) Reset(reader io.Reader) {
+	r.ReadCloser.(flate.Resetter).Reset(reader, nil)
+}
+
 func (c *WebsocketConn) Write(p []byte) (n int, err error) {
-	err = wsutil.WriteMessage(c.Conn, c.state, ws.OpBinary, p)
+	if !c.Writer.isDirect(len(p)) {
+		err = c.Writer.writeMessage(p)
+	} else {
+		err = wsutil.WriteMessage(c.Conn, c.state, ws.OpBinary, p)
+	}
 	if err != nil {
 		return
 	}
@@ -9,10 +9,12 @@ import (
 	"strings"
 
 	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/pipelistener"
 	"github.com/sagernet/sing-box/common/tls"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/log"
 	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/transport/v2rayfallback"
 	"github.com/sagernet/sing/common"
 	"github.com/sagernet/sing/common/buf"
 	"github.com/sagernet/sing/common/bufio"
@@ -23,6 +25,7 @@ import (
 	aTLS "github.com/sagernet/sing/common/tls"
 	sHttp "github.com/sagernet/sing/protocol/http"
 	"github.com/sagernet/ws"
+	"github.com/sagernet/ws/wsflate"
 )
 
 var _ adapter.V2RayServerTransport = (*Server)(nil)
@@ -36,10 +39,17 @@ type Server struct {
 	path                string
 	maxEarlyData        uint32
 	earlyDataHeaderName string
+	compression         bool
+	maxFrameSize        uint32
 	upgrader            ws.HTTPUpgrader
+	dispatcher          *v2rayfallback.Dispatcher
 }
 
 func NewServer(ctx context.Context, logger logger.ContextLogger, options option.V2RayWebsocketOptions, tlsConfig tls.ServerConfig, handler adapter.V2RayServerTransportHandler) (*Server, error) {
+	dispatcher, err := v2rayfallback.NewDispatcher(ctx, logger, options.PathDetour, options.Fallback)
+	if err != nil {
+		return nil, err
+	}
 	server := &Server{
 		ctx:                 ctx,
 		logger:              logger,
@@ -48,10 +58,13 @@ func NewServer(ctx context.Context, logger logger.ContextLogger, options option.
 		path:                options.Path,
 		maxEarlyData:        options.MaxEarlyData,
 		earlyDataHeaderName: options.EarlyDataHeaderName,
+		compression:         options.Compression,
+		maxFrameSize:        options.MaxFrameSize,
 		upgrader: ws.HTTPUpgrader{
 			Timeout: C.TCPTimeout,
 			Header:  options.Headers.Build(),
 		},
+		dispatcher: dispatcher,
 	}
 	if !strings.HasPrefix(server.path, "/") {
 		server.path = "/" + server.path
@@ -64,16 +77,20 @@ func NewServer(ctx context.Context, logger logger.ContextLogger, options option.
 			return ctx
 		},
 		ConnContext: func(ctx context.Context, c net.Conn) context.Context {
-			return log.ContextWithNewID(ctx)
+			return log.ContextWithNewID(pipelistener.ConnContext(ctx, c))
 		},
 	}
 	return server, nil
 }
 
 func (s *Server) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	if detour, isDetour := s.dispatcher.Detour(request.URL.Path); isDetour {
+		s.serveDetour(writer, request, detour)
+		return
+	}
 	if s.maxEarlyData == 0 || s.earlyDataHeaderName != "" {
 		if request.URL.Path != s.path {
-			s.invalidRequest(writer, request, http.StatusNotFound, E.New("bad path: ", request.URL.Path))
+			s.fallbackRequest(writer, request, http.StatusNotFound, E.New("bad path: ", request.URL.Path))
 			return
 		}
 	}
@@ -87,12 +104,12 @@ func (s *Server) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
 			earlyDataStr := request.URL.RequestURI()[len(s.path):]
 			earlyData, err = base64.RawURLEncoding.DecodeString(earlyDataStr)
 		} else {
-			s.invalidRequest(writer, request, http.StatusNotFound, E.New("bad path: ", request.URL.Path))
+			s.fallbackRequest(writer, request, http.StatusNotFound, E.New("bad path: ", request.URL.Path))
 			return
 		}
 	} else {
 		if request.URL.Path != s.path {
-			s.invalidRequest(writer, request, http.StatusNotFound, E.New("bad path: ", request.URL.Path))
+			s.fallbackRequest(writer, request, http.StatusNotFound, E.New("bad path: ", request.URL.Path))
 			return
 		}
 		earlyDataStr := request.Header.Get(s.earlyDataHeaderName)
@@ -104,19 +121,60 @@ func (s *Server) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
 		s.invalidRequest(writer, request, http.StatusBadRequest, E.Cause(err, "decode early data"))
 		return
 	}
-	wsConn, _, _, err := ws.UpgradeHTTP(request, writer)
+	if !isWebsocketRequest(request) {
+		s.fallbackRequest(writer, request, http.StatusBadRequest, E.New("not a websocket request"))
+		return
+	}
+	wsConn, compression, err := s.upgrade(request, writer)
 	if err != nil {
 		s.invalidRequest(writer, request, 0, E.Cause(err, "upgrade websocket connection"))
 		return
 	}
 	source := sHttp.SourceAddress(request)
-	conn = NewConn(wsConn, source, ws.StateServerSide)
+	conn = NewConn(wsConn, source, ws.StateServerSide, compression, s.maxFrameSize)
 	if len(earlyData) > 0 {
 		conn = bufio.NewCachedConn(conn, buf.As(earlyData))
 	}
 	s.handler.NewConnectionEx(request.Context(), conn, source, M.Socksaddr{}, nil)
 }
 
+func (s *Server) upgrade(request *http.Request, writer http.ResponseWriter) (net.Conn, bool, error) {
+	if !s.compression {
+		wsConn, _, _, err := ws.UpgradeHTTP(request, writer)
+		return wsConn, false, err
+	}
+	extension := wsflate.Extension{Parameters: wsflate.DefaultParameters}
+	wsConn, _, _, err := ws.HTTPUpgrader{Negotiate: extension.Negotiate}.Upgrade(request, writer)
+	if err != nil {
+		return nil, false, err
+	}
+	_, compression := extension.Accepted()
+	return wsConn, compression, nil
+}
+
+func (s *Server) serveDetour(writer http.ResponseWriter, request *http.Request, detour string) {
+	wsConn, compression, err := s.upgrade(request, writer)
+	if err != nil {
+		s.invalidRequest(writer, request, 0, E.Cause(err, "upgrade websocket connection"))
+		return
+	}
+	source := sHttp.SourceAddress(request)
+	s.dispatcher.NewConnectionEx(request.Context(), detour, NewConn(wsConn, source, ws.StateServerSide, compression, s.maxFrameSize), source, nil)
+}
+
+func (s *Server) fallbackRequest(writer http.ResponseWriter, request *http.Request, statusCode int, err error) {
+	if s.dispatcher.ServeFallback(writer, request) {
+		return
+	}
+	s.invalidRequest(writer, request, statusCode, err)
+}
+
+func isWebsocketRequest(request *http.Request) bool {
+	return request.Method == http.MethodGet &&
+		strings.EqualFold(request.Header.Get("Upgrade"), "websocket") &&
+		request.Header.Get("Sec-WebSocket-Key") != ""
+}
+
 func (s *Server) invalidRequest(writer http.ResponseWriter, request *http.Request, statusCode int, err error) {
 	if statusCode > 0 {
 		writer.WriteHeader(statusCode)
@@ -1,29 +1,102 @@
 package v2raywebsocket
 
 import (
+	"bytes"
+	"compress/flate"
 	"encoding/binary"
 	"io"
 	"math/rand"
 
+	"github.com/sagernet/sing/common"
 	"github.com/sagernet/sing/common/buf"
 	"github.com/sagernet/sing/common/bufio"
 	N "github.com/sagernet/sing/common/network"
 	"github.com/sagernet/ws"
+	"github.com/sagernet/ws/wsflate"
 )
 
+const frameHeadroom = 14
+
 type Writer struct {
-	writer   N.ExtendedWriter
-	isServer bool
+	writer       N.ExtendedWriter
+	isServer     bool
+	maxFrameSize int
+	compressor   *wsflate.Writer
+	compressed   bytes.Buffer
 }
 
 func NewWriter(writer io.Writer, state ws.State) *Writer {
 	return &Writer{
-		bufio.NewExtendedWriter(writer),
-		state == ws.StateServerSide,
+		writer:   bufio.NewExtendedWriter(writer),
+		isServer: state == ws.StateServerSide,
 	}
 }
 
+// enableCompression compresses each message with the permessage-deflate
+// extension, without context takeover.
+func (w *Writer) enableCompression() {
+	w.compressor = wsflate.NewWriter(nil, func(writer io.Writer) wsflate.Compressor {
+		compressor, _ := flate.NewWriter(writer, flate.BestSpeed)
+		return compressor
+	})
+}
+
+func (w *Writer) isDirect(dataLen int) bool {
+	return w.compressor == nil && (w.maxFrameSize == 0 || dataLen <= w.maxFrameSize)
+}
+
 func (w *Writer) WriteBuffer(buffer *buf.Buffer) error {
+	if !w.isDirect(buffer.Len()) {
+		defer buffer.Release()
+		return w.writeMessage(buffer.Bytes())
+	}
+	return w.writeFrame(buffer, ws.OpBinary, true, false)
+}
+
+// writeMessage writes p as a binary message, compressed and split into
+// fragments of maxFrameSize as configured.
+func (w *Writer) writeMessage(p []byte) error {
+	var compressed bool
+	if w.compressor != nil {
+		w.compressed.Reset()
+		w.compressor.Reset(&w.compressed)
+		_, err := w.compressor.Write(p)
+		if err != nil {
+			return err
+		}
+		err = w.compressor.Flush()
+		if err != nil {
+			return err
+		}
+		p = w.compressed.Bytes()
+		compressed = true
+	}
+	opCode := ws.OpBinary
+	for {
+		frameLen := len(p)
+		fin := true
+		if w.maxFrameSize > 0 && frameLen > w.maxFrameSize {
+			frameLen = w.maxFrameSize
+			fin = false
+		}
+		frame := buf.NewSize(frameHeadroom + frameLen)
+		frame.Resize(frameHeadroom, 0)
+		common.Must1(frame.Write(p[:frameLen]))
+		err := w.writeFrame(frame, opCode, fin, compressed)
+		if err != nil {
+			return err
+		}
+		if fin {
+			return nil
+		}
+		p = p[frameLen:]
+		opCode = ws.OpContinuation
+		// only the first fragment carries the compression bit
+		compressed = false
+	}
+}
+
+func (w *Writer) writeFrame(buffer *buf.Buffer, opCode ws.OpCode, fin bool, compressed bool) error {
 	var payloadBitLength int
 	dataLen := buffer.Len()
 	data := buffer.Bytes()
@@ -43,7 +116,13 @@ func (w *Writer) WriteBuffer(buffer *buf.Buffer) error {
 	}
 
 	header := buffer.ExtendHeader(headerLen)
-	header[0] = byte(ws.OpBinary) | 0x80
+	header[0] = byte(opCode)
+	if fin {
+		header[0] |= 0x80
+	}
+	if compressed {
+		header[0] |= 0x40
+	}
 	if w.isServer {
 		header[1] = 0
 	} else {
@@ -70,5 +149,5 @@ func (w *Writer) WriteBuffer(buffer *buf.Buffer) error {
 }
 
 func (w *Writer) FrontHeadroom() int {
-	return 14
+	return frameHeadroom
 }
@@ -0,0 +1,171 @@
+//go:build linux
+
+package wireguard
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"time"
+
+	"github.com/sagernet/netlink"
+	"github.com/sagernet/sing-tun"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+
+	"golang.org/x/sys/unix"
+	"golang.zx2c4.com/wireguard/wgctrl"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+const kernelLinkType = "wireguard"
+
+var _ N.Dialer = (*kernelDevice)(nil)
+
+// kernelDevice drives a WireGuard interface of the Linux kernel module, so
+// packets are encrypted in the kernel instead of by wireguard-go.
+type kernelDevice struct {
+	options    DeviceOptions
+	privateKey wgtypes.Key
+	listenPort uint16
+	fwmark     uint32
+	dialer     N.Dialer
+	link       netlink.Link
+}
+
+func newKernelDevice(options DeviceOptions, privateKey []byte, listenPort uint16, fwmark uint32) (*kernelDevice, error) {
+	key, err := wgtypes.NewKey(privateKey)
+	if err != nil {
+		return nil, E.Cause(err, "parse private key")
+	}
+	if options.Name == "" {
+		options.Name = tun.CalculateInterfaceName("wg")
+	}
+	return &kernelDevice{
+		options:    options,
+		privateKey: key,
+		listenPort: listenPort,
+		fwmark:     fwmark,
+		dialer:     options.CreateDialer(options.Name),
+	}, nil
+}
+
+func (w *kernelDevice) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	return w.dialer.DialContext(ctx, network, destination)
+}
+
+func (w *kernelDevice) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
+	return w.dialer.ListenPacket(ctx, destination)
+}
+
+func (w *kernelDevice) Start(peers []peerConfig) error {
+	existsLink, err := netlink.LinkByName(w.options.Name)
+	if err == nil {
+		// left over from an unclean exit
+		if existsLink.Type() != kernelLinkType {
+			return E.New("interface ", w.options.Name, " already exists")
+		}
+		err = netlink.LinkDel(existsLink)
+		if err != nil {
+			return E.Cause(err, "remove existing interface")
+		}
+	}
+	link := &netlink.Wireguard{
+		LinkAttrs: netlink.LinkAttrs{
+			Name: w.options.Name,
+			MTU:  int(w.options.MTU),
+		},
+	}
+	err = netlink.LinkAdd(link)
+	if err != nil {
+		if errors.Is(err, unix.EOPNOTSUPP) {
+			return E.Cause(err, "create kernel WireGuard interface (is the wireguard module loaded?)")
+		}
+		return E.Cause(err, "create kernel WireGuard interface")
+	}
+	w.link = link
+	err = w.configure(peers)
+	if err != nil {
+		netlink.LinkDel(link)
+		w.link = nil
+		return err
+	}
+	w.options.Logger.Info("started at ", w.options.Name, " in kernel mode")
+	return nil
+}
+
+func (w *kernelDevice) configure(peers []peerConfig) error {
+	client, err := wgctrl.New()
+	if err != nil {
+		return E.Cause(err, "open WireGuard control")
+	}
+	defer client.Close()
+	config := wgtypes.Config{
+		PrivateKey:   &w.privateKey,
+		ReplacePeers: true,
+	}
+	if w.listenPort != 0 {
+		listenPort := int(w.listenPort)
+		config.ListenPort = &listenPort
+	}
+	if w.fwmark != 0 {
+		fwmark := int(w.fwmark)
+		config.FirewallMark = &fwmark
+	}
+	for _, peer := range peers {
+		peerConfig := wgtypes.PeerConfig{
+			ReplaceAllowedIPs: true,
+		}
+		copy(peerConfig.PublicKey[:], peer.publicKey)
+		if peer.preSharedKey != nil {
+			var preSharedKey wgtypes.Key
+			copy(preSharedKey[:], peer.preSharedKey)
+			peerConfig.PresharedKey = &preSharedKey
+		}
+		if peer.endpoint.IsValid() {
+			peerConfig.Endpoint = net.UDPAddrFromAddrPort(peer.endpoint)
+		}
+		if peer.keepalive > 0 {
+			keepalive := time.Duration(peer.keepalive) * time.Second
+			peerConfig.PersistentKeepaliveInterval = &keepalive
+		}
+		for _, allowedIP := range peer.allowedIPs {
+			peerConfig.AllowedIPs = append(peerConfig.AllowedIPs, prefixToIPNet(allowedIP))
+		}
+		config.Peers = append(config.Peers, peerConfig)
+	}
+	err = client.ConfigureDevice(w.options.Name, config)
+	if err != nil {
+		return E.Cause(err, "configure kernel WireGuard interface")
+	}
+	for _, address := range w.options.Address {
+		addressNet := prefixToIPNet(address)
+		err = netlink.AddrAdd(w.link, &netlink.Addr{IPNet: &addressNet})
+		if err != nil {
+			return E.Cause(err, "add address ", address)
+		}
+	}
+	err = netlink.LinkSetUp(w.link)
+	if err != nil {
+		return E.Cause(err, "set interface up")
+	}
+	return nil
+}
+
+func (w *kernelDevice) Close() error {
+	if w.link == nil {
+		return nil
+	}
+	err := netlink.LinkDel(w.link)
+	w.link = nil
+	return err
+}
+
+func prefixToIPNet(prefix netip.Prefix) net.IPNet {
+	return net.IPNet{
+		IP:   prefix.Addr().AsSlice(),
+		Mask: net.CIDRMask(prefix.Bits(), prefix.Addr().BitLen()),
+	}
+}
@@ -0,0 +1,36 @@
+//go:build !linux
+
+package wireguard
+
+import (
+	"context"
+	"net"
+
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+var _ N.Dialer = (*kernelDevice)(nil)
+
+type kernelDevice struct{}
+
+func newKernelDevice(options DeviceOptions, privateKey []byte, listenPort uint16, fwmark uint32) (*kernelDevice, error) {
+	return nil, E.New("kernel mode is only supported on Linux")
+}
+
+func (w *kernelDevice) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	return nil, E.New("kernel mode is only supported on Linux")
+}
+
+func (w *kernelDevice) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
+	return nil, E.New("kernel mode is only supported on Linux")
+}
+
+func (w *kernelDevice) Start(peers []peerConfig) error {
+	return E.New("kernel mode is only supported on Linux")
+}
+
+func (w *kernelDevice) Close() error {
+	return nil
+}
@@ -2,6 +2,7 @@ package wireguard
 
 import (
 	"context"
+	"crypto/ecdh"
 	"encoding/base64"
 	"encoding/hex"
 	"fmt"
@@ -9,11 +10,14 @@ import (
 	"net/netip"
 	"os"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/sagernet/sing/common"
 	E "github.com/sagernet/sing/common/exceptions"
 	F "github.com/sagernet/sing/common/format"
 	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
 	"github.com/sagernet/sing/common/x/list"
 	"github.com/sagernet/sing/service"
 	"github.com/sagernet/sing/service/pause"
@@ -29,9 +33,21 @@ type Endpoint struct {
 	ipcConf        string
 	allowedAddress []netip.Prefix
 	tunDevice      Device
+	kernelDevice   *kernelDevice
+	dialer         N.Dialer
 	device         *device.Device
 	pauseManager   pause.Manager
 	pauseCallback  *list.Element[pause.Callback]
+	amnezia        *amneziaObfuscator
+	obfuscated     bool
+	onDemand       bool
+	access         sync.Mutex
+	closed         bool
+	paused         bool
+	idle           bool
+	connections    int
+	idleSince      time.Time
+	idleTimer      *time.Timer
 }
 
 func NewEndpoint(options EndpointOptions) (*Endpoint, error) {
@@ -62,12 +78,14 @@ func NewEndpoint(options EndpointOptions) (*Endpoint, error) {
 		if err != nil {
 			return nil, E.Cause(err, "decode public key for peer ", peerIndex)
 		}
+		peer.publicKey = publicKeyBytes
 		peer.publicKeyHex = hex.EncodeToString(publicKeyBytes)
 		if rawPeer.PreSharedKey != "" {
 			preSharedKeyBytes, err := base64.StdEncoding.DecodeString(rawPeer.PreSharedKey)
 			if err != nil {
 				return nil, E.Cause(err, "decode pre shared key for peer ", peerIndex)
 			}
+			peer.preSharedKey = preSharedKeyBytes
 			peer.preSharedKeyHex = hex.EncodeToString(preSharedKeyBytes)
 		}
 		if len(rawPeer.AllowedIPs) == 0 {
@@ -95,6 +113,34 @@ func NewEndpoint(options EndpointOptions) (*Endpoint, error) {
 	if options.MTU == 0 {
 		options.MTU = 1408
 	}
+	if options.Kernel {
+		return newKernelEndpoint(options, privateKeyBytes, peers, allowedAddresses)
+	}
+	var (
+		obfuscators []packetObfuscator
+		amnezia     *amneziaObfuscator
+	)
+	if options.Amnezia != nil {
+		privateKey, err := ecdh.X25519().NewPrivateKey(privateKeyBytes)
+		if err != nil {
+			return nil, E.Cause(err, "parse private key")
+		}
+		amnezia, err = newAmneziaObfuscator(*options.Amnezia, privateKey.PublicKey().Bytes())
+		if err != nil {
+			return nil, E.Cause(err, "create amnezia obfuscator")
+		}
+		amnezia.SetPeers(peers)
+		obfuscators = append(obfuscators, amnezia)
+	}
+	if options.Obfuscation != nil {
+		if options.Obfuscation.Password == "" {
+			return nil, E.New("missing obfuscation password")
+		}
+		obfuscators = append(obfuscators, newSaltedObfuscator(options.Obfuscation.Password, amnezia != nil))
+	}
+	if len(obfuscators) > 0 {
+		options.Dialer = newObfsDialer(options.Dialer, options.ListenPort, obfuscators...)
+	}
 	deviceOptions := DeviceOptions{
 		Context:        options.Context,
 		Logger:         options.Logger,
@@ -117,29 +163,100 @@ func NewEndpoint(options EndpointOptions) (*Endpoint, error) {
 		ipcConf:        ipcConf,
 		allowedAddress: allowedAddresses,
 		tunDevice:      tunDevice,
+		dialer:         tunDevice,
+		amnezia:        amnezia,
+		obfuscated:     len(obfuscators) > 0,
+		onDemand:       options.LazyStart || options.IdleTimeout > 0,
+	}, nil
+}
+
+func newKernelEndpoint(options EndpointOptions, privateKey []byte, peers []peerConfig, allowedAddresses []netip.Prefix) (*Endpoint, error) {
+	if !options.System {
+		return nil, E.New("kernel mode requires system interface")
+	}
+	if options.Obfuscation != nil || options.Amnezia != nil {
+		return nil, E.New("obfuscation is not supported in kernel mode")
+	}
+	if options.LazyStart || options.IdleTimeout > 0 {
+		return nil, E.New("lazy start and idle timeout are not supported in kernel mode")
+	}
+	for peerIndex, peer := range peers {
+		if peer.reserved != [3]uint8{} {
+			return nil, E.New("reserved is not supported in kernel mode, peer ", peerIndex)
+		}
+	}
+	kernelDevice, err := newKernelDevice(DeviceOptions{
+		Context:        options.Context,
+		Logger:         options.Logger,
+		System:         true,
+		CreateDialer:   options.CreateDialer,
+		Name:           options.Name,
+		MTU:            options.MTU,
+		Address:        options.Address,
+		AllowedAddress: allowedAddresses,
+	}, privateKey, options.ListenPort, options.RoutingMark)
+	if err != nil {
+		return nil, E.Cause(err, "create WireGuard device")
+	}
+	return &Endpoint{
+		options:        options,
+		peers:          peers,
+		allowedAddress: allowedAddresses,
+		kernelDevice:   kernelDevice,
+		dialer:         kernelDevice,
 	}, nil
 }
 
 func (e *Endpoint) Start(resolve bool) error {
+	if e.options.LazyStart {
+		return nil
+	}
 	if common.Any(e.peers, func(peer peerConfig) bool {
 		return !peer.endpoint.IsValid() && peer.destination.IsFqdn()
 	}) {
 		if !resolve {
 			return nil
 		}
-		for peerIndex, peer := range e.peers {
-			if peer.endpoint.IsValid() || !peer.destination.IsFqdn() {
-				continue
-			}
-			destinationAddress, err := e.options.ResolvePeer(peer.destination.Fqdn)
-			if err != nil {
-				return E.Cause(err, "resolve endpoint domain for peer[", peerIndex, "]: ", peer.destination)
-			}
-			e.peers[peerIndex].endpoint = netip.AddrPortFrom(destinationAddress, peer.destination.Port)
+		err := e.resolvePeers()
+		if err != nil {
+			return err
 		}
 	} else if resolve {
 		return nil
 	}
+	e.access.Lock()
+	defer e.access.Unlock()
+	err := e.startDevice()
+	if err != nil {
+		return err
+	}
+	if e.options.IdleTimeout > 0 {
+		e.resetIdleTimer()
+	}
+	return nil
+}
+
+func (e *Endpoint) resolvePeers() error {
+	for peerIndex, peer := range e.peers {
+		if peer.endpoint.IsValid() || !peer.destination.IsFqdn() {
+			continue
+		}
+		destinationAddress, err := e.options.ResolvePeer(peer.destination.Fqdn)
+		if err != nil {
+			return E.Cause(err, "resolve endpoint domain for peer[", peerIndex, "]: ", peer.destination)
+		}
+		e.peers[peerIndex].endpoint = netip.AddrPortFrom(destinationAddress, peer.destination.Port)
+	}
+	if e.amnezia != nil {
+		e.amnezia.SetPeers(e.peers)
+	}
+	return nil
+}
+
+func (e *Endpoint) startDevice() error {
+	if e.kernelDevice != nil {
+		return e.kernelDevice.Start(e.peers)
+	}
 	var bind conn.Bind
 	wgListener, isWgListener := e.options.Dialer.(conn.Listener)
 	if isWgListener {
@@ -150,7 +267,9 @@ func (e *Endpoint) Start(resolve bool) error {
 			connectAddr netip.AddrPort
 			reserved    [3]uint8
 		)
-		if len(e.peers) == 1 {
+		// The obfuscating dialer listens on listen_port itself and needs a known peer
+		// endpoint to connect, otherwise the bind is left unconnected.
+		if len(e.peers) == 1 && (!e.obfuscated || e.peers[0].endpoint.IsValid() && e.options.ListenPort == 0) {
 			isConnect = true
 			connectAddr = e.peers[0].endpoint
 			reserved = e.peers[0].reserved
@@ -198,24 +317,62 @@ func (e *Endpoint) DialContext(ctx context.Context, network string, destination
 	if !destination.Addr.IsValid() {
 		return nil, E.Cause(os.ErrInvalid, "invalid non-IP destination")
 	}
-	return e.tunDevice.DialContext(ctx, network, destination)
+	if !e.onDemand {
+		return e.dialer.DialContext(ctx, network, destination)
+	}
+	err := e.acquire()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := e.dialer.DialContext(ctx, network, destination)
+	if err != nil {
+		e.release()
+		return nil, err
+	}
+	return &onDemandConn{Conn: conn, endpoint: e}, nil
 }
 
 func (e *Endpoint) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
 	if !destination.Addr.IsValid() {
 		return nil, E.Cause(os.ErrInvalid, "invalid non-IP destination")
 	}
-	return e.tunDevice.ListenPacket(ctx, destination)
+	if !e.onDemand {
+		return e.dialer.ListenPacket(ctx, destination)
+	}
+	err := e.acquire()
+	if err != nil {
+		return nil, err
+	}
+	packetConn, err := e.dialer.ListenPacket(ctx, destination)
+	if err != nil {
+		e.release()
+		return nil, err
+	}
+	return &onDemandPacketConn{PacketConn: packetConn, endpoint: e}, nil
 }
 
 func (e *Endpoint) BindUpdate() error {
+	e.access.Lock()
+	defer e.access.Unlock()
+	if e.device == nil {
+		return nil
+	}
 	return e.device.BindUpdate()
 }
 
 func (e *Endpoint) Close() error {
+	e.access.Lock()
+	defer e.access.Unlock()
+	e.closed = true
+	if e.idleTimer != nil {
+		e.idleTimer.Stop()
+	}
 	if e.device != nil {
 		e.device.Close()
 	}
+	if e.kernelDevice != nil {
+		e.kernelDevice.Close()
+	}
 	if e.pauseCallback != nil {
 		e.pauseManager.UnregisterCallback(e.pauseCallback)
 	}
@@ -223,18 +380,27 @@ func (e *Endpoint) Close() error {
 }
 
 func (e *Endpoint) onPauseUpdated(event int) {
+	e.access.Lock()
+	defer e.access.Unlock()
 	switch event {
 	case pause.EventDevicePaused:
+		e.paused = true
 		e.device.Down()
 	case pause.EventDeviceWake:
-		e.device.Up()
+		e.paused = false
+		// stay down if idle, the next connection brings the device up
+		if !e.idle {
+			e.device.Up()
+		}
 	}
 }
 
 type peerConfig struct {
 	destination     M.Socksaddr
 	endpoint        netip.AddrPort
+	publicKey       []byte
 	publicKeyHex    string
+	preSharedKey    []byte
 	preSharedKeyHex string
 	allowedIPs      []netip.Prefix
 	keepalive       uint16
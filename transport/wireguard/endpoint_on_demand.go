@@ -0,0 +1,102 @@
+package wireguard
+
+import (
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// acquire starts the device on first use, or brings it up again if it has
+// been taken down after being idle, and holds it up until release is called.
+func (e *Endpoint) acquire() error {
+	e.access.Lock()
+	defer e.access.Unlock()
+	if e.closed {
+		return os.ErrClosed
+	}
+	if e.device == nil {
+		err := e.resolvePeers()
+		if err != nil {
+			return err
+		}
+		err = e.startDevice()
+		if err != nil {
+			return err
+		}
+		e.options.Logger.Info("started on demand")
+	} else if e.idle {
+		e.idle = false
+		if !e.paused {
+			err := e.device.Up()
+			if err != nil {
+				return err
+			}
+		}
+		e.options.Logger.Info("resumed from idle")
+	}
+	e.connections++
+	return nil
+}
+
+func (e *Endpoint) release() {
+	e.access.Lock()
+	defer e.access.Unlock()
+	e.connections--
+	if e.connections == 0 && e.options.IdleTimeout > 0 {
+		e.resetIdleTimer()
+	}
+}
+
+func (e *Endpoint) resetIdleTimer() {
+	e.idleSince = time.Now()
+	if e.idleTimer == nil {
+		e.idleTimer = time.AfterFunc(e.options.IdleTimeout, e.onIdle)
+	} else {
+		e.idleTimer.Reset(e.options.IdleTimeout)
+	}
+}
+
+func (e *Endpoint) onIdle() {
+	e.access.Lock()
+	defer e.access.Unlock()
+	// the timer may have fired while a connection was being released
+	if e.closed || e.idle || e.connections > 0 || time.Since(e.idleSince) < e.options.IdleTimeout {
+		return
+	}
+	e.idle = true
+	if !e.paused {
+		e.device.Down()
+	}
+	e.options.Logger.Info("idle for ", e.options.IdleTimeout, ", down until next connection")
+}
+
+type onDemandConn struct {
+	net.Conn
+	endpoint  *Endpoint
+	closeOnce sync.Once
+}
+
+func (c *onDemandConn) Close() error {
+	c.closeOnce.Do(c.endpoint.release)
+	return c.Conn.Close()
+}
+
+func (c *onDemandConn) Upstream() any {
+	return c.Conn
+}
+
+type onDemandPacketConn struct {
+	net.PacketConn
+	endpoint  *Endpoint
+	closeOnce sync.Once
+}
+
+func (c *onDemandPacketConn) Close() error {
+	c.closeOnce.Do(c.endpoint.release)
+	return c.PacketConn.Close()
+}
+
+func (c *onDemandPacketConn) Upstream() any {
+	return c.PacketConn
+}
@@ -15,6 +15,8 @@ type EndpointOptions struct {
 	Context      context.Context
 	Logger       logger.ContextLogger
 	System       bool
+	Kernel       bool
+	RoutingMark  uint32
 	Handler      tun.Handler
 	UDPTimeout   time.Duration
 	Dialer       N.Dialer
@@ -27,6 +29,26 @@ type EndpointOptions struct {
 	ResolvePeer  func(domain string) (netip.Addr, error)
 	Peers        []PeerOptions
 	Workers      int
+	Obfuscation  *ObfuscationOptions
+	Amnezia      *AmneziaOptions
+	LazyStart    bool
+	IdleTimeout  time.Duration
+}
+
+type ObfuscationOptions struct {
+	Password string
+}
+
+type AmneziaOptions struct {
+	JunkPacketCount            int
+	JunkPacketMinSize          int
+	JunkPacketMaxSize          int
+	InitPacketJunkSize         int
+	ResponsePacketJunkSize     int
+	InitPacketMagicHeader      uint32
+	ResponsePacketMagicHeader  uint32
+	UnderloadPacketMagicHeader uint32
+	TransportPacketMagicHeader uint32
 }
 
 type PeerOptions struct {
@@ -0,0 +1,11 @@
+package wireguard
+
+// WireGuard message types, the first byte of the little endian message type field.
+const (
+	messageInitiationType  = 1
+	messageResponseType    = 2
+	messageCookieReplyType = 3
+	messageTransportType   = 4
+)
+
+const messageHeaderLength = 4
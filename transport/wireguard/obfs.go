@@ -0,0 +1,151 @@
+package wireguard
+
+import (
+	"context"
+	"net"
+	"net/netip"
+
+	"github.com/sagernet/sing/common"
+	F "github.com/sagernet/sing/common/format"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+	"github.com/sagernet/wireguard-go/conn"
+)
+
+// packetObfuscator transforms WireGuard packets on the wire.
+type packetObfuscator interface {
+	// Obfuscate passes the datagrams to send for the packet to write, in order.
+	// Datagrams may use pooled buffers and are only valid during the call.
+	Obfuscate(packet []byte, destination netip.AddrPort, write func(datagram []byte) error) error
+	// Deobfuscate restores the packet in place and returns its length,
+	// datagrams that do not carry a packet are dropped.
+	Deobfuscate(datagram []byte, source netip.AddrPort) (int, bool)
+}
+
+type obfsDialer struct {
+	N.Dialer
+	listenPort  uint16
+	obfuscators []packetObfuscator
+}
+
+// newObfsDialer wraps the dialer so that packets pass through obfuscators in order on write,
+// and in reverse order on read.
+func newObfsDialer(dialer N.Dialer, listenPort uint16, obfuscators ...packetObfuscator) N.Dialer {
+	return &obfsDialer{
+		Dialer:      dialer,
+		listenPort:  listenPort,
+		obfuscators: obfuscators,
+	}
+}
+
+func (d *obfsDialer) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	conn, err := d.Dialer.DialContext(ctx, network, destination)
+	if err != nil {
+		return nil, err
+	}
+	if N.NetworkName(network) != N.NetworkUDP {
+		return conn, nil
+	}
+	return &obfsConn{
+		Conn:        conn,
+		destination: destination.AddrPort(),
+		obfuscators: d.obfuscators,
+	}, nil
+}
+
+func (d *obfsDialer) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
+	var (
+		packetConn net.PacketConn
+		err        error
+	)
+	// The bind of the device is replaced when obfuscating, so listen on the port here.
+	if listener, isListener := d.Dialer.(conn.Listener); isListener && d.listenPort != 0 {
+		packetConn, err = listener.ListenPacketCompat(N.NetworkUDP, ":"+F.ToString(d.listenPort))
+	} else {
+		packetConn, err = d.Dialer.ListenPacket(ctx, destination)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &obfsPacketConn{
+		PacketConn:  packetConn,
+		obfuscators: d.obfuscators,
+	}, nil
+}
+
+type obfsConn struct {
+	net.Conn
+	destination netip.AddrPort
+	obfuscators []packetObfuscator
+}
+
+func (c *obfsConn) Read(b []byte) (int, error) {
+	for {
+		n, err := c.Conn.Read(b)
+		if err != nil {
+			return 0, err
+		}
+		n, loaded := deobfuscatePacket(c.obfuscators, b[:n], c.destination)
+		if loaded {
+			return n, nil
+		}
+	}
+}
+
+func (c *obfsConn) Write(b []byte) (int, error) {
+	err := obfuscatePacket(c.obfuscators, b, c.destination, func(datagram []byte) error {
+		return common.Error(c.Conn.Write(datagram))
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+type obfsPacketConn struct {
+	net.PacketConn
+	obfuscators []packetObfuscator
+}
+
+func (c *obfsPacketConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	for {
+		n, addr, err := c.PacketConn.ReadFrom(b)
+		if err != nil {
+			return 0, nil, err
+		}
+		n, loaded := deobfuscatePacket(c.obfuscators, b[:n], M.AddrPortFromNet(addr))
+		if loaded {
+			return n, addr, nil
+		}
+	}
+}
+
+func (c *obfsPacketConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	err := obfuscatePacket(c.obfuscators, b, M.AddrPortFromNet(addr), func(datagram []byte) error {
+		return common.Error(c.PacketConn.WriteTo(datagram, addr))
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func obfuscatePacket(obfuscators []packetObfuscator, packet []byte, destination netip.AddrPort, write func(datagram []byte) error) error {
+	if len(obfuscators) == 0 {
+		return write(packet)
+	}
+	return obfuscators[0].Obfuscate(packet, destination, func(datagram []byte) error {
+		return obfuscatePacket(obfuscators[1:], datagram, destination, write)
+	})
+}
+
+func deobfuscatePacket(obfuscators []packetObfuscator, datagram []byte, source netip.AddrPort) (int, bool) {
+	for i := len(obfuscators) - 1; i >= 0; i-- {
+		n, loaded := obfuscators[i].Deobfuscate(datagram, source)
+		if !loaded {
+			return 0, false
+		}
+		datagram = datagram[:n]
+	}
+	return len(datagram), true
+}
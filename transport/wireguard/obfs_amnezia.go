@@ -0,0 +1,212 @@
+package wireguard
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/binary"
+	mRand "math/rand"
+	"net/netip"
+	"sync"
+
+	"github.com/sagernet/sing/common/buf"
+	E "github.com/sagernet/sing/common/exceptions"
+
+	"golang.org/x/crypto/blake2s"
+)
+
+const (
+	messageInitiationSize  = 148
+	messageResponseSize    = 92
+	messageCookieReplySize = 64
+	messageTransportMin    = 32
+
+	messageInitiationMAC1Offset = 116
+	messageResponseMAC1Offset   = 60
+	messageMACSize              = 16
+
+	amneziaMaxJunkCount  = 128
+	amneziaMaxPacketSize = 1280
+)
+
+var labelMAC1 = []byte("mac1----")
+
+// amneziaObfuscator implements the AmneziaWG wire format: junk packets before handshake initiations,
+// random prefixes on handshake messages and replaced message type headers.
+//
+// AmneziaWG computes MAC1 over the replaced header, so MAC1 is recomputed on both directions.
+type amneziaObfuscator struct {
+	options     AmneziaOptions
+	headers     [messageTransportType + 1]uint32
+	localMAC1   [blake2s.Size]byte
+	access      sync.RWMutex
+	peerMAC1    map[netip.AddrPort][blake2s.Size]byte
+	defaultMAC1 *[blake2s.Size]byte
+}
+
+func newAmneziaObfuscator(options AmneziaOptions, localPublicKey []byte) (*amneziaObfuscator, error) {
+	if options.JunkPacketCount < 0 || options.JunkPacketCount > amneziaMaxJunkCount {
+		return nil, E.New("junk packet count must be between 0 and ", amneziaMaxJunkCount)
+	}
+	if options.JunkPacketCount > 0 {
+		if options.JunkPacketMinSize < 0 || options.JunkPacketMaxSize > amneziaMaxPacketSize || options.JunkPacketMinSize > options.JunkPacketMaxSize {
+			return nil, E.New("invalid junk packet size range: ", options.JunkPacketMinSize, "-", options.JunkPacketMaxSize)
+		}
+	}
+	if options.InitPacketJunkSize < 0 || options.InitPacketJunkSize > amneziaMaxPacketSize-messageInitiationSize {
+		return nil, E.New("init packet junk size must be between 0 and ", amneziaMaxPacketSize-messageInitiationSize)
+	}
+	if options.ResponsePacketJunkSize < 0 || options.ResponsePacketJunkSize > amneziaMaxPacketSize-messageResponseSize {
+		return nil, E.New("response packet junk size must be between 0 and ", amneziaMaxPacketSize-messageResponseSize)
+	}
+	if options.InitPacketJunkSize+messageInitiationSize == options.ResponsePacketJunkSize+messageResponseSize {
+		return nil, E.New("init and response packets must have different sizes after adding junk")
+	}
+	obfuscator := &amneziaObfuscator{
+		options: options,
+		headers: [messageTransportType + 1]uint32{
+			messageInitiationType:  options.InitPacketMagicHeader,
+			messageResponseType:    options.ResponsePacketMagicHeader,
+			messageCookieReplyType: options.UnderloadPacketMagicHeader,
+			messageTransportType:   options.TransportPacketMagicHeader,
+		},
+		localMAC1: mac1Key(localPublicKey),
+		peerMAC1:  make(map[netip.AddrPort][blake2s.Size]byte),
+	}
+	for messageType := messageInitiationType; messageType <= messageTransportType; messageType++ {
+		if obfuscator.headers[messageType] == 0 {
+			obfuscator.headers[messageType] = uint32(messageType)
+		}
+		for otherType := messageInitiationType; otherType < messageType; otherType++ {
+			if obfuscator.headers[otherType] == obfuscator.headers[messageType] {
+				return nil, E.New("magic headers must be different")
+			}
+		}
+	}
+	return obfuscator, nil
+}
+
+// SetPeers updates MAC1 keys of peers by endpoint, the only peer is used for unknown endpoints.
+func (o *amneziaObfuscator) SetPeers(peers []peerConfig) {
+	o.access.Lock()
+	defer o.access.Unlock()
+	o.peerMAC1 = make(map[netip.AddrPort][blake2s.Size]byte)
+	o.defaultMAC1 = nil
+	for _, peer := range peers {
+		key := mac1Key(peer.publicKey)
+		if peer.endpoint.IsValid() {
+			o.peerMAC1[peer.endpoint] = key
+		}
+		if len(peers) == 1 {
+			o.defaultMAC1 = &key
+		}
+	}
+}
+
+func (o *amneziaObfuscator) peerKey(destination netip.AddrPort) (*[blake2s.Size]byte, bool) {
+	o.access.RLock()
+	defer o.access.RUnlock()
+	if key, loaded := o.peerMAC1[destination]; loaded {
+		return &key, true
+	}
+	return o.defaultMAC1, o.defaultMAC1 != nil
+}
+
+func (o *amneziaObfuscator) Obfuscate(packet []byte, destination netip.AddrPort, write func(datagram []byte) error) error {
+	if len(packet) < messageHeaderLength {
+		return write(packet)
+	}
+	switch {
+	case packet[0] == messageInitiationType && len(packet) == messageInitiationSize:
+		for i := 0; i < o.options.JunkPacketCount; i++ {
+			junkSize := o.options.JunkPacketMinSize
+			if o.options.JunkPacketMaxSize > o.options.JunkPacketMinSize {
+				junkSize += mRand.Intn(o.options.JunkPacketMaxSize - o.options.JunkPacketMinSize + 1)
+			}
+			err := o.writeJunk(junkSize, write)
+			if err != nil {
+				return err
+			}
+		}
+		return o.obfuscateHandshake(packet, messageInitiationType, o.options.InitPacketJunkSize, messageInitiationMAC1Offset, destination, write)
+	case packet[0] == messageResponseType && len(packet) == messageResponseSize:
+		return o.obfuscateHandshake(packet, messageResponseType, o.options.ResponsePacketJunkSize, messageResponseMAC1Offset, destination, write)
+	case packet[0] == messageCookieReplyType && len(packet) == messageCookieReplySize:
+		binary.LittleEndian.PutUint32(packet, o.headers[messageCookieReplyType])
+	case packet[0] == messageTransportType:
+		binary.LittleEndian.PutUint32(packet, o.headers[messageTransportType])
+	}
+	return write(packet)
+}
+
+func (o *amneziaObfuscator) writeJunk(junkSize int, write func(datagram []byte) error) error {
+	if junkSize == 0 {
+		return write(nil)
+	}
+	junk := buf.Get(junkSize)
+	defer buf.Put(junk)
+	rand.Read(junk)
+	return write(junk)
+}
+
+func (o *amneziaObfuscator) obfuscateHandshake(packet []byte, messageType int, junkSize int, mac1Offset int, destination netip.AddrPort, write func(datagram []byte) error) error {
+	datagram := buf.Get(junkSize + len(packet))
+	defer buf.Put(datagram)
+	rand.Read(datagram[:junkSize])
+	message := datagram[junkSize:]
+	copy(message, packet)
+	binary.LittleEndian.PutUint32(message, o.headers[messageType])
+	if key, loaded := o.peerKey(destination); loaded {
+		computeMAC1(message[mac1Offset:mac1Offset+messageMACSize], key, message[:mac1Offset])
+	}
+	return write(datagram)
+}
+
+func (o *amneziaObfuscator) Deobfuscate(datagram []byte, source netip.AddrPort) (int, bool) {
+	initSize := o.options.InitPacketJunkSize + messageInitiationSize
+	responseSize := o.options.ResponsePacketJunkSize + messageResponseSize
+	switch {
+	case len(datagram) == initSize && o.readHeader(datagram[o.options.InitPacketJunkSize:]) == o.headers[messageInitiationType]:
+		return o.deobfuscateHandshake(datagram, messageInitiationType, o.options.InitPacketJunkSize, messageInitiationMAC1Offset)
+	case len(datagram) == responseSize && o.readHeader(datagram[o.options.ResponsePacketJunkSize:]) == o.headers[messageResponseType]:
+		return o.deobfuscateHandshake(datagram, messageResponseType, o.options.ResponsePacketJunkSize, messageResponseMAC1Offset)
+	case len(datagram) == messageCookieReplySize && o.readHeader(datagram) == o.headers[messageCookieReplyType]:
+		binary.LittleEndian.PutUint32(datagram, messageCookieReplyType)
+		return len(datagram), true
+	case len(datagram) >= messageTransportMin && o.readHeader(datagram) == o.headers[messageTransportType]:
+		binary.LittleEndian.PutUint32(datagram, messageTransportType)
+		return len(datagram), true
+	default:
+		return 0, false
+	}
+}
+
+func (o *amneziaObfuscator) deobfuscateHandshake(datagram []byte, messageType int, junkSize int, mac1Offset int) (int, bool) {
+	message := datagram[junkSize:]
+	var mac1 [messageMACSize]byte
+	computeMAC1(mac1[:], &o.localMAC1, message[:mac1Offset])
+	if !hmac.Equal(mac1[:], message[mac1Offset:mac1Offset+messageMACSize]) {
+		return 0, false
+	}
+	binary.LittleEndian.PutUint32(message, uint32(messageType))
+	computeMAC1(message[mac1Offset:mac1Offset+messageMACSize], &o.localMAC1, message[:mac1Offset])
+	return copy(datagram, message), true
+}
+
+func (o *amneziaObfuscator) readHeader(message []byte) uint32 {
+	return binary.LittleEndian.Uint32(message)
+}
+
+func mac1Key(publicKey []byte) [blake2s.Size]byte {
+	hash, _ := blake2s.New256(nil)
+	hash.Write(labelMAC1)
+	hash.Write(publicKey)
+	var key [blake2s.Size]byte
+	hash.Sum(key[:0])
+	return key
+}
+
+func computeMAC1(destination []byte, key *[blake2s.Size]byte, message []byte) {
+	hash, _ := blake2s.New128(key[:])
+	hash.Write(message)
+	hash.Sum(destination[:0])
+}
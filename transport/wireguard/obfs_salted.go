@@ -0,0 +1,90 @@
+package wireguard
+
+import (
+	"crypto/rand"
+	mRand "math/rand"
+	"net/netip"
+
+	"github.com/sagernet/sing/common/buf"
+
+	"golang.org/x/crypto/blake2s"
+)
+
+const (
+	saltedSaltLength = 8
+	saltedMaskLength = blake2s.Size
+	saltedMaxPadding = 64
+)
+
+// saltedObfuscator hides WireGuard message headers with a per-packet salted XOR mask,
+// and pads handshake messages to random lengths to hide their fixed sizes.
+//
+// datagram = salt || mask(salt)[:] XOR (padding length || packet || padding)
+//
+// When wrapping another obfuscator that replaces message headers, such as
+// AmneziaWG, the message type is left for the inner obfuscator to check.
+type saltedObfuscator struct {
+	key     [blake2s.Size]byte
+	wrapped bool
+}
+
+func newSaltedObfuscator(password string, wrapped bool) *saltedObfuscator {
+	return &saltedObfuscator{
+		key:     blake2s.Sum256([]byte(password)),
+		wrapped: wrapped,
+	}
+}
+
+func (o *saltedObfuscator) mask(salt []byte) [saltedMaskLength]byte {
+	hash, _ := blake2s.New256(o.key[:])
+	hash.Write(salt)
+	var mask [saltedMaskLength]byte
+	hash.Sum(mask[:0])
+	return mask
+}
+
+func (o *saltedObfuscator) Obfuscate(packet []byte, destination netip.AddrPort, write func(datagram []byte) error) error {
+	var paddingLength int
+	if len(packet) > 0 && packet[0] != messageTransportType {
+		paddingLength = mRand.Intn(saltedMaxPadding + 1)
+	}
+	datagram := buf.Get(saltedSaltLength + 1 + len(packet) + paddingLength)
+	defer buf.Put(datagram)
+	salt := datagram[:saltedSaltLength]
+	rand.Read(salt)
+	frame := datagram[saltedSaltLength:]
+	frame[0] = byte(paddingLength)
+	copy(frame[1:], packet)
+	rand.Read(frame[1+len(packet):])
+	o.xorMask(salt, frame)
+	return write(datagram)
+}
+
+func (o *saltedObfuscator) Deobfuscate(datagram []byte, source netip.AddrPort) (int, bool) {
+	if len(datagram) < saltedSaltLength+1+messageHeaderLength {
+		return 0, false
+	}
+	salt := datagram[:saltedSaltLength]
+	frame := datagram[saltedSaltLength:]
+	o.xorMask(salt, frame)
+	if frame[0] > saltedMaxPadding {
+		return 0, false
+	}
+	packetLength := len(frame) - 1 - int(frame[0])
+	if packetLength < messageHeaderLength {
+		return 0, false
+	}
+	messageType := frame[1]
+	if !o.wrapped && (messageType < messageInitiationType || messageType > messageTransportType) {
+		return 0, false
+	}
+	copy(datagram, frame[1:1+packetLength])
+	return packetLength, true
+}
+
+func (o *saltedObfuscator) xorMask(salt []byte, frame []byte) {
+	mask := o.mask(salt)
+	for i := 0; i < len(frame) && i < saltedMaskLength; i++ {
+		frame[i] ^= mask[i]
+	}
+}
@@ -0,0 +1,166 @@
+package wireguard
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/binary"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var testPeerAddr = netip.MustParseAddrPort("192.0.2.1:51820")
+
+func collectDatagrams(t *testing.T, obfuscators []packetObfuscator, packet []byte) [][]byte {
+	var datagrams [][]byte
+	err := obfuscatePacket(obfuscators, packet, testPeerAddr, func(datagram []byte) error {
+		datagrams = append(datagrams, append([]byte(nil), datagram...))
+		return nil
+	})
+	require.NoError(t, err)
+	return datagrams
+}
+
+func newTestMessage(messageType byte, size int) []byte {
+	message := make([]byte, size)
+	rand.Read(message)
+	binary.LittleEndian.PutUint32(message, uint32(messageType))
+	return message
+}
+
+func TestSaltedObfuscator(t *testing.T) {
+	t.Parallel()
+	obfuscator := newSaltedObfuscator("password", false)
+	for _, message := range [][]byte{
+		newTestMessage(messageInitiationType, messageInitiationSize),
+		newTestMessage(messageResponseType, messageResponseSize),
+		newTestMessage(messageCookieReplyType, messageCookieReplySize),
+		newTestMessage(messageTransportType, 1280),
+	} {
+		datagrams := collectDatagrams(t, []packetObfuscator{obfuscator}, bytes.Clone(message))
+		require.Len(t, datagrams, 1)
+		datagram := datagrams[0]
+		require.NotEqual(t, message[:messageHeaderLength], datagram[saltedSaltLength+1:saltedSaltLength+1+messageHeaderLength])
+		if message[0] == messageTransportType {
+			require.Len(t, datagram, saltedSaltLength+1+len(message))
+		}
+		n, loaded := obfuscator.Deobfuscate(datagram, testPeerAddr)
+		require.True(t, loaded)
+		require.Equal(t, message, datagram[:n])
+	}
+}
+
+func TestSaltedObfuscatorRejects(t *testing.T) {
+	t.Parallel()
+	obfuscator := newSaltedObfuscator("password", false)
+	datagrams := collectDatagrams(t, []packetObfuscator{obfuscator}, newTestMessage(messageInitiationType, messageInitiationSize))
+	_, loaded := newSaltedObfuscator("other", false).Deobfuscate(datagrams[0], testPeerAddr)
+	require.False(t, loaded)
+	_, loaded = obfuscator.Deobfuscate(make([]byte, saltedSaltLength+messageHeaderLength), testPeerAddr)
+	require.False(t, loaded)
+	_, loaded = obfuscator.Deobfuscate(newTestMessage(messageTransportType, 64), testPeerAddr)
+	require.False(t, loaded)
+}
+
+func newTestAmnezia(t *testing.T) (*amneziaObfuscator, *amneziaObfuscator, []byte) {
+	localKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	peerKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	options := AmneziaOptions{
+		JunkPacketCount:            3,
+		JunkPacketMinSize:          40,
+		JunkPacketMaxSize:          70,
+		InitPacketJunkSize:         15,
+		ResponsePacketJunkSize:     20,
+		InitPacketMagicHeader:      1020325451,
+		ResponsePacketMagicHeader:  3288052141,
+		UnderloadPacketMagicHeader: 1766607858,
+		TransportPacketMagicHeader: 2528465083,
+	}
+	local, err := newAmneziaObfuscator(options, localKey.PublicKey().Bytes())
+	require.NoError(t, err)
+	local.SetPeers([]peerConfig{{publicKey: peerKey.PublicKey().Bytes(), endpoint: testPeerAddr}})
+	peer, err := newAmneziaObfuscator(options, peerKey.PublicKey().Bytes())
+	require.NoError(t, err)
+	return local, peer, peerKey.PublicKey().Bytes()
+}
+
+func TestAmneziaHandshake(t *testing.T) {
+	t.Parallel()
+	local, peer, peerPublicKey := newTestAmnezia(t)
+	peerMAC1 := mac1Key(peerPublicKey)
+	message := newTestMessage(messageInitiationType, messageInitiationSize)
+	computeMAC1(message[messageInitiationMAC1Offset:messageInitiationMAC1Offset+messageMACSize], &peerMAC1, message[:messageInitiationMAC1Offset])
+	datagrams := collectDatagrams(t, []packetObfuscator{local}, bytes.Clone(message))
+	require.Len(t, datagrams, 4)
+	for _, junk := range datagrams[:3] {
+		require.GreaterOrEqual(t, len(junk), 40)
+		require.LessOrEqual(t, len(junk), 70)
+	}
+	initiation := datagrams[3]
+	require.Len(t, initiation, 15+messageInitiationSize)
+	require.Equal(t, uint32(1020325451), binary.LittleEndian.Uint32(initiation[15:]))
+	n, loaded := peer.Deobfuscate(initiation, testPeerAddr)
+	require.True(t, loaded)
+	require.Equal(t, message, initiation[:n])
+}
+
+func TestAmneziaRejectsBadMAC1(t *testing.T) {
+	t.Parallel()
+	local, peer, _ := newTestAmnezia(t)
+	// MAC1 is computed for the wrong key, so the peer drops the initiation.
+	local.SetPeers([]peerConfig{{publicKey: make([]byte, 32), endpoint: testPeerAddr}})
+	datagrams := collectDatagrams(t, []packetObfuscator{local}, newTestMessage(messageInitiationType, messageInitiationSize))
+	_, loaded := peer.Deobfuscate(datagrams[len(datagrams)-1], testPeerAddr)
+	require.False(t, loaded)
+}
+
+func TestAmneziaTransport(t *testing.T) {
+	t.Parallel()
+	local, peer, _ := newTestAmnezia(t)
+	message := newTestMessage(messageTransportType, 128)
+	datagrams := collectDatagrams(t, []packetObfuscator{local}, bytes.Clone(message))
+	require.Len(t, datagrams, 1)
+	require.Equal(t, uint32(2528465083), binary.LittleEndian.Uint32(datagrams[0]))
+	n, loaded := peer.Deobfuscate(datagrams[0], testPeerAddr)
+	require.True(t, loaded)
+	require.Equal(t, message, datagrams[0][:n])
+	_, loaded = peer.Deobfuscate(newTestMessage(messageTransportType, 128), testPeerAddr)
+	require.False(t, loaded)
+}
+
+func TestAmneziaOptions(t *testing.T) {
+	t.Parallel()
+	_, err := newAmneziaObfuscator(AmneziaOptions{
+		InitPacketJunkSize:     messageResponseSize,
+		ResponsePacketJunkSize: messageInitiationSize,
+	}, make([]byte, 32))
+	require.Error(t, err)
+	_, err = newAmneziaObfuscator(AmneziaOptions{
+		InitPacketMagicHeader:     5,
+		ResponsePacketMagicHeader: 5,
+	}, make([]byte, 32))
+	require.Error(t, err)
+	_, err = newAmneziaObfuscator(AmneziaOptions{
+		JunkPacketCount:   1,
+		JunkPacketMinSize: 10,
+		JunkPacketMaxSize: 5,
+	}, make([]byte, 32))
+	require.Error(t, err)
+}
+
+func TestObfuscatorChain(t *testing.T) {
+	t.Parallel()
+	local, peer, _ := newTestAmnezia(t)
+	localChain := []packetObfuscator{local, newSaltedObfuscator("password", true)}
+	peerChain := []packetObfuscator{peer, newSaltedObfuscator("password", true)}
+	message := newTestMessage(messageTransportType, 256)
+	datagrams := collectDatagrams(t, localChain, bytes.Clone(message))
+	require.Len(t, datagrams, 1)
+	n, loaded := deobfuscatePacket(peerChain, datagrams[0], testPeerAddr)
+	require.True(t, loaded)
+	require.Equal(t, message, datagrams[0][:n])
+}